@@ -1,12 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/willis7/service_status/status"
@@ -16,10 +24,135 @@ func init() {
 	status.LoadTemplate()
 }
 
+// defaultInterval is how often services are checked when
+// the config does not specify an interval
+const defaultInterval = time.Minute
+
+// defaultFlapWindow is the period state changes are counted
+// over when flap detection is enabled
+const defaultFlapWindow = time.Hour
+
+// janitorInterval is how often the storage is pruned
+const janitorInterval = time.Hour
+
+// sloInterval is how often the error budgets are checked
+const sloInterval = time.Minute
+
+// The status history is written in batches of historyBatchSize
+// records, at least every historyFlushInterval
+const (
+	historyBatchSize     = 1000
+	historyFlushInterval = time.Minute
+)
+
+// Notification queue defaults, see status.NotificationManager.Start
+const (
+	defaultNotifyWorkers = 4
+	notifyQueueSize      = 100
+)
+
 // Config holds a list of services to be
 // checked
 type Config struct {
-	Services []status.Service `json:"services"`
+	Services            []status.Service           `json:"services"`
+	Notifiers           []status.NotifierConfig    `json:"notifiers,omitempty"`
+	MaintenanceSchedule []status.MaintenanceWindow `json:"maintenance_schedule,omitempty"`
+	Interval            string                     `json:"interval,omitempty"`
+	AlertCooldown       string                     `json:"alert_cooldown,omitempty"`
+	FlapThreshold       int                        `json:"flap_threshold,omitempty"`
+	FlapWindow          string                     `json:"flap_window,omitempty"`
+	// NotifyRetries overrides how often failed deliveries are retried,
+	// NotifyRetryBackoff the wait before the first retry. Alerts which
+	// still fail are kept in DeadLetterFile.
+	NotifyRetries      *int   `json:"notify_retries,omitempty"`
+	NotifyRetryBackoff string `json:"notify_retry_backoff,omitempty"`
+	DeadLetterFile     string `json:"dead_letter_file,omitempty"`
+	// NotifyWorkers is how many alerts are delivered at once
+	NotifyWorkers int `json:"notify_workers,omitempty"`
+	// Escalations are told of incidents which stay unresolved
+	Escalations []status.EscalationConfig `json:"escalations,omitempty"`
+	// ReminderInterval re-sends the alert of a service which stays
+	// down, at most MaxReminders times when set
+	ReminderInterval string `json:"reminder_interval,omitempty"`
+	MaxReminders     int    `json:"max_reminders,omitempty"`
+	// Digests send scheduled summaries
+	Digests []status.DigestConfig `json:"digests,omitempty"`
+	// Discovery are the sources of services checked along with the
+	// configured ones, such as the Services of a Kubernetes cluster
+	Discovery []status.DiscoveryConfig `json:"discovery,omitempty"`
+	// RemoteConfig are the stores more of the config, such as the
+	// services, is fetched from, again every RemoteConfigInterval
+	RemoteConfig         []status.RemoteConfigSource `json:"remote_config,omitempty"`
+	RemoteConfigInterval string                      `json:"remote_config_interval,omitempty"`
+
+	// StorageFile keeps the incident history across restarts. It is
+	// the location given to the StorageType backend, a JSON file by
+	// default.
+	StorageType string `json:"storage_type,omitempty"`
+	StorageFile string `json:"storage_file,omitempty"`
+	// Tenant is the partition of the storage this status page
+	// uses, so several pages can share one storage
+	Tenant string `json:"tenant,omitempty"`
+	// StorageTimeout is how long a query waits on a busy storage
+	StorageTimeout string `json:"storage_timeout,omitempty"`
+	// StorageKey encrypts the messages in the storage. It is a
+	// base64 encoded AES key, usually "env:NAME".
+	StorageKey string `json:"storage_key,omitempty"`
+	// RetentionConfig sets how long the storage keeps its records
+	status.RetentionConfig
+	// Backup backs up the storage on an interval
+	Backup *status.BackupConfig `json:"backup,omitempty"`
+	// Mail is the SMTP server the status updates are mailed
+	// through to the subscribers of the page, who subscribe on it
+	Mail *status.MailConfig `json:"mail,omitempty"`
+	// MonitorStorage checks the health of the storage as a service
+	// named "storage", alerting when writes fail
+	MonitorStorage bool `json:"monitor_storage,omitempty"`
+	// MetricsPush pushes the results of every sweep to a Pushgateway
+	// or a remote-write endpoint
+	MetricsPush *status.MetricsPushConfig `json:"metrics_push,omitempty"`
+	// PublicURL is where the status page is reached, used to link
+	// alerts to the acknowledgement of their incident
+	PublicURL string `json:"public_url,omitempty"`
+	// Listen are the addresses the page is served on: "host:port",
+	// or "unix:" followed by the path of a unix socket. It is ":8080"
+	// by default, or the addr of TLS.
+	Listen []string `json:"listen,omitempty"`
+	// ShutdownTimeout is how long the requests in flight may take to
+	// finish on SIGTERM, 30s by default
+	ShutdownTimeout string `json:"shutdown_timeout,omitempty"`
+	// RequestTimeout is how long a request may take before it is
+	// answered 503, 30s by default. The live updates and exports
+	// stream for as long as they need.
+	RequestTimeout string `json:"request_timeout,omitempty"`
+	// ValidateNotifiers sends a test alert to every notifier at
+	// startup and exits if one fails
+	ValidateNotifiers bool `json:"validate_notifiers,omitempty"`
+	// Theme brands the status page
+	Theme status.Theme `json:"theme,omitempty"`
+	// Auth puts the page, or its private services, behind sign-in
+	Auth status.AuthConfig `json:"auth,omitempty"`
+	// TLS serves the page over HTTPS instead of plain HTTP on :8080
+	TLS *status.TLSConfig `json:"tls,omitempty"`
+	// CORS lets the pages of other origins call the API
+	CORS status.CORSConfig `json:"cors,omitempty"`
+	// AdminToken enables the API managing notifiers at runtime,
+	// presented as a bearer token. It may be "env:NAME".
+	AdminToken string `json:"admin_token,omitempty"`
+	// AllowAnonymousWrites lets anyone send heartbeats, which
+	// otherwise need an API key of their scope or the admin token
+	AllowAnonymousWrites bool `json:"allow_anonymous_writes,omitempty"`
+
+	// Region names where this instance runs. With Server set it
+	// runs as an agent, reporting its results to the central
+	// server with AgentToken instead of serving a page.
+	Region     string `json:"region,omitempty"`
+	Server     string `json:"server,omitempty"`
+	AgentToken string `json:"agent_token,omitempty"`
+	// Agents maps the region of each agent reporting to this
+	// server to its token
+	Agents map[string]string `json:"agents,omitempty"`
+	Quorum int               `json:"quorum,omitempty"`
 }
 
 // CreateFactories will return a slice of Pinger concrete services
@@ -27,78 +160,1030 @@ func (c *Config) CreateFactories() ([]status.Pinger, error) {
 	var checks []status.Pinger
 
 	for _, service := range c.Services {
-		switch service.Type {
-		case "ping":
-			pf := status.PingFactory{}
-			p, err := pf.Create(service)
-			if err != nil {
-				return nil, errors.New("failed to create ping object")
-			}
-			checks = append(checks, p)
-		case "grep":
-			gf := status.GrepFactory{}
-			g, err := gf.Create(service)
-			if err != nil {
-				return nil, errors.New("failed to create ping object")
-			}
-			checks = append(checks, g)
+		if errs := status.ValidateService(service); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid %s: %v", service.ID(), errs[0])
 		}
+
+		check, err := status.NewChecker(service)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s object for %s: %v", service.Type, service.ID(), err)
+		}
+		checks = append(checks, check)
 	}
 
 	return checks, nil
 }
 
-// LoadConfiguration takes a configuration file and returns
-// a Config struct
+// CreateNotifiers will return a slice of the configured Notifiers
+func (c *Config) CreateNotifiers() ([]status.Notifier, error) {
+	var notifiers []status.Notifier
+
+	for _, nc := range c.Notifiers {
+		n, err := status.NewNotifier(nc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s notifier: %v", nc.Type, err)
+		}
+		notifiers = append(notifiers, n)
+	}
+
+	return notifiers, nil
+}
+
+// LoadConfiguration takes a configuration file, or a directory of
+// config fragments, and returns a Config struct
 func LoadConfiguration(file string) (Config, error) {
+	config, _, err := loadConfiguration(file)
+	return config, err
+}
+
+// loadConfiguration returns the Config of file and the JSON it was
+// decoded from, with its fragments merged and its secrets resolved
+func loadConfiguration(file string) (Config, []byte, error) {
 	var config Config
-	configFile, err := os.Open(file)
-	defer configFile.Close()
+	b, _, err := status.ReadConfig(file)
 	if err != nil {
-		return config, err
+		return config, nil, err
 	}
-	jsonParser := json.NewDecoder(configFile)
-	jsonParser.Decode(&config)
-	return config, nil
+	// secrets come from the environment or files, not the config
+	b, err = status.ResolveSecrets(b, &config)
+	if err != nil {
+		return config, nil, err
+	}
+	err = json.Unmarshal(b, &config)
+	return config, b, err
+}
+
+// Validate returns every problem with the config, each naming the
+// setting at fault, so they can be fixed at once rather than one
+// failed start at a time
+func (c *Config) Validate() []error {
+	errs := status.ValidateServices(c.Services)
+	setting := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, &status.FieldError{Field: field, Err: err})
+		}
+	}
+
+	for i, nc := range c.Notifiers {
+		if err := status.ValidateNotifier(nc); err != nil {
+			fe := err.(*status.FieldError)
+			setting(fmt.Sprintf("notifiers[%d].%s", i, fe.Field), fe.Err)
+		}
+	}
+	for i, ec := range c.Escalations {
+		_, err := status.NewEscalation(ec)
+		setting(fmt.Sprintf("escalations[%d]", i), err)
+	}
+	for i, w := range c.MaintenanceSchedule {
+		setting(fmt.Sprintf("maintenance_schedule[%d]", i), w.Validate())
+	}
+	for _, d := range []struct{ field, value string }{
+		{"interval", c.Interval},
+		{"alert_cooldown", c.AlertCooldown},
+		{"flap_window", c.FlapWindow},
+		{"notify_retry_backoff", c.NotifyRetryBackoff},
+		{"reminder_interval", c.ReminderInterval},
+		{"storage_timeout", c.StorageTimeout},
+		{"request_timeout", c.RequestTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"remote_config_interval", c.RemoteConfigInterval},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil || v <= 0 {
+			setting(d.field, fmt.Errorf("%q is not a positive duration", d.value))
+		}
+	}
+	for i, dc := range c.Digests {
+		_, err := status.NewDigestSchedule(dc)
+		setting(fmt.Sprintf("digests[%d]", i), err)
+	}
+	for i, dc := range c.Discovery {
+		_, err := status.NewDiscoverer(dc)
+		setting(fmt.Sprintf("discovery[%d]", i), err)
+	}
+	for i, rc := range c.RemoteConfig {
+		_, err := status.NewRemoteConfig(rc)
+		setting(fmt.Sprintf("remote_config[%d]", i), err)
+	}
+
+	_, err := status.NewRetention(c.RetentionConfig)
+	setting("retention", err)
+	if c.Backup != nil {
+		_, err := status.NewBackupSchedule(*c.Backup)
+		setting("backup", err)
+	}
+	if c.Mail != nil {
+		_, err := status.NewSMTPMailer(*c.Mail)
+		setting("mail", err)
+	}
+	if c.MetricsPush != nil {
+		_, err := status.NewMetricsPusher(*c.MetricsPush)
+		setting("metrics_push", err)
+	}
+	setting("theme", c.Theme.Validate())
+	_, err = status.NewAuth(c.Auth, c.PublicURL, c.AdminToken)
+	setting("auth", err)
+	_, err = status.NewCORS(c.CORS)
+	setting("cors", err)
+	return errs
+}
+
+// NewNotificationManager returns the NotificationManager of the
+// notifiers, escalations and alert settings of the config
+func (c *Config) NewNotificationManager() (*status.NotificationManager, error) {
+	notifiers, err := c.CreateNotifiers()
+	if err != nil {
+		return nil, fmt.Errorf("create notifiers: %v", err)
+	}
+
+	var cooldown time.Duration
+	if c.AlertCooldown != "" {
+		cooldown, err = time.ParseDuration(c.AlertCooldown)
+		if err != nil {
+			return nil, fmt.Errorf("parse alert cooldown: %v", err)
+		}
+	}
+
+	nm := status.NewNotificationManager(notifiers, cooldown)
+	for _, nc := range c.Notifiers {
+		nm.NotifierIDs = append(nm.NotifierIDs, status.NotifierID(nc))
+	}
+	nm.FlapThreshold = c.FlapThreshold
+	nm.FlapWindow = defaultFlapWindow
+	if c.FlapWindow != "" {
+		nm.FlapWindow, err = time.ParseDuration(c.FlapWindow)
+		if err != nil {
+			return nil, fmt.Errorf("parse flap window: %v", err)
+		}
+	}
+
+	if c.NotifyRetries != nil {
+		nm.Retries = *c.NotifyRetries
+	}
+	if c.NotifyRetryBackoff != "" {
+		nm.RetryBackoff, err = time.ParseDuration(c.NotifyRetryBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("parse notify retry backoff: %v", err)
+		}
+	}
+	if c.ReminderInterval != "" {
+		nm.ReminderInterval, err = time.ParseDuration(c.ReminderInterval)
+		if err != nil {
+			return nil, fmt.Errorf("parse reminder interval: %v", err)
+		}
+	}
+	nm.MaxReminders = c.MaxReminders
+	for _, ec := range c.Escalations {
+		e, err := status.NewEscalation(ec)
+		if err != nil {
+			return nil, fmt.Errorf("create escalation: %v", err)
+		}
+		nm.Escalations = append(nm.Escalations, e)
+	}
+	return nm, nil
 }
 
 func main() {
-	if len(os.Args) < 2 {
+	testNotifiers := flag.Bool("test-notifiers", false, "send a test alert to every notifier and exit")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "export" {
+		runExport(args[1:])
+		return
+	}
+	if len(args) > 0 && (args[0] == "backup" || args[0] == "restore") {
+		runBackup(args[0], args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "token" {
+		runToken(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "keys" {
+		runKeys(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "validate" {
+		runValidate(args[1:])
+		return
+	}
+	migrate := len(args) > 0 && args[0] == "migrate"
+	if migrate {
+		args = args[1:]
+	}
+	if len(args) < 1 {
 		fmt.Println("Missing path to config")
 		os.Exit(2)
 	}
-	configPath := os.Args[1]
+	configPath := args[0]
 
-	fmt.Println("Starting the application...")
 	// read the config file to determine which services need to be checked
-	config, _ := LoadConfiguration(configPath)
+	config, loaded, err := loadConfiguration(configPath)
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+
+	if migrate {
+		// opening the storage applies its migrations
+		db, err := openStorage(config)
+		if err != nil {
+			log.Fatalf("migrate storage: %v", err)
+		}
+		db.Close()
+		fmt.Println("Storage is up to date")
+		return
+	}
+
+	fmt.Println("Starting the application...")
+
+	if errs := config.Validate(); len(errs) > 0 {
+		for _, err := range errs {
+			log.Printf("invalid config: %v", err)
+		}
+		log.Fatalf("invalid config: %d problems, see service_status validate", len(errs))
+	}
+
+	if config.Theme.Templates != "" {
+		if err := status.LoadTemplateOverrides(config.Theme.Templates); err != nil {
+			log.Fatalf("load theme templates: %v", err)
+		}
+	}
 
 	services, err := config.CreateFactories()
 	if err != nil {
 		log.Fatalf("create factories: %v", err)
 	}
 
-	down := make(map[string]int)
-	var up []string
+	nm, err := config.NewNotificationManager()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	interval := defaultInterval
+	if config.Interval != "" {
+		interval, err = time.ParseDuration(config.Interval)
+		if err != nil {
+			log.Fatalf("parse interval: %v", err)
+		}
+	}
+
+	nm.DeadLetters, err = status.NewDeadLetterStore(config.DeadLetterFile)
+	if err != nil {
+		log.Fatalf("load dead letters: %v", err)
+	}
+	nm.BaseURL = config.PublicURL
+	if config.StorageTimeout != "" {
+		status.StorageTimeout, err = time.ParseDuration(config.StorageTimeout)
+		if err != nil || status.StorageTimeout <= 0 {
+			log.Fatalf("parse storage timeout: %q is not a positive duration", config.StorageTimeout)
+		}
+	}
+	requestTimeout := 30 * time.Second
+	if config.RequestTimeout != "" {
+		requestTimeout, err = time.ParseDuration(config.RequestTimeout)
+		if err != nil || requestTimeout <= 0 {
+			log.Fatalf("parse request timeout: %q is not a positive duration", config.RequestTimeout)
+		}
+	}
+	shutdownTimeout := 30 * time.Second
+	if config.ShutdownTimeout != "" {
+		shutdownTimeout, err = time.ParseDuration(config.ShutdownTimeout)
+		if err != nil || shutdownTimeout <= 0 {
+			log.Fatalf("parse shutdown timeout: %q is not a positive duration", config.ShutdownTimeout)
+		}
+	}
+	db, err := openStorage(config)
+	if err != nil {
+		log.Fatalf("open storage: %v", err)
+	}
+	// components subscribe to the changes written to the storage
+	events := status.NewEventBus()
+	health := status.NewStorageHealth()
+	nm.Storage = status.WithEvents(status.WithHealth(db, health), events)
+	if config.MonitorStorage {
+		services = append(services, status.NewStoragePinger(health))
+	}
+	if err := nm.LoadNotifiers(context.Background()); err != nil {
+		log.Fatalf("load notifiers: %v", err)
+	}
+	retention, err := status.NewRetention(config.RetentionConfig)
+	if err != nil {
+		log.Fatalf("parse retention: %v", err)
+	}
+	var backups *status.BackupSchedule
+	if config.Backup != nil {
+		backups, err = status.NewBackupSchedule(*config.Backup)
+		if err != nil {
+			log.Fatalf("parse backup: %v", err)
+		}
+	}
+
+	if *testNotifiers || config.ValidateNotifiers {
+		failed := false
+		for _, t := range nm.TestNotifiers() {
+			if t.OK {
+				fmt.Printf("notifier %d: ok\n", t.Notifier)
+				continue
+			}
+			fmt.Printf("notifier %d: %s\n", t.Notifier, t.Error)
+			failed = true
+		}
+		if failed {
+			os.Exit(1)
+		}
+		if *testNotifiers {
+			return
+		}
+	}
+
+	workers := defaultNotifyWorkers
+	if config.NotifyWorkers > 0 {
+		workers = config.NotifyWorkers
+	}
+	nm.Start(workers, notifyQueueSize)
+
+	monitor := status.NewMonitor(services, nm)
+	monitor.History = nm.Storage
+	monitor.HistoryWriter = status.NewStatusWriter(nm.Storage, historyBatchSize)
+	go monitor.HistoryWriter.Run(historyFlushInterval)
+	if config.MetricsPush != nil {
+		monitor.Metrics, err = status.NewMetricsPusher(*config.MetricsPush)
+		if err != nil {
+			log.Fatalf("parse metrics_push: %v", err)
+		}
+		go monitor.Metrics.Run()
+	}
+	monitor.Maintenance = status.NewMaintenanceRegistry()
+	monitor.Maintenance.Schedule = config.MaintenanceSchedule
+	monitor.Maintenance.Storage = nm.Storage
+	if err := monitor.Maintenance.LoadScheduled(context.Background()); err != nil {
+		log.Fatalf("load scheduled maintenance: %v", err)
+	}
+	// services added in the admin area are checked along with those
+	// of the config
+	admin := status.NewAdmin(monitor, config.Services, nil)
+	if err := admin.LoadServices(context.Background()); err != nil {
+		log.Fatalf("load services: %v", err)
+	}
+
+	if config.Server != "" {
+		runAgent(config, monitor, interval)
+		return
+	}
+
+	// discovered services are checked along with the configured ones,
+	// and the services of the config are reloaded through discovery
+	discovery, err := status.NewDiscovery(admin, config.Services, config.Discovery)
+	if err != nil {
+		log.Fatalf("create discovery: %v", err)
+	}
+	if len(config.Discovery) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if changes, err := discovery.Refresh(ctx); err != nil {
+			log.Printf("discover services: %v", err)
+		} else {
+			log.Printf("Discovered services, %v", changes)
+		}
+		cancel()
+		go discovery.Run()
+	}
+
+	if len(config.Agents) > 0 {
+		// reports from agents which missed a few intervals are stale
+		monitor.Regions = status.NewRegionStore(3 * interval)
+		monitor.LocalRegion = config.Region
+		monitor.Quorum = config.Quorum
+	}
+
+	go status.RunJanitor(nm.Storage, retention, janitorInterval)
+	slos := status.NewSLOMonitor(config.Services, nm)
+	go slos.Run(sloInterval)
+	if backups != nil {
+		go backups.Run(nm.Storage)
+	}
+
+	for _, dc := range config.Digests {
+		ds, err := status.NewDigestSchedule(dc)
+		if err != nil {
+			log.Fatalf("create digest: %v", err)
+		}
+		go ds.Run(monitor, nm.Storage)
+	}
+
+	var subs *status.Subscriptions
+	if config.Mail != nil {
+		mailer, err := status.NewSMTPMailer(*config.Mail)
+		if err != nil {
+			log.Fatalf("create mailer: %v", err)
+		}
+		subs = status.NewSubscriptions(nm.Storage, mailer, config.PublicURL)
+		go subs.Run(events)
+	}
+
+	auth, err := status.NewAuth(config.Auth, config.PublicURL, config.AdminToken)
+	if err != nil {
+		log.Fatalf("create auth: %v", err)
+	}
+	var certs *status.CertManager
+	if config.TLS != nil {
+		certs, err = status.NewCertManager(*config.TLS)
+		if err != nil {
+			log.Fatalf("load certificate: %v", err)
+		}
+	}
+	cors, err := status.NewCORS(config.CORS)
+	if err != nil {
+		log.Fatalf("parse cors: %v", err)
+	}
+	keys := status.NewAPIKeys(nm.Storage, config.AdminToken)
+	keys.AllowAnonymous = config.AllowAnonymousWrites
+	admin.Keys = keys
+	auth.Keys = keys
+	auth.Hidden = func() bool {
+		for _, s := range monitor.Services() {
+			if s.Private {
+				return true
+			}
+		}
+		return false
+	}
 
-	for _, service := range services {
-		err := service.Status()
+	// the theme changes when the config is reloaded
+	var theme atomic.Value
+	theme.Store(config.Theme)
+	page := status.NewPageStore(newPage(monitor, nm.Storage, config.Theme, subs != nil))
+	// viewers of the page are told to update it once it shows the
+	// changes written to the storage since its last update
+	var changed int32
+	changes, _ := events.Subscribe(16)
+	go func() {
+		for e := range changes {
+			if e.Type != status.EventPageUpdated {
+				atomic.StoreInt32(&changed, 1)
+			}
+		}
+	}()
+	admin.Refresh = func() {
+		page.Set(newPage(monitor, nm.Storage, theme.Load().(status.Theme), subs != nil))
+		if atomic.SwapInt32(&changed, 0) == 1 {
+			events.Publish(status.Event{Type: status.EventPageUpdated, Time: time.Now()})
+		}
+	}
+	// re-check the services on an interval so passive checks such
+	// as heartbeats are re-evaluated
+	go func() {
+		for range time.Tick(interval) {
+			admin.Refresh()
+		}
+	}()
+	reloads := &reloader{path: configPath, running: config, applied: loaded, admin: admin, discovery: discovery, nm: nm, slos: slos, theme: &theme}
+	go reloads.watch()
+
+	mux := http.NewServeMux()
+	// create and serve the page. Viewers who have not signed in see
+	// the page without the private services, and the APIs listing
+	// every service need them to sign in once any service is private.
+	// The API answers the origins allowed by CORS before sign-in, so
+	// their preflight requests succeed.
+	mux.HandleFunc("/", auth.Optional(status.Index(page)))
+	mux.HandleFunc("/static/", status.StaticHandler())
+	mux.HandleFunc("/incidents", auth.Optional(status.TimelineHandler(nm.Storage, page, monitor.Maintenance)))
+	mux.HandleFunc("/auth/", status.AuthHandler(auth))
+	mux.HandleFunc("/api/status", cors.Wrap(auth.Optional(status.APIStatus(page))))
+	mux.HandleFunc("/badge/", cors.Wrap(auth.Optional(status.BadgeHandler(page))))
+	mux.HandleFunc("/api/v2/", cors.Wrap(auth.Optional(status.StatuspageHandler(page, config.PublicURL))))
+	mux.HandleFunc("/api/events", cors.Wrap(auth.Optional(status.LiveHandler(events))))
+	if subs != nil {
+		mux.HandleFunc("/subscribe", auth.Optional(status.SubscribeHandler(subs)))
+		mux.HandleFunc("/subscribe/", auth.Optional(status.SubscribeHandler(subs)))
+		mux.HandleFunc("/api/subscribers/", keys.Identify(status.SubscriberHandler(subs, config.AdminToken)))
+	}
+	mux.HandleFunc("/api/heartbeat/", keys.Require(status.ScopeHeartbeat, status.HeartbeatHandler(status.Heartbeats)))
+	mux.HandleFunc("/api/maintenance/", auth.Protect(keys.Identify(status.MaintenanceHandler(monitor.Maintenance, config.AdminToken))))
+	mux.HandleFunc("/api/scheduled-maintenance/", cors.Wrap(auth.Protect(keys.Identify(status.ScheduledMaintenanceHandler(monitor.Maintenance, config.AdminToken)))))
+	mux.HandleFunc("/api/deadletters/", keys.Identify(status.DeadLetterHandler(nm, config.AdminToken)))
+	mux.HandleFunc("/api/notifiers/test", keys.Identify(status.NotifierTestHandler(nm, config.AdminToken)))
+	mux.HandleFunc("/api/alerts", cors.Wrap(auth.Protect(status.AlertsHandler(nm.Storage))))
+	mux.HandleFunc("/api/export/", auth.Protect(status.ExportHandler(nm.Storage)))
+	mux.HandleFunc("/api/history", cors.Wrap(auth.Protect(status.HistoryHandler(nm.Storage))))
+	mux.HandleFunc("/api/services", cors.Wrap(auth.Protect(status.ServicesHandler(nm.Storage, page))))
+	mux.HandleFunc("/api/services/", cors.Wrap(auth.Protect(status.ServicesHandler(nm.Storage, page))))
+	mux.HandleFunc("/api/uptime", cors.Wrap(auth.Protect(status.UptimeHandler(nm.Storage))))
+	mux.HandleFunc("/graphql", cors.Wrap(auth.Protect(status.GraphQLHandler(nm.Storage, page))))
+	mux.HandleFunc("/api/openapi.json", cors.Wrap(status.OpenAPIHandler()))
+	mux.HandleFunc("/api/slo", cors.Wrap(auth.Protect(status.SLOHandler(slos))))
+	mux.HandleFunc("/metrics", status.MetricsHandler(health))
+	// load balancers and orchestrators probe these without signing in.
+	// A sweep missing two intervals in a row makes the server unready.
+	mux.HandleFunc("/healthz", status.LivenessHandler())
+	mux.HandleFunc("/readyz", status.ReadinessHandler(nm.Storage, health, page, 3*interval))
+	mux.HandleFunc("/api/notifiers/", keys.Identify(status.NotifierHandler(nm, config.AdminToken)))
+	mux.HandleFunc("/admin/", keys.Identify(status.AdminHandler(admin, config.AdminToken)))
+	mux.HandleFunc("/api/incidents/", cors.Wrap(auth.Protect(keys.Identify(status.IncidentHandler(nm, config.AdminToken)))))
+	mux.HandleFunc("/ack/", auth.Optional(keys.Identify(status.AckHandler(nm, auth))))
+	if monitor.Regions != nil {
+		mux.HandleFunc("/api/agent/results", status.AgentHandler(monitor.Regions, config.Agents))
+	}
+	// every request gets an ID, is logged and compressed, and is
+	// answered 503 once it runs past the request timeout; a handler
+	// which panics answers 500 instead of dropping the connection
+	handler := status.Chain(mux.ServeHTTP,
+		status.WithRequestID,
+		status.LogRequests,
+		status.Compress,
+		status.Timeout(requestTimeout, "/api/events", "/api/export/"),
+		status.Recover,
+	)
+	server := &http.Server{Handler: handler}
+	// the live updates never go idle, so they end once the server
+	// shuts down rather than hold up its draining
+	server.RegisterOnShutdown(events.Close)
+	servers := []*http.Server{server}
+	listen := config.Listen
+	if len(listen) == 0 {
+		listen = []string{":8080"}
+	}
+	if certs != nil {
+		server.TLSConfig = certs.TLSConfig()
+		if len(config.Listen) == 0 {
+			listen = []string{certs.Addr}
+		}
+		if certs.RedirectAddr != "" {
+			redirect := &http.Server{Addr: certs.RedirectAddr, Handler: certs.RedirectHandler()}
+			servers = append(servers, redirect)
+			go func() {
+				if err := redirect.ListenAndServe(); err != http.ErrServerClosed {
+					log.Fatalf("serve %s: %v", certs.RedirectAddr, err)
+				}
+			}()
+		}
+		// certificates are reloaded or renewed twice a day, and an
+		// hour after a renewal failed
+		go func() {
+			for {
+				wait := 12 * time.Hour
+				ctx, cancel := context.WithTimeout(context.Background(), status.RenewTimeout)
+				if err := certs.Renew(ctx); err != nil {
+					log.Printf("renew certificate: %v", err)
+					wait = time.Hour
+				}
+				cancel()
+				time.Sleep(wait)
+			}
+		}()
+	}
+	for _, addr := range listen {
+		l, err := status.Listen(addr)
 		if err != nil {
-			down[service.GetService().URL] = 60
-			break
+			log.Fatalf("listen on %s: %v", addr, err)
 		}
-		up = append(up, service.GetService().URL)
+		log.Printf("Serving on %s", addr)
+		go func(addr string, l net.Listener) {
+			var err error
+			if certs != nil {
+				err = server.ServeTLS(l, "", "")
+			} else {
+				err = server.Serve(l)
+			}
+			if err != http.ErrServerClosed {
+				log.Fatalf("serve %s: %v", addr, err)
+			}
+		}(addr, l)
 	}
 
-	p := status.Page{
-		Title:  "My Status",
-		Status: "danger",
-		Up:     up,
-		Down:   down,
-		Time:   time.Now().Format("2006-01-02 15:04:05"),
+	// on SIGTERM the servers stop accepting connections and finish
+	// the requests in flight, and the queued alerts and the buffered
+	// history are written, before the storage is closed
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	<-stop
+	log.Printf("Shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, s := range servers {
+		if err := s.Shutdown(ctx); err != nil {
+			log.Printf("drain connections: %v", err)
+		}
+	}
+	nm.Stop()
+	// the draining may have used up ctx
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelFlush()
+	if err := monitor.HistoryWriter.Flush(flushCtx); err != nil {
+		log.Printf("flush status history: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Printf("close storage: %v", err)
+	}
+}
+
+// runExport writes the status history, alerts or incidents in the
+// storage to stdout:
+//
+//	service_status export [-format csv|ndjson] [-start T] [-end T] history|alerts|incidents config.json
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", status.ExportCSV, "csv or ndjson")
+	from := fs.String("start", "", "export from this RFC 3339 time")
+	to := fs.String("end", "", "export until this RFC 3339 time, now by default")
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fmt.Println("Usage: service_status export [-format csv|ndjson] [-start T] [-end T] history|alerts|incidents config.json")
+		os.Exit(2)
 	}
 
-	// create and serve the page
-	http.HandleFunc("/", status.Index(p))
-	http.ListenAndServe(":8080", nil)
+	start, end := time.Time{}, time.Now()
+	var err error
+	if *from != "" {
+		if start, err = time.Parse(time.RFC3339, *from); err != nil {
+			log.Fatalf("parse start: %v", err)
+		}
+	}
+	if *to != "" {
+		if end, err = time.Parse(time.RFC3339, *to); err != nil {
+			log.Fatalf("parse end: %v", err)
+		}
+	}
+
+	config, _ := LoadConfiguration(fs.Arg(1))
+	db, err := openStorage(config)
+	if err != nil {
+		log.Fatalf("open storage: %v", err)
+	}
+	defer db.Close()
+	if err := status.Export(context.Background(), os.Stdout, db, fs.Arg(0), *format, start, end); err != nil {
+		log.Fatalf("export: %v", err)
+	}
+}
+
+// runValidate checks a config and prints all its problems, exiting 1
+// when there are any:
+//
+//	service_status validate config.json
+func runValidate(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: service_status validate config.json")
+		os.Exit(2)
+	}
+	config, err := LoadConfiguration(args[0])
+	if os.IsNotExist(err) {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var errs []error
+	if err != nil {
+		// a field of the wrong type stops only that field from
+		// loading, the others are still checked
+		errs = append(errs, err)
+	}
+	errs = append(errs, config.Validate()...)
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		fmt.Printf("%s: %d problem(s)\n", args[0], len(errs))
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid\n", args[0])
+}
+
+// runBackup writes the storage to FILE, or replaces it with the
+// backup in FILE. Stop the service before a restore, or it overwrites
+// the restored storage.
+//
+//	service_status backup|restore FILE config.json
+func runBackup(command string, args []string) {
+	if len(args) < 2 {
+		fmt.Printf("Usage: service_status %s FILE config.json\n", command)
+		os.Exit(2)
+	}
+	config, _ := LoadConfiguration(args[1])
+	db, err := openStorage(config)
+	if err != nil {
+		log.Fatalf("open storage: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if command == "restore" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatalf("restore: %v", err)
+		}
+		defer f.Close()
+		if err := db.Restore(ctx, f); err != nil {
+			log.Fatalf("restore: %v", err)
+		}
+		fmt.Println("Storage restored")
+		return
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		log.Fatalf("backup: %v", err)
+	}
+	if err := db.Backup(ctx, f); err != nil {
+		log.Fatalf("backup: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("backup: %v", err)
+	}
+	fmt.Println("Storage backed up")
+}
+
+// runToken prints an access token to the page, signed with the
+// token_secret of the auth of the config:
+//
+//	service_status token [-subject NAME] [-ttl 720h] config.json
+func runToken(args []string) {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	subject := fs.String("subject", "token", "who the token signs in as")
+	ttl := fs.Duration("ttl", 30*24*time.Hour, "how long the token is valid")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: service_status token [-subject NAME] [-ttl 720h] config.json")
+		os.Exit(2)
+	}
+	config, _ := LoadConfiguration(fs.Arg(0))
+	auth, err := status.NewAuth(config.Auth, config.PublicURL, config.AdminToken)
+	if err != nil {
+		log.Fatalf("create auth: %v", err)
+	}
+	token, err := auth.SignToken(*subject, time.Now().Add(*ttl))
+	if err != nil {
+		log.Fatalf("sign token: %v", err)
+	}
+	fmt.Println(token)
+}
+
+// runKeys creates, lists or revokes the API keys in the storage:
+//
+//	service_status keys create -name NAME -scopes read,ack config.json
+//	service_status keys list config.json
+//	service_status keys revoke ID config.json
+func runKeys(args []string) {
+	usage := func() {
+		fmt.Println("Usage: service_status keys create -name NAME -scopes SCOPE,... config.json | list config.json | revoke ID config.json")
+		os.Exit(2)
+	}
+	if len(args) < 1 {
+		usage()
+	}
+	fs := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	name := fs.String("name", "", "name of the key")
+	scopes := fs.String("scopes", "", "comma separated scopes of the key")
+	fs.Parse(args[1:])
+	want := map[string]int{"create": 1, "list": 1, "revoke": 2}[args[0]]
+	if want == 0 || fs.NArg() != want {
+		usage()
+	}
+	config, _ := LoadConfiguration(fs.Arg(want - 1))
+	db, err := openStorage(config)
+	if err != nil {
+		log.Fatalf("open storage: %v", err)
+	}
+	defer db.Close()
+	keys := status.NewAPIKeys(db, "")
+
+	ctx := context.Background()
+	switch args[0] {
+	case "create":
+		var list []status.Scope
+		for _, s := range strings.Split(*scopes, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, status.Scope(s))
+			}
+		}
+		_, key, err := keys.Create(ctx, *name, list, time.Now())
+		if err != nil {
+			log.Fatalf("create key: %v", err)
+		}
+		fmt.Println(key)
+	case "list":
+		list, err := keys.List(ctx)
+		if err != nil {
+			log.Fatalf("list keys: %v", err)
+		}
+		for _, k := range list {
+			var scopes []string
+			for _, s := range k.Scopes {
+				scopes = append(scopes, string(s))
+			}
+			fmt.Printf("%s\t%s\t%s...\t%s\t%s\n", k.ID, k.Name, k.Prefix, strings.Join(scopes, ","), k.Created.Format(time.RFC3339))
+		}
+	case "revoke":
+		if err := keys.Revoke(ctx, fs.Arg(0)); err != nil {
+			log.Fatalf("revoke key: %v", err)
+		}
+		fmt.Println("Key revoked")
+	}
+}
+
+// openStorage opens the storage of the config
+func openStorage(config Config) (status.StorageBackend, error) {
+	if err := status.SetStorageKey(config.StorageKey); err != nil {
+		return nil, err
+	}
+	db, err := status.OpenStorageBackend(config.StorageType, config.StorageFile)
+	if err != nil {
+		return nil, err
+	}
+	return db.Tenant(config.Tenant), nil
+}
+
+// newPage checks the services and builds the page, listing the
+// recent incidents in db
+func newPage(monitor *status.Monitor, db status.StorageBackend, theme status.Theme, subscriptions bool) status.Page {
+	p := status.NewPage(theme.PageTitle(), monitor.CheckAllServices())
+	p.Theme = theme
+	p.Subscriptions = subscriptions
+	ctx, cancel := context.WithTimeout(context.Background(), status.StorageTimeout)
+	defer cancel()
+	incidents, err := status.RecentIncidents(ctx, db, time.Now())
+	if err != nil {
+		log.Printf("load incidents: %v", err)
+	}
+	p.SetIncidents(incidents)
+	p.Upcoming = monitor.Maintenance.Upcoming(time.Now())
+	return p
+}
+
+// configPollInterval is how often the config file is checked for
+// changes
+const configPollInterval = 5 * time.Second
+
+// defaultRemoteConfigInterval is how often the remote config is
+// fetched again by default
+const defaultRemoteConfigInterval = time.Minute
+
+// reloadable are the settings of the config a reload applies, the
+// others need a restart
+var reloadable = map[string]bool{
+	"services":             true,
+	"notifiers":            true,
+	"escalations":          true,
+	"maintenance_schedule": true,
+	"alert_cooldown":       true,
+	"flap_threshold":       true,
+	"flap_window":          true,
+	"reminder_interval":    true,
+	"max_reminders":        true,
+	"theme":                true,
+	"remote_config":        true,
+}
+
+// reloader applies the changes to the config files to the running
+// server, whenever one is written, the remote config changed or on
+// SIGHUP
+type reloader struct {
+	path string
+	// running is the config the server started with
+	running Config
+	// applied is the JSON of the config last applied, so a config
+	// which did not change is not applied again
+	applied   []byte
+	admin     *status.Admin
+	discovery *status.Discovery
+	nm        *status.NotificationManager
+	slos      *status.SLOMonitor
+	theme     *atomic.Value
+}
+
+// watch reloads the config whenever it changes. It does not return.
+func (r *reloader) watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	watcher, err := status.NewConfigWatcher(r.path)
+	if err != nil {
+		log.Printf("watch config: %v, reloading on SIGHUP only", err)
+	}
+	tick := time.NewTicker(configPollInterval)
+	defer tick.Stop()
+	// the remote config is fetched on every reload, and on its
+	// interval in case it changed
+	remoteInterval := defaultRemoteConfigInterval
+	if r.running.RemoteConfigInterval != "" {
+		remoteInterval, _ = time.ParseDuration(r.running.RemoteConfigInterval)
+	}
+	remote := time.NewTicker(remoteInterval)
+	defer remote.Stop()
+	for {
+		select {
+		case <-remote.C:
+			if !r.remote() {
+				continue
+			}
+		case <-hup:
+			if watcher != nil {
+				// the change is reloaded now rather than once more
+				watcher.Changed()
+			}
+		case <-tick.C:
+			if watcher == nil {
+				continue
+			}
+			changed, err := watcher.Changed()
+			if err != nil {
+				log.Printf("watch config: %v", err)
+			}
+			if !changed {
+				continue
+			}
+		}
+		if err := r.reload(); err != nil {
+			log.Printf("reload config: %v, keeping the running config", err)
+		}
+	}
+}
+
+// remote reports whether the config applied has a remote config
+func (r *reloader) remote() bool {
+	var config Config
+	json.Unmarshal(r.applied, &config)
+	return len(config.RemoteConfig) > 0
+}
+
+// reload applies the services, notifiers and alert settings,
+// maintenance schedule and theme of the config file. Services whose
+// settings did not change keep being checked without interruption,
+// with their state and ongoing incidents. Nothing changes when the
+// config is invalid, or the same as the one applied.
+func (r *reloader) reload() error {
+	config, loaded, err := loadConfiguration(r.path)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(loaded, r.applied) {
+		return nil
+	}
+	if errs := config.Validate(); len(errs) == 1 {
+		return errs[0]
+	} else if len(errs) > 1 {
+		return fmt.Errorf("%v, and %d more problems", errs[0], len(errs)-1)
+	}
+	nm, err := config.NewNotificationManager()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), status.StorageTimeout)
+	defer cancel()
+	changes, err := r.discovery.SetConfigured(ctx, config.Services)
+	if err != nil {
+		return err
+	}
+
+	r.nm.Reload(nm)
+	r.admin.Monitor.Maintenance.SetSchedule(config.MaintenanceSchedule)
+	r.slos.SetServices(config.Services)
+	r.theme.Store(config.Theme)
+	r.applied = loaded
+	log.Printf("Reloaded config, services %v", changes)
+	if restart := restartSettings(r.running, config); len(restart) > 0 {
+		log.Printf("reload config: %s changed, restart to apply", strings.Join(restart, ", "))
+	}
+	r.admin.Refresh()
+	return nil
+}
+
+// restartSettings returns the settings which differ between the
+// running config and config and which a reload does not apply
+func restartSettings(running, config Config) []string {
+	var settings []string
+	// the templates of the theme are parsed at startup
+	if running.Theme.Templates != config.Theme.Templates {
+		settings = append(settings, "theme.templates")
+	}
+	var before, after map[string]json.RawMessage
+	b, _ := json.Marshal(running)
+	json.Unmarshal(b, &before)
+	b, _ = json.Marshal(config)
+	json.Unmarshal(b, &after)
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			before[k] = nil
+		}
+	}
+	for k, v := range before {
+		if !reloadable[k] && !bytes.Equal(v, after[k]) {
+			settings = append(settings, k)
+		}
+	}
+	sort.Strings(settings)
+	return settings
+}
+
+// runAgent checks the services on an interval and reports the
+// results to the central server
+func runAgent(config Config, monitor *status.Monitor, interval time.Duration) {
+	// the central server decides on alerts from all regions
+	monitor.Notifications = nil
+
+	for {
+		report := status.NewAgentReport(config.Region, monitor.CheckAllServices())
+		if err := status.SendReport(nil, config.Server, config.AgentToken, report); err != nil {
+			log.Printf("report to %s: %v", config.Server, err)
+		}
+		time.Sleep(interval)
+	}
 }
@@ -0,0 +1,114 @@
+package status
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Acknowledge records who acknowledged an ongoing incident and
+// stops its escalations and reminders
+func (nm *NotificationManager) Acknowledge(ctx context.Context, id, by string) (Incident, error) {
+	if nm.Storage == nil {
+		return Incident{}, ErrIncidentNotFound
+	}
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	inc, err := nm.Storage.Acknowledge(ctx, id, by, time.Now())
+	if err != nil {
+		return inc, err
+	}
+	if st, ok := nm.states[inc.Service]; ok && st.incident == id {
+		st.stopEscalations()
+		st.remindGen++
+	}
+	return inc, nil
+}
+
+// ackURL returns the URL acknowledging an incident, empty without
+// an incident or a BaseURL
+func (nm *NotificationManager) ackURL(id string) string {
+	if id == "" || nm.BaseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(nm.BaseURL, "/") + "/ack/" + id
+}
+
+// ackPage is the data of the acknowledgement form
+type ackPage struct {
+	Incident Incident
+	// By is who acknowledges the incident and CSRF the token the form
+	// posts back
+	By    string
+	CSRF  string
+	Error string
+}
+
+// ackedBy returns who the request acknowledges an incident as: the
+// name of the API key it presents, which needs the ack scope, or the
+// viewer it is signed in as. It is empty for anyone else.
+func ackedBy(r *http.Request) string {
+	if ak, ok := r.Context().Value(apiKeyKey{}).(APIKey); ok {
+		if ak.Allows(ScopeAck) {
+			return "key " + ak.Name
+		}
+		return ""
+	}
+	return Viewer(r.Context())
+}
+
+// AckHandler is a HandlerFunc which shows the incident in
+// /ack/{incident_id} with a form (GET) to acknowledge it (POST). Only
+// viewers signed in with a, who are recorded as acknowledging it, and
+// API keys of the ack scope may; the others are asked to sign in. The
+// form posts back a token of the viewer, so other sites cannot submit
+// it with the credentials of a browser.
+func AckHandler(nm *NotificationManager, a *Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/ack/")
+		if nm.Storage == nil {
+			http.NotFound(w, r)
+			return
+		}
+		by := ackedBy(r)
+		if by == "" {
+			a.challenge(w, r)
+			return
+		}
+		csrf := a.formToken(by)
+		ctx, cancel := requestContext(r)
+		defer cancel()
+		inc, ok, err := nm.Storage.Incident(ctx, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			tpl.ExecuteTemplate(w, "ack.gohtml", ackPage{Incident: inc, By: by, CSRF: csrf})
+		case http.MethodPost:
+			bearer := strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !bearer && subtle.ConstantTimeCompare([]byte(r.FormValue("csrf")), []byte(csrf)) != 1 {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			if _, err := nm.Acknowledge(ctx, id, by); err != nil {
+				w.WriteHeader(http.StatusConflict)
+				tpl.ExecuteTemplate(w, "ack.gohtml", ackPage{Incident: inc, By: by, CSRF: csrf, Error: err.Error()})
+				return
+			}
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
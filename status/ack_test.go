@@ -0,0 +1,117 @@
+package status
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcknowledge(t *testing.T) {
+	tpl = template.Must(template.ParseGlob("../templates/*.gohtml"))
+
+	c := make(chanNotifier, 10)
+	nm := NewNotificationManager([]Notifier{c}, 0)
+	nm.Storage, _ = OpenStorage("")
+	nm.BaseURL = "https://status.example.com/"
+	nm.ReminderInterval = 20 * time.Millisecond
+	s := &Service{Name: "api"}
+
+	nm.NotifyState(s, StateDown, "timeout")
+	a := <-c
+	if a.Incident == "" || a.AckURL != "https://status.example.com/ack/"+a.Incident {
+		t.Fatalf("expected an acknowledgement link got %+v", a)
+	}
+	expectAlert(t, c, AlertTypeDown, 0)
+
+	auth, _ := NewAuth(AuthConfig{Users: map[string]string{"alice": "hunter2"}}, "", "")
+	keys := NewAPIKeys(nm.Storage, "")
+	h := auth.Optional(keys.Identify(AckHandler(nm, auth)))
+	_, reader, _ := keys.Create(context.Background(), "dashboard", []Scope{ScopeRead}, time.Now())
+	request := func(method string, form url.Values, signIn func(*http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/ack/"+a.Incident, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if signIn != nil {
+			signIn(r)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+	alice := func(r *http.Request) { r.SetBasicAuth("alice", "hunter2") }
+	csrf := url.Values{"csrf": {auth.formToken("alice")}}
+
+	// only those signed in, or with a key of the ack scope, may acknowledge
+	if w := request(http.MethodGet, nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected %v got %v", http.StatusUnauthorized, w.Code)
+	}
+	if w := request(http.MethodPost, url.Values{"by": {"mallory"}}, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected %v got %v", http.StatusUnauthorized, w.Code)
+	}
+	if w := request(http.MethodPost, nil, func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+reader) }); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a read key refused got %v", w.Code)
+	}
+
+	w := request(http.MethodGet, nil, alice)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "api is down") || !strings.Contains(w.Body.String(), csrf.Get("csrf")) {
+		t.Errorf("expected the incident page got %v %s", w.Code, w.Body)
+	}
+
+	// the form of another site has no token
+	if w := request(http.MethodPost, url.Values{"by": {"mallory"}}, alice); w.Code != http.StatusForbidden {
+		t.Errorf("expected %v got %v", http.StatusForbidden, w.Code)
+	}
+	if w := request(http.MethodPost, csrf, alice); w.Code != http.StatusSeeOther {
+		t.Errorf("expected %v got %v", http.StatusSeeOther, w.Code)
+	}
+
+	// the reminders stop once acknowledged
+	for len(c) > 0 {
+		<-c
+	}
+	expectNoAlert(t, c, 60*time.Millisecond)
+
+	m := NewMonitor([]Pinger{&fakePinger{Service: *s, err: ErrServiceUnavailable}}, nm)
+	page := NewPage("test", m.CheckAllServices())
+	if !strings.HasPrefix(page.Acknowledged["api"], "acknowledged by alice at ") {
+		t.Errorf("expected the acknowledgement on the page got %q", page.Acknowledged["api"])
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/ack/42", nil)
+	alice(r)
+	h(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %v got %v", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestAcknowledgeWithKey(t *testing.T) {
+	tpl = template.Must(template.ParseGlob("../templates/*.gohtml"))
+
+	nm := NewNotificationManager(nil, 0)
+	nm.Storage, _ = OpenStorage("")
+	nm.NotifyState(&Service{Name: "api"}, StateDown, "timeout")
+	inc, _, _ := nm.Storage.OngoingIncident(context.Background(), "api")
+
+	auth, _ := NewAuth(AuthConfig{}, "", "")
+	keys := NewAPIKeys(nm.Storage, "")
+	_, key, _ := keys.Create(context.Background(), "pager", []Scope{ScopeAck}, time.Now())
+	h := auth.Optional(keys.Identify(AckHandler(nm, auth)))
+
+	// scripts present the key as a bearer token, without a form token
+	r := httptest.NewRequest(http.MethodPost, "/ack/"+inc.ID, nil)
+	r.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	h(w, r)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected %v got %v", http.StatusSeeOther, w.Code)
+	}
+	if inc, _, _ := nm.Storage.Incident(context.Background(), inc.ID); inc.AckedBy != "key pager" {
+		t.Errorf("expected the incident acknowledged by the key got %q", inc.AckedBy)
+	}
+}
@@ -0,0 +1,405 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Errors returned by the ACME server
+var (
+	ErrACMEDirectory = errors.New("acme: invalid directory")
+	ErrACMENoHTTP01  = errors.New("acme: the server offers no http-01 challenge")
+)
+
+// acmeChallengePath is where the ACME server fetches the answers to
+// its http-01 challenges
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// acmeBadNonce is the problem of a request whose nonce was used
+const acmeBadNonce = "urn:ietf:params:acme:error:badNonce"
+
+// acmeProblem is an error of the ACME server
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+func (p *acmeProblem) Error() string {
+	if p.Detail == "" {
+		return "acme: " + p.Type
+	}
+	return "acme: " + p.Detail
+}
+
+// acmeDirectory lists the endpoints of an ACME server
+type acmeDirectory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+// acmeOrder is a request for the certificate of some domains
+type acmeOrder struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+}
+
+// acmeAuthorization proves the control of a domain of an order by
+// answering one of its challenges
+type acmeAuthorization struct {
+	Status     string `json:"status"`
+	Challenges []struct {
+		Type  string `json:"type"`
+		URL   string `json:"url"`
+		Token string `json:"token"`
+	} `json:"challenges"`
+}
+
+// acmeClient obtains certificates from an ACME server (RFC 8555),
+// proving the control of their domains with http-01 challenges
+type acmeClient struct {
+	directoryURL string
+	email        string
+	key          *ecdsa.PrivateKey
+	client       *http.Client
+	// poll is how often pending authorizations and orders are checked
+	poll time.Duration
+
+	mu    sync.Mutex
+	dir   *acmeDirectory
+	kid   string
+	nonce string
+
+	challengesMu sync.RWMutex
+	challenges   map[string]string
+}
+
+func newACMEClient(directoryURL, email string, key *ecdsa.PrivateKey) *acmeClient {
+	return &acmeClient{
+		directoryURL: directoryURL,
+		email:        email,
+		key:          key,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		poll:         2 * time.Second,
+		challenges:   make(map[string]string),
+	}
+}
+
+// loadACMEKey reads the key of the ACME account, creating it first
+func loadACMEKey(path string) (*ecdsa.PrivateKey, error) {
+	if b, err := ioutil.ReadFile(path); err == nil {
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("acme: invalid account key " + path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// jwk is the public key of the account, its members in the order of
+// its thumbprint
+func (c *acmeClient) jwk() string {
+	return `{"crv":"P-256","kty":"EC","x":"` + b64(padded(c.key.X, 32)) + `","y":"` + b64(padded(c.key.Y, 32)) + `"}`
+}
+
+// padded returns the big-endian bytes of n, left padded to size
+func padded(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// keyAuthorization is the answer to the http-01 challenge of token
+func (c *acmeClient) keyAuthorization(token string) string {
+	sum := sha256.Sum256([]byte(c.jwk()))
+	return token + "." + b64(sum[:])
+}
+
+// challenge returns the answer to a pending challenge
+func (c *acmeClient) challenge(token string) (string, bool) {
+	c.challengesMu.RLock()
+	defer c.challengesMu.RUnlock()
+	keyAuth, ok := c.challenges[token]
+	return keyAuth, ok
+}
+
+// directory fetches the endpoints of the server once
+func (c *acmeClient) directory(ctx context.Context) (*acmeDirectory, error) {
+	if c.dir != nil {
+		return c.dir, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, c.directoryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var dir acmeDirectory
+	if !validStatus(resp.StatusCode) || json.NewDecoder(resp.Body).Decode(&dir) != nil ||
+		dir.NewNonce == "" || dir.NewAccount == "" || dir.NewOrder == "" {
+		return nil, ErrACMEDirectory
+	}
+	c.dir = &dir
+	return c.dir, nil
+}
+
+// fetchNonce gets a fresh nonce when the last response left none
+func (c *acmeClient) fetchNonce(ctx context.Context) (string, error) {
+	if c.nonce != "" {
+		nonce := c.nonce
+		c.nonce = ""
+		return nonce, nil
+	}
+	req, err := http.NewRequest(http.MethodHead, c.dir.NewNonce, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Replay-Nonce"), nil
+}
+
+// post sends payload to url signed with the account key, a nil
+// payload fetching url (POST-as-GET). The response is decoded into v,
+// or read into it when it is a *[]byte. A used nonce is retried once.
+func (c *acmeClient) post(ctx context.Context, url string, payload, v interface{}) (http.Header, error) {
+	header, err := c.postOnce(ctx, url, payload, v)
+	if p, ok := err.(*acmeProblem); ok && p.Type == acmeBadNonce {
+		header, err = c.postOnce(ctx, url, payload, v)
+	}
+	return header, err
+}
+
+func (c *acmeClient) postOnce(ctx context.Context, url string, payload, v interface{}) (http.Header, error) {
+	nonce, err := c.fetchNonce(ctx)
+	if err != nil {
+		return nil, err
+	}
+	protected := map[string]interface{}{"alg": "ES256", "nonce": nonce, "url": url}
+	if c.kid == "" {
+		protected["jwk"] = json.RawMessage(c.jwk())
+	} else {
+		protected["kid"] = c.kid
+	}
+	p, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	body := ""
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = b64(b)
+	}
+	signed := b64(p) + "." + body
+	sum := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, sum[:])
+	if err != nil {
+		return nil, err
+	}
+	sig := append(padded(r, 32), padded(s, 32)...)
+	jws, err := json.Marshal(map[string]string{"protected": b64(p), "payload": body, "signature": b64(sig)})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jws))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/jose+json")
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	c.nonce = resp.Header.Get("Replay-Nonce")
+	if resp.StatusCode >= http.StatusBadRequest {
+		problem := &acmeProblem{}
+		if json.NewDecoder(resp.Body).Decode(problem) != nil || problem.Type == "" {
+			problem.Type = resp.Status
+		}
+		return nil, problem
+	}
+	if raw, ok := v.(*[]byte); ok {
+		*raw, err = ioutil.ReadAll(resp.Body)
+		return resp.Header, err
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return nil, err
+		}
+	}
+	return resp.Header, nil
+}
+
+// account registers the account key once, agreeing to the terms of
+// service of the server
+func (c *acmeClient) account(ctx context.Context) error {
+	if c.kid != "" {
+		return nil
+	}
+	payload := map[string]interface{}{"termsOfServiceAgreed": true}
+	if c.email != "" {
+		payload["contact"] = []string{"mailto:" + c.email}
+	}
+	header, err := c.post(ctx, c.dir.NewAccount, payload, nil)
+	if err != nil {
+		return err
+	}
+	c.kid = header.Get("Location")
+	return nil
+}
+
+// obtain orders a certificate of the domains for key, answering the
+// challenges of the order, and returns its PEM chain
+func (c *acmeClient) obtain(ctx context.Context, domains []string, key crypto.Signer) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.directory(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.account(ctx); err != nil {
+		return nil, err
+	}
+
+	identifiers := make([]map[string]string, len(domains))
+	for i, d := range domains {
+		identifiers[i] = map[string]string{"type": "dns", "value": d}
+	}
+	var order acmeOrder
+	header, err := c.post(ctx, c.dir.NewOrder, map[string]interface{}{"identifiers": identifiers}, &order)
+	if err != nil {
+		return nil, err
+	}
+	orderURL := header.Get("Location")
+	for _, authz := range order.Authorizations {
+		if err := c.authorize(ctx, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.post(ctx, order.Finalize, map[string]string{"csr": b64(csr)}, &order); err != nil {
+		return nil, err
+	}
+	for order.Status != "valid" {
+		if order.Status == "invalid" {
+			return nil, &acmeProblem{Detail: "the order of " + domains[0] + " is invalid"}
+		}
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+		if _, err := c.post(ctx, orderURL, nil, &order); err != nil {
+			return nil, err
+		}
+	}
+	var chain []byte
+	_, err = c.post(ctx, order.Certificate, nil, &chain)
+	return chain, err
+}
+
+// authorize answers the http-01 challenge of an authorization, until
+// the server checked it
+func (c *acmeClient) authorize(ctx context.Context, url string) error {
+	var authz acmeAuthorization
+	if _, err := c.post(ctx, url, nil, &authz); err != nil {
+		return err
+	}
+	if authz.Status == "valid" {
+		return nil
+	}
+	challenge := -1
+	for i, ch := range authz.Challenges {
+		if ch.Type == "http-01" {
+			challenge = i
+		}
+	}
+	if challenge < 0 {
+		return ErrACMENoHTTP01
+	}
+	token := authz.Challenges[challenge].Token
+	c.challengesMu.Lock()
+	c.challenges[token] = c.keyAuthorization(token)
+	c.challengesMu.Unlock()
+	defer func() {
+		c.challengesMu.Lock()
+		delete(c.challenges, token)
+		c.challengesMu.Unlock()
+	}()
+
+	if _, err := c.post(ctx, authz.Challenges[challenge].URL, struct{}{}, nil); err != nil {
+		return err
+	}
+	for {
+		if err := c.wait(ctx); err != nil {
+			return err
+		}
+		if _, err := c.post(ctx, url, nil, &authz); err != nil {
+			return err
+		}
+		switch authz.Status {
+		case "valid":
+			return nil
+		case "invalid":
+			return &acmeProblem{Detail: "the challenge of " + url + " failed"}
+		}
+	}
+}
+
+// wait waits for the server to process a pending request
+func (c *acmeClient) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.poll):
+		return nil
+	}
+}
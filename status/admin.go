@@ -0,0 +1,438 @@
+package status
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the admin area
+var (
+	ErrManagedServiceNotFound = errors.New("admin: service not found")
+	ErrServiceExists          = errors.New("admin: a service with this name is already checked")
+)
+
+// ManagedService is a service added in the admin area, kept in the
+// storage so it survives restarts
+type ManagedService struct {
+	ID      string  `json:"id"`
+	Service Service `json:"service"`
+}
+
+// Admin changes the services and notifiers of a Monitor at runtime,
+// persisting the changes to its storage and applying them at once
+type Admin struct {
+	Monitor *Monitor
+	// Configured are the services of the config, which only change
+	// with it, through ReloadServices
+	Configured []Service
+	// Refresh checks the services and updates the page, nil only
+	// checks them
+	Refresh func()
+	// Keys are the API keys managed in the admin area, nil to hide
+	// them
+	Keys *APIKeys
+
+	// mu guards Configured
+	mu sync.Mutex
+}
+
+// NewAdmin returns the Admin of the monitor, whose notification
+// manager keeps the storage
+func NewAdmin(m *Monitor, configured []Service, refresh func()) *Admin {
+	return &Admin{Monitor: m, Configured: configured, Refresh: refresh}
+}
+
+func (a *Admin) storage() StorageBackend {
+	return a.Monitor.Notifications.Storage
+}
+
+// configured returns the services of the config
+func (a *Admin) configured() []Service {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.Configured
+}
+
+// LoadServices checks the managed services kept in the storage along
+// with the others, and records the configured and managed services
+// in the storage
+func (a *Admin) LoadServices(ctx context.Context) error {
+	managed, err := a.storage().ManagedServices(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ms := range managed {
+		p, err := NewChecker(ms.Service)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ms.Service.ID(), err)
+		}
+		a.Monitor.SetPinger(p)
+	}
+	return a.register(ctx)
+}
+
+// register records the configured and managed services in the
+// storage, flagging those no longer checked as removed
+func (a *Admin) register(ctx context.Context) error {
+	managed, err := a.storage().ManagedServices(ctx)
+	if err != nil {
+		return err
+	}
+	services := append([]Service(nil), a.configured()...)
+	for _, ms := range managed {
+		services = append(services, ms.Service)
+	}
+	return RegisterServices(ctx, a.storage(), services, time.Now())
+}
+
+// validateService checks the settings of a service the way the config
+// is checked at startup, returning the first problem
+func validateService(s Service) error {
+	if errs := ValidateService(s); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// SaveService adds a managed service, or replaces the one with the
+// same ID, and starts checking it in place of the service it replaces.
+// Its name may not be the one of another service checked. A renamed
+// service keeps its history, as with RenamedFrom.
+func (a *Admin) SaveService(ctx context.Context, ms ManagedService) (ManagedService, error) {
+	if err := validateService(ms.Service); err != nil {
+		return ManagedService{}, err
+	}
+	p, err := NewChecker(ms.Service)
+	if err != nil {
+		return ManagedService{}, err
+	}
+	managed, err := a.storage().ManagedServices(ctx)
+	if err != nil {
+		return ManagedService{}, err
+	}
+	replaced := ""
+	for _, existing := range managed {
+		if existing.ID == ms.ID {
+			replaced = existing.Service.ID()
+		}
+	}
+	if ms.ID != "" && replaced == "" {
+		return ManagedService{}, ErrManagedServiceNotFound
+	}
+	for _, s := range a.Monitor.Services() {
+		if s.ID() == ms.Service.ID() && s.ID() != replaced {
+			return ManagedService{}, ErrServiceExists
+		}
+	}
+
+	renamed := replaced != "" && replaced != ms.Service.ID()
+	if renamed {
+		known := false
+		for _, old := range ms.Service.RenamedFrom {
+			known = known || old == replaced
+		}
+		if !known {
+			ms.Service.RenamedFrom = append(ms.Service.RenamedFrom, replaced)
+		}
+	}
+	if ms, err = a.storage().SaveManagedService(ctx, ms); err != nil {
+		return ManagedService{}, err
+	}
+	if renamed {
+		a.Monitor.RemovePinger(replaced)
+	}
+	a.Monitor.SetPinger(p)
+	return ms, a.register(ctx)
+}
+
+// RemoveService removes a managed service and stops checking it. Its
+// history is kept, flagged as removed.
+func (a *Admin) RemoveService(ctx context.Context, id string) error {
+	managed, err := a.storage().ManagedServices(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ms := range managed {
+		if ms.ID != id {
+			continue
+		}
+		if err := a.storage().RemoveManagedService(ctx, id); err != nil {
+			return err
+		}
+		a.Monitor.RemovePinger(ms.Service.ID())
+		return a.register(ctx)
+	}
+	return ErrManagedServiceNotFound
+}
+
+// SaveNotifier adds or replaces a managed notifier like the
+// NotificationManager, keeping the secrets left redacted
+func (a *Admin) SaveNotifier(ctx context.Context, mn ManagedNotifier) (ManagedNotifier, error) {
+	if mn.ID != "" {
+		managed, err := a.storage().ManagedNotifiers(ctx)
+		if err != nil {
+			return ManagedNotifier{}, err
+		}
+		for _, old := range managed {
+			if old.ID == mn.ID {
+				mn = mn.unredacted(old)
+			}
+		}
+	}
+	return a.Monitor.Notifications.SaveNotifier(ctx, mn)
+}
+
+// StartMaintenance puts the services, or every service without any,
+// into maintenance from now for d
+func (a *Admin) StartMaintenance(ctx context.Context, services []string, d time.Duration, description string, now time.Time) (ScheduledMaintenance, error) {
+	return a.Monitor.Maintenance.SaveScheduled(ctx, ScheduledMaintenance{
+		Start:       now,
+		End:         now.Add(d),
+		Services:    services,
+		Description: description,
+	})
+}
+
+// CheckNow checks the services without waiting for the interval
+func (a *Admin) CheckNow() {
+	if a.Refresh != nil {
+		a.Refresh()
+		return
+	}
+	a.Monitor.CheckAllServices()
+}
+
+// adminPage is the data of the admin area
+type adminPage struct {
+	Services  []adminService
+	Notifiers []adminNotifier
+	Incidents []Incident
+	Upcoming  []ScheduledMaintenance
+	// Keys are the API keys, created with one of the Scopes. NewKey
+	// is the key just created, shown only once.
+	Keys   []APIKey
+	Scopes []Scope
+	NewKey string
+	// CSRF is posted back by the forms
+	CSRF  string
+	Error string
+}
+
+// adminService is a service listed in the admin area, with the JSON
+// of its settings. Configured services have no ID and are not edited.
+type adminService struct {
+	ID     string
+	Name   string
+	Type   string
+	State  State
+	Config string
+}
+
+// adminNotifier is a managed notifier listed in the admin area, with
+// the JSON of its redacted config
+type adminNotifier struct {
+	ID       string
+	Type     string
+	Disabled bool
+	Config   string
+}
+
+// page returns the data of the admin area
+func (a *Admin) page(ctx context.Context) (adminPage, error) {
+	var p adminPage
+	states := make(map[string]State)
+	for _, r := range a.Monitor.Results() {
+		states[r.Service.ID()] = r.State
+	}
+	managed, err := a.storage().ManagedServices(ctx)
+	if err != nil {
+		return p, err
+	}
+	for _, s := range a.configured() {
+		p.Services = append(p.Services, adminService{Name: s.ID(), Type: s.Type, State: states[s.ID()]})
+	}
+	for _, ms := range managed {
+		config, _ := json.MarshalIndent(ms.Service, "", "  ")
+		p.Services = append(p.Services, adminService{
+			ID:     ms.ID,
+			Name:   ms.Service.ID(),
+			Type:   ms.Service.Type,
+			State:  states[ms.Service.ID()],
+			Config: string(config),
+		})
+	}
+
+	notifiers, err := a.storage().ManagedNotifiers(ctx)
+	if err != nil {
+		return p, err
+	}
+	for _, mn := range notifiers {
+		config, _ := json.MarshalIndent(mn.redacted().Config, "", "  ")
+		p.Notifiers = append(p.Notifiers, adminNotifier{ID: mn.ID, Type: mn.Config.Type, Disabled: mn.Disabled, Config: string(config)})
+	}
+
+	ongoing := true
+	if p.Incidents, _, err = a.storage().IncidentsPage(ctx, IncidentFilter{Ongoing: &ongoing}, 0, maxPageSize); err != nil {
+		return p, err
+	}
+	p.Upcoming = a.Monitor.Maintenance.Upcoming(time.Now())
+	if a.Keys != nil {
+		p.Scopes = Scopes
+		if p.Keys, err = a.Keys.List(ctx); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// adminAuthorized reports whether r presents token, or an API key of
+// the admin scope, as a bearer token, or token as the password of
+// basic auth as browsers do
+func adminAuthorized(r *http.Request, token string) bool {
+	if granted(r, token, ScopeAdmin) {
+		return true
+	}
+	_, password, ok := r.BasicAuth()
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(password), []byte(token)) == 1
+}
+
+// csrfToken returns the token the forms of the admin area post back,
+// so other sites cannot submit them with the credentials of a browser
+func csrfToken(token string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("admin csrf"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// AdminHandler is a HandlerFunc which serves the admin area (GET
+// /admin/) and its forms, posted to /admin/services and
+// /admin/services/remove, /admin/notifiers and
+// /admin/notifiers/remove, /admin/check, /admin/maintenance,
+// /admin/incidents/ack, /admin/keys and /admin/keys/revoke. Browsers log in with token, which may be
+// "env:NAME", as the password; scripts present it as a bearer token.
+// Without a token the admin area is disabled.
+func AdminHandler(a *Admin, token string) http.HandlerFunc {
+	token = readSecret(token)
+	csrf := csrfToken(token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !adminAuthorized(r, token) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin"), "/")
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		render := func(code int, message, key string) {
+			p, err := a.page(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			p.CSRF, p.Error, p.NewKey = csrf, message, key
+			w.WriteHeader(code)
+			tpl.ExecuteTemplate(w, "admin.gohtml", p)
+		}
+		if r.Method == http.MethodGet && action == "" {
+			render(http.StatusOK, "", "")
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		bearer := strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !bearer && subtle.ConstantTimeCompare([]byte(r.FormValue("csrf")), []byte(csrf)) != 1 {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		id := r.FormValue("id")
+		var err error
+		switch action {
+		case "services":
+			var s Service
+			if err = json.Unmarshal([]byte(r.FormValue("config")), &s); err == nil {
+				_, err = a.SaveService(ctx, ManagedService{ID: id, Service: s})
+			}
+		case "services/remove":
+			err = a.RemoveService(ctx, id)
+		case "notifiers":
+			var c NotifierConfig
+			if err = json.Unmarshal([]byte(r.FormValue("config")), &c); err == nil {
+				_, err = a.SaveNotifier(ctx, ManagedNotifier{ID: id, Config: c, Disabled: r.FormValue("disabled") != ""})
+			}
+		case "notifiers/remove":
+			err = a.Monitor.Notifications.RemoveNotifier(ctx, id)
+		case "check":
+			a.CheckNow()
+		case "maintenance":
+			var d time.Duration
+			if d, err = time.ParseDuration(r.FormValue("duration")); err == nil {
+				_, err = a.StartMaintenance(ctx, splitList(r.FormValue("services")), d, r.FormValue("description"), time.Now())
+			}
+		case "incidents/ack":
+			by := strings.TrimSpace(r.FormValue("by"))
+			if by == "" {
+				by = "admin"
+			}
+			_, err = a.Monitor.Notifications.Acknowledge(ctx, id, by)
+		case "keys", "keys/revoke":
+			if a.Keys == nil {
+				http.NotFound(w, r)
+				return
+			}
+			if action == "keys/revoke" {
+				err = a.Keys.Revoke(ctx, id)
+				break
+			}
+			var scopes []Scope
+			for _, s := range r.Form["scope"] {
+				scopes = append(scopes, Scope(s))
+			}
+			var key string
+			if _, key, err = a.Keys.Create(ctx, strings.TrimSpace(r.FormValue("name")), scopes, time.Now()); err == nil {
+				// the key is shown once rather than redirecting
+				render(http.StatusCreated, "", key)
+				return
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case err == ErrManagedServiceNotFound, err == ErrNotifierNotFound, err == ErrIncidentNotFound, err == ErrAPIKeyNotFound:
+			render(http.StatusNotFound, err.Error(), "")
+		case err != nil && ctx.Err() != nil:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case err != nil:
+			render(http.StatusBadRequest, err.Error(), "")
+		default:
+			http.Redirect(w, r, "/admin/", http.StatusSeeOther)
+		}
+	}
+}
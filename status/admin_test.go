@@ -0,0 +1,169 @@
+package status
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAdmin returns the Admin of a monitor checking a configured
+// service, whose storage is in memory
+func newTestAdmin() *Admin {
+	nm := NewNotificationManager(nil, 0)
+	nm.Storage, _ = OpenStorage("")
+	configured := []Service{{Name: "web", Type: "ping", URL: "http://web.example.com"}}
+	p, _ := NewChecker(configured[0])
+	m := NewMonitor([]Pinger{p}, nm)
+	m.Maintenance = NewMaintenanceRegistry()
+	m.Maintenance.Storage = nm.Storage
+	return NewAdmin(m, configured, nil)
+}
+
+func TestAdminServices(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAdmin()
+
+	ms, err := a.SaveService(ctx, ManagedService{Service: Service{Name: "api", Type: "ping", URL: "http://api.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SaveService(ctx, ManagedService{Service: Service{Name: "web", Type: "ping", URL: "http://other.example.com"}}); err != ErrServiceExists {
+		t.Errorf("expected ErrServiceExists got %v", err)
+	}
+	if _, err := a.SaveService(ctx, ManagedService{Service: Service{Name: "db", Type: "nope"}}); err == nil {
+		t.Errorf("expected an error for an unknown type")
+	}
+
+	// a renamed service replaces its check and keeps its history
+	ms.Service.Name = "public-api"
+	if _, err := a.SaveService(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+	ids := func() string {
+		var ids []string
+		for _, s := range a.Monitor.Services() {
+			ids = append(ids, s.ID())
+		}
+		return strings.Join(ids, ",")
+	}
+	if got := ids(); got != "web,public-api" {
+		t.Errorf("expected web,public-api got %s", got)
+	}
+	infos, _ := a.storage().Services(ctx)
+	if len(infos) != 2 {
+		t.Errorf("expected the services to be registered got %+v", infos)
+	}
+
+	// the managed services are checked again after a restart
+	b := newTestAdmin()
+	b.Monitor.Notifications.Storage = a.storage()
+	if err := b.LoadServices(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Monitor.Services()) != 2 {
+		t.Errorf("expected the managed service to be loaded got %+v", b.Monitor.Services())
+	}
+
+	if err := a.RemoveService(ctx, ms.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(); got != "web" {
+		t.Errorf("expected web got %s", got)
+	}
+	if err := a.RemoveService(ctx, ms.ID); err != ErrManagedServiceNotFound {
+		t.Errorf("expected ErrManagedServiceNotFound got %v", err)
+	}
+}
+
+func TestAdminSaveNotifierKeepsSecrets(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAdmin()
+	mn, err := a.SaveNotifier(ctx, ManagedNotifier{Config: NotifierConfig{Type: "ntfy", URL: "https://ntfy.sh", Topic: "status", Token: "s3cret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := mn.redacted()
+	edited.Config.Topic = "alerts"
+	if _, err := a.SaveNotifier(ctx, edited); err != nil {
+		t.Fatal(err)
+	}
+	managed, _ := a.storage().ManagedNotifiers(ctx)
+	if len(managed) != 1 || managed[0].Config.Token != "s3cret" || managed[0].Config.Topic != "alerts" {
+		t.Errorf("unexpected notifiers %+v", managed)
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	tpl = template.Must(template.ParseGlob("../templates/*.gohtml"))
+	a := newTestAdmin()
+	inc, _ := a.storage().StartIncident(context.Background(), "web", StateDown, SeverityCritical, "timeout", time.Now())
+	handler := AdminHandler(a, "secret")
+
+	do := func(method, path string, form url.Values, csrf bool) *httptest.ResponseRecorder {
+		if csrf {
+			form.Set("csrf", csrfToken("secret"))
+		}
+		r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/admin/", nil))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected a basic auth challenge got %d", w.Code)
+	}
+
+	if w := do(http.MethodGet, "/admin/", url.Values{}, false); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "web down") {
+		t.Errorf("expected the admin area got %d %s", w.Code, w.Body)
+	}
+	service := url.Values{"config": {`{"name": "api", "type": "ping", "url": "http://api.example.com"}`}}
+	if w := do(http.MethodPost, "/admin/services", service, false); w.Code != http.StatusForbidden {
+		t.Errorf("expected a post without the csrf token to be forbidden got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/admin/services", service, true); w.Code != http.StatusSeeOther {
+		t.Errorf("expected a redirect got %d %s", w.Code, w.Body)
+	}
+	if len(a.Monitor.Services()) != 2 {
+		t.Errorf("expected the service to be checked got %+v", a.Monitor.Services())
+	}
+	if w := do(http.MethodPost, "/admin/services", service, true); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ErrServiceExists.Error()) {
+		t.Errorf("expected the error to be shown got %d", w.Code)
+	}
+
+	if w := do(http.MethodPost, "/admin/maintenance", url.Values{"services": {"api, web"}, "duration": {"30m"}}, true); w.Code != http.StatusSeeOther {
+		t.Errorf("expected a redirect got %d %s", w.Code, w.Body)
+	}
+	if upcoming := a.Monitor.Maintenance.Upcoming(time.Now()); len(upcoming) != 1 || len(upcoming[0].Services) != 2 {
+		t.Errorf("expected the maintenance to start got %+v", upcoming)
+	}
+
+	if w := do(http.MethodPost, "/admin/incidents/ack", url.Values{"id": {inc.ID}, "by": {"alice"}}, true); w.Code != http.StatusSeeOther {
+		t.Errorf("expected a redirect got %d %s", w.Code, w.Body)
+	}
+	if acked, _, _ := a.storage().Incident(context.Background(), inc.ID); acked.AckedBy != "alice" {
+		t.Errorf("expected the incident to be acknowledged got %+v", acked)
+	}
+
+	// a key is shown once, when created
+	a.Keys = NewAPIKeys(a.storage(), "")
+	w = do(http.MethodPost, "/admin/keys", url.Values{"name": {"ci"}, "scope": {"heartbeat", "ack"}}, true)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), "<code>ss_") {
+		t.Errorf("expected the key to be shown got %d %s", w.Code, w.Body)
+	}
+	keys, _ := a.Keys.List(context.Background())
+	if len(keys) != 1 || len(keys[0].Scopes) != 2 {
+		t.Fatalf("expected the key to be created got %+v", keys)
+	}
+	if w := do(http.MethodPost, "/admin/keys/revoke", url.Values{"id": {keys[0].ID}}, true); w.Code != http.StatusSeeOther {
+		t.Errorf("expected a redirect got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,202 @@
+package status
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAgentRejected is returned when the central server does
+// not accept a report
+var ErrAgentRejected = errors.New("agent: report rejected by server")
+
+// agentResultsPath is where agents POST their reports
+const agentResultsPath = "/api/agent/results"
+
+// AgentReport is sent by a remote agent with the results
+// of the checks it ran in its region
+type AgentReport struct {
+	Region  string        `json:"region"`
+	Results []AgentResult `json:"results"`
+}
+
+// AgentResult is the state of a single service seen by an agent
+type AgentResult struct {
+	Service string `json:"service"`
+	State   State  `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewAgentReport builds the report an agent sends for results
+func NewAgentReport(region string, results []Result) AgentReport {
+	report := AgentReport{Region: region}
+	for _, r := range results {
+		ar := AgentResult{Service: r.Service.ID(), State: r.State, Message: r.Message}
+		if r.Err != nil && ar.Message == "" {
+			ar.Message = r.Err.Error()
+		}
+		report.Results = append(report.Results, ar)
+	}
+	return report
+}
+
+// SendReport POSTs report to the central server authenticated
+// with the agent token
+func SendReport(client *http.Client, server, token string, report AgentReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(server, "/")+agentResultsPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := clientOrDefault(client).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return ErrAgentRejected
+	}
+	return nil
+}
+
+// RegionStore holds the latest report of each region. Reports
+// older than MaxAge are ignored so a dead agent does not hold
+// services in the state it last saw.
+type RegionStore struct {
+	MaxAge time.Duration
+
+	mu      sync.RWMutex
+	reports map[string]regionReport
+}
+
+type regionReport struct {
+	states map[string]State
+	time   time.Time
+}
+
+// NewRegionStore returns an empty RegionStore
+func NewRegionStore(maxAge time.Duration) *RegionStore {
+	return &RegionStore{MaxAge: maxAge, reports: make(map[string]regionReport)}
+}
+
+// Record replaces the report of a region
+func (rs *RegionStore) Record(report AgentReport, t time.Time) {
+	states := make(map[string]State, len(report.Results))
+	for _, r := range report.Results {
+		states[r.Service] = r.State
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.reports[report.Region] = regionReport{states: states, time: t}
+}
+
+// States returns the state of a service in each region with
+// a fresh report for it
+func (rs *RegionStore) States(id string, now time.Time) map[string]State {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	states := make(map[string]State)
+	for region, report := range rs.reports {
+		if now.Sub(report.time) > rs.MaxAge {
+			continue
+		}
+		if st, ok := report.states[id]; ok {
+			states[region] = st
+		}
+	}
+	return states
+}
+
+// AgentHandler is a HandlerFunc which records reports POSTed by
+// agents. tokens maps each region to the token of its agent, and
+// the region of a report is taken from the token it presents.
+func AgentHandler(rs *RegionStore, tokens map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		region, ok := agentRegion(r.Header.Get("Authorization"), tokens)
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		var report AgentReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		report.Region = region
+		rs.Record(report, time.Now())
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// agentRegion returns the region whose token is presented
+// in a bearer Authorization header
+func agentRegion(header string, tokens map[string]string) (string, bool) {
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	token := []byte(strings.TrimPrefix(header, "Bearer "))
+	for region, t := range tokens {
+		if t != "" && subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+			return region, true
+		}
+	}
+	return "", false
+}
+
+// applyQuorum combines the local result of a service with the state
+// reported by agents in other regions. The service is down when at
+// least quorum regions see it down, and degraded when fewer do.
+func applyQuorum(r *Result, local string, regions map[string]State, quorum int) {
+	if len(regions) == 0 {
+		return
+	}
+	regions[local] = r.State
+	r.Regions = regions
+
+	var down []string
+	for region, st := range regions {
+		if st == StateDown {
+			down = append(down, region)
+		}
+	}
+	sort.Strings(down)
+
+	if quorum <= 0 {
+		quorum = len(regions)/2 + 1
+	}
+	switch {
+	case len(down) >= quorum:
+		r.State = StateDown
+		if r.Err == nil {
+			r.Err = fmt.Errorf("agent: down in %s", strings.Join(down, ", "))
+		}
+	case len(down) > 0:
+		r.State = StateDegraded
+		r.Message = "down in " + strings.Join(down, ", ")
+	}
+}
@@ -0,0 +1,112 @@
+package status
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgentReportRoundTrip(t *testing.T) {
+	rs := NewRegionStore(time.Minute)
+	ts := httptest.NewServer(AgentHandler(rs, map[string]string{"eu-west": "secret"}))
+	defer ts.Close()
+
+	results := []Result{
+		{Service: &Service{Name: "api"}, State: StateDown, Err: ErrServiceUnavailable},
+		{Service: &Service{Name: "web"}, State: StateUp},
+	}
+
+	// the region comes from the token, not the report
+	report := NewAgentReport("us-east", results)
+	if err := SendReport(nil, ts.URL, "secret", report); err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	if err := SendReport(nil, ts.URL, "wrong", report); err != ErrAgentRejected {
+		t.Errorf("expected %v got %v", ErrAgentRejected, err)
+	}
+
+	expected := map[string]State{"eu-west": StateDown}
+	if actual := rs.States("api", time.Now()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+	if actual := rs.States("api", time.Now().Add(2*time.Minute)); len(actual) != 0 {
+		t.Errorf("expected stale report to be ignored got %v", actual)
+	}
+}
+
+func TestAgentHandlerErrors(t *testing.T) {
+	h := AgentHandler(NewRegionStore(time.Minute), map[string]string{"eu-west": "secret"})
+
+	tt := []struct {
+		name   string
+		method string
+		auth   string
+		body   string
+		code   int
+	}{
+		{name: "method", method: http.MethodGet, auth: "Bearer secret", code: http.StatusMethodNotAllowed},
+		{name: "no token", method: http.MethodPost, body: "{}", code: http.StatusUnauthorized},
+		{name: "bad body", method: http.MethodPost, auth: "Bearer secret", body: "{", code: http.StatusBadRequest},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/api/agent/results", strings.NewReader(tc.body))
+			req.Header.Set("Authorization", tc.auth)
+			w := httptest.NewRecorder()
+			h(w, req)
+			if w.Code != tc.code {
+				t.Errorf("expected %v got %v", tc.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestApplyQuorum(t *testing.T) {
+	tt := []struct {
+		name    string
+		local   State
+		regions map[string]State
+		quorum  int
+		state   State
+	}{
+		{name: "no agents", local: StateDown, regions: map[string]State{}, state: StateDown},
+		{name: "only local down", local: StateDown, regions: map[string]State{"eu": StateUp, "us": StateUp}, state: StateDegraded},
+		{name: "majority down", local: StateDown, regions: map[string]State{"eu": StateDown, "us": StateUp}, state: StateDown},
+		{name: "remote quorum", local: StateUp, regions: map[string]State{"eu": StateDown, "us": StateDown}, quorum: 2, state: StateDown},
+		{name: "below quorum", local: StateDown, regions: map[string]State{"eu": StateDown, "us": StateUp}, quorum: 3, state: StateDegraded},
+		{name: "all up", local: StateUp, regions: map[string]State{"eu": StateUp}, state: StateUp},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := Result{Service: &Service{Name: "api"}, State: tc.local}
+			applyQuorum(&r, "local", tc.regions, tc.quorum)
+			if r.State != tc.state {
+				t.Errorf("expected %v got %v", tc.state, r.State)
+			}
+		})
+	}
+}
+
+func TestMonitorRegionsSuppressLocalFailure(t *testing.T) {
+	nm := NewNotificationManager([]Notifier{&recordingNotifier{}}, 0)
+	m := NewMonitor([]Pinger{&fakePinger{Service: Service{Name: "api"}, err: errors.New("timeout")}}, nm)
+	m.Regions = NewRegionStore(time.Minute)
+	m.Regions.Record(AgentReport{Region: "eu", Results: []AgentResult{{Service: "api", State: StateUp}}}, time.Now())
+	m.Regions.Record(AgentReport{Region: "us", Results: []AgentResult{{Service: "api", State: StateUp}}}, time.Now())
+
+	r := m.CheckAllServices()[0]
+	if r.State != StateDegraded || r.Message != "down in local" {
+		t.Errorf("expected degraded got %v %q", r.State, r.Message)
+	}
+	for _, a := range nm.Notifiers[0].(*recordingNotifier).alerts {
+		if a.Type != AlertTypeDegraded {
+			t.Errorf("expected no %s alert below quorum", a.Type)
+		}
+	}
+}
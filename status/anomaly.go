@@ -0,0 +1,87 @@
+package status
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	// anomalyAlpha weighs each response time in the baseline, so it
+	// follows the last few dozen checks
+	anomalyAlpha = 0.1
+	// minBaselineChecks is how many response times a baseline needs
+	// before it flags anomalies
+	minBaselineChecks = 10
+	// anomalyHistory is how far back the stored response times seed
+	// a baseline
+	anomalyHistory = 24 * time.Hour
+)
+
+// baseline is the exponentially weighted mean and variance of the
+// response times of a service, in nanoseconds
+type baseline struct {
+	mean, variance float64
+	n              int
+}
+
+// add weighs a response time into the baseline
+func (b *baseline) add(d time.Duration) {
+	x := float64(d)
+	b.n++
+	if b.n == 1 {
+		b.mean = x
+		return
+	}
+	diff := x - b.mean
+	incr := anomalyAlpha * diff
+	b.mean += incr
+	b.variance = (1 - anomalyAlpha) * (b.variance + diff*incr)
+}
+
+// deviation returns how many standard deviations d is above the
+// mean, zero until the baseline has enough checks
+func (b *baseline) deviation(d time.Duration) float64 {
+	stddev := math.Sqrt(b.variance)
+	if b.n < minBaselineChecks || stddev == 0 {
+		return 0
+	}
+	return (float64(d) - b.mean) / stddev
+}
+
+// detectAnomaly degrades a service which is up but responded more
+// than AnomalySigma standard deviations slower than its baseline,
+// then weighs the response time into the baseline. The baseline is
+// seeded from the history the first time. The caller holds m.mu.
+func (m *Monitor) detectAnomaly(r *Result, now time.Time) {
+	sigma := r.Service.AnomalySigma
+	if sigma <= 0 || r.State != StateUp {
+		return
+	}
+	id := r.Service.ID()
+	b, ok := m.baselines[id]
+	if !ok {
+		b = &baseline{}
+		m.baselines[id] = b
+		if m.History != nil {
+			ctx, cancel := storageContext()
+			history, err := ResponseTimeHistory(ctx, m.History, id, now.Add(-anomalyHistory), now)
+			cancel()
+			if err != nil {
+				log.Printf("load response times of %s: %v", id, err)
+			}
+			for _, h := range history {
+				b.add(h.ResponseTime)
+			}
+		}
+	}
+
+	if dev := b.deviation(r.Latency); dev > sigma {
+		reason := fmt.Sprintf("response time %v is %.1fσ above the baseline of %v", r.Latency.Round(time.Millisecond), dev, time.Duration(b.mean).Round(time.Millisecond))
+		r.State = StateDegraded
+		r.Message = reason
+		r.Err = Degraded(reason)
+	}
+	b.add(r.Latency)
+}
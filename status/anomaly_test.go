@@ -0,0 +1,63 @@
+package status
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowPinger is a fakePinger which takes delay to answer
+type slowPinger struct {
+	fakePinger
+	delay time.Duration
+}
+
+func (s *slowPinger) Status() error {
+	time.Sleep(s.delay)
+	return s.err
+}
+
+func TestBaseline(t *testing.T) {
+	b := &baseline{}
+	for i := 0; i < minBaselineChecks-1; i++ {
+		b.add(100 * time.Millisecond)
+		b.add(110 * time.Millisecond)
+	}
+	if dev := b.deviation(105 * time.Millisecond); dev > 1 {
+		t.Errorf("expected a usual response time within 1σ got %.1fσ", dev)
+	}
+	if dev := b.deviation(time.Second); dev < 10 {
+		t.Errorf("expected a slow response time far above the baseline got %.1fσ", dev)
+	}
+	if dev := (&baseline{}).deviation(time.Second); dev != 0 {
+		t.Errorf("expected no deviation without enough checks got %.1fσ", dev)
+	}
+}
+
+func TestMonitorAnomaly(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		d := time.Millisecond
+		if i%2 == 0 {
+			d = 2 * time.Millisecond
+		}
+		db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: now.Add(time.Duration(i-20) * time.Minute), ResponseTime: d})
+		db.RecordStatus(ctx, StatusRecord{Service: "web", State: StateUp, Time: now.Add(time.Duration(i-20) * time.Minute), ResponseTime: d})
+	}
+
+	api := &slowPinger{fakePinger: fakePinger{Service: Service{Name: "api", AnomalySigma: 3}}, delay: 50 * time.Millisecond}
+	web := &slowPinger{fakePinger: fakePinger{Service: Service{Name: "web"}}, delay: 50 * time.Millisecond}
+	m := NewMonitor([]Pinger{api, web}, nil)
+	m.History = db
+
+	results := m.CheckAllServices()
+	if results[0].State != StateDegraded || !strings.Contains(results[0].Message, "above the baseline") {
+		t.Errorf("expected api degraded by its response time got %+v", results[0])
+	}
+	if results[1].State != StateUp {
+		t.Errorf("expected web without anomaly detection up got %+v", results[1])
+	}
+}
@@ -0,0 +1,121 @@
+package status
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// APIResponse is the JSON representation of the status page
+type APIResponse struct {
+	Title string `json:"title"`
+	// Status is the overall status: success, warning, danger
+	// or maintenance
+	Status   string       `json:"status"`
+	Services []APIService `json:"services"`
+	// Groups are the groups of the services in the order of the
+	// config, when they have any
+	Groups []APIGroup `json:"groups,omitempty"`
+	Time   string     `json:"time"`
+}
+
+// APIGroup is a group of services in an APIResponse, in the state of
+// the worst of them
+type APIGroup struct {
+	Name     string   `json:"name"`
+	State    State    `json:"state"`
+	Services []string `json:"services"`
+}
+
+// APIService is the status of a single service in an APIResponse
+type APIService struct {
+	ID    string `json:"id"`
+	Group string `json:"group,omitempty"`
+	State State  `json:"state"`
+	// Message is the error of a down service, the reason a service
+	// is degraded or the message of its maintenance window
+	Message      string         `json:"message,omitempty"`
+	Flapping     bool           `json:"flapping,omitempty"`
+	Acknowledged string         `json:"acknowledged,omitempty"`
+	Uptime       []WindowUptime `json:"uptime,omitempty"`
+}
+
+// NewAPIResponse builds the APIResponse of a Page. Services are
+// sorted by ID.
+func NewAPIResponse(p Page) APIResponse {
+	resp := APIResponse{Title: p.Title, Status: string(p.Status), Services: []APIService{}, Time: p.Time}
+	groups := make(map[string]string)
+	for _, g := range p.Groups {
+		ag := APIGroup{Name: g.Name, State: g.State}
+		for _, s := range g.Services {
+			groups[s.ID] = g.Name
+			ag.Services = append(ag.Services, s.ID)
+		}
+		resp.Groups = append(resp.Groups, ag)
+	}
+	add := func(id string, state State, message string) {
+		resp.Services = append(resp.Services, APIService{
+			ID:           id,
+			Group:        groups[id],
+			State:        state,
+			Message:      message,
+			Flapping:     p.Flapping[id],
+			Acknowledged: p.Acknowledged[id],
+			Uptime:       p.Uptime[id],
+		})
+	}
+
+	for _, id := range p.Up {
+		add(id, StateUp, "")
+	}
+	for id := range p.Down {
+		add(id, StateDown, p.Errors[id])
+	}
+	for id, reason := range p.Degraded {
+		add(id, StateDegraded, reason)
+	}
+	for _, id := range p.Affected {
+		add(id, StateAffected, "")
+	}
+	for id, message := range p.Maintenance {
+		add(id, StateMaintenance, message)
+	}
+	sort.Slice(resp.Services, func(i, j int) bool { return resp.Services[i].ID < resp.Services[j].ID })
+	return resp
+}
+
+// APIStatus is a HandlerFunc which serves the latest Page of a
+// PageStore, as shown to the viewer, as an APIResponse. Clients
+// polling it revalidate their copy with its ETag or Last-Modified.
+func APIStatus(s *PageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := s.PageFor(r)
+		serveCached(w, r, NewAPIResponse(p), p.Updated)
+	}
+}
+
+// serveCached writes v as JSON with an ETag of its content and the
+// time it was last modified, answering 304 Not Modified to requests
+// whose copy is still current. Copies are revalidated on every use,
+// and those of viewers who have signed in are private to them.
+func serveCached(w http.ResponseWriter, r *http.Request, v interface{}, modified time.Time) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	cache := "public, no-cache"
+	if Viewer(r.Context()) != "" {
+		cache = "private, no-cache"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cache)
+	w.Header().Add("Vary", "Authorization, Cookie")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, "", modified, bytes.NewReader(buf.Bytes()))
+}
@@ -0,0 +1,117 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	OpenAPIHandler()(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	var spec struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI == "" || spec.Paths["/api/uptime"] == nil || spec.Paths["/api/services/{name}/history"] == nil {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+}
+
+func TestAPIStatus(t *testing.T) {
+	uptime := []WindowUptime{{"24h", 99.5}}
+	p := NewPage("Status", []Result{
+		{Service: &Service{Name: "web"}, State: StateUp, Uptime: uptime},
+		{Service: &Service{Name: "api"}, State: StateDown, Err: errors.New("timeout")},
+		{Service: &Service{Name: "db"}, State: StateDegraded, Message: "slow"},
+	})
+
+	w := httptest.NewRecorder()
+	APIStatus(NewPageStore(p))(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	var resp APIResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Status != "danger" {
+		t.Errorf("expected %v got %v", "danger", resp.Status)
+	}
+	expected := []APIService{
+		{ID: "api", State: StateDown, Message: "timeout"},
+		{ID: "db", State: StateDegraded, Message: "slow"},
+		{ID: "web", State: StateUp, Uptime: uptime},
+	}
+	if len(resp.Services) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, resp.Services)
+	}
+	for i, s := range resp.Services {
+		e := expected[i]
+		if s.ID != e.ID || s.State != e.State || s.Message != e.Message || len(s.Uptime) != len(e.Uptime) {
+			t.Errorf("expected %+v got %+v", e, s)
+		}
+	}
+}
+
+func TestAPIStatusLatestPage(t *testing.T) {
+	store := NewPageStore(NewPage("Status", []Result{
+		{Service: &Service{Name: "web"}, State: StateUp},
+	}))
+	handler := APIStatus(store)
+	store.Set(NewPage("Status", []Result{
+		{Service: &Service{Name: "web"}, State: StateDown, Err: errors.New("timeout")},
+	}))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	var resp APIResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "danger" || len(resp.Services) != 1 || resp.Services[0].State != StateDown {
+		t.Errorf("expected the latest page got %+v", resp)
+	}
+}
+
+func TestAPIStatusConditional(t *testing.T) {
+	p := NewPage("Status", []Result{{Service: &Service{Name: "web"}, State: StateUp}})
+	p.Updated = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	handler := APIStatus(NewPageStore(p))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Last-Modified") != "Thu, 02 Jan 2020 03:04:05 GMT" {
+		t.Fatalf("expected the validators of the page got %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Cache-Control") != "public, no-cache" {
+		t.Errorf("expected the page to be revalidated got %q", w.Header().Get("Cache-Control"))
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected the page not to be sent again got %d", w.Code)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	r.Header.Set("If-Modified-Since", "Thu, 02 Jan 2020 03:04:05 GMT")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected the page not to be sent again got %d", w.Code)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	r.Header.Set("If-None-Match", `"stale"`)
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected a changed page to be sent got %d", w.Code)
+	}
+}
@@ -0,0 +1,201 @@
+package status
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors returned by the API keys
+var (
+	ErrAPIKeyNotFound = errors.New("apikey: key not found")
+	ErrInvalidScope   = errors.New("apikey: scope must be read, heartbeat, ack, incidents, maintenance or admin")
+	ErrNoScopes       = errors.New("apikey: a key needs at least one scope")
+	ErrNoKeyName      = errors.New("apikey: a key needs a name")
+)
+
+// Scope is what an API key may do
+type Scope string
+
+// Scopes of the API keys. A key with the admin scope may do anything.
+const (
+	// ScopeRead views the page and the API behind sign-in
+	ScopeRead Scope = "read"
+	// ScopeHeartbeat sends heartbeats
+	ScopeHeartbeat Scope = "heartbeat"
+	// ScopeAck acknowledges incidents
+	ScopeAck Scope = "ack"
+	// ScopeIncidents opens, updates and resolves incidents
+	ScopeIncidents Scope = "incidents"
+	// ScopeMaintenance schedules maintenance
+	ScopeMaintenance Scope = "maintenance"
+	// ScopeAdmin manages the notifiers, subscribers and the admin area
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes lists the scopes of the API keys
+var Scopes = []Scope{ScopeRead, ScopeHeartbeat, ScopeAck, ScopeIncidents, ScopeMaintenance, ScopeAdmin}
+
+// Validate returns an error unless s is one of the Scopes
+func (s Scope) Validate() error {
+	for _, known := range Scopes {
+		if s == known {
+			return nil
+		}
+	}
+	return ErrInvalidScope
+}
+
+// apiKeyPrefix starts every API key, so leaked keys are recognised
+const apiKeyPrefix = "ss_"
+
+// APIKey is a key presented as a bearer token to use the API within
+// its scopes. Only the hash of the key is kept; the key itself is
+// shown once, when it is created.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Hash is the hex SHA-256 of the key, and Prefix its first
+	// characters, which tell keys apart
+	Hash    string    `json:"hash"`
+	Prefix  string    `json:"prefix"`
+	Scopes  []Scope   `json:"scopes"`
+	Created time.Time `json:"created"`
+}
+
+// Allows reports whether the key has the scope, or the admin scope
+func (k APIKey) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// hashKey returns the hash of an API key kept in the storage
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeys creates, revokes and checks the API keys kept in a storage
+type APIKeys struct {
+	Storage StorageBackend
+	// AllowAnonymous lets anyone make the changes of the endpoints
+	// wrapped by Require, such as heartbeats, as before there were
+	// keys. It is off by default.
+	AllowAnonymous bool
+	// adminToken has every scope
+	adminToken string
+}
+
+// NewAPIKeys returns the APIKeys of the storage. adminToken, which
+// may be "env:NAME", has every scope.
+func NewAPIKeys(db StorageBackend, adminToken string) *APIKeys {
+	return &APIKeys{Storage: db, adminToken: readSecret(adminToken)}
+}
+
+// Create adds a key with the name and scopes, returning it along with
+// the key itself, which cannot be recovered later
+func (k *APIKeys) Create(ctx context.Context, name string, scopes []Scope, now time.Time) (APIKey, string, error) {
+	if name == "" {
+		return APIKey{}, "", ErrNoKeyName
+	}
+	if len(scopes) == 0 {
+		return APIKey{}, "", ErrNoScopes
+	}
+	for _, s := range scopes {
+		if err := s.Validate(); err != nil {
+			return APIKey{}, "", err
+		}
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return APIKey{}, "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(b)
+	ak, err := k.Storage.SaveAPIKey(ctx, APIKey{
+		Name:    name,
+		Hash:    hashKey(key),
+		Prefix:  key[:len(apiKeyPrefix)+6],
+		Scopes:  scopes,
+		Created: now,
+	})
+	return ak, key, err
+}
+
+// Revoke removes the key with the given ID, which stops working at once
+func (k *APIKeys) Revoke(ctx context.Context, id string) error {
+	return k.Storage.RemoveAPIKey(ctx, id)
+}
+
+// List returns the keys, oldest first
+func (k *APIKeys) List(ctx context.Context) ([]APIKey, error) {
+	return k.Storage.APIKeys(ctx)
+}
+
+// lookup returns the key r presents as a bearer token
+func (k *APIKeys) lookup(ctx context.Context, r *http.Request) (APIKey, bool) {
+	header := r.Header.Get("Authorization")
+	if k == nil || !strings.HasPrefix(header, "Bearer "+apiKeyPrefix) {
+		return APIKey{}, false
+	}
+	hash := hashKey(strings.TrimPrefix(header, "Bearer "))
+	keys, err := k.Storage.APIKeys(ctx)
+	if err != nil {
+		return APIKey{}, false
+	}
+	for _, ak := range keys {
+		if subtle.ConstantTimeCompare([]byte(ak.Hash), []byte(hash)) == 1 {
+			return ak, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// apiKeyKey is the context key of the API key of a request
+type apiKeyKey struct{}
+
+// Identify looks up the API key r presents before h, whose handlers
+// grant the scopes of the key along with their token
+func (k *APIKeys) Identify(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		ak, ok := k.lookup(ctx, r)
+		cancel()
+		if ok {
+			r = r.WithContext(context.WithValue(r.Context(), apiKeyKey{}, ak))
+		}
+		h(w, r)
+	}
+}
+
+// Require identifies the API key before h like Identify and rejects
+// the changes which present neither a key of the scope nor the admin
+// token, unless anonymous changes are allowed
+func (k *APIKeys) Require(scope Scope, h http.HandlerFunc) http.HandlerFunc {
+	return k.Identify(func(w http.ResponseWriter, r *http.Request) {
+		if !k.AllowAnonymous && r.Method != http.MethodGet && r.Method != http.MethodHead && !granted(r, k.adminToken, scope) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	})
+}
+
+// granted reports whether r presents token as a bearer token, or an
+// API key with the scope
+func granted(r *http.Request, token string, scope Scope) bool {
+	if authorized(r.Header.Get("Authorization"), token) {
+		return true
+	}
+	ak, ok := r.Context().Value(apiKeyKey{}).(APIKey)
+	return ok && ak.Allows(scope)
+}
@@ -0,0 +1,103 @@
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	keys := NewAPIKeys(db, "")
+
+	if _, _, err := keys.Create(ctx, "ci", []Scope{"write"}, time.Now()); err != ErrInvalidScope {
+		t.Errorf("expected ErrInvalidScope got %v", err)
+	}
+	if _, _, err := keys.Create(ctx, "ci", nil, time.Now()); err != ErrNoScopes {
+		t.Errorf("expected ErrNoScopes got %v", err)
+	}
+	ak, key, err := keys.Create(ctx, "ci", []Scope{ScopeHeartbeat, ScopeAck}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, "ss_") || !strings.HasPrefix(key, ak.Prefix) || ak.Hash == key || ak.Hash != hashKey(key) {
+		t.Errorf("expected the key to be kept hashed got %+v for %s", ak, key)
+	}
+	if !ak.Allows(ScopeAck) || ak.Allows(ScopeIncidents) {
+		t.Errorf("expected the scopes of the key got %v", ak.Scopes)
+	}
+	if !(APIKey{Scopes: []Scope{ScopeAdmin}}).Allows(ScopeMaintenance) {
+		t.Error("expected the admin scope to allow everything")
+	}
+
+	list, _ := keys.List(ctx)
+	if len(list) != 1 || list[0].ID != ak.ID {
+		t.Errorf("expected the key to be listed got %+v", list)
+	}
+	if err := keys.Revoke(ctx, ak.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Revoke(ctx, ak.ID); err != ErrAPIKeyNotFound {
+		t.Errorf("expected ErrAPIKeyNotFound got %v", err)
+	}
+}
+
+func TestAPIKeysScopes(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", time.Now())
+	incidents, _ := db.Incidents(ctx)
+	nm := NewNotificationManager(nil, 0)
+	nm.Storage = db
+	keys := NewAPIKeys(db, "s3cret")
+	_, ack, _ := keys.Create(ctx, "pager", []Scope{ScopeAck}, time.Now())
+	_, beat, _ := keys.Create(ctx, "cron", []Scope{ScopeHeartbeat}, time.Now())
+
+	do := func(h http.HandlerFunc, method, path, key, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w.Code
+	}
+
+	incident := keys.Identify(IncidentHandler(nm, "s3cret"))
+	if code := do(incident, http.MethodPost, "/api/incidents/", ack, `{"title": "Slow"}`); code != http.StatusUnauthorized {
+		t.Errorf("expected a key of the ack scope not to open incidents got %d", code)
+	}
+	path := "/api/incidents/" + incidents[0].ID + "/ack"
+	if code := do(incident, http.MethodPost, path, beat, `{"by": "cron"}`); code != http.StatusUnauthorized {
+		t.Errorf("expected a key of another scope to be rejected got %d", code)
+	}
+	if code := do(incident, http.MethodPost, path, ack, `{"by": "ann"}`); code != http.StatusOK {
+		t.Errorf("expected a key of the ack scope to acknowledge got %d", code)
+	}
+	if inc, _, _ := db.Incident(ctx, incidents[0].ID); inc.AckedBy != "ann" {
+		t.Errorf("expected the incident to be acknowledged got %+v", inc)
+	}
+
+	hs := NewHeartbeatStore()
+	hs.Register("nightly")
+	heartbeat := keys.Require(ScopeHeartbeat, HeartbeatHandler(hs))
+	if code := do(heartbeat, http.MethodPost, "/api/heartbeat/nightly", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected a heartbeat without a key to be rejected got %d", code)
+	}
+	if code := do(heartbeat, http.MethodPost, "/api/heartbeat/nightly", ack, ""); code != http.StatusUnauthorized {
+		t.Errorf("expected a key of another scope to be rejected got %d", code)
+	}
+	for _, key := range []string{beat, "s3cret"} {
+		if code := do(heartbeat, http.MethodPost, "/api/heartbeat/nightly", key, ""); code != http.StatusNoContent {
+			t.Errorf("expected the heartbeat to be accepted got %d", code)
+		}
+	}
+	keys.AllowAnonymous = true
+	if code := do(heartbeat, http.MethodPost, "/api/heartbeat/nightly", "", ""); code != http.StatusNoContent {
+		t.Errorf("expected anonymous heartbeats once allowed got %d", code)
+	}
+}
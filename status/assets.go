@@ -0,0 +1,264 @@
+package status
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// statusCSS styles the status page. Colors are custom properties, so
+// themes and the dark mode of the browser only replace them.
+const statusCSS = `:root {
+  --bg: #f5f6f8;
+  --card: #fff;
+  --fg: #1f2328;
+  --muted: #59636e;
+  --border: #d1d9e0;
+  --primary: #0969da;
+  --success: #1a7f37;
+  --warning: #9a6700;
+  --danger: #cf222e;
+  --maintenance: #0550ae;
+  --empty: #d1d9e0;
+  color-scheme: light dark;
+}
+
+@media (prefers-color-scheme: dark) {
+  :root {
+    --bg: #0d1117;
+    --card: #161b22;
+    --fg: #e6edf3;
+    --muted: #9198a1;
+    --border: #30363d;
+    --primary: #4493f8;
+    --success: #3fb950;
+    --warning: #d29922;
+    --danger: #f85149;
+    --maintenance: #58a6ff;
+    --empty: #30363d;
+  }
+}
+
+* { box-sizing: border-box; }
+
+body {
+  margin: 0;
+  background: var(--bg);
+  color: var(--fg);
+  font: 16px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif;
+}
+
+a { color: var(--primary); }
+
+.container { max-width: 960px; margin: 0 auto; padding: 0 16px 24px; }
+
+.page-header {
+  display: flex;
+  flex-wrap: wrap;
+  align-items: center;
+  justify-content: space-between;
+  gap: 12px;
+  padding: 24px 0 16px;
+  border-bottom: 1px solid var(--border);
+  margin-bottom: 24px;
+}
+.page-header h1 { display: flex; align-items: center; margin: 0; font-size: 1.75rem; }
+.logo { max-height: 40px; margin-right: 10px; }
+
+.btn {
+  display: inline-block;
+  padding: 6px 14px;
+  border: 1px solid var(--border);
+  border-radius: 6px;
+  background: var(--card);
+  color: var(--fg);
+  font: inherit;
+  text-decoration: none;
+  cursor: pointer;
+}
+.btn-primary { background: var(--primary); border-color: var(--primary); color: #fff; }
+
+.banner {
+  display: flex;
+  align-items: center;
+  gap: 10px;
+  padding: 14px 18px;
+  border-radius: 6px;
+  margin-bottom: 24px;
+  color: #fff;
+  font-size: 1.15rem;
+  font-weight: 600;
+}
+.banner-success { background: var(--success); }
+.banner-warning { background: var(--warning); }
+.banner-danger { background: var(--danger); }
+.banner-maintenance { background: var(--maintenance); }
+
+.icon { display: inline-block; width: 1.2em; text-align: center; font-weight: 700; }
+
+.services, .upcoming {
+  list-style: none;
+  padding: 0;
+  margin: 0 0 24px;
+  background: var(--card);
+  border: 1px solid var(--border);
+  border-radius: 6px;
+}
+.services > li, .upcoming > li { padding: 12px 16px; border-top: 1px solid var(--border); }
+.services > li:first-child, .upcoming > li:first-child { border-top: 0; }
+.services .heading { font-weight: 600; }
+.service-name { display: flex; flex-wrap: wrap; justify-content: space-between; gap: 8px; }
+
+.group { margin-bottom: 24px; }
+.group summary {
+  display: flex;
+  justify-content: space-between;
+  gap: 8px;
+  padding: 12px 16px;
+  background: var(--card);
+  border: 1px solid var(--border);
+  border-radius: 6px;
+  font-weight: 600;
+  cursor: pointer;
+  list-style: none;
+}
+.group summary::-webkit-details-marker { display: none; }
+.group summary::before { content: "\25B8"; margin-right: 8px; }
+.group[open] summary::before { content: "\25BE"; }
+.group-name { flex: 1; }
+.group[open] summary { border-radius: 6px 6px 0 0; }
+.group .services { border-top: 0; border-radius: 0 0 6px 6px; margin: 0; }
+
+.state { font-weight: 600; white-space: nowrap; }
+.state-up { color: var(--success); }
+.state-degraded, .state-affected { color: var(--warning); }
+.state-down { color: var(--danger); }
+.state-maintenance { color: var(--maintenance); }
+
+.label {
+  display: inline-block;
+  padding: 0 6px;
+  border: 1px solid var(--border);
+  border-radius: 10px;
+  font-size: 0.8rem;
+  color: var(--muted);
+}
+.label-up { border-color: var(--success); color: var(--success); }
+.label-warning { border-color: var(--warning); color: var(--warning); }
+.label-down { border-color: var(--danger); color: var(--danger); }
+.label-info { border-color: var(--maintenance); color: var(--maintenance); }
+
+.text-muted { color: var(--muted); }
+small { font-size: 0.85rem; }
+pre { white-space: pre-wrap; word-break: break-word; margin: 8px 0 0; }
+
+/* the bars of the days are told apart by their pattern as well as
+   their color: solid up, striped degraded, crosshatched down */
+.days { display: flex; margin-top: 6px; }
+.days span { flex: 1; height: 24px; margin-right: 1px; background: var(--empty); }
+.days .day-success { background: var(--success); }
+.days .day-warning {
+  background: repeating-linear-gradient(45deg, var(--warning) 0 3px, transparent 3px 5px), var(--card);
+}
+.days .day-danger {
+  background: repeating-linear-gradient(45deg, var(--danger) 0 2px, transparent 2px 4px),
+    repeating-linear-gradient(-45deg, var(--danger) 0 2px, var(--card) 2px 4px);
+}
+
+.incident {
+  margin-bottom: 16px;
+  background: var(--card);
+  border: 1px solid var(--border);
+  border-left: 4px solid var(--border);
+  border-radius: 6px;
+  padding: 12px 16px;
+}
+.incident-down { border-left-color: var(--danger); }
+.incident-degraded { border-left-color: var(--warning); }
+.incident-maintenance { border-left-color: var(--maintenance); }
+.incident h3 { margin: 0 0 4px; font-size: 1.05rem; }
+.incident h4 { margin: 12px 0 4px; }
+.postmortem { white-space: pre-wrap; }
+.updates { margin: 8px 0 0; padding-left: 20px; }
+.timeline h2 { margin: 24px 0 12px; font-size: 1.25rem; }
+.pager { display: flex; justify-content: space-between; margin-bottom: 24px; }
+.pager .btn:only-child { margin-left: auto; }
+
+.subscribe { display: flex; flex-wrap: wrap; align-items: center; gap: 8px; margin-bottom: 24px; }
+.subscribe input {
+  flex: 1 1 200px;
+  padding: 6px 10px;
+  border: 1px solid var(--border);
+  border-radius: 6px;
+  background: var(--card);
+  color: var(--fg);
+  font: inherit;
+}
+
+.admin section { margin-bottom: 32px; }
+.admin form { display: flex; flex-direction: column; align-items: flex-start; gap: 6px; margin: 8px 0 16px; }
+.admin textarea, .admin input[type=text] {
+  width: 100%;
+  padding: 6px 10px;
+  border: 1px solid var(--border);
+  border-radius: 6px;
+  background: var(--card);
+  color: var(--fg);
+  font: 0.9rem/1.4 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace;
+}
+.admin details summary { cursor: pointer; color: var(--primary); }
+.admin fieldset { border: 1px solid var(--border); border-radius: 6px; }
+.admin code { word-break: break-all; }
+
+footer { border-top: 1px solid var(--border); padding-top: 12px; }
+footer ul { list-style: none; display: flex; flex-wrap: wrap; gap: 16px; padding: 0; margin: 0; }
+
+@media (max-width: 600px) {
+  body { font-size: 15px; }
+  .page-header h1 { font-size: 1.4rem; }
+  .services > li, .upcoming > li { padding: 10px 12px; }
+  .days span { margin-right: 0; }
+}
+`
+
+// asset is a static file served by StaticHandler
+type asset struct {
+	contentType string
+	content     string
+	etag        string
+}
+
+// staticAssets are the static files of the page by name, shipped in
+// the binary
+var staticAssets = map[string]asset{
+	"status.css": newAsset("text/css; charset=utf-8", statusCSS),
+}
+
+func newAsset(contentType, content string) asset {
+	sum := sha256.Sum256([]byte(content))
+	return asset{contentType: contentType, content: content, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+}
+
+// StaticHandler is a HandlerFunc which serves the static files of the
+// page (GET /static/{name}). They are revalidated on every load, so a
+// new version is picked up at once.
+func StaticHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		a, ok := staticAssets[strings.TrimPrefix(r.URL.Path, "/static/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", a.contentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", a.etag)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(a.content))
+	}
+}
@@ -0,0 +1,34 @@
+package status
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStaticHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	StaticHandler()(w, httptest.NewRequest(http.MethodGet, "/static/status.css", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+		t.Fatalf("expected the stylesheet got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "prefers-color-scheme: dark") {
+		t.Errorf("expected a dark mode in %s", w.Body)
+	}
+
+	// a cached copy is revalidated by its ETag
+	r := httptest.NewRequest(http.MethodGet, "/static/status.css", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	StaticHandler()(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	StaticHandler()(w, httptest.NewRequest(http.MethodGet, "/static/missing.js", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 got %d", w.Code)
+	}
+}
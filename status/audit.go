@@ -0,0 +1,118 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxDeliveries is how many alert deliveries the storage keeps
+const maxDeliveries = 1000
+
+// AlertDelivery is the outcome of delivering an alert to a notifier
+type AlertDelivery struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Notifier is the index of the notifier in the
+	// NotificationManager, counting on into the notifiers
+	// of its escalations
+	Notifier int       `json:"notifier"`
+	Type     AlertType `json:"type"`
+	Service  string    `json:"service"`
+	Incident string    `json:"incident,omitempty"`
+	OK       bool      `json:"ok"`
+	// Held reports an alert held back for the quiet hours of the
+	// notifier, neither delivered nor failed
+	Held  bool   `json:"held,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Code is the status the endpoint of the notifier answered a
+	// rejected alert with
+	Code     int `json:"code,omitempty"`
+	Attempts int `json:"attempts"`
+	// Latency is how long the last attempt took
+	Latency time.Duration `json:"latency"`
+}
+
+// audit records the outcome of delivering an alert to the
+// notifier at index i in the storage
+func (nm *NotificationManager) audit(i int, a Alert, err error, attempts int, latency time.Duration) {
+	if nm.Storage == nil {
+		return
+	}
+	d := AlertDelivery{
+		Time:     time.Now(),
+		Notifier: i,
+		Type:     a.Type,
+		Service:  a.Service.ID(),
+		Incident: a.Incident,
+		OK:       err == nil,
+		Held:     errors.Is(err, ErrAlertHeld),
+		Attempts: attempts,
+		Latency:  latency,
+	}
+	if err != nil && !d.Held {
+		d.Error = err.Error()
+		var se *StatusError
+		if errors.As(err, &se) {
+			d.Code = se.Code
+		}
+	}
+	ctx, cancel := storageContext()
+	defer cancel()
+	if err := nm.Storage.AddDelivery(ctx, d); err != nil {
+		log.Printf("record delivery to %s: %v", d.Service, err)
+	}
+}
+
+// AlertsHandler is a HandlerFunc which lists the latest alert
+// deliveries (GET /api/alerts), newest first. The service, incident
+// and failed=true query parameters filter them and limit caps how
+// many are listed.
+func AlertsHandler(db StorageBackend) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		limit := maxDeliveries
+		if l := q.Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		ctx, cancel := requestContext(r)
+		defer cancel()
+		deliveries, err := db.Deliveries(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		list := []AlertDelivery{}
+		for i := len(deliveries) - 1; i >= 0 && len(list) < limit; i-- {
+			d := deliveries[i]
+			if s := q.Get("service"); s != "" && d.Service != s {
+				continue
+			}
+			if inc := q.Get("incident"); inc != "" && d.Incident != inc {
+				continue
+			}
+			if q.Get("failed") == "true" && (d.OK || d.Held) {
+				continue
+			}
+			list = append(list, d)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
@@ -0,0 +1,70 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuditDeliveries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	db, _ := OpenStorage("")
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec, &WebhookNotifier{URL: ts.URL}}, 0)
+	nm.Retries = 1
+	nm.RetryBackoff = time.Millisecond
+	nm.Storage = db
+
+	nm.CheckAndNotify(&Service{Name: "api"}, false, "timeout")
+	nm.CheckAndNotify(&Service{Name: "db"}, false, "timeout")
+
+	tt := []struct {
+		query    string
+		expected []AlertDelivery
+	}{
+		{"", []AlertDelivery{
+			{Notifier: 1, Service: "db", Code: http.StatusServiceUnavailable, Attempts: 2},
+			{Notifier: 0, Service: "db", OK: true, Attempts: 1},
+			{Notifier: 1, Service: "api", Code: http.StatusServiceUnavailable, Attempts: 2},
+			{Notifier: 0, Service: "api", OK: true, Attempts: 1},
+		}},
+		{"?service=api&failed=true", []AlertDelivery{
+			{Notifier: 1, Service: "api", Code: http.StatusServiceUnavailable, Attempts: 2},
+		}},
+		{"?limit=1", []AlertDelivery{
+			{Notifier: 1, Service: "db", Code: http.StatusServiceUnavailable, Attempts: 2},
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			AlertsHandler(db)(w, httptest.NewRequest(http.MethodGet, "/api/alerts"+tc.query, nil))
+			var list []AlertDelivery
+			if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != len(tc.expected) {
+				t.Fatalf("expected %d deliveries got %+v", len(tc.expected), list)
+			}
+			for i, d := range list {
+				e := tc.expected[i]
+				if d.Notifier != e.Notifier || d.Service != e.Service || d.OK != e.OK || d.Code != e.Code || d.Attempts != e.Attempts || d.Incident == "" {
+					t.Errorf("expected %+v got %+v", e, d)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	AlertsHandler(db)(w, httptest.NewRequest(http.MethodGet, "/api/alerts?limit=none", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,535 @@
+package status
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the auth layer
+var (
+	ErrNoAuthMethod    = errors.New("auth: a private page needs users, a token_secret or oidc")
+	ErrInvalidOIDC     = errors.New("auth: oidc needs an issuer, a client_id and a redirect_url or public_url")
+	ErrOIDCAllowlist   = errors.New("auth: oidc needs allowed_emails or allowed_domains")
+	ErrNoTokenSecret   = errors.New("auth: signing tokens needs a token_secret")
+	ErrInvalidToken    = errors.New("auth: invalid or expired token")
+	ErrOIDCDiscovery   = errors.New("auth: oidc discovery failed")
+	ErrOIDCUserinfo    = errors.New("auth: oidc userinfo request failed")
+	ErrViewerForbidden = errors.New("auth: this account may not view the page")
+)
+
+// defaultSessionTTL is how long a viewer stays signed in by default
+const defaultSessionTTL = 24 * time.Hour
+
+// stateTTL is how long a viewer has to sign in with the provider
+const stateTTL = 10 * time.Minute
+
+// Cookies of the auth layer
+const (
+	sessionCookie = "status_session"
+	stateCookie   = "status_auth_state"
+)
+
+// AuthConfig puts the page and the API behind authentication. Viewers
+// sign in with the basic auth of Users, an access token signed with
+// TokenSecret, or an OpenID Connect provider. Without Private only the
+// private services are hidden from viewers who have not signed in.
+type AuthConfig struct {
+	Private bool `json:"private,omitempty"`
+	// Users maps user names to passwords, each may be "env:NAME"
+	Users map[string]string `json:"users,omitempty"`
+	// TokenSecret signs access tokens and sessions. It may be
+	// "env:NAME"; without it sessions end on restart.
+	TokenSecret string      `json:"token_secret,omitempty"`
+	OIDC        *OIDCConfig `json:"oidc,omitempty"`
+	// SessionTTL is how long a sign-in lasts, 24h by default
+	SessionTTL string `json:"session_ttl,omitempty"`
+}
+
+// OIDCConfig signs viewers in with an OpenID Connect provider. Only
+// the accounts with a verified email of AllowedEmails or of the
+// AllowedDomains may view the page, and one of them must be set as
+// most providers let anyone sign up.
+type OIDCConfig struct {
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// RedirectURL defaults to /auth/callback of the public URL
+	RedirectURL    string   `json:"redirect_url,omitempty"`
+	Scopes         []string `json:"scopes,omitempty"`
+	AllowedEmails  []string `json:"allowed_emails,omitempty"`
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+}
+
+// Auth identifies the viewers of the page and the API
+type Auth struct {
+	// Private requires every viewer to sign in
+	Private bool
+	// Hidden reports whether any service is private, which puts the
+	// APIs listing every service behind sign-in. Nil reports none.
+	Hidden func() bool
+	// Keys of the read scope sign in as bearer tokens
+	Keys *APIKeys
+
+	users       map[string]string
+	adminToken  string
+	secret      []byte
+	signing     bool
+	ttl         time.Duration
+	oidc        *OIDCConfig
+	redirectURL string
+	client      *http.Client
+
+	mu       sync.Mutex
+	provider *oidcProvider
+}
+
+// NewAuth returns the Auth of the config. baseURL is the public URL
+// of the page and adminToken, which may be "env:NAME", also signs in
+// as a bearer token.
+func NewAuth(c AuthConfig, baseURL, adminToken string) (*Auth, error) {
+	a := &Auth{
+		Private:    c.Private,
+		users:      make(map[string]string),
+		adminToken: readSecret(adminToken),
+		ttl:        defaultSessionTTL,
+		oidc:       c.OIDC,
+	}
+	for user, password := range c.Users {
+		a.users[user] = readSecret(password)
+	}
+	if secret := readSecret(c.TokenSecret); secret != "" {
+		a.secret, a.signing = []byte(secret), true
+	} else {
+		a.secret = make([]byte, 32)
+		if _, err := rand.Read(a.secret); err != nil {
+			return nil, err
+		}
+	}
+	if c.SessionTTL != "" {
+		ttl, err := time.ParseDuration(c.SessionTTL)
+		if err != nil {
+			return nil, err
+		}
+		a.ttl = ttl
+	}
+	if o := c.OIDC; o != nil {
+		a.redirectURL = o.RedirectURL
+		if a.redirectURL == "" && baseURL != "" {
+			a.redirectURL = strings.TrimSuffix(baseURL, "/") + "/auth/callback"
+		}
+		if o.Issuer == "" || o.ClientID == "" || a.redirectURL == "" {
+			return nil, ErrInvalidOIDC
+		}
+		if len(o.AllowedEmails) == 0 && len(o.AllowedDomains) == 0 {
+			return nil, ErrOIDCAllowlist
+		}
+	}
+	if a.Private && len(a.users) == 0 && !a.signing && a.oidc == nil {
+		return nil, ErrNoAuthMethod
+	}
+	return a, nil
+}
+
+// SignToken returns an access token signing in as subject until it
+// expires
+func (a *Auth) SignToken(subject string, expires time.Time) (string, error) {
+	if !a.signing {
+		return "", ErrNoTokenSecret
+	}
+	return a.sign(sessionToken, subject, expires), nil
+}
+
+// Kinds of the tokens signed with the secret, so a token is only
+// accepted for what it was signed for
+const (
+	sessionToken = "session"
+	stateToken   = "state"
+)
+
+// sign returns a token of the kind, subject and expiry, signed with
+// the secret
+func (a *Auth) sign(kind, subject string, expires time.Time) string {
+	payload := kind + ":" + subject + "|" + strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// formToken returns the token the forms of the viewer subject post
+// back, so other sites cannot submit them with the credentials of
+// their browser
+func (a *Auth) formToken(subject string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte("form:" + subject))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the subject of a token of the kind signed with the
+// secret which has not expired
+func (a *Auth) verify(kind, token string, now time.Time) (string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return "", ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", ErrInvalidToken
+	}
+	s := string(payload)
+	i := strings.LastIndex(s, "|")
+	if i < 0 || !strings.HasPrefix(s, kind+":") {
+		return "", ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(s[i+1:], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return "", ErrInvalidToken
+	}
+	return s[len(kind)+1 : i], nil
+}
+
+// identify returns who r is signed in as, from a bearer token or API
+// key of the read scope, basic
+// auth, a "token" query parameter or the session cookie. A token in
+// the query also signs the browser in.
+func (a *Auth) identify(w http.ResponseWriter, r *http.Request) string {
+	now := time.Now()
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		if authorized(header, a.adminToken) {
+			return "admin"
+		}
+		if subject, err := a.verify(sessionToken, strings.TrimPrefix(header, "Bearer "), now); err == nil {
+			return subject
+		}
+		ctx, cancel := requestContext(r)
+		ak, ok := a.Keys.lookup(ctx, r)
+		cancel()
+		if ok && ak.Allows(ScopeRead) {
+			return "key " + ak.Name
+		}
+	}
+	if user, password, ok := r.BasicAuth(); ok {
+		if want, known := a.users[user]; known && want != "" && subtle.ConstantTimeCompare([]byte(password), []byte(want)) == 1 {
+			return user
+		}
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		if subject, err := a.verify(sessionToken, token, now); err == nil {
+			a.setCookie(w, r, sessionCookie, token, now.Add(a.ttl))
+			return subject
+		}
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if subject, err := a.verify(sessionToken, c.Value, now); err == nil {
+			return subject
+		}
+	}
+	return ""
+}
+
+// setCookie sets a cookie of the auth layer, or clears it when it
+// has expired
+func (a *Auth) setCookie(w http.ResponseWriter, r *http.Request, name, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.HasPrefix(a.redirectURL, "https:"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// viewerKey is the context key of the viewer of a request
+type viewerKey struct{}
+
+// viewer is who is viewing the page
+type viewer struct {
+	name string
+	// login is whether viewers can sign in with the provider
+	login bool
+	// limited is whether private services are hidden from them
+	limited bool
+}
+
+// Viewer returns who the request of ctx is signed in as, empty for
+// viewers who have not signed in
+func Viewer(ctx context.Context) string {
+	v, _ := ctx.Value(viewerKey{}).(viewer)
+	return v.name
+}
+
+// canLogin reports whether the viewer of ctx can sign in with the
+// provider
+func canLogin(ctx context.Context) bool {
+	v, _ := ctx.Value(viewerKey{}).(viewer)
+	return v.login
+}
+
+// limited reports whether private services are hidden from the
+// viewer of ctx
+func limited(ctx context.Context) bool {
+	v, _ := ctx.Value(viewerKey{}).(viewer)
+	return v.limited
+}
+
+// Optional identifies the viewer before h, which shows the private
+// services to viewers who have signed in. A private page requires
+// them to sign in.
+func (a *Auth) Optional(h http.HandlerFunc) http.HandlerFunc {
+	return a.wrap(h, func() bool { return a.Private })
+}
+
+// Protect identifies the viewer before h, which lists every service,
+// so the viewer must sign in when the page or any service is private
+func (a *Auth) Protect(h http.HandlerFunc) http.HandlerFunc {
+	return a.wrap(h, func() bool { return a.Private || a.Hidden != nil && a.Hidden() })
+}
+
+func (a *Auth) wrap(h http.HandlerFunc, required func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := viewer{name: a.identify(w, r), login: a.oidc != nil}
+		if v.name == "" && required() {
+			a.challenge(w, r)
+			return
+		}
+		v.limited = v.name == "" && a.Hidden != nil && a.Hidden()
+		h(w, r.WithContext(context.WithValue(r.Context(), viewerKey{}, v)))
+	}
+}
+
+// challenge asks the viewer to sign in: browsers are sent to the
+// provider, and other clients answer basic auth or present a token
+func (a *Auth) challenge(w http.ResponseWriter, r *http.Request) {
+	if a.oidc != nil && r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, "/auth/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+		return
+	}
+	if len(a.users) > 0 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="status"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="status"`)
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// oidcProvider is the part of the discovery document of the provider
+// used to sign in
+type oidcProvider struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// discover returns the endpoints of the provider, fetched on the
+// first sign-in
+func (a *Auth) discover() (*oidcProvider, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.provider != nil {
+		return a.provider, nil
+	}
+	resp, err := clientOrDefault(a.client).Get(strings.TrimSuffix(a.oidc.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var p oidcProvider
+	if !validStatus(resp.StatusCode) || json.NewDecoder(resp.Body).Decode(&p) != nil ||
+		p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.UserinfoEndpoint == "" {
+		return nil, ErrOIDCDiscovery
+	}
+	a.provider = &p
+	return a.provider, nil
+}
+
+// oidcUserinfo is the part of the userinfo of the provider which
+// identifies the viewer
+type oidcUserinfo struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// exchange trades the code of a sign-in for the userinfo of the
+// viewer. The userinfo is requested from the provider with the access
+// token, so no ID token has to be verified.
+func (a *Auth) exchange(p *oidcProvider, code string) (oidcUserinfo, error) {
+	var info oidcUserinfo
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {a.redirectURL},
+	}
+	req, err := http.NewRequest(http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return info, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.oidc.ClientID), url.QueryEscape(readSecret(a.oidc.ClientSecret)))
+	resp, err := clientOrDefault(a.client).Do(req)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+	if !validStatus(resp.StatusCode) {
+		return info, ErrOAuth2TokenRequest
+	}
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil || tr.AccessToken == "" {
+		return info, ErrOAuth2NoToken
+	}
+
+	req, err = http.NewRequest(http.MethodGet, p.UserinfoEndpoint, nil)
+	if err != nil {
+		return info, err
+	}
+	req.Header.Set("Authorization", "Bearer "+tr.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err = clientOrDefault(a.client).Do(req)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+	if !validStatus(resp.StatusCode) || json.NewDecoder(resp.Body).Decode(&info) != nil || info.Subject == "" {
+		return info, ErrOIDCUserinfo
+	}
+	return info, nil
+}
+
+// allowed reports whether the account of info may view the page
+func (a *Auth) allowed(info oidcUserinfo) bool {
+	if info.Email == "" || !info.EmailVerified {
+		return false
+	}
+	email := strings.ToLower(info.Email)
+	for _, e := range a.oidc.AllowedEmails {
+		if strings.ToLower(e) == email {
+			return true
+		}
+	}
+	for _, d := range a.oidc.AllowedDomains {
+		if strings.HasSuffix(email, "@"+strings.ToLower(strings.TrimPrefix(d, "@"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// localPath returns next when it is a path of this site, so viewers
+// are not sent elsewhere after signing in
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// AuthHandler is a HandlerFunc which signs viewers in with the OpenID
+// Connect provider (GET /auth/login?next=/path), completes the sign-in
+// (GET /auth/callback) and signs them out (GET /auth/logout)
+func AuthHandler(a *Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		action := strings.TrimPrefix(r.URL.Path, "/auth/")
+		if action == "logout" {
+			a.setCookie(w, r, sessionCookie, "", time.Unix(1, 0))
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		if a.oidc == nil || (action != "login" && action != "callback") {
+			http.NotFound(w, r)
+			return
+		}
+		p, err := a.discover()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		now := time.Now()
+		if action == "login" {
+			nonce := make([]byte, 16)
+			if _, err := rand.Read(nonce); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			state := hex.EncodeToString(nonce)
+			a.setCookie(w, r, stateCookie, a.sign(stateToken, state+" "+localPath(r.URL.Query().Get("next")), now.Add(stateTTL)), now.Add(stateTTL))
+			scopes := append([]string{"openid", "email"}, a.oidc.Scopes...)
+			q := url.Values{
+				"response_type": {"code"},
+				"client_id":     {a.oidc.ClientID},
+				"redirect_uri":  {a.redirectURL},
+				"scope":         {strings.Join(scopes, " ")},
+				"state":         {state},
+			}
+			sep := "?"
+			if strings.Contains(p.AuthorizationEndpoint, "?") {
+				sep = "&"
+			}
+			http.Redirect(w, r, p.AuthorizationEndpoint+sep+q.Encode(), http.StatusFound)
+			return
+		}
+
+		c, err := r.Cookie(stateCookie)
+		if err != nil {
+			http.Error(w, ErrInvalidToken.Error(), http.StatusBadRequest)
+			return
+		}
+		signed, err := a.verify(stateToken, c.Value, now)
+		parts := strings.SplitN(signed, " ", 2)
+		if err != nil || len(parts) != 2 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(r.URL.Query().Get("state"))) != 1 {
+			http.Error(w, ErrInvalidToken.Error(), http.StatusBadRequest)
+			return
+		}
+		a.setCookie(w, r, stateCookie, "", time.Unix(1, 0))
+		if e := r.URL.Query().Get("error"); e != "" {
+			http.Error(w, e, http.StatusForbidden)
+			return
+		}
+		info, err := a.exchange(p, r.URL.Query().Get("code"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if !a.allowed(info) {
+			http.Error(w, ErrViewerForbidden.Error(), http.StatusForbidden)
+			return
+		}
+		subject := info.Email
+		if subject == "" {
+			subject = info.Subject
+		}
+		a.setCookie(w, r, sessionCookie, a.sign(sessionToken, subject, now.Add(a.ttl)), now.Add(a.ttl))
+		http.Redirect(w, r, parts[1], http.StatusFound)
+	}
+}
@@ -0,0 +1,254 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthTokens(t *testing.T) {
+	a, err := NewAuth(AuthConfig{TokenSecret: "secret"}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	token, err := a.SignToken("ci", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject, err := a.verify(sessionToken, token, now); err != nil || subject != "ci" {
+		t.Errorf("expected the token of ci got %q %v", subject, err)
+	}
+	if _, err := a.verify(sessionToken, token, now.Add(2*time.Hour)); err != ErrInvalidToken {
+		t.Errorf("expected an expired token got %v", err)
+	}
+	if _, err := a.verify(sessionToken, token[:len(token)-2]+"AA", now); err != ErrInvalidToken {
+		t.Errorf("expected a tampered token to be rejected got %v", err)
+	}
+	if _, err := a.verify(sessionToken, a.sign(stateToken, "nonce /", now.Add(time.Hour)), now); err != ErrInvalidToken {
+		t.Errorf("expected a state token not to sign in got %v", err)
+	}
+
+	other, _ := NewAuth(AuthConfig{TokenSecret: "other"}, "", "")
+	if _, err := other.verify(sessionToken, token, now); err != ErrInvalidToken {
+		t.Errorf("expected a token of another secret to be rejected got %v", err)
+	}
+	unsigned, _ := NewAuth(AuthConfig{}, "", "")
+	if _, err := unsigned.SignToken("ci", now.Add(time.Hour)); err != ErrNoTokenSecret {
+		t.Errorf("expected ErrNoTokenSecret got %v", err)
+	}
+}
+
+func TestNewAuthValidates(t *testing.T) {
+	if _, err := NewAuth(AuthConfig{Private: true}, "", ""); err != ErrNoAuthMethod {
+		t.Errorf("expected ErrNoAuthMethod got %v", err)
+	}
+	if _, err := NewAuth(AuthConfig{OIDC: &OIDCConfig{Issuer: "https://id.example.com", ClientID: "status"}}, "", ""); err != ErrInvalidOIDC {
+		t.Errorf("expected ErrInvalidOIDC without a redirect URL got %v", err)
+	}
+	if _, err := NewAuth(AuthConfig{OIDC: &OIDCConfig{Issuer: "https://id.example.com", ClientID: "status"}}, "https://status.example.com/", ""); err != ErrOIDCAllowlist {
+		t.Errorf("expected ErrOIDCAllowlist without allowed accounts got %v", err)
+	}
+	a, err := NewAuth(AuthConfig{OIDC: &OIDCConfig{Issuer: "https://id.example.com", ClientID: "status", AllowedEmails: []string{"ann@example.com"}}}, "https://status.example.com/", "")
+	if err != nil || a.redirectURL != "https://status.example.com/auth/callback" {
+		t.Errorf("expected the redirect URL of the public URL got %q %v", a.redirectURL, err)
+	}
+}
+
+func TestAuthPrivatePage(t *testing.T) {
+	a, err := NewAuth(AuthConfig{Private: true, Users: map[string]string{"ops": "hunter2"}, TokenSecret: "secret"}, "", "admin-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := a.Optional(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Viewer(r.Context())))
+	})
+	get := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	w := get(httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic") {
+		t.Errorf("expected a basic auth challenge got %d %v", w.Code, w.Header())
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("ops", "wrong")
+	if w := get(r); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong password to be rejected got %d", w.Code)
+	}
+	r.SetBasicAuth("ops", "hunter2")
+	if w := get(r); w.Code != http.StatusOK || w.Body.String() != "ops" {
+		t.Errorf("expected ops to sign in got %d %q", w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer admin-token")
+	if w := get(r); w.Code != http.StatusOK || w.Body.String() != "admin" {
+		t.Errorf("expected the admin token to sign in got %d %q", w.Code, w.Body.String())
+	}
+
+	// a token in a link signs the browser in for the next requests
+	token, _ := a.SignToken("wallboard", time.Now().Add(time.Hour))
+	w = get(httptest.NewRequest(http.MethodGet, "/?token="+token, nil))
+	if w.Code != http.StatusOK || w.Body.String() != "wallboard" {
+		t.Fatalf("expected the token to sign in got %d %q", w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	if w := get(r); w.Code != http.StatusOK || w.Body.String() != "wallboard" {
+		t.Errorf("expected the session cookie to sign in got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthPrivateServices(t *testing.T) {
+	a, _ := NewAuth(AuthConfig{TokenSecret: "secret"}, "", "")
+	a.Hidden = func() bool { return true }
+	now := time.Now()
+	p := NewPage("Status", []Result{
+		{Service: &Service{Name: "web"}, State: StateUp},
+		{Service: &Service{Name: "billing", Private: true}, State: StateDown},
+	})
+	p.SetIncidents([]Incident{
+		{ID: "1", Service: "billing", State: StateDown, Start: now},
+		{ID: "2", Manual: true, Title: "Slow", Services: []string{"billing", "web"}, State: StateDegraded, Start: now},
+	})
+	s := NewPageStore(p)
+	handler := a.Optional(APIStatus(s))
+	get := func(token string) APIResponse {
+		r := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		var resp APIResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	public := get("")
+	if len(public.Services) != 1 || public.Services[0].ID != "web" {
+		t.Errorf("expected only the public service got %+v", public.Services)
+	}
+	if incidents := p.Public().Incidents; len(incidents) != 1 || len(incidents[0].Services) != 1 || incidents[0].Services[0] != "web" {
+		t.Errorf("expected only the public incident got %+v", incidents)
+	}
+	if public.Status != "warning" {
+		t.Errorf("expected the status of the public services got %s", public.Status)
+	}
+
+	token, _ := a.SignToken("ops", now.Add(time.Hour))
+	if all := get(token); len(all.Services) != 2 || all.Status != "danger" {
+		t.Errorf("expected every service once signed in got %+v", all)
+	}
+
+	protected := a.Protect(func(w http.ResponseWriter, r *http.Request) {})
+	w := httptest.NewRecorder()
+	protected(w, httptest.NewRequest(http.MethodGet, "/api/history", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the history to need sign-in got %d", w.Code)
+	}
+}
+
+func TestAuthOIDC(t *testing.T) {
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(oidcProvider{
+				AuthorizationEndpoint: provider.URL + "/authorize",
+				TokenEndpoint:         provider.URL + "/token",
+				UserinfoEndpoint:      provider.URL + "/userinfo",
+			})
+		case "/token":
+			if id, secret, _ := r.BasicAuth(); id != "status" || secret != "shh" || r.FormValue("code") != "code-1" {
+				http.Error(w, "invalid_grant", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"access_token": "at-1", "token_type": "Bearer"}`))
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer at-1" {
+				http.Error(w, "invalid_token", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"sub": "42", "email": "Ann@Example.com", "email_verified": true}`))
+		}
+	}))
+	defer provider.Close()
+
+	a, err := NewAuth(AuthConfig{Private: true, OIDC: &OIDCConfig{
+		Issuer: provider.URL, ClientID: "status", ClientSecret: "shh", AllowedDomains: []string{"example.com"},
+	}}, "https://status.example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// browsers are sent to sign in
+	page := a.Optional(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(Viewer(r.Context()))) })
+	r := httptest.NewRequest(http.MethodGet, "/?x=1", nil)
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	page(w, r)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/auth/login?next=%2F%3Fx%3D1" {
+		t.Fatalf("expected a redirect to sign in got %d %v", w.Code, w.Header())
+	}
+
+	handler := AuthHandler(a)
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/auth/login?next=/%3Fx%3D1", nil))
+	loc, _ := url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || loc.Path != "/authorize" || loc.Query().Get("redirect_uri") != "https://status.example.com/auth/callback" {
+		t.Fatalf("expected a redirect to the provider got %d %v", w.Code, loc)
+	}
+	state := w.Result().Cookies()
+
+	// a callback of another sign-in is rejected
+	r = httptest.NewRequest(http.MethodGet, "/auth/callback?code=code-1&state=other", nil)
+	for _, c := range state {
+		r.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a wrong state to be rejected got %d", w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/auth/callback?code=code-1&state="+loc.Query().Get("state"), nil)
+	for _, c := range state {
+		r.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/?x=1" {
+		t.Fatalf("expected a redirect back got %d %s %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			r.AddCookie(c)
+		}
+	}
+	w = httptest.NewRecorder()
+	page(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "Ann@Example.com" {
+		t.Errorf("expected to be signed in got %d %q", w.Code, w.Body.String())
+	}
+
+	a.oidc.AllowedDomains = []string{"acme.com"}
+	if a.allowed(oidcUserinfo{Subject: "42", Email: "ann@example.com", EmailVerified: true}) {
+		t.Error("expected an account of another domain not to be allowed")
+	}
+	if localPath("//evil.example.com") != "/" {
+		t.Error("expected to stay on the site after signing in")
+	}
+}
@@ -0,0 +1,146 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Errors returned by scheduled backups
+var (
+	ErrInvalidBackup  = errors.New("storage: backup needs a positive interval and a dir or a bucket")
+	ErrBackupRejected = errors.New("storage: backup upload rejected")
+)
+
+// backupPrefix starts the name of every scheduled backup
+const backupPrefix = "status-"
+
+// BackupConfig holds the configuration of scheduled backups
+type BackupConfig struct {
+	Interval string `json:"interval"`
+	// Dir keeps the backups on disk, the latest Keep of them when
+	// set
+	Dir  string `json:"dir,omitempty"`
+	Keep int    `json:"keep,omitempty"`
+	// Bucket uploads the backups to S3 under Prefix, with the
+	// credentials of the s3 check. URL overrides the endpoint,
+	// e.g. for MinIO.
+	Bucket string `json:"bucket,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Region string `json:"region,omitempty"`
+	URL    string `json:"url,omitempty"`
+}
+
+// BackupSchedule backs up a storage on an interval
+type BackupSchedule struct {
+	Interval time.Duration
+	config   BackupConfig
+	client   *http.Client
+}
+
+// NewBackupSchedule returns the BackupSchedule of the config
+func NewBackupSchedule(c BackupConfig) (*BackupSchedule, error) {
+	interval, err := time.ParseDuration(c.Interval)
+	if err != nil || interval <= 0 || (c.Dir == "" && c.Bucket == "") {
+		return nil, ErrInvalidBackup
+	}
+	return &BackupSchedule{Interval: interval, config: c}, nil
+}
+
+// Run backs up db every interval. It does not return.
+func (bs *BackupSchedule) Run(db StorageBackend) {
+	for {
+		time.Sleep(bs.Interval)
+		ctx, cancel := storageContext()
+		if err := bs.Backup(ctx, db, time.Now()); err != nil {
+			log.Printf("back up storage: %v", err)
+		}
+		cancel()
+	}
+}
+
+// Backup writes a backup of db named after now to the dir and the
+// bucket of the schedule, then removes the oldest backups in the dir
+// beyond Keep
+func (bs *BackupSchedule) Backup(ctx context.Context, db StorageBackend, now time.Time) error {
+	var b bytes.Buffer
+	if err := db.Backup(ctx, &b); err != nil {
+		return err
+	}
+	name := backupPrefix + now.UTC().Format("20060102T150405Z") + ".json"
+
+	if bs.config.Dir != "" {
+		if err := ioutil.WriteFile(filepath.Join(bs.config.Dir, name), b.Bytes(), 0600); err != nil {
+			return err
+		}
+		if err := bs.prune(); err != nil {
+			return err
+		}
+	}
+	if bs.config.Bucket != "" {
+		return bs.upload(name, b.Bytes())
+	}
+	return nil
+}
+
+// prune removes the oldest backups in the dir beyond Keep
+func (bs *BackupSchedule) prune() error {
+	if bs.config.Keep <= 0 {
+		return nil
+	}
+	files, err := ioutil.ReadDir(bs.config.Dir)
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), backupPrefix) && strings.HasSuffix(f.Name(), ".json") {
+			backups = append(backups, f.Name())
+		}
+	}
+	// the names sort by time
+	sort.Strings(backups)
+	for len(backups) > bs.config.Keep {
+		if err := os.Remove(filepath.Join(bs.config.Dir, backups[0])); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// upload puts a backup in the bucket
+func (bs *BackupSchedule) upload(name string, body []byte) error {
+	creds, err := awsCredentials()
+	if err != nil {
+		return err
+	}
+	s := &S3{Service: Service{URL: bs.config.URL, Bucket: bs.config.Bucket, Key: path.Join(bs.config.Prefix, name), Region: bs.config.Region}}
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	signV4Payload(req, creds, s.region(), "s3", hex.EncodeToString(sum[:]), time.Now())
+
+	resp, err := clientOrDefault(bs.client).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if !validStatus(resp.StatusCode) {
+		return &StatusError{Err: ErrBackupRejected, Code: resp.StatusCode}
+	}
+	return nil
+}
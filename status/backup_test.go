@@ -0,0 +1,95 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorageBackup(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", time.Now())
+
+	var b bytes.Buffer
+	if err := db.Backup(ctx, &b); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _ := ioutil.TempDir("", "backup")
+	defer os.RemoveAll(dir)
+	restored, _ := OpenStorage(dir + "/status.json")
+	restored.StartIncident(ctx, "db", StateDown, SeverityCritical, "", time.Now())
+	if err := restored.Restore(ctx, &b); err != nil {
+		t.Fatal(err)
+	}
+	incidents, _ := restored.Incidents(ctx)
+	if len(incidents) != 1 || incidents[0].Service != "api" {
+		t.Errorf("expected the backed up incident got %+v", incidents)
+	}
+
+	// the restore is kept across restarts
+	reopened, _ := OpenStorage(dir + "/status.json")
+	if _, ok, _ := reopened.OngoingIncident(ctx, "api"); !ok {
+		t.Error("expected the restored incident kept")
+	}
+
+	if err := restored.Restore(ctx, strings.NewReader(`{"version": 999}`)); err != ErrStorageTooNew {
+		t.Errorf("expected %v got %v", ErrStorageTooNew, err)
+	}
+}
+
+func TestBackupSchedule(t *testing.T) {
+	for k, v := range map[string]string{"AWS_ACCESS_KEY_ID": "AKID", "AWS_SECRET_ACCESS_KEY": "secret"} {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if ok {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+
+	var uploaded []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		uploaded = append(uploaded, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	dir, _ := ioutil.TempDir("", "backup")
+	defer os.RemoveAll(dir)
+	bs, err := NewBackupSchedule(BackupConfig{Interval: "1h", Dir: dir, Keep: 2, Bucket: "bucket", Prefix: "backups", URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, _ := OpenStorage("")
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if err := bs.Backup(context.Background(), db, now.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 || files[0].Name() != "status-20200101T010000Z.json" {
+		t.Errorf("expected the latest 2 backups kept got %v", files)
+	}
+	if len(uploaded) != 3 || uploaded[0] != "/bucket/backups/status-20200101T000000Z.json" {
+		t.Errorf("expected every backup uploaded got %v", uploaded)
+	}
+
+	if _, err := NewBackupSchedule(BackupConfig{Interval: "1h"}); err != ErrInvalidBackup {
+		t.Errorf("expected %v got %v", ErrInvalidBackup, err)
+	}
+}
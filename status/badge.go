@@ -0,0 +1,107 @@
+package status
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// defaultBadgeWindow is the uptime window of an uptime badge
+const defaultBadgeWindow = "30d"
+
+// badgeColors are the colours of the states on a status badge
+var badgeColors = map[State]string{
+	StateUp:          "#4c1",
+	StateDegraded:    "#dfb317",
+	StateDown:        "#e05d44",
+	StateAffected:    "#fe7d37",
+	StateMaintenance: "#007ec6",
+}
+
+// Badge renders a shields.io style SVG badge of a label and a value
+// on a background of color
+func Badge(label, value, color string) []byte {
+	// an approximation of the width of Verdana at 11px
+	width := func(s string) int { return 7*len([]rune(s)) + 10 }
+	lw, vw := width(label), width(value)
+	label, value = template.HTMLEscapeString(label), template.HTMLEscapeString(value)
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">
+<title>%[4]s: %[5]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>
+<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text>
+</g>
+</svg>
+`, lw+vw, lw, vw, label, value, template.HTMLEscapeString(color), lw/2, lw+vw/2))
+}
+
+// uptimeColor returns the colour of an uptime percentage on a badge
+func uptimeColor(percent float64) string {
+	switch {
+	case percent >= 99.9:
+		return "#4c1"
+	case percent >= 99:
+		return "#97ca00"
+	case percent >= 95:
+		return "#dfb317"
+	}
+	return "#e05d44"
+}
+
+// BadgeHandler is a HandlerFunc which serves, from the latest Page
+// of a PageStore, a badge of the state of a service (GET /badge/{service}.svg), or
+// with ?uptime=WINDOW of its uptime over one of the UptimeWindows,
+// 30d by default. ?label= replaces the label of the badge.
+func BadgeHandler(s *PageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/badge/")
+		if !strings.HasSuffix(id, ".svg") {
+			http.NotFound(w, r)
+			return
+		}
+		id = strings.TrimSuffix(id, ".svg")
+		var service *APIService
+		for _, svc := range NewAPIResponse(s.PageFor(r)).Services {
+			if svc.ID == id {
+				service = &svc
+				break
+			}
+		}
+		if service == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		q := r.URL.Query()
+		label, value, color := id, string(service.State), badgeColors[service.State]
+		if _, ok := q["uptime"]; ok {
+			window := q.Get("uptime")
+			if window == "" {
+				window = defaultBadgeWindow
+			}
+			label, value, color = "uptime "+window, "no data", "#9f9f9f"
+			for _, u := range service.Uptime {
+				if u.Window == window {
+					value, color = fmt.Sprintf("%.2f%%", u.Percent), uptimeColor(u.Percent)
+				}
+			}
+		}
+		if l := q.Get("label"); l != "" {
+			label = l
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		// badges embedded in READMEs are proxied, and must not be
+		// cached past a change of state
+		w.Header().Set("Cache-Control", "no-cache, max-age=0")
+		w.Write(Badge(label, value, color))
+	}
+}
@@ -0,0 +1,53 @@
+package status
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBadgeHandler(t *testing.T) {
+	p := NewPage("Status", []Result{
+		{Service: &Service{Name: "web"}, State: StateUp, Uptime: []WindowUptime{{"24h", 100}, {"30d", 99.5}}},
+		{Service: &Service{Name: "a&b"}, State: StateDown, Err: errors.New("timeout")},
+	})
+	store := NewPageStore(p)
+
+	tests := []struct {
+		path     string
+		code     int
+		contains []string
+	}{
+		{"/badge/web.svg", http.StatusOK, []string{">web<", ">up<", `fill="#4c1"`}},
+		{"/badge/a%26b.svg", http.StatusOK, []string{">a&amp;b<", ">down<", `fill="#e05d44"`}},
+		{"/badge/web.svg?uptime", http.StatusOK, []string{">uptime 30d<", ">99.50%<", `fill="#97ca00"`}},
+		{"/badge/web.svg?uptime=24h&label=web+24h", http.StatusOK, []string{">web 24h<", ">100.00%<"}},
+		{"/badge/web.svg?uptime=90d", http.StatusOK, []string{">no data<"}},
+		{"/badge/db.svg", http.StatusNotFound, nil},
+		{"/badge/web", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		BadgeHandler(store)(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: expected %d got %d", tt.path, tt.code, w.Code)
+			continue
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(w.Body.String(), s) {
+				t.Errorf("%s: expected %q in %s", tt.path, s, w.Body)
+			}
+		}
+		if w.Code == http.StatusOK {
+			if ct := w.Header().Get("Content-Type"); ct != "image/svg+xml" {
+				t.Errorf("%s: expected an svg got %q", tt.path, ct)
+			}
+			if err := xml.Unmarshal(w.Body.Bytes(), new(struct{})); err != nil {
+				t.Errorf("%s: invalid svg: %v", tt.path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// UptimeDays is how many days of daily uptime the page charts
+const UptimeDays = 90
+
+// DayUptime is the uptime of a service over a day, from the rollups
+// of its status history
+type DayUptime struct {
+	Day     time.Time `json:"day"`
+	Checks  int       `json:"checks"`
+	Percent float64   `json:"percent"`
+	// Incidents describe the incidents of the service that day
+	Incidents []string `json:"incidents,omitempty"`
+}
+
+// Level returns the context the day is charted in: success when no
+// check found the service down, warning when it was up for at least
+// 95% of the checks, danger below and none without checks
+func (d DayUptime) Level() string {
+	switch {
+	case d.Checks == 0:
+		return ""
+	case d.Percent == 100:
+		return "success"
+	case d.Percent >= 95:
+		return "warning"
+	}
+	return "danger"
+}
+
+// Title describes the day, for the tooltip of its bar
+func (d DayUptime) Title() string {
+	s := d.Day.Format("2006-01-02") + ": no checks"
+	if d.Checks > 0 {
+		s = fmt.Sprintf("%s: %.2f%% uptime", d.Day.Format("2006-01-02"), d.Percent)
+	}
+	for _, inc := range d.Incidents {
+		s += "\n" + inc
+	}
+	return s
+}
+
+// servicesDays returns the uptime of each of services over the last
+// UptimeDays days up to now, the current day last. Days are UTC.
+func servicesDays(ctx context.Context, db StorageBackend, services []string, now time.Time) (map[string][]DayUptime, error) {
+	start := now.Truncate(RollupDaily).Add(-(UptimeDays - 1) * RollupDaily)
+	incidents, err := db.IncidentsBetween(ctx, start, now)
+	if err != nil {
+		return nil, err
+	}
+	days := make(map[string][]DayUptime, len(services))
+	for _, s := range services {
+		rollups, err := StatusSummary(ctx, db, s, start, now)
+		if err != nil {
+			return nil, err
+		}
+		d := make([]DayUptime, UptimeDays)
+		for i := range d {
+			d[i] = DayUptime{Day: start.Add(time.Duration(i) * RollupDaily), Percent: 100}
+		}
+		for _, r := range rollups {
+			if i := int(r.Start.Sub(start) / RollupDaily); i >= 0 && i < len(d) {
+				d[i].Checks, d[i].Percent = r.Checks, r.Uptime()
+			}
+		}
+		for _, inc := range incidents {
+			if !affects(inc, s) {
+				continue
+			}
+			for i := range d {
+				end := d[i].Day.Add(RollupDaily)
+				if inc.Start.Before(end) && (inc.Ongoing() || inc.End.After(d[i].Day)) {
+					d[i].Incidents = append(d[i].Incidents, describeIncident(inc))
+				}
+			}
+		}
+		days[s] = d
+	}
+	return days, nil
+}
+
+// describeIncident returns the state or title of an incident and
+// when it happened
+func describeIncident(inc Incident) string {
+	what := string(inc.State)
+	if inc.Manual {
+		what = inc.Title
+	}
+	const layout = "2006-01-02 15:04"
+	if inc.Ongoing() {
+		return fmt.Sprintf("%s since %s", what, inc.Start.UTC().Format(layout))
+	}
+	return fmt.Sprintf("%s %s to %s", what, inc.Start.UTC().Format(layout), inc.End.UTC().Format(layout))
+}
@@ -0,0 +1,47 @@
+package status
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServicesDays(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	today := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	now := today.Add(12 * time.Hour)
+
+	// yesterday rolled up, with one check of four down, and today
+	// from the history only
+	yesterday := today.Add(-RollupDaily)
+	for i, state := range []State{StateUp, StateDown, StateUp, StateUp} {
+		db.RecordStatus(ctx, StatusRecord{Service: "api", State: state, Time: yesterday.Add(time.Duration(i) * time.Hour)})
+	}
+	Rollup(ctx, db, today.Add(time.Hour))
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: today.Add(2 * time.Hour)})
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", yesterday.Add(time.Hour))
+	db.ResolveIncident(ctx, "api", yesterday.Add(90*time.Minute))
+
+	days, err := servicesDays(ctx, db, []string{"api", "db"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := days["api"]
+	if len(api) != UptimeDays || !api[UptimeDays-1].Day.Equal(today) {
+		t.Fatalf("expected %d days up to today got %+v", UptimeDays, api)
+	}
+	if d := api[UptimeDays-2]; d.Checks != 4 || d.Percent != 75 || d.Level() != "danger" || len(d.Incidents) != 1 {
+		t.Errorf("unexpected yesterday %+v", d)
+	}
+	if d := api[UptimeDays-1]; d.Checks != 1 || d.Level() != "success" || len(d.Incidents) != 0 {
+		t.Errorf("unexpected today %+v", d)
+	}
+	if title := api[UptimeDays-2].Title(); !strings.Contains(title, "75.00% uptime") || !strings.Contains(title, "down 2024-03-09 01:00 to 2024-03-09 01:30") {
+		t.Errorf("unexpected title %q", title)
+	}
+	if d := days["db"][0]; d.Checks != 0 || d.Level() != "" {
+		t.Errorf("expected a day without checks got %+v", d)
+	}
+}
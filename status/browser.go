@@ -0,0 +1,325 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Errors returned by the browser check
+var (
+	ErrChromeNotFound   = errors.New("browser: chrome not found")
+	ErrChromeNotStarted = errors.New("browser: chrome did not start")
+	ErrNoPageTarget     = errors.New("browser: no page to drive")
+	ErrScriptFalse      = errors.New("browser: script returned false")
+)
+
+// chromeNames are the executables searched for when a browser
+// service does not configure one
+var chromeNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "headless-shell"}
+
+// Browser loads a page in headless Chrome, so failures which only
+// show once JavaScript runs are caught. It drives Chrome over the
+// DevTools protocol.
+type Browser struct {
+	Service
+	timingRecorder
+	timeout time.Duration
+}
+
+// GetService return the Service pointer
+func (b *Browser) GetService() *Service {
+	return &b.Service
+}
+
+// Status starts Chrome, loads the page, waits for the selector and
+// runs the script, recording the load time of the page in its
+// Timings. Chrome is killed when the check finishes.
+func (b *Browser) Status() error {
+	deadline := time.Now().Add(b.timeout)
+	b.set(Timings{})
+
+	dir, err := ioutil.TempDir("", "status-chrome")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	chrome := b.chrome()
+	if chrome == "" {
+		return ErrChromeNotFound
+	}
+
+	cmd := exec.Command(chrome, "--headless", "--disable-gpu", "--no-first-run",
+		"--remote-debugging-port=0", "--user-data-dir="+dir, "about:blank")
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		killProcessGroup(cmd)
+		cmd.Wait()
+	}()
+
+	port, err := devToolsPort(dir, deadline)
+	if err != nil {
+		return err
+	}
+	wsURL, err := pageTarget(port)
+	if err != nil {
+		return err
+	}
+
+	load, err := runBrowserSession(wsURL, b.Service, deadline)
+	b.set(Timings{Load: load})
+	return err
+}
+
+// chrome returns the configured Chrome executable or the
+// first one found in PATH, empty when there is none
+func (b *Browser) chrome() string {
+	if b.Chrome != "" {
+		return b.Chrome
+	}
+	for _, name := range chromeNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// devToolsPort waits for Chrome to write the port it listens on
+// for DevTools connections into its profile directory
+func devToolsPort(dir string, deadline time.Time) (string, error) {
+	for time.Now().Before(deadline) {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "DevToolsActivePort"))
+		if lines := strings.Split(string(b), "\n"); err == nil && len(lines) > 1 {
+			return lines[0], nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return "", ErrChromeNotStarted
+}
+
+// pageTarget returns the DevTools websocket URL of the first page
+func pageTarget(port string) (string, error) {
+	resp, err := http.Get("http://127.0.0.1:" + port + "/json/list")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var targets []struct {
+		Type string `json:"type"`
+		URL  string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+		return "", err
+	}
+	for _, t := range targets {
+		if t.Type == "page" {
+			return t.URL, nil
+		}
+	}
+	return "", ErrNoPageTarget
+}
+
+// runBrowserSession navigates the page at wsURL to the service URL
+// and returns the time until the load event fired
+func runBrowserSession(wsURL string, s Service, deadline time.Time) (time.Duration, error) {
+	ws, err := dialWebSocket(wsURL, time.Until(deadline))
+	if err != nil {
+		return 0, err
+	}
+	defer ws.Close()
+	ws.SetDeadline(deadline)
+	c := &cdpClient{ws: ws}
+
+	if err := c.call("Page.enable", nil, nil); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	var nav struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := c.call("Page.navigate", map[string]string{"url": s.URL}, &nav); err != nil {
+		return 0, err
+	}
+	if nav.ErrorText != "" {
+		return 0, fmt.Errorf("browser: %s", nav.ErrorText)
+	}
+	if err := c.waitEvent("Page.loadEventFired"); err != nil {
+		return 0, err
+	}
+	loadTime := time.Since(start)
+
+	if s.Selector != "" {
+		sel, _ := json.Marshal(s.Selector)
+		wait := fmt.Sprintf(`new Promise(resolve => {
+	const check = () => document.querySelector(%s) ? resolve(true) : setTimeout(check, 100);
+	check();
+})`, sel)
+		if err := c.evaluate(wait); err != nil {
+			return loadTime, err
+		}
+	}
+
+	if s.Script != "" {
+		if err := c.evaluate(s.Script); err != nil {
+			return loadTime, err
+		}
+	}
+
+	return loadTime, nil
+}
+
+// cdpMessage is a Chrome DevTools protocol response or event
+type cdpMessage struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// cdpClient sends DevTools protocol commands over a websocket and
+// queues the events received while waiting for responses
+type cdpClient struct {
+	ws     *wsConn
+	nextID int
+	events []string
+}
+
+// call sends a command and decodes its result into result
+func (c *cdpClient) call(method string, params, result interface{}) error {
+	c.nextID++
+	id := c.nextID
+
+	req := map[string]interface{}{"id": id, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	msg, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if err := c.ws.WriteText(msg); err != nil {
+		return err
+	}
+
+	for {
+		m, err := c.read()
+		if err != nil {
+			return err
+		}
+		if m.ID != id {
+			if m.Method != "" {
+				c.events = append(c.events, m.Method)
+			}
+			continue
+		}
+		if m.Error != nil {
+			return fmt.Errorf("browser: %s: %s", method, m.Error.Message)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(m.Result, result)
+	}
+}
+
+// waitEvent returns once the event has been received
+func (c *cdpClient) waitEvent(method string) error {
+	for i, e := range c.events {
+		if e == method {
+			c.events = c.events[i+1:]
+			return nil
+		}
+	}
+	c.events = nil
+
+	for {
+		m, err := c.read()
+		if err != nil {
+			return err
+		}
+		if m.Method == method {
+			return nil
+		}
+	}
+}
+
+// evaluate runs a JavaScript expression in the page, awaiting it when
+// it is a promise, and fails when it throws or returns false
+func (c *cdpClient) evaluate(expression string) error {
+	var res struct {
+		Result struct {
+			Value interface{} `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}
+	params := map[string]interface{}{"expression": expression, "awaitPromise": true, "returnByValue": true}
+	if err := c.call("Runtime.evaluate", params, &res); err != nil {
+		return err
+	}
+
+	if e := res.ExceptionDetails; e != nil {
+		if e.Exception.Description != "" {
+			return fmt.Errorf("browser: script failed: %s", e.Exception.Description)
+		}
+		return fmt.Errorf("browser: script failed: %s", e.Text)
+	}
+	if v, ok := res.Result.Value.(bool); ok && !v {
+		return ErrScriptFalse
+	}
+	return nil
+}
+
+func (c *cdpClient) read() (cdpMessage, error) {
+	var m cdpMessage
+	b, err := c.ws.ReadMessage()
+	if err != nil {
+		return m, err
+	}
+	return m, json.Unmarshal(b, &m)
+}
+
+// BrowserFactory implements the PingerFactory
+// interface
+type BrowserFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *BrowserFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "browser" {
+		return nil, ErrInvalidCreate
+	}
+
+	timeout := defaultScriptTimeout
+	if s.Timeout != "" {
+		d, err := time.ParseDuration(s.Timeout)
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidTimeout
+		}
+		timeout = d
+	}
+
+	return &Browser{
+		Service: s,
+		timeout: timeout,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package status
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDevTools serves the DevTools protocol over a websocket, answering
+// Runtime.evaluate with the result of evaluate
+func fakeDevTools(t *testing.T, evaluate func(expression string) string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		accept := websocketAccept(r.Header.Get("Sec-WebSocket-Key"))
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: "+accept+"\r\n\r\n")
+
+		ws := &wsConn{conn: conn, br: rw.Reader}
+		for {
+			msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req struct {
+				ID     int    `json:"id"`
+				Method string `json:"method"`
+				Params struct {
+					Expression string `json:"expression"`
+				} `json:"params"`
+			}
+			json.Unmarshal(msg, &req)
+
+			result := "{}"
+			switch req.Method {
+			case "Page.navigate":
+				// the load event may arrive before the response
+				writeServerText(conn, `{"method": "Page.loadEventFired", "params": {}}`)
+			case "Runtime.evaluate":
+				result = evaluate(req.Params.Expression)
+			}
+			writeServerText(conn, `{"id": `+strconv.Itoa(req.ID)+`, "result": `+result+`}`)
+		}
+	}))
+}
+
+// writeServerText writes an unmasked text frame as a server does
+func writeServerText(w io.Writer, s string) {
+	header := []byte{0x81, 126, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(s)))
+	w.Write(append(header, s...))
+}
+
+func TestRunBrowserSession(t *testing.T) {
+	tt := []struct {
+		name     string
+		service  Service
+		result   string
+		expected string
+	}{
+		{name: "loads", service: Service{URL: "https://example.com"}, result: `{"result": {"value": true}}`},
+		{name: "selector", service: Service{URL: "https://example.com", Selector: "#app"}, result: `{"result": {"value": true}}`},
+		{name: "script false", service: Service{URL: "https://example.com", Script: "window.ready"}, result: `{"result": {"value": false}}`, expected: ErrScriptFalse.Error()},
+		{name: "script throws", service: Service{URL: "https://example.com", Script: "boom()"}, result: `{"result": {}, "exceptionDetails": {"text": "Uncaught", "exception": {"description": "ReferenceError: boom is not defined"}}}`, expected: "browser: script failed: ReferenceError: boom is not defined"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var expressions []string
+			ts := fakeDevTools(t, func(expression string) string {
+				expressions = append(expressions, expression)
+				return tc.result
+			})
+			defer ts.Close()
+
+			_, err := runBrowserSession("ws"+strings.TrimPrefix(ts.URL, "http"), tc.service, time.Now().Add(5*time.Second))
+			if tc.expected == "" && err != nil {
+				t.Errorf("expected nil got %v", err)
+			}
+			if tc.expected != "" && (err == nil || err.Error() != tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, err)
+			}
+			if tc.service.Selector != "" && (len(expressions) != 1 || !strings.Contains(expressions[0], `document.querySelector("#app")`)) {
+				t.Errorf("expected selector wait got %v", expressions)
+			}
+		})
+	}
+}
+
+func TestWebSocketLargeFrame(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	payload := strings.Repeat("x", 70000)
+	go (&wsConn{conn: client}).WriteText([]byte(payload))
+
+	msg, err := (&wsConn{conn: server, br: bufio.NewReader(server)}).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg) != payload {
+		t.Errorf("expected %d bytes got %d", len(payload), len(msg))
+	}
+}
+
+func TestBrowserChromeNotFound(t *testing.T) {
+	f := BrowserFactory{}
+	b, err := f.Create(Service{Type: "browser", URL: "https://example.com", Chrome: "/nonexistent/chrome", Timeout: "1s"})
+	if err != nil {
+		t.Fatalf("failed create with error: %v", err)
+	}
+	if _, ok := b.(Timed); !ok {
+		t.Error("expected the browser check to record its timings")
+	}
+	if err := b.Status(); err == nil {
+		t.Error("expected error")
+	}
+}
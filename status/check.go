@@ -16,10 +16,162 @@ var (
 
 // Service represents a single endpoint to be tested
 type Service struct {
+	Name  string `json:"name,omitempty"`
 	Type  string `json:"type"`
 	URL   string `json:"url"`
 	Port  string `json:"port,omitempty"`
 	Regex string `json:"regex,omitempty"`
+
+	// Group is the section of the page the service is listed in.
+	// Group and Tags describe the service in the API. RenamedFrom
+	// lists its former names, whose history it keeps.
+	Group       string   `json:"group,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	RenamedFrom []string `json:"renamed_from,omitempty"`
+	// Private services only appear to viewers who have signed in
+	Private bool `json:"private,omitempty"`
+	// Pool names the service this is an instance of, when each of
+	// its instances is checked. An instance down while others of its
+	// pool are up is degraded; the pool is down once all of them are.
+	Pool string `json:"pool,omitempty"`
+
+	// ProxyURL is an http, https or socks5 proxy used by HTTP
+	// checks in place of the HTTP_PROXY environment variables
+	ProxyURL string `json:"proxy_url,omitempty"`
+
+	// NoRedirects stops HTTP checks following redirects, so a redirect
+	// response fails the check, and MaxRedirects caps the number
+	// followed (default 10). FinalURL is a regex the URL finally
+	// reached by ping and grep checks must match.
+	NoRedirects  bool   `json:"no_redirects,omitempty"`
+	MaxRedirects int    `json:"max_redirects,omitempty"`
+	FinalURL     string `json:"final_url,omitempty"`
+
+	// Protocol is the protocol ping checks expect to negotiate,
+	// "http/1.1" or "h2". HTTP/3 needs QUIC which the standard
+	// library does not provide.
+	Protocol string `json:"protocol,omitempty"`
+
+	// ExpectHeaders asserts on response headers of HTTP checks. An
+	// empty value only requires the header to be present, otherwise
+	// the value must match it as a regex.
+	ExpectHeaders map[string]string `json:"expect_headers,omitempty"`
+
+	// ClientCert and ClientKey are the PEM client certificate and key
+	// presented to mutual TLS endpoints and CABundle the PEM CAs trusted
+	// for the server. Each is a file path or "env:NAME".
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	CABundle   string `json:"ca_bundle,omitempty"`
+	// InsecureSkipVerify disables server certificate verification
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+
+	// IPFamily restricts ping, tcp and icmp checks, and the other
+	// checks of an HTTP url, to "v4" or "v6". With "both" a ping, tcp
+	// or icmp check probes each family and the service is degraded
+	// when only one of them works.
+	IPFamily string `json:"ip_family,omitempty"`
+
+	// DependsOn lists the services this service relies on. A failing
+	// service with a failing dependency is reported as affected.
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	// Severity of the alerts sent when the service goes down,
+	// critical by default
+	Severity Severity `json:"severity,omitempty"`
+	// AlertCooldown overrides the global alert cooldown
+	AlertCooldown string `json:"alert_cooldown,omitempty"`
+	// AnomalySigma degrades the service when it responds more than
+	// that many standard deviations slower than its usual response
+	// time, learned from the history, without a fixed threshold
+	AnomalySigma float64 `json:"anomaly_sigma,omitempty"`
+
+	// SLO is the objective whose error budget is tracked
+	SLO *SLO `json:"slo,omitempty"`
+
+	// FailuresBeforeDown and SuccessesBeforeUp are the number of
+	// consecutive results needed before the service changes state
+	FailuresBeforeDown int `json:"failures_before_down,omitempty"`
+	SuccessesBeforeUp  int `json:"successes_before_up,omitempty"`
+
+	// SHA256 is the expected hash of the body, or of the HashHeaders
+	// when set, for checksum checks. OnMismatch is "down" (default)
+	// or "degraded".
+	SHA256      string   `json:"sha256,omitempty"`
+	HashHeaders []string `json:"hash_headers,omitempty"`
+	OnMismatch  string   `json:"on_mismatch,omitempty"`
+
+	// Traceroute runs a traceroute to the service host when it goes
+	// down and attaches the hop report to the alert
+	Traceroute bool `json:"traceroute,omitempty"`
+
+	// Maintenance lists scheduled windows during which the
+	// service is not checked or alerted on
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+
+	// Container is the name or id of the container
+	// inspected by docker checks
+	Container string `json:"container,omitempty"`
+
+	// Token identifies a heartbeat service and Grace is how long
+	// it may go without checking in, e.g. "5m"
+	Token string `json:"token,omitempty"`
+	Grace string `json:"grace,omitempty"`
+
+	// Bucket, Key and Region locate the bucket or test object
+	// for s3 checks; URL overrides the endpoint (e.g. MinIO)
+	Bucket string `json:"bucket,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Region string `json:"region,omitempty"`
+
+	// Query is the GraphQL query sent by graphql checks and Expect
+	// maps dotted paths in the response data to expected values
+	Query  string            `json:"query,omitempty"`
+	Expect map[string]string `json:"expect,omitempty"`
+
+	// TokenURL is where oauth2 checks request a client credentials
+	// token with ClientID and ClientSecret, which may be "env:NAME".
+	// When URL is set the token is used to call it.
+	TokenURL     string   `json:"token_url,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+
+	// Domain is the registered domain whose expiry domain checks
+	// look up over RDAP, defaulting to the host of URL. They are
+	// degraded within WarnDays (default 30) of expiry.
+	Domain     string `json:"domain,omitempty"`
+	WarnDays   int    `json:"warn_days,omitempty"`
+	RDAPServer string `json:"rdap_server,omitempty"`
+
+	// Selector is a CSS selector browser checks wait for once the
+	// page has loaded and Script a JavaScript expression, which may
+	// return a promise, that must not throw or return false. Chrome
+	// is the browser executable, searched for in PATH by default.
+	Selector string `json:"selector,omitempty"`
+	Script   string `json:"script,omitempty"`
+	Chrome   string `json:"chrome,omitempty"`
+
+	// Command is run with Args by script checks, with Env added to
+	// the environment. Timeout bounds script runs and tcp and icmp
+	// probes, e.g. "30s".
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Timeout string            `json:"timeout,omitempty"`
+
+	// Prober selects how tcp and icmp checks reach the host,
+	// "native" (default) or "exec" to run the system tools
+	Prober string `json:"prober,omitempty"`
+}
+
+// ID returns the name used to identify the service, which
+// defaults to its URL
+func (s *Service) ID() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.URL
 }
 
 // Pinger is an interface which describes how
@@ -39,6 +191,11 @@ type PingerFactory interface {
 // services availability
 type Ping struct {
 	Service
+	timingRecorder
+	client *http.Client
+	// client6 is set when ip_family is "both", client
+	// then only connects over IPv4
+	client6 *http.Client
 }
 
 // GetService return the Service pointer
@@ -49,16 +206,42 @@ func (p *Ping) GetService() *Service {
 // Status sends a HEAD http request and checks for a valid
 // http responce code
 func (p *Ping) Status() error {
-	resp, err := http.Head(p.URL)
+	err := p.head(clientOrDefault(p.client), true)
+	if p.client6 == nil {
+		return err
+	}
+	return bothFamilies(err, p.head(p.client6, false))
+}
+
+// head sends the HEAD request, recording its timings when traced
+func (p *Ping) head(client *http.Client, traced bool) error {
+	req, err := http.NewRequest(http.MethodHead, p.URL, nil)
+	if err != nil {
+		return err
+	}
+	if traced {
+		req = p.trace(req)
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
+	resp.Body.Close()
 
 	if !validStatus(resp.StatusCode) {
 		return ErrServiceUnavailable
 	}
 
-	return nil
+	if err := checkProtocol(resp, p.Protocol); err != nil {
+		return err
+	}
+
+	if err := checkFinalURL(resp, p.FinalURL); err != nil {
+		return err
+	}
+
+	return checkHeaders(resp.Header, p.ExpectHeaders)
 }
 
 // PingFactory implements the PingerFactory
@@ -70,14 +253,22 @@ func (factory *PingFactory) Create(s Service) (Pinger, error) {
 	if s.Type != "ping" {
 		return nil, ErrInvalidCreate
 	}
+	client, client6, err := newFamilyClients(s)
+	if err != nil {
+		return nil, err
+	}
 	return &Ping{
-		Service: Service{URL: s.URL},
+		Service: s,
+		client:  client,
+		client6: client6,
 	}, nil
 }
 
 // Grep checks a response body for a value
 type Grep struct {
 	Service
+	timingRecorder
+	client *http.Client
 }
 
 // GetService return the Service pointer
@@ -88,8 +279,13 @@ func (p *Grep) GetService() *Service {
 // Status requests a page given a URL and checks the response for
 // a value matching the regex
 func (p *Grep) Status() error {
+	req, err := http.NewRequest(http.MethodGet, p.URL, nil)
+	if err != nil {
+		return err
+	}
+
 	// hit the URL and get a response
-	resp, err := http.Get(p.URL)
+	resp, err := clientOrDefault(p.client).Do(p.trace(req))
 	if err != nil {
 		return err
 	}
@@ -99,6 +295,14 @@ func (p *Grep) Status() error {
 		return ErrServiceUnavailable
 	}
 
+	if err := checkFinalURL(resp, p.FinalURL); err != nil {
+		return err
+	}
+
+	if err := checkHeaders(resp.Header, p.ExpectHeaders); err != nil {
+		return err
+	}
+
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	re := regexp.MustCompile(p.Regex)
 	if !re.Match(bodyBytes) {
@@ -117,9 +321,14 @@ func (factory *GrepFactory) Create(s Service) (Pinger, error) {
 	if s.Type != "grep" {
 		return nil, ErrInvalidCreate
 	}
+	client, err := newHTTPClient(s)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Grep{
-		Service: Service{URL: s.URL, Regex: s.Regex},
+		Service: s,
+		client:  client,
 	}, nil
 }
 
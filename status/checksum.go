@@ -0,0 +1,92 @@
+package status
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when the fetched content does
+// not hash to the expected value
+var ErrChecksumMismatch = errors.New("commands: checksum mismatch")
+
+// Checksum compares the SHA-256 of a response body, or of selected
+// response headers, against an expected value to catch defacement or
+// unexpected content changes
+type Checksum struct {
+	Service
+	timingRecorder
+	client *http.Client
+}
+
+// GetService return the Service pointer
+func (c *Checksum) GetService() *Service {
+	return &c.Service
+}
+
+// Status fetches the URL and compares the hash of the content. A
+// mismatch is down, or degraded when on_mismatch is "degraded".
+func (c *Checksum) Status() error {
+	req, err := http.NewRequest(http.MethodGet, c.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := clientOrDefault(c.client).Do(c.trace(req))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !validStatus(resp.StatusCode) {
+		return ErrServiceUnavailable
+	}
+
+	if err := checkHeaders(resp.Header, c.ExpectHeaders); err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if len(c.HashHeaders) == 0 {
+		if _, err := io.Copy(h, resp.Body); err != nil {
+			return err
+		}
+	} else {
+		for _, name := range c.HashHeaders {
+			fmt.Fprintf(h, "%s: %s\n", http.CanonicalHeaderKey(name), resp.Header.Get(name))
+		}
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if strings.EqualFold(sum, c.SHA256) {
+		return nil
+	}
+	if c.OnMismatch == "degraded" {
+		return Degraded("checksum mismatch, got sha256 " + sum)
+	}
+	return ErrChecksumMismatch
+}
+
+// ChecksumFactory implements the PingerFactory
+// interface
+type ChecksumFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *ChecksumFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "checksum" {
+		return nil, ErrInvalidCreate
+	}
+	client, err := newHTTPClient(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Checksum{
+		Service: s,
+		client:  client,
+	}, nil
+}
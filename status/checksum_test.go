@@ -0,0 +1,63 @@
+package status
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChecksumStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "hello")
+	}))
+	defer ts.Close()
+
+	const (
+		helloSum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+		// sha256 of "Etag: \"v1\"\n"
+		etagSum = "8aba917998b3f61cf678d02c2af4dfe51028f05428201d63a7d8d6c30bbe60ea"
+	)
+
+	tt := []struct {
+		name     string
+		service  Service
+		expected error
+	}{
+		{name: "body match", service: Service{SHA256: helloSum}, expected: nil},
+		{name: "body match upper case", service: Service{SHA256: "2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824"}, expected: nil},
+		{name: "body mismatch", service: Service{SHA256: etagSum}, expected: ErrChecksumMismatch},
+		{name: "header match", service: Service{SHA256: etagSum, HashHeaders: []string{"ETag"}}, expected: nil},
+		{name: "header mismatch", service: Service{SHA256: helloSum, HashHeaders: []string{"ETag"}}, expected: ErrChecksumMismatch},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.service.URL = ts.URL
+			c := Checksum{Service: tc.service}
+			if actual := c.Status(); actual != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChecksumHeadersDegraded(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+	}))
+	defer ts.Close()
+
+	c := Checksum{Service: Service{URL: ts.URL, HashHeaders: []string{"etag"}, SHA256: "00", OnMismatch: "degraded"}}
+	if err := c.Status(); !IsDegraded(err) {
+		t.Errorf("expected degraded got %v", err)
+	}
+}
+
+func TestChecksumFactoryCreateErr(t *testing.T) {
+	f := ChecksumFactory{}
+	if _, err := f.Create(Service{Type: "ping"}); err != ErrInvalidCreate {
+		t.Errorf("expected %v got %v", ErrInvalidCreate, err)
+	}
+}
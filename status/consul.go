@@ -0,0 +1,119 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrConsulRefused is returned when the Consul API rejects a request
+var ErrConsulRefused = errors.New("discovery: consul API request rejected")
+
+// defaultConsulURL is the address of the local Consul agent
+const defaultConsulURL = "http://127.0.0.1:8500"
+
+// ConsulDiscoverer discovers the instances of services registered in
+// the Consul catalog, checking each at its address and port
+type ConsulDiscoverer struct {
+	config DiscoveryConfig
+
+	// mu guards client, made on first use as it reads the CA bundle
+	mu     sync.Mutex
+	client *http.Client
+}
+
+func newConsulDiscoverer(c DiscoveryConfig) (*ConsulDiscoverer, error) {
+	if c.URL == "" {
+		c.URL = defaultConsulURL
+	}
+	if err := validateHTTPURL(c.URL); err != nil {
+		return nil, err
+	}
+	return &ConsulDiscoverer{config: c}, nil
+}
+
+// consulInstance is an instance of a service in the Consul catalog.
+// ServiceAddress is empty when the service has the address of its
+// node.
+type consulInstance struct {
+	Address        string
+	ServiceAddress string
+	ServicePort    int
+}
+
+// Discover returns the services of the instances of every name,
+// sorted by name
+func (d *ConsulDiscoverer) Discover(ctx context.Context) ([]Service, error) {
+	var services []Service
+	for _, name := range d.config.Names {
+		instances, err := d.instances(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, in := range instances {
+			host := in.ServiceAddress
+			if host == "" {
+				host = in.Address
+			}
+			services = append(services, instanceService(d.config, name, host, in.ServicePort))
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+// instances returns the instances of the service name in the catalog
+func (d *ConsulDiscoverer) instances(ctx context.Context, name string) ([]consulInstance, error) {
+	client, err := d.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	if d.config.Tag != "" {
+		q.Set("tag", d.config.Tag)
+	}
+	if d.config.Datacenter != "" {
+		q.Set("dc", d.config.Datacenter)
+	}
+	u := strings.TrimSuffix(d.config.URL, "/") + "/v1/catalog/service/" + url.PathEscape(name)
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if d.config.Token != "" {
+		req.Header.Set("X-Consul-Token", readSecret(d.config.Token))
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{Err: ErrConsulRefused, Code: resp.StatusCode}
+	}
+	var instances []consulInstance
+	err = json.NewDecoder(resp.Body).Decode(&instances)
+	return instances, err
+}
+
+func (d *ConsulDiscoverer) httpClient() (*http.Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client == nil {
+		client, err := discoveryClient(d.config.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		d.client = client
+	}
+	return d.client, nil
+}
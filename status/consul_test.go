@@ -0,0 +1,60 @@
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestConsulDiscoverer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path + "?" + r.URL.RawQuery {
+		case "/v1/catalog/service/api?dc=eu&tag=v2":
+			w.Write([]byte(`[
+				{"Node": "b", "Address": "10.0.0.2", "ServiceAddress": "", "ServicePort": 8080},
+				{"Node": "a", "Address": "10.0.0.1", "ServiceAddress": "172.16.0.1", "ServicePort": 8080}
+			]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	d, err := NewDiscoverer(DiscoveryConfig{
+		Type:       "consul",
+		URL:        server.URL,
+		Token:      "secret",
+		Names:      []string{"api", "unknown"},
+		Tag:        "v2",
+		Datacenter: "eu",
+		Path:       "health",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	services, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Service{
+		{Name: "api@10.0.0.2:8080", Type: "ping", URL: "http://10.0.0.2:8080/health", Group: "api", Pool: "api"},
+		{Name: "api@172.16.0.1:8080", Type: "ping", URL: "http://172.16.0.1:8080/health", Group: "api", Pool: "api"},
+	}
+	if !reflect.DeepEqual(services, expected) {
+		t.Errorf("expected %+v got %+v", expected, services)
+	}
+
+	d, _ = NewDiscoverer(DiscoveryConfig{Type: "consul", URL: server.URL, Names: []string{"api"}})
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Error("expected an error without a token")
+	}
+	if _, err := NewDiscoverer(DiscoveryConfig{Type: "consul"}); err != ErrMissingNames {
+		t.Errorf("expected ErrMissingNames got %v", err)
+	}
+}
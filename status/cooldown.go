@@ -0,0 +1,42 @@
+package status
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidCooldown is returned for a notifier cooldown which is
+// not a positive duration
+var ErrInvalidCooldown = errors.New("notify: cooldown must be a positive duration")
+
+// cooldownNotifier passes on at most one down or degraded alert per
+// service and severity in each cooldown, so a service getting worse is
+// still alerted on. Recoveries and other alerts always go through. The
+// last alerts are only kept in memory, unlike those of the services,
+// so the cooldown starts over on a restart or a reload.
+type cooldownNotifier struct {
+	Notifier
+	cooldown time.Duration
+
+	mu   sync.Mutex
+	last map[string]Alert
+}
+
+// Notify notifies the wrapped Notifier unless the service was
+// alerted on within the cooldown at the same or a higher severity
+func (n *cooldownNotifier) Notify(a Alert) error {
+	if a.Type != AlertTypeDown && a.Type != AlertTypeDegraded {
+		return n.Notifier.Notify(a)
+	}
+
+	id := a.Service.ID()
+	n.mu.Lock()
+	if last, ok := n.last[id]; ok && a.Time.Sub(last.Time) < n.cooldown && !worsens(a.Severity, last.Severity) {
+		n.mu.Unlock()
+		return nil
+	}
+	n.last[id] = a
+	n.mu.Unlock()
+	return n.Notifier.Notify(a)
+}
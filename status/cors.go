@@ -0,0 +1,102 @@
+package status
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS headers sent to the origins allowed
+const (
+	corsMethods = "GET, HEAD, POST, PUT, DELETE"
+	corsHeaders = "Authorization, Content-Type, If-None-Match, If-Modified-Since"
+	// corsExpose are the response headers read by the pages of other
+	// origins which are not safelisted, so they can poll conditionally
+	corsExpose = "ETag"
+)
+
+// CORSConfig lets the pages of other origins call the API from
+// browsers, such as third-party dashboards. "*" allows every origin.
+type CORSConfig struct {
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	// AllowCredentials lets the pages send the cookies and basic auth
+	// of the viewer, when their origin is listed
+	AllowCredentials bool `json:"allow_credentials,omitempty"`
+	// MaxAge is how long browsers cache the answer to a preflight
+	// request, such as "1h"
+	MaxAge string `json:"max_age,omitempty"`
+}
+
+// CORS adds the CORS headers of a CORSConfig to the responses of the
+// API and answers the preflight requests
+type CORS struct {
+	origins     map[string]bool
+	any         bool
+	credentials bool
+	maxAge      time.Duration
+}
+
+// NewCORS returns the CORS of the config, which allows no origin
+// without AllowedOrigins
+func NewCORS(c CORSConfig) (*CORS, error) {
+	cors := &CORS{origins: make(map[string]bool), credentials: c.AllowCredentials}
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			cors.any = true
+			continue
+		}
+		cors.origins[strings.TrimSuffix(o, "/")] = true
+	}
+	if c.MaxAge != "" {
+		d, err := time.ParseDuration(c.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		cors.maxAge = d
+	}
+	return cors, nil
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin of a request
+// from origin, empty when the origin is not allowed. Credentials are
+// only allowed to the origins listed.
+func (c *CORS) allowOrigin(origin string) string {
+	switch {
+	case origin == "":
+		return ""
+	case c.origins[origin]:
+		return origin
+	case c.any && !c.credentials:
+		return "*"
+	}
+	return ""
+}
+
+// Wrap adds the CORS headers to the responses of h to the origins
+// allowed, and answers their preflight requests itself
+func (c *CORS) Wrap(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		allowed := c.allowOrigin(r.Header.Get("Origin"))
+		if allowed == "" {
+			h(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", allowed)
+		if allowed != "*" && c.credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", corsMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+			if c.maxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.maxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", corsExpose)
+		h(w, r)
+	}
+}
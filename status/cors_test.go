@@ -0,0 +1,60 @@
+package status
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	if _, err := NewCORS(CORSConfig{MaxAge: "soon"}); err == nil {
+		t.Error("expected an invalid max age to be rejected")
+	}
+	cors, err := NewCORS(CORSConfig{AllowedOrigins: []string{"https://dash.example.com/"}, MaxAge: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	handler := cors.Wrap(func(w http.ResponseWriter, r *http.Request) { called = true })
+	do := func(method, origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/status", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		called = false
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	w := do(http.MethodOptions, "https://dash.example.com")
+	if w.Code != http.StatusNoContent || called || w.Header().Get("Access-Control-Allow-Origin") != "https://dash.example.com" ||
+		w.Header().Get("Access-Control-Max-Age") != "3600" || w.Header().Get("Access-Control-Allow-Headers") == "" {
+		t.Errorf("expected the preflight to be answered got %d %v", w.Code, w.Header())
+	}
+	w = do(http.MethodGet, "https://dash.example.com")
+	if !called || w.Header().Get("Access-Control-Expose-Headers") != "ETag" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("expected the headers of an allowed origin got %v", w.Header())
+	}
+	w = do(http.MethodGet, "https://evil.example.com")
+	if !called || w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Vary") != "Origin" {
+		t.Errorf("expected no CORS headers for another origin got %v", w.Header())
+	}
+
+	cors, _ = NewCORS(CORSConfig{AllowedOrigins: []string{"*", "https://dash.example.com"}, AllowCredentials: true})
+	handler = cors.Wrap(func(w http.ResponseWriter, r *http.Request) {})
+	if w := do(http.MethodGet, "https://dash.example.com"); w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("expected credentials for a listed origin got %v", w.Header())
+	}
+	if w := do(http.MethodGet, "https://other.example.com"); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no wildcard along with credentials got %v", w.Header())
+	}
+	cors, _ = NewCORS(CORSConfig{AllowedOrigins: []string{"*"}})
+	handler = cors.Wrap(func(w http.ResponseWriter, r *http.Request) {})
+	if w := do(http.MethodGet, "https://other.example.com"); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("expected every origin to be allowed got %v", w.Header())
+	}
+}
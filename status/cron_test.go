@@ -0,0 +1,73 @@
+package status
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := parseCron(expr); err != ErrInvalidCron {
+			t.Errorf("%q: expected %v got %v", expr, ErrInvalidCron, err)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2018-01-07 is a Sunday
+	sunday := time.Date(2018, 1, 7, 2, 30, 0, 0, time.UTC)
+
+	tt := []struct {
+		name     string
+		expr     string
+		t        time.Time
+		expected bool
+	}{
+		{name: "every minute", expr: "* * * * *", t: sunday, expected: true},
+		{name: "exact", expr: "30 2 * * *", t: sunday, expected: true},
+		{name: "wrong minute", expr: "31 2 * * *", t: sunday, expected: false},
+		{name: "day name", expr: "30 2 * * sun", t: sunday, expected: true},
+		{name: "sunday as 7", expr: "30 2 * * 7", t: sunday, expected: true},
+		{name: "weekdays", expr: "30 2 * * mon-fri", t: sunday, expected: false},
+		{name: "step", expr: "*/15 * * * *", t: sunday, expected: true},
+		{name: "list", expr: "0,30 1,2 * * *", t: sunday, expected: true},
+		{name: "month name", expr: "30 2 * feb *", t: sunday, expected: false},
+		{name: "day of month or week", expr: "30 2 1 * sun", t: sunday, expected: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := parseCron(tc.expr)
+			if err != nil {
+				t.Fatalf("parse %q: %v", tc.expr, err)
+			}
+			if actual := c.Matches(tc.t); actual != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCronActiveWithin(t *testing.T) {
+	c, _ := parseCron("0 2 * * sun")
+
+	tt := []struct {
+		name     string
+		t        time.Time
+		expected bool
+	}{
+		{name: "before", t: time.Date(2018, 1, 7, 1, 59, 0, 0, time.UTC), expected: false},
+		{name: "start", t: time.Date(2018, 1, 7, 2, 0, 0, 0, time.UTC), expected: true},
+		{name: "inside", t: time.Date(2018, 1, 7, 3, 59, 59, 0, time.UTC), expected: true},
+		{name: "end", t: time.Date(2018, 1, 7, 4, 0, 0, 0, time.UTC), expected: false},
+		{name: "next day", t: time.Date(2018, 1, 8, 3, 0, 0, 0, time.UTC), expected: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := c.activeWithin(tc.t, 2*time.Hour); actual != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package status
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Errors returned by the encryption of the storage
+var (
+	ErrStorageKey = errors.New("storage: key must be 16, 24 or 32 bytes, base64 encoded")
+	ErrEncrypted  = errors.New("storage: encrypted messages need the storage key")
+)
+
+// encryptedPrefix marks an encrypted message in the storage
+const encryptedPrefix = "enc:"
+
+// storageAEAD encrypts the messages of the storages opened after
+// SetStorageKey, nil when they are kept in plain text
+var storageAEAD cipher.AEAD
+
+// SetStorageKey encrypts the messages of incidents, the errors of
+// alert deliveries and the addresses of subscribers in the storages
+// opened from now on with AES-GCM.
+// The key, which may be "env:NAME", is base64 encoded; an empty one
+// keeps them in plain text.
+func SetStorageKey(ref string) error {
+	key := readSecret(ref)
+	if key == "" {
+		storageAEAD = nil
+		return nil
+	}
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return ErrStorageKey
+	}
+	block, err := aes.NewCipher(b)
+	if err != nil {
+		return ErrStorageKey
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	storageAEAD = aead
+	return nil
+}
+
+// encrypt returns s encrypted with a random nonce, unchanged when
+// empty or without a key
+func encrypt(aead cipher.AEAD, s string) (string, error) {
+	if aead == nil || s == "" {
+		return s, nil
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(s), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt returns s decrypted, unchanged when it was stored in plain
+// text
+func decrypt(aead cipher.AEAD, s string) (string, error) {
+	if !strings.HasPrefix(s, encryptedPrefix) {
+		return s, nil
+	}
+	if aead == nil {
+		return "", ErrEncrypted
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encryptedPrefix))
+	if err != nil || len(b) < aead.NonceSize() {
+		return "", ErrStorageKey
+	}
+	plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrStorageKey
+	}
+	return string(plain), nil
+}
+
+// convertMessages returns a copy of data with f applied to the
+// messages of its incidents, the errors of its deliveries and the
+// addresses of its subscribers
+func convertMessages(data storageData, f func(string) (string, error)) (storageData, error) {
+	var err error
+	convert := func(s *string) {
+		if err == nil {
+			*s, err = f(*s)
+		}
+	}
+
+	incidents := make([]Incident, len(data.Incidents))
+	for i, inc := range data.Incidents {
+		convert(&inc.Message)
+		convert(&inc.Postmortem)
+		updates := make([]IncidentUpdate, len(inc.Updates))
+		for j, u := range inc.Updates {
+			convert(&u.Message)
+			updates[j] = u
+		}
+		if inc.Updates != nil {
+			inc.Updates = updates
+		}
+		incidents[i] = inc
+	}
+	deliveries := make([]AlertDelivery, len(data.Deliveries))
+	for i, d := range data.Deliveries {
+		convert(&d.Error)
+		deliveries[i] = d
+	}
+	subscribers := make([]Subscriber, len(data.Subscribers))
+	for i, s := range data.Subscribers {
+		convert(&s.Email)
+		subscribers[i] = s
+	}
+	if data.Incidents != nil {
+		data.Incidents = incidents
+	}
+	if data.Subscribers != nil {
+		data.Subscribers = subscribers
+	}
+	if data.Deliveries != nil {
+		data.Deliveries = deliveries
+	}
+	if data.Tenants != nil {
+		tenants := make(map[string]*storageData, len(data.Tenants))
+		for name, t := range data.Tenants {
+			c, terr := convertMessages(*t, f)
+			if err == nil {
+				err = terr
+			}
+			tenants[name] = &c
+		}
+		data.Tenants = tenants
+	}
+	return data, err
+}
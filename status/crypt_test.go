@@ -0,0 +1,57 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorageEncryption(t *testing.T) {
+	ctx := context.Background()
+	dir, _ := ioutil.TempDir("", "storage")
+	defer os.RemoveAll(dir)
+	path := dir + "/status.json"
+
+	os.Setenv("STATUS_STORAGE_KEY", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	defer os.Unsetenv("STATUS_STORAGE_KEY")
+	if err := SetStorageKey("env:STATUS_STORAGE_KEY"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetStorageKey("")
+
+	db, _ := OpenStorage(path)
+	inc, _ := db.StartIncident(ctx, "api", StateDown, SeverityCritical, "dial tcp db01.internal:5432", time.Now())
+	db.AddIncidentUpdate(ctx, inc.ID, IncidentUpdate{Time: time.Now(), Message: "failing over db01.internal"})
+	db.AddDelivery(ctx, AlertDelivery{Service: "api", Error: "post https://hooks.internal: refused"})
+	db.SaveSubscriber(ctx, Subscriber{Email: "ops@corp.internal", Token: "t"})
+
+	b, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(b), ".internal") {
+		t.Errorf("expected the messages encrypted got %s", b)
+	}
+
+	// the messages are decrypted when read
+	db, err := OpenStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inc, _, _ = db.Incident(ctx, inc.ID)
+	deliveries, _ := db.Deliveries(ctx)
+	subscribers, _ := db.Subscribers(ctx)
+	if inc.Message != "dial tcp db01.internal:5432" || inc.Updates[0].Message != "failing over db01.internal" ||
+		deliveries[0].Error != "post https://hooks.internal: refused" || subscribers[0].Email != "ops@corp.internal" {
+		t.Errorf("expected the messages decrypted got %+v, %+v and %+v", inc, deliveries, subscribers)
+	}
+
+	SetStorageKey("")
+	if _, err := OpenStorage(path); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("expected %v got %v", ErrEncrypted, err)
+	}
+	if err := SetStorageKey("c2hvcnQ="); err != ErrStorageKey {
+		t.Errorf("expected %v got %v", ErrStorageKey, err)
+	}
+}
@@ -0,0 +1,163 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned re-sending dead letters
+var (
+	ErrDeadLetterNotFound = errors.New("notify: dead letter not found")
+	ErrNotifierGone       = errors.New("notify: the notifier of the dead letter is no longer configured")
+)
+
+// DeadLetter is an alert a notifier failed to deliver after
+// all of its retries
+type DeadLetter struct {
+	ID string `json:"id"`
+	// Notifier is the index the notifier had in the
+	// NotificationManager, counting on into the notifiers
+	// of its escalations, and NotifierID its identity, which
+	// the letter is re-sent to
+	Notifier   int       `json:"notifier"`
+	NotifierID string    `json:"notifier_id,omitempty"`
+	Alert      Alert     `json:"alert"`
+	Error      string    `json:"error"`
+	Attempts   int       `json:"attempts"`
+	Time       time.Time `json:"time"`
+}
+
+// DeadLetterStore keeps failed deliveries so they can be inspected
+// and re-sent. With a path they are persisted to a JSON file and
+// survive restarts.
+type DeadLetterStore struct {
+	mu      sync.Mutex
+	path    string
+	letters []DeadLetter
+	next    int
+}
+
+// NewDeadLetterStore returns a store persisted to path, loading the
+// letters already in it. An empty path keeps them in memory.
+func NewDeadLetterStore(path string) (*DeadLetterStore, error) {
+	ds := &DeadLetterStore{path: path}
+	if path == "" {
+		return ds, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ds, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &ds.letters); err != nil {
+		return nil, err
+	}
+	for _, l := range ds.letters {
+		if id, err := strconv.Atoi(l.ID); err == nil && id > ds.next {
+			ds.next = id
+		}
+	}
+	return ds, nil
+}
+
+// Add stores a dead letter, assigning its ID
+func (ds *DeadLetterStore) Add(l DeadLetter) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.next++
+	l.ID = strconv.Itoa(ds.next)
+	ds.letters = append(ds.letters, l)
+	return ds.save()
+}
+
+// List returns the stored dead letters, oldest first
+func (ds *DeadLetterStore) List() []DeadLetter {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return append([]DeadLetter(nil), ds.letters...)
+}
+
+// Remove deletes the dead letter with the given ID and returns it
+func (ds *DeadLetterStore) Remove(id string) (DeadLetter, bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	for i, l := range ds.letters {
+		if l.ID == id {
+			ds.letters = append(ds.letters[:i:i], ds.letters[i+1:]...)
+			return l, true, ds.save()
+		}
+	}
+	return DeadLetter{}, false, nil
+}
+
+// save writes the letters to a temporary file which replaces
+// the store file, so a crash never leaves it half written
+func (ds *DeadLetterStore) save() error {
+	if ds.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(ds.letters, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ds.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ds.path)
+}
+
+// DeadLetterHandler is a HandlerFunc which lists the dead letters
+// (GET /api/deadletters/), re-sends one (POST /api/deadletters/{id})
+// or discards it (DELETE /api/deadletters/{id}). Requests present
+// token, or an API key with the admin scope, as a bearer token.
+func DeadLetterHandler(nm *NotificationManager, token string) http.HandlerFunc {
+	token = readSecret(token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !granted(r, token, ScopeAdmin) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/deadletters/")
+
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(nm.DeadLetters.List())
+		case r.Method == http.MethodPost && id != "":
+			if err := nm.Resend(id); err == ErrDeadLetterNotFound {
+				http.NotFound(w, r)
+			} else if err == ErrNotifierGone {
+				http.Error(w, err.Error(), http.StatusConflict)
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		case r.Method == http.MethodDelete && id != "":
+			_, ok, err := nm.DeadLetters.Remove(id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package status
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// failingNotifier fails the first fails deliveries
+type failingNotifier struct {
+	fails    int
+	attempts int
+	recordingNotifier
+}
+
+func (f *failingNotifier) Notify(a Alert) error {
+	f.attempts++
+	if f.attempts <= f.fails {
+		return errors.New("unavailable")
+	}
+	return f.recordingNotifier.Notify(a)
+}
+
+func TestDeliverRetries(t *testing.T) {
+	f := &failingNotifier{fails: 2}
+	nm := NewNotificationManager([]Notifier{f}, 0)
+	nm.RetryBackoff = time.Millisecond
+	nm.DeadLetters, _ = NewDeadLetterStore("")
+
+	nm.CheckAndNotify(&Service{Name: "api"}, false, "timeout")
+	if f.attempts != 3 || len(f.alerts) != 1 {
+		t.Errorf("expected delivery on attempt 3 got %d attempts", f.attempts)
+	}
+	if l := nm.DeadLetters.List(); len(l) != 0 {
+		t.Errorf("expected no dead letters got %v", l)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deadletters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deadletters.json")
+
+	f := &failingNotifier{fails: 2}
+	nm := NewNotificationManager([]Notifier{f}, 0)
+	nm.Retries = 1
+	nm.RetryBackoff = time.Millisecond
+	nm.DeadLetters, _ = NewDeadLetterStore(path)
+
+	nm.CheckAndNotify(&Service{Name: "api"}, false, "timeout")
+	if f.attempts != 2 {
+		t.Errorf("expected 2 attempts got %d", f.attempts)
+	}
+
+	// the letter survives a restart
+	nm.DeadLetters, err = NewDeadLetterStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	letters := nm.DeadLetters.List()
+	if len(letters) != 1 || letters[0].Alert.Message != "timeout" || letters[0].Attempts != 2 {
+		t.Fatalf("expected the failed alert got %+v", letters)
+	}
+
+	h := DeadLetterHandler(nm, "secret")
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		h(w, r)
+		return w
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+		if w := do(method, "/api/deadletters/"+letters[0].ID, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected %v got %v", method, http.StatusUnauthorized, w.Code)
+		}
+	}
+	w := do(http.MethodPost, "/api/deadletters/"+letters[0].ID, "secret")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected %v got %v", http.StatusNoContent, w.Code)
+	}
+	if len(f.alerts) != 1 || len(nm.DeadLetters.List()) != 0 {
+		t.Errorf("expected the letter re-sent and removed")
+	}
+
+	w = do(http.MethodPost, "/api/deadletters/"+letters[0].ID, "secret")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %v got %v", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestResendNotifierIdentity(t *testing.T) {
+	f := &failingNotifier{fails: 1}
+	nm := NewNotificationManager([]Notifier{f}, 0)
+	nm.NotifierIDs = []string{"webhook-a"}
+	nm.Retries = 0
+	nm.DeadLetters, _ = NewDeadLetterStore("")
+	nm.CheckAndNotify(&Service{Name: "api"}, false, "timeout")
+	letters := nm.DeadLetters.List()
+	if len(letters) != 1 || letters[0].NotifierID != "webhook-a" {
+		t.Fatalf("expected the identity of the notifier kept got %+v", letters)
+	}
+
+	// the notifier moved, so the letter follows it
+	other := &recordingNotifier{}
+	nm.Notifiers, nm.NotifierIDs = []Notifier{other, f}, []string{"webhook-b", "webhook-a"}
+	if err := nm.Resend(letters[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.alerts) != 1 || len(other.alerts) != 0 {
+		t.Errorf("expected the letter re-sent to its notifier got %v %v", f.alerts, other.alerts)
+	}
+
+	// the notifier is gone, so the letter is not sent to another
+	nm.Notifiers, nm.NotifierIDs = []Notifier{&failingNotifier{fails: 1}}, []string{"webhook-a"}
+	nm.CheckAndNotify(&Service{Name: "db"}, false, "timeout")
+	letters = nm.DeadLetters.List()
+	nm.Notifiers, nm.NotifierIDs = []Notifier{other}, []string{"webhook-b"}
+	if len(letters) != 1 || nm.Resend(letters[0].ID) != ErrNotifierGone || len(other.alerts) != 0 {
+		t.Errorf("expected the letter kept for its notifier got %+v %v", letters, other.alerts)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for retry := 1; retry <= 4; retry++ {
+		d := retryDelay(time.Second, retry)
+		max := time.Second << uint(retry-1)
+		if d < max/2 || d > max {
+			t.Errorf("expected retry %d to wait between %v and %v got %v", retry, max/2, max, d)
+		}
+	}
+}
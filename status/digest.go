@@ -0,0 +1,196 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDigest is returned for a digest without a valid
+// schedule, period or notifiers
+var ErrInvalidDigest = errors.New("notify: digest needs a cron schedule, a positive period and notifiers")
+
+// digestSlowest is how many of the slowest services a digest lists
+const digestSlowest = 5
+
+// Digest summarises the services over a period
+type Digest struct {
+	Start     time.Time       `json:"start"`
+	End       time.Time       `json:"end"`
+	Services  []DigestService `json:"services"`
+	Incidents []Incident      `json:"incidents"`
+}
+
+// DigestService is the summary of a single service in a Digest
+type DigestService struct {
+	ID    string `json:"id"`
+	State State  `json:"state"`
+	// Uptime is the percentage of the period the service was not down
+	Uptime  float64       `json:"uptime"`
+	Latency time.Duration `json:"latency"`
+}
+
+// NewDigest returns the digest of the period between start and end,
+// from the last results and the incidents in db
+func NewDigest(ctx context.Context, results []Result, db StorageBackend, start, end time.Time) (Digest, error) {
+	d := Digest{Start: start, End: end}
+	for _, r := range results {
+		ds := DigestService{ID: r.Service.ID(), State: r.State, Uptime: 100, Latency: r.Latency}
+		if db != nil {
+			var err error
+			if ds.Uptime, err = Uptime(ctx, db, ds.ID, start, end); err != nil {
+				return Digest{}, err
+			}
+		}
+		d.Services = append(d.Services, ds)
+	}
+	if db != nil {
+		var err error
+		if d.Incidents, err = db.IncidentsBetween(ctx, start, end); err != nil {
+			return Digest{}, err
+		}
+	}
+	return d, nil
+}
+
+// String formats the digest as a plain text message
+func (d Digest) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Status digest for %s to %s\n", d.Start.Format("2006-01-02 15:04"), d.End.Format("2006-01-02 15:04"))
+
+	up := 0
+	var notUp []string
+	for _, s := range d.Services {
+		if s.State == StateUp {
+			up++
+			continue
+		}
+		notUp = append(notUp, fmt.Sprintf("  %s: %s", s.ID, s.State))
+	}
+	fmt.Fprintf(&b, "\nCurrent status: %d of %d up\n", up, len(d.Services))
+	for _, line := range notUp {
+		b.WriteString(line + "\n")
+	}
+
+	fmt.Fprintf(&b, "\nIncidents: %d\n", len(d.Incidents))
+	for _, inc := range d.Incidents {
+		duration := "ongoing"
+		if !inc.Ongoing() {
+			duration = inc.End.Sub(inc.Start).Round(time.Second).String()
+		}
+		fmt.Fprintf(&b, "  %s %s at %s, %s", inc.Service, inc.State, inc.Start.Format("2006-01-02 15:04"), duration)
+		if inc.Message != "" {
+			fmt.Fprintf(&b, ": %s", firstLine(inc.Message))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\nUptime:\n")
+	for _, s := range d.Services {
+		fmt.Fprintf(&b, "  %s %.2f%%\n", s.ID, s.Uptime)
+	}
+
+	slowest := append([]DigestService(nil), d.Services...)
+	sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].Latency > slowest[j].Latency })
+	if len(slowest) > digestSlowest {
+		slowest = slowest[:digestSlowest]
+	}
+	b.WriteString("\nSlowest:\n")
+	for _, s := range slowest {
+		fmt.Fprintf(&b, "  %s %v\n", s.ID, s.Latency.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// firstLine returns s up to its first newline
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// DigestConfig holds the configuration of a scheduled digest
+type DigestConfig struct {
+	// Cron is when the digest is sent, in Timezone (UTC by
+	// default), and Period how far back it looks (default 24h)
+	Cron      string           `json:"cron"`
+	Timezone  string           `json:"timezone,omitempty"`
+	Period    string           `json:"period,omitempty"`
+	Notifiers []NotifierConfig `json:"notifiers"`
+}
+
+// DigestSchedule sends digests to its notifiers on a cron schedule,
+// separately from the alerts
+type DigestSchedule struct {
+	Period    time.Duration
+	Notifiers []Notifier
+
+	cron *cronSchedule
+	loc  *time.Location
+}
+
+// NewDigestSchedule returns the DigestSchedule described by the config
+func NewDigestSchedule(c DigestConfig) (*DigestSchedule, error) {
+	cron, err := parseCron(c.Cron)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	period := 24 * time.Hour
+	if c.Period != "" {
+		if period, err = time.ParseDuration(c.Period); err != nil || period <= 0 {
+			return nil, ErrInvalidDigest
+		}
+	}
+	if len(c.Notifiers) == 0 {
+		return nil, ErrInvalidDigest
+	}
+
+	ds := &DigestSchedule{Period: period, cron: cron, loc: loc}
+	for _, nc := range c.Notifiers {
+		n, err := NewNotifier(nc)
+		if err != nil {
+			return nil, fmt.Errorf("%s notifier: %v", nc.Type, err)
+		}
+		ds.Notifiers = append(ds.Notifiers, n)
+	}
+	return ds, nil
+}
+
+// Run sends a digest of the monitor each time the schedule fires.
+// It does not return.
+func (ds *DigestSchedule) Run(m *Monitor, db StorageBackend) {
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(time.Until(next))
+		if !ds.cron.Matches(next.In(ds.loc)) {
+			continue
+		}
+		ctx, cancel := storageContext()
+		d, err := NewDigest(ctx, m.Results(), db, next.Add(-ds.Period), next)
+		cancel()
+		if err != nil {
+			log.Printf("build digest: %v", err)
+			continue
+		}
+		ds.Send(d)
+	}
+}
+
+// Send delivers a digest to every notifier of the schedule
+func (ds *DigestSchedule) Send(d Digest) {
+	a := Alert{Type: AlertTypeDigest, Severity: SeverityInfo, Message: d.String(), Time: d.End, Digest: &d}
+	for _, n := range ds.Notifiers {
+		if err := n.Notify(a); err != nil && !errors.Is(err, ErrAlertHeld) {
+			log.Printf("send digest: %v", err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package status
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewDigest(t *testing.T) {
+	ctx := context.Background()
+	end := time.Date(2020, 3, 2, 9, 0, 0, 0, time.UTC)
+	start := end.Add(-24 * time.Hour)
+
+	db, _ := OpenStorage("")
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout\ndetails", end.Add(-3*time.Hour))
+	db.ResolveIncident(ctx, "api", end.Add(-3*time.Hour+36*time.Minute))
+	// before the period
+	db.StartIncident(ctx, "db", StateDown, SeverityCritical, "refused", start.Add(-2*time.Hour))
+	db.ResolveIncident(ctx, "db", start.Add(-time.Hour))
+
+	results := []Result{
+		{Service: &Service{Name: "api"}, State: StateUp, Latency: 120 * time.Millisecond},
+		{Service: &Service{Name: "db"}, State: StateDegraded, Latency: 900 * time.Millisecond},
+	}
+	d, err := NewDigest(ctx, results, db, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Incidents) != 1 || d.Incidents[0].Service != "api" {
+		t.Errorf("expected the api incident got %+v", d.Incidents)
+	}
+	if d.Services[0].Uptime != 97.5 || d.Services[1].Uptime != 100 {
+		t.Errorf("expected uptimes 97.5 and 100 got %v and %v", d.Services[0].Uptime, d.Services[1].Uptime)
+	}
+
+	text := d.String()
+	for _, expected := range []string{"1 of 2 up", "db: degraded", "api down at 2020-03-02 06:00, 36m0s: timeout\n", "api 97.50%", "Slowest:\n  db 900ms\n  api 120ms"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected %q in digest:\n%s", expected, text)
+		}
+	}
+}
+
+func TestDigestSchedule(t *testing.T) {
+	if _, err := NewDigestSchedule(DigestConfig{Cron: "0 9 * * mon"}); err != ErrInvalidDigest {
+		t.Errorf("expected %v got %v", ErrInvalidDigest, err)
+	}
+
+	ds, err := NewDigestSchedule(DigestConfig{Cron: "0 9 * * mon", Period: "168h", Notifiers: []NotifierConfig{{Type: "log"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordingNotifier{}
+	ds.Notifiers = []Notifier{rec}
+	ds.Send(Digest{})
+	if len(rec.alerts) != 1 || rec.alerts[0].Type != AlertTypeDigest || rec.alerts[0].Digest == nil {
+		t.Errorf("expected a digest alert got %+v", rec.alerts)
+	}
+	if ds.Period != 168*time.Hour {
+		t.Errorf("expected %v got %v", 168*time.Hour, ds.Period)
+	}
+}
@@ -0,0 +1,279 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the discovery of services
+var (
+	ErrInvalidDiscovery = errors.New("discovery: invalid discovery type")
+	ErrInvalidTemplate  = errors.New("discovery: the service template cannot set a name or url")
+	ErrMissingNames     = errors.New("discovery: consul and dns discovery need the names of the services")
+)
+
+// defaultDiscoveryInterval is how often services are discovered by
+// default
+const defaultDiscoveryInterval = 30 * time.Second
+
+// Discoverer finds services to check, such as those deployed on a
+// cluster
+type Discoverer interface {
+	Discover(ctx context.Context) ([]Service, error)
+}
+
+// DiscoveryConfig holds the configuration of a source of discovered
+// services
+type DiscoveryConfig struct {
+	// Type is "kubernetes", "consul" or "dns"
+	Type string `json:"type"`
+	// URL is the address of the API: by default the cluster the
+	// status page runs in, or the local Consul agent. Token
+	// authenticates with it and may be "env:NAME"; CABundle is a path
+	// to the PEM of its CA, or "env:NAME".
+	URL      string `json:"url,omitempty"`
+	Token    string `json:"token,omitempty"`
+	CABundle string `json:"ca_bundle,omitempty"`
+	// Namespace limits the discovery to one namespace, all of them by
+	// default
+	Namespace string `json:"namespace,omitempty"`
+	// Kinds are the kinds of objects discovered, "services" and
+	// "ingresses" by default
+	Kinds []string `json:"kinds,omitempty"`
+	// Selector is a label selector the objects must match, and
+	// Annotation an annotation they must set to "true". Without
+	// either, they must set the annotation "service-status/check".
+	Selector   string `json:"selector,omitempty"`
+	Annotation string `json:"annotation,omitempty"`
+	// Names are the Consul services, or the DNS SRV records, whose
+	// instances are each checked. Tag only discovers the instances of
+	// the Consul services with the tag, in Datacenter, the one of the
+	// agent by default. Server is the host:port of the DNS server, the
+	// one of the system by default.
+	Names      []string `json:"names,omitempty"`
+	Tag        string   `json:"tag,omitempty"`
+	Datacenter string   `json:"datacenter,omitempty"`
+	Server     string   `json:"server,omitempty"`
+	// Scheme and Path are the url checked on the Consul and DNS
+	// instances, "http" and "/" by default
+	Scheme string `json:"scheme,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Interval is how often the services are discovered again, 30s by
+	// default
+	Interval string `json:"interval,omitempty"`
+	// Service holds the settings of the services discovered, a ping
+	// check by default. Their name and url are discovered.
+	Service Service `json:"service,omitempty"`
+}
+
+// NewDiscoverer returns the Discoverer of the config
+func NewDiscoverer(c DiscoveryConfig) (Discoverer, error) {
+	if c.Service.Name != "" || c.Service.URL != "" {
+		return nil, ErrInvalidTemplate
+	}
+	if c.Service.Type == "" {
+		c.Service.Type = "ping"
+	}
+	// the discovered services only add a name and url to the template
+	sample := c.Service
+	sample.Name, sample.URL = "discovered", "http://discovered.example.com:80/"
+	if err := validateService(sample); err != nil {
+		return nil, fmt.Errorf("service: %v", err)
+	}
+	if c.Interval != "" {
+		if _, err := time.ParseDuration(c.Interval); err != nil {
+			return nil, err
+		}
+	}
+
+	switch c.Type {
+	case "kubernetes":
+		return newKubernetesDiscoverer(c)
+	case "consul", "dns":
+		if len(c.Names) == 0 {
+			return nil, ErrMissingNames
+		}
+		if c.Type == "consul" {
+			return newConsulDiscoverer(c)
+		}
+		return newSRVDiscoverer(c)
+	}
+	return nil, ErrInvalidDiscovery
+}
+
+// instanceService returns the service of the template of the config
+// checking the instance at host and port of the pool, the service
+// named pool. The instances are listed under the pool unless the
+// template sets their group.
+func instanceService(c DiscoveryConfig, pool, host string, port int) Service {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	s := c.Service
+	s.Name = pool + "@" + addr
+	s.Pool = pool
+	if s.Group == "" {
+		s.Group = pool
+	}
+	switch s.Type {
+	case "tcp", "icmp":
+		s.URL, s.Port = host, strconv.Itoa(port)
+	default:
+		scheme := c.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		path := c.Path
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		s.URL = scheme + "://" + addr + path
+	}
+	return s
+}
+
+// Discovery checks the services its discoverers find along with the
+// configured ones, for as long as they are found. A configured service
+// overrides a discovered one with its name. A discoverer which fails
+// keeps the services it last found, so an outage of its API does not
+// remove them.
+type Discovery struct {
+	admin *Admin
+	// mu guards configured and the services of the sources, and
+	// serializes the reloads of the services
+	mu         sync.Mutex
+	configured []Service
+	sources    []*discoverySource
+}
+
+// discoverySource is a discoverer and the services it last found
+type discoverySource struct {
+	discoverer Discoverer
+	interval   time.Duration
+	services   []Service
+}
+
+// NewDiscovery returns the Discovery of the configured services and
+// the discoverers of configs, reloading the services of admin
+func NewDiscovery(admin *Admin, configured []Service, configs []DiscoveryConfig) (*Discovery, error) {
+	d := &Discovery{admin: admin, configured: configured}
+	for i, c := range configs {
+		discoverer, err := NewDiscoverer(c)
+		if err != nil {
+			return nil, fmt.Errorf("discovery[%d]: %v", i, err)
+		}
+		interval := defaultDiscoveryInterval
+		if c.Interval != "" {
+			interval, _ = time.ParseDuration(c.Interval)
+		}
+		d.sources = append(d.sources, &discoverySource{discoverer: discoverer, interval: interval})
+	}
+	return d, nil
+}
+
+// Refresh discovers the services of every discoverer once and checks
+// them, returning the first error of a discoverer
+func (d *Discovery) Refresh(ctx context.Context) (ServiceChanges, error) {
+	var first error
+	for _, src := range d.sources {
+		if err := d.discover(ctx, src); err != nil && first == nil {
+			first = err
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, err := d.reload(ctx)
+	if err != nil {
+		return c, err
+	}
+	return c, first
+}
+
+// Run discovers the services of each discoverer on its interval. It
+// only returns when there are none.
+func (d *Discovery) Run() {
+	var wg sync.WaitGroup
+	for _, src := range d.sources {
+		wg.Add(1)
+		go func(src *discoverySource) {
+			defer wg.Done()
+			d.run(src)
+		}(src)
+	}
+	wg.Wait()
+}
+
+func (d *Discovery) run(src *discoverySource) {
+	for range time.Tick(src.interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), src.interval)
+		if err := d.discover(ctx, src); err != nil {
+			log.Printf("discover services: %v", err)
+		}
+		d.mu.Lock()
+		c, err := d.reload(ctx)
+		d.mu.Unlock()
+		cancel()
+		if err != nil {
+			log.Printf("discover services: %v", err)
+		} else if len(c.Added)+len(c.Changed)+len(c.Removed) > 0 {
+			log.Printf("Discovered services, %v", c)
+		}
+	}
+}
+
+// discover keeps the services the discoverer of src finds
+func (d *Discovery) discover(ctx context.Context, src *discoverySource) error {
+	services, err := src.discoverer.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	src.services = services
+	d.mu.Unlock()
+	return nil
+}
+
+// SetConfigured replaces the configured services, as when the config
+// is reloaded, keeping the discovered ones
+func (d *Discovery) SetConfigured(ctx context.Context, services []Service) (ServiceChanges, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prev := d.configured
+	d.configured = services
+	c, err := d.reload(ctx)
+	if err != nil {
+		d.configured = prev
+	}
+	return c, err
+}
+
+// reload checks the configured and discovered services. The caller
+// holds mu.
+func (d *Discovery) reload(ctx context.Context) (ServiceChanges, error) {
+	return d.admin.ReloadServices(ctx, d.services())
+}
+
+// services returns the configured services, then the valid services
+// discovered whose name is not taken. The caller holds mu.
+func (d *Discovery) services() []Service {
+	services := append([]Service(nil), d.configured...)
+	seen := make(map[string]bool, len(services))
+	for _, s := range services {
+		seen[s.ID()] = true
+	}
+	for _, src := range d.sources {
+		for _, s := range src.services {
+			if seen[s.ID()] || validateService(s) != nil {
+				continue
+			}
+			seen[s.ID()] = true
+			services = append(services, s)
+		}
+	}
+	return services
+}
@@ -0,0 +1,77 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// fakeDiscoverer finds its services, or fails with err
+type fakeDiscoverer struct {
+	services []Service
+	err      error
+}
+
+func (d *fakeDiscoverer) Discover(ctx context.Context) ([]Service, error) {
+	return d.services, d.err
+}
+
+// checked returns the IDs of the services the admin checks, sorted
+func checked(a *Admin) []string {
+	var ids []string
+	for _, s := range a.Monitor.Services() {
+		ids = append(ids, s.ID())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestDiscovery(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAdmin()
+	fake := &fakeDiscoverer{services: []Service{
+		{Name: "web", Type: "ping", URL: "http://discovered.example.com"},
+		{Name: "api", Type: "ping", URL: "http://api.example.com"},
+		{Name: "broken", Type: "ping"},
+	}}
+	d := &Discovery{admin: a, configured: a.Configured, sources: []*discoverySource{{discoverer: fake}}}
+
+	c, err := d.Refresh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Added, []string{"api"}) || !reflect.DeepEqual(checked(a), []string{"api", "web"}) {
+		t.Errorf("expected api discovered got %+v %v", c, checked(a))
+	}
+	// the configured service overrides the discovered one
+	for _, s := range a.Monitor.Services() {
+		if s.ID() == "web" && s.URL != "http://web.example.com" {
+			t.Errorf("expected the configured web checked got %+v", s)
+		}
+	}
+
+	// a failing discoverer keeps the services it found
+	fake.services, fake.err = nil, errors.New("unreachable")
+	if _, err := d.Refresh(ctx); err != fake.err {
+		t.Errorf("expected the error of the discoverer got %v", err)
+	}
+	if !reflect.DeepEqual(checked(a), []string{"api", "web"}) {
+		t.Errorf("expected api kept got %v", checked(a))
+	}
+
+	// reloading the config keeps the discovered services
+	c, err = d.SetConfigured(ctx, []Service{{Name: "db", Type: "ping", URL: "http://db.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(checked(a), []string{"api", "db", "web"}) {
+		t.Errorf("expected the discovered web checked in place of the configured one got %v", checked(a))
+	}
+
+	fake.err = nil
+	if c, _ = d.Refresh(ctx); !reflect.DeepEqual(c.Removed, []string{"web", "api"}) || !reflect.DeepEqual(checked(a), []string{"db"}) {
+		t.Errorf("expected the services no longer found removed got %+v %v", c, checked(a))
+	}
+}
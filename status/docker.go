@@ -0,0 +1,124 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Errors returned by the docker check
+var (
+	ErrInvalidDockerHost   = errors.New("commands: invalid docker host")
+	ErrContainerNotRunning = errors.New("commands: container not running")
+	ErrContainerUnhealthy  = errors.New("commands: container unhealthy")
+)
+
+// defaultDockerHost is used when a docker service does not
+// specify a URL
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// Docker checks the state of a container through the
+// Docker Engine API
+type Docker struct {
+	Service
+}
+
+// GetService return the Service pointer
+func (d *Docker) GetService() *Service {
+	return &d.Service
+}
+
+// dockerContainer is the subset of the container inspect
+// response needed to determine its health
+type dockerContainer struct {
+	State struct {
+		Running bool   `json:"Running"`
+		Status  string `json:"Status"`
+		Health  *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// Status inspects the container and checks that it is running and,
+// when it declares a HEALTHCHECK, that it is not unhealthy
+func (d *Docker) Status() error {
+	client, base, err := dockerClient(d.URL)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Get(base + "/containers/" + url.PathEscape(d.Container) + "/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !validStatus(resp.StatusCode) {
+		return ErrServiceUnavailable
+	}
+
+	var c dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		return err
+	}
+
+	if !c.State.Running {
+		return ErrContainerNotRunning
+	}
+	if c.State.Health != nil && c.State.Health.Status == "unhealthy" {
+		return ErrContainerUnhealthy
+	}
+
+	return nil
+}
+
+// dockerClient returns an http.Client and base URL able to reach the
+// Docker API at host, which may be a unix://, tcp:// or http(s):// address
+func dockerClient(host string) (*http.Client, string, error) {
+	if host == "" {
+		host = defaultDockerHost
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		return &http.Client{Transport: transport}, "http://docker", nil
+	case "tcp":
+		return http.DefaultClient, "http://" + u.Host, nil
+	case "http", "https":
+		return http.DefaultClient, strings.TrimSuffix(host, "/"), nil
+	}
+
+	return nil, "", ErrInvalidDockerHost
+}
+
+// DockerFactory implements the PingerFactory
+// interface
+type DockerFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *DockerFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "docker" {
+		return nil, ErrInvalidCreate
+	}
+
+	return &Docker{
+		Service: s,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package status
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func dockerServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/web/json" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+}
+
+func TestDockerStatus(t *testing.T) {
+	tt := []struct {
+		name     string
+		body     string
+		expected error
+	}{
+		{name: "running", body: `{"State":{"Running":true,"Status":"running"}}`, expected: nil},
+		{name: "healthy", body: `{"State":{"Running":true,"Health":{"Status":"healthy"}}}`, expected: nil},
+		{name: "starting", body: `{"State":{"Running":true,"Health":{"Status":"starting"}}}`, expected: nil},
+		{name: "stopped", body: `{"State":{"Running":false,"Status":"exited"}}`, expected: ErrContainerNotRunning},
+		{name: "unhealthy", body: `{"State":{"Running":true,"Health":{"Status":"unhealthy"}}}`, expected: ErrContainerUnhealthy},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := dockerServer(tc.body)
+			defer ts.Close()
+
+			d := Docker{Service: Service{URL: ts.URL, Container: "web"}}
+			if actual := d.Status(); actual != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDockerMissingContainer(t *testing.T) {
+	ts := dockerServer(`{}`)
+	defer ts.Close()
+
+	d := Docker{Service: Service{URL: ts.URL, Container: "db"}}
+	if actual := d.Status(); actual != ErrServiceUnavailable {
+		t.Errorf("expected %v got %v", ErrServiceUnavailable, actual)
+	}
+}
+
+func TestDockerUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"State":{"Running":true}}`)
+	}))
+	ts.Listener.Close()
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	d := Docker{Service: Service{URL: "unix://" + socket, Container: "web"}}
+	if err := d.Status(); err != nil {
+		t.Errorf("expected nil got %v", err)
+	}
+}
+
+func TestDockerInvalidHost(t *testing.T) {
+	d := Docker{Service: Service{URL: "ftp://docker", Container: "web"}}
+	if actual := d.Status(); actual != ErrInvalidDockerHost {
+		t.Errorf("expected %v got %v", ErrInvalidDockerHost, actual)
+	}
+}
+
+func TestDockerFactoryCreateErr(t *testing.T) {
+	s := Service{Type: "ping", URL: "test", Container: "web"}
+	f := DockerFactory{}
+	_, err := f.Create(s)
+	if err != ErrInvalidCreate {
+		t.Fatalf("failed create with error: %v", err)
+	}
+}
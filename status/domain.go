@@ -0,0 +1,135 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Errors returned by the domain check
+var (
+	ErrDomainExpired  = errors.New("commands: domain registration expired")
+	ErrDomainNotFound = errors.New("commands: domain not found in rdap")
+	ErrNoExpiry       = errors.New("commands: rdap response has no expiration date")
+)
+
+const (
+	// defaultRDAPServer redirects to the RDAP server of the
+	// registry responsible for a domain
+	defaultRDAPServer = "https://rdap.org"
+	// defaultWarnDays is how close to expiry a domain is
+	// reported degraded
+	defaultWarnDays = 30
+)
+
+// rdapDomain is the part of an RDAP domain response
+// used by domain checks
+type rdapDomain struct {
+	Events []struct {
+		Action string    `json:"eventAction"`
+		Date   time.Time `json:"eventDate"`
+	} `json:"events"`
+}
+
+// Domain checks when the registration of a domain expires,
+// a common silent cause of outages
+type Domain struct {
+	Service
+	client *http.Client
+	now    func() time.Time
+}
+
+// GetService return the Service pointer
+func (d *Domain) GetService() *Service {
+	return &d.Service
+}
+
+// Status looks up the expiration date of the domain. It is down once
+// expired and degraded within WarnDays of expiring.
+func (d *Domain) Status() error {
+	resp, err := clientOrDefault(d.client).Get(d.rdapServer() + "/domain/" + url.PathEscape(d.domain()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrDomainNotFound
+	case !validStatus(resp.StatusCode):
+		return ErrServiceUnavailable
+	}
+
+	var rd rdapDomain
+	if err := json.NewDecoder(resp.Body).Decode(&rd); err != nil {
+		return err
+	}
+
+	for _, e := range rd.Events {
+		if e.Action != "expiration" {
+			continue
+		}
+		now := time.Now()
+		if d.now != nil {
+			now = d.now()
+		}
+		left := e.Date.Sub(now)
+		switch {
+		case left <= 0:
+			return ErrDomainExpired
+		case left < time.Duration(d.warnDays())*24*time.Hour:
+			return Degraded(fmt.Sprintf("domain expires in %d days on %s", int(left.Hours()/24), e.Date.Format("2006-01-02")))
+		}
+		return nil
+	}
+	return ErrNoExpiry
+}
+
+// domain returns the configured domain or the host of the URL
+func (d *Domain) domain() string {
+	if d.Domain != "" {
+		return d.Domain
+	}
+	if u, err := url.Parse(d.URL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return d.URL
+}
+
+func (d *Domain) rdapServer() string {
+	if d.RDAPServer != "" {
+		return strings.TrimSuffix(d.RDAPServer, "/")
+	}
+	return defaultRDAPServer
+}
+
+func (d *Domain) warnDays() int {
+	if d.WarnDays > 0 {
+		return d.WarnDays
+	}
+	return defaultWarnDays
+}
+
+// DomainFactory implements the PingerFactory
+// interface
+type DomainFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *DomainFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "domain" {
+		return nil, ErrInvalidCreate
+	}
+	client, err := newHTTPClient(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Domain{
+		Service: s,
+		client:  client,
+	}, nil
+}
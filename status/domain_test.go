@@ -0,0 +1,63 @@
+package status
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDomainStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/domain/example.com":
+			io.WriteString(w, `{"events": [{"eventAction": "registration", "eventDate": "1995-08-14T04:00:00Z"}, {"eventAction": "expiration", "eventDate": "2030-08-13T04:00:00Z"}]}`)
+		case "/domain/noexpiry.com":
+			io.WriteString(w, `{"events": []}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	at := func(date string) func() time.Time {
+		return func() time.Time {
+			t, _ := time.Parse("2006-01-02", date)
+			return t
+		}
+	}
+
+	tt := []struct {
+		name     string
+		service  Service
+		now      string
+		degraded bool
+		expected error
+	}{
+		{name: "valid", service: Service{Domain: "example.com"}, now: "2030-01-01"},
+		{name: "from url", service: Service{URL: "https://example.com/login"}, now: "2030-01-01"},
+		{name: "expiring", service: Service{Domain: "example.com"}, now: "2030-08-01", degraded: true},
+		{name: "custom warn days", service: Service{Domain: "example.com", WarnDays: 90}, now: "2030-06-01", degraded: true},
+		{name: "expired", service: Service{Domain: "example.com"}, now: "2030-09-01", expected: ErrDomainExpired},
+		{name: "no expiry", service: Service{Domain: "noexpiry.com"}, now: "2030-01-01", expected: ErrNoExpiry},
+		{name: "not found", service: Service{Domain: "missing.com"}, now: "2030-01-01", expected: ErrDomainNotFound},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.service.RDAPServer = ts.URL + "/"
+			d := Domain{Service: tc.service, now: at(tc.now)}
+			err := d.Status()
+			if tc.degraded {
+				if !IsDegraded(err) {
+					t.Errorf("expected degraded got %v", err)
+				}
+				return
+			}
+			if err != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, err)
+			}
+		})
+	}
+}
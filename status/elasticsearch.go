@@ -0,0 +1,101 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Errors returned by the elasticsearch check
+var (
+	ErrClusterRed    = errors.New("commands: cluster health red")
+	ErrUnknownHealth = errors.New("commands: unknown cluster health")
+)
+
+// clusterHealth is the part of the _cluster/health response
+// reported by elasticsearch checks
+type clusterHealth struct {
+	ClusterName        string `json:"cluster_name"`
+	Status             string `json:"status"`
+	NumberOfNodes      int    `json:"number_of_nodes"`
+	ActiveShards       int    `json:"active_shards"`
+	RelocatingShards   int    `json:"relocating_shards"`
+	InitializingShards int    `json:"initializing_shards"`
+	UnassignedShards   int    `json:"unassigned_shards"`
+}
+
+func (h clusterHealth) String() string {
+	return fmt.Sprintf("%d nodes, shards: %d active, %d relocating, %d initializing, %d unassigned",
+		h.NumberOfNodes, h.ActiveShards, h.RelocatingShards, h.InitializingShards, h.UnassignedShards)
+}
+
+// Elasticsearch checks the health of an Elasticsearch or
+// OpenSearch cluster
+type Elasticsearch struct {
+	Service
+	timingRecorder
+	client *http.Client
+}
+
+// GetService return the Service pointer
+func (e *Elasticsearch) GetService() *Service {
+	return &e.Service
+}
+
+// Status maps the cluster health to the service state: green is
+// up, yellow degraded and red down
+func (e *Elasticsearch) Status() error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(e.URL, "/")+"/_cluster/health", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := clientOrDefault(e.client).Do(e.trace(req))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// a red cluster answers 200, 503 means the node could not
+	// answer at all
+	if !validStatus(resp.StatusCode) {
+		return ErrServiceUnavailable
+	}
+
+	var health clusterHealth
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return err
+	}
+
+	switch health.Status {
+	case "green":
+		return nil
+	case "yellow":
+		return Degraded("cluster yellow, " + health.String())
+	case "red":
+		return fmt.Errorf("%w, %s", ErrClusterRed, health)
+	}
+	return ErrUnknownHealth
+}
+
+// ElasticsearchFactory implements the PingerFactory
+// interface
+type ElasticsearchFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *ElasticsearchFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "elasticsearch" {
+		return nil, ErrInvalidCreate
+	}
+	client, err := newHTTPClient(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Elasticsearch{
+		Service: s,
+		client:  client,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package status
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestElasticsearchStatus(t *testing.T) {
+	tt := []struct {
+		name     string
+		body     string
+		code     int
+		degraded bool
+		expected error
+	}{
+		{name: "green", body: `{"status": "green"}`, code: http.StatusOK},
+		{name: "yellow", body: `{"status": "yellow", "unassigned_shards": 5}`, code: http.StatusOK, degraded: true},
+		{name: "red", body: `{"status": "red", "unassigned_shards": 5}`, code: http.StatusOK, expected: ErrClusterRed},
+		{name: "unknown", body: `{"status": "blue"}`, code: http.StatusOK, expected: ErrUnknownHealth},
+		{name: "unavailable", body: `{}`, code: http.StatusServiceUnavailable, expected: ErrServiceUnavailable},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/_cluster/health" {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(tc.code)
+				io.WriteString(w, tc.body)
+			}))
+			defer ts.Close()
+
+			e := Elasticsearch{Service: Service{URL: ts.URL + "/"}}
+			err := e.Status()
+			switch {
+			case tc.degraded:
+				if !IsDegraded(err) || !strings.Contains(err.Error(), "5 unassigned") {
+					t.Errorf("expected degraded with shard counts got %v", err)
+				}
+			case !errors.Is(err, tc.expected):
+				t.Errorf("expected %v got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestElasticsearchFactoryCreateErr(t *testing.T) {
+	f := ElasticsearchFactory{}
+	if _, err := f.Create(Service{Type: "ping"}); err != ErrInvalidCreate {
+		t.Errorf("expected %v got %v", ErrInvalidCreate, err)
+	}
+}
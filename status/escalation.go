@@ -0,0 +1,277 @@
+package status
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidEscalation is returned for an escalation without
+// a positive delay or without notifiers
+var ErrInvalidEscalation = errors.New("notify: escalation needs a positive after and notifiers")
+
+// Escalation is a tier of notifiers told of an incident which is
+// still ongoing After it started
+type Escalation struct {
+	After     time.Duration
+	Notifiers []Notifier
+	// NotifierIDs are the identities of the Notifiers, see NotifierID
+	NotifierIDs []string
+}
+
+// EscalationConfig holds the configuration of an escalation tier
+type EscalationConfig struct {
+	After     string           `json:"after"`
+	Notifiers []NotifierConfig `json:"notifiers"`
+}
+
+// NewEscalation returns the Escalation described by the config
+func NewEscalation(c EscalationConfig) (Escalation, error) {
+	after, err := time.ParseDuration(c.After)
+	if err != nil || after <= 0 || len(c.Notifiers) == 0 {
+		return Escalation{}, ErrInvalidEscalation
+	}
+
+	e := Escalation{After: after}
+	for _, nc := range c.Notifiers {
+		n, err := NewNotifier(nc)
+		if err != nil {
+			return Escalation{}, fmt.Errorf("%s notifier: %v", nc.Type, err)
+		}
+		e.Notifiers = append(e.Notifiers, n)
+		e.NotifierIDs = append(e.NotifierIDs, NotifierID(nc))
+	}
+	return e, nil
+}
+
+// newState returns the state of a service seen for the first time,
+// picking up its last alert and ongoing incident after a restart
+func (nm *NotificationManager) newState(s *Service) *alertState {
+	st := &alertState{state: StateUp}
+	if nm.Storage == nil {
+		return st
+	}
+	ctx, cancel := storageContext()
+	defer cancel()
+	var err error
+	if st.lastAlert, err = nm.Storage.LastAlert(ctx, s.ID()); err != nil {
+		log.Printf("load last alert of %s: %v", s.ID(), err)
+	}
+	inc, ok, err := nm.Storage.OngoingIncident(ctx, s.ID())
+	if err != nil {
+		log.Printf("load incident of %s: %v", s.ID(), err)
+	}
+	if ok {
+		st.state = inc.State
+		st.downSince = inc.Start
+		st.severity = inc.Severity
+		st.incident = inc.ID
+		st.cause = inc.Message
+		if !inc.Acked() {
+			nm.armEscalations(st, *s, inc)
+			nm.armReminders(st, *s)
+		}
+	}
+	return st
+}
+
+// recordIncident opens, updates or resolves the incident of a service
+// which changed state. On recovery it returns the resolved incident.
+func (nm *NotificationManager) recordIncident(st *alertState, s *Service, message string, started bool, now time.Time) Incident {
+	ctx, cancel := storageContext()
+	defer cancel()
+	if st.state == StateUp {
+		st.stopEscalations()
+		st.incident = ""
+
+		inc, _, err := nm.Storage.ResolveIncident(ctx, s.ID(), now)
+		if err != nil {
+			log.Printf("resolve incident of %s: %v", s.ID(), err)
+		}
+		return inc
+	}
+
+	inc, err := nm.Storage.StartIncident(ctx, s.ID(), st.state, stateSeverity(s, st.state), message, now)
+	if err != nil {
+		log.Printf("record incident of %s: %v", s.ID(), err)
+	}
+	st.incident = inc.ID
+	if started {
+		nm.armEscalations(st, *s, inc)
+	}
+	return Incident{}
+}
+
+// stopEscalations cancels the pending escalations of the service
+func (st *alertState) stopEscalations() {
+	for _, t := range st.timers {
+		t.Stop()
+	}
+	st.timers = nil
+}
+
+// armEscalations starts a timer for each escalation tier not yet
+// told of the incident
+func (nm *NotificationManager) armEscalations(st *alertState, s Service, inc Incident) {
+	st.service = s
+	for tier := inc.Escalations; tier < len(nm.Escalations); tier++ {
+		tier := tier
+		wait := time.Until(inc.Start.Add(nm.Escalations[tier].After))
+		st.timers = append(st.timers, time.AfterFunc(wait, func() {
+			nm.escalate(s, inc.ID, tier)
+		}))
+	}
+}
+
+// escalate sends the alert of an incident to an escalation tier,
+// unless the incident was resolved, acknowledged or already escalated
+// past it
+func (nm *NotificationManager) escalate(s Service, id string, tier int) {
+	ctx, cancel := storageContext()
+	defer cancel()
+	nm.mu.Lock()
+	inc, ok, err := nm.Storage.Incident(ctx, id)
+	if err != nil {
+		log.Printf("load incident of %s: %v", s.ID(), err)
+	}
+	if !ok || !inc.Ongoing() || inc.Acked() || inc.Escalations > tier {
+		nm.mu.Unlock()
+		return
+	}
+	if err := nm.Storage.SetEscalations(ctx, id, tier+1); err != nil {
+		log.Printf("record escalation of %s: %v", s.ID(), err)
+	}
+	nm.mu.Unlock()
+
+	now := time.Now()
+	a := Alert{
+		Type:       AlertTypeDown,
+		Severity:   inc.Severity,
+		Service:    s,
+		Message:    fmt.Sprintf("unresolved for %v: %s", now.Sub(inc.Start).Round(time.Second), inc.Message),
+		Time:       now,
+		Escalation: tier + 1,
+		Incident:   id,
+		AckURL:     nm.ackURL(id),
+	}
+	if inc.State == StateDegraded {
+		a.Type = AlertTypeDegraded
+	}
+	nm.dispatch(a, nm.tier(tier))
+}
+
+// notifier returns the notifier at index i, counting on from the
+// notifiers of the manager into those of its escalations and then the
+// managed notifiers, nil when there is none
+func (nm *NotificationManager) notifier(i int) Notifier {
+	nm.notifiersMu.RLock()
+	defer nm.notifiersMu.RUnlock()
+	return nm.notifierAt(i)
+}
+
+// notifierAt is notifier for a caller holding notifiersMu
+func (nm *NotificationManager) notifierAt(i int) Notifier {
+	if i < 0 {
+		return nil
+	}
+	if i < len(nm.Notifiers) {
+		return nm.Notifiers[i]
+	}
+	i -= len(nm.Notifiers)
+	for _, e := range nm.Escalations {
+		if i < len(e.Notifiers) {
+			return e.Notifiers[i]
+		}
+		i -= len(e.Notifiers)
+	}
+	return nm.managed[i+1]
+}
+
+// configuredIDs returns the identities of the notifiers of the manager
+// and of its escalations, by index: the NotifierID of their config, or
+// their index when they have none. A notifier configured twice has a
+// suffix telling the two apart. The caller holds notifiersMu.
+func (nm *NotificationManager) configuredIDs() []string {
+	var ids []string
+	seen := make(map[string]int)
+	add := func(id string) {
+		seen[id]++
+		if n := seen[id]; n > 1 {
+			id += "-" + strconv.Itoa(n)
+		}
+		ids = append(ids, id)
+	}
+	for i := range nm.Notifiers {
+		if i < len(nm.NotifierIDs) && nm.NotifierIDs[i] != "" {
+			add(nm.NotifierIDs[i])
+		} else {
+			add("notifier-" + strconv.Itoa(i))
+		}
+	}
+	for tier, e := range nm.Escalations {
+		for i := range e.Notifiers {
+			if i < len(e.NotifierIDs) && e.NotifierIDs[i] != "" {
+				add(e.NotifierIDs[i])
+			} else {
+				add(fmt.Sprintf("escalation-%d-%d", tier, i))
+			}
+		}
+	}
+	return ids
+}
+
+// lookup returns the notifier with the identity id and its index,
+// nil when there is none any longer
+func (nm *NotificationManager) lookup(id string) (Notifier, int) {
+	nm.notifiersMu.RLock()
+	defer nm.notifiersMu.RUnlock()
+	ids := nm.configuredIDs()
+	for i, known := range ids {
+		if known == id {
+			return nm.notifierAt(i), i
+		}
+	}
+	if m, err := strconv.Atoi(strings.TrimPrefix(id, "managed-")); err == nil && strings.HasPrefix(id, "managed-") {
+		if n := nm.managed[m]; n != nil {
+			return n, len(ids) + m - 1
+		}
+	}
+	return nil, -1
+}
+
+// targets returns the identities of the notifiers of the manager, of
+// the first escalated escalation tiers and of the managed notifiers
+func (nm *NotificationManager) targets(escalated int) []string {
+	nm.notifiersMu.RLock()
+	defer nm.notifiersMu.RUnlock()
+	ids := nm.configuredIDs()
+	targets := append([]string(nil), ids[:len(nm.Notifiers)]...)
+	for tier := 0; tier < escalated && tier < len(nm.Escalations); tier++ {
+		targets = append(targets, nm.tierTargets(ids, tier)...)
+	}
+	return append(targets, nm.managedTargets()...)
+}
+
+// tier returns the identities of the notifiers of an escalation tier,
+// none once a reload removed the tier
+func (nm *NotificationManager) tier(tier int) []string {
+	nm.notifiersMu.RLock()
+	defer nm.notifiersMu.RUnlock()
+	return nm.tierTargets(nm.configuredIDs(), tier)
+}
+
+// tierTargets is tier for a caller holding notifiersMu, given the
+// configuredIDs
+func (nm *NotificationManager) tierTargets(ids []string, tier int) []string {
+	if tier >= len(nm.Escalations) {
+		return nil
+	}
+	first := len(nm.Notifiers)
+	for _, e := range nm.Escalations[:tier] {
+		first += len(e.Notifiers)
+	}
+	return append([]string(nil), ids[first:first+len(nm.Escalations[tier].Notifiers)]...)
+}
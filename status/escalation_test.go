@@ -0,0 +1,106 @@
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// chanNotifier sends every alert on a channel
+type chanNotifier chan Alert
+
+func (c chanNotifier) Notify(a Alert) error {
+	c <- a
+	return nil
+}
+
+func expectAlert(t *testing.T, c chanNotifier, typ AlertType, escalation int) {
+	t.Helper()
+	select {
+	case a := <-c:
+		if a.Type != typ || a.Escalation != escalation {
+			t.Errorf("expected %s alert at escalation %d got %s at %d", typ, escalation, a.Type, a.Escalation)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected %s alert", typ)
+	}
+}
+
+func expectNoAlert(t *testing.T, c chanNotifier, wait time.Duration) {
+	t.Helper()
+	select {
+	case a := <-c:
+		t.Errorf("expected no alert got %+v", a)
+	case <-time.After(wait):
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	ctx := context.Background()
+	primary, first, second := make(chanNotifier, 10), make(chanNotifier, 10), make(chanNotifier, 10)
+	nm := NewNotificationManager([]Notifier{primary}, 0)
+	nm.Storage, _ = OpenStorage("")
+	nm.Escalations = []Escalation{
+		{After: 20 * time.Millisecond, Notifiers: []Notifier{first}},
+		{After: 200 * time.Millisecond, Notifiers: []Notifier{second}},
+	}
+	s := &Service{Name: "api"}
+
+	nm.NotifyState(s, StateDown, "timeout")
+	expectAlert(t, primary, AlertTypeDown, 0)
+	expectAlert(t, first, AlertTypeDown, 1)
+
+	nm.NotifyState(s, StateUp, "")
+	expectAlert(t, primary, AlertTypeRecovery, 0)
+	expectAlert(t, first, AlertTypeRecovery, 0)
+
+	// the second tier is cancelled by the recovery
+	expectNoAlert(t, second, 300*time.Millisecond)
+
+	incidents, _ := nm.Storage.Incidents(ctx)
+	if len(incidents) != 1 || incidents[0].Ongoing() || incidents[0].Escalations != 1 {
+		t.Errorf("expected a resolved incident escalated once got %+v", incidents)
+	}
+}
+
+func TestEscalationAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", time.Now().Add(-time.Hour))
+
+	primary, tier := make(chanNotifier, 10), make(chanNotifier, 10)
+	nm := NewNotificationManager([]Notifier{primary}, 0)
+	nm.Storage = db
+	nm.Escalations = []Escalation{{After: 30 * time.Minute, Notifiers: []Notifier{tier}}}
+	s := &Service{Name: "api"}
+
+	// the ongoing incident is picked up without alerting again
+	// and its overdue escalation fires
+	nm.NotifyState(s, StateDown, "timeout")
+	expectAlert(t, tier, AlertTypeDown, 1)
+	expectNoAlert(t, primary, 10*time.Millisecond)
+
+	nm.NotifyState(s, StateUp, "")
+	expectAlert(t, primary, AlertTypeRecovery, 0)
+	expectAlert(t, tier, AlertTypeRecovery, 0)
+}
+
+func TestNewEscalation(t *testing.T) {
+	cases := []struct {
+		name string
+		c    EscalationConfig
+		err  bool
+	}{
+		{"valid", EscalationConfig{After: "15m", Notifiers: []NotifierConfig{{Type: "log"}}}, false},
+		{"no notifiers", EscalationConfig{After: "15m"}, true},
+		{"bad after", EscalationConfig{After: "soon", Notifiers: []NotifierConfig{{Type: "log"}}}, true},
+		{"bad notifier", EscalationConfig{After: "15m", Notifiers: []NotifierConfig{{Type: "fax"}}}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewEscalation(tc.c); (err != nil) != tc.err {
+				t.Errorf("expected error %v got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package status
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of change to the storage an Event reports
+type EventType string
+
+// Types of the storage events
+const (
+	EventIncidentStarted  EventType = "incident_started"
+	EventIncidentUpdated  EventType = "incident_updated"
+	EventIncidentResolved EventType = "incident_resolved"
+	EventStateChanged     EventType = "state_changed"
+	// EventMaintenanceScheduled is published when scheduled
+	// maintenance is saved
+	EventMaintenanceScheduled EventType = "maintenance_scheduled"
+	// EventPageUpdated is published once the status page shows
+	// the changes written since it was last updated
+	EventPageUpdated EventType = "page_updated"
+)
+
+// Event is a change written to the storage, or to the partition
+// Tenant of it. Incident and maintenance events carry the incident or
+// maintenance as written, state changes the service and the state it
+// changed From and To, From being empty for the first recorded state.
+type Event struct {
+	Type        EventType             `json:"type"`
+	Time        time.Time             `json:"time"`
+	Tenant      string                `json:"tenant,omitempty"`
+	Service     string                `json:"service,omitempty"`
+	Incident    *Incident             `json:"incident,omitempty"`
+	Maintenance *ScheduledMaintenance `json:"maintenance,omitempty"`
+	From        State                 `json:"from,omitempty"`
+	To          State                 `json:"to,omitempty"`
+}
+
+// EventBus hands the storage events to its subscribers, so they can
+// react to changes rather than poll the storage
+type EventBus struct {
+	mu   sync.RWMutex
+	subs map[chan Event]struct{}
+}
+
+// NewEventBus returns an EventBus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel receiving the events published from now
+// on, buffering up to buffer of them, and a func which unsubscribes
+// and closes it. A subscriber which falls behind misses events rather
+// than blocking the storage.
+func (b *EventBus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close unsubscribes every subscriber, closing their channels, such
+// as to end the live updates when the server shuts down
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Publish hands e to every subscriber with room for it
+func (b *EventBus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// eventStorage is a StorageBackend which publishes the changes
+// written to the backend it wraps
+type eventStorage struct {
+	StorageBackend
+	bus    *EventBus
+	tenant string
+
+	mu sync.Mutex
+	// states are the last recorded states of the services
+	states map[string]State
+}
+
+// WithEvents returns db publishing the incidents it starts, updates
+// and resolves, the maintenance it schedules and the changes of state
+// it records on bus
+func WithEvents(db StorageBackend, bus *EventBus) StorageBackend {
+	return &eventStorage{StorageBackend: db, bus: bus, states: make(map[string]State)}
+}
+
+// incident publishes an incident event
+func (db *eventStorage) incident(typ EventType, inc Incident, t time.Time) {
+	db.bus.Publish(Event{Type: typ, Time: t, Tenant: db.tenant, Service: inc.Service, Incident: &inc})
+}
+
+func (db *eventStorage) Tenant(name string) StorageBackend {
+	return &eventStorage{StorageBackend: db.StorageBackend.Tenant(name), bus: db.bus, tenant: name, states: make(map[string]State)}
+}
+
+func (db *eventStorage) StartIncident(ctx context.Context, service string, state State, severity Severity, message string, t time.Time) (Incident, error) {
+	inc, err := db.StorageBackend.StartIncident(ctx, service, state, severity, message, t)
+	if err != nil {
+		return inc, err
+	}
+	// an ongoing incident keeps the time it started
+	if inc.Start.Equal(t) {
+		db.incident(EventIncidentStarted, inc, t)
+	} else {
+		db.incident(EventIncidentUpdated, inc, t)
+	}
+	return inc, nil
+}
+
+func (db *eventStorage) ResolveIncident(ctx context.Context, service string, t time.Time) (Incident, bool, error) {
+	inc, ok, err := db.StorageBackend.ResolveIncident(ctx, service, t)
+	if err == nil && ok {
+		db.incident(EventIncidentResolved, inc, t)
+	}
+	return inc, ok, err
+}
+
+func (db *eventStorage) Acknowledge(ctx context.Context, id, by string, t time.Time) (Incident, error) {
+	inc, err := db.StorageBackend.Acknowledge(ctx, id, by, t)
+	if err == nil {
+		db.incident(EventIncidentUpdated, inc, t)
+	}
+	return inc, err
+}
+
+func (db *eventStorage) OpenIncident(ctx context.Context, inc Incident) (Incident, error) {
+	inc, err := db.StorageBackend.OpenIncident(ctx, inc)
+	if err == nil {
+		db.incident(EventIncidentStarted, inc, inc.Start)
+	}
+	return inc, err
+}
+
+func (db *eventStorage) CloseIncident(ctx context.Context, id string, t time.Time) (Incident, error) {
+	inc, err := db.StorageBackend.CloseIncident(ctx, id, t)
+	if err == nil {
+		db.incident(EventIncidentResolved, inc, t)
+	}
+	return inc, err
+}
+
+func (db *eventStorage) AddIncidentUpdate(ctx context.Context, id string, u IncidentUpdate) (Incident, error) {
+	inc, err := db.StorageBackend.AddIncidentUpdate(ctx, id, u)
+	if err == nil {
+		db.incident(EventIncidentUpdated, inc, u.Time)
+	}
+	return inc, err
+}
+
+func (db *eventStorage) SetPostmortem(ctx context.Context, id, postmortem string) (Incident, error) {
+	inc, err := db.StorageBackend.SetPostmortem(ctx, id, postmortem)
+	if err == nil {
+		db.incident(EventIncidentUpdated, inc, time.Now())
+	}
+	return inc, err
+}
+
+func (db *eventStorage) SaveMaintenance(ctx context.Context, sm ScheduledMaintenance) (ScheduledMaintenance, error) {
+	sm, err := db.StorageBackend.SaveMaintenance(ctx, sm)
+	if err == nil {
+		db.bus.Publish(Event{Type: EventMaintenanceScheduled, Time: time.Now(), Tenant: db.tenant, Maintenance: &sm})
+	}
+	return sm, err
+}
+
+func (db *eventStorage) RecordStatus(ctx context.Context, records ...StatusRecord) error {
+	if err := db.StorageBackend.RecordStatus(ctx, records...); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	var events []Event
+	for _, r := range records {
+		if from, ok := db.states[r.Service]; !ok || from != r.State {
+			events = append(events, Event{Type: EventStateChanged, Time: r.Time, Tenant: db.tenant, Service: r.Service, From: from, To: r.State})
+		}
+		db.states[r.Service] = r.State
+	}
+	db.mu.Unlock()
+	for _, e := range events {
+		db.bus.Publish(e)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithEvents(t *testing.T) {
+	ctx := context.Background()
+	mem, _ := OpenStorage("")
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe(10)
+	db := WithEvents(mem, bus)
+
+	now := time.Now()
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: now})
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: now.Add(time.Minute)})
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateDown, Time: now.Add(2 * time.Minute)})
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", now.Add(2*time.Minute))
+	db.StartIncident(ctx, "api", StateDegraded, SeverityWarning, "slow", now.Add(3*time.Minute))
+	db.ResolveIncident(ctx, "api", now.Add(4*time.Minute))
+	unsubscribe()
+
+	expected := []struct {
+		typ      EventType
+		from, to State
+	}{
+		{EventStateChanged, "", StateUp},
+		{EventStateChanged, StateUp, StateDown},
+		{EventIncidentStarted, "", ""},
+		{EventIncidentUpdated, "", ""},
+		{EventIncidentResolved, "", ""},
+	}
+	var received []Event
+	for e := range events {
+		received = append(received, e)
+	}
+	if len(received) != len(expected) {
+		t.Fatalf("expected %d events got %+v", len(expected), received)
+	}
+	for i, e := range received {
+		if e.Type != expected[i].typ || e.From != expected[i].from || e.To != expected[i].to || e.Service != "api" {
+			t.Errorf("expected %+v got %+v", expected[i], e)
+		}
+	}
+	if inc := received[4].Incident; inc == nil || inc.Ongoing() {
+		t.Errorf("expected the resolved incident got %+v", inc)
+	}
+
+	// a subscriber which falls behind does not block the storage
+	_, unsubscribe = bus.Subscribe(0)
+	defer unsubscribe()
+	if _, err := db.OpenIncident(ctx, Incident{Title: "x", Start: now}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithEventsTenant(t *testing.T) {
+	mem, _ := OpenStorage("")
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe(1)
+	defer unsubscribe()
+
+	WithEvents(mem, bus).Tenant("payments").StartIncident(context.Background(), "api", StateDown, SeverityCritical, "", time.Now())
+	if e := <-events; e.Type != EventIncidentStarted || e.Tenant != "payments" {
+		t.Errorf("expected the incident of payments got %+v", e)
+	}
+}
+
+func TestWithEventsMaintenance(t *testing.T) {
+	mem, _ := OpenStorage("")
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe(1)
+	defer unsubscribe()
+	db := WithEvents(mem, bus)
+	now := time.Now()
+	sm, err := db.SaveMaintenance(context.Background(), ScheduledMaintenance{Start: now, End: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := <-events; e.Type != EventMaintenanceScheduled || e.Maintenance == nil || e.Maintenance.ID != sm.ID {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
+
+func TestEventBusClose(t *testing.T) {
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe(1)
+	bus.Close()
+	if _, ok := <-events; ok {
+		t.Error("expected the subscription to be closed")
+	}
+	// unsubscribing after the bus closed is harmless
+	unsubscribe()
+	bus.Publish(Event{Type: EventPageUpdated})
+}
@@ -0,0 +1,172 @@
+package status
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned when exporting
+var (
+	ErrUnknownExport = errors.New("export: kind must be history, alerts or incidents")
+	ErrExportFormat  = errors.New("export: format must be csv or ndjson")
+)
+
+// Export formats
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+// exportHeaders are the CSV columns of each kind of export
+var exportHeaders = map[string][]string{
+	"history":   {"service", "state", "time", "response_time_ms"},
+	"alerts":    {"id", "time", "notifier", "type", "service", "incident", "ok", "error", "code", "attempts", "latency_ms"},
+	"incidents": {"id", "service", "title", "state", "severity", "start", "end", "message", "acked_by", "acked_at", "postmortem"},
+}
+
+// Export writes the status history, alert deliveries or incidents
+// (kind "history", "alerts" or "incidents") between start and end to
+// w as CSV, with a header, or as NDJSON, one record per line
+func Export(ctx context.Context, w io.Writer, db StorageBackend, kind, format string, start, end time.Time) error {
+	if format != ExportCSV && format != ExportNDJSON {
+		return ErrExportFormat
+	}
+	header, ok := exportHeaders[kind]
+	if !ok {
+		return ErrUnknownExport
+	}
+
+	var records []interface{}
+	var rows [][]string
+	switch kind {
+	case "history":
+		history, err := db.StatusHistory(ctx, "", start, end)
+		if err != nil {
+			return err
+		}
+		for _, r := range history {
+			records = append(records, r)
+			rows = append(rows, []string{r.Service, string(r.State), formatTime(r.Time), millis(r.ResponseTime)})
+		}
+	case "alerts":
+		deliveries, err := db.Deliveries(ctx)
+		if err != nil {
+			return err
+		}
+		for _, d := range deliveries {
+			if d.Time.Before(start) || !d.Time.Before(end) {
+				continue
+			}
+			records = append(records, d)
+			rows = append(rows, []string{
+				d.ID, formatTime(d.Time), strconv.Itoa(d.Notifier), string(d.Type), d.Service, d.Incident,
+				strconv.FormatBool(d.OK), d.Error, strconv.Itoa(d.Code), strconv.Itoa(d.Attempts), millis(d.Latency),
+			})
+		}
+	case "incidents":
+		incidents, err := db.IncidentsBetween(ctx, start, end)
+		if err != nil {
+			return err
+		}
+		for _, inc := range incidents {
+			records = append(records, inc)
+			rows = append(rows, []string{
+				inc.ID, inc.Service, inc.Title, string(inc.State), string(inc.Severity), formatTime(inc.Start),
+				formatTime(inc.End), inc.Message, inc.AckedBy, formatTime(inc.AckedAt), inc.Postmortem,
+			})
+		}
+	}
+
+	if format == ExportNDJSON {
+		enc := json.NewEncoder(w)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, row := range rows {
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatTime formats t for an export, empty when zero
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// millis formats d in milliseconds
+func millis(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
+
+// ExportHandler is a HandlerFunc which exports the status history,
+// alert deliveries or incidents in /api/export/{kind}. The format
+// query parameter is csv (default) or ndjson, and start and end,
+// RFC 3339 times, limit the range, which is everything until now by
+// default.
+func ExportHandler(db StorageBackend) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		kind := strings.TrimPrefix(r.URL.Path, "/api/export/")
+		if _, ok := exportHeaders[kind]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		q := r.URL.Query()
+		format := q.Get("format")
+		if format == "" {
+			format = ExportCSV
+		}
+		start, end := time.Time{}, time.Now()
+		for _, p := range []struct {
+			name string
+			t    *time.Time
+		}{{"start", &start}, {"end", &end}} {
+			if v := q.Get(p.name); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					http.Error(w, "invalid "+p.name, http.StatusBadRequest)
+					return
+				}
+				*p.t = t
+			}
+		}
+		if format != ExportCSV && format != ExportNDJSON {
+			http.Error(w, ErrExportFormat.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := requestContext(r)
+		defer cancel()
+		if format == ExportCSV {
+			w.Header().Set("Content-Type", "text/csv")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="`+kind+"."+format+`"`)
+		if err := Export(ctx, w, db, kind, format, start, end); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	}
+}
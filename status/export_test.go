@@ -0,0 +1,81 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	db.RecordStatus(ctx,
+		StatusRecord{Service: "api", State: StateUp, Time: start, ResponseTime: 120 * time.Millisecond},
+		StatusRecord{Service: "api", State: StateDown, Time: start.Add(time.Hour)},
+	)
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout, retrying", start.Add(time.Hour))
+	db.AddDelivery(ctx, AlertDelivery{Time: start.Add(time.Hour), Type: AlertTypeDown, Service: "api", OK: true, Attempts: 1})
+
+	tt := []struct {
+		kind     string
+		format   string
+		expected string
+	}{
+		{"history", ExportCSV, "service,state,time,response_time_ms\napi,up,2024-03-10T12:00:00Z,120\napi,down,2024-03-10T13:00:00Z,0\n"},
+		{"incidents", ExportCSV, "id,service,title,state,severity,start,end,message,acked_by,acked_at,postmortem\n1,api,,down,critical,2024-03-10T13:00:00Z,,\"timeout, retrying\",,,\n"},
+		{"alerts", ExportCSV, "id,time,notifier,type,service,incident,ok,error,code,attempts,latency_ms\n1,2024-03-10T13:00:00Z,0,down,api,,true,,0,1,0\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.kind, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := Export(ctx, &b, db, tc.kind, tc.format, start, start.Add(2*time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tc.expected {
+				t.Errorf("expected %q got %q", tc.expected, b.String())
+			}
+		})
+	}
+
+	var b bytes.Buffer
+	Export(ctx, &b, db, "history", ExportNDJSON, start.Add(time.Minute), start.Add(2*time.Hour))
+	var r StatusRecord
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &r) != nil || r.State != StateDown {
+		t.Errorf("expected the down check in the range got %q", b.String())
+	}
+	if err := Export(ctx, &b, db, "checks", ExportCSV, start, start); err != ErrUnknownExport {
+		t.Errorf("expected %v got %v", ErrUnknownExport, err)
+	}
+	if err := Export(ctx, &b, db, "history", "xml", start, start); err != ErrExportFormat {
+		t.Errorf("expected %v got %v", ErrExportFormat, err)
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	db, _ := OpenStorage("")
+	h := ExportHandler(db)
+
+	tt := []struct {
+		path     string
+		expected int
+	}{
+		{"/api/export/history", http.StatusOK},
+		{"/api/export/alerts?format=ndjson&start=2024-03-10T12:00:00Z", http.StatusOK},
+		{"/api/export/incidents?format=xml", http.StatusBadRequest},
+		{"/api/export/history?start=yesterday", http.StatusBadRequest},
+		{"/api/export/checks", http.StatusNotFound},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if w.Code != tc.expected {
+			t.Errorf("%s: expected %d got %d", tc.path, tc.expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Errors returned by the Gotify notifier
+var (
+	ErrMissingGotifyConfig = errors.New("notify: gotify notifier needs a url and token")
+	ErrGotifyRejected      = errors.New("notify: gotify rejected the message")
+)
+
+// GotifyNotifier sends alerts to a self-hosted Gotify server
+type GotifyNotifier struct {
+	URL string
+	// Token is the application token
+	Token  string
+	Client *http.Client
+}
+
+// gotifyMessage is the body of a create message request
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// Notify creates a message. Down alerts get a high priority
+// so clients show them prominently.
+func (n *GotifyNotifier) Notify(a Alert) error {
+	m := gotifyMessage{
+		Title:    a.Title(),
+		Message:  a.Message,
+		Priority: 5,
+	}
+	switch a.Type {
+	case AlertTypeDown:
+		m.Priority = 8
+	case AlertTypeDegraded:
+		m.Priority = 6
+	case AlertTypeRecovery, AlertTypeBurnRateResolved:
+		m.Priority = 4
+	}
+	if m.Message == "" {
+		m.Message = m.Title
+	}
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(n.URL, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", n.Token)
+
+	resp, err := clientOrDefault(n.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{Err: ErrGotifyRejected, Code: resp.StatusCode}
+	}
+	return nil
+}
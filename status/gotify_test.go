@@ -0,0 +1,42 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGotifyNotifier(t *testing.T) {
+	var m gotifyMessage
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/message" || r.Header.Get("X-Gotify-Key") != "app-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&m)
+	}))
+	defer ts.Close()
+
+	n, err := NewNotifier(NotifierConfig{Type: "gotify", URL: ts.URL, Token: "app-token"})
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	if err := n.Notify(Alert{Type: AlertTypeDown, Service: Service{Name: "api"}, Message: "timeout"}); err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	expected := gotifyMessage{Title: "api is down", Message: "timeout", Priority: 8}
+	if m != expected {
+		t.Errorf("expected %+v got %+v", expected, m)
+	}
+
+	bad := &GotifyNotifier{URL: ts.URL, Token: "wrong"}
+	if err := bad.Notify(Alert{Type: AlertTypeDown, Service: Service{Name: "api"}}); !errors.Is(err, ErrGotifyRejected) {
+		t.Errorf("expected %v got %v", ErrGotifyRejected, err)
+	}
+
+	if _, err := NewNotifier(NotifierConfig{Type: "gotify", URL: ts.URL}); err != ErrMissingGotifyConfig {
+		t.Errorf("expected %v got %v", ErrMissingGotifyConfig, err)
+	}
+}
@@ -0,0 +1,814 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// This file holds a small GraphQL engine: a parser of query documents
+// and an executor resolving them against a gqlSchema. It supports
+// queries with variables, aliases, fragments and the @skip and
+// @include directives, and __typename, but no mutations, subscriptions
+// or introspection.
+
+// gqlMaxDepth is how deeply the fields of a query may nest
+const gqlMaxDepth = 10
+
+// gqlLocation is the line and column of an error in a query
+type gqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// gqlError is an error of a query, reported along with the data
+type gqlError struct {
+	Message   string        `json:"message"`
+	Locations []gqlLocation `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+func (e *gqlError) Error() string {
+	return e.Message
+}
+
+// gqlVariable and gqlEnum are the variables and enum values of the
+// arguments of a query before they are resolved
+type (
+	gqlVariable string
+	gqlEnum     string
+)
+
+// gqlDirective is a directive such as @skip(if: $flag)
+type gqlDirective struct {
+	name string
+	args map[string]interface{}
+}
+
+// gqlSelection is a field, a fragment spread or an inline fragment
+type gqlSelection struct {
+	// field is set on fields, spread on fragment spreads and on on
+	// inline fragments with a type condition
+	field      *gqlField
+	spread     string
+	on         string
+	selections []gqlSelection
+	directives []gqlDirective
+}
+
+// gqlField is a field of a selection set
+type gqlField struct {
+	alias, name string
+	args        map[string]interface{}
+	selections  []gqlSelection
+	pos         int
+}
+
+// key is the name of the field in the response
+func (f *gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// gqlVariableDefinition declares a variable of an operation
+type gqlVariableDefinition struct {
+	name, typ  string
+	def        interface{}
+	hasDefault bool
+}
+
+// gqlOperation is an operation of a document
+type gqlOperation struct {
+	kind, name string
+	variables  []gqlVariableDefinition
+	selections []gqlSelection
+	pos        int
+}
+
+// gqlFragment is a named fragment of a document
+type gqlFragment struct {
+	on         string
+	selections []gqlSelection
+}
+
+// gqlDocument is a parsed query document
+type gqlDocument struct {
+	operations []gqlOperation
+	fragments  map[string]gqlFragment
+}
+
+// gqlToken kinds
+const (
+	gqlEOF = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind  int
+	value string
+	pos   int
+}
+
+// gqlParser parses a document one token ahead
+type gqlParser struct {
+	src string
+	pos int
+	tok gqlToken
+}
+
+// parseGraphQL parses a query document
+func parseGraphQL(src string) (doc *gqlDocument, err error) {
+	p := &gqlParser{src: src}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*gqlError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, e
+		}
+	}()
+	p.next()
+	doc = &gqlDocument{fragments: make(map[string]gqlFragment)}
+	for p.tok.kind != gqlEOF {
+		switch {
+		case p.is(gqlPunct, "{"):
+			doc.operations = append(doc.operations, gqlOperation{kind: "query", pos: p.tok.pos, selections: p.selectionSet()})
+		case p.is(gqlName, "query"), p.is(gqlName, "mutation"), p.is(gqlName, "subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.is(gqlName, "fragment"):
+			p.next()
+			name := p.name()
+			if name == "on" {
+				p.fail(p.tok.pos, "Unexpected Name \"on\"")
+			}
+			p.expectName("on")
+			on := p.name()
+			p.directives(true)
+			doc.fragments[name] = gqlFragment{on: on, selections: p.selectionSet()}
+		default:
+			p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		p.fail(0, "the document has no operation")
+	}
+	return doc, nil
+}
+
+// location returns the line and column of the offset pos
+func gqlLocate(src string, pos int) gqlLocation {
+	if pos > len(src) {
+		pos = len(src)
+	}
+	line := 1 + strings.Count(src[:pos], "\n")
+	column := 1 + utf8.RuneCountInString(src[strings.LastIndex(src[:pos], "\n")+1:pos])
+	return gqlLocation{Line: line, Column: column}
+}
+
+func (p *gqlParser) fail(pos int, format string, args ...interface{}) {
+	panic(&gqlError{Message: "Syntax Error: " + fmt.Sprintf(format, args...), Locations: []gqlLocation{gqlLocate(p.src, pos)}})
+}
+
+func (p *gqlParser) unexpected() {
+	if p.tok.kind == gqlEOF {
+		p.fail(p.tok.pos, "Unexpected <EOF>")
+	}
+	p.fail(p.tok.pos, "Unexpected %q", p.tok.value)
+}
+
+func (p *gqlParser) is(kind int, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// skip moves past the punctuator when it is next
+func (p *gqlParser) skip(punct string) bool {
+	if p.is(gqlPunct, punct) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *gqlParser) expect(punct string) {
+	if !p.skip(punct) {
+		p.unexpected()
+	}
+}
+
+func (p *gqlParser) expectName(name string) {
+	if !p.is(gqlName, name) {
+		p.unexpected()
+	}
+	p.next()
+}
+
+func (p *gqlParser) name() string {
+	if p.tok.kind != gqlName {
+		p.unexpected()
+	}
+	name := p.tok.value
+	p.next()
+	return name
+}
+
+// next reads the next token, skipping white space, commas and
+// comments
+func (p *gqlParser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.pos++
+	}
+	start := p.pos
+	if strings.HasPrefix(p.src[p.pos:], "\ufeff") {
+		p.pos += len("\ufeff")
+		p.next()
+		return
+	}
+	if p.pos >= len(p.src) {
+		p.tok = gqlToken{kind: gqlEOF, pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = gqlToken{gqlPunct, "...", start}
+	case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+		p.pos++
+		p.tok = gqlToken{gqlPunct, string(c), start}
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(p.src) && gqlNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = gqlToken{gqlName, p.src[start:p.pos], start}
+	case c == '-' || c >= '0' && c <= '9':
+		p.number(start)
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		p.blockString(start)
+	case c == '"':
+		p.string(start)
+	default:
+		p.fail(start, "Unexpected character %q", c)
+	}
+}
+
+func gqlNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *gqlParser) digits() {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail(p.pos, "Invalid number")
+	}
+}
+
+func (p *gqlParser) number(start int) {
+	kind := gqlInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	p.digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = gqlFloat
+		p.pos++
+		p.digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = gqlFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		p.digits()
+	}
+	if p.pos < len(p.src) && (gqlNameChar(p.src[p.pos]) || p.src[p.pos] == '.') {
+		p.fail(p.pos, "Invalid number")
+	}
+	p.tok = gqlToken{kind, p.src[start:p.pos], start}
+}
+
+func (p *gqlParser) string(start int) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			p.fail(p.pos, "Unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			p.tok = gqlToken{gqlString, b.String(), start}
+			return
+		case c == '\\' && p.pos+1 < len(p.src):
+			e := p.src[p.pos+1]
+			p.pos += 2
+			switch e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.src) {
+					p.fail(p.pos, "Invalid escape")
+				}
+				r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					p.fail(p.pos, "Invalid escape")
+				}
+				b.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				p.fail(p.pos-1, "Invalid escape")
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// blockString reads a """block string""", whose lines lose their
+// common indentation and the blank lines around them
+func (p *gqlParser) blockString(start int) {
+	p.pos += 3
+	end := strings.Index(strings.Replace(p.src[p.pos:], `\"""`, "xxxx", -1), `"""`)
+	if end < 0 {
+		p.fail(start, "Unterminated string")
+	}
+	raw := strings.Replace(p.src[p.pos:p.pos+end], `\"""`, `"""`, -1)
+	p.pos += end + 3
+	lines := strings.Split(strings.Replace(raw, "\r\n", "\n", -1), "\n")
+	indent := -1
+	for _, l := range lines[1:] {
+		if n := len(l) - len(strings.TrimLeft(l, " \t")); n < len(l) && (indent < 0 || n < indent) {
+			indent = n
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	p.tok = gqlToken{gqlString, strings.Join(lines, "\n"), start}
+}
+
+func (p *gqlParser) operation() gqlOperation {
+	op := gqlOperation{kind: p.tok.value, pos: p.tok.pos}
+	p.next()
+	if p.tok.kind == gqlName {
+		op.name = p.name()
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			v := gqlVariableDefinition{}
+			p.expect("$")
+			v.name = p.name()
+			p.expect(":")
+			v.typ = p.typ()
+			if p.skip("=") {
+				v.def, v.hasDefault = p.value(true), true
+			}
+			p.directives(true)
+			op.variables = append(op.variables, v)
+		}
+	}
+	p.directives(false)
+	op.selections = p.selectionSet()
+	return op
+}
+
+// typ reads a type reference such as [String!]!
+func (p *gqlParser) typ() string {
+	var t string
+	if p.skip("[") {
+		t = "[" + p.typ() + "]"
+		p.expect("]")
+	} else {
+		t = p.name()
+	}
+	if p.skip("!") {
+		t += "!"
+	}
+	return t
+}
+
+func (p *gqlParser) selectionSet() []gqlSelection {
+	p.expect("{")
+	var selections []gqlSelection
+	for !p.skip("}") {
+		selections = append(selections, p.selection())
+	}
+	if len(selections) == 0 {
+		p.fail(p.tok.pos, "Expected a selection")
+	}
+	return selections
+}
+
+func (p *gqlParser) selection() gqlSelection {
+	if p.skip("...") {
+		if p.tok.kind == gqlName && p.tok.value != "on" {
+			return gqlSelection{spread: p.name(), directives: p.directives(false)}
+		}
+		s := gqlSelection{}
+		if p.is(gqlName, "on") {
+			p.next()
+			s.on = p.name()
+		}
+		s.directives = p.directives(false)
+		s.selections = p.selectionSet()
+		return s
+	}
+	f := &gqlField{pos: p.tok.pos, name: p.name()}
+	if p.skip(":") {
+		f.alias, f.name = f.name, p.name()
+	}
+	f.args = p.arguments(false)
+	s := gqlSelection{field: f, directives: p.directives(false)}
+	if p.is(gqlPunct, "{") {
+		f.selections = p.selectionSet()
+	}
+	return s
+}
+
+func (p *gqlParser) arguments(constant bool) map[string]interface{} {
+	args := make(map[string]interface{})
+	if !p.skip("(") {
+		return args
+	}
+	for !p.skip(")") {
+		name := p.name()
+		p.expect(":")
+		args[name] = p.value(constant)
+	}
+	return args
+}
+
+func (p *gqlParser) directives(constant bool) []gqlDirective {
+	var directives []gqlDirective
+	for p.skip("@") {
+		directives = append(directives, gqlDirective{name: p.name(), args: p.arguments(constant)})
+	}
+	return directives
+}
+
+// value reads a value, which may not use variables when constant
+func (p *gqlParser) value(constant bool) interface{} {
+	tok := p.tok
+	switch {
+	case tok.kind == gqlPunct && tok.value == "$" && !constant:
+		p.next()
+		return gqlVariable(p.name())
+	case tok.kind == gqlInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			p.fail(tok.pos, "Invalid number")
+		}
+		return n
+	case tok.kind == gqlFloat:
+		p.next()
+		f, _ := strconv.ParseFloat(tok.value, 64)
+		return f
+	case tok.kind == gqlString:
+		p.next()
+		return tok.value
+	case tok.kind == gqlName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return gqlEnum(tok.value)
+	case p.skip("["):
+		list := []interface{}{}
+		for !p.skip("]") {
+			list = append(list, p.value(constant))
+		}
+		return list
+	case p.skip("{"):
+		object := make(map[string]interface{})
+		for !p.skip("}") {
+			name := p.name()
+			p.expect(":")
+			object[name] = p.value(constant)
+		}
+		return object
+	}
+	p.unexpected()
+	return nil
+}
+
+// gqlResolver resolves a field of parent with its arguments
+type gqlResolver func(ctx context.Context, parent interface{}, args map[string]interface{}) (interface{}, error)
+
+// gqlFieldDef is a field of a type of a gqlSchema: its type, such as
+// "[Service!]!", the names of its arguments and its resolver
+type gqlFieldDef struct {
+	typ     string
+	args    []string
+	resolve gqlResolver
+}
+
+// gqlSchema maps the object types to their fields. Query is the root
+// type; types without fields are scalars.
+type gqlSchema map[string]map[string]gqlFieldDef
+
+// gqlResult is an object of the response, keeping the order of the
+// fields of the query
+type gqlResult []struct {
+	key   string
+	value interface{}
+}
+
+// MarshalJSON encodes the fields in order
+func (r gqlResult) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// gqlExecutor runs an operation of a document
+type gqlExecutor struct {
+	schema    gqlSchema
+	src       string
+	doc       *gqlDocument
+	variables map[string]interface{}
+	errors    []*gqlError
+}
+
+// executeGraphQL runs the operation of the document src named
+// operation, or its only one, resolving root. A query which cannot
+// run returns an error; errors of fields are reported along with the
+// data.
+func executeGraphQL(ctx context.Context, schema gqlSchema, src, operation string, variables map[string]interface{}, root interface{}) (gqlResult, []*gqlError, error) {
+	doc, err := parseGraphQL(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	var op *gqlOperation
+	for i := range doc.operations {
+		if operation == "" && len(doc.operations) > 1 {
+			return nil, nil, &gqlError{Message: "Must provide the operation name of a document with several operations"}
+		}
+		if operation == "" || doc.operations[i].name == operation {
+			op = &doc.operations[i]
+		}
+	}
+	if op == nil {
+		return nil, nil, &gqlError{Message: fmt.Sprintf("Unknown operation named %q", operation)}
+	}
+	if op.kind != "query" {
+		return nil, nil, &gqlError{Message: "Only queries are supported", Locations: []gqlLocation{gqlLocate(src, op.pos)}}
+	}
+
+	e := &gqlExecutor{schema: schema, src: src, doc: doc, variables: make(map[string]interface{})}
+	for _, v := range op.variables {
+		value, ok := variables[v.name]
+		switch {
+		case !ok && v.hasDefault:
+			value = v.def
+		case (!ok || value == nil) && strings.HasSuffix(v.typ, "!"):
+			return nil, nil, &gqlError{Message: fmt.Sprintf("Variable \"$%s\" of required type %q was not provided", v.name, v.typ)}
+		}
+		e.variables[v.name] = value
+	}
+	data := e.selectionSet(ctx, "Query", root, op.selections, nil)
+	return data, e.errors, nil
+}
+
+// fieldError records the error of a field at path
+func (e *gqlExecutor) fieldError(f *gqlField, path []interface{}, err error) {
+	ge := &gqlError{Message: err.Error(), Path: append([]interface{}(nil), path...)}
+	if f != nil {
+		ge.Locations = []gqlLocation{gqlLocate(e.src, f.pos)}
+	}
+	e.errors = append(e.errors, ge)
+}
+
+// included evaluates the @skip and @include directives
+func (e *gqlExecutor) included(directives []gqlDirective) bool {
+	for _, d := range directives {
+		cond, _ := e.resolveValue(d.args["if"]).(bool)
+		if d.name == "skip" && cond || d.name == "include" && !cond {
+			return false
+		}
+	}
+	return true
+}
+
+// collect gathers the fields of the selections which apply to typ by
+// their key, merging those of the same key
+func (e *gqlExecutor) collect(typ string, selections []gqlSelection, keys *[]string, fields map[string][]*gqlField, visited map[string]bool) {
+	for _, s := range selections {
+		if !e.included(s.directives) {
+			continue
+		}
+		switch {
+		case s.field != nil:
+			k := s.field.key()
+			if _, ok := fields[k]; !ok {
+				*keys = append(*keys, k)
+			}
+			fields[k] = append(fields[k], s.field)
+		case s.spread != "":
+			f, ok := e.doc.fragments[s.spread]
+			if visited[s.spread] || !ok || f.on != typ {
+				continue
+			}
+			visited[s.spread] = true
+			e.collect(typ, f.selections, keys, fields, visited)
+		case s.on == "" || s.on == typ:
+			e.collect(typ, s.selections, keys, fields, visited)
+		}
+	}
+}
+
+// resolveValue replaces the variables of an argument by their values
+func (e *gqlExecutor) resolveValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case gqlVariable:
+		return e.variables[string(v)]
+	case gqlEnum:
+		return string(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = e.resolveValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			object[k] = e.resolveValue(item)
+		}
+		return object
+	}
+	return v
+}
+
+func (e *gqlExecutor) selectionSet(ctx context.Context, typ string, parent interface{}, selections []gqlSelection, path []interface{}) gqlResult {
+	var keys []string
+	fields := make(map[string][]*gqlField)
+	e.collect(typ, selections, &keys, fields, make(map[string]bool))
+
+	result := make(gqlResult, 0, len(keys))
+	for _, k := range keys {
+		f := fields[k][0]
+		var sub []gqlSelection
+		for _, same := range fields[k] {
+			sub = append(sub, same.selections...)
+		}
+		fieldPath := append(append([]interface{}(nil), path...), k)
+		result = append(result, struct {
+			key   string
+			value interface{}
+		}{k, e.field(ctx, typ, parent, f, sub, fieldPath)})
+	}
+	return result
+}
+
+// field resolves a field of parent, of type typ, and completes its
+// value, which is null when it fails
+func (e *gqlExecutor) field(ctx context.Context, typ string, parent interface{}, f *gqlField, sub []gqlSelection, path []interface{}) interface{} {
+	if f.name == "__typename" {
+		return typ
+	}
+	def, ok := e.schema[typ][f.name]
+	if !ok {
+		e.fieldError(f, path, fmt.Errorf("Cannot query field %q on type %q", f.name, typ))
+		return nil
+	}
+	args := make(map[string]interface{}, len(f.args))
+	for name, v := range f.args {
+		known := false
+		for _, a := range def.args {
+			known = known || a == name
+		}
+		if !known {
+			e.fieldError(f, path, fmt.Errorf("Unknown argument %q on field %q of type %q", name, f.name, typ))
+			return nil
+		}
+		args[name] = e.resolveValue(v)
+	}
+	depth := 0
+	for _, p := range path {
+		if _, ok := p.(string); ok {
+			depth++
+		}
+	}
+	if depth > gqlMaxDepth {
+		e.fieldError(f, path, fmt.Errorf("the query nests more than %d fields deep", gqlMaxDepth))
+		return nil
+	}
+	v, err := def.resolve(ctx, parent, args)
+	if err != nil {
+		e.fieldError(f, path, err)
+		return nil
+	}
+	return e.complete(ctx, def.typ, v, f, sub, path)
+}
+
+// complete turns the value of a field of type typ into that of the
+// response, resolving the fields selected of objects
+func (e *gqlExecutor) complete(ctx context.Context, typ string, v interface{}, f *gqlField, sub []gqlSelection, path []interface{}) interface{} {
+	typ = strings.TrimSuffix(typ, "!")
+	rv := reflect.ValueOf(v)
+	if v == nil || (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Map) && rv.IsNil() {
+		if strings.HasPrefix(typ, "[") {
+			return []interface{}{}
+		}
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr {
+		v, rv = rv.Elem().Interface(), rv.Elem()
+	}
+	if strings.HasPrefix(typ, "[") {
+		item := strings.TrimSuffix(strings.TrimPrefix(typ, "["), "]")
+		if rv.Kind() != reflect.Slice {
+			e.fieldError(f, path, fmt.Errorf("field %q is not a list", f.name))
+			return nil
+		}
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = e.complete(ctx, item, rv.Index(i).Interface(), f, sub, append(path, i))
+		}
+		return list
+	}
+	if len(e.schema[typ]) == 0 {
+		if len(sub) > 0 {
+			e.fieldError(f, path, fmt.Errorf("Field %q must not have a selection since type %q has no subfields", f.name, typ))
+			return nil
+		}
+		if t, ok := v.(time.Time); ok {
+			if t.IsZero() {
+				return nil
+			}
+			return t.Format(time.RFC3339)
+		}
+		return v
+	}
+	if len(sub) == 0 {
+		e.fieldError(f, path, fmt.Errorf("Field %q of type %q must have a selection of subfields", f.name, typ))
+		return nil
+	}
+	return e.selectionSet(ctx, typ, v, sub, path)
+}
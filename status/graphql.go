@@ -0,0 +1,141 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by the graphql check
+var (
+	ErrGraphQLErrors    = errors.New("commands: graphql response contains errors")
+	ErrGraphQLAssertion = errors.New("commands: graphql data assertion failed")
+)
+
+// defaultGraphQLQuery is the cheapest query every GraphQL
+// server is able to answer
+const defaultGraphQLQuery = "{ __typename }"
+
+// GraphQL checks a GraphQL endpoint answers a query
+// without errors
+type GraphQL struct {
+	Service
+	timingRecorder
+	client *http.Client
+}
+
+// GetService return the Service pointer
+func (g *GraphQL) GetService() *Service {
+	return &g.Service
+}
+
+// graphQLResponse is the standard GraphQL response envelope
+type graphQLResponse struct {
+	Data   interface{}       `json:"data"`
+	Errors []json.RawMessage `json:"errors"`
+}
+
+// Status POSTs the query and checks the response has no top-level
+// errors and that each expected data field has the expected value
+func (g *GraphQL) Status() error {
+	query := g.Query
+	if query == "" {
+		query = defaultGraphQLQuery
+	}
+
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, g.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := clientOrDefault(g.client).Do(g.trace(req))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !validStatus(resp.StatusCode) {
+		return ErrServiceUnavailable
+	}
+
+	if err := checkHeaders(resp.Header, g.ExpectHeaders); err != nil {
+		return err
+	}
+
+	var gr graphQLResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gr); err != nil {
+		return err
+	}
+
+	if len(gr.Errors) > 0 {
+		return ErrGraphQLErrors
+	}
+
+	for path, expected := range g.Expect {
+		v, ok := lookupPath(gr.Data, path)
+		if !ok || fmt.Sprint(v) != expected {
+			return ErrGraphQLAssertion
+		}
+	}
+
+	return nil
+}
+
+// lookupPath walks a decoded JSON value following a dotted path
+// such as "viewer.repositories.0.name"
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return v, true
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// GraphQLFactory implements the PingerFactory
+// interface
+type GraphQLFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *GraphQLFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "graphql" {
+		return nil, ErrInvalidCreate
+	}
+	client, err := newHTTPClient(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GraphQL{
+		Service: s,
+		client:  client,
+	}, nil
+}
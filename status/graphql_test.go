@@ -0,0 +1,77 @@
+package status
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGraphQLStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch req.Query {
+		case defaultGraphQLQuery:
+			io.WriteString(w, `{"data":{"__typename":"Query"}}`)
+		case "{ health { status replicas { name } } }":
+			io.WriteString(w, `{"data":{"health":{"status":"OK","replicas":[{"name":"a"},{"name":"b"}]}}}`)
+		default:
+			io.WriteString(w, `{"data":null,"errors":[{"message":"Cannot query field"}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	tt := []struct {
+		name     string
+		query    string
+		expect   map[string]string
+		expected error
+	}{
+		{name: "default query", expected: nil},
+		{name: "errors", query: "{ nope }", expected: ErrGraphQLErrors},
+		{
+			name:     "assertion",
+			query:    "{ health { status replicas { name } } }",
+			expect:   map[string]string{"health.status": "OK", "health.replicas.1.name": "b"},
+			expected: nil,
+		},
+		{
+			name:     "assertion mismatch",
+			query:    "{ health { status replicas { name } } }",
+			expect:   map[string]string{"health.status": "DOWN"},
+			expected: ErrGraphQLAssertion,
+		},
+		{
+			name:     "assertion missing field",
+			query:    "{ health { status replicas { name } } }",
+			expect:   map[string]string{"health.replicas.5.name": "b"},
+			expected: ErrGraphQLAssertion,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := GraphQL{Service: Service{URL: ts.URL, Query: tc.query, Expect: tc.expect}}
+			if actual := g.Status(); actual != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGraphQLStatusCodeFail(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	g := GraphQL{Service: Service{URL: ts.URL}}
+	if actual := g.Status(); actual != ErrServiceUnavailable {
+		t.Errorf("expected %v got %v", ErrServiceUnavailable, actual)
+	}
+}
@@ -0,0 +1,560 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// graphQLMaxBody is the size of the largest query accepted
+const graphQLMaxBody = 1 << 20
+
+// graphQLSchema describes the queries GraphQLHandler answers. Times
+// are RFC 3339 strings.
+const graphQLSchema = `scalar Time
+
+enum State { up down degraded affected maintenance }
+enum Severity { critical warning info }
+enum IncidentStatus { ongoing resolved }
+
+type Query {
+  # the page, with its overall status and groups
+  page: Page!
+  # the services, including those removed from the config, sorted by name
+  services(status: [State!], group: String, tag: String): [Service!]!
+  service(name: String!): Service
+  # the incidents, newest first
+  incidents(service: String, state: State, status: IncidentStatus, start: Time, end: Time, first: Int = 50, offset: Int = 0): [Incident!]!
+  incident(id: ID!): Incident
+  # the uptime of the services over a window ending now, such as 30d or 12h
+  uptime(window: String = "30d", service: String): [Uptime!]!
+  # the checks of the services, newest first
+  history(service: String, start: Time, end: Time, first: Int = 100, offset: Int = 0): [Check!]!
+}
+
+type Page {
+  title: String!
+  status: String!
+  updated: Time
+  groups: [Group!]!
+}
+
+type Group {
+  name: String!
+  state: State!
+  services: [String!]!
+}
+
+type Service {
+  name: String!
+  type: String!
+  url: String
+  group: String
+  tags: [String!]!
+  renamedFrom: [String!]!
+  created: Time
+  removed: Time
+  # the state on the page, null once removed
+  state: State
+  message: String
+  since: Time
+  incident: Incident
+  # when the service was last checked, its latency and checks over the last day
+  checked: Time
+  latency: Latency
+  history(first: Int = 50): [Check!]!
+  uptime(window: String = "30d"): Float
+}
+
+type Latency {
+  checks: Int!
+  lastMs: Float!
+  minMs: Float!
+  avgMs: Float!
+  p50Ms: Float!
+  p95Ms: Float!
+  p99Ms: Float!
+  maxMs: Float!
+}
+
+type Incident {
+  id: ID!
+  service: String
+  manual: Boolean!
+  title: String
+  services: [String!]!
+  state: State!
+  severity: Severity!
+  message: String
+  start: Time!
+  end: Time
+  ongoing: Boolean!
+  escalations: Int!
+  ackedBy: String
+  ackedAt: Time
+  updates: [IncidentUpdate!]!
+  postmortem: String
+}
+
+type IncidentUpdate {
+  time: Time!
+  message: String!
+}
+
+type Uptime {
+  service: String!
+  window: String!
+  percent: Float!
+}
+
+type Check {
+  service: String!
+  state: State!
+  time: Time!
+  responseTimeMs: Float!
+}
+`
+
+// gqlStringArg returns the String argument name, empty when unset
+func gqlStringArg(args map[string]interface{}, name string) (string, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("argument %q must be a String", name)
+}
+
+// gqlIntArg returns the Int argument name between 0 and max, def when
+// unset
+func gqlIntArg(args map[string]interface{}, name string, def, max int) (int, error) {
+	var n float64
+	switch v := args[name].(type) {
+	case nil:
+		return def, nil
+	case int64:
+		n = float64(v)
+	case float64:
+		n = v
+	default:
+		return 0, fmt.Errorf("argument %q must be an Int", name)
+	}
+	if n != math.Trunc(n) || n < 0 || n > float64(max) {
+		return 0, fmt.Errorf("argument %q must be an Int between 0 and %d", name, max)
+	}
+	return int(n), nil
+}
+
+// gqlTimeArg returns the Time argument name, zero when unset
+func gqlTimeArg(args map[string]interface{}, name string) (time.Time, error) {
+	s, err := gqlStringArg(args, name)
+	if err != nil || s == "" {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("argument %q must be an RFC 3339 time", name)
+	}
+	return t, nil
+}
+
+// gqlGet is a field resolved from its parent alone
+func gqlGet(typ string, get func(parent interface{}) interface{}) gqlFieldDef {
+	return gqlFieldDef{typ: typ, resolve: func(_ context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+		return get(parent), nil
+	}}
+}
+
+// newGraphQLSchema returns the resolvers of graphQLSchema reading db.
+// The root of the queries is the Page of the viewer.
+func newGraphQLSchema(db StorageBackend) gqlSchema {
+	service := func(p interface{}) ServiceStatus { return p.(ServiceStatus) }
+	incident := func(p interface{}) Incident { return p.(Incident) }
+	latency := func(p interface{}) ServiceLatency { return p.(ServiceLatency) }
+	check := func(p interface{}) StatusRecord { return p.(StatusRecord) }
+	uptime := func(p interface{}) gqlUptime { return p.(gqlUptime) }
+	optional := func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	strs := func(s []string) []string {
+		if s == nil {
+			return []string{}
+		}
+		return s
+	}
+
+	return gqlSchema{
+		"Query": {
+			"page": gqlGet("Page!", func(p interface{}) interface{} { return p }),
+			"services": {typ: "[Service!]!", args: []string{"status", "group", "tag"}, resolve: func(ctx context.Context, p interface{}, args map[string]interface{}) (interface{}, error) {
+				var states map[State]bool
+				switch v := args["status"].(type) {
+				case nil:
+				case string:
+					states = map[State]bool{State(v): true}
+				case []interface{}:
+					states = make(map[State]bool)
+					for _, s := range v {
+						if s, ok := s.(string); ok {
+							states[State(s)] = true
+						}
+					}
+				default:
+					return nil, fmt.Errorf("argument %q must be a list of State", "status")
+				}
+				group, err := gqlStringArg(args, "group")
+				if err != nil {
+					return nil, err
+				}
+				tag, err := gqlStringArg(args, "tag")
+				if err != nil {
+					return nil, err
+				}
+				services, err := serviceStatuses(ctx, db, p.(Page))
+				if err != nil {
+					return nil, err
+				}
+				list := []ServiceStatus{}
+				for _, ss := range services {
+					tagged := tag == ""
+					for _, t := range ss.Tags {
+						tagged = tagged || t == tag
+					}
+					if (states == nil || states[ss.State]) && (group == "" || ss.Group == group) && tagged {
+						list = append(list, ss)
+					}
+				}
+				return list, nil
+			}},
+			"service": {typ: "Service", args: []string{"name"}, resolve: func(ctx context.Context, p interface{}, args map[string]interface{}) (interface{}, error) {
+				name, err := gqlStringArg(args, "name")
+				if err != nil {
+					return nil, err
+				}
+				services, err := serviceStatuses(ctx, db, p.(Page))
+				if err != nil {
+					return nil, err
+				}
+				for _, ss := range services {
+					if ss.Name == name {
+						return ss, nil
+					}
+				}
+				return nil, nil
+			}},
+			"incidents": {typ: "[Incident!]!", args: []string{"service", "state", "status", "start", "end", "first", "offset"}, resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				var f IncidentFilter
+				var state, status string
+				var err error
+				for _, v := range []struct {
+					name string
+					s    *string
+				}{{"service", &f.Service}, {"state", &state}, {"status", &status}} {
+					if *v.s, err = gqlStringArg(args, v.name); err != nil {
+						return nil, err
+					}
+				}
+				f.State = State(state)
+				if status != "" {
+					ongoing := status == "ongoing"
+					f.Ongoing = &ongoing
+				}
+				if f.Start, err = gqlTimeArg(args, "start"); err != nil {
+					return nil, err
+				}
+				if f.End, err = gqlTimeArg(args, "end"); err != nil {
+					return nil, err
+				}
+				first, err := gqlIntArg(args, "first", defaultPageSize, maxPageSize)
+				if err != nil {
+					return nil, err
+				}
+				offset, err := gqlIntArg(args, "offset", 0, math.MaxInt32)
+				if err != nil {
+					return nil, err
+				}
+				incidents, _, err := db.IncidentsPage(ctx, f, offset, first)
+				return incidents, err
+			}},
+			"incident": {typ: "Incident", args: []string{"id"}, resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				id := fmt.Sprint(args["id"])
+				inc, ok, err := db.Incident(ctx, id)
+				if err != nil || !ok {
+					return nil, err
+				}
+				return inc, nil
+			}},
+			"uptime": {typ: "[Uptime!]!", args: []string{"window", "service"}, resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				window, err := gqlStringArg(args, "window")
+				if err != nil {
+					return nil, err
+				}
+				service, err := gqlStringArg(args, "service")
+				if err != nil {
+					return nil, err
+				}
+				return graphQLUptime(ctx, db, service, window)
+			}},
+			"history": {typ: "[Check!]!", args: []string{"service", "start", "end", "first", "offset"}, resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				service, err := gqlStringArg(args, "service")
+				if err != nil {
+					return nil, err
+				}
+				start, err := gqlTimeArg(args, "start")
+				if err != nil {
+					return nil, err
+				}
+				end, err := gqlTimeArg(args, "end")
+				if err != nil {
+					return nil, err
+				}
+				if end.IsZero() {
+					end = time.Now()
+				}
+				first, err := gqlIntArg(args, "first", 100, maxPageSize)
+				if err != nil {
+					return nil, err
+				}
+				offset, err := gqlIntArg(args, "offset", 0, math.MaxInt32)
+				if err != nil {
+					return nil, err
+				}
+				checks, _, err := db.StatusHistoryPage(ctx, service, start, end, offset, first)
+				return checks, err
+			}},
+		},
+		"Page": {
+			"title":   gqlGet("String!", func(p interface{}) interface{} { return p.(Page).Title }),
+			"status":  gqlGet("String!", func(p interface{}) interface{} { return string(p.(Page).Status) }),
+			"updated": gqlGet("Time", func(p interface{}) interface{} { return p.(Page).Updated }),
+			"groups":  gqlGet("[Group!]!", func(p interface{}) interface{} { return p.(Page).Groups }),
+		},
+		"Group": {
+			"name":  gqlGet("String!", func(p interface{}) interface{} { return p.(ServiceGroup).Name }),
+			"state": gqlGet("State!", func(p interface{}) interface{} { return p.(ServiceGroup).State }),
+			"services": gqlGet("[String!]!", func(p interface{}) interface{} {
+				ids := []string{}
+				for _, s := range p.(ServiceGroup).Services {
+					ids = append(ids, s.ID)
+				}
+				return ids
+			}),
+		},
+		"Service": {
+			"name":        gqlGet("String!", func(p interface{}) interface{} { return service(p).Name }),
+			"type":        gqlGet("String!", func(p interface{}) interface{} { return service(p).Type }),
+			"url":         gqlGet("String", func(p interface{}) interface{} { return optional(service(p).URL) }),
+			"group":       gqlGet("String", func(p interface{}) interface{} { return optional(service(p).Group) }),
+			"tags":        gqlGet("[String!]!", func(p interface{}) interface{} { return strs(service(p).Tags) }),
+			"renamedFrom": gqlGet("[String!]!", func(p interface{}) interface{} { return strs(service(p).RenamedFrom) }),
+			"created":     gqlGet("Time", func(p interface{}) interface{} { return service(p).Created }),
+			"removed":     gqlGet("Time", func(p interface{}) interface{} { return service(p).Removed }),
+			"state":       gqlGet("State", func(p interface{}) interface{} { return optional(string(service(p).State)) }),
+			"message":     gqlGet("String", func(p interface{}) interface{} { return optional(service(p).Message) }),
+			"since":       gqlGet("Time", func(p interface{}) interface{} { return service(p).Since }),
+			"incident":    gqlGet("Incident", func(p interface{}) interface{} { return service(p).Incident }),
+			"checked": {typ: "Time", resolve: func(ctx context.Context, p interface{}, _ map[string]interface{}) (interface{}, error) {
+				now := time.Now()
+				checks, _, err := db.StatusHistoryPage(ctx, service(p).Name, now.Add(-recentWindow), now, 0, 1)
+				if err != nil || len(checks) == 0 {
+					return nil, err
+				}
+				return checks[0].Time, nil
+			}},
+			"latency": {typ: "Latency", resolve: func(ctx context.Context, p interface{}, _ map[string]interface{}) (interface{}, error) {
+				now := time.Now()
+				checks, err := db.StatusHistory(ctx, service(p).Name, now.Add(-recentWindow), now)
+				if err != nil {
+					return nil, err
+				}
+				return newServiceLatency(checks), nil
+			}},
+			"history": {typ: "[Check!]!", args: []string{"first"}, resolve: func(ctx context.Context, p interface{}, args map[string]interface{}) (interface{}, error) {
+				first, err := gqlIntArg(args, "first", recentChecks, maxPageSize)
+				if err != nil {
+					return nil, err
+				}
+				now := time.Now()
+				checks, _, err := db.StatusHistoryPage(ctx, service(p).Name, now.Add(-recentWindow), now, 0, first)
+				return checks, err
+			}},
+			"uptime": {typ: "Float", args: []string{"window"}, resolve: func(ctx context.Context, p interface{}, args map[string]interface{}) (interface{}, error) {
+				window, err := gqlStringArg(args, "window")
+				if err != nil {
+					return nil, err
+				}
+				uptimes, err := graphQLUptime(ctx, db, service(p).Name, window)
+				if err != nil || len(uptimes) == 0 {
+					return nil, err
+				}
+				return uptimes[0].Percent, nil
+			}},
+		},
+		"Latency": {
+			"checks": gqlGet("Int!", func(p interface{}) interface{} { return latency(p).Checks }),
+			"lastMs": gqlGet("Float!", func(p interface{}) interface{} { return latency(p).Last }),
+			"minMs":  gqlGet("Float!", func(p interface{}) interface{} { return latency(p).Min }),
+			"avgMs":  gqlGet("Float!", func(p interface{}) interface{} { return latency(p).Avg }),
+			"p50Ms":  gqlGet("Float!", func(p interface{}) interface{} { return latency(p).P50 }),
+			"p95Ms":  gqlGet("Float!", func(p interface{}) interface{} { return latency(p).P95 }),
+			"p99Ms":  gqlGet("Float!", func(p interface{}) interface{} { return latency(p).P99 }),
+			"maxMs":  gqlGet("Float!", func(p interface{}) interface{} { return latency(p).Max }),
+		},
+		"Incident": {
+			"id":          gqlGet("ID!", func(p interface{}) interface{} { return incident(p).ID }),
+			"service":     gqlGet("String", func(p interface{}) interface{} { return optional(incident(p).Service) }),
+			"manual":      gqlGet("Boolean!", func(p interface{}) interface{} { return incident(p).Manual }),
+			"title":       gqlGet("String", func(p interface{}) interface{} { return optional(incident(p).Title) }),
+			"services":    gqlGet("[String!]!", func(p interface{}) interface{} { return strs(incident(p).Services) }),
+			"state":       gqlGet("State!", func(p interface{}) interface{} { return incident(p).State }),
+			"severity":    gqlGet("Severity!", func(p interface{}) interface{} { return incident(p).Severity }),
+			"message":     gqlGet("String", func(p interface{}) interface{} { return optional(incident(p).Message) }),
+			"start":       gqlGet("Time!", func(p interface{}) interface{} { return incident(p).Start }),
+			"end":         gqlGet("Time", func(p interface{}) interface{} { return incident(p).End }),
+			"ongoing":     gqlGet("Boolean!", func(p interface{}) interface{} { return incident(p).Ongoing() }),
+			"escalations": gqlGet("Int!", func(p interface{}) interface{} { return incident(p).Escalations }),
+			"ackedBy":     gqlGet("String", func(p interface{}) interface{} { return optional(incident(p).AckedBy) }),
+			"ackedAt":     gqlGet("Time", func(p interface{}) interface{} { return incident(p).AckedAt }),
+			"updates":     gqlGet("[IncidentUpdate!]!", func(p interface{}) interface{} { return incident(p).Updates }),
+			"postmortem":  gqlGet("String", func(p interface{}) interface{} { return optional(incident(p).Postmortem) }),
+		},
+		"IncidentUpdate": {
+			"time":    gqlGet("Time!", func(p interface{}) interface{} { return p.(IncidentUpdate).Time }),
+			"message": gqlGet("String!", func(p interface{}) interface{} { return p.(IncidentUpdate).Message }),
+		},
+		"Uptime": {
+			"service": gqlGet("String!", func(p interface{}) interface{} { return uptime(p).Service }),
+			"window":  gqlGet("String!", func(p interface{}) interface{} { return uptime(p).Window }),
+			"percent": gqlGet("Float!", func(p interface{}) interface{} { return uptime(p).Percent }),
+		},
+		"Check": {
+			"service":        gqlGet("String!", func(p interface{}) interface{} { return check(p).Service }),
+			"state":          gqlGet("State!", func(p interface{}) interface{} { return check(p).State }),
+			"time":           gqlGet("Time!", func(p interface{}) interface{} { return check(p).Time }),
+			"responseTimeMs": gqlGet("Float!", func(p interface{}) interface{} { return milliseconds(check(p).ResponseTime) }),
+		},
+	}
+}
+
+// gqlUptime is the uptime of a service over a window
+type gqlUptime struct {
+	Service string
+	Window  string
+	Percent float64
+}
+
+// graphQLUptime returns the uptime of the services, or of service,
+// over the window ending now, sorted by service
+func graphQLUptime(ctx context.Context, db StorageBackend, service, window string) ([]gqlUptime, error) {
+	if window == "" {
+		window = "30d"
+	}
+	period, err := parseRetention(window)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window %q", window)
+	}
+	uptimes, err := windowsUptime(ctx, db, service, []UptimeWindow{{window, period}}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	list := []gqlUptime{}
+	for s, u := range uptimes {
+		list = append(list, gqlUptime{Service: s, Window: window, Percent: u[0].Percent})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Service < list[j].Service })
+	return list, nil
+}
+
+// graphQLRequest is a query sent to GraphQLHandler
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphQLResult is the answer to a query
+type graphQLResult struct {
+	Data   gqlResult   `json:"data,omitempty"`
+	Errors []*gqlError `json:"errors,omitempty"`
+}
+
+// GraphQLHandler is a HandlerFunc which answers GraphQL queries of the
+// services, incidents, uptime and history (POST /graphql with a JSON
+// query, operationName and variables, or GET /graphql?query=...).
+// GET /graphql without a query returns the schema.
+func GraphQLHandler(db StorageBackend, page *PageStore) http.HandlerFunc {
+	schema := newGraphQLSchema(db)
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req graphQLRequest
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			q := r.URL.Query()
+			req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+			if req.Query == "" {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.Write([]byte(graphQLSchema))
+				return
+			}
+			if v := q.Get("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					writeGraphQLError(w, "variables must be a JSON object")
+					return
+				}
+			}
+		case http.MethodPost:
+			r.Body = http.MaxBytesReader(w, r.Body, graphQLMaxBody)
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					writeGraphQLError(w, err.Error())
+					return
+				}
+				req.Query = string(b)
+			} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeGraphQLError(w, "the body must be a JSON object with a query")
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if req.Query == "" {
+			writeGraphQLError(w, "Must provide a query")
+			return
+		}
+
+		ctx, cancel := requestContext(r)
+		defer cancel()
+		var p Page
+		if page != nil {
+			p = page.PageFor(r)
+		}
+		data, errs, err := executeGraphQL(ctx, schema, req.Query, req.OperationName, req.Variables, p)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(graphQLResult{Errors: []*gqlError{err.(*gqlError)}})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(graphQLResult{Data: data, Errors: errs})
+	}
+}
+
+// writeGraphQLError answers a request which holds no query it can run
+func writeGraphQLError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(graphQLResult{Errors: []*gqlError{{Message: message}}})
+}
@@ -0,0 +1,190 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL(`
+		query Services($tag: String = "prod", $first: Int!) {
+			list: services(tag: $tag, status: [down, degraded]) { ...fields }
+			history(first: $first) @skip(if: true) { time }
+		}
+		fragment fields on Service { name ... on Service { state } }
+		# a comment
+		{ page { title } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.operations) != 2 || len(doc.fragments) != 1 {
+		t.Fatalf("expected 2 operations and a fragment got %+v", doc)
+	}
+	op := doc.operations[0]
+	if op.kind != "query" || op.name != "Services" || len(op.variables) != 2 || op.variables[0].def != "prod" || op.variables[1].typ != "Int!" {
+		t.Errorf("expected the Services query got %+v", op)
+	}
+	f := op.selections[0].field
+	if f.alias != "list" || f.name != "services" || f.args["tag"] != gqlVariable("tag") || !reflect.DeepEqual(f.args["status"], []interface{}{gqlEnum("down"), gqlEnum("degraded")}) {
+		t.Errorf("expected the aliased services field got %+v", f)
+	}
+	if d := op.selections[1].directives; len(d) != 1 || d[0].name != "skip" || d[0].args["if"] != true {
+		t.Errorf("expected @skip got %+v", d)
+	}
+	if fr := doc.fragments["fields"]; fr.on != "Service" || len(fr.selections) != 2 || fr.selections[1].on != "Service" {
+		t.Errorf("expected the fields fragment got %+v", fr)
+	}
+	if doc.operations[1].kind != "query" || doc.operations[1].name != "" {
+		t.Errorf("expected the anonymous query got %+v", doc.operations[1])
+	}
+
+	for src, loc := range map[string]gqlLocation{
+		"{ page { title }":         {1, 17},
+		"{\n  page(first: ) { x }": {2, 15},
+		`{ page(t: "open) }`:       {1, 19},
+	} {
+		_, err := parseGraphQL(src)
+		e, ok := err.(*gqlError)
+		if !ok || len(e.Locations) != 1 || e.Locations[0] != loc {
+			t.Errorf("%q: expected a syntax error at %+v got %v", src, loc, err)
+		}
+	}
+}
+
+// TestGraphQLSchema checks the resolvers match the types and fields
+// of the schema served to clients
+func TestGraphQLSchema(t *testing.T) {
+	schema := newGraphQLSchema(nil)
+	typ := regexp.MustCompile(`^type (\w+) \{$`)
+	field := regexp.MustCompile(`^(\w+)(?:\((.*)\))?: (.+)$`)
+	arg := regexp.MustCompile(`(\w+): `)
+	var current string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(graphQLSchema, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case typ.MatchString(line):
+			current = typ.FindStringSubmatch(line)[1]
+			if schema[current] == nil {
+				t.Errorf("type %s has no resolvers", current)
+			}
+		case line == "}":
+			current = ""
+		case current != "" && field.MatchString(line):
+			m := field.FindStringSubmatch(line)
+			seen[current+"."+m[1]] = true
+			def, ok := schema[current][m[1]]
+			if !ok {
+				t.Errorf("%s.%s has no resolver", current, m[1])
+				continue
+			}
+			var args []string
+			for _, a := range arg.FindAllStringSubmatch(m[2], -1) {
+				args = append(args, a[1])
+			}
+			if def.typ != m[3] || !reflect.DeepEqual(def.args, args) {
+				t.Errorf("%s.%s: expected %v %s got %v %s", current, m[1], args, m[3], def.args, def.typ)
+			}
+		}
+	}
+	for name, fields := range schema {
+		for f := range fields {
+			if !seen[name+"."+f] {
+				t.Errorf("%s.%s is missing from the schema", name, f)
+			}
+		}
+	}
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Now()
+	RegisterServices(ctx, db, []Service{{Name: "api", Type: "http", Tags: []string{"prod"}}, {Name: "web", Type: "http", Group: "edge"}}, now.Add(-time.Hour))
+	for i, ms := range []time.Duration{30, 10} {
+		db.RecordStatus(ctx, StatusRecord{Service: "web", State: StateUp, Time: now.Add(time.Duration(i-2) * time.Minute), ResponseTime: ms * time.Millisecond})
+	}
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: now.Add(-time.Hour)})
+	since := now.Add(-time.Minute)
+	inc, _ := db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", since)
+	page := NewPageStore(NewPage("Status", []Result{
+		{Service: &Service{Name: "api"}, State: StateDown, Err: errors.New("timeout"), Since: since, Incident: &inc},
+		{Service: &Service{Name: "web", Group: "edge"}, State: StateUp},
+	}))
+	handler := GraphQLHandler(db, page)
+	query := func(req *http.Request) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		handler(w, req)
+		var body map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&body)
+		return w.Code, body
+	}
+	post := func(q string, variables map[string]interface{}) (int, map[string]interface{}) {
+		b, _ := json.Marshal(graphQLRequest{Query: q, Variables: variables})
+		return query(httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(b))))
+	}
+
+	code, body := post(`query Down($status: [State!]) {
+		page { title groups { name state services } }
+		down: services(status: $status) { name state message since incident { id severity ongoing } }
+		web: service(name: "web") { ...latency history(first: 1) { responseTimeMs } }
+		incidents(service: "api", status: ongoing) { id message }
+	}
+	fragment latency on Service { checked latency { checks minMs maxMs } }`, map[string]interface{}{"status": []string{"down"}})
+	if code != http.StatusOK || body["errors"] != nil {
+		t.Fatalf("expected the data got %d %v", code, body)
+	}
+	b, _ := json.Marshal(body["data"])
+	expected := `{"down":[{"incident":{"id":"` + inc.ID + `","ongoing":true,"severity":"critical"},"message":"timeout","name":"api","since":"` + since.Format(time.RFC3339) + `","state":"down"}],` +
+		`"incidents":[{"id":"` + inc.ID + `","message":"timeout"}],` +
+		`"page":{"groups":[{"name":"edge","services":["web"],"state":"up"},{"name":"Other","services":["api"],"state":"down"}],"title":"Status"},` +
+		`"web":{"checked":"` + now.Add(-time.Minute).Format(time.RFC3339) + `","history":[{"responseTimeMs":10}],"latency":{"checks":2,"maxMs":30,"minMs":10}}}`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, b)
+	}
+
+	// the fields are answered in the order of the query
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`{ services(tag: "prod") { type name } uptime(window: "7d") { service percent } }`), nil))
+	if got := strings.TrimSpace(w.Body.String()); !strings.HasPrefix(got, `{"data":{"services":[{"type":"http","name":"api"}],"uptime":[{"service":"api","percent":`) {
+		t.Errorf("expected the services and uptime got %s", got)
+	}
+
+	// field errors are reported along with the data
+	code, body = post(`{ page { title } unknown history(first: -1) { time } }`, nil)
+	if errs, _ := body["errors"].([]interface{}); code != http.StatusOK || len(errs) != 2 || body["data"] == nil {
+		t.Errorf("expected the field errors got %d %v", code, body)
+	}
+
+	for q, message := range map[string]string{
+		`mutation { page { title } }`:                       "Only queries are supported",
+		`{ page { title }`:                                  "Unexpected <EOF>",
+		`query($n: String!) { service(name: $n) { name } }`: `Variable "$n" of required type "String!" was not provided`,
+	} {
+		code, body := post(q, nil)
+		errs, _ := body["errors"].([]interface{})
+		if code != http.StatusBadRequest || len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), message) {
+			t.Errorf("%q: expected %q got %d %v", q, message, code, body)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/graphql", nil))
+	if w.Body.String() != graphQLSchema {
+		t.Errorf("expected the schema got %s", w.Body)
+	}
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodDelete, "/graphql", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("expected %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
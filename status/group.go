@@ -0,0 +1,93 @@
+package status
+
+import "time"
+
+// ungroupedName names the group of the services without one, on a
+// page whose other services are grouped
+const ungroupedName = "Other"
+
+// ServiceGroup is a section of the page listing the services of a
+// Group. Its State rolls up theirs: the state of the worst of them.
+type ServiceGroup struct {
+	Name     string
+	State    State
+	Services []GroupedService
+}
+
+// GroupedService is a service of a ServiceGroup. Its details are in
+// the maps of the Page, under its ID.
+type GroupedService struct {
+	ID    string
+	State State
+	// Message is the reason a service is degraded or the message
+	// of its maintenance window
+	Message string
+	// Minutes is how long a down service has been down
+	Minutes int
+}
+
+// stateRank orders the states of services from up to down, so the
+// state of a group is the one of its highest rank
+func stateRank(s State) int {
+	switch s {
+	case StateDown:
+		return 4
+	case StateDegraded:
+		return 3
+	case StateAffected:
+		return 2
+	case StateMaintenance:
+		return 1
+	}
+	return 0
+}
+
+// groupResults groups the results by the Group of their service, in
+// the order the groups first appear in. Services without a group are
+// grouped last, as "Other". Results of which no service has a group
+// are not grouped.
+func groupResults(results []Result) []ServiceGroup {
+	grouped := false
+	for _, r := range results {
+		if r.Service.Group != "" {
+			grouped = true
+		}
+	}
+	if !grouped {
+		return nil
+	}
+
+	var groups []ServiceGroup
+	index := make(map[string]int)
+	add := func(name string, r Result) {
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, ServiceGroup{Name: name, State: StateUp})
+		}
+		s := GroupedService{ID: r.Service.ID(), State: r.State}
+		switch r.State {
+		case StateDegraded, StateMaintenance:
+			s.Message = r.Message
+		case StateDown:
+			s.Minutes = int(time.Since(r.Since).Minutes())
+		}
+		g := &groups[i]
+		g.Services = append(g.Services, s)
+		if stateRank(r.State) > stateRank(g.State) {
+			g.State = r.State
+		}
+	}
+	for _, r := range results {
+		if r.Service.Group != "" {
+			add(r.Service.Group, r)
+		}
+	}
+	for _, r := range results {
+		if r.Service.Group == "" {
+			add(ungroupedName, r)
+		}
+	}
+	return groups
+}
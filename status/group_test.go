@@ -0,0 +1,111 @@
+package status
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGroupResults(t *testing.T) {
+	if groups := groupResults([]Result{{Service: &Service{Name: "web"}, State: StateUp}}); groups != nil {
+		t.Errorf("expected no groups got %+v", groups)
+	}
+
+	groups := groupResults([]Result{
+		{Service: &Service{Name: "web", Group: "APIs"}, State: StateUp},
+		{Service: &Service{Name: "db", Group: "Databases"}, State: StateDegraded, Message: "slow"},
+		{Service: &Service{Name: "cdn"}, State: StateUp},
+		{Service: &Service{Name: "api", Group: "APIs"}, State: StateDown, Err: errors.New("timeout")},
+	})
+	expected := []struct {
+		name     string
+		state    State
+		services []string
+	}{
+		{"APIs", StateDown, []string{"web", "api"}},
+		{"Databases", StateDegraded, []string{"db"}},
+		{"Other", StateUp, []string{"cdn"}},
+	}
+	if len(groups) != len(expected) {
+		t.Fatalf("expected %d groups got %+v", len(expected), groups)
+	}
+	for i, e := range expected {
+		g := groups[i]
+		var ids []string
+		for _, s := range g.Services {
+			ids = append(ids, s.ID)
+		}
+		if g.Name != e.name || g.State != e.state || strings.Join(ids, ",") != strings.Join(e.services, ",") {
+			t.Errorf("expected %+v got %+v", e, g)
+		}
+	}
+	if groups[1].Services[0].Message != "slow" {
+		t.Errorf("expected the reason of the degraded service got %+v", groups[1].Services[0])
+	}
+}
+
+func TestIndexGroups(t *testing.T) {
+	tpl = template.Must(template.ParseGlob("../templates/*.gohtml"))
+	p := NewPage("Status", []Result{
+		{Service: &Service{Name: "web", Group: "APIs"}, State: StateUp},
+		{Service: &Service{Name: "db", Group: "Databases"}, State: StateDown, Err: errors.New("timeout")},
+	})
+
+	resp := NewAPIResponse(p)
+	if len(resp.Groups) != 2 || resp.Groups[1].State != StateDown || resp.Services[0].Group != "Databases" {
+		t.Errorf("unexpected groups %+v %+v", resp.Groups, resp.Services)
+	}
+
+	w := httptest.NewRecorder()
+	Index(NewPageStore(p))(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := w.Body.String()
+	// groups with a problem are expanded, the others collapsed
+	for _, s := range []string{
+		`<details class="group">`,
+		`<details class="group" open>`,
+		`<span class="group-name">Databases</span>`,
+		"</span> Down</span>",
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("expected %q in %s", s, body)
+		}
+	}
+}
+
+func TestIndexOrder(t *testing.T) {
+	tpl = template.Must(template.ParseGlob("../templates/*.gohtml"))
+	p := NewPage("Status", []Result{
+		{Service: &Service{Name: "web"}, State: StateUp},
+		{Service: &Service{Name: "search"}, State: StateDown, Err: errors.New("timeout")},
+		{Service: &Service{Name: "api"}, State: StateUp},
+		{Service: &Service{Name: "queue"}, State: StateDegraded},
+		{Service: &Service{Name: "db"}, State: StateDown, Err: errors.New("refused")},
+		{Service: &Service{Name: "cache"}, State: StateDegraded},
+	})
+	if strings.Join(p.Up, ",") != "api,web" {
+		t.Errorf("expected the services up sorted got %v", p.Up)
+	}
+
+	render := func() string {
+		w := httptest.NewRecorder()
+		Index(NewPageStore(p))(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Body.String()
+	}
+	body := render()
+	last := -1
+	for _, id := range []string{"db", "search", "cache", "queue", "api", "web"} {
+		i := strings.Index(body, "<span>"+id)
+		if i <= last {
+			t.Fatalf("expected %s after the services before it in %s", id, body)
+		}
+		last = i
+	}
+	for i := 0; i < 5; i++ {
+		if render() != body {
+			t.Fatal("expected the page in the same order on every render")
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStorageWrite is returned by the storage check when the last
+// write to the storage failed
+var ErrStorageWrite = errors.New("storage: last write failed")
+
+const (
+	// slowStorageWrite is the write latency past which the
+	// storage is degraded
+	slowStorageWrite = time.Second
+	// pruneOverdue is how long the storage may go without being
+	// pruned before it is degraded
+	pruneOverdue = 3 * time.Hour
+)
+
+// StorageStats is the health of the storage measured by WithHealth
+type StorageStats struct {
+	// Size is the size of the storage in bytes, when the backend
+	// reports it
+	Size         int64 `json:"size"`
+	Writes       int   `json:"writes"`
+	FailedWrites int   `json:"failed_writes"`
+	// WriteLatency is an average over the latest writes
+	WriteLatency time.Duration `json:"write_latency"`
+	LastError    string        `json:"last_error,omitempty"`
+	// LastPrune is when the storage was last pruned, Pruned how
+	// many records that removed
+	LastPrune time.Time `json:"last_prune"`
+	Pruned    int       `json:"pruned"`
+}
+
+// sizer is implemented by the backends which know their size
+type sizer interface {
+	Size() (int64, error)
+}
+
+// Size returns the size of the storage file, zero in memory
+func (db *Storage) Size() (int64, error) {
+	if db.path == "" {
+		return 0, nil
+	}
+	fi, err := os.Stat(db.path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// StorageHealth measures the writes to a storage, so a storage which
+// fails or slows down is noticed rather than only logged
+type StorageHealth struct {
+	mu      sync.Mutex
+	db      StorageBackend
+	started time.Time
+	stats   StorageStats
+	// failing is whether the last write failed
+	failing bool
+}
+
+// NewStorageHealth returns a StorageHealth which has seen no writes
+func NewStorageHealth() *StorageHealth {
+	return &StorageHealth{started: time.Now()}
+}
+
+// observe records a write which started at start and ended with err
+func (h *StorageHealth) observe(start time.Time, err error) {
+	latency := time.Since(start)
+	err = writeError(err)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stats.Writes++
+	if h.stats.Writes == 1 {
+		h.stats.WriteLatency = latency
+	} else {
+		h.stats.WriteLatency += (latency - h.stats.WriteLatency) / 10
+	}
+	h.failing = err != nil
+	if err != nil {
+		h.stats.FailedWrites++
+		h.stats.LastError = err.Error()
+	}
+}
+
+// Stats returns the health of the storage
+func (h *StorageHealth) Stats() StorageStats {
+	h.mu.Lock()
+	stats := h.stats
+	db := h.db
+	h.mu.Unlock()
+	if s, ok := db.(sizer); ok {
+		stats.Size, _ = s.Size()
+	}
+	return stats
+}
+
+// Check returns ErrStorageWrite when the last write failed, and a
+// DegradedError when writes are slow or pruning is overdue at now
+func (h *StorageHealth) Check(now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	lastPrune := h.stats.LastPrune
+	if lastPrune.IsZero() {
+		lastPrune = h.started
+	}
+	switch {
+	case h.failing:
+		return fmt.Errorf("%w: %s", ErrStorageWrite, h.stats.LastError)
+	case h.stats.WriteLatency > slowStorageWrite:
+		return Degraded("writes take " + h.stats.WriteLatency.Round(time.Millisecond).String())
+	case now.Sub(lastPrune) > pruneOverdue:
+		return Degraded("not pruned since " + lastPrune.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// healthStorage is a StorageBackend which measures the writes to
+// the backend it wraps
+type healthStorage struct {
+	StorageBackend
+	health *StorageHealth
+}
+
+// WithHealth returns db measuring its writes in h
+func WithHealth(db StorageBackend, h *StorageHealth) StorageBackend {
+	h.mu.Lock()
+	if h.db == nil {
+		h.db = db
+	}
+	h.mu.Unlock()
+	return &healthStorage{StorageBackend: db, health: h}
+}
+
+func (db *healthStorage) Tenant(name string) StorageBackend {
+	return &healthStorage{StorageBackend: db.StorageBackend.Tenant(name), health: db.health}
+}
+
+func (db *healthStorage) StartIncident(ctx context.Context, service string, state State, severity Severity, message string, t time.Time) (Incident, error) {
+	start := time.Now()
+	inc, err := db.StorageBackend.StartIncident(ctx, service, state, severity, message, t)
+	db.health.observe(start, err)
+	return inc, err
+}
+
+func (db *healthStorage) ResolveIncident(ctx context.Context, service string, t time.Time) (Incident, bool, error) {
+	start := time.Now()
+	inc, ok, err := db.StorageBackend.ResolveIncident(ctx, service, t)
+	db.health.observe(start, err)
+	return inc, ok, err
+}
+
+func (db *healthStorage) SetEscalations(ctx context.Context, id string, n int) error {
+	start := time.Now()
+	err := db.StorageBackend.SetEscalations(ctx, id, n)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) Acknowledge(ctx context.Context, id, by string, t time.Time) (Incident, error) {
+	start := time.Now()
+	inc, err := db.StorageBackend.Acknowledge(ctx, id, by, t)
+	db.health.observe(start, err)
+	return inc, err
+}
+
+func (db *healthStorage) OpenIncident(ctx context.Context, inc Incident) (Incident, error) {
+	start := time.Now()
+	inc, err := db.StorageBackend.OpenIncident(ctx, inc)
+	db.health.observe(start, err)
+	return inc, err
+}
+
+func (db *healthStorage) CloseIncident(ctx context.Context, id string, t time.Time) (Incident, error) {
+	start := time.Now()
+	inc, err := db.StorageBackend.CloseIncident(ctx, id, t)
+	db.health.observe(start, err)
+	return inc, err
+}
+
+func (db *healthStorage) AddIncidentUpdate(ctx context.Context, id string, u IncidentUpdate) (Incident, error) {
+	start := time.Now()
+	inc, err := db.StorageBackend.AddIncidentUpdate(ctx, id, u)
+	db.health.observe(start, err)
+	return inc, err
+}
+
+func (db *healthStorage) SetPostmortem(ctx context.Context, id, postmortem string) (Incident, error) {
+	start := time.Now()
+	inc, err := db.StorageBackend.SetPostmortem(ctx, id, postmortem)
+	db.health.observe(start, err)
+	return inc, err
+}
+
+func (db *healthStorage) RecordStatus(ctx context.Context, records ...StatusRecord) error {
+	start := time.Now()
+	err := db.StorageBackend.RecordStatus(ctx, records...)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) SaveRollups(ctx context.Context, period time.Duration, until time.Time, rollups []StatusRollup) error {
+	start := time.Now()
+	err := db.StorageBackend.SaveRollups(ctx, period, until, rollups)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) SyncServices(ctx context.Context, services []ServiceInfo, now time.Time) error {
+	start := time.Now()
+	err := db.StorageBackend.SyncServices(ctx, services, now)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) SetLastAlert(ctx context.Context, service string, t time.Time) error {
+	start := time.Now()
+	err := db.StorageBackend.SetLastAlert(ctx, service, t)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) AddDelivery(ctx context.Context, d AlertDelivery) error {
+	start := time.Now()
+	err := db.StorageBackend.AddDelivery(ctx, d)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) SaveMaintenance(ctx context.Context, sm ScheduledMaintenance) (ScheduledMaintenance, error) {
+	start := time.Now()
+	sm, err := db.StorageBackend.SaveMaintenance(ctx, sm)
+	db.health.observe(start, err)
+	return sm, err
+}
+
+func (db *healthStorage) RemoveMaintenance(ctx context.Context, id string) error {
+	start := time.Now()
+	err := db.StorageBackend.RemoveMaintenance(ctx, id)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) SaveNotifier(ctx context.Context, mn ManagedNotifier) (ManagedNotifier, error) {
+	start := time.Now()
+	mn, err := db.StorageBackend.SaveNotifier(ctx, mn)
+	db.health.observe(start, err)
+	return mn, err
+}
+
+func (db *healthStorage) RemoveNotifier(ctx context.Context, id string) error {
+	start := time.Now()
+	err := db.StorageBackend.RemoveNotifier(ctx, id)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) SaveManagedService(ctx context.Context, ms ManagedService) (ManagedService, error) {
+	start := time.Now()
+	ms, err := db.StorageBackend.SaveManagedService(ctx, ms)
+	db.health.observe(start, err)
+	return ms, err
+}
+
+func (db *healthStorage) RemoveManagedService(ctx context.Context, id string) error {
+	start := time.Now()
+	err := db.StorageBackend.RemoveManagedService(ctx, id)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) SaveAPIKey(ctx context.Context, k APIKey) (APIKey, error) {
+	start := time.Now()
+	k, err := db.StorageBackend.SaveAPIKey(ctx, k)
+	db.health.observe(start, err)
+	return k, err
+}
+
+func (db *healthStorage) RemoveAPIKey(ctx context.Context, id string) error {
+	start := time.Now()
+	err := db.StorageBackend.RemoveAPIKey(ctx, id)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) SaveSubscriber(ctx context.Context, s Subscriber) (Subscriber, error) {
+	start := time.Now()
+	s, err := db.StorageBackend.SaveSubscriber(ctx, s)
+	db.health.observe(start, err)
+	return s, err
+}
+
+func (db *healthStorage) RemoveSubscriber(ctx context.Context, id string) error {
+	start := time.Now()
+	err := db.StorageBackend.RemoveSubscriber(ctx, id)
+	db.health.observe(start, err)
+	return err
+}
+
+func (db *healthStorage) Prune(ctx context.Context, r Retention, now time.Time) (int, error) {
+	start := time.Now()
+	n, err := db.StorageBackend.Prune(ctx, r, now)
+	db.health.observe(start, err)
+	if err == nil {
+		db.health.mu.Lock()
+		db.health.stats.LastPrune, db.health.stats.Pruned = now, n
+		db.health.mu.Unlock()
+	}
+	return n, err
+}
+
+func (db *healthStorage) Restore(ctx context.Context, r io.Reader) error {
+	start := time.Now()
+	err := db.StorageBackend.Restore(ctx, r)
+	db.health.observe(start, err)
+	return err
+}
+
+// writeError returns err unless it rejects the write itself, such as
+// a missing incident, rather than reporting a failing storage
+func writeError(err error) error {
+	switch err {
+	case ErrIncidentNotFound, ErrIncidentResolved, ErrEmptyUpdate, ErrInvalidIncident, ErrNotManual,
+		ErrMaintenanceNotFound, ErrNotifierNotFound, ErrManagedServiceNotFound, ErrSubscriberNotFound,
+		ErrAPIKeyNotFound:
+		return nil
+	}
+	return err
+}
+
+// StoragePinger checks the health of the storage as a service named
+// "storage", so a failing storage shows on the page and is alerted on
+type StoragePinger struct {
+	Service
+	health *StorageHealth
+}
+
+// NewStoragePinger returns a StoragePinger of h
+func NewStoragePinger(h *StorageHealth) *StoragePinger {
+	return &StoragePinger{Service: Service{Name: "storage", Type: "storage"}, health: h}
+}
+
+// GetService returns the storage service
+func (s *StoragePinger) GetService() *Service {
+	return &s.Service
+}
+
+// Status returns the health of the storage
+func (s *StoragePinger) Status() error {
+	return s.health.Check(time.Now())
+}
+
+// MetricsHandler is a HandlerFunc which serves the health of the
+// storage in the Prometheus text format (GET /metrics)
+func MetricsHandler(h *StorageHealth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		stats := h.Stats()
+		up := 1
+		if h.Check(time.Now()) != nil {
+			up = 0
+		}
+		var lastPrune float64
+		if !stats.LastPrune.IsZero() {
+			lastPrune = float64(stats.LastPrune.UnixNano()) / 1e9
+		}
+
+		var b strings.Builder
+		for _, m := range []struct {
+			name, typ, help string
+			value           float64
+		}{
+			{"service_status_storage_healthy", "gauge", "Whether the storage is healthy.", float64(up)},
+			{"service_status_storage_size_bytes", "gauge", "Size of the storage.", float64(stats.Size)},
+			{"service_status_storage_writes_total", "counter", "Writes to the storage.", float64(stats.Writes)},
+			{"service_status_storage_failed_writes_total", "counter", "Writes to the storage which failed.", float64(stats.FailedWrites)},
+			{"service_status_storage_write_latency_seconds", "gauge", "Average latency of the latest writes.", stats.WriteLatency.Seconds()},
+			{"service_status_storage_last_prune_timestamp_seconds", "gauge", "When the storage was last pruned.", lastPrune},
+			{"service_status_storage_pruned_records", "gauge", "Records removed by the last prune.", float64(stats.Pruned)},
+		} {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", m.name, m.help, m.name, m.typ, m.name, m.value)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		io.WriteString(w, b.String())
+	}
+}
@@ -0,0 +1,111 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingStorage is a StorageBackend whose status history cannot be
+// written while err is set
+type failingStorage struct {
+	*Storage
+	err error
+}
+
+func (db *failingStorage) RecordStatus(ctx context.Context, records ...StatusRecord) error {
+	if db.err != nil {
+		return db.err
+	}
+	return db.Storage.RecordStatus(ctx, records...)
+}
+
+func TestWithHealth(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, err := OpenStorage(filepath.Join(dir, "status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := &failingStorage{Storage: file}
+	health := NewStorageHealth()
+	db := WithHealth(failing, health)
+	pinger := NewStoragePinger(health)
+
+	now := time.Now()
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: now})
+	// rejecting a write does not make the storage unhealthy
+	if _, err := db.CloseIncident(ctx, "missing", now); err != ErrIncidentNotFound {
+		t.Fatalf("expected ErrIncidentNotFound got %v", err)
+	}
+	if err := pinger.Status(); err != nil {
+		t.Errorf("expected a healthy storage got %v", err)
+	}
+
+	failing.err = errors.New("disk full")
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: now})
+	if err := pinger.Status(); !errors.Is(err, ErrStorageWrite) {
+		t.Errorf("expected ErrStorageWrite got %v", err)
+	}
+	stats := health.Stats()
+	if stats.Writes != 3 || stats.FailedWrites != 1 || stats.LastError != "disk full" || stats.Size == 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// a later write recovers the storage, and an overdue prune
+	// degrades it
+	failing.err = nil
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: now})
+	if err := health.Check(now.Add(pruneOverdue + time.Minute)); !IsDegraded(err) {
+		t.Errorf("expected a degraded storage got %v", err)
+	}
+	if _, err := db.Tenant("acme").Prune(ctx, Retention{Status: time.Hour}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := health.Check(now.Add(pruneOverdue - time.Minute)); err != nil {
+		t.Errorf("expected a healthy storage got %v", err)
+	}
+	if health.Stats().LastPrune != now {
+		t.Errorf("expected the prune of the tenant to be recorded")
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	mem, _ := OpenStorage("")
+	health := NewStorageHealth()
+	db := WithHealth(mem, health)
+	db.RecordStatus(context.Background(), StatusRecord{Service: "api", State: StateUp, Time: time.Now()})
+
+	w := httptest.NewRecorder()
+	MetricsHandler(health)(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+	for _, line := range []string{
+		"service_status_storage_healthy 1\n",
+		"service_status_storage_writes_total 1\n",
+		"service_status_storage_failed_writes_total 0\n",
+		"# TYPE service_status_storage_size_bytes gauge\n",
+	} {
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("expected %q in %s", line, w.Body)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	MetricsHandler(health)(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 got %d", w.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package status
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the heartbeat check
+var (
+	ErrHeartbeatMissed = errors.New("commands: heartbeat missed")
+	ErrInvalidGrace    = errors.New("commands: invalid heartbeat grace period")
+)
+
+// defaultGrace is the grace period used when a heartbeat service
+// does not configure one
+const defaultGrace = 5 * time.Minute
+
+// Heartbeats is the store used by heartbeat services created
+// through the HeartbeatFactory
+var Heartbeats = NewHeartbeatStore()
+
+// HeartbeatStore records the last time a heartbeat was received
+// for each registered token
+type HeartbeatStore struct {
+	mu   sync.RWMutex
+	seen map[string]time.Time
+}
+
+// NewHeartbeatStore returns an empty HeartbeatStore
+func NewHeartbeatStore() *HeartbeatStore {
+	return &HeartbeatStore{seen: make(map[string]time.Time)}
+}
+
+// Register adds a token to the store. The registration time counts as
+// the first heartbeat so a freshly started service is not reported down
+// before the monitored system has had a chance to check in.
+func (hs *HeartbeatStore) Register(token string) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if _, ok := hs.seen[token]; !ok {
+		hs.seen[token] = time.Now()
+	}
+}
+
+// Beat records a heartbeat for token. It returns false if the
+// token has not been registered.
+func (hs *HeartbeatStore) Beat(token string) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if _, ok := hs.seen[token]; !ok {
+		return false
+	}
+	hs.seen[token] = time.Now()
+	return true
+}
+
+// LastSeen returns the time of the last heartbeat for token
+func (hs *HeartbeatStore) LastSeen(token string) (time.Time, bool) {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	t, ok := hs.seen[token]
+	return t, ok
+}
+
+// HeartbeatHandler is a HandlerFunc which records heartbeats POSTed
+// to /api/heartbeat/{token}
+func HeartbeatHandler(hs *HeartbeatStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := strings.TrimPrefix(r.URL.Path, "/api/heartbeat/")
+		if token == "" || !hs.Beat(token) {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Heartbeat is a passive check which is down when the monitored
+// system has not checked in within the grace period
+type Heartbeat struct {
+	Service
+	grace time.Duration
+	store *HeartbeatStore
+}
+
+// GetService return the Service pointer
+func (h *Heartbeat) GetService() *Service {
+	return &h.Service
+}
+
+// Status checks the last heartbeat arrived within the grace period
+func (h *Heartbeat) Status() error {
+	last, ok := h.store.LastSeen(h.Token)
+	if !ok || time.Since(last) > h.grace {
+		return ErrHeartbeatMissed
+	}
+	return nil
+}
+
+// HeartbeatFactory implements the PingerFactory
+// interface
+type HeartbeatFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *HeartbeatFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "heartbeat" {
+		return nil, ErrInvalidCreate
+	}
+
+	grace := defaultGrace
+	if s.Grace != "" {
+		d, err := time.ParseDuration(s.Grace)
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidGrace
+		}
+		grace = d
+	}
+
+	Heartbeats.Register(s.Token)
+	return &Heartbeat{
+		Service: s,
+		grace:   grace,
+		store:   Heartbeats,
+	}, nil
+}
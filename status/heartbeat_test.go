@@ -0,0 +1,83 @@
+package status
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatStatus(t *testing.T) {
+	hs := NewHeartbeatStore()
+	hs.Register("abc")
+
+	h := Heartbeat{Service: Service{Token: "abc"}, grace: time.Minute, store: hs}
+	if err := h.Status(); err != nil {
+		t.Errorf("expected nil got %v", err)
+	}
+
+	hs.seen["abc"] = time.Now().Add(-2 * time.Minute)
+	if err := h.Status(); err != ErrHeartbeatMissed {
+		t.Errorf("expected %v got %v", ErrHeartbeatMissed, err)
+	}
+
+	hs.Beat("abc")
+	if err := h.Status(); err != nil {
+		t.Errorf("expected nil got %v", err)
+	}
+}
+
+func TestHeartbeatUnregistered(t *testing.T) {
+	h := Heartbeat{Service: Service{Token: "abc"}, grace: time.Minute, store: NewHeartbeatStore()}
+	if err := h.Status(); err != ErrHeartbeatMissed {
+		t.Errorf("expected %v got %v", ErrHeartbeatMissed, err)
+	}
+}
+
+func TestHeartbeatHandler(t *testing.T) {
+	hs := NewHeartbeatStore()
+	hs.Register("abc")
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		code   int
+	}{
+		{name: "beat", method: http.MethodPost, path: "/api/heartbeat/abc", code: http.StatusNoContent},
+		{name: "unknown token", method: http.MethodPost, path: "/api/heartbeat/xyz", code: http.StatusNotFound},
+		{name: "missing token", method: http.MethodPost, path: "/api/heartbeat/", code: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodGet, path: "/api/heartbeat/abc", code: http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HeartbeatHandler(hs)(w, httptest.NewRequest(tc.method, tc.path, nil))
+			if w.Code != tc.code {
+				t.Errorf("expected %d got %d", tc.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestHeartbeatFactoryCreate(t *testing.T) {
+	f := HeartbeatFactory{}
+	p, err := f.Create(Service{Type: "heartbeat", Token: "factory", Grace: "30s"})
+	if err != nil {
+		t.Fatalf("failed create with error: %v", err)
+	}
+	if p.(*Heartbeat).grace != 30*time.Second {
+		t.Errorf("expected 30s got %v", p.(*Heartbeat).grace)
+	}
+	if _, ok := Heartbeats.LastSeen("factory"); !ok {
+		t.Error("expected token to be registered")
+	}
+
+	if _, err := f.Create(Service{Type: "heartbeat", Token: "factory", Grace: "soon"}); err != ErrInvalidGrace {
+		t.Errorf("expected %v got %v", ErrInvalidGrace, err)
+	}
+	if _, err := f.Create(Service{Type: "ping"}); err != ErrInvalidCreate {
+		t.Errorf("expected %v got %v", ErrInvalidCreate, err)
+	}
+}
@@ -0,0 +1,363 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StatusRecord is the outcome of a check of a service kept in
+// the history
+type StatusRecord struct {
+	Service string    `json:"service"`
+	State   State     `json:"state"`
+	Time    time.Time `json:"time"`
+	// ResponseTime is how long the check took, and Timings its
+	// breakdown for the checks which record one
+	ResponseTime time.Duration `json:"response_time"`
+	Timings      *Timings      `json:"timings,omitempty"`
+}
+
+// ResponseTimeHistory returns the checks of a service between start
+// and end which measured its response time, oldest first. Checks of
+// a service which was down or not checked are left out.
+func ResponseTimeHistory(ctx context.Context, db StorageBackend, service string, start, end time.Time) ([]StatusRecord, error) {
+	records, err := db.StatusHistory(ctx, service, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var history []StatusRecord
+	for _, r := range records {
+		if r.State == StateUp || r.State == StateDegraded {
+			history = append(history, r)
+		}
+	}
+	return history, nil
+}
+
+// ResponseTimePercentiles returns the response times of a service
+// between start and end at each of the percentiles, such as 50, 95
+// and 99. They are zero when no response time was measured.
+func ResponseTimePercentiles(ctx context.Context, db StorageBackend, service string, start, end time.Time, percentiles ...float64) ([]time.Duration, error) {
+	history, err := ResponseTimeHistory(ctx, db, service, start, end)
+	if err != nil {
+		return nil, err
+	}
+	times := make([]time.Duration, len(history))
+	for i, r := range history {
+		times[i] = r.ResponseTime
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return nearestRanks(times, percentiles...), nil
+}
+
+// nearestRanks returns the sorted times at each of the percentiles,
+// by nearest rank. They are zero when there are no times.
+func nearestRanks(sorted []time.Duration, percentiles ...float64) []time.Duration {
+	values := make([]time.Duration, len(percentiles))
+	if len(sorted) == 0 {
+		return values
+	}
+	for i, p := range percentiles {
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		if rank > len(sorted) {
+			rank = len(sorted)
+		}
+		values[i] = sorted[rank-1]
+	}
+	return values
+}
+
+// UptimeWindow is a period uptime is reported over, ending now
+type UptimeWindow struct {
+	Name   string
+	Period time.Duration
+}
+
+// UptimeWindows are the periods the uptime of services is
+// reported over, shortest first
+var UptimeWindows = []UptimeWindow{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+	{"90d", 90 * 24 * time.Hour},
+}
+
+// WindowUptime is the percentage of a window a service was not down
+type WindowUptime struct {
+	Window  string  `json:"window"`
+	Percent float64 `json:"percent"`
+}
+
+// ServiceUptime returns the uptime of a service over each of the
+// UptimeWindows ending at end, from its incidents. Windows are counted
+// from the first check of the service in the history, so a service is
+// not credited with uptime from before it was monitored, and there is
+// none without checks.
+func ServiceUptime(ctx context.Context, db StorageBackend, service string, end time.Time) ([]WindowUptime, error) {
+	uptimes, err := servicesUptime(ctx, db, service, end)
+	return uptimes[service], err
+}
+
+// servicesUptime returns the uptime over the UptimeWindows ending at
+// end of a service, or of every service in the history when empty,
+// reading the history and the incidents once
+func servicesUptime(ctx context.Context, db StorageBackend, service string, end time.Time) (map[string][]WindowUptime, error) {
+	return windowsUptime(ctx, db, service, UptimeWindows, end)
+}
+
+// windowsUptime returns the uptime over windows, shortest first,
+// ending at end of a service, or of every service in the history
+// when empty. The first checks are looked up over the UptimeWindows
+// at least, so short windows are not counted from within them.
+func windowsUptime(ctx context.Context, db StorageBackend, service string, windows []UptimeWindow, end time.Time) (map[string][]WindowUptime, error) {
+	period := windows[len(windows)-1].Period
+	if p := UptimeWindows[len(UptimeWindows)-1].Period; p > period {
+		period = p
+	}
+	longest := end.Add(-period)
+	history, err := db.StatusHistory(ctx, service, longest, end)
+	if err != nil || len(history) == 0 {
+		return nil, err
+	}
+	incidents, err := db.IncidentsBetween(ctx, longest, end)
+	if err != nil {
+		return nil, err
+	}
+
+	first := make(map[string]time.Time)
+	for _, r := range history {
+		if _, ok := first[r.Service]; !ok {
+			first[r.Service] = r.Time
+		}
+	}
+	uptimes := make(map[string][]WindowUptime, len(first))
+	for s, from := range first {
+		u := make([]WindowUptime, len(windows))
+		for i, w := range windows {
+			start := end.Add(-w.Period)
+			if start.Before(from) {
+				start = from
+			}
+			u[i] = WindowUptime{Window: w.Name, Percent: uptime(incidents, s, start, end)}
+		}
+		uptimes[s] = u
+	}
+	return uptimes, nil
+}
+
+// statusWriterBacklog is how many batches a StatusWriter keeps
+// buffered while its storage cannot be written, before it drops the
+// oldest records
+const statusWriterBacklog = 10
+
+// StatusWriter buffers status records and writes them to a storage
+// in batches, so checking many services often writes the history once
+// a batch rather than on every sweep. Flush it before closing the
+// storage, or the records still buffered are lost.
+type StatusWriter struct {
+	db  StorageBackend
+	max int
+
+	mu  sync.Mutex
+	buf []StatusRecord
+}
+
+// NewStatusWriter returns a StatusWriter to db which writes once
+// max records are buffered
+func NewStatusWriter(db StorageBackend, max int) *StatusWriter {
+	return &StatusWriter{db: db, max: max}
+}
+
+// RecordStatus buffers records, writing the batch once it is full.
+// While the storage cannot be written it keeps statusWriterBacklog
+// batches at most, dropping the oldest records.
+func (w *StatusWriter) RecordStatus(ctx context.Context, records ...StatusRecord) error {
+	w.mu.Lock()
+	w.buf = append(w.buf, records...)
+	limit := w.max * statusWriterBacklog
+	if limit < statusWriterBacklog {
+		limit = statusWriterBacklog
+	}
+	if over := len(w.buf) - limit; over > 0 {
+		log.Printf("status history backlog full, dropped %d records", over)
+		w.buf = append([]StatusRecord(nil), w.buf[over:]...)
+	}
+	full := len(w.buf) >= w.max
+	w.mu.Unlock()
+	if !full {
+		return nil
+	}
+	return w.Flush(ctx)
+}
+
+// Flush writes the buffered records in one batch. When it fails
+// they stay buffered for the next flush.
+func (w *StatusWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if err := w.db.RecordStatus(ctx, w.buf...); err != nil {
+		return err
+	}
+	w.buf = nil
+	return nil
+}
+
+// writerHistory is a storage whose status history includes the
+// records its StatusWriter still buffers
+type writerHistory struct {
+	StorageBackend
+	w *StatusWriter
+}
+
+// StatusHistory returns the checks of a service, or of every service
+// when empty, between start and end, oldest first, written or not
+func (h writerHistory) StatusHistory(ctx context.Context, service string, start, end time.Time) ([]StatusRecord, error) {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	history, err := h.StorageBackend.StatusHistory(ctx, service, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range h.w.buf {
+		if (service == "" || r.Service == service) && !r.Time.Before(start) && r.Time.Before(end) {
+			history = append(history, r)
+		}
+	}
+	return history, nil
+}
+
+// Run flushes the buffered records every interval. It does not
+// return.
+func (w *StatusWriter) Run(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		ctx, cancel := storageContext()
+		if err := w.Flush(ctx); err != nil {
+			log.Printf("flush status history: %v", err)
+		}
+		cancel()
+	}
+}
+
+// HistoryList is a page of the status history, newest first
+type HistoryList struct {
+	Pagination
+	History []StatusRecord `json:"history"`
+}
+
+// HistoryHandler is a HandlerFunc which lists the status history
+// (GET /api/history), newest first, a page and size at a time. The
+// service query parameter filters it, and start and end, RFC 3339
+// times, limit the range, which is everything until now by default.
+func HistoryHandler(db StorageBackend) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		writeHistory(w, r, db, r.URL.Query().Get("service"))
+	}
+}
+
+// writeHistory answers a page of the status history of a service, or
+// of every service when empty, between the start and end parameters
+func writeHistory(w http.ResponseWriter, r *http.Request, db StorageBackend, service string) {
+	q := r.URL.Query()
+	p, err := parsePagination(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, end := time.Time{}, time.Now()
+	for _, v := range []struct {
+		name string
+		t    *time.Time
+	}{{"start", &start}, {"end", &end}} {
+		if s := q.Get(v.name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				http.Error(w, "invalid "+v.name, http.StatusBadRequest)
+				return
+			}
+			*v.t = t
+		}
+	}
+
+	ctx, cancel := requestContext(r)
+	defer cancel()
+	list := HistoryList{Pagination: p}
+	list.History, list.Total, err = db.StatusHistoryPage(ctx, service, start, end, p.offset(), p.Size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// UptimeList is the uptime of the services over a window
+type UptimeList struct {
+	Window   string                 `json:"window"`
+	Services []ServiceUptimePercent `json:"services"`
+}
+
+// ServiceUptimePercent is the percentage of a window a service was
+// not down
+type ServiceUptimePercent struct {
+	Service string  `json:"service"`
+	Percent float64 `json:"percent"`
+}
+
+// UptimeHandler is a HandlerFunc which lists the uptime of the
+// services over the window ending now, such as 30d or 12h, 30d by
+// default, sorted by service (GET /api/uptime?window=30d). The
+// service parameter selects one. Services are counted from their
+// first check in the history, and those without any are left out.
+func UptimeHandler(db StorageBackend) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		window := q.Get("window")
+		if window == "" {
+			window = "30d"
+		}
+		period, err := parseRetention(window)
+		if err != nil {
+			http.Error(w, "invalid window", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := requestContext(r)
+		defer cancel()
+		uptimes, err := windowsUptime(ctx, db, q.Get("service"), []UptimeWindow{{window, period}}, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		list := UptimeList{Window: window, Services: []ServiceUptimePercent{}}
+		for s, u := range uptimes {
+			list.Services = append(list.Services, ServiceUptimePercent{Service: s, Percent: u[0].Percent})
+		}
+		sort.Slice(list.Services, func(i, j int) bool { return list.Services[i].Service < list.Services[j].Service })
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
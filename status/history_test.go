@@ -0,0 +1,243 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseTimes(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	start := time.Now()
+	var records []StatusRecord
+	for i := 1; i <= 10; i++ {
+		records = append(records, StatusRecord{Service: "api", State: StateUp, Time: start.Add(time.Duration(i) * time.Minute), ResponseTime: time.Duration(i) * time.Millisecond})
+	}
+	records = append(records,
+		StatusRecord{Service: "api", State: StateDown, Time: start.Add(11 * time.Minute), ResponseTime: 10 * time.Second},
+		StatusRecord{Service: "db", State: StateUp, Time: start.Add(time.Minute), ResponseTime: time.Second},
+	)
+	db.RecordStatus(ctx, records...)
+
+	if h, _ := ResponseTimeHistory(ctx, db, "api", start, start.Add(time.Hour)); len(h) != 10 {
+		t.Errorf("expected 10 response times got %d", len(h))
+	}
+	if h, _ := ResponseTimeHistory(ctx, db, "api", start, start.Add(5*time.Minute)); len(h) != 4 {
+		t.Errorf("expected 4 response times got %d", len(h))
+	}
+
+	tt := []struct {
+		percentile float64
+		expected   time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 5 * time.Millisecond},
+		{95, 10 * time.Millisecond},
+		{100, 10 * time.Millisecond},
+	}
+	for _, tc := range tt {
+		p, _ := ResponseTimePercentiles(ctx, db, "api", start, start.Add(time.Hour), tc.percentile)
+		if p[0] != tc.expected {
+			t.Errorf("expected p%v %v got %v", tc.percentile, tc.expected, p[0])
+		}
+	}
+	if p, _ := ResponseTimePercentiles(ctx, db, "web", start, start.Add(time.Hour), 50); p[0] != 0 {
+		t.Errorf("expected no response time got %v", p[0])
+	}
+}
+
+func TestMonitorHistory(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	m := NewMonitor([]Pinger{&fakePinger{Service: Service{Name: "api"}}}, nil)
+	m.History = db
+	start := time.Now()
+
+	m.CheckAllServices()
+	history, _ := db.StatusHistory(ctx, "api", start, time.Now().Add(time.Second))
+	if len(history) != 1 || history[0].State != StateUp {
+		t.Errorf("expected the check recorded got %+v", history)
+	}
+}
+
+func TestStatusWriter(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	w := NewStatusWriter(db, 3)
+	start := time.Now().Add(-time.Minute)
+	count := func() int {
+		h, _ := db.StatusHistory(ctx, "", start, time.Now())
+		return len(h)
+	}
+
+	w.RecordStatus(ctx, StatusRecord{Service: "api", Time: start}, StatusRecord{Service: "db", Time: start})
+	if n := count(); n != 0 {
+		t.Errorf("expected the records buffered got %d written", n)
+	}
+	w.RecordStatus(ctx, StatusRecord{Service: "api", Time: start.Add(time.Second)})
+	if n := count(); n != 3 {
+		t.Errorf("expected the full batch written got %d", n)
+	}
+	w.RecordStatus(ctx, StatusRecord{Service: "api", Time: start.Add(2 * time.Second)})
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 4 {
+		t.Errorf("expected the flushed record written got %d", n)
+	}
+}
+
+func TestStatusWriterBacklog(t *testing.T) {
+	ctx := context.Background()
+	mem, _ := OpenStorage("")
+	db := &failingStorage{Storage: mem, err: errors.New("disk full")}
+	w := NewStatusWriter(db, 2)
+	start := time.Now().Add(-time.Hour)
+
+	for i := 0; i < 3*statusWriterBacklog; i++ {
+		w.RecordStatus(ctx, StatusRecord{Service: "api", Time: start.Add(time.Duration(i) * time.Second)})
+	}
+	if n := len(w.buf); n != 2*statusWriterBacklog {
+		t.Fatalf("expected the backlog capped at %d got %d", 2*statusWriterBacklog, n)
+	}
+	if first := w.buf[0].Time; !first.Equal(start.Add(statusWriterBacklog * time.Second)) {
+		t.Errorf("expected the oldest records dropped got %v first", first)
+	}
+
+	db.err = nil
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := mem.StatusHistory(ctx, "", start, time.Now()); len(h) != 2*statusWriterBacklog {
+		t.Errorf("expected the backlog written got %d", len(h))
+	}
+}
+
+func TestMonitorBufferedUptime(t *testing.T) {
+	db, _ := OpenStorage("")
+	m := NewMonitor([]Pinger{&fakePinger{Service: Service{Name: "api"}}}, nil)
+	m.History = db
+	m.HistoryWriter = NewStatusWriter(db, 100)
+
+	m.CheckAllServices()
+	time.Sleep(time.Millisecond)
+	m.CheckAllServices()
+	r := m.Results()
+	if len(r) != 1 || len(r[0].Uptime) == 0 || r[0].Uptime[0].Percent != 100 {
+		t.Errorf("expected the uptime of the buffered checks got %+v", r)
+	}
+	if len(r[0].Days) != UptimeDays || r[0].Days[UptimeDays-1].Checks != 1 {
+		t.Errorf("expected the buffered check in the day bars got %+v", r[0].Days)
+	}
+}
+
+func TestServiceUptime(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	end := time.Now()
+
+	if u, _ := ServiceUptime(ctx, db, "api", end); u != nil {
+		t.Errorf("expected no uptime without checks got %v", u)
+	}
+
+	// monitored for two days, down for the last 2h24m of them
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: end.Add(-48 * time.Hour)})
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", end.Add(-144*time.Minute))
+	db.ResolveIncident(ctx, "api", end)
+
+	expected := []WindowUptime{{"24h", 90}, {"7d", 95}, {"30d", 95}, {"90d", 95}}
+	u, _ := ServiceUptime(ctx, db, "api", end)
+	if len(u) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, u)
+	}
+	for i := range u {
+		if u[i].Window != expected[i].Window || math.Abs(u[i].Percent-expected[i].Percent) > 0.001 {
+			t.Errorf("expected %v got %v", expected[i], u[i])
+		}
+	}
+}
+
+func TestUptimeHandler(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Now()
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: now.Add(-48 * time.Hour)})
+	db.RecordStatus(ctx, StatusRecord{Service: "db", State: StateUp, Time: now.Add(-48 * time.Hour)})
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", now.Add(-144*time.Minute))
+	db.ResolveIncident(ctx, "api", now)
+
+	w := httptest.NewRecorder()
+	UptimeHandler(db)(w, httptest.NewRequest(http.MethodGet, "/api/uptime?window=24h", nil))
+	var list UptimeList
+	json.NewDecoder(w.Body).Decode(&list)
+	if list.Window != "24h" || len(list.Services) != 2 || list.Services[0].Service != "api" ||
+		math.Abs(list.Services[0].Percent-90) > 0.01 || list.Services[1].Percent != 100 {
+		t.Errorf("unexpected uptime %+v", list)
+	}
+
+	w = httptest.NewRecorder()
+	UptimeHandler(db)(w, httptest.NewRequest(http.MethodGet, "/api/uptime?window=soon", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestHistoryHandler(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: now.Add(time.Duration(i-5) * time.Minute)})
+	}
+	db.RecordStatus(ctx, StatusRecord{Service: "db", State: StateUp, Time: now.Add(-time.Minute)})
+	handler := HistoryHandler(db)
+
+	tt := []struct {
+		name     string
+		query    string
+		code     int
+		total    int
+		expected []time.Time
+	}{
+		{"first page", "?service=api&size=2", http.StatusOK, 5, []time.Time{now.Add(-time.Minute), now.Add(-2 * time.Minute)}},
+		{"last page", "?service=api&size=2&page=3", http.StatusOK, 5, []time.Time{now.Add(-5 * time.Minute)}},
+		{"past the end", "?service=api&size=2&page=4", http.StatusOK, 5, nil},
+		{"every service", "", http.StatusOK, 6, nil},
+		{"invalid page", "?page=0", http.StatusBadRequest, 0, nil},
+		{"invalid size", "?size=5000", http.StatusBadRequest, 0, nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodGet, "/api/history"+tc.query, nil))
+			if w.Code != tc.code {
+				t.Fatalf("expected %d got %d", tc.code, w.Code)
+			}
+			if tc.code != http.StatusOK {
+				return
+			}
+			var list HistoryList
+			json.NewDecoder(w.Body).Decode(&list)
+			if list.Total != tc.total {
+				t.Errorf("expected %d in all got %d", tc.total, list.Total)
+			}
+			if tc.expected == nil {
+				return
+			}
+			if len(list.History) != len(tc.expected) {
+				t.Fatalf("expected %d records got %+v", len(tc.expected), list.History)
+			}
+			for i, r := range list.History {
+				if !r.Time.Equal(tc.expected[i]) {
+					t.Errorf("expected %v got %v", tc.expected[i], r.Time)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package status
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Errors returned when building the HTTP client of a service
+var (
+	ErrInvalidProxy      = errors.New("commands: invalid proxy url")
+	ErrInvalidClientCert = errors.New("commands: invalid client certificate or key")
+	ErrInvalidCABundle   = errors.New("commands: invalid ca bundle")
+	ErrInvalidIPFamily   = errors.New("commands: ip_family must be v4, v6 or both")
+	ErrInvalidProtocol   = errors.New("commands: protocol must be http/1.1 or h2")
+	ErrProtocolMismatch  = errors.New("commands: unexpected protocol negotiated")
+	ErrTooManyRedirects  = errors.New("commands: too many redirects")
+	ErrFinalURLMismatch  = errors.New("commands: redirected to unexpected url")
+)
+
+// newHTTPClient returns an http.Client configured with the transport
+// options of s. Without a proxy_url the standard HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY environment variables are honoured.
+func newHTTPClient(s Service) (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if s.ProxyURL != "" {
+		u, err := url.Parse(s.ProxyURL)
+		if err != nil || u.Host == "" {
+			return nil, ErrInvalidProxy
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, ErrInvalidProxy
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	switch s.IPFamily {
+	case "", "both":
+	case "v4":
+		transport.DialContext = familyDialer("tcp4")
+	case "v6":
+		transport.DialContext = familyDialer("tcp6")
+	default:
+		return nil, ErrInvalidIPFamily
+	}
+
+	switch s.Protocol {
+	case "":
+	case "h2":
+		transport.ForceAttemptHTTP2 = true
+	case "http/1.1":
+		// a non-nil empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	default:
+		return nil, ErrInvalidProtocol
+	}
+
+	if s.ClientCert != "" || s.ClientKey != "" || s.CABundle != "" || s.InsecureSkipVerify {
+		tlsConfig, err := newTLSConfig(s)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport, CheckRedirect: redirectPolicy(s)}, nil
+}
+
+// redirectPolicy returns the CheckRedirect func for the
+// redirect options of s
+func redirectPolicy(s Service) func(*http.Request, []*http.Request) error {
+	if s.NoRedirects {
+		return func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	max := s.MaxRedirects
+	if max <= 0 {
+		max = 10
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return ErrTooManyRedirects
+		}
+		return nil
+	}
+}
+
+// familyDialer returns a DialContext func which only
+// connects over the given network, "tcp4" or "tcp6"
+func familyDialer(network string) func(context.Context, string, string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// newFamilyClients returns one client per IP family to probe. A
+// service with ip_family "both" gets an IPv4 and an IPv6 client.
+func newFamilyClients(s Service) (*http.Client, *http.Client, error) {
+	if s.IPFamily != "both" {
+		client, err := newHTTPClient(s)
+		return client, nil, err
+	}
+
+	s.IPFamily = "v4"
+	client, err := newHTTPClient(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.IPFamily = "v6"
+	client6, err := newHTTPClient(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, client6, nil
+}
+
+// bothFamilies combines the results of probing a service over IPv4
+// and IPv6, which is degraded when only one of them works
+func bothFamilies(err4, err6 error) error {
+	switch {
+	case err4 == nil && err6 == nil:
+		return nil
+	case err4 != nil && err6 != nil:
+		return err4
+	case err4 != nil:
+		return Degraded("IPv4 failed: " + err4.Error())
+	}
+	return Degraded("IPv6 failed: " + err6.Error())
+}
+
+// newTLSConfig returns the TLS config for a service with a client
+// certificate, a custom CA bundle or certificate verification disabled
+func newTLSConfig(s Service) (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: s.InsecureSkipVerify}
+
+	if s.ClientCert != "" || s.ClientKey != "" {
+		certPEM, err := readPEM(s.ClientCert)
+		if err != nil {
+			return nil, ErrInvalidClientCert
+		}
+		keyPEM, err := readPEM(s.ClientKey)
+		if err != nil {
+			return nil, ErrInvalidClientCert
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, ErrInvalidClientCert
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	if s.CABundle != "" {
+		caPEM, err := readPEM(s.CABundle)
+		if err != nil {
+			return nil, ErrInvalidCABundle
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, ErrInvalidCABundle
+		}
+		c.RootCAs = pool
+	}
+
+	return c, nil
+}
+
+// readPEM returns PEM data from a file path, or from an environment
+// variable when ref has the form "env:NAME"
+func readPEM(ref string) ([]byte, error) {
+	if strings.HasPrefix(ref, "env:") {
+		v := os.Getenv(strings.TrimPrefix(ref, "env:"))
+		if v == "" {
+			return nil, os.ErrNotExist
+		}
+		return []byte(v), nil
+	}
+	return ioutil.ReadFile(ref)
+}
+
+// HeaderError is returned when a response header does
+// not meet the expectations of a service
+type HeaderError struct {
+	Header   string
+	Expected string
+	// Actual is the received value, empty when missing
+	Actual string
+}
+
+func (e *HeaderError) Error() string {
+	if e.Actual == "" {
+		return fmt.Sprintf("commands: header %s missing", e.Header)
+	}
+	return fmt.Sprintf("commands: header %s is %q, expected %q", e.Header, e.Actual, e.Expected)
+}
+
+// checkHeaders asserts that each expected header is present and, when
+// the expectation is not empty, that its value matches it as a regex
+func checkHeaders(h http.Header, expect map[string]string) error {
+	for name, pattern := range expect {
+		actual, ok := h[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return &HeaderError{Header: name, Expected: pattern}
+		}
+		if pattern == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		value := strings.Join(actual, ", ")
+		if !re.MatchString(value) {
+			return &HeaderError{Header: name, Expected: pattern, Actual: value}
+		}
+	}
+	return nil
+}
+
+// checkProtocol checks resp was served over the expected
+// protocol, "http/1.1" or "h2"
+func checkProtocol(resp *http.Response, expected string) error {
+	switch {
+	case expected == "":
+	case expected == "h2" && resp.ProtoMajor != 2:
+		return ErrProtocolMismatch
+	case expected == "http/1.1" && resp.Proto != "HTTP/1.1":
+		return ErrProtocolMismatch
+	}
+	return nil
+}
+
+// checkFinalURL checks the URL resp was finally served from
+// matches pattern, catching e.g. a redirect to a login page
+func checkFinalURL(resp *http.Response, pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	if !re.MatchString(resp.Request.URL.String()) {
+		return ErrFinalURLMismatch
+	}
+	return nil
+}
+
+// clientOrDefault returns c, or the default client for checks
+// constructed without a factory
+func clientOrDefault(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
@@ -0,0 +1,328 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Errors returned when changing incidents
+var (
+	ErrEmptyUpdate     = errors.New("storage: incident update needs a message")
+	ErrInvalidIncident = errors.New("storage: incident needs a title and a down or degraded state")
+	ErrNotManual       = errors.New("storage: incidents of checks are resolved when the service recovers")
+	ErrIncidentFilter  = errors.New("storage: incidents are filtered by status ongoing or resolved and RFC 3339 start and end times")
+)
+
+// incidentHistory is how far back the page lists incidents
+const incidentHistory = 7 * 24 * time.Hour
+
+// IncidentUpdate is a note on the progress of an incident, such as
+// "investigating" or "fix deployed"
+type IncidentUpdate struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// IncidentList is a page of the incidents, newest first
+type IncidentList struct {
+	Pagination
+	Incidents []Incident `json:"incidents"`
+}
+
+// IncidentFilter selects the incidents of or affecting Service, in
+// State, ongoing or resolved when Ongoing is set, and ongoing at some
+// point between Start and End. Zero fields select every incident.
+type IncidentFilter struct {
+	Service    string
+	State      State
+	Ongoing    *bool
+	Start, End time.Time
+}
+
+// Matches reports whether the filter selects inc
+func (f IncidentFilter) Matches(inc Incident) bool {
+	switch {
+	case f.Service != "" && !affects(inc, f.Service):
+	case f.State != "" && inc.State != f.State:
+	case f.Ongoing != nil && inc.Ongoing() != *f.Ongoing:
+	case !f.End.IsZero() && !inc.Start.Before(f.End):
+	case !f.Start.IsZero() && !inc.Ongoing() && !inc.End.After(f.Start):
+	default:
+		return true
+	}
+	return false
+}
+
+// parseIncidentFilter returns the filter of the service, state,
+// status (ongoing or resolved) and start and end, RFC 3339 times,
+// query parameters
+func parseIncidentFilter(q url.Values) (IncidentFilter, error) {
+	f := IncidentFilter{Service: q.Get("service"), State: State(q.Get("state"))}
+	switch q.Get("status") {
+	case "":
+	case "ongoing", "resolved":
+		ongoing := q.Get("status") == "ongoing"
+		f.Ongoing = &ongoing
+	default:
+		return f, ErrIncidentFilter
+	}
+	for _, v := range []struct {
+		name string
+		t    *time.Time
+	}{{"start", &f.Start}, {"end", &f.End}} {
+		if s := q.Get(v.name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return f, ErrIncidentFilter
+			}
+			*v.t = t
+		}
+	}
+	return f, nil
+}
+
+// affects reports whether inc is an incident of service, or a manual
+// one affecting it
+func affects(inc Incident, service string) bool {
+	if inc.Service == service {
+		return true
+	}
+	for _, s := range inc.Services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// RecentIncidents returns the incidents ongoing at some point in the
+// week before now, newest first
+func RecentIncidents(ctx context.Context, db StorageBackend, now time.Time) ([]Incident, error) {
+	incidents, err := db.IncidentsBetween(ctx, now.Add(-incidentHistory), now)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(incidents, func(i, j int) bool { return incidents[i].Start.After(incidents[j].Start) })
+	return incidents, nil
+}
+
+// OpenIncident opens a manual incident, such as "elevated error
+// rates", affecting inc.Services and alerts the notifiers. Its state
+// is down unless degraded, its severity follows from the state unless
+// given.
+func (nm *NotificationManager) OpenIncident(ctx context.Context, inc Incident) (Incident, error) {
+	if inc.State == "" {
+		inc.State = StateDown
+	}
+	if strings.TrimSpace(inc.Title) == "" || (inc.State != StateDown && inc.State != StateDegraded) {
+		return Incident{}, ErrInvalidIncident
+	}
+	if err := inc.Severity.Validate(); err != nil {
+		return Incident{}, err
+	}
+	if inc.Severity == "" {
+		inc.Severity = stateSeverity(&Service{}, inc.State)
+	}
+	inc = Incident{
+		Title:    inc.Title,
+		Services: inc.Services,
+		State:    inc.State,
+		Severity: inc.Severity,
+		Message:  inc.Message,
+		Start:    time.Now(),
+	}
+
+	inc, err := nm.Storage.OpenIncident(ctx, inc)
+	if err != nil {
+		return Incident{}, err
+	}
+	a := incidentAlert(inc, AlertTypeDown)
+	if inc.State == StateDegraded {
+		a.Type = AlertTypeDegraded
+	}
+	nm.dispatch(a, nm.targets(0))
+	return inc, nil
+}
+
+// ResolveIncident resolves a manual incident and alerts the
+// notifiers of the recovery
+func (nm *NotificationManager) ResolveIncident(ctx context.Context, id string) (Incident, error) {
+	inc, ok, err := nm.Storage.Incident(ctx, id)
+	if err != nil {
+		return Incident{}, err
+	}
+	if !ok {
+		return Incident{}, ErrIncidentNotFound
+	}
+	if !inc.Manual {
+		return inc, ErrNotManual
+	}
+	if inc, err = nm.Storage.CloseIncident(ctx, id, time.Now()); err != nil {
+		return inc, err
+	}
+	a := incidentAlert(inc, AlertTypeRecovery)
+	a.Time, a.Since, a.Duration, a.Cause = inc.End, inc.Start, inc.End.Sub(inc.Start), inc.Message
+	nm.dispatch(a, nm.targets(0))
+	return inc, nil
+}
+
+// incidentAlert returns an alert about a manual incident, named
+// after its title
+func incidentAlert(inc Incident, typ AlertType) Alert {
+	a := Alert{
+		Type:     typ,
+		Severity: inc.Severity,
+		Service:  Service{Name: inc.Title},
+		Message:  inc.Message,
+		Time:     inc.Start,
+		Incident: inc.ID,
+	}
+	if len(inc.Services) > 0 {
+		a.Message = strings.TrimSpace("affects " + strings.Join(inc.Services, ", ") + "\n\n" + inc.Message)
+	}
+	return a
+}
+
+// IncidentHandler is a HandlerFunc which lists the incidents, newest
+// first, a page and size at a time (GET /api/incidents/?page=1&size=50),
+// filtered by the service, state, status, start and end parameters, opens a manual incident (POST /api/incidents/), shows an incident
+// (GET /api/incidents/{id}),
+// resolves a manual one (POST /api/incidents/{id}/resolve), adds an
+// update to one (POST /api/incidents/{id}/updates), acknowledges one
+// (POST /api/incidents/{id}/ack with who acknowledges it as "by") or
+// sets its postmortem (PUT /api/incidents/{id}/postmortem). Changes
+// need token, which may be "env:NAME", or an API key of the incidents
+// scope, or of the ack scope to acknowledge, as a bearer token;
+// without either they are disabled.
+func IncidentHandler(nm *NotificationManager, token string) http.HandlerFunc {
+	token = readSecret(token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/incidents/")
+		id, action := path, ""
+		if i := strings.Index(path, "/"); i >= 0 {
+			id, action = path[:i], path[i+1:]
+		}
+		scope := ScopeIncidents
+		if action == "ack" {
+			scope = ScopeAck
+		}
+		if r.Method != http.MethodGet && !granted(r, token, scope) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		db := nm.Storage
+		var inc Incident
+		var err error
+		code := http.StatusOK
+		switch {
+		case id == "" && r.Method == http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&inc); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			inc, err = nm.OpenIncident(ctx, inc)
+			code = http.StatusCreated
+		case id == "" && r.Method == http.MethodGet:
+			p, err := parsePagination(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			f, err := parseIncidentFilter(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			list := IncidentList{Pagination: p}
+			if list.Incidents, list.Total, err = db.IncidentsPage(ctx, f, p.offset(), p.Size); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list)
+			return
+		case id == "":
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		case r.Method == http.MethodGet && action == "":
+			var ok bool
+			inc, ok, err = db.Incident(ctx, id)
+			if err == nil && !ok {
+				err = ErrIncidentNotFound
+			}
+		case r.Method == http.MethodPost && action == "resolve":
+			inc, err = nm.ResolveIncident(ctx, id)
+		case r.Method == http.MethodPost && action == "updates":
+			var u IncidentUpdate
+			if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			u.Time = time.Now()
+			inc, err = db.AddIncidentUpdate(ctx, id, u)
+			code = http.StatusCreated
+		case r.Method == http.MethodPost && action == "ack":
+			var body struct {
+				By string `json:"by"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if body.By = strings.TrimSpace(body.By); body.By == "" {
+				http.Error(w, "incident: by is required", http.StatusBadRequest)
+				return
+			}
+			inc, err = nm.Acknowledge(ctx, id, body.By)
+		case r.Method == http.MethodPut && action == "postmortem":
+			var body struct {
+				Postmortem string `json:"postmortem"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			inc, err = db.SetPostmortem(ctx, id, body.Postmortem)
+		case action == "":
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		case action == "updates", action == "resolve", action == "ack":
+			w.Header().Set("Allow", "POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		case action == "postmortem":
+			w.Header().Set("Allow", "PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case err == ErrIncidentNotFound:
+			http.NotFound(w, r)
+		case err == ErrEmptyUpdate, err == ErrInvalidIncident, err == ErrInvalidSeverity:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err == ErrNotManual, err == ErrIncidentResolved:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(inc)
+		}
+	}
+}
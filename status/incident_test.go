@@ -0,0 +1,152 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncidentHandler(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", time.Now().Add(-time.Hour))
+	nm := NewNotificationManager(nil, 0)
+	nm.Storage = db
+	handler := IncidentHandler(nm, "s3cret")
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		expected int
+	}{
+		{"update", http.MethodPost, "/api/incidents/1/updates", "s3cret", `{"message": "investigating"}`, http.StatusCreated},
+		{"second update", http.MethodPost, "/api/incidents/1/updates", "s3cret", `{"message": "fix deployed"}`, http.StatusCreated},
+		{"postmortem", http.MethodPut, "/api/incidents/1/postmortem", "s3cret", `{"postmortem": "a bad deploy"}`, http.StatusOK},
+		{"unauthorized", http.MethodPost, "/api/incidents/1/updates", "wrong", `{"message": "x"}`, http.StatusUnauthorized},
+		{"empty update", http.MethodPost, "/api/incidents/1/updates", "s3cret", `{"message": " "}`, http.StatusBadRequest},
+		{"unknown incident", http.MethodPut, "/api/incidents/9/postmortem", "s3cret", `{"postmortem": "x"}`, http.StatusNotFound},
+		{"unknown action", http.MethodPost, "/api/incidents/1/notes", "s3cret", `{}`, http.StatusNotFound},
+		{"wrong method", http.MethodGet, "/api/incidents/1/updates", "", "", http.StatusMethodNotAllowed},
+		{"open", http.MethodPost, "/api/incidents/", "s3cret", `{"title": "Elevated error rates", "state": "degraded"}`, http.StatusCreated},
+		{"open without title", http.MethodPost, "/api/incidents/", "s3cret", `{"state": "down"}`, http.StatusBadRequest},
+		{"resolve check incident", http.MethodPost, "/api/incidents/1/resolve", "s3cret", "", http.StatusConflict},
+		{"resolve", http.MethodPost, "/api/incidents/2/resolve", "s3cret", "", http.StatusOK},
+		{"resolve twice", http.MethodPost, "/api/incidents/2/resolve", "s3cret", "", http.StatusConflict},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := do(tc.method, tc.path, tc.token, tc.body); w.Code != tc.expected {
+				t.Errorf("expected %d got %d: %s", tc.expected, w.Code, w.Body)
+			}
+		})
+	}
+
+	w := do(http.MethodGet, "/api/incidents/?size=1", "", "")
+	var list IncidentList
+	json.NewDecoder(w.Body).Decode(&list)
+	if list.Total != 2 || len(list.Incidents) != 1 || list.Incidents[0].ID != "2" {
+		t.Errorf("expected the newest of 2 incidents got %+v", list)
+	}
+	for query, expected := range map[string]string{
+		"?service=api":              "1",
+		"?status=ongoing":           "1",
+		"?state=degraded":           "2",
+		"?status=resolved&state=up": "",
+	} {
+		list = IncidentList{}
+		json.NewDecoder(do(http.MethodGet, "/api/incidents/"+query, "", "").Body).Decode(&list)
+		if expected == "" && list.Total != 0 || expected != "" && (list.Total != 1 || list.Incidents[0].ID != expected) {
+			t.Errorf("%s: expected incident %q got %+v", query, expected, list)
+		}
+	}
+	if w := do(http.MethodGet, "/api/incidents/?status=open", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = do(http.MethodGet, "/api/incidents/1", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	var inc Incident
+	json.NewDecoder(w.Body).Decode(&inc)
+	if len(inc.Updates) != 2 || inc.Updates[1].Message != "fix deployed" || inc.Postmortem != "a bad deploy" {
+		t.Errorf("expected the updates and postmortem got %+v", inc)
+	}
+}
+
+func TestManualIncident(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	nm.Storage = db
+
+	inc, err := nm.OpenIncident(ctx, Incident{Title: "Elevated error rates", Services: []string{"api", "web"}, Message: "5% of requests fail"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inc.Manual || inc.State != StateDown || inc.Severity != SeverityCritical {
+		t.Errorf("expected a critical manual incident got %+v", inc)
+	}
+	if len(rec.alerts) != 1 || rec.alerts[0].Title() != "Elevated error rates is down" || rec.alerts[0].Message != "affects api, web\n\n5% of requests fail" {
+		t.Fatalf("expected the incident alerted got %+v", rec.alerts)
+	}
+
+	// the incidents of checks are kept apart
+	nm.CheckAndNotify(&Service{Name: "api"}, false, "timeout")
+	if ongoing, ok, _ := db.OngoingIncident(ctx, "api"); !ok || ongoing.ID == inc.ID {
+		t.Errorf("expected a separate incident for the check got %+v", ongoing)
+	}
+
+	p := NewPage("status", nil)
+	p.SetIncidents([]Incident{inc})
+	if p.Status != "danger" {
+		t.Errorf("expected the page status raised got %s", p.Status)
+	}
+
+	if inc, err = nm.ResolveIncident(ctx, inc.ID); err != nil || inc.Ongoing() {
+		t.Fatalf("expected the incident resolved got %+v and %v", inc, err)
+	}
+	if a := rec.alerts[len(rec.alerts)-1]; a.Type != AlertTypeRecovery || a.Incident != inc.ID {
+		t.Errorf("expected the recovery alerted got %+v", a)
+	}
+	if _, err := nm.OpenIncident(ctx, Incident{Title: "x", State: StateMaintenance}); err != ErrInvalidIncident {
+		t.Errorf("expected %v got %v", ErrInvalidIncident, err)
+	}
+}
+
+func TestRecentIncidents(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Now()
+	db.StartIncident(ctx, "old", StateDown, SeverityCritical, "", now.Add(-10*24*time.Hour))
+	db.ResolveIncident(ctx, "old", now.Add(-9*24*time.Hour))
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "", now.Add(-2*time.Hour))
+	db.ResolveIncident(ctx, "api", now.Add(-time.Hour))
+	db.StartIncident(ctx, "db", StateDegraded, SeverityWarning, "", now.Add(-time.Minute))
+
+	incidents, err := RecentIncidents(ctx, db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 2 || incidents[0].Service != "db" || incidents[1].Service != "api" {
+		t.Errorf("expected the incidents of the last week newest first got %+v", incidents)
+	}
+}
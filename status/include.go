@@ -0,0 +1,227 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Errors returned reading a config split across files
+var (
+	ErrIncludedTwice    = errors.New("config: file included more than once")
+	ErrInvalidInclude   = errors.New("config: include must be a path or a list of paths")
+	ErrConflictingValue = errors.New("config: set by more than one file")
+	ErrNotObject        = errors.New("config: must be a JSON object")
+)
+
+// ReadConfig reads the JSON config at path and returns it with the
+// paths of the files it was read from. path is a file, or a directory
+// whose *.json files are fragments of the config, read in name order.
+// A file may include others with "include", a path or a list of paths
+// relative to it, each a file, a directory as above or a glob pattern.
+// The fragments are merged into one config: the lists, such as the
+// services, are concatenated in the order the files were read and the
+// objects are merged, but a setting may only be set by one file. The
+// fragments of the stores of "remote_config" are merged last.
+func ReadConfig(path string) ([]byte, []string, error) {
+	r := &configReader{seen: make(map[string]bool)}
+	if err := r.read(path); err != nil {
+		return nil, nil, err
+	}
+	if err := r.readRemote(context.Background()); err != nil {
+		return nil, nil, err
+	}
+	b, err := json.Marshal(r.config)
+	return b, r.files, err
+}
+
+// ConfigFiles returns the paths of the files the config at path is
+// read from, as ReadConfig, without fetching its remote fragments
+func ConfigFiles(path string) ([]string, error) {
+	r := &configReader{seen: make(map[string]bool)}
+	err := r.read(path)
+	return r.files, err
+}
+
+// configReader merges the fragments of a config
+type configReader struct {
+	config map[string]interface{}
+	files  []string
+	seen   map[string]bool
+}
+
+// readRemote merges the fragments of the remote config stores
+func (r *configReader) readRemote(ctx context.Context) error {
+	remotes, err := remoteSources(r.config["remote_config"])
+	if err != nil {
+		return err
+	}
+	for _, remote := range remotes {
+		fragments, err := remote.Fetch(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", remote, err)
+		}
+		for _, b := range fragments {
+			fragment, err := parseRemoteFragment(b)
+			if err != nil {
+				return fmt.Errorf("%s: %w", remote, err)
+			}
+			if err := mergeObject("", r.config, fragment); err != nil {
+				return fmt.Errorf("%s: %w", remote, err)
+			}
+		}
+	}
+	return nil
+}
+
+// read merges the config at path, a file or a directory
+func (r *configReader) read(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return r.readFile(path)
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if err := r.readFile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile merges the file at path, then the files it includes
+func (r *configReader) readFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if r.seen[abs] {
+		return fmt.Errorf("%s: %w", path, ErrIncludedTwice)
+	}
+	r.seen[abs] = true
+	r.files = append(r.files, path)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fragment map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&fragment); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if fragment == nil {
+		return fmt.Errorf("%s: %w", path, ErrNotObject)
+	}
+	includes, err := includePaths(fragment["include"])
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, &FieldError{Field: "include", Err: err})
+	}
+	delete(fragment, "include")
+
+	if r.config == nil {
+		r.config = fragment
+	} else if err := mergeObject("", r.config, fragment); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(dir, include)
+		}
+		if !strings.ContainsAny(include, "*?[") {
+			if err := r.read(include); err != nil {
+				return fmt.Errorf("%s: include: %w", path, err)
+			}
+			continue
+		}
+		// a pattern matching nothing is an empty directory of fragments
+		matches, err := filepath.Glob(include)
+		if err != nil {
+			return fmt.Errorf("%s: include: %w", path, err)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if err := r.read(m); err != nil {
+				return fmt.Errorf("%s: include: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// includePaths returns the paths of the include setting, a path or a
+// list of paths
+func includePaths(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		paths := make([]string, 0, len(v))
+		for _, p := range v {
+			s, ok := p.(string)
+			if !ok || s == "" {
+				return nil, ErrInvalidInclude
+			}
+			paths = append(paths, s)
+		}
+		return paths, nil
+	}
+	return nil, ErrInvalidInclude
+}
+
+// mergeObject merges the settings of from into config, the object at
+// path
+func mergeObject(path string, config, from map[string]interface{}) error {
+	keys := make([]string, 0, len(from))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field := k
+		if path != "" {
+			field = path + "." + k
+		}
+		v := from[k]
+		prev, ok := config[k]
+		if !ok {
+			config[k] = v
+			continue
+		}
+		switch prev := prev.(type) {
+		case []interface{}:
+			if list, ok := v.([]interface{}); ok {
+				config[k] = append(prev, list...)
+				continue
+			}
+		case map[string]interface{}:
+			if obj, ok := v.(map[string]interface{}); ok {
+				if err := mergeObject(field, prev, obj); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		return &FieldError{Field: field, Err: ErrConflictingValue}
+	}
+	return nil
+}
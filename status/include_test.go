@@ -0,0 +1,120 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeConfigFiles writes the files, by path relative to dir
+func writeConfigFiles(dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+	writeConfigFiles(dir, map[string]string{
+		"config.json":           `{"listen": ":8080", "include": ["conf.d", "teams/*.json"], "services": [{"name": "web"}], "theme": {"title": "Status"}}`,
+		"conf.d/10-db.json":     `{"services": [{"name": "db"}]}`,
+		"conf.d/20-alerts.json": `{"notifiers": [{"type": "log"}], "theme": {"logo": "logo.png"}}`,
+		"conf.d/notes.txt":      `not a fragment`,
+		"teams/api.json":        `{"services": [{"name": "api"}], "include": "../shared.json"}`,
+		"shared.json":           `{"services": [{"name": "dns"}]}`,
+	})
+
+	b, files, err := ReadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Listen    string           `json:"listen"`
+		Services  []Service        `json:"services"`
+		Notifiers []NotifierConfig `json:"notifiers"`
+		Theme     map[string]string
+	}
+	json.Unmarshal(b, &config)
+	var names []string
+	for _, s := range config.Services {
+		names = append(names, s.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"web", "db", "api", "dns"}) {
+		t.Errorf("expected the services of every file in order got %v", names)
+	}
+	if config.Listen != ":8080" || len(config.Notifiers) != 1 || len(config.Theme) != 2 {
+		t.Errorf("expected the settings merged got %+v", config)
+	}
+	if len(files) != 5 {
+		t.Errorf("expected the 5 files read got %v", files)
+	}
+
+	// a directory is read as a directory of fragments
+	if _, files, err := ReadConfig(filepath.Join(dir, "conf.d")); err != nil || len(files) != 2 {
+		t.Errorf("expected the 2 fragments of the directory got %v %v", files, err)
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		files    map[string]string
+		expected error
+	}{
+		"conflict": {map[string]string{
+			"config.json": `{"listen": ":8080", "include": "other.json"}`,
+			"other.json":  `{"listen": ":9090"}`,
+		}, ErrConflictingValue},
+		"cycle": {map[string]string{
+			"config.json": `{"include": "other.json"}`,
+			"other.json":  `{"include": "config.json"}`,
+		}, ErrIncludedTwice},
+		"invalid include": {map[string]string{
+			"config.json": `{"include": [1]}`,
+		}, ErrInvalidInclude},
+		"missing include": {map[string]string{
+			"config.json": `{"include": "missing.json"}`,
+		}, os.ErrNotExist},
+		"not an object": {map[string]string{
+			"config.json": `null`,
+		}, ErrNotObject},
+	} {
+		dir, _ := ioutil.TempDir("", "config")
+		writeConfigFiles(dir, tc.files)
+		_, _, err := ReadConfig(filepath.Join(dir, "config.json"))
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("%s: expected %v got %v", name, tc.expected, err)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestConfigWatcher(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+	writeConfigFiles(dir, map[string]string{
+		"config.json":       `{"include": "conf.d"}`,
+		"conf.d/10-db.json": `{"services": [{"name": "db"}]}`,
+	})
+	w, err := NewConfigWatcher(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := w.Changed(); changed {
+		t.Error("expected the files unchanged")
+	}
+	writeConfigFiles(dir, map[string]string{"conf.d/20-web.json": `{"services": [{"name": "web"}]}`})
+	if changed, err := w.Changed(); !changed || err != nil {
+		t.Errorf("expected an added fragment noticed got %v %v", changed, err)
+	}
+	os.Remove(filepath.Join(dir, "conf.d/10-db.json"))
+	if changed, err := w.Changed(); !changed || err != nil {
+		t.Errorf("expected a removed fragment noticed got %v %v", changed, err)
+	}
+}
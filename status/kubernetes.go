@@ -0,0 +1,327 @@
+package status
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Errors returned discovering the services of Kubernetes
+var (
+	ErrNotInCluster      = errors.New("discovery: kubernetes discovery needs a url outside a cluster")
+	ErrKubernetesKind    = errors.New("discovery: kubernetes kinds must be services or ingresses")
+	ErrKubernetesRefused = errors.New("discovery: kubernetes API request rejected")
+)
+
+// The annotations of a Kubernetes object which tune its check
+const (
+	// kubernetesCheckAnnotation set to "true" discovers the object
+	// when no selector or annotation is configured
+	kubernetesCheckAnnotation = "service-status/check"
+	// kubernetesNameAnnotation names its service instead of the
+	// name and namespace of a Service, or the host of an Ingress
+	kubernetesNameAnnotation = "service-status/name"
+	// kubernetesPathAnnotation is the path checked, "/" by default
+	kubernetesPathAnnotation = "service-status/path"
+	// kubernetesPortAnnotation is the name or number of the port of
+	// a Service checked, its first port by default
+	kubernetesPortAnnotation = "service-status/port"
+)
+
+// serviceAccountDir holds the credentials of the pod the status page
+// runs in
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesDiscoverer discovers the Services and Ingresses of a
+// Kubernetes cluster which match a label selector or set an
+// annotation, checking each Service at its cluster DNS name and each
+// Ingress at its hosts
+type KubernetesDiscoverer struct {
+	config DiscoveryConfig
+
+	// mu guards client, made on first use as it needs the CA of the
+	// cluster
+	mu     sync.Mutex
+	client *http.Client
+}
+
+func newKubernetesDiscoverer(c DiscoveryConfig) (*KubernetesDiscoverer, error) {
+	if len(c.Kinds) == 0 {
+		c.Kinds = []string{"services", "ingresses"}
+	}
+	for _, kind := range c.Kinds {
+		if kind != "services" && kind != "ingresses" {
+			return nil, ErrKubernetesKind
+		}
+	}
+	if c.URL != "" {
+		if err := validateHTTPURL(c.URL); err != nil {
+			return nil, err
+		}
+	}
+	if c.Annotation == "" && c.Selector == "" {
+		c.Annotation = kubernetesCheckAnnotation
+	}
+	return &KubernetesDiscoverer{config: c}, nil
+}
+
+// kubernetesObject is the part of a Service or an Ingress discovery
+// reads
+type kubernetesObject struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Namespace   string            `json:"namespace"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		// Ports are the ports of a Service
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+		// Rules and TLS are the hosts of an Ingress
+		Rules []struct {
+			Host string `json:"host"`
+		} `json:"rules"`
+		TLS []struct {
+			Hosts []string `json:"hosts"`
+		} `json:"tls"`
+	} `json:"spec"`
+}
+
+// Discover lists the Services and Ingresses, returning the services
+// of those discovered sorted by name
+func (k *KubernetesDiscoverer) Discover(ctx context.Context) ([]Service, error) {
+	var services []Service
+	for _, kind := range k.config.Kinds {
+		objects, err := k.list(ctx, kind)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", kind, err)
+		}
+		for _, o := range objects {
+			if k.config.Annotation != "" && o.Metadata.Annotations[k.config.Annotation] != "true" {
+				continue
+			}
+			if kind == "services" {
+				if s, ok := k.service(o); ok {
+					services = append(services, s)
+				}
+			} else {
+				services = append(services, k.ingress(o)...)
+			}
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+// service returns the service checking a Service at its cluster DNS
+// name, false when it has no port
+func (k *KubernetesDiscoverer) service(o kubernetesObject) (Service, bool) {
+	if len(o.Spec.Ports) == 0 {
+		return Service{}, false
+	}
+	port := o.Spec.Ports[0]
+	if want := o.Metadata.Annotations[kubernetesPortAnnotation]; want != "" {
+		found := false
+		for _, p := range o.Spec.Ports {
+			if p.Name == want || strconv.Itoa(p.Port) == want {
+				port, found = p, true
+			}
+		}
+		if !found {
+			return Service{}, false
+		}
+	}
+	scheme := "http"
+	if port.Name == "https" || port.Port == 443 {
+		scheme = "https"
+	}
+	name := o.Metadata.Name + "." + o.Metadata.Namespace
+	if n := o.Metadata.Annotations[kubernetesNameAnnotation]; n != "" {
+		name = n
+	}
+	host := net.JoinHostPort(o.Metadata.Name+"."+o.Metadata.Namespace+".svc", strconv.Itoa(port.Port))
+	return k.discovered(o, name, scheme, host), true
+}
+
+// ingress returns the services checking each host of an Ingress, over
+// HTTPS when its TLS covers the host
+func (k *KubernetesDiscoverer) ingress(o kubernetesObject) []Service {
+	secure := make(map[string]bool)
+	for _, t := range o.Spec.TLS {
+		for _, h := range t.Hosts {
+			secure[h] = true
+		}
+	}
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, r := range o.Spec.Rules {
+		// a wildcard host has no address to check
+		if r.Host == "" || strings.HasPrefix(r.Host, "*") || seen[r.Host] {
+			continue
+		}
+		seen[r.Host] = true
+		hosts = append(hosts, r.Host)
+	}
+	var services []Service
+	for _, host := range hosts {
+		name := host
+		// a name only fits an Ingress of one host
+		if n := o.Metadata.Annotations[kubernetesNameAnnotation]; n != "" && len(hosts) == 1 {
+			name = n
+		}
+		scheme := "http"
+		if secure[host] {
+			scheme = "https"
+		}
+		services = append(services, k.discovered(o, name, scheme, host))
+	}
+	return services
+}
+
+// discovered returns the service of the template of the config named
+// name, checking scheme://host and the path of the object
+func (k *KubernetesDiscoverer) discovered(o kubernetesObject, name, scheme, host string) Service {
+	path := o.Metadata.Annotations[kubernetesPathAnnotation]
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	s := k.config.Service
+	s.Name = name
+	s.URL = scheme + "://" + host + path
+	return s
+}
+
+// list returns the objects of the kind, in the namespace of the config
+// or all of them
+func (k *KubernetesDiscoverer) list(ctx context.Context, kind string) ([]kubernetesObject, error) {
+	base, err := k.apiURL()
+	if err != nil {
+		return nil, err
+	}
+	path := "/api/v1/"
+	if kind == "ingresses" {
+		path = "/apis/networking.k8s.io/v1/"
+	}
+	if k.config.Namespace != "" {
+		path += "namespaces/" + url.PathEscape(k.config.Namespace) + "/"
+	}
+	u := strings.TrimSuffix(base, "/") + path + kind
+	if k.config.Selector != "" {
+		u += "?labelSelector=" + url.QueryEscape(k.config.Selector)
+	}
+
+	client, err := k.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	token, err := k.token()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{Err: ErrKubernetesRefused, Code: resp.StatusCode}
+	}
+	var list struct {
+		Items []kubernetesObject `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// inCluster reports whether the API is the one of the cluster the
+// status page runs in
+func (k *KubernetesDiscoverer) inCluster() bool {
+	return k.config.URL == ""
+}
+
+// apiURL returns the url of the API
+func (k *KubernetesDiscoverer) apiURL() (string, error) {
+	if !k.inCluster() {
+		return k.config.URL, nil
+	}
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return "", ErrNotInCluster
+	}
+	return "https://" + net.JoinHostPort(host, port), nil
+}
+
+// token returns the bearer token of the config, or the token of the
+// service account in a cluster, read on every request as it rotates
+func (k *KubernetesDiscoverer) token() (string, error) {
+	if k.config.Token != "" || !k.inCluster() {
+		return readSecret(k.config.Token), nil
+	}
+	b, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	return strings.TrimSpace(string(b)), err
+}
+
+// httpClient returns the client of the API, trusting the CA bundle of
+// the config or the CA of the cluster
+func (k *KubernetesDiscoverer) httpClient() (*http.Client, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.client != nil {
+		return k.client, nil
+	}
+	ca := k.config.CABundle
+	if ca == "" && k.inCluster() {
+		ca = serviceAccountDir + "/ca.crt"
+	}
+	client, err := discoveryClient(ca)
+	if err != nil {
+		return nil, err
+	}
+	k.client = client
+	return client, nil
+}
+
+// discoveryClient returns the client of the API of a discoverer,
+// trusting the CA bundle ca when set, a path or "env:NAME"
+func discoveryClient(ca string) (*http.Client, error) {
+	client := &http.Client{Timeout: defaultDiscoveryInterval}
+	if ca == "" {
+		return client, nil
+	}
+	caPEM, err := readPEM(ca)
+	if err != nil {
+		return nil, ErrInvalidCABundle
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, ErrInvalidCABundle
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	client.Transport = transport
+	return client, nil
+}
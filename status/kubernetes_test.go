@@ -0,0 +1,99 @@
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestKubernetesDiscoverer(t *testing.T) {
+	var selectors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		selectors = append(selectors, r.URL.Query().Get("labelSelector"))
+		switch r.URL.Path {
+		case "/api/v1/namespaces/shop/services":
+			w.Write([]byte(`{"items": [
+				{"metadata": {"name": "web", "namespace": "shop", "annotations": {"service-status/check": "true", "service-status/path": "healthz"}},
+				 "spec": {"ports": [{"name": "https", "port": 443}]}},
+				{"metadata": {"name": "api", "namespace": "shop", "annotations": {"service-status/check": "true", "service-status/port": "http", "service-status/name": "API"}},
+				 "spec": {"ports": [{"name": "grpc", "port": 9090}, {"name": "http", "port": 8080}]}},
+				{"metadata": {"name": "db", "namespace": "shop"}, "spec": {"ports": [{"port": 5432}]}},
+				{"metadata": {"name": "headless", "namespace": "shop", "annotations": {"service-status/check": "true"}}, "spec": {}}
+			]}`))
+		case "/apis/networking.k8s.io/v1/namespaces/shop/ingresses":
+			w.Write([]byte(`{"items": [
+				{"metadata": {"name": "front", "namespace": "shop", "annotations": {"service-status/check": "true", "service-status/name": "Front"}},
+				 "spec": {"rules": [{"host": "shop.example.com"}, {"host": "*.example.com"}, {"host": "www.example.com"}],
+				          "tls": [{"hosts": ["shop.example.com"]}]}}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	d, err := NewDiscoverer(DiscoveryConfig{
+		Type:      "kubernetes",
+		URL:       server.URL,
+		Token:     "secret",
+		Namespace: "shop",
+		Service:   Service{Group: "Shop"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	services, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Service{
+		{Name: "API", Type: "ping", URL: "http://api.shop.svc:8080/", Group: "Shop"},
+		{Name: "shop.example.com", Type: "ping", URL: "https://shop.example.com/", Group: "Shop"},
+		{Name: "web.shop", Type: "ping", URL: "https://web.shop.svc:443/healthz", Group: "Shop"},
+		{Name: "www.example.com", Type: "ping", URL: "http://www.example.com/", Group: "Shop"},
+	}
+	if !reflect.DeepEqual(services, expected) {
+		t.Errorf("expected %+v got %+v", expected, services)
+	}
+
+	// a selector replaces the default annotation
+	d, _ = NewDiscoverer(DiscoveryConfig{Type: "kubernetes", URL: server.URL, Token: "secret", Namespace: "shop", Kinds: []string{"services"}, Selector: "team=shop"})
+	selectors = nil
+	if services, _ := d.Discover(context.Background()); len(services) != 3 || !reflect.DeepEqual(selectors, []string{"team=shop"}) {
+		t.Errorf("expected every service with a port listed by selector got %+v %v", services, selectors)
+	}
+
+	d, _ = NewDiscoverer(DiscoveryConfig{Type: "kubernetes", URL: server.URL, Namespace: "shop"})
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Error("expected an error without a token")
+	}
+}
+
+func TestNewKubernetesDiscoverer(t *testing.T) {
+	for _, tc := range []struct {
+		config   DiscoveryConfig
+		expected error
+	}{
+		{DiscoveryConfig{Type: "kubernetes"}, nil},
+		{DiscoveryConfig{Type: "kubernetes", Kinds: []string{"pods"}}, ErrKubernetesKind},
+		{DiscoveryConfig{Type: "kubernetes", URL: "cluster:6443"}, ErrInvalidURL},
+		{DiscoveryConfig{Type: "kubernetes", Service: Service{URL: "http://x"}}, ErrInvalidTemplate},
+		{DiscoveryConfig{Type: "swarm"}, ErrInvalidDiscovery},
+	} {
+		if _, err := NewDiscoverer(tc.config); err != tc.expected {
+			t.Errorf("%+v: expected %v got %v", tc.config, tc.expected, err)
+		}
+	}
+
+	// outside a cluster the API has no address
+	d, _ := NewDiscoverer(DiscoveryConfig{Type: "kubernetes"})
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Error("expected an error outside a cluster")
+	}
+}
@@ -0,0 +1,28 @@
+package status
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// unixPrefix starts the listen addresses of unix sockets
+const unixPrefix = "unix:"
+
+// Listen listens on addr, a "host:port" or "unix:" followed by the
+// path of a unix socket. A socket left behind by a server which did
+// not stop cleanly is replaced.
+func Listen(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixPrefix) {
+		return net.Listen("tcp", addr)
+	}
+	path := strings.TrimPrefix(addr, unixPrefix)
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+		} else if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,49 @@
+package status
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	l, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	dir, _ := ioutil.TempDir("", "listen")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "status.sock")
+	l, err = Listen("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("expected the socket to be served got %v", err)
+	}
+	c.Close()
+	if _, err := Listen("unix:" + path); err == nil {
+		t.Error("expected a socket in use not to be replaced")
+	}
+	l.Close()
+
+	// a socket left behind is replaced
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+	l, err = Listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("expected a stale socket to be replaced got %v", err)
+	}
+	l.Close()
+}
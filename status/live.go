@@ -0,0 +1,68 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// liveBuffer is how many events a viewer of the page may fall
+	// behind by before missing some
+	liveBuffer = 16
+	// liveKeepAlive is how often an idle stream is written to, so
+	// proxies do not close it
+	liveKeepAlive = 30 * time.Second
+)
+
+// LiveHandler is a HandlerFunc which streams the events published on
+// bus as Server-Sent Events, named after their type with the event as
+// JSON data, until the client goes away (GET /api/events). Viewers
+// private services are hidden from are only told the page changed.
+func LiveHandler(bus *EventBus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		events, unsubscribe := bus.Subscribe(liveBuffer)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(liveKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if e.Type != EventPageUpdated && limited(r.Context()) {
+					continue
+				}
+				b, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, b)
+			case <-keepAlive.C:
+				io.WriteString(w, ": keep-alive\n\n")
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package status
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveHandler(t *testing.T) {
+	bus := NewEventBus()
+	ts := httptest.NewServer(LiveHandler(bus))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream got %q", ct)
+	}
+
+	now := time.Now().UTC()
+	bus.Publish(Event{Type: EventStateChanged, Time: now, Service: "api", From: StateUp, To: StateDown})
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	if lines[0] != "event: state_changed" || lines[2] != "" {
+		t.Fatalf("unexpected event %q", lines)
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Service != "api" || e.From != StateUp || e.To != StateDown || !e.Time.Equal(now) {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	w := httptest.NewRecorder()
+	LiveHandler(bus)(w, httptest.NewRequest(http.MethodPost, "/api/events", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 got %d", w.Code)
+	}
+}
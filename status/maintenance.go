@@ -0,0 +1,351 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned when validating maintenance windows
+var (
+	ErrInvalidWindow       = errors.New("maintenance: window needs start and end, or cron and duration")
+	ErrInvalidTimezone     = errors.New("maintenance: invalid timezone")
+	ErrMaintenanceNotFound = errors.New("maintenance: scheduled maintenance not found")
+)
+
+// MaintenanceWindow is a period during which a service is expected to
+// be unavailable. It is either a fixed Start/End range or a recurring
+// window opened by a Cron expression and lasting Duration.
+type MaintenanceWindow struct {
+	Start    time.Time `json:"start,omitempty"`
+	End      time.Time `json:"end,omitempty"`
+	Cron     string    `json:"cron,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+	// Timezone the cron expression is evaluated in, UTC by default
+	Timezone string `json:"timezone,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Validate checks the window is either a fixed or a recurring window
+func (w MaintenanceWindow) Validate() error {
+	if w.Cron == "" {
+		if w.Start.IsZero() || !w.End.After(w.Start) {
+			return ErrInvalidWindow
+		}
+		return nil
+	}
+
+	if _, err := parseCron(w.Cron); err != nil {
+		return err
+	}
+	if d, err := time.ParseDuration(w.Duration); err != nil || d <= 0 {
+		return ErrInvalidWindow
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
+
+// Active reports whether t falls inside the window. Invalid
+// windows are never active.
+func (w MaintenanceWindow) Active(t time.Time) bool {
+	if w.Cron == "" {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+
+	c, err := parseCron(w.Cron)
+	if err != nil {
+		return false
+	}
+	d, err := time.ParseDuration(w.Duration)
+	if err != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	return c.activeWithin(t.In(loc), d)
+}
+
+// ScheduledMaintenance is a maintenance of some services, or of every
+// service when none are given, announced in advance and kept in the
+// storage
+type ScheduledMaintenance struct {
+	ID          string    `json:"id"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Services    []string  `json:"services,omitempty"`
+	Description string    `json:"description,omitempty"`
+}
+
+// Affects reports whether the maintenance is of the service with
+// the given id
+func (sm ScheduledMaintenance) Affects(id string) bool {
+	if len(sm.Services) == 0 {
+		return true
+	}
+	for _, s := range sm.Services {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
+
+// window returns the maintenance as a MaintenanceWindow
+func (sm ScheduledMaintenance) window() MaintenanceWindow {
+	return MaintenanceWindow{Start: sm.Start, End: sm.End, Message: sm.Description}
+}
+
+// MaintenanceRegistry holds the maintenance windows added to
+// services at runtime through the API, the global Schedule which
+// puts every service into maintenance, and the maintenance scheduled
+// in Storage
+type MaintenanceRegistry struct {
+	Schedule []MaintenanceWindow
+	// Storage keeps the scheduled maintenance, nil keeps none
+	Storage StorageBackend
+
+	mu        sync.RWMutex
+	windows   map[string][]MaintenanceWindow
+	scheduled []ScheduledMaintenance
+}
+
+// NewMaintenanceRegistry returns an empty MaintenanceRegistry
+func NewMaintenanceRegistry() *MaintenanceRegistry {
+	return &MaintenanceRegistry{windows: make(map[string][]MaintenanceWindow)}
+}
+
+// SetSchedule replaces the global Schedule, as when the config is
+// reloaded
+func (mr *MaintenanceRegistry) SetSchedule(schedule []MaintenanceWindow) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.Schedule = schedule
+}
+
+// Add schedules a window for the service with the given id
+func (mr *MaintenanceRegistry) Add(id string, w MaintenanceWindow) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.windows[id] = append(mr.windows[id], w)
+	return nil
+}
+
+// Clear removes all runtime windows of a service
+func (mr *MaintenanceRegistry) Clear(id string) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	delete(mr.windows, id)
+}
+
+// Windows returns the runtime windows of a service
+func (mr *MaintenanceRegistry) Windows(id string) []MaintenanceWindow {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	return append([]MaintenanceWindow(nil), mr.windows[id]...)
+}
+
+// LoadScheduled reads the scheduled maintenance from the storage
+func (mr *MaintenanceRegistry) LoadScheduled(ctx context.Context) error {
+	scheduled, err := mr.Storage.Maintenances(ctx)
+	if err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.scheduled = scheduled
+	return nil
+}
+
+// SaveScheduled schedules a maintenance, or replaces the one with the
+// same ID, and persists it
+func (mr *MaintenanceRegistry) SaveScheduled(ctx context.Context, sm ScheduledMaintenance) (ScheduledMaintenance, error) {
+	if err := sm.window().Validate(); err != nil {
+		return ScheduledMaintenance{}, err
+	}
+	sm, err := mr.Storage.SaveMaintenance(ctx, sm)
+	if err != nil {
+		return ScheduledMaintenance{}, err
+	}
+	return sm, mr.LoadScheduled(ctx)
+}
+
+// RemoveScheduled cancels a scheduled maintenance
+func (mr *MaintenanceRegistry) RemoveScheduled(ctx context.Context, id string) error {
+	if err := mr.Storage.RemoveMaintenance(ctx, id); err != nil {
+		return err
+	}
+	return mr.LoadScheduled(ctx)
+}
+
+// Scheduled returns the scheduled maintenance
+func (mr *MaintenanceRegistry) Scheduled() []ScheduledMaintenance {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	return append([]ScheduledMaintenance(nil), mr.scheduled...)
+}
+
+// Upcoming returns the scheduled maintenance which has not ended by
+// t, soonest first
+func (mr *MaintenanceRegistry) Upcoming(t time.Time) []ScheduledMaintenance {
+	if mr == nil {
+		return nil
+	}
+	var upcoming []ScheduledMaintenance
+	for _, sm := range mr.Scheduled() {
+		if sm.End.After(t) {
+			upcoming = append(upcoming, sm)
+		}
+	}
+	sort.SliceStable(upcoming, func(i, j int) bool { return upcoming[i].Start.Before(upcoming[j].Start) })
+	return upcoming
+}
+
+// InMaintenance returns the window, global, configured, added at
+// runtime or scheduled, that s is in at time t
+func (mr *MaintenanceRegistry) InMaintenance(s *Service, t time.Time) (MaintenanceWindow, bool) {
+	var windows []MaintenanceWindow
+	if mr != nil {
+		mr.mu.RLock()
+		windows = append(windows, mr.Schedule...)
+		mr.mu.RUnlock()
+		windows = append(windows, mr.Windows(s.ID())...)
+		for _, sm := range mr.Upcoming(t) {
+			if sm.Affects(s.ID()) {
+				windows = append(windows, sm.window())
+			}
+		}
+	}
+	windows = append(windows, s.Maintenance...)
+
+	for _, w := range windows {
+		if w.Active(t) {
+			return w, true
+		}
+	}
+	return MaintenanceWindow{}, false
+}
+
+// MaintenanceHandler is a HandlerFunc which lists (GET), adds (POST)
+// and clears (DELETE) the runtime maintenance windows of the service
+// named in /api/maintenance/{service}. Changes need token, which may be
+// "env:NAME", or an API key of the maintenance scope as a bearer
+// token; without either they are disabled.
+func MaintenanceHandler(mr *MaintenanceRegistry, token string) http.HandlerFunc {
+	token = readSecret(token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/maintenance/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && !granted(r, token, ScopeMaintenance) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(mr.Windows(id))
+		case http.MethodPost:
+			var mw MaintenanceWindow
+			if err := json.NewDecoder(r.Body).Decode(&mw); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := mr.Add(id, mw); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			mr.Clear(id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// ScheduledMaintenanceHandler is a HandlerFunc which lists the
+// scheduled maintenance (GET /api/scheduled-maintenance/), schedules
+// one (POST /api/scheduled-maintenance/), shows, replaces or cancels
+// one (GET, PUT or DELETE /api/scheduled-maintenance/{id}). Changes
+// need token, which may be "env:NAME", or an API key of the
+// maintenance scope as a bearer token; without either they are
+// disabled.
+func ScheduledMaintenanceHandler(mr *MaintenanceRegistry, token string) http.HandlerFunc {
+	token = readSecret(token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/scheduled-maintenance/")
+		if r.Method != http.MethodGet && !granted(r, token, ScopeMaintenance) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(append([]ScheduledMaintenance{}, mr.Upcoming(time.Now())...))
+		case r.Method == http.MethodGet:
+			for _, sm := range mr.Scheduled() {
+				if sm.ID == id {
+					w.Header().Set("Content-Type", "application/json")
+					json.NewEncoder(w).Encode(sm)
+					return
+				}
+			}
+			http.NotFound(w, r)
+		case r.Method == http.MethodPost && id == "", r.Method == http.MethodPut && id != "":
+			var sm ScheduledMaintenance
+			if err := json.NewDecoder(r.Body).Decode(&sm); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			sm.ID = id
+			code := http.StatusOK
+			if id == "" {
+				code = http.StatusCreated
+			}
+			sm, err := mr.SaveScheduled(ctx, sm)
+			switch {
+			case err == ErrMaintenanceNotFound:
+				http.NotFound(w, r)
+			case err == ErrInvalidWindow:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			default:
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(code)
+				json.NewEncoder(w).Encode(sm)
+			}
+		case r.Method == http.MethodDelete && id != "":
+			if err := mr.RemoveScheduled(ctx, id); err == ErrMaintenanceNotFound {
+				http.NotFound(w, r)
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowValidate(t *testing.T) {
+	now := time.Now()
+
+	tt := []struct {
+		name     string
+		window   MaintenanceWindow
+		expected error
+	}{
+		{name: "fixed", window: MaintenanceWindow{Start: now, End: now.Add(time.Hour)}, expected: nil},
+		{name: "fixed backwards", window: MaintenanceWindow{Start: now, End: now.Add(-time.Hour)}, expected: ErrInvalidWindow},
+		{name: "empty", window: MaintenanceWindow{}, expected: ErrInvalidWindow},
+		{name: "cron", window: MaintenanceWindow{Cron: "0 2 * * sun", Duration: "2h"}, expected: nil},
+		{name: "cron without duration", window: MaintenanceWindow{Cron: "0 2 * * sun"}, expected: ErrInvalidWindow},
+		{name: "bad cron", window: MaintenanceWindow{Cron: "0 2 * *", Duration: "2h"}, expected: ErrInvalidCron},
+		{name: "bad timezone", window: MaintenanceWindow{Cron: "0 2 * * sun", Duration: "2h", Timezone: "Mars/Olympus"}, expected: ErrInvalidTimezone},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.window.Validate(); actual != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowActive(t *testing.T) {
+	now := time.Now()
+
+	fixed := MaintenanceWindow{Start: now.Add(-time.Minute), End: now.Add(time.Minute)}
+	if !fixed.Active(now) || fixed.Active(now.Add(time.Hour)) {
+		t.Error("fixed window active at wrong time")
+	}
+
+	always := MaintenanceWindow{Cron: "* * * * *", Duration: "1m"}
+	if !always.Active(now) {
+		t.Error("expected recurring window to be active")
+	}
+}
+
+func TestCheckAllServicesMaintenance(t *testing.T) {
+	fail := errors.New("fail")
+	db := &fakePinger{Service: Service{Name: "db"}, err: fail}
+	api := &fakePinger{Service: Service{Name: "api", DependsOn: []string{"db"}}, err: fail}
+	web := &fakePinger{Service: Service{Name: "web"}, err: fail}
+
+	rec := &recordingNotifier{}
+	m := NewMonitor([]Pinger{db, api, web}, NewNotificationManager([]Notifier{rec}, 0))
+	m.Maintenance = NewMaintenanceRegistry()
+	m.Maintenance.Add("db", MaintenanceWindow{Start: time.Now().Add(-time.Minute), End: time.Now().Add(time.Hour), Message: "upgrade"})
+
+	results := m.CheckAllServices()
+	expected := []State{StateMaintenance, StateAffected, StateDown}
+	for i, r := range results {
+		if r.State != expected[i] {
+			t.Errorf("%s: expected %v got %v", r.Service.ID(), expected[i], r.State)
+		}
+	}
+	if results[0].Message != "upgrade" {
+		t.Errorf("expected maintenance message got %q", results[0].Message)
+	}
+	if len(rec.alerts) != 1 || rec.alerts[0].Service.ID() != "web" {
+		t.Errorf("expected a single alert for web got %v", rec.alerts)
+	}
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	mr := NewMaintenanceRegistry()
+	h := MaintenanceHandler(mr, "secret")
+	authed := func(r *http.Request) *http.Request {
+		r.Header.Set("Authorization", "Bearer secret")
+		return r
+	}
+
+	// changes need the token
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(method, "/api/maintenance/db", strings.NewReader(`{"cron":"0 2 * * sun","duration":"2h"}`)))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected %d got %d", method, http.StatusUnauthorized, w.Code)
+		}
+	}
+	if len(mr.Windows("db")) != 0 {
+		t.Fatalf("expected no window added without the token got %v", mr.Windows("db"))
+	}
+
+	w := httptest.NewRecorder()
+	h(w, authed(httptest.NewRequest(http.MethodPost, "/api/maintenance/db", strings.NewReader(`{"cron":"0 2 * * sun","duration":"2h"}`))))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, w.Code)
+	}
+	if len(mr.Windows("db")) != 1 {
+		t.Fatalf("expected 1 window got %d", len(mr.Windows("db")))
+	}
+
+	w = httptest.NewRecorder()
+	h(w, authed(httptest.NewRequest(http.MethodPost, "/api/maintenance/db", strings.NewReader(`{"cron":"0 2 * * sun"}`))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/api/maintenance/db", nil))
+	if !strings.Contains(w.Body.String(), `"cron":"0 2 * * sun"`) {
+		t.Errorf("expected window in body got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h(w, authed(httptest.NewRequest(http.MethodDelete, "/api/maintenance/db", nil)))
+	if w.Code != http.StatusNoContent || len(mr.Windows("db")) != 0 {
+		t.Errorf("expected windows to be cleared")
+	}
+}
+
+func TestMaintenanceSchedule(t *testing.T) {
+	m := NewMonitor([]Pinger{
+		&fakePinger{Service: Service{Name: "db"}},
+		&fakePinger{Service: Service{Name: "api"}, err: errors.New("fail")},
+	}, nil)
+	m.Maintenance = NewMaintenanceRegistry()
+	m.Maintenance.Schedule = []MaintenanceWindow{{Cron: "* * * * *", Duration: "1m", Message: "patching"}}
+
+	results := m.CheckAllServices()
+	for _, r := range results {
+		if r.State != StateMaintenance || r.Message != "patching" {
+			t.Errorf("%s: expected maintenance got %v %q", r.Service.ID(), r.State, r.Message)
+		}
+	}
+
+	if p := NewPage("test", results); p.Status != "maintenance" {
+		t.Errorf("expected page status maintenance got %v", p.Status)
+	}
+}
+
+func TestScheduledMaintenance(t *testing.T) {
+	db, _ := OpenStorage("")
+	mr := NewMaintenanceRegistry()
+	mr.Storage = db
+	h := ScheduledMaintenanceHandler(mr, "s3cret")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	now := time.Now()
+	body := func(start, end time.Time, services string) string {
+		return `{"start": "` + start.Format(time.RFC3339) + `", "end": "` + end.Format(time.RFC3339) + `", "services": ` + services + `, "description": "db upgrade"}`
+	}
+	if w := do(http.MethodPost, "/api/scheduled-maintenance/", body(now.Add(-time.Minute), now.Add(time.Hour), `["db"]`)); w.Code != http.StatusCreated {
+		t.Fatalf("expected %d got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/api/scheduled-maintenance/", body(now.Add(24*time.Hour), now.Add(25*time.Hour), `[]`)); w.Code != http.StatusCreated {
+		t.Fatalf("expected %d got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/api/scheduled-maintenance/", body(now, now.Add(-time.Hour), `[]`)); w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// the checker suppresses the services in maintenance
+	if mw, ok := mr.InMaintenance(&Service{Name: "db"}, now); !ok || mw.Message != "db upgrade" {
+		t.Errorf("expected db in maintenance got %+v", mw)
+	}
+	if _, ok := mr.InMaintenance(&Service{Name: "api"}, now); ok {
+		t.Error("expected api not in maintenance")
+	}
+	if _, ok := mr.InMaintenance(&Service{Name: "api"}, now.Add(24*time.Hour+time.Minute)); !ok {
+		t.Error("expected every service in the second maintenance")
+	}
+
+	// a restarted registry loads it from the storage
+	mr = NewMaintenanceRegistry()
+	mr.Storage = db
+	if err := mr.LoadScheduled(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if upcoming := mr.Upcoming(now); len(upcoming) != 2 || upcoming[0].ID != "1" {
+		t.Errorf("expected both maintenances soonest first got %+v", upcoming)
+	}
+	if upcoming := mr.Upcoming(now.Add(2 * time.Hour)); len(upcoming) != 1 || upcoming[0].ID != "2" {
+		t.Errorf("expected the ended maintenance left out got %+v", upcoming)
+	}
+
+	h = ScheduledMaintenanceHandler(mr, "s3cret")
+	if w := do(http.MethodDelete, "/api/scheduled-maintenance/1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected %d got %d", http.StatusNoContent, w.Code)
+	}
+	if w := do(http.MethodDelete, "/api/scheduled-maintenance/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected %d got %d", http.StatusNotFound, w.Code)
+	}
+	if w := do(http.MethodGet, "/api/scheduled-maintenance/2", ""); w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+}
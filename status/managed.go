@@ -0,0 +1,216 @@
+package status
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNotifierNotFound is returned when changing a managed notifier
+// which does not exist
+var ErrNotifierNotFound = errors.New("notify: notifier not found")
+
+// redactedSecret replaces the secrets of managed notifiers when
+// they are listed
+const redactedSecret = "********"
+
+// ManagedNotifier is a notifier added at runtime through the API,
+// kept in the storage so it survives restarts
+type ManagedNotifier struct {
+	ID       string         `json:"id"`
+	Config   NotifierConfig `json:"config"`
+	Disabled bool           `json:"disabled,omitempty"`
+}
+
+// redacted returns the notifier with the secrets of its config
+// hidden, except "env:NAME" references
+func (mn ManagedNotifier) redacted() ManagedNotifier {
+	for _, s := range []*string{&mn.Config.RoutingKey, &mn.Config.APIKey, &mn.Config.Token, &mn.Config.Password, &mn.Config.Secret} {
+		if *s != "" && !strings.HasPrefix(*s, "env:") {
+			*s = redactedSecret
+		}
+	}
+	return mn
+}
+
+// unredacted returns the notifier with the secrets left redacted
+// replaced by those of old, so a listed notifier can be saved back
+func (mn ManagedNotifier) unredacted(old ManagedNotifier) ManagedNotifier {
+	secrets := []*string{&mn.Config.RoutingKey, &mn.Config.APIKey, &mn.Config.Token, &mn.Config.Password, &mn.Config.Secret}
+	olds := []string{old.Config.RoutingKey, old.Config.APIKey, old.Config.Token, old.Config.Password, old.Config.Secret}
+	for i, s := range secrets {
+		if *s == redactedSecret {
+			*s = olds[i]
+		}
+	}
+	return mn
+}
+
+// LoadNotifiers builds the managed notifiers kept in the storage
+func (nm *NotificationManager) LoadNotifiers(ctx context.Context) error {
+	list, err := nm.Storage.ManagedNotifiers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mn := range list {
+		if err := nm.setManaged(mn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveNotifier adds a managed notifier, or replaces the one with the
+// same ID, and persists it. Disabled notifiers receive no alerts.
+func (nm *NotificationManager) SaveNotifier(ctx context.Context, mn ManagedNotifier) (ManagedNotifier, error) {
+	if _, err := NewNotifier(mn.Config); err != nil {
+		return ManagedNotifier{}, err
+	}
+	mn, err := nm.Storage.SaveNotifier(ctx, mn)
+	if err != nil {
+		return ManagedNotifier{}, err
+	}
+	return mn, nm.setManaged(mn)
+}
+
+// RemoveNotifier removes a managed notifier. Its alerts still
+// queued are dropped.
+func (nm *NotificationManager) RemoveNotifier(ctx context.Context, id string) error {
+	if err := nm.Storage.RemoveNotifier(ctx, id); err != nil {
+		return err
+	}
+	n, _ := strconv.Atoi(id)
+	nm.notifiersMu.Lock()
+	delete(nm.managed, n)
+	nm.notifiersMu.Unlock()
+	return nil
+}
+
+// setManaged builds a managed notifier and starts sending it alerts,
+// unless it is disabled
+func (nm *NotificationManager) setManaged(mn ManagedNotifier) error {
+	id, err := strconv.Atoi(mn.ID)
+	if err != nil {
+		return ErrNotifierNotFound
+	}
+	var n Notifier
+	if !mn.Disabled {
+		if n, err = NewNotifier(mn.Config); err != nil {
+			return err
+		}
+	}
+
+	nm.notifiersMu.Lock()
+	defer nm.notifiersMu.Unlock()
+	if n == nil {
+		delete(nm.managed, id)
+		return nil
+	}
+	if nm.managed == nil {
+		nm.managed = make(map[int]Notifier)
+	}
+	nm.managed[id] = n
+	return nil
+}
+
+// managedTargets returns the identities of the enabled managed
+// notifiers, "managed-ID", which stay the same while they exist as IDs
+// are not reused. The caller holds notifiersMu.
+func (nm *NotificationManager) managedTargets() []string {
+	var ids []int
+	for id := range nm.managed {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var targets []string
+	for _, id := range ids {
+		targets = append(targets, "managed-"+strconv.Itoa(id))
+	}
+	return targets
+}
+
+// NotifierHandler is a HandlerFunc which lists the managed notifiers
+// (GET /api/notifiers/), adds one (POST /api/notifiers/), replaces,
+// enables or disables one (PUT /api/notifiers/{id}) or removes one
+// (DELETE /api/notifiers/{id}). Requests need token, which may be
+// "env:NAME", or an API key of the admin scope as a bearer token;
+// without either the API is disabled.
+func NotifierHandler(nm *NotificationManager, token string) http.HandlerFunc {
+	token = readSecret(token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !granted(r, token, ScopeAdmin) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/notifiers/")
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			managed, err := nm.Storage.ManagedNotifiers(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			list := []ManagedNotifier{}
+			for _, mn := range managed {
+				list = append(list, mn.redacted())
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodPost && id == "", r.Method == http.MethodPut && id != "":
+			var mn ManagedNotifier
+			if err := json.NewDecoder(r.Body).Decode(&mn); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mn.ID = id
+			code := http.StatusOK
+			if id == "" {
+				code = http.StatusCreated
+			}
+			mn, err := nm.SaveNotifier(ctx, mn)
+			if err == ErrNotifierNotFound {
+				http.NotFound(w, r)
+				return
+			}
+			if ctx.Err() != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(mn.redacted())
+		case r.Method == http.MethodDelete && id != "":
+			if err := nm.RemoveNotifier(ctx, id); err == ErrNotifierNotFound {
+				http.NotFound(w, r)
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// authorized reports whether a bearer Authorization header
+// presents token
+func authorized(header, token string) bool {
+	if token == "" || !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(token)) == 1
+}
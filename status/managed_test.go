@@ -0,0 +1,109 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManagedNotifiers(t *testing.T) {
+	ctx := context.Background()
+	var hits []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	db, _ := OpenStorage("")
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	nm.Storage = db
+	nm.Escalations = []Escalation{{After: time.Hour, Notifiers: []Notifier{&recordingNotifier{}}}}
+	handler := NotifierHandler(nm, "s3cret")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/notifiers/", `{"config": {"type": "webhook", "url": "`+ts.URL+`/a", "secret": "hmac"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected %d got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	var mn ManagedNotifier
+	json.NewDecoder(w.Body).Decode(&mn)
+	if mn.ID != "1" || mn.Config.Secret != redactedSecret {
+		t.Errorf("expected notifier 1 with its secret redacted got %+v", mn)
+	}
+	do(http.MethodPost, "/api/notifiers/", `{"config": {"type": "webhook", "url": "`+ts.URL+`/b"}}`)
+
+	nm.CheckAndNotify(&Service{Name: "api"}, false, "timeout")
+	if len(rec.alerts) != 1 || len(hits) != 2 {
+		t.Fatalf("expected the alert sent to every notifier got %d and %v", len(rec.alerts), hits)
+	}
+
+	if w := do(http.MethodPut, "/api/notifiers/1", `{"config": {"type": "webhook", "url": "`+ts.URL+`/a"}, "disabled": true}`); w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if w := do(http.MethodDelete, "/api/notifiers/2", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected %d got %d", http.StatusNoContent, w.Code)
+	}
+	nm.CheckAndNotify(&Service{Name: "api"}, true, "")
+	if len(rec.alerts) != 2 || len(hits) != 2 {
+		t.Errorf("expected the recovery only sent to the configured notifier got %v", hits)
+	}
+
+	// a restarted manager loads the enabled managed notifiers,
+	// known by their ID
+	do(http.MethodPut, "/api/notifiers/1", `{"config": {"type": "webhook", "url": "`+ts.URL+`/a"}}`)
+	nm = NewNotificationManager(nil, 0)
+	nm.Storage = db
+	if err := nm.LoadNotifiers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if targets := nm.targets(0); len(targets) != 1 || targets[0] != "managed-1" {
+		t.Errorf("expected notifier 1 got %v", targets)
+	}
+}
+
+func TestNotifierHandlerErrors(t *testing.T) {
+	db, _ := OpenStorage("")
+	nm := NewNotificationManager(nil, 0)
+	nm.Storage = db
+
+	tt := []struct {
+		name   string
+		token  string
+		auth   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"no token configured", "", "Bearer ", http.MethodGet, "/api/notifiers/", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer guess", http.MethodGet, "/api/notifiers/", "", http.StatusUnauthorized},
+		{"invalid notifier", "s3cret", "Bearer s3cret", http.MethodPost, "/api/notifiers/", `{"config": {"type": "carrier-pigeon"}}`, http.StatusBadRequest},
+		{"unknown notifier", "s3cret", "Bearer s3cret", http.MethodPut, "/api/notifiers/7", `{"config": {"type": "log"}}`, http.StatusNotFound},
+		{"remove unknown", "s3cret", "Bearer s3cret", http.MethodDelete, "/api/notifiers/7", "", http.StatusNotFound},
+		{"post to id", "s3cret", "Bearer s3cret", http.MethodPost, "/api/notifiers/7", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", tc.auth)
+			w := httptest.NewRecorder()
+			NotifierHandler(nm, tc.token)(w, req)
+			if w.Code != tc.code {
+				t.Errorf("expected %d got %d", tc.code, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package status
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a handler, such as to log or compress its responses
+type Middleware func(http.HandlerFunc) http.HandlerFunc
+
+// Chain wraps h in the middlewares, the first of which sees the
+// requests first
+func Chain(h http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// requestIDHeader carries the ID of a request, which is kept when a
+// proxy in front of the page already set it
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key of the ID of a request
+type requestIDKey struct{}
+
+// RequestID returns the ID WithRequestID gave the request of ctx
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether an ID set by a client is kept, so
+// it cannot forge the lines of the log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID gives every request an ID, sent back in the
+// X-Request-ID header and logged along with the request
+func WithRequestID(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(requestIDHeader, id)
+		h(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	}
+}
+
+// statusWriter records the status and size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Flush lets the live updates stream through the middlewares
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// LogRequests logs every request once answered: its method, path,
+// status, size, duration and ID
+func LogRequests(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			code := sw.status
+			if code == 0 {
+				code = http.StatusOK
+			}
+			log.Printf("%s %s %d %dB %s id=%s", r.Method, r.URL.RequestURI(), code, sw.size,
+				time.Since(start).Round(time.Millisecond), RequestID(r.Context()))
+		}()
+		h(sw, r)
+	}
+}
+
+// Recover answers 500 to the requests whose handler panics, logging
+// the panic, rather than dropping the connection
+func Recover(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			log.Printf("panic serving %s %s id=%s: %v\n%s", r.Method, r.URL.Path, RequestID(r.Context()), err, debug.Stack())
+			if sw.status == 0 {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		h(sw, r)
+	}
+}
+
+// Timeout answers 503 to the requests not answered within d. The
+// paths in streams, or below them when they end in "/", such as the
+// live updates, are left to run as long as they need.
+func Timeout(d time.Duration, streams ...string) Middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		timeout := http.TimeoutHandler(h, d, "request timed out")
+		return func(w http.ResponseWriter, r *http.Request) {
+			for _, s := range streams {
+				if r.URL.Path == s || strings.HasSuffix(s, "/") && strings.HasPrefix(r.URL.Path, s) {
+					h(w, r)
+					return
+				}
+			}
+			timeout.ServeHTTP(w, r)
+		}
+	}
+}
+
+// EncodeWriter compresses what is written to it
+type EncodeWriter interface {
+	io.WriteCloser
+	// Flush writes out what is pending, for streamed responses
+	Flush() error
+}
+
+// encoding is a content coding the responses may be compressed with
+type encoding struct {
+	name string
+	new  func(io.Writer) EncodeWriter
+}
+
+var (
+	encodingsMu sync.RWMutex
+	// encodings are the content codings, most preferred first
+	encodings = []encoding{{"gzip", func(w io.Writer) EncodeWriter { return gzip.NewWriter(w) }}}
+)
+
+// RegisterEncoding makes Compress use a content coding, such as "br"
+// with a Brotli package, for the clients accepting it. It is preferred
+// over the codings registered before it, gzip being built in. It
+// panics if the coding is registered twice or new is nil.
+func RegisterEncoding(name string, new func(io.Writer) EncodeWriter) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+
+	if new == nil {
+		panic("status: RegisterEncoding new is nil")
+	}
+	for _, e := range encodings {
+		if e.name == name {
+			panic("status: RegisterEncoding called twice for " + name)
+		}
+	}
+	encodings = append([]encoding{{name, new}}, encodings...)
+}
+
+// negotiateEncoding returns the preferred coding the Accept-Encoding
+// header accepts, false when it accepts none
+func negotiateEncoding(header string) (encoding, bool) {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		ok := true
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				ok = err == nil && q > 0
+			}
+		}
+		accepted[name] = ok
+	}
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	for _, e := range encodings {
+		if ok, listed := accepted[e.name]; ok || !listed && accepted["*"] {
+			return e, true
+		}
+	}
+	return encoding{}, false
+}
+
+// compressMinSize is the size below which a response of known length
+// is not worth compressing
+const compressMinSize = 512
+
+// compressible reports whether a response of the content type is
+// compressed. Images other than SVG are compressed already, and the
+// live updates are left to stream as they are.
+func compressible(contentType string) bool {
+	t := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case t == "text/event-stream":
+		return false
+	case strings.HasPrefix(t, "text/"), strings.HasSuffix(t, "+json"), strings.HasSuffix(t, "+xml"):
+		return true
+	}
+	switch t {
+	case "application/json", "application/x-ndjson", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter compresses a response once its headers show it is
+// worth it
+type compressWriter struct {
+	http.ResponseWriter
+	encoding encoding
+	enc      EncodeWriter
+	decided  bool
+}
+
+// decide compresses the response of code unless its headers rule it
+// out, sniffing the content type from the first bytes written
+func (w *compressWriter) decide(code int, b []byte) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(b) > 0 {
+		h.Set("Content-Type", http.DetectContentType(b))
+	}
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusPartialContent || code == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+		return
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < compressMinSize {
+		return
+	}
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	h.Set("Content-Encoding", w.encoding.name)
+	// the compressed bytes differ, so a strong validator is weakened
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+	w.enc = w.encoding.new(w.ResponseWriter)
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	w.decide(code, nil)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decide(http.StatusOK, b)
+	}
+	if w.enc == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.enc.Write(b)
+}
+
+// Flush writes out what is compressed so far
+func (w *compressWriter) Flush() {
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the handlers take over the connection when the
+// response is not compressed
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok && w.enc == nil {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("status: cannot hijack a compressed response")
+}
+
+// close ends the compressed stream
+func (w *compressWriter) close() {
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
+
+// Compress compresses the text, JSON and SVG responses with the
+// preferred coding the client accepts
+func Compress(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		e, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if !ok || r.Method == http.MethodHead {
+			h(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: e}
+		defer cw.close()
+		h(cw, r)
+	}
+}
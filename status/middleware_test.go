@@ -0,0 +1,147 @@
+package status
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(h http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				h(w, r)
+			}
+		}
+	}
+	h := Chain(func(w http.ResponseWriter, r *http.Request) { order = append(order, "handler") }, mark("a"), mark("b"))
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Join(order, ",") != "a,b,handler" {
+		t.Errorf("expected the first middleware to see the request first got %v", order)
+	}
+}
+
+func TestRequestIDAndLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	var seen string
+	h := Chain(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	}, WithRequestID, LogRequests)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/api/status?x=1", nil))
+	if seen == "" || w.Header().Get("X-Request-ID") != seen {
+		t.Errorf("expected a request ID got %q %q", seen, w.Header().Get("X-Request-ID"))
+	}
+	if line := buf.String(); !strings.Contains(line, "GET /api/status?x=1 418") || !strings.Contains(line, "id="+seen) {
+		t.Errorf("expected the request to be logged got %q", line)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-ID", "lb-1234")
+	h(httptest.NewRecorder(), r)
+	if seen != "lb-1234" {
+		t.Errorf("expected the ID of the proxy to be kept got %q", seen)
+	}
+	r.Header.Set("X-Request-ID", "forged\nline")
+	h(httptest.NewRecorder(), r)
+	if seen == "forged\nline" {
+		t.Error("expected an invalid ID to be replaced")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	h := Recover(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected a panic to answer 500 got %d", w.Code)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.Write([]byte("done"))
+	}
+	h := Timeout(10*time.Millisecond, "/api/events")(slow)
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/api/history", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a slow request to time out got %d", w.Code)
+	}
+	h = Timeout(10*time.Millisecond, "/api/export/")(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/api/export/history", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Errorf("expected a stream to run on got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"status": "success"}`, 100)
+	h := Compress(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc"`)
+		io.WriteString(w, body)
+	})
+	get := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		if accept != "" {
+			r.Header.Set("Accept-Encoding", accept)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	w := get("br;q=1.0, gzip;q=0.8")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") != `W/"abc"` || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected a gzipped response got %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != body {
+		t.Errorf("expected the body to be gzipped got %q", b)
+	}
+	for _, accept := range []string{"", "gzip;q=0", "identity"} {
+		if w := get(accept); w.Header().Get("Content-Encoding") != "" || w.Body.String() != body {
+			t.Errorf("expected no compression for %q got %v", accept, w.Header())
+		}
+	}
+
+	png := Compress(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(make([]byte, 2048))
+	})
+	r := httptest.NewRequest(http.MethodGet, "/badge/web.png", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	png(w, r)
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("expected images not to be compressed got %v", w.Header())
+	}
+}
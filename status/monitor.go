@@ -0,0 +1,421 @@
+package status
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// State describes the condition of a service after a check
+type State string
+
+// States a service can be reported in
+const (
+	StateUp   State = "up"
+	StateDown State = "down"
+	// StateDegraded is a service which works but not fully,
+	// e.g. only over one IP family
+	StateDegraded State = "degraded"
+	// StateAffected is a failing service whose failure is
+	// explained by one of its dependencies being down
+	StateAffected State = "affected"
+	// StateMaintenance is a service inside a maintenance window
+	StateMaintenance State = "maintenance"
+)
+
+// Result holds the outcome of checking a single service
+type Result struct {
+	Service *Service
+	State   State
+	Err     error
+	Latency time.Duration
+	// Timings breaks Latency down for HTTP checks
+	Timings *Timings
+	// Message explains the state, e.g. the maintenance message
+	Message string
+	// Flapping is set when the service is changing state too often
+	Flapping bool
+	// Diagnostics holds evidence collected once the service went
+	// down, such as a traceroute hop report, until the next sweep
+	Diagnostics string
+	// Since is when the service entered its current state
+	Since time.Time
+	// Regions maps each region reporting the service, including
+	// the local one, to the state seen there
+	Regions map[string]State
+	// Incident is the ongoing incident of the service, when
+	// notifications record incidents
+	Incident *Incident
+	// Uptime is the uptime of the service over the UptimeWindows,
+	// and Days each of the last UptimeDays, when the monitor keeps
+	// a history
+	Uptime []WindowUptime
+	Days   []DayUptime
+}
+
+// DegradedError is returned by a check when the service
+// works, but not fully
+type DegradedError struct {
+	Reason string
+}
+
+func (e *DegradedError) Error() string {
+	return "commands: degraded: " + e.Reason
+}
+
+// Degraded returns a DegradedError for reason
+func Degraded(reason string) error {
+	return &DegradedError{Reason: reason}
+}
+
+// IsDegraded reports whether err is a DegradedError
+func IsDegraded(err error) bool {
+	_, ok := err.(*DegradedError)
+	return ok
+}
+
+// Monitor checks a set of services and remembers when
+// each of them last changed state
+type Monitor struct {
+	Pingers       []Pinger
+	Notifications *NotificationManager
+	Maintenance   *MaintenanceRegistry
+	// Regions holds the reports of remote agents. When set, the
+	// local result is combined with theirs and a service is only
+	// down once Quorum regions (default a majority) agree.
+	Regions     *RegionStore
+	LocalRegion string
+	Quorum      int
+	// History records the outcome of every check, nil keeps none.
+	// With HistoryWriter set the records are written through it in
+	// batches.
+	History       StorageBackend
+	HistoryWriter *StatusWriter
+	// Metrics pushes the results of every sweep, nil pushes none
+	Metrics *MetricsPusher
+
+	mu    sync.Mutex
+	since map[string]time.Time
+	last  map[string]State
+	// consecutive counts the consecutive failures (negative) or
+	// successes (positive) of each service
+	consecutive map[string]int
+	// baselines are the response times of the services with
+	// anomaly detection
+	baselines map[string]*baseline
+	results   []Result
+}
+
+// NewMonitor returns a Monitor for the pingers. nm may be nil
+// when no notifications should be sent.
+func NewMonitor(pingers []Pinger, nm *NotificationManager) *Monitor {
+	return &Monitor{
+		Pingers:       pingers,
+		Notifications: nm,
+		since:         make(map[string]time.Time),
+		last:          make(map[string]State),
+		consecutive:   make(map[string]int),
+		baselines:     make(map[string]*baseline),
+	}
+}
+
+// SetPinger checks p in place of the pinger of the service with the
+// same ID, or after the other pingers
+func (m *Monitor) SetPinger(p Pinger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.Pingers {
+		if existing.GetService().ID() == p.GetService().ID() {
+			m.Pingers[i] = p
+			return
+		}
+	}
+	m.Pingers = append(m.Pingers, p)
+}
+
+// RemovePinger stops checking the service with the given ID and
+// forgets its state, reporting whether it was checked
+func (m *Monitor) RemovePinger(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.Pingers {
+		if p.GetService().ID() == id {
+			m.Pingers = append(m.Pingers[:i:i], m.Pingers[i+1:]...)
+			delete(m.since, id)
+			delete(m.last, id)
+			delete(m.consecutive, id)
+			delete(m.baselines, id)
+			return true
+		}
+	}
+	return false
+}
+
+// Services returns the services checked
+func (m *Monitor) Services() []Service {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	services := make([]Service, len(m.Pingers))
+	for i, p := range m.Pingers {
+		services[i] = *p.GetService()
+	}
+	return services
+}
+
+// CheckAllServices checks every service, resolves dependencies and
+// notifies on state changes. Results are returned in pinger order.
+func (m *Monitor) CheckAllServices() []Result {
+	m.mu.Lock()
+	pingers := append([]Pinger(nil), m.Pingers...)
+	m.mu.Unlock()
+	results := make([]Result, len(pingers))
+	byID := make(map[string]*Result, len(pingers))
+
+	for i, p := range pingers {
+		if mw, ok := m.Maintenance.InMaintenance(p.GetService(), time.Now()); ok {
+			results[i] = Result{Service: p.GetService(), State: StateMaintenance, Message: mw.Message}
+			byID[results[i].Service.ID()] = &results[i]
+			continue
+		}
+
+		start := time.Now()
+		err := p.Status()
+		results[i] = Result{Service: p.GetService(), State: StateUp, Err: err, Latency: time.Since(start)}
+		if t, ok := p.(Timed); ok {
+			timings := t.Timings()
+			results[i].Timings = &timings
+		}
+		if IsDegraded(err) {
+			results[i].State = StateDegraded
+			results[i].Message = err.(*DegradedError).Reason
+		} else if err != nil {
+			results[i].State = StateDown
+		}
+		byID[results[i].Service.ID()] = &results[i]
+	}
+
+	if m.Regions != nil {
+		local := m.LocalRegion
+		if local == "" {
+			local = "local"
+		}
+		now := time.Now()
+		for i := range results {
+			if results[i].State != StateMaintenance {
+				applyQuorum(&results[i], local, m.Regions.States(results[i].Service.ID(), now), m.Quorum)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range results {
+		m.detectAnomaly(&results[i], time.Now())
+		m.confirm(&results[i])
+	}
+	degradePools(results)
+
+	// decide against the confirmed results first so that marking one
+	// service affected can't hide the root cause from another
+	affected := make(map[string]bool)
+	for i := range results {
+		r := &results[i]
+		if r.State == StateDown && dependencyDown(r.Service, byID) {
+			affected[r.Service.ID()] = true
+		}
+	}
+
+	now := time.Now()
+	var traced []*Result
+	for i := range results {
+		r := &results[i]
+		id := r.Service.ID()
+		if affected[id] {
+			r.State = StateAffected
+		}
+
+		if m.last[id] != r.State {
+			m.last[id] = r.State
+			m.since[id] = now
+			if r.State == StateDown && r.Service.Traceroute {
+				traced = append(traced, r)
+			}
+		}
+		r.Since = m.since[id]
+
+		// affected services are suppressed, the alert for the
+		// dependency that is down explains the failure
+		if m.Notifications == nil || r.State == StateAffected || r.State == StateMaintenance {
+			continue
+		}
+		message := ""
+		if r.Err != nil {
+			message = r.Err.Error()
+		}
+		m.Notifications.NotifyState(r.Service, r.State, message)
+		r.Flapping = m.Notifications.IsFlapping(id)
+		if m.Notifications.Storage != nil {
+			ctx, cancel := storageContext()
+			inc, ok, err := m.Notifications.Storage.OngoingIncident(ctx, id)
+			cancel()
+			if err != nil {
+				log.Printf("load incident of %s: %v", id, err)
+			}
+			if ok {
+				r.Incident = &inc
+			}
+		}
+	}
+
+	// a trace takes up to a second a hop, so it runs apart from the
+	// sweep and its report follows the alert
+	for _, r := range traced {
+		incident := ""
+		if r.Incident != nil {
+			incident = r.Incident.ID
+		}
+		go m.diagnose(r.Service, incident, now)
+	}
+
+	m.record(results, now)
+	if m.Metrics != nil {
+		m.Metrics.Record(results, now)
+	}
+	m.results = results
+	return results
+}
+
+// diagnose traces the network path to a service which went down at
+// since and adds the hop report as an update to its incident, when it
+// has one, and to its result while the service is still down
+func (m *Monitor) diagnose(s *Service, incident string, since time.Time) {
+	report := traceService(s)
+
+	m.mu.Lock()
+	for i := range m.results {
+		r := &m.results[i]
+		if r.Service.ID() == s.ID() && r.State == StateDown && r.Since.Equal(since) {
+			r.Diagnostics = report
+		}
+	}
+	m.mu.Unlock()
+
+	if incident == "" || m.Notifications == nil || m.Notifications.Storage == nil {
+		return
+	}
+	ctx, cancel := storageContext()
+	defer cancel()
+	if _, err := m.Notifications.Storage.AddIncidentUpdate(ctx, incident, IncidentUpdate{Time: time.Now(), Message: report}); err != nil {
+		log.Printf("record diagnostics of %s: %v", s.ID(), err)
+	}
+}
+
+// record keeps the results in the history and sets their uptime
+func (m *Monitor) record(results []Result, now time.Time) {
+	if m.History == nil {
+		return
+	}
+	records := make([]StatusRecord, len(results))
+	for i, r := range results {
+		records[i] = StatusRecord{Service: r.Service.ID(), State: r.State, Time: now, ResponseTime: r.Latency, Timings: r.Timings}
+	}
+	ctx, cancel := storageContext()
+	defer cancel()
+	var err error
+	if m.HistoryWriter != nil {
+		err = m.HistoryWriter.RecordStatus(ctx, records...)
+	} else {
+		err = m.History.RecordStatus(ctx, records...)
+	}
+	if err != nil {
+		log.Printf("record status history: %v", err)
+	}
+
+	// the uptime counts the records the writer has not written yet
+	history := m.History
+	if m.HistoryWriter != nil {
+		history = writerHistory{StorageBackend: m.History, w: m.HistoryWriter}
+	}
+	uptimes, err := servicesUptime(ctx, history, "", now)
+	if err != nil {
+		log.Printf("load uptime: %v", err)
+	}
+	ids := make([]string, len(results))
+	for i := range results {
+		results[i].Uptime = uptimes[results[i].Service.ID()]
+		ids[i] = results[i].Service.ID()
+	}
+	days, err := servicesDays(ctx, history, ids, now)
+	if err != nil {
+		log.Printf("load daily uptime: %v", err)
+	}
+	for i := range results {
+		results[i].Days = days[ids[i]]
+	}
+}
+
+// Results returns the results of the last CheckAllServices
+func (m *Monitor) Results() []Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Result(nil), m.results...)
+}
+
+// confirm holds a service in its previous state until it has failed
+// FailuresBeforeDown times, or succeeded SuccessesBeforeUp times, in
+// a row so a single bad probe doesn't flip the page or fire alerts
+func (m *Monitor) confirm(r *Result) {
+	id := r.Service.ID()
+	if r.State == StateMaintenance {
+		delete(m.consecutive, id)
+		return
+	}
+
+	ok := r.State != StateDown
+	n := m.consecutive[id]
+	switch {
+	case ok && n > 0:
+		n++
+	case ok:
+		n = 1
+	case n < 0:
+		n--
+	default:
+		n = -1
+	}
+	m.consecutive[id] = n
+
+	wasDown := m.last[id] == StateDown || m.last[id] == StateAffected
+	switch {
+	case !ok && !wasDown && -n < threshold(r.Service.FailuresBeforeDown):
+		r.State = StateUp
+		if m.last[id] == StateDegraded {
+			r.State = StateDegraded
+		}
+	case ok && wasDown && n < threshold(r.Service.SuccessesBeforeUp):
+		r.State = StateDown
+	}
+}
+
+// threshold returns the number of consecutive results needed
+// to change state, which defaults to one
+func threshold(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// dependencyDown reports whether any service s depends on is down
+// or in maintenance. Dependencies are judged on their own check so a
+// chain of failing services is affected all the way back to the root
+// cause.
+func dependencyDown(s *Service, byID map[string]*Result) bool {
+	for _, dep := range s.DependsOn {
+		if r, ok := byID[dep]; ok && (r.State == StateDown || r.State == StateMaintenance) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakePinger is a Pinger which returns a preset error
+type fakePinger struct {
+	Service
+	err error
+}
+
+func (f *fakePinger) GetService() *Service {
+	return &f.Service
+}
+
+func (f *fakePinger) Status() error {
+	return f.err
+}
+
+func TestCheckAllServicesDependencies(t *testing.T) {
+	fail := errors.New("fail")
+	db := &fakePinger{Service: Service{Name: "db"}, err: fail}
+	api := &fakePinger{Service: Service{Name: "api", DependsOn: []string{"db"}}, err: fail}
+	web := &fakePinger{Service: Service{Name: "web", DependsOn: []string{"api"}}, err: fail}
+	cache := &fakePinger{Service: Service{Name: "cache", DependsOn: []string{"db"}}}
+	docs := &fakePinger{Service: Service{Name: "docs", DependsOn: []string{"cache"}}, err: fail}
+
+	m := NewMonitor([]Pinger{db, api, web, cache, docs}, nil)
+	results := m.CheckAllServices()
+
+	expected := map[string]State{
+		"db":    StateDown,
+		"api":   StateAffected,
+		"web":   StateAffected,
+		"cache": StateUp,
+		"docs":  StateDown,
+	}
+	for _, r := range results {
+		if r.State != expected[r.Service.ID()] {
+			t.Errorf("%s: expected %v got %v", r.Service.ID(), expected[r.Service.ID()], r.State)
+		}
+	}
+}
+
+func TestCheckAllServicesSuppressesAffected(t *testing.T) {
+	fail := errors.New("fail")
+	db := &fakePinger{Service: Service{Name: "db"}, err: fail}
+	api := &fakePinger{Service: Service{Name: "api", DependsOn: []string{"db"}}, err: fail}
+
+	rec := &recordingNotifier{}
+	m := NewMonitor([]Pinger{db, api}, NewNotificationManager([]Notifier{rec}, 0))
+	m.CheckAllServices()
+
+	if len(rec.alerts) != 1 || rec.alerts[0].Service.ID() != "db" {
+		t.Fatalf("expected a single alert for db got %v", rec.alerts)
+	}
+}
+
+func TestCheckAllServicesSince(t *testing.T) {
+	p := &fakePinger{Service: Service{Name: "api"}}
+	m := NewMonitor([]Pinger{p}, nil)
+
+	first := m.CheckAllServices()[0]
+	second := m.CheckAllServices()[0]
+	if !second.Since.Equal(first.Since) {
+		t.Errorf("expected since to be unchanged got %v and %v", first.Since, second.Since)
+	}
+
+	p.err = errors.New("fail")
+	third := m.CheckAllServices()[0]
+	if third.State != StateDown || !third.Since.After(first.Since) {
+		t.Errorf("expected since to move on state change got %v", third.Since)
+	}
+}
+
+func TestCheckAllServicesThresholds(t *testing.T) {
+	p := &fakePinger{Service: Service{Name: "api", FailuresBeforeDown: 3, SuccessesBeforeUp: 2}}
+	m := NewMonitor([]Pinger{p}, nil)
+
+	fail := errors.New("fail")
+	steps := []struct {
+		err      error
+		expected State
+	}{
+		{err: nil, expected: StateUp},
+		{err: fail, expected: StateUp},
+		{err: fail, expected: StateUp},
+		{err: nil, expected: StateUp},
+		{err: fail, expected: StateUp},
+		{err: fail, expected: StateUp},
+		{err: fail, expected: StateDown},
+		{err: nil, expected: StateDown},
+		{err: fail, expected: StateDown},
+		{err: nil, expected: StateDown},
+		{err: nil, expected: StateUp},
+	}
+
+	for i, step := range steps {
+		p.err = step.err
+		if actual := m.CheckAllServices()[0].State; actual != step.expected {
+			t.Errorf("step %d: expected %v got %v", i, step.expected, actual)
+		}
+	}
+}
+
+func TestCheckAllServicesDegraded(t *testing.T) {
+	p := &fakePinger{Service: Service{Name: "api"}, err: Degraded("IPv6 failed")}
+	rec := &recordingNotifier{}
+	m := NewMonitor([]Pinger{p}, NewNotificationManager([]Notifier{rec}, 0))
+
+	r := m.CheckAllServices()[0]
+	if r.State != StateDegraded || r.Message != "IPv6 failed" {
+		t.Errorf("expected degraded got %v %q", r.State, r.Message)
+	}
+	if len(rec.alerts) != 1 || rec.alerts[0].Type != AlertTypeDegraded || rec.alerts[0].Severity != SeverityWarning {
+		t.Errorf("expected a degraded warning got %v", rec.alerts)
+	}
+
+	page := NewPage("test", []Result{r})
+	if page.Status != "warning" || page.Degraded["api"] != "IPv6 failed" {
+		t.Errorf("expected degraded page got %+v", page)
+	}
+}
+
+func TestCheckAllServicesPools(t *testing.T) {
+	down := errors.New("connection refused")
+	a := &fakePinger{Service: Service{Name: "api@10.0.0.1:80", Pool: "api"}}
+	b := &fakePinger{Service: Service{Name: "api@10.0.0.2:80", Pool: "api"}, err: down}
+	db := &fakePinger{Service: Service{Name: "db"}, err: down}
+	rec := &recordingNotifier{}
+	m := NewMonitor([]Pinger{a, b, db}, NewNotificationManager([]Notifier{rec}, 0))
+
+	results := m.CheckAllServices()
+	if results[1].State != StateDegraded || results[1].Message != "1 of 2 instances of api down" {
+		t.Errorf("expected the instance down degraded got %v %q", results[1].State, results[1].Message)
+	}
+	if results[2].State != StateDown {
+		t.Errorf("expected a service without pool down got %v", results[2].State)
+	}
+
+	a.err = down
+	if results = m.CheckAllServices(); results[0].State != StateDown || results[1].State != StateDown {
+		t.Errorf("expected every instance down got %v %v", results[0].State, results[1].State)
+	}
+}
+
+func TestCheckAllServicesTraceroute(t *testing.T) {
+	traced := make(chan string, 1)
+	release := make(chan struct{})
+	defer func(trace func(*Service) string) { traceService = trace }(traceService)
+	traceService = func(s *Service) string {
+		<-release
+		traced <- s.ID()
+		return "traceroute to " + s.ID()
+	}
+
+	nm := NewNotificationManager([]Notifier{&recordingNotifier{}}, 0)
+	nm.Storage, _ = OpenStorage("")
+	api := &fakePinger{Service: Service{Name: "api", Traceroute: true}, err: errors.New("timeout")}
+	m := NewMonitor([]Pinger{api}, nm)
+
+	// the sweep and the readers of its results do not wait for the trace
+	done := make(chan struct{})
+	go func() {
+		m.CheckAllServices()
+		m.Results()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the sweep not to wait for the trace")
+	}
+
+	close(release)
+	<-traced
+	deadline := time.Now().Add(time.Second)
+	for {
+		inc, _, _ := nm.Storage.OngoingIncident(context.Background(), "api")
+		if len(inc.Updates) == 1 && inc.Updates[0].Message == "traceroute to api" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the hop report added to the incident got %+v", inc)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r := m.Results(); r[0].Diagnostics != "traceroute to api" {
+		t.Errorf("expected the hop report in the result got %q", r[0].Diagnostics)
+	}
+}
@@ -0,0 +1,126 @@
+package status
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+// fakeBroker accepts one connection and records the publish, answering
+// with the acknowledgements of its QoS
+func fakeBroker(t *testing.T, returnCode byte) (string, <-chan []byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := make(chan []byte, 1)
+
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		if _, _, err := readMQTTPacket(r); err != nil {
+			return
+		}
+		writeMQTTPacket(conn, mqttConnack<<4, []byte{0, returnCode})
+
+		for {
+			header, body, err := readMQTTPacket(r)
+			if err != nil {
+				return
+			}
+			switch header >> 4 {
+			case mqttPublish:
+				published <- append([]byte{header}, body...)
+				switch (header >> 1) & 0x03 {
+				case 1:
+					writeMQTTPacket(conn, mqttPuback<<4, []byte{0, 1})
+				case 2:
+					writeMQTTPacket(conn, mqttPubrec<<4, []byte{0, 1})
+				}
+			case mqttPubrel:
+				writeMQTTPacket(conn, mqttPubcomp<<4, []byte{0, 1})
+			case mqttDisconnect:
+				return
+			}
+		}
+	}()
+
+	return "mqtt://user:pass@" + l.Addr().String(), published
+}
+
+func TestMQTTNotifier(t *testing.T) {
+	for _, qos := range []int{0, 1, 2} {
+		addr, published := fakeBroker(t, 0)
+		n := &MQTTNotifier{URL: addr, Topic: "status/{service}", QoS: qos, Retain: true}
+		if err := n.Notify(Alert{Type: AlertTypeDown, Service: Service{Name: "api"}}); err != nil {
+			t.Fatalf("qos %d: expected nil got %v", qos, err)
+		}
+
+		packet := <-published
+		if packet[0]&0x01 == 0 || int(packet[0]>>1&0x03) != qos {
+			t.Errorf("qos %d: unexpected publish flags %08b", qos, packet[0])
+		}
+		topicLen := int(packet[1])<<8 | int(packet[2])
+		if topic := string(packet[3 : 3+topicLen]); topic != "status/api" {
+			t.Errorf("expected %v got %v", "status/api", topic)
+		}
+		payload := packet[3+topicLen:]
+		if qos > 0 {
+			payload = payload[2:]
+		}
+		var a Alert
+		if err := json.Unmarshal(payload, &a); err != nil || a.Type != AlertTypeDown {
+			t.Errorf("qos %d: unexpected payload %s", qos, payload)
+		}
+	}
+}
+
+func TestMQTTNotifierRefused(t *testing.T) {
+	addr, _ := fakeBroker(t, 5)
+	n := &MQTTNotifier{URL: addr, Topic: "status"}
+	if err := n.Notify(Alert{Type: AlertTypeDown}); err != ErrMQTTRefused {
+		t.Errorf("expected %v got %v", ErrMQTTRefused, err)
+	}
+}
+
+func TestNewNotifierMQTTErrors(t *testing.T) {
+	tt := []struct {
+		name     string
+		config   NotifierConfig
+		expected error
+	}{
+		{name: "no topic", config: NotifierConfig{Type: "mqtt", URL: "mqtt://broker"}, expected: ErrMQTTMissingTopic},
+		{name: "bad qos", config: NotifierConfig{Type: "mqtt", URL: "mqtt://broker", Topic: "status", QoS: 3}, expected: ErrInvalidQoS},
+		{name: "bad url", config: NotifierConfig{Type: "mqtt", URL: "broker", Topic: "status"}, expected: ErrInvalidMQTTURL},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewNotifier(tc.config); err != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestMQTTRemainingLength(t *testing.T) {
+	r, w := net.Pipe()
+	defer r.Close()
+	body := make([]byte, 321)
+	go func() {
+		writeMQTTPacket(w, mqttPublish<<4, body)
+		w.Close()
+	}()
+
+	_, got, err := readMQTTPacket(bufio.NewReader(r))
+	if err != nil || len(got) != len(body) {
+		t.Errorf("expected %d bytes got %d (%v)", len(body), len(got), err)
+	}
+}
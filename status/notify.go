@@ -0,0 +1,733 @@
+package status
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"net/url"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Errors returned by the notifications
+var (
+	ErrInvalidNotifier = errors.New("notify: invalid notifier type")
+	ErrQueueFull       = errors.New("notify: notification queue full")
+)
+
+// StatusError is returned by a notifier whose endpoint answered with
+// a status outside 2xx. It wraps the error of the notifier, such as
+// ErrWebhookRejected.
+type StatusError struct {
+	Err  error
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v (status %d)", e.Err, e.Code)
+}
+
+// Unwrap returns the error of the notifier
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// AlertType describes the state change an alert reports
+type AlertType string
+
+// Alert types sent to notifiers
+const (
+	AlertTypeDown     AlertType = "down"
+	AlertTypeDegraded AlertType = "degraded"
+	AlertTypeRecovery AlertType = "recovery"
+	AlertTypeFlapping AlertType = "flapping"
+	// AlertTypeDigest is a scheduled summary, see DigestSchedule
+	AlertTypeDigest AlertType = "digest"
+	// AlertTypeTest checks a notifier works, see TestNotifiers
+	AlertTypeTest AlertType = "test"
+	// AlertTypeBurnRate warns that the error budget of a service
+	// is spent too fast, see SLOMonitor, and AlertTypeBurnRateResolved
+	// that the burn slowed down again
+	AlertTypeBurnRate         AlertType = "burn_rate"
+	AlertTypeBurnRateResolved AlertType = "burn_rate_resolved"
+)
+
+// Color returns the colour an alert type is shown in by
+// notifiers which support one, matching the status page
+func (t AlertType) Color() string {
+	switch t {
+	case AlertTypeDown:
+		return "#dc3545"
+	case AlertTypeRecovery, AlertTypeBurnRateResolved:
+		return "#28a745"
+	case AlertTypeDigest:
+		return "#17a2b8"
+	}
+	return "#ffc107"
+}
+
+// Alert is a single notification about a service
+type Alert struct {
+	Type     AlertType `json:"type"`
+	Severity Severity  `json:"severity"`
+	Service  Service   `json:"service"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+	// Duration is how long the service was down, set on
+	// recovery alerts and reminders
+	Duration time.Duration `json:"duration,omitempty"`
+	// Since is when the outage started and Cause the message it
+	// started with, set on recovery alerts
+	Since time.Time `json:"since"`
+	Cause string    `json:"cause,omitempty"`
+	// Escalation is the escalation tier the alert is sent to,
+	// zero for the first alert of an incident
+	Escalation int `json:"escalation,omitempty"`
+	// Digest is the summary sent by digest alerts
+	Digest *Digest `json:"digest,omitempty"`
+	// Reminder counts the reminders sent for an ongoing outage,
+	// zero for other alerts
+	Reminder int `json:"reminder,omitempty"`
+	// Incident is the ID of the incident the alert is about and
+	// AckURL where it can be acknowledged
+	Incident string `json:"incident,omitempty"`
+	AckURL   string `json:"ack_url,omitempty"`
+}
+
+// Title returns a short description of the alert, such
+// as "api is down"
+func (a *Alert) Title() string {
+	switch a.Type {
+	case AlertTypeDigest:
+		return "Status digest"
+	case AlertTypeTest:
+		return "Test notification"
+	case AlertTypeBurnRate:
+		return a.Service.ID() + " is burning its error budget"
+	case AlertTypeBurnRateResolved:
+		return a.Service.ID() + " stopped burning its error budget"
+	}
+	return a.Service.ID() + " is " + string(a.Type)
+}
+
+// Notifier is an interface which describes how
+// to deliver an alert
+type Notifier interface {
+	Notify(Alert) error
+}
+
+// NotifierConfig holds the configuration of a
+// single notifier
+type NotifierConfig struct {
+	Type string `json:"type"`
+	// URL overrides the endpoint alerts are sent to
+	URL string `json:"url,omitempty"`
+	// RoutingKey is the PagerDuty integration key, which may
+	// be "env:NAME"
+	RoutingKey string `json:"routing_key,omitempty"`
+	// APIKey is the Opsgenie API key, which may be "env:NAME",
+	// and Priority the priority of down alerts (default P1)
+	APIKey   string `json:"api_key,omitempty"`
+	Priority string `json:"priority,omitempty"`
+	// Topic is the ntfy topic and Token the ntfy access token or
+	// Gotify application token, which may be "env:NAME"
+	Topic string `json:"topic,omitempty"`
+	Token string `json:"token,omitempty"`
+	// QoS and Retain are the MQTT publish options
+	QoS    int  `json:"qos,omitempty"`
+	Retain bool `json:"retain,omitempty"`
+	// Method, Headers and Username and Password configure webhook
+	// requests. Token is sent as a bearer token. Header values and
+	// the password may be "env:NAME".
+	Method   string            `json:"method,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	// Secret signs webhook payloads, see WebhookNotifier
+	Secret string `json:"secret,omitempty"`
+	// Cooldown limits this notifier to one down or degraded alert
+	// per service in the period. It is kept in memory, so it starts
+	// over on a restart.
+	Cooldown string `json:"cooldown,omitempty"`
+	// Template is a text/template rendered with the Alert to
+	// replace the message sent by the notifier. Templates override
+	// it for the alert types they are set for.
+	Template  string               `json:"template,omitempty"`
+	Templates map[AlertType]string `json:"templates,omitempty"`
+	// MinSeverity drops alerts less urgent than it
+	MinSeverity Severity `json:"min_severity,omitempty"`
+	// QuietHours are windows, as for maintenance, during which
+	// alerts less severe than QuietExcept (default critical) are
+	// held back and summarized once the window ends
+	QuietHours  []MaintenanceWindow `json:"quiet_hours,omitempty"`
+	QuietExcept Severity            `json:"quiet_except,omitempty"`
+}
+
+// NotifierID returns the identity of the notifier of the config, its
+// type and a digest of its settings. It stays the same across restarts
+// and reloads for as long as the settings do.
+func NotifierID(c NotifierConfig) string {
+	b, _ := json.Marshal(c)
+	sum := sha256.Sum256(b)
+	return c.Type + "-" + hex.EncodeToString(sum[:6])
+}
+
+// NewNotifier returns the Notifier described by the config
+func NewNotifier(c NotifierConfig) (Notifier, error) {
+	if err := c.MinSeverity.Validate(); err != nil {
+		return nil, err
+	}
+	n, err := newNotifier(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.QuietHours) > 0 {
+		if n, err = newQuietNotifier(n, c.QuietHours, c.QuietExcept); err != nil {
+			return nil, err
+		}
+	}
+	if c.Template != "" || len(c.Templates) > 0 {
+		tn := &templateNotifier{Notifier: n, byType: make(map[AlertType]*template.Template)}
+		if c.Template != "" {
+			if tn.tpl, err = template.New(c.Type).Parse(c.Template); err != nil {
+				return nil, fmt.Errorf("notify: invalid template: %v", err)
+			}
+		}
+		for typ, text := range c.Templates {
+			if tn.byType[typ], err = template.New(string(typ)).Parse(text); err != nil {
+				return nil, fmt.Errorf("notify: invalid %s template: %v", typ, err)
+			}
+		}
+		n = tn
+	}
+	if c.Cooldown != "" {
+		d, err := time.ParseDuration(c.Cooldown)
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidCooldown
+		}
+		n = &cooldownNotifier{Notifier: n, cooldown: d, last: make(map[string]Alert)}
+	}
+	if c.MinSeverity != "" {
+		n = &severityNotifier{Notifier: n, min: c.MinSeverity}
+	}
+	return n, nil
+}
+
+func newNotifier(c NotifierConfig) (Notifier, error) {
+	switch c.Type {
+	case "log":
+		return &LogNotifier{}, nil
+	case "pagerduty":
+		if c.RoutingKey == "" {
+			return nil, ErrMissingRoutingKey
+		}
+		return &PagerDutyNotifier{RoutingKey: readSecret(c.RoutingKey), URL: c.URL}, nil
+	case "opsgenie":
+		if c.APIKey == "" {
+			return nil, ErrMissingAPIKey
+		}
+		switch c.Priority {
+		case "", "P1", "P2", "P3", "P4", "P5":
+		default:
+			return nil, ErrInvalidPriority
+		}
+		return &OpsgenieNotifier{APIKey: readSecret(c.APIKey), Priority: c.Priority, URL: c.URL}, nil
+	case "ntfy":
+		if c.Topic == "" {
+			return nil, ErrMissingTopic
+		}
+		return &NtfyNotifier{Topic: c.Topic, Token: readSecret(c.Token), URL: c.URL}, nil
+	case "gotify":
+		if c.URL == "" || c.Token == "" {
+			return nil, ErrMissingGotifyConfig
+		}
+		return &GotifyNotifier{URL: c.URL, Token: readSecret(c.Token)}, nil
+	case "mqtt":
+		if c.Topic == "" {
+			return nil, ErrMQTTMissingTopic
+		}
+		if c.QoS < 0 || c.QoS > 2 {
+			return nil, ErrInvalidQoS
+		}
+		if u, err := url.Parse(c.URL); err != nil || u.Host == "" {
+			return nil, ErrInvalidMQTTURL
+		}
+		return &MQTTNotifier{URL: c.URL, Topic: c.Topic, QoS: c.QoS, Retain: c.Retain}, nil
+	case "webhook":
+		return newWebhookNotifier(c)
+	}
+	return nil, ErrInvalidNotifier
+}
+
+// templateNotifier renders the message of each alert with the
+// template of its type, or the default one, before passing it on
+type templateNotifier struct {
+	Notifier
+	tpl    *template.Template
+	byType map[AlertType]*template.Template
+}
+
+// Notify renders the message and notifies the wrapped Notifier
+func (n *templateNotifier) Notify(a Alert) error {
+	tpl := n.tpl
+	if t, ok := n.byType[a.Type]; ok {
+		tpl = t
+	}
+	if tpl == nil {
+		return n.Notifier.Notify(a)
+	}
+
+	var b bytes.Buffer
+	if err := tpl.Execute(&b, &a); err != nil {
+		return err
+	}
+	a.Message = b.String()
+	return n.Notifier.Notify(a)
+}
+
+// LogNotifier writes alerts to the standard logger
+type LogNotifier struct{}
+
+// Notify logs the alert
+func (n *LogNotifier) Notify(a Alert) error {
+	if a.Type == AlertTypeDigest {
+		log.Printf("[%s]\n%s", a.Type, a.Message)
+		return nil
+	}
+	if a.Message != "" {
+		log.Printf("[%s] %s: %s", a.Type, a.Service.ID(), a.Message)
+		return nil
+	}
+	log.Printf("[%s] %s", a.Type, a.Service.ID())
+	return nil
+}
+
+// alertState is the last known state of a service
+// and when it was last alerted on
+type alertState struct {
+	state State
+	// lastAlert is when the last alert was sent and lastSeverity its
+	// severity, against which the cooldown lets a worse one through
+	lastAlert    time.Time
+	lastSeverity Severity
+	// downSince is when the service last stopped being up, cause
+	// the message it stopped with and severity the highest severity
+	// alerted on since
+	downSince time.Time
+	cause     string
+	severity  Severity
+	// changes holds the times of recent state changes,
+	// used to detect flapping
+	changes  []time.Time
+	flapping bool
+	// incident is the ID of the ongoing incident and timers
+	// fire its escalations about service
+	incident string
+	timers   []*time.Timer
+	service  Service
+	// message is the last check error, reminders the number sent
+	// while down and remindGen tells the reminders of the current
+	// outage from those of earlier ones
+	message   string
+	reminders int
+	remindGen int
+}
+
+// NotificationManager tracks the state of each service and
+// notifies when a service goes down or recovers
+type NotificationManager struct {
+	Notifiers []Notifier
+	// NotifierIDs are the identities of the Notifiers, see NotifierID.
+	// A notifier without one is known by its index.
+	NotifierIDs   []string
+	AlertCooldown time.Duration
+	// FlapThreshold is the number of state changes within FlapWindow
+	// above which a service is flapping. Zero disables detection.
+	FlapThreshold int
+	FlapWindow    time.Duration
+	// Retries is how many times a failed delivery is retried, waiting
+	// RetryBackoff doubled after each attempt, with jitter
+	Retries      int
+	RetryBackoff time.Duration
+	// DeadLetters keeps the alerts which could not be delivered
+	// after all retries, nil drops them
+	DeadLetters *DeadLetterStore
+	// Storage records an incident while a service is not up, nil
+	// keeps none. Escalations need it.
+	Storage     StorageBackend
+	Escalations []Escalation
+	// ReminderInterval is how often a reminder is sent while a
+	// service stays down, up to MaxReminders (zero is no limit).
+	// Zero disables reminders.
+	ReminderInterval time.Duration
+	MaxReminders     int
+	// BaseURL is the public URL of the status page, used to link
+	// alerts to the acknowledgement of their incident
+	BaseURL string
+
+	mu     sync.Mutex
+	states map[string]*alertState
+	// queuesMu guards queues, which Stop closes while alerts may
+	// still be dispatched
+	queuesMu sync.RWMutex
+	queues   []chan delivery
+	wg       sync.WaitGroup
+
+	// managed holds the enabled managed notifiers by ID.
+	// notifiersMu guards them, and the Notifiers and Escalations
+	// once a reload may replace them.
+	notifiersMu sync.RWMutex
+	managed     map[int]Notifier
+}
+
+// Delivery retry defaults of a NotificationManager
+const (
+	defaultRetries      = 3
+	defaultRetryBackoff = time.Second
+)
+
+// NewNotificationManager returns a NotificationManager which sends
+// alerts to notifiers, at most once per cooldown for each service
+func NewNotificationManager(notifiers []Notifier, cooldown time.Duration) *NotificationManager {
+	return &NotificationManager{
+		Notifiers:     notifiers,
+		AlertCooldown: cooldown,
+		Retries:       defaultRetries,
+		RetryBackoff:  defaultRetryBackoff,
+		states:        make(map[string]*alertState),
+	}
+}
+
+// CheckAndNotify records whether a service is up or down and sends
+// an alert when it changes, see NotifyState
+func (nm *NotificationManager) CheckAndNotify(s *Service, up bool, message string) {
+	state := StateUp
+	if !up {
+		state = StateDown
+	}
+	nm.NotifyState(s, state, message)
+}
+
+// NotifyState records the state of a service, up, degraded or down,
+// and sends an alert when it changes. Services are assumed to be up
+// when first seen. While a service is flapping a single flapping alert
+// is sent in place of its individual alerts, followed by an alert for
+// the state it settles in.
+//
+// Down alerts have the severity of the service, degraded and flapping
+// alerts are at most warnings, and recoveries have the highest
+// severity alerted on since the service stopped being up so they reach
+// every notifier which was told of the problem.
+func (nm *NotificationManager) NotifyState(s *Service, state State, message string) {
+	if a, escalated, ok := nm.update(s, state, message); ok {
+		nm.dispatch(a, nm.targets(escalated))
+	}
+}
+
+// update records the state of a service and returns the alert to
+// send, if any, and how many escalation tiers should also receive it
+func (nm *NotificationManager) update(s *Service, state State, message string) (Alert, int, bool) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	now := time.Now()
+	st, ok := nm.states[s.ID()]
+	if !ok {
+		st = nm.newState(s)
+		nm.states[s.ID()] = st
+	}
+	changed := st.state != state
+	started := changed && st.state == StateUp
+	if started {
+		st.downSince = now
+		st.severity = ""
+		st.cause = message
+	}
+	st.state = state
+	st.message = message
+	if changed && (started || state == StateUp) {
+		nm.armReminders(st, *s)
+	}
+
+	var resolved Incident
+	if changed && nm.Storage != nil {
+		resolved = nm.recordIncident(st, s, message, started, now)
+	}
+
+	if nm.FlapThreshold > 0 {
+		if changed {
+			st.changes = append(st.changes, now)
+		}
+		for len(st.changes) > 0 && now.Sub(st.changes[0]) > nm.FlapWindow {
+			st.changes = st.changes[1:]
+		}
+
+		flapping := len(st.changes) > nm.FlapThreshold
+		switch {
+		case flapping && !st.flapping:
+			st.flapping = true
+			return nm.alerted(st, Alert{
+				Type:     AlertTypeFlapping,
+				Severity: stateSeverity(s, StateDegraded),
+				Service:  *s,
+				Message:  fmt.Sprintf("changed state %d times in %v", len(st.changes), nm.FlapWindow),
+				Time:     now,
+			}), 0, true
+		case flapping:
+			return Alert{}, 0, false
+		case st.flapping:
+			st.flapping = false
+			changed = true
+		}
+	}
+
+	if !changed {
+		return Alert{}, 0, false
+	}
+
+	alert := Alert{Service: *s, Message: message, Severity: stateSeverity(s, state), Time: now, Incident: st.incident}
+	switch state {
+	case StateUp:
+		alert.Type = AlertTypeRecovery
+		alert.Severity = st.severity
+		if alert.Severity == "" {
+			alert.Severity = SeverityInfo
+		}
+		alert.Since, alert.Cause = st.downSince, st.cause
+		if resolved.ID != "" {
+			alert.Incident = resolved.ID
+			alert.Since, alert.Cause = resolved.Start, resolved.Message
+		}
+		alert.Duration = now.Sub(alert.Since)
+		alert.Message = recoveryMessage(alert)
+		return nm.alerted(st, alert), resolved.Escalations, true
+	case StateDegraded:
+		alert.Type = AlertTypeDegraded
+		alert.AckURL = nm.ackURL(st.incident)
+	default:
+		alert.Type = AlertTypeDown
+		alert.AckURL = nm.ackURL(st.incident)
+	}
+	if now.Sub(st.lastAlert) < nm.cooldown(s) && !worsens(alert.Severity, st.lastSeverity) {
+		return Alert{}, 0, false
+	}
+	return nm.alerted(st, alert), 0, true
+}
+
+// recoveryMessage describes the outage a recovery alert ends
+func recoveryMessage(a Alert) string {
+	msg := fmt.Sprintf("recovered after %v, down since %s", a.Duration.Round(time.Second), a.Since.Format("2006-01-02 15:04:05"))
+	if a.Cause != "" {
+		msg += ": " + firstLine(a.Cause)
+	}
+	return msg
+}
+
+// cooldown returns the alert cooldown of a service
+func (nm *NotificationManager) cooldown(s *Service) time.Duration {
+	if d, err := time.ParseDuration(s.AlertCooldown); err == nil {
+		return d
+	}
+	return nm.AlertCooldown
+}
+
+// worsens reports whether an alert of the severity is more urgent than
+// the last alert, of last, so it goes through the cooldown. Without a
+// last severity, as after a restart, it does not.
+func worsens(severity, last Severity) bool {
+	return last != "" && !last.AtLeast(severity)
+}
+
+// alerted records that an alert is sent for the service
+func (nm *NotificationManager) alerted(st *alertState, a Alert) Alert {
+	st.lastAlert, st.lastSeverity = a.Time, a.Severity
+	if nm.Storage != nil {
+		ctx, cancel := storageContext()
+		defer cancel()
+		if err := nm.Storage.SetLastAlert(ctx, a.Service.ID(), a.Time); err != nil {
+			log.Printf("record last alert of %s: %v", a.Service.ID(), err)
+		}
+	}
+	if a.Type != AlertTypeRecovery && a.Severity.AtLeast(st.severity) {
+		st.severity = a.Severity
+	}
+	return a
+}
+
+// IsFlapping reports whether the service with the given id
+// is currently flapping
+func (nm *NotificationManager) IsFlapping(id string) bool {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	st, ok := nm.states[id]
+	return ok && st.flapping
+}
+
+// delivery is an alert queued for a notifier, known by its identity
+// so a reload which moves or removes notifiers never sends it to
+// another
+type delivery struct {
+	notifier string
+	alert    Alert
+}
+
+// Start delivers alerts in the background from now on, so slow
+// notifiers do not hold up checks. Each of the workers has a queue of
+// size alerts; the alerts of a service always go to the same worker so
+// they are delivered in order. Alerts which do not fit in a full queue
+// go straight to the dead letters.
+func (nm *NotificationManager) Start(workers, size int) {
+	nm.queuesMu.Lock()
+	defer nm.queuesMu.Unlock()
+	nm.queues = make([]chan delivery, workers)
+	for i := range nm.queues {
+		q := make(chan delivery, size)
+		nm.queues[i] = q
+		nm.wg.Add(1)
+		go func() {
+			defer nm.wg.Done()
+			for d := range q {
+				nm.deliver(d.notifier, d.alert)
+			}
+		}()
+	}
+}
+
+// Stop waits for the queued alerts to be delivered and stops the
+// workers. Alerts dispatched meanwhile wait for them, so those of a
+// service stay in order, and are delivered synchronously afterwards.
+func (nm *NotificationManager) Stop() {
+	nm.queuesMu.Lock()
+	defer nm.queuesMu.Unlock()
+	for _, q := range nm.queues {
+		close(q)
+	}
+	nm.wg.Wait()
+	nm.queues = nil
+}
+
+// dispatch sends an alert to the target notifiers, by identity,
+// through the queue of its service once the workers are started
+func (nm *NotificationManager) dispatch(a Alert, targets []string) {
+	nm.queuesMu.RLock()
+	if len(nm.queues) == 0 {
+		nm.queuesMu.RUnlock()
+		for _, id := range targets {
+			nm.deliver(id, a)
+		}
+		return
+	}
+	defer nm.queuesMu.RUnlock()
+
+	h := fnv.New32a()
+	h.Write([]byte(a.Service.ID()))
+	q := nm.queues[h.Sum32()%uint32(len(nm.queues))]
+	for _, id := range targets {
+		select {
+		case q <- delivery{notifier: id, alert: a}:
+		default:
+			log.Printf("notify %s: queue full", a.Service.ID())
+			_, i := nm.lookup(id)
+			nm.audit(i, a, ErrQueueFull, 0, 0)
+			nm.deadLetter(id, i, a, ErrQueueFull, 0)
+		}
+	}
+}
+
+// deliver sends an alert to the notifier with the identity id,
+// retrying failures. Alerts which are still not delivered go to the
+// dead letters, while those held for quiet hours wait for its summary.
+func (nm *NotificationManager) deliver(id string, a Alert) {
+	n, i := nm.lookup(id)
+	if n == nil {
+		// a notifier removed since the alert was queued
+		return
+	}
+	var err error
+	var latency time.Duration
+	attempts := 0
+	for attempts <= nm.Retries {
+		if attempts > 0 {
+			time.Sleep(retryDelay(nm.RetryBackoff, attempts))
+		}
+		attempts++
+		start := time.Now()
+		err = nm.notify(id, n, a)
+		latency = time.Since(start)
+		if err == nil || errors.Is(err, ErrAlertHeld) {
+			nm.audit(i, a, err, attempts, latency)
+			return
+		}
+	}
+	nm.audit(i, a, err, attempts, latency)
+	log.Printf("notify %s: giving up after %d attempts: %v", a.Service.ID(), attempts, err)
+	nm.deadLetter(id, i, a, err, attempts)
+}
+
+// notify sends an alert to the notifier n with the identity id once.
+// The summary of its quiet hours is delivered to it like an alert.
+func (nm *NotificationManager) notify(id string, n Notifier, a Alert) error {
+	if q := quietHours(n); q != nil {
+		q.deliverBy(func(summary Alert) { nm.dispatch(summary, []string{id}) })
+	}
+	return n.Notify(a)
+}
+
+// deadLetter keeps an alert which could not be delivered to the
+// notifier with the identity id, at index i
+func (nm *NotificationManager) deadLetter(id string, i int, a Alert, err error, attempts int) {
+	if nm.DeadLetters == nil {
+		return
+	}
+	l := DeadLetter{Notifier: i, NotifierID: id, Alert: a, Error: err.Error(), Attempts: attempts, Time: time.Now()}
+	if err := nm.DeadLetters.Add(l); err != nil {
+		log.Printf("store dead letter for %s: %v", a.Service.ID(), err)
+	}
+}
+
+// retryDelay returns the wait before the given retry, base doubled
+// for each previous retry. Half of it is random so notifiers failing
+// together do not retry in lockstep.
+func retryDelay(base time.Duration, retry int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base << uint(retry-1)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Resend delivers a dead letter again to its notifier, removing it
+// from the store once delivered. It returns ErrNotifierGone when no
+// notifier has the identity of the one which failed any longer, as
+// when its settings changed, so the alert never reaches another.
+func (nm *NotificationManager) Resend(id string) error {
+	if nm.DeadLetters == nil {
+		return ErrDeadLetterNotFound
+	}
+	for _, l := range nm.DeadLetters.List() {
+		if l.ID != id {
+			continue
+		}
+		n, i := nm.lookup(l.NotifierID)
+		if n == nil {
+			return ErrNotifierGone
+		}
+		start := time.Now()
+		err := nm.notify(l.NotifierID, n, l.Alert)
+		nm.audit(i, l.Alert, err, 1, time.Since(start))
+		if err != nil && !errors.Is(err, ErrAlertHeld) {
+			return err
+		}
+		_, _, err = nm.DeadLetters.Remove(id)
+		return err
+	}
+	return ErrDeadLetterNotFound
+}
@@ -0,0 +1,426 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// recordingNotifier is a Notifier which keeps every alert
+type recordingNotifier struct {
+	alerts []Alert
+}
+
+func (r *recordingNotifier) Notify(a Alert) error {
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func TestCheckAndNotify(t *testing.T) {
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	s := &Service{Name: "api"}
+
+	nm.CheckAndNotify(s, true, "")
+	nm.CheckAndNotify(s, false, "connection refused")
+	nm.CheckAndNotify(s, false, "connection refused")
+	nm.CheckAndNotify(s, true, "")
+
+	if len(rec.alerts) != 2 {
+		t.Fatalf("expected 2 alerts got %d", len(rec.alerts))
+	}
+	if rec.alerts[0].Type != AlertTypeDown || rec.alerts[0].Message != "connection refused" {
+		t.Errorf("expected down alert got %+v", rec.alerts[0])
+	}
+	if rec.alerts[1].Type != AlertTypeRecovery {
+		t.Errorf("expected recovery alert got %+v", rec.alerts[1])
+	}
+}
+
+func TestCheckAndNotifyCooldown(t *testing.T) {
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, time.Hour)
+	s := &Service{Name: "api"}
+
+	nm.CheckAndNotify(s, false, "")
+	nm.CheckAndNotify(s, true, "")
+	nm.CheckAndNotify(s, false, "")
+
+	if len(rec.alerts) != 2 {
+		t.Fatalf("expected 2 alerts got %d", len(rec.alerts))
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	if _, err := NewNotifier(NotifierConfig{Type: "log"}); err != nil {
+		t.Errorf("expected nil got %v", err)
+	}
+	if _, err := NewNotifier(NotifierConfig{Type: "carrier-pigeon"}); err != ErrInvalidNotifier {
+		t.Errorf("expected %v got %v", ErrInvalidNotifier, err)
+	}
+}
+
+func TestNotifierID(t *testing.T) {
+	a := NotifierConfig{Type: "webhook", URL: "https://hooks.example.com/a"}
+	b := NotifierConfig{Type: "webhook", URL: "https://hooks.example.com/b"}
+	if NotifierID(a) != NotifierID(a) || NotifierID(a) == NotifierID(b) || !strings.HasPrefix(NotifierID(a), "webhook-") {
+		t.Errorf("expected an identity per settings got %s %s", NotifierID(a), NotifierID(b))
+	}
+
+	// a notifier configured twice is told apart, and one without an
+	// identity is known by its index
+	nm := NewNotificationManager([]Notifier{&recordingNotifier{}, &recordingNotifier{}, &recordingNotifier{}}, 0)
+	nm.NotifierIDs = []string{NotifierID(a), NotifierID(a)}
+	second := nm.Notifiers[1]
+	if n, i := nm.lookup(NotifierID(a) + "-2"); n != second || i != 1 {
+		t.Errorf("expected the second notifier got %d", i)
+	}
+	if _, i := nm.lookup("notifier-2"); i != 2 {
+		t.Errorf("expected the third notifier got %d", i)
+	}
+	if n, _ := nm.lookup(NotifierID(b)); n != nil {
+		t.Errorf("expected no notifier got %v", n)
+	}
+}
+
+func TestCheckAndNotifyFlapping(t *testing.T) {
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	nm.FlapThreshold = 3
+	nm.FlapWindow = time.Hour
+	s := &Service{Name: "api"}
+
+	// down, up, down alert individually; the fourth change flaps
+	for _, up := range []bool{false, true, false, true, false, true} {
+		nm.CheckAndNotify(s, up, "")
+	}
+
+	expected := []AlertType{AlertTypeDown, AlertTypeRecovery, AlertTypeDown, AlertTypeFlapping}
+	if len(rec.alerts) != len(expected) {
+		t.Fatalf("expected %d alerts got %v", len(expected), rec.alerts)
+	}
+	for i, a := range rec.alerts {
+		if a.Type != expected[i] {
+			t.Errorf("alert %d: expected %v got %v", i, expected[i], a.Type)
+		}
+	}
+	if !nm.IsFlapping("api") {
+		t.Fatal("expected service to be flapping")
+	}
+
+	// once the changes age out of the window the settled state is sent
+	nm.states["api"].changes = nil
+	nm.CheckAndNotify(s, true, "")
+	if nm.IsFlapping("api") {
+		t.Error("expected service to stop flapping")
+	}
+	if last := rec.alerts[len(rec.alerts)-1]; len(rec.alerts) != 5 || last.Type != AlertTypeRecovery {
+		t.Errorf("expected a settled recovery alert got %v", rec.alerts)
+	}
+}
+
+func TestTemplateNotifier(t *testing.T) {
+	rec := &recordingNotifier{}
+	tpl := template.Must(template.New("test").Parse(`{{.Service.ID}} {{.Type}}: {{.Message}} (runbook https://wiki/{{.Service.Name}})`))
+	n := &templateNotifier{Notifier: rec, tpl: tpl}
+
+	if err := n.Notify(Alert{Type: AlertTypeDown, Service: Service{Name: "api"}, Message: "timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "api down: timeout (runbook https://wiki/api)"
+	if rec.alerts[0].Message != expected {
+		t.Errorf("expected %q got %q", expected, rec.alerts[0].Message)
+	}
+
+	if _, err := NewNotifier(NotifierConfig{Type: "log", Template: "{{.Broken"}); err == nil {
+		t.Error("expected invalid template error")
+	}
+	if _, ok := mustNotifier(t, NotifierConfig{Type: "log", Template: "{{.Type}}"}).(*templateNotifier); !ok {
+		t.Error("expected templated notifier")
+	}
+}
+
+func TestRecoveryDuration(t *testing.T) {
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	s := &Service{Name: "api"}
+
+	nm.CheckAndNotify(s, false, "timeout")
+	time.Sleep(10 * time.Millisecond)
+	nm.CheckAndNotify(s, true, "")
+
+	if d := rec.alerts[1].Duration; rec.alerts[1].Type != AlertTypeRecovery || d < 10*time.Millisecond {
+		t.Errorf("expected recovery after at least 10ms got %v after %v", rec.alerts[1].Type, d)
+	}
+}
+
+func mustNotifier(t *testing.T, c NotifierConfig) Notifier {
+	n, err := NewNotifier(c)
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	return n
+}
+
+// blockingNotifier waits for release before recording each alert
+type blockingNotifier struct {
+	release chan struct{}
+	mu      sync.Mutex
+	recordingNotifier
+}
+
+func (b *blockingNotifier) Notify(a Alert) error {
+	<-b.release
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.recordingNotifier.Notify(a)
+}
+
+func TestNotificationQueue(t *testing.T) {
+	b := &blockingNotifier{release: make(chan struct{})}
+	nm := NewNotificationManager([]Notifier{b}, 0)
+	nm.DeadLetters, _ = NewDeadLetterStore("")
+	nm.Start(1, 2)
+
+	// checks carry on while the notifier is stuck
+	done := make(chan struct{})
+	go func() {
+		s := &Service{Name: "api"}
+		nm.CheckAndNotify(s, false, "timeout")
+		nm.CheckAndNotify(s, true, "")
+		nm.CheckAndNotify(s, false, "timeout")
+		nm.CheckAndNotify(s, true, "")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected CheckAndNotify not to block")
+	}
+
+	close(b.release)
+	nm.Stop()
+
+	// the worker holds one alert and the queue two more
+	if len(b.alerts) < 2 || b.alerts[0].Type != AlertTypeDown || b.alerts[1].Type != AlertTypeRecovery {
+		t.Errorf("expected alerts in order got %+v", b.alerts)
+	}
+	if n := len(b.alerts) + len(nm.DeadLetters.List()); n != 4 {
+		t.Errorf("expected 4 alerts delivered or dead lettered got %d", n)
+	}
+}
+
+func TestNotificationQueueStop(t *testing.T) {
+	rec := &blockingNotifier{release: make(chan struct{})}
+	close(rec.release)
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	nm.Start(2, 100)
+
+	// alerts dispatched while stopping are delivered, not sent on a
+	// closed queue
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := &Service{Name: fmt.Sprintf("svc%d", i)}
+			for j := 0; j < 10; j++ {
+				nm.CheckAndNotify(s, j%2 == 1, "timeout")
+			}
+		}(i)
+	}
+	nm.Stop()
+	wg.Wait()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.alerts) != 40 {
+		t.Errorf("expected every alert delivered got %d", len(rec.alerts))
+	}
+}
+
+func TestNotifyStateSeverity(t *testing.T) {
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	s := &Service{Name: "api"}
+
+	nm.NotifyState(s, StateDegraded, "slow")
+	nm.NotifyState(s, StateDown, "timeout")
+	nm.NotifyState(s, StateUp, "")
+	nm.NotifyState(s, StateDegraded, "slow")
+	nm.NotifyState(s, StateUp, "")
+
+	expected := []struct {
+		typ      AlertType
+		severity Severity
+	}{
+		{AlertTypeDegraded, SeverityWarning},
+		{AlertTypeDown, SeverityCritical},
+		{AlertTypeRecovery, SeverityCritical},
+		{AlertTypeDegraded, SeverityWarning},
+		{AlertTypeRecovery, SeverityWarning},
+	}
+	if len(rec.alerts) != len(expected) {
+		t.Fatalf("expected %d alerts got %d", len(expected), len(rec.alerts))
+	}
+	for i, e := range expected {
+		if a := rec.alerts[i]; a.Type != e.typ || a.Severity != e.severity {
+			t.Errorf("alert %d: expected %s %s got %s %s", i, e.severity, e.typ, a.Severity, a.Type)
+		}
+	}
+
+	rec.alerts = nil
+	nm.NotifyState(&Service{Name: "batch", Severity: SeverityInfo}, StateDown, "failed")
+	if rec.alerts[0].Severity != SeverityInfo {
+		t.Errorf("expected %s got %s", SeverityInfo, rec.alerts[0].Severity)
+	}
+}
+
+func TestMinSeverity(t *testing.T) {
+	if _, err := NewNotifier(NotifierConfig{Type: "log", MinSeverity: "urgent"}); err != ErrInvalidSeverity {
+		t.Errorf("expected %v got %v", ErrInvalidSeverity, err)
+	}
+
+	rec := &recordingNotifier{}
+	n := &severityNotifier{Notifier: rec, min: SeverityWarning}
+	for _, sev := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+		n.Notify(Alert{Type: AlertTypeDown, Severity: sev})
+	}
+	if len(rec.alerts) != 2 || rec.alerts[0].Severity != SeverityWarning {
+		t.Errorf("expected warning and critical alerts got %+v", rec.alerts)
+	}
+}
+
+func TestTemplatesByType(t *testing.T) {
+	rec := &recordingNotifier{}
+	n, err := NewNotifier(NotifierConfig{
+		Type:      "log",
+		Template:  "{{.Service.Name}} is {{.Type}}",
+		Templates: map[AlertType]string{AlertTypeDegraded: "{{.Service.Name}} is slow: {{.Message}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.(*templateNotifier).Notifier = rec
+
+	n.Notify(Alert{Type: AlertTypeDegraded, Service: Service{Name: "api"}, Message: "p95 2s"})
+	n.Notify(Alert{Type: AlertTypeDown, Service: Service{Name: "api"}})
+	if rec.alerts[0].Message != "api is slow: p95 2s" || rec.alerts[1].Message != "api is down" {
+		t.Errorf("unexpected messages %q and %q", rec.alerts[0].Message, rec.alerts[1].Message)
+	}
+
+	if _, err := NewNotifier(NotifierConfig{Type: "log", Templates: map[AlertType]string{AlertTypeDown: "{{"}}); err == nil {
+		t.Error("expected invalid template error")
+	}
+	if AlertTypeDegraded.Color() == AlertTypeDown.Color() {
+		t.Error("expected degraded alerts to have their own colour")
+	}
+}
+
+func TestCooldownWorsening(t *testing.T) {
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, time.Hour)
+	s := &Service{Name: "api"}
+
+	// getting worse goes through the cooldown, getting better does not
+	nm.NotifyState(s, StateDegraded, "slow")
+	nm.NotifyState(s, StateDown, "timeout")
+	nm.NotifyState(s, StateDegraded, "slow")
+	nm.NotifyState(s, StateDown, "timeout")
+	expected := []AlertType{AlertTypeDegraded, AlertTypeDown}
+	if len(rec.alerts) != len(expected) {
+		t.Fatalf("expected %v got %+v", expected, rec.alerts)
+	}
+	for i, a := range rec.alerts {
+		if a.Type != expected[i] {
+			t.Errorf("expected %v got %v", expected[i], a.Type)
+		}
+	}
+}
+
+func TestServiceAlertCooldown(t *testing.T) {
+	db, _ := OpenStorage("")
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	nm.Storage = db
+	s := &Service{Name: "api", AlertCooldown: "1h"}
+
+	nm.CheckAndNotify(s, false, "")
+	nm.CheckAndNotify(s, true, "")
+	if len(rec.alerts) != 2 {
+		t.Fatalf("expected 2 alerts got %d", len(rec.alerts))
+	}
+
+	// a restarted manager picks up the last alert from the storage
+	nm = NewNotificationManager([]Notifier{rec}, 0)
+	nm.Storage = db
+	nm.CheckAndNotify(s, false, "")
+	if len(rec.alerts) != 2 {
+		t.Errorf("expected the cooldown to survive a restart got %d alerts", len(rec.alerts))
+	}
+}
+
+func TestNotifierCooldown(t *testing.T) {
+	rec := &recordingNotifier{}
+	n := &cooldownNotifier{Notifier: rec, cooldown: time.Hour, last: make(map[string]Alert)}
+	now := time.Now()
+	api, db := Service{Name: "api"}, Service{Name: "db"}
+
+	for _, a := range []Alert{
+		{Type: AlertTypeDown, Service: api, Time: now},
+		{Type: AlertTypeRecovery, Service: api, Time: now.Add(time.Minute)},
+		{Type: AlertTypeDown, Service: api, Time: now.Add(2 * time.Minute)},
+		{Type: AlertTypeDown, Service: db, Time: now.Add(3 * time.Minute)},
+		{Type: AlertTypeDegraded, Service: api, Time: now.Add(2 * time.Hour)},
+		{Type: AlertTypeDegraded, Service: db, Severity: SeverityWarning, Time: now.Add(3 * time.Hour)},
+		{Type: AlertTypeDown, Service: db, Severity: SeverityCritical, Time: now.Add(3*time.Hour + time.Minute)},
+		{Type: AlertTypeDegraded, Service: db, Severity: SeverityWarning, Time: now.Add(3*time.Hour + 2*time.Minute)},
+	} {
+		n.Notify(a)
+	}
+
+	expected := []AlertType{AlertTypeDown, AlertTypeRecovery, AlertTypeDown, AlertTypeDegraded, AlertTypeDegraded, AlertTypeDown}
+	if len(rec.alerts) != len(expected) {
+		t.Fatalf("expected %d alerts got %v", len(expected), rec.alerts)
+	}
+	for i, a := range rec.alerts {
+		if a.Type != expected[i] {
+			t.Errorf("expected %v got %v", expected[i], a.Type)
+		}
+	}
+
+	if _, err := NewNotifier(NotifierConfig{Type: "log", Cooldown: "soon"}); err != ErrInvalidCooldown {
+		t.Errorf("expected %v got %v", ErrInvalidCooldown, err)
+	}
+}
+
+func TestRecoveryAlert(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	nm.Storage = db
+	s := &Service{Name: "api"}
+
+	nm.CheckAndNotify(s, false, "connection refused\nat 10.0.0.1")
+	nm.CheckAndNotify(s, true, "")
+
+	if len(rec.alerts) != 2 {
+		t.Fatalf("expected 2 alerts got %d", len(rec.alerts))
+	}
+	incidents, _ := db.Incidents(ctx)
+	inc := incidents[0]
+	a := rec.alerts[1]
+	if a.Incident != inc.ID || !a.Since.Equal(inc.Start) || a.Duration != inc.End.Sub(inc.Start) {
+		t.Errorf("expected the recovery of incident %+v got %+v", inc, a)
+	}
+	if a.Cause != "connection refused\nat 10.0.0.1" {
+		t.Errorf("expected %q got %q", "connection refused\nat 10.0.0.1", a.Cause)
+	}
+	if !strings.HasSuffix(a.Message, ": connection refused") {
+		t.Errorf("expected the cause in the message got %q", a.Message)
+	}
+}
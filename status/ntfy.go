@@ -0,0 +1,76 @@
+package status
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Errors returned by the ntfy notifier
+var (
+	ErrMissingTopic = errors.New("notify: ntfy notifier needs a topic")
+	ErrNtfyRejected = errors.New("notify: ntfy rejected the message")
+)
+
+// ntfyURL is the public ntfy server
+const ntfyURL = "https://ntfy.sh"
+
+// NtfyNotifier publishes alerts to an ntfy topic, which
+// delivers them as push notifications
+type NtfyNotifier struct {
+	Topic string
+	// Token is the access token of protected topics
+	Token string
+	// URL of a self-hosted server, ntfy.sh when empty
+	URL    string
+	Client *http.Client
+}
+
+// Notify publishes the alert. Down alerts are sent with urgent
+// priority, degraded and flapping alerts with high priority.
+func (n *NtfyNotifier) Notify(a Alert) error {
+	base := strings.TrimSuffix(n.URL, "/")
+	if base == "" {
+		base = ntfyURL
+	}
+
+	message := a.Message
+	if message == "" {
+		message = a.Title()
+	}
+	req, err := http.NewRequest(http.MethodPost, base+"/"+n.Topic, strings.NewReader(message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", a.Title())
+	switch a.Type {
+	case AlertTypeDown:
+		req.Header.Set("Priority", "urgent")
+		req.Header.Set("Tags", "rotating_light")
+	case AlertTypeRecovery, AlertTypeBurnRateResolved:
+		req.Header.Set("Tags", "white_check_mark")
+	case AlertTypeDegraded:
+		req.Header.Set("Priority", "high")
+		req.Header.Set("Tags", "large_orange_diamond")
+	case AlertTypeFlapping, AlertTypeBurnRate:
+		req.Header.Set("Priority", "high")
+		req.Header.Set("Tags", "warning")
+	}
+	if a.AckURL != "" {
+		req.Header.Set("Actions", "view, Acknowledge, "+a.AckURL)
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	resp, err := clientOrDefault(n.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{Err: ErrNtfyRejected, Code: resp.StatusCode}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package status
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNtfyNotifier(t *testing.T) {
+	var got *http.Request
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got, body = r, string(b)
+		if r.Header.Get("Authorization") != "Bearer tk_abc" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	n, err := NewNotifier(NotifierConfig{Type: "ntfy", Topic: "alerts", Token: "tk_abc", URL: ts.URL + "/"})
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	if err := n.Notify(Alert{Type: AlertTypeDown, Service: Service{Name: "api"}, Message: "timeout"}); err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+
+	if got.URL.Path != "/alerts" || body != "timeout" {
+		t.Errorf("unexpected request to %v with %q", got.URL.Path, body)
+	}
+	if got.Header.Get("Title") != "api is down" || got.Header.Get("Priority") != "urgent" {
+		t.Errorf("unexpected headers %v", got.Header)
+	}
+
+	bad := &NtfyNotifier{Topic: "alerts", URL: ts.URL}
+	if err := bad.Notify(Alert{Type: AlertTypeRecovery, Service: Service{Name: "api"}}); !errors.Is(err, ErrNtfyRejected) {
+		t.Errorf("expected %v got %v", ErrNtfyRejected, err)
+	}
+
+	if _, err := NewNotifier(NotifierConfig{Type: "ntfy"}); err != ErrMissingTopic {
+		t.Errorf("expected %v got %v", ErrMissingTopic, err)
+	}
+}
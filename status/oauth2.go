@@ -0,0 +1,132 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Errors returned by the oauth2 check
+var (
+	ErrMissingTokenURL     = errors.New("commands: oauth2 check needs a token_url")
+	ErrOAuth2TokenRequest  = errors.New("commands: oauth2 token request failed")
+	ErrOAuth2NoToken       = errors.New("commands: oauth2 response has no access token")
+	ErrOAuth2TokenRejected = errors.New("commands: oauth2 token rejected by resource")
+)
+
+// OAuth2 checks an identity provider issues client credentials
+// tokens, and optionally that a protected resource accepts them
+type OAuth2 struct {
+	Service
+	client *http.Client
+}
+
+// GetService return the Service pointer
+func (o *OAuth2) GetService() *Service {
+	return &o.Service
+}
+
+// tokenResponse is the successful response of a token request
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// Status requests a token and, when URL is set, calls it with the token
+func (o *OAuth2) Status() error {
+	token, err := o.token()
+	if err != nil {
+		return err
+	}
+	if o.URL == "" {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, o.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := clientOrDefault(o.client).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrOAuth2TokenRejected
+	case !validStatus(resp.StatusCode):
+		return ErrServiceUnavailable
+	}
+	return nil
+}
+
+// token performs the client credentials grant, authenticating
+// the client with HTTP basic auth
+func (o *OAuth2) token() (string, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(readSecret(o.ClientSecret)))
+
+	resp, err := clientOrDefault(o.client).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if !validStatus(resp.StatusCode) {
+		return "", ErrOAuth2TokenRequest
+	}
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil || tr.AccessToken == "" {
+		return "", ErrOAuth2NoToken
+	}
+	return tr.AccessToken, nil
+}
+
+// readSecret returns ref, or the environment variable it names
+// when it has the form "env:NAME"
+func readSecret(ref string) string {
+	if strings.HasPrefix(ref, "env:") {
+		return os.Getenv(strings.TrimPrefix(ref, "env:"))
+	}
+	return ref
+}
+
+// OAuth2Factory implements the PingerFactory
+// interface
+type OAuth2Factory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *OAuth2Factory) Create(s Service) (Pinger, error) {
+	if s.Type != "oauth2" {
+		return nil, ErrInvalidCreate
+	}
+	if s.TokenURL == "" {
+		return nil, ErrMissingTokenURL
+	}
+	client, err := newHTTPClient(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuth2{
+		Service: s,
+		client:  client,
+	}, nil
+}
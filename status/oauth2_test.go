@@ -0,0 +1,69 @@
+package status
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestOAuth2Status(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.FormValue("grant_type") != "client_credentials" || id != "status" || secret != "s3cret" {
+			http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("scope") == "none" {
+			io.WriteString(w, `{"token_type": "bearer"}`)
+			return
+		}
+		io.WriteString(w, `{"access_token": "abc", "token_type": "bearer"}`)
+	})
+	mux.HandleFunc("/resource", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	mux.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	os.Setenv("STATUS_TEST_SECRET", "s3cret")
+	defer os.Unsetenv("STATUS_TEST_SECRET")
+
+	tt := []struct {
+		name     string
+		service  Service
+		expected error
+	}{
+		{name: "token only", service: Service{ClientSecret: "s3cret"}, expected: nil},
+		{name: "secret from env", service: Service{ClientSecret: "env:STATUS_TEST_SECRET"}, expected: nil},
+		{name: "bad secret", service: Service{ClientSecret: "wrong"}, expected: ErrOAuth2TokenRequest},
+		{name: "no token", service: Service{ClientSecret: "s3cret", Scopes: []string{"none"}}, expected: ErrOAuth2NoToken},
+		{name: "resource", service: Service{ClientSecret: "s3cret", URL: ts.URL + "/resource"}, expected: nil},
+		{name: "resource rejects token", service: Service{ClientSecret: "s3cret", URL: ts.URL + "/admin"}, expected: ErrOAuth2TokenRejected},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.service.TokenURL = ts.URL + "/token"
+			tc.service.ClientID = "status"
+			o := OAuth2{Service: tc.service}
+			if actual := o.Status(); actual != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestOAuth2FactoryCreateErr(t *testing.T) {
+	f := OAuth2Factory{}
+	if _, err := f.Create(Service{Type: "oauth2"}); err != ErrMissingTokenURL {
+		t.Errorf("expected %v got %v", ErrMissingTokenURL, err)
+	}
+}
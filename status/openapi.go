@@ -0,0 +1,307 @@
+package status
+
+import "net/http"
+
+// openAPISpec describes the read endpoints of the API and the
+// incidents endpoints in OpenAPI 3
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "service_status",
+    "description": "The state, history and incidents of the monitored services.",
+    "version": "1"
+  },
+  "paths": {
+    "/api/status": {
+      "get": {
+        "summary": "The current state of the services",
+        "responses": {"200": {"description": "The status page", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Status"}}}}}
+      }
+    },
+    "/api/services": {
+      "get": {
+        "summary": "The services with their state, including those removed from the config",
+        "parameters": [{"name": "status", "in": "query", "description": "Only the services in these states, such as down,degraded", "schema": {"type": "string"}}],
+        "responses": {
+          "200": {"description": "The services, sorted by name", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Service"}}}}},
+          "400": {"description": "Invalid status"}
+        }
+      }
+    },
+    "/api/services/{name}": {
+      "get": {
+        "summary": "A service with its state, latency and checks over the last day",
+        "parameters": [{"$ref": "#/components/parameters/name"}],
+        "responses": {
+          "200": {"description": "The service", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Service"}}}},
+          "404": {"description": "No such service"}
+        }
+      }
+    },
+    "/api/services/{name}/history": {
+      "get": {
+        "summary": "The checks of a service, newest first",
+        "parameters": [
+          {"$ref": "#/components/parameters/name"},
+          {"$ref": "#/components/parameters/start"},
+          {"$ref": "#/components/parameters/end"},
+          {"$ref": "#/components/parameters/page"},
+          {"$ref": "#/components/parameters/size"}
+        ],
+        "responses": {
+          "200": {"description": "A page of the checks", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/HistoryList"}}}},
+          "400": {"description": "Invalid parameters"},
+          "404": {"description": "No such service"}
+        }
+      }
+    },
+    "/api/history": {
+      "get": {
+        "summary": "The checks of every service, newest first",
+        "parameters": [
+          {"name": "service", "in": "query", "schema": {"type": "string"}},
+          {"$ref": "#/components/parameters/start"},
+          {"$ref": "#/components/parameters/end"},
+          {"$ref": "#/components/parameters/page"},
+          {"$ref": "#/components/parameters/size"}
+        ],
+        "responses": {
+          "200": {"description": "A page of the checks", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/HistoryList"}}}},
+          "400": {"description": "Invalid parameters"}
+        }
+      }
+    },
+    "/api/uptime": {
+      "get": {
+        "summary": "The uptime of the services over a window ending now",
+        "parameters": [
+          {"name": "window", "in": "query", "description": "Such as 30d or 12h", "schema": {"type": "string", "default": "30d"}},
+          {"name": "service", "in": "query", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "The uptime of the services with checks", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/UptimeList"}}}},
+          "400": {"description": "Invalid window"}
+        }
+      }
+    },
+    "/api/incidents/": {
+      "get": {
+        "summary": "The incidents, newest first",
+        "parameters": [
+          {"name": "service", "in": "query", "description": "Incidents of or affecting the service", "schema": {"type": "string"}},
+          {"name": "state", "in": "query", "schema": {"$ref": "#/components/schemas/State"}},
+          {"name": "status", "in": "query", "schema": {"type": "string", "enum": ["ongoing", "resolved"]}},
+          {"$ref": "#/components/parameters/start"},
+          {"$ref": "#/components/parameters/end"},
+          {"$ref": "#/components/parameters/page"},
+          {"$ref": "#/components/parameters/size"}
+        ],
+        "responses": {
+          "200": {"description": "A page of the incidents", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IncidentList"}}}},
+          "400": {"description": "Invalid parameters"}
+        }
+      },
+      "post": {
+        "summary": "Open a manual incident",
+        "security": [{"admin": []}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Incident"}}}},
+        "responses": {
+          "201": {"description": "The incident", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Incident"}}}},
+          "400": {"description": "Invalid incident"},
+          "401": {"description": "Missing or wrong admin token or API key"}
+        }
+      }
+    },
+    "/api/incidents/{id}": {
+      "get": {
+        "summary": "An incident",
+        "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {
+          "200": {"description": "The incident", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Incident"}}}},
+          "404": {"description": "No such incident"}
+        }
+      }
+    },
+    "/api/incidents/{id}/ack": {
+      "post": {
+        "summary": "Acknowledge an incident",
+        "security": [{"admin": []}],
+        "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"type": "object", "required": ["by"], "properties": {"by": {"type": "string"}}}}}},
+        "responses": {
+          "200": {"description": "The incident", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Incident"}}}},
+          "400": {"description": "Missing who acknowledges it"},
+          "401": {"description": "Missing or wrong admin token or API key"},
+          "404": {"description": "No such incident"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "admin": {"type": "http", "scheme": "bearer", "description": "The admin_token of the config, or an API key of the incidents scope, or of the ack scope to acknowledge"}
+    },
+    "parameters": {
+      "name": {"name": "name", "in": "path", "required": true, "description": "The service name, percent-encoded", "schema": {"type": "string"}},
+      "start": {"name": "start", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+      "end": {"name": "end", "in": "query", "description": "Now by default", "schema": {"type": "string", "format": "date-time"}},
+      "page": {"name": "page", "in": "query", "schema": {"type": "integer", "minimum": 1, "default": 1}},
+      "size": {"name": "size", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 1000, "default": 50}}
+    },
+    "schemas": {
+      "State": {"type": "string", "enum": ["up", "down", "degraded", "affected", "maintenance"]},
+      "WindowUptime": {
+        "type": "object",
+        "properties": {"window": {"type": "string"}, "percent": {"type": "number"}}
+      },
+      "Status": {
+        "type": "object",
+        "properties": {
+          "title": {"type": "string"},
+          "status": {"type": "string", "enum": ["success", "warning", "danger", "maintenance"]},
+          "services": {"type": "array", "items": {
+            "type": "object",
+            "properties": {
+              "id": {"type": "string"},
+              "group": {"type": "string"},
+              "state": {"$ref": "#/components/schemas/State"},
+              "message": {"type": "string"},
+              "flapping": {"type": "boolean"},
+              "acknowledged": {"type": "string"},
+              "uptime": {"type": "array", "items": {"$ref": "#/components/schemas/WindowUptime"}}
+            }
+          }},
+          "groups": {"type": "array", "items": {
+            "type": "object",
+            "properties": {
+              "name": {"type": "string"},
+              "state": {"$ref": "#/components/schemas/State"},
+              "services": {"type": "array", "items": {"type": "string"}}
+            }
+          }},
+          "time": {"type": "string"}
+        }
+      },
+      "Service": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "type": {"type": "string"},
+          "url": {"type": "string"},
+          "group": {"type": "string"},
+          "tags": {"type": "array", "items": {"type": "string"}},
+          "renamed_from": {"type": "array", "items": {"type": "string"}},
+          "created": {"type": "string", "format": "date-time"},
+          "removed": {"type": "string", "format": "date-time"},
+          "state": {"$ref": "#/components/schemas/State"},
+          "message": {"type": "string"},
+          "since": {"type": "string", "format": "date-time"},
+          "incident": {"$ref": "#/components/schemas/Incident"},
+          "checked": {"type": "string", "format": "date-time", "description": "Only on a single service"},
+          "latency": {"$ref": "#/components/schemas/Latency"},
+          "history": {"type": "array", "description": "The checks of the last day, newest first; only on a single service", "items": {"$ref": "#/components/schemas/StatusRecord"}}
+        }
+      },
+      "Latency": {
+        "type": "object",
+        "description": "The response times of the last day, in milliseconds",
+        "properties": {
+          "checks": {"type": "integer"},
+          "last_ms": {"type": "number"},
+          "min_ms": {"type": "number"},
+          "avg_ms": {"type": "number"},
+          "p50_ms": {"type": "number"},
+          "p95_ms": {"type": "number"},
+          "p99_ms": {"type": "number"},
+          "max_ms": {"type": "number"}
+        }
+      },
+      "StatusRecord": {
+        "type": "object",
+        "properties": {
+          "service": {"type": "string"},
+          "state": {"$ref": "#/components/schemas/State"},
+          "time": {"type": "string", "format": "date-time"},
+          "response_time": {"type": "integer", "description": "Nanoseconds"},
+          "timings": {"$ref": "#/components/schemas/Timings"}
+        }
+      },
+      "Timings": {
+        "type": "object",
+        "description": "The phases of an HTTP or browser check, in nanoseconds",
+        "properties": {
+          "dns": {"type": "integer"},
+          "connect": {"type": "integer"},
+          "tls": {"type": "integer"},
+          "ttfb": {"type": "integer"},
+          "load": {"type": "integer", "description": "The load time of the page of a browser check"}
+        }
+      },
+      "HistoryList": {
+        "type": "object",
+        "properties": {
+          "page": {"type": "integer"},
+          "size": {"type": "integer"},
+          "total": {"type": "integer"},
+          "history": {"type": "array", "items": {"$ref": "#/components/schemas/StatusRecord"}}
+        }
+      },
+      "UptimeList": {
+        "type": "object",
+        "properties": {
+          "window": {"type": "string"},
+          "services": {"type": "array", "items": {
+            "type": "object",
+            "properties": {"service": {"type": "string"}, "percent": {"type": "number"}}
+          }}
+        }
+      },
+      "Incident": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string", "readOnly": true},
+          "service": {"type": "string"},
+          "manual": {"type": "boolean"},
+          "title": {"type": "string"},
+          "services": {"type": "array", "items": {"type": "string"}},
+          "state": {"$ref": "#/components/schemas/State"},
+          "severity": {"type": "string"},
+          "message": {"type": "string"},
+          "start": {"type": "string", "format": "date-time"},
+          "end": {"type": "string", "format": "date-time"},
+          "acked_by": {"type": "string"},
+          "acked_at": {"type": "string", "format": "date-time"},
+          "updates": {"type": "array", "items": {
+            "type": "object",
+            "properties": {"time": {"type": "string", "format": "date-time"}, "message": {"type": "string"}}
+          }},
+          "postmortem": {"type": "string"}
+        }
+      },
+      "IncidentList": {
+        "type": "object",
+        "properties": {
+          "page": {"type": "integer"},
+          "size": {"type": "integer"},
+          "total": {"type": "integer"},
+          "incidents": {"type": "array", "items": {"$ref": "#/components/schemas/Incident"}}
+        }
+      }
+    }
+  }
+}
+`
+
+// OpenAPIHandler is a HandlerFunc which serves the OpenAPI document of
+// the API (GET /api/openapi.json)
+func OpenAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(openAPISpec))
+	}
+}
@@ -0,0 +1,149 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by the Opsgenie notifier
+var (
+	ErrMissingAPIKey    = errors.New("notify: opsgenie notifier needs an api_key")
+	ErrInvalidPriority  = errors.New("notify: opsgenie priority must be P1 to P5")
+	ErrOpsgenieRejected = errors.New("notify: opsgenie rejected the request")
+)
+
+// opsgenieURL is the Opsgenie API, EU accounts use
+// https://api.eu.opsgenie.com
+const opsgenieURL = "https://api.opsgenie.com"
+
+// OpsgenieNotifier creates Opsgenie alerts through the Alerts API.
+// Alerts are aliased per service so a recovery closes the alert
+// opened when the service went down.
+type OpsgenieNotifier struct {
+	APIKey string
+	// Priority of critical alerts, P1 when empty. Warnings,
+	// such as flapping alerts, are P3 and info alerts P5.
+	Priority string
+	// URL overrides the API endpoint
+	URL    string
+	Client *http.Client
+}
+
+// opsgenieAlert is the body of a create alert request
+type opsgenieAlert struct {
+	Message     string `json:"message"`
+	Alias       string `json:"alias"`
+	Description string `json:"description,omitempty"`
+	Priority    string `json:"priority"`
+	Source      string `json:"source"`
+}
+
+// Notify creates an alert when a service goes down, is degraded
+// or starts flapping and closes it when the service recovers.
+// Digests are not sent, they are not incidents. A test creates an
+// alert of its own and closes it at once, leaving the alert of the
+// service alone, and a burn rate alert its own alert too, closed
+// when the burn slows down.
+func (n *OpsgenieNotifier) Notify(a Alert) error {
+	switch a.Type {
+	case AlertTypeDigest:
+		return nil
+	case AlertTypeTest:
+		alias := "service_status:test:" + strconv.FormatInt(a.Time.UnixNano(), 10)
+		if err := n.create(a, alias); err != nil {
+			return err
+		}
+		return n.close(alias, "test notification delivered")
+	case AlertTypeRecovery:
+		note := a.Service.ID() + " recovered"
+		if a.Message != "" {
+			note += ": " + a.Message
+		}
+		return n.close(opsgenieAlias(a.Service), note)
+	case AlertTypeBurnRate:
+		return n.create(a, opsgenieBurnRateAlias(a.Service))
+	case AlertTypeBurnRateResolved:
+		return n.close(opsgenieBurnRateAlias(a.Service), a.Title())
+	}
+	return n.create(a, opsgenieAlias(a.Service))
+}
+
+// create creates the alert of the alias
+func (n *OpsgenieNotifier) create(a Alert, alias string) error {
+	priority := n.Priority
+	if priority == "" {
+		priority = "P1"
+	}
+	switch {
+	case a.Severity == SeverityInfo:
+		priority = "P5"
+	case a.Severity == SeverityWarning, a.Type == AlertTypeFlapping:
+		priority = "P3"
+	}
+	return n.send("/v2/alerts", opsgenieAlert{
+		Message:     a.Title(),
+		Alias:       alias,
+		Description: a.Message,
+		Priority:    priority,
+		Source:      "service_status",
+	})
+}
+
+// close closes the alert of the alias with a note
+func (n *OpsgenieNotifier) close(alias, note string) error {
+	path := "/v2/alerts/" + url.PathEscape(alias) + "/close?identifierType=alias"
+	return n.send(path, map[string]string{"source": "service_status", "note": note})
+}
+
+// send posts body to the path of the API
+func (n *OpsgenieNotifier) send(path string, body interface{}) error {
+	base := strings.TrimSuffix(n.URL, "/")
+	if base == "" {
+		base = opsgenieURL
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, base+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+n.APIKey)
+
+	resp, err := clientOrDefault(n.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{Err: ErrOpsgenieRejected, Code: resp.StatusCode}
+	}
+	return nil
+}
+
+// opsgenieAlias identifies the alert of a service
+func opsgenieAlias(s Service) string {
+	return limitAlias("service_status:" + s.ID())
+}
+
+// opsgenieBurnRateAlias identifies the burn rate alert of a service,
+// apart from the alert of its outages
+func opsgenieBurnRateAlias(s Service) string {
+	return limitAlias("service_status:burn_rate:" + s.ID())
+}
+
+// limitAlias cuts an alias to the 512 characters Opsgenie allows
+func limitAlias(alias string) string {
+	if len(alias) > 512 {
+		alias = alias[:512]
+	}
+	return alias
+}
@@ -0,0 +1,134 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpsgenieNotifier(t *testing.T) {
+	var requests []*http.Request
+	var alerts []opsgenieAlert
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		var a opsgenieAlert
+		json.NewDecoder(r.Body).Decode(&a)
+		alerts = append(alerts, a)
+		if r.Header.Get("Authorization") != "GenieKey key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	n, err := NewNotifier(NotifierConfig{Type: "opsgenie", APIKey: "key", Priority: "P2", URL: ts.URL})
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+
+	s := Service{Name: "api"}
+	for _, typ := range []AlertType{AlertTypeDown, AlertTypeFlapping, AlertTypeRecovery} {
+		if err := n.Notify(Alert{Type: typ, Service: s, Message: "timeout"}); err != nil {
+			t.Fatalf("%s: expected nil got %v", typ, err)
+		}
+	}
+
+	if alerts[0].Priority != "P2" || alerts[0].Alias != "service_status:api" || alerts[0].Description != "timeout" {
+		t.Errorf("unexpected down alert %+v", alerts[0])
+	}
+	if alerts[1].Priority != "P3" {
+		t.Errorf("expected %v got %v", "P3", alerts[1].Priority)
+	}
+	closeURL := requests[2].URL
+	if closeURL.Path != "/v2/alerts/service_status:api/close" || closeURL.Query().Get("identifierType") != "alias" {
+		t.Errorf("unexpected close request %v", closeURL)
+	}
+
+	bad := &OpsgenieNotifier{APIKey: "wrong", URL: ts.URL}
+	if err := bad.Notify(Alert{Type: AlertTypeDown, Service: s}); !errors.Is(err, ErrOpsgenieRejected) {
+		t.Errorf("expected %v got %v", ErrOpsgenieRejected, err)
+	}
+}
+
+func TestOpsgenieNotifierTest(t *testing.T) {
+	var paths []string
+	var alerts []opsgenieAlert
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var a opsgenieAlert
+		json.NewDecoder(r.Body).Decode(&a)
+		alerts = append(alerts, a)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	n := &OpsgenieNotifier{APIKey: "key", URL: ts.URL}
+	s := Service{Name: "service_status"}
+	if err := n.Notify(Alert{Type: AlertTypeTest, Severity: SeverityInfo, Service: s, Time: time.Now()}); err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 requests got %d", len(paths))
+	}
+	if paths[0] != "/v2/alerts" || alerts[0].Priority != "P5" || alerts[0].Alias == opsgenieAlias(s) {
+		t.Errorf("unexpected test alert %+v", alerts[0])
+	}
+	if paths[1] != "/v2/alerts/"+alerts[0].Alias+"/close" {
+		t.Errorf("expected the test alert to be closed got %v", paths[1])
+	}
+}
+
+func TestOpsgenieNotifierBurnRate(t *testing.T) {
+	var paths []string
+	var alerts []opsgenieAlert
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var a opsgenieAlert
+		json.NewDecoder(r.Body).Decode(&a)
+		alerts = append(alerts, a)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	n := &OpsgenieNotifier{APIKey: "key", URL: ts.URL}
+	s := Service{Name: "api"}
+	for _, typ := range []AlertType{AlertTypeBurnRate, AlertTypeBurnRateResolved} {
+		if err := n.Notify(Alert{Type: typ, Severity: SeverityWarning, Service: s}); err != nil {
+			t.Fatalf("%s: expected nil got %v", typ, err)
+		}
+	}
+
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 requests got %d", len(paths))
+	}
+	if alerts[0].Alias != "service_status:burn_rate:api" || alerts[0].Priority != "P3" {
+		t.Errorf("unexpected burn rate alert %+v", alerts[0])
+	}
+	if paths[1] != "/v2/alerts/service_status:burn_rate:api/close" {
+		t.Errorf("expected the burn rate alert to be closed got %v", paths[1])
+	}
+}
+
+func TestNewNotifierOpsgenieErrors(t *testing.T) {
+	tt := []struct {
+		name     string
+		config   NotifierConfig
+		expected error
+	}{
+		{name: "missing key", config: NotifierConfig{Type: "opsgenie"}, expected: ErrMissingAPIKey},
+		{name: "bad priority", config: NotifierConfig{Type: "opsgenie", APIKey: "key", Priority: "urgent"}, expected: ErrInvalidPriority},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewNotifier(tc.config); err != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, err)
+			}
+		})
+	}
+}
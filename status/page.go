@@ -3,17 +3,233 @@ package status
 import (
 	"html/template"
 	"net/http"
+	"sort"
+	"time"
 )
 
 var tpl *template.Template
 
-// Page represents the data of the status page
+// Page represents the data of the status page. Its lists of services
+// are sorted by ID, and the maps keyed by ID are ranged over in the
+// order of their keys by the templates and JSON, so the services are
+// shown in the same order on every refresh.
 type Page struct {
 	Title  string
 	Status template.HTML
 	Up     []string
 	Down   map[string]int
-	Time   string
+	// Errors maps down services to the error of their check
+	Errors map[string]string
+	// Degraded maps degraded services to the reason
+	Degraded map[string]string
+	Affected []string
+	// Maintenance maps services in maintenance to the
+	// message of their window
+	Maintenance map[string]string
+	// Flapping holds the services changing state too often
+	Flapping map[string]bool
+	// Timings maps HTTP services to the latency breakdown
+	// of their last check
+	Timings map[string]string
+	// Regions maps services checked from several regions
+	// to the state seen in each
+	Regions map[string]map[string]State
+	// Acknowledged maps services with an acknowledged incident
+	// to who acknowledged it and when
+	Acknowledged map[string]string
+	// Uptime maps services to their uptime over the UptimeWindows
+	Uptime map[string][]WindowUptime
+	// Days maps services to their uptime over each of the last
+	// UptimeDays, charted as bars
+	Days map[string][]DayUptime
+	// Groups lists the services in the sections of their Group,
+	// when any service has one
+	Groups []ServiceGroup
+	// Incidents are the incidents of the last week, newest first,
+	// with their updates and postmortems
+	Incidents []Incident
+	// Upcoming is the scheduled maintenance which has not ended,
+	// soonest first
+	Upcoming []ScheduledMaintenance
+	// Subscriptions shows the form subscribing to the status
+	// updates by email
+	Subscriptions bool
+	// Theme brands the page
+	Theme Theme
+	// Updated is when the page was built, shown formatted as Time
+	Updated time.Time
+	Time    string
+	// Viewer is who is viewing the page, empty until they sign in,
+	// which they can with SignIn
+	Viewer string
+	SignIn bool
+
+	// results are those the page was built from
+	results []Result
+}
+
+// NewPage builds a Page from the results of a check. Down services
+// are mapped to the number of minutes they have been down.
+func NewPage(title string, results []Result) Page {
+	now := time.Now()
+	p := Page{
+		Title:        title,
+		Down:         make(map[string]int),
+		Errors:       make(map[string]string),
+		Degraded:     make(map[string]string),
+		Maintenance:  make(map[string]string),
+		Flapping:     make(map[string]bool),
+		Timings:      make(map[string]string),
+		Regions:      make(map[string]map[string]State),
+		Acknowledged: make(map[string]string),
+		Uptime:       make(map[string][]WindowUptime),
+		Days:         make(map[string][]DayUptime),
+		Updated:      now,
+		Time:         now.Format("2006-01-02 15:04:05"),
+		Groups:       groupResults(results),
+		results:      results,
+	}
+
+	for _, r := range results {
+		if r.Flapping {
+			p.Flapping[r.Service.ID()] = true
+		}
+		if r.Timings != nil {
+			p.Timings[r.Service.ID()] = r.Timings.String()
+		}
+		if r.Regions != nil {
+			p.Regions[r.Service.ID()] = r.Regions
+		}
+		if r.Uptime != nil {
+			p.Uptime[r.Service.ID()] = r.Uptime
+		}
+		if r.Days != nil {
+			p.Days[r.Service.ID()] = r.Days
+		}
+		if r.Incident != nil && r.Incident.Acked() {
+			p.Acknowledged[r.Service.ID()] = "acknowledged by " + r.Incident.AckedBy + " at " + r.Incident.AckedAt.Format("2006-01-02 15:04")
+		}
+		switch r.State {
+		case StateUp:
+			p.Up = append(p.Up, r.Service.ID())
+		case StateDegraded:
+			p.Degraded[r.Service.ID()] = r.Message
+		case StateDown:
+			p.Down[r.Service.ID()] = int(time.Since(r.Since).Minutes())
+			if r.Err != nil {
+				p.Errors[r.Service.ID()] = r.Err.Error()
+			}
+		case StateAffected:
+			p.Affected = append(p.Affected, r.Service.ID())
+		case StateMaintenance:
+			p.Maintenance[r.Service.ID()] = r.Message
+		}
+	}
+	sort.Strings(p.Up)
+	sort.Strings(p.Affected)
+
+	switch {
+	case len(p.Maintenance) > 0 && len(p.Maintenance) == len(results):
+		p.Status = "maintenance"
+	case len(p.Down) > 0:
+		p.Status = "danger"
+	case len(p.Degraded) > 0 || len(p.Affected) > 0:
+		p.Status = "warning"
+	default:
+		p.Status = "success"
+	}
+
+	return p
+}
+
+// SetIncidents lists the incidents on the page. Ongoing manual
+// incidents raise its status like services which are down or
+// degraded.
+func (p *Page) SetIncidents(incidents []Incident) {
+	p.Incidents = incidents
+	for _, inc := range incidents {
+		if !inc.Manual || !inc.Ongoing() {
+			continue
+		}
+		switch {
+		case inc.State == StateDown:
+			p.Status = "danger"
+		case p.Status != "danger":
+			p.Status = "warning"
+		}
+	}
+}
+
+// Public returns the page shown to viewers who have not signed in,
+// without the private services or the incidents and maintenance of
+// only private services
+func (p Page) Public() Page {
+	private := p.privateServices()
+	if len(private) == 0 {
+		return p
+	}
+	var results []Result
+	for _, r := range p.results {
+		if !private[r.Service.ID()] {
+			results = append(results, r)
+		}
+	}
+
+	public := NewPage(p.Title, results)
+	public.Subscriptions = p.Subscriptions
+	public.Theme = p.Theme
+	public.Updated, public.Time = p.Updated, p.Time
+	for _, sm := range p.Upcoming {
+		if services, ok := publicServices(sm.Services, private); ok {
+			sm.Services = services
+			public.Upcoming = append(public.Upcoming, sm)
+		}
+	}
+	public.SetIncidents(publicIncidents(p.Incidents, private))
+	return public
+}
+
+// privateServices returns the IDs of the private services of the page
+func (p Page) privateServices() map[string]bool {
+	private := make(map[string]bool)
+	for _, r := range p.results {
+		if r.Service.Private {
+			private[r.Service.ID()] = true
+		}
+	}
+	return private
+}
+
+// publicIncidents drops the incidents of only private services, and
+// the private services from those affected
+func publicIncidents(incidents []Incident, private map[string]bool) []Incident {
+	var public []Incident
+	for _, inc := range incidents {
+		if private[inc.Service] {
+			continue
+		}
+		if services, ok := publicServices(inc.Services, private); ok {
+			inc.Services = services
+			public = append(public, inc)
+		}
+	}
+	return public
+}
+
+// publicServices drops the private services from a list of those
+// affected, reporting false when it only lists private services. An
+// empty list affects every service.
+func publicServices(services []string, private map[string]bool) ([]string, bool) {
+	if len(services) == 0 {
+		return services, true
+	}
+	var public []string
+	for _, s := range services {
+		if !private[s] {
+			public = append(public, s)
+		}
+	}
+	return public, len(public) > 0
 }
 
 // LoadTemplate parses the templates in the templates dir
@@ -21,9 +237,13 @@ func LoadTemplate() {
 	tpl = template.Must(template.ParseGlob("templates/*.gohtml"))
 }
 
-// Index is a HandlerFunc which closes over a Page data structure
-func Index(p Page) http.HandlerFunc {
+// Index is a HandlerFunc which renders the latest Page of a PageStore
+// for the viewer
+func Index(s *PageStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		p := s.PageFor(r)
+		p.Viewer = Viewer(r.Context())
+		p.SignIn = canLogin(r.Context())
 		tpl.ExecuteTemplate(w, "status.gohtml", p)
 	}
 }
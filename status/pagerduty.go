@@ -0,0 +1,133 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Errors returned by the PagerDuty notifier
+var (
+	ErrMissingRoutingKey = errors.New("notify: pagerduty notifier needs a routing_key")
+	ErrPagerDutyRejected = errors.New("notify: pagerduty rejected the event")
+)
+
+// pagerDutyEventsURL is the Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyNotifier sends alerts to the PagerDuty Events API v2.
+// Events are deduplicated per service so a recovery resolves the
+// incident opened when the service went down.
+type PagerDutyNotifier struct {
+	RoutingKey string
+	// URL overrides the Events API endpoint
+	URL    string
+	Client *http.Client
+}
+
+// pagerDutyEvent is an Events API v2 event
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// Notify triggers an incident when a service goes down, is degraded
+// or starts flapping and resolves it when the service recovers.
+// Digests are not sent, they are not incidents. A test triggers an
+// incident of its own and resolves it at once, leaving the incident
+// of the service alone, and a burn rate alert its own incident too,
+// resolved when the burn slows down.
+func (n *PagerDutyNotifier) Notify(a Alert) error {
+	switch a.Type {
+	case AlertTypeDigest:
+		return nil
+	case AlertTypeTest:
+		key := "service_status:test:" + strconv.FormatInt(a.Time.UnixNano(), 10)
+		if err := n.send(n.trigger(a, key)); err != nil {
+			return err
+		}
+		return n.send(n.resolve(key))
+	case AlertTypeRecovery:
+		return n.send(n.resolve(pagerDutyDedupKey(a.Service)))
+	case AlertTypeBurnRate:
+		return n.send(n.trigger(a, pagerDutyDedupKey(a.Service)+":burn_rate"))
+	case AlertTypeBurnRateResolved:
+		return n.send(n.resolve(pagerDutyDedupKey(a.Service) + ":burn_rate"))
+	}
+	return n.send(n.trigger(a, pagerDutyDedupKey(a.Service)))
+}
+
+// trigger returns the event which opens the incident of the dedup key
+func (n *PagerDutyNotifier) trigger(a Alert, key string) pagerDutyEvent {
+	severity := a.Severity
+	if severity == "" {
+		severity = SeverityCritical
+		if a.Type == AlertTypeFlapping {
+			severity = SeverityWarning
+		}
+	}
+	event := pagerDutyEvent{
+		RoutingKey:  n.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    key,
+		Payload: &pagerDutyPayload{
+			Summary:   a.Title(),
+			Source:    a.Service.ID(),
+			Severity:  string(severity),
+			Timestamp: a.Time.Format(time.RFC3339),
+		},
+	}
+	if a.Message != "" {
+		event.Payload.CustomDetails = map[string]string{"message": a.Message}
+	}
+	return event
+}
+
+// resolve returns the event which resolves the incident of the dedup key
+func (n *PagerDutyNotifier) resolve(key string) pagerDutyEvent {
+	return pagerDutyEvent{RoutingKey: n.RoutingKey, EventAction: "resolve", DedupKey: key}
+}
+
+// send sends an event to the Events API
+func (n *PagerDutyNotifier) send(event pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	url := n.URL
+	if url == "" {
+		url = pagerDutyEventsURL
+	}
+	resp, err := clientOrDefault(n.Client).Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{Err: ErrPagerDutyRejected, Code: resp.StatusCode}
+	}
+	return nil
+}
+
+// pagerDutyDedupKey identifies the incident of a service by its URL
+func pagerDutyDedupKey(s Service) string {
+	if s.URL != "" {
+		return "service_status:" + s.URL
+	}
+	return "service_status:" + s.ID()
+}
@@ -0,0 +1,121 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPagerDutyNotifier(t *testing.T) {
+	var events []pagerDutyEvent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e pagerDutyEvent
+		json.NewDecoder(r.Body).Decode(&e)
+		events = append(events, e)
+		if e.RoutingKey != "key" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	n, err := NewNotifier(NotifierConfig{Type: "pagerduty", RoutingKey: "key", URL: ts.URL})
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+
+	s := Service{Name: "api", URL: "https://api.example.com"}
+	if err := n.Notify(Alert{Type: AlertTypeDown, Service: s, Message: "timeout", Time: time.Now()}); err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	if err := n.Notify(Alert{Type: AlertTypeRecovery, Service: s, Time: time.Now()}); err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events got %d", len(events))
+	}
+	if events[0].EventAction != "trigger" || events[0].Payload.Severity != "critical" || events[0].Payload.CustomDetails["message"] != "timeout" {
+		t.Errorf("unexpected trigger event %+v", events[0])
+	}
+	if events[1].EventAction != "resolve" || events[1].Payload != nil {
+		t.Errorf("unexpected resolve event %+v", events[1])
+	}
+	if events[0].DedupKey != events[1].DedupKey {
+		t.Errorf("expected matching dedup keys got %v and %v", events[0].DedupKey, events[1].DedupKey)
+	}
+
+	bad := &PagerDutyNotifier{RoutingKey: "wrong", URL: ts.URL}
+	if err := bad.Notify(Alert{Type: AlertTypeDown, Service: s}); !errors.Is(err, ErrPagerDutyRejected) {
+		t.Errorf("expected %v got %v", ErrPagerDutyRejected, err)
+	}
+}
+
+func TestPagerDutyNotifierTest(t *testing.T) {
+	var events []pagerDutyEvent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e pagerDutyEvent
+		json.NewDecoder(r.Body).Decode(&e)
+		events = append(events, e)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	n := &PagerDutyNotifier{RoutingKey: "key", URL: ts.URL}
+	s := Service{Name: "service_status"}
+	if err := n.Notify(Alert{Type: AlertTypeTest, Severity: SeverityInfo, Service: s, Time: time.Now()}); err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events got %d", len(events))
+	}
+	if events[0].EventAction != "trigger" || events[0].Payload.Severity != "info" {
+		t.Errorf("unexpected trigger event %+v", events[0])
+	}
+	if events[1].EventAction != "resolve" || events[1].DedupKey != events[0].DedupKey {
+		t.Errorf("expected the test incident to be resolved got %+v", events[1])
+	}
+	if events[0].DedupKey == pagerDutyDedupKey(s) {
+		t.Errorf("expected a dedup key of the test got %v", events[0].DedupKey)
+	}
+}
+
+func TestPagerDutyNotifierBurnRate(t *testing.T) {
+	var events []pagerDutyEvent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e pagerDutyEvent
+		json.NewDecoder(r.Body).Decode(&e)
+		events = append(events, e)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	n := &PagerDutyNotifier{RoutingKey: "key", URL: ts.URL}
+	s := Service{Name: "api", URL: "https://api.example.com"}
+	for _, typ := range []AlertType{AlertTypeBurnRate, AlertTypeBurnRateResolved} {
+		if err := n.Notify(Alert{Type: typ, Severity: SeverityWarning, Service: s, Time: time.Now()}); err != nil {
+			t.Fatalf("%s: expected nil got %v", typ, err)
+		}
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events got %d", len(events))
+	}
+	if events[0].EventAction != "trigger" || events[0].DedupKey == pagerDutyDedupKey(s) {
+		t.Errorf("expected a burn rate incident apart from the outage got %+v", events[0])
+	}
+	if events[1].EventAction != "resolve" || events[1].DedupKey != events[0].DedupKey {
+		t.Errorf("expected the burn rate incident to be resolved got %+v", events[1])
+	}
+}
+
+func TestNewNotifierPagerDutyMissingKey(t *testing.T) {
+	if _, err := NewNotifier(NotifierConfig{Type: "pagerduty"}); err != ErrMissingRoutingKey {
+		t.Errorf("expected %v got %v", ErrMissingRoutingKey, err)
+	}
+}
@@ -0,0 +1,51 @@
+package status
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// Page sizes of the paginated endpoints
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// ErrPagination is returned for an invalid page or size
+var ErrPagination = errors.New("pagination: page must be at least 1 and size between 1 and 1000")
+
+// Pagination is the page of a list an endpoint answered with, and
+// how many items the list has in all
+type Pagination struct {
+	Page  int `json:"page"`
+	Size  int `json:"size"`
+	Total int `json:"total"`
+}
+
+// offset is how many items come before the page
+func (p Pagination) offset() int {
+	return (p.Page - 1) * p.Size
+}
+
+// parsePagination returns the page and size query parameters, the
+// first page of defaultPageSize items by default
+func parsePagination(q url.Values) (Pagination, error) {
+	p := Pagination{Page: 1, Size: defaultPageSize}
+	for _, v := range []struct {
+		name string
+		n    *int
+	}{{"page", &p.Page}, {"size", &p.Size}} {
+		if s := q.Get(v.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return p, ErrPagination
+			}
+			*v.n = n
+		}
+	}
+	if p.Page < 1 || p.Size < 1 || p.Size > maxPageSize {
+		return p, ErrPagination
+	}
+	return p, nil
+}
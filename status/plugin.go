@@ -0,0 +1,102 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// ErrInvalidPluginResult is returned when a plugin does not
+// write a valid result to stdout
+var ErrInvalidPluginResult = errors.New("commands: invalid plugin result")
+
+// maxPluginResult is the number of bytes read from the
+// stdout of a plugin
+const maxPluginResult = 64 * 1024
+
+// PluginResult is the JSON a plugin writes to stdout. State
+// is "up", "degraded" or "down".
+type PluginResult struct {
+	State   State  `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+// Plugin runs an external checker. The service is written to the
+// plugin as JSON on stdin and it answers with a PluginResult on
+// stdout, so checkers can be written in any language.
+type Plugin struct {
+	Service
+	timeout time.Duration
+}
+
+// GetService return the Service pointer
+func (p *Plugin) GetService() *Service {
+	return &p.Service
+}
+
+// Status runs the plugin and converts its result
+func (p *Plugin) Status() error {
+	spec, err := json.Marshal(p.Service)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(p.Command, p.Args...)
+	cmd.Env = append(os.Environ(), envList(p.Env)...)
+	cmd.Stdin = bytes.NewReader(spec)
+	stdout := &limitedBuffer{max: maxPluginResult}
+	stderr := &limitedBuffer{max: maxScriptOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := runWithTimeout(cmd, p.timeout); err != nil {
+		return &ScriptError{Err: err, Output: stderr.String()}
+	}
+
+	var result PluginResult
+	if stdout.truncated || json.Unmarshal(stdout.buf.Bytes(), &result) != nil {
+		return ErrInvalidPluginResult
+	}
+
+	switch result.State {
+	case StateUp:
+		return nil
+	case StateDegraded:
+		return Degraded(result.Message)
+	case StateDown:
+		return fmt.Errorf("commands: plugin reported down: %s", result.Message)
+	}
+	return ErrInvalidPluginResult
+}
+
+// PluginFactory implements the PingerFactory
+// interface
+type PluginFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *PluginFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "plugin" {
+		return nil, ErrInvalidCreate
+	}
+	if s.Command == "" {
+		return nil, ErrMissingCommand
+	}
+
+	timeout := defaultScriptTimeout
+	if s.Timeout != "" {
+		d, err := time.ParseDuration(s.Timeout)
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidTimeout
+		}
+		timeout = d
+	}
+
+	return &Plugin{
+		Service: s,
+		timeout: timeout,
+	}, nil
+}
@@ -0,0 +1,60 @@
+package status
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestPluginStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	tt := []struct {
+		name     string
+		script   string
+		up       bool
+		degraded bool
+	}{
+		{name: "up", script: `echo '{"state": "up"}'`, up: true},
+		{name: "degraded", script: `echo '{"state": "degraded", "message": "slow"}'`, degraded: true},
+		{name: "down", script: `echo '{"state": "down", "message": "queue full"}'`},
+		{name: "reads spec", script: `grep -q '"name":"queue"' && echo '{"state": "up"}'`, up: true},
+		{name: "exit status", script: `echo broken >&2; exit 1`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Plugin{Service: Service{Name: "queue", Command: "sh", Args: []string{"-c", tc.script}}, timeout: defaultScriptTimeout}
+			err := p.Status()
+			switch {
+			case tc.up && err != nil:
+				t.Errorf("expected nil got %v", err)
+			case tc.degraded && !IsDegraded(err):
+				t.Errorf("expected degraded got %v", err)
+			case !tc.up && !tc.degraded && (err == nil || IsDegraded(err)):
+				t.Errorf("expected down got %v", err)
+			}
+		})
+	}
+}
+
+func TestPluginInvalidResult(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	for _, out := range []string{"not json", `{"state": "sideways"}`} {
+		p := Plugin{Service: Service{Command: "sh", Args: []string{"-c", "echo '" + out + "'"}}, timeout: defaultScriptTimeout}
+		if err := p.Status(); err != ErrInvalidPluginResult {
+			t.Errorf("%s: expected %v got %v", out, ErrInvalidPluginResult, err)
+		}
+	}
+}
+
+func TestPluginFactoryCreateErr(t *testing.T) {
+	f := PluginFactory{}
+	if _, err := f.Create(Service{Type: "plugin"}); err != ErrMissingCommand {
+		t.Errorf("expected %v got %v", ErrMissingCommand, err)
+	}
+}
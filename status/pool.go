@@ -0,0 +1,35 @@
+package status
+
+import "fmt"
+
+// degradePools marks the instances of a pool which are down as
+// degraded while other instances of the pool are up, so losing some
+// instances of a service is not alerted as its outage. Instances in
+// maintenance are not counted.
+func degradePools(results []Result) {
+	type pool struct{ down, total int }
+	pools := make(map[string]*pool)
+	for _, r := range results {
+		if r.Service.Pool == "" || r.State == StateMaintenance {
+			continue
+		}
+		p, ok := pools[r.Service.Pool]
+		if !ok {
+			p = &pool{}
+			pools[r.Service.Pool] = p
+		}
+		p.total++
+		if r.State == StateDown {
+			p.down++
+		}
+	}
+	for i := range results {
+		r := &results[i]
+		p := pools[r.Service.Pool]
+		if r.State != StateDown || p == nil || p.down == p.total {
+			continue
+		}
+		r.State = StateDegraded
+		r.Message = fmt.Sprintf("%d of %d instances of %s down", p.down, p.total, r.Service.Pool)
+	}
+}
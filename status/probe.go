@@ -0,0 +1,327 @@
+package status
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Errors returned by the tcp and icmp checks
+var (
+	ErrInvalidProber   = errors.New("commands: prober must be native or exec")
+	ErrMissingPort     = errors.New("commands: tcp check needs a port")
+	ErrProbeTimeout    = errors.New("commands: no reply before timeout")
+	ErrExecUnsupported = errors.New("commands: exec prober not supported on this platform")
+)
+
+// defaultProbeTimeout is used when a tcp or icmp service
+// does not configure a timeout
+const defaultProbeTimeout = 5 * time.Second
+
+const (
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+// Prober checks that a host can be reached. The target is a
+// host for ICMP probes and a host:port for TCP probes.
+type Prober interface {
+	Probe(target string) error
+}
+
+// tcpProber connects to the target with the net package
+type tcpProber struct {
+	network string
+	timeout time.Duration
+}
+
+func (p *tcpProber) Probe(target string) error {
+	conn, err := net.DialTimeout(p.network, target, p.timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// icmpProber sends an ICMP echo request over a raw socket, which
+// needs root or CAP_NET_RAW
+type icmpProber struct {
+	network string
+	timeout time.Duration
+}
+
+// icmpSeq numbers the echo requests of the process. A raw socket
+// receives the replies to every probe, which share the identifier of
+// the process, so each probe only accepts a reply from its target
+// which echoes its own sequence number.
+var icmpSeq uint32
+
+// nextICMPSeq returns the sequence number of a new echo request
+func nextICMPSeq() int {
+	return int(atomic.AddUint32(&icmpSeq, 1) & 0xffff)
+}
+
+func (p *icmpProber) Probe(target string) error {
+	addr, err := net.ResolveIPAddr(p.network, target)
+	if err != nil {
+		return err
+	}
+
+	proto, request, reply := "ip4:icmp", icmpEchoRequest, icmpEchoReply
+	if addr.IP.To4() == nil {
+		proto, request, reply = "ip6:ipv6-icmp", icmpv6EchoRequest, icmpv6EchoReply
+	}
+
+	conn, err := net.ListenPacket(proto, "")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	id, seq := os.Getpid()&0xffff, nextICMPSeq()
+	if _, err := conn.WriteTo(icmpMessage(request, id, seq), addr); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(p.timeout))
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			return ErrProbeTimeout
+		}
+		if ip, ok := peer.(*net.IPAddr); !ok || !ip.IP.Equal(addr.IP) {
+			continue
+		}
+		b := buf[:n]
+		if len(b) >= 8 && int(b[0]) == reply && int(b[4])<<8|int(b[5]) == id && int(b[6])<<8|int(b[7]) == seq {
+			return nil
+		}
+	}
+}
+
+// execProber runs a system tool and treats a zero exit status
+// as the target being reachable
+type execProber struct {
+	args func(target string) (string, []string, error)
+}
+
+func (p *execProber) Probe(target string) error {
+	name, args, err := p.args(target)
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("commands: %s failed: %v: %s", name, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// pingArgs returns the ping command line for goos. Only Linux,
+// macOS and Windows flags are known.
+func pingArgs(goos, family, host string, timeout time.Duration) (string, []string, error) {
+	secs := strconv.Itoa(int((timeout + time.Second - 1) / time.Second))
+	switch goos {
+	case "linux":
+		return "ping", append([]string{"-c", "1", "-W", secs}, familyFlag(family, host)...), nil
+	case "darwin":
+		// macOS has no -4/-6 flags and ping6 has no timeout flag
+		if family == "v6" {
+			return "ping6", []string{"-c", "1", host}, nil
+		}
+		return "ping", []string{"-c", "1", "-t", secs, host}, nil
+	case "windows":
+		ms := strconv.Itoa(int(timeout / time.Millisecond))
+		return "ping", append([]string{"-n", "1", "-w", ms}, familyFlag(family, host)...), nil
+	}
+	return "", nil, ErrExecUnsupported
+}
+
+// ncArgs returns the netcat command line testing host:port is open
+func ncArgs(goos, family, target string, timeout time.Duration) (string, []string, error) {
+	if goos == "windows" {
+		return "", nil, ErrExecUnsupported
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return "", nil, err
+	}
+	secs := strconv.Itoa(int((timeout + time.Second - 1) / time.Second))
+	return "nc", append([]string{"-z", "-w", secs}, append(familyFlag(family, host), port)...), nil
+}
+
+// familyFlag returns the -4/-6 flag for family followed by host
+func familyFlag(family, host string) []string {
+	switch family {
+	case "v4":
+		return []string{"-4", host}
+	case "v6":
+		return []string{"-6", host}
+	}
+	return []string{host}
+}
+
+// newProbers returns the probers for a tcp or icmp service, one per
+// IP family to probe. A service with ip_family "both" gets an IPv4
+// and an IPv6 prober.
+func newProbers(s Service, kind string) (Prober, Prober, error) {
+	timeout := defaultProbeTimeout
+	if s.Timeout != "" {
+		d, err := time.ParseDuration(s.Timeout)
+		if err != nil || d <= 0 {
+			return nil, nil, ErrInvalidTimeout
+		}
+		timeout = d
+	}
+
+	switch s.Prober {
+	case "", "native", "exec":
+	default:
+		return nil, nil, ErrInvalidProber
+	}
+
+	switch s.IPFamily {
+	case "", "v4", "v6":
+		return newProber(s.Prober, kind, s.IPFamily, timeout), nil, nil
+	case "both":
+		return newProber(s.Prober, kind, "v4", timeout), newProber(s.Prober, kind, "v6", timeout), nil
+	}
+	return nil, nil, ErrInvalidIPFamily
+}
+
+func newProber(prober, kind, family string, timeout time.Duration) Prober {
+	if prober == "exec" {
+		return &execProber{args: func(target string) (string, []string, error) {
+			if kind == "tcp" {
+				return ncArgs(runtime.GOOS, family, target, timeout)
+			}
+			return pingArgs(runtime.GOOS, family, target, timeout)
+		}}
+	}
+
+	suffix := ""
+	switch family {
+	case "v4":
+		suffix = "4"
+	case "v6":
+		suffix = "6"
+	}
+	if kind == "tcp" {
+		return &tcpProber{network: "tcp" + suffix, timeout: timeout}
+	}
+	return &icmpProber{network: "ip" + suffix, timeout: timeout}
+}
+
+// probeHost returns the host of a tcp or icmp service, whose
+// URL may be a bare host or a URL such as tcp://db:5432
+func probeHost(s Service) (string, string) {
+	if u, err := url.Parse(s.URL); err == nil && u.Hostname() != "" {
+		port := u.Port()
+		if port == "" {
+			port = s.Port
+		}
+		return u.Hostname(), port
+	}
+	return s.URL, s.Port
+}
+
+// TCP checks a port accepts connections
+type TCP struct {
+	Service
+	prober Prober
+	// prober6 is set when ip_family is "both", prober
+	// then only connects over IPv4
+	prober6 Prober
+}
+
+// GetService return the Service pointer
+func (t *TCP) GetService() *Service {
+	return &t.Service
+}
+
+// Status connects to the host and port of the service
+func (t *TCP) Status() error {
+	host, port := probeHost(t.Service)
+	target := net.JoinHostPort(host, port)
+	err := t.prober.Probe(target)
+	if t.prober6 == nil {
+		return err
+	}
+	return bothFamilies(err, t.prober6.Probe(target))
+}
+
+// TCPFactory implements the PingerFactory
+// interface
+type TCPFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *TCPFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "tcp" {
+		return nil, ErrInvalidCreate
+	}
+	if _, port := probeHost(s); port == "" {
+		return nil, ErrMissingPort
+	}
+	prober, prober6, err := newProbers(s, "tcp")
+	if err != nil {
+		return nil, err
+	}
+	return &TCP{
+		Service: s,
+		prober:  prober,
+		prober6: prober6,
+	}, nil
+}
+
+// ICMP checks a host answers ICMP echo requests
+type ICMP struct {
+	Service
+	prober Prober
+	// prober6 is set when ip_family is "both", prober
+	// then only pings over IPv4
+	prober6 Prober
+}
+
+// GetService return the Service pointer
+func (i *ICMP) GetService() *Service {
+	return &i.Service
+}
+
+// Status pings the host of the service
+func (i *ICMP) Status() error {
+	host, _ := probeHost(i.Service)
+	err := i.prober.Probe(host)
+	if i.prober6 == nil {
+		return err
+	}
+	return bothFamilies(err, i.prober6.Probe(host))
+}
+
+// ICMPFactory implements the PingerFactory
+// interface
+type ICMPFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *ICMPFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "icmp" {
+		return nil, ErrInvalidCreate
+	}
+	prober, prober6, err := newProbers(s, "icmp")
+	if err != nil {
+		return nil, err
+	}
+	return &ICMP{
+		Service: s,
+		prober:  prober,
+		prober6: prober6,
+	}, nil
+}
@@ -0,0 +1,166 @@
+package status
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProber records the targets probed and returns err
+type fakeProber struct {
+	err     error
+	targets []string
+}
+
+func (p *fakeProber) Probe(target string) error {
+	p.targets = append(p.targets, target)
+	return p.err
+}
+
+func TestTCPStatus(t *testing.T) {
+	fp := &fakeProber{}
+	tc := TCP{Service: Service{URL: "tcp://db.internal:5432"}, prober: fp}
+	if err := tc.Status(); err != nil {
+		t.Errorf("expected nil got %v", err)
+	}
+
+	tc = TCP{Service: Service{URL: "db.internal", Port: "6379"}, prober: fp}
+	tc.Status()
+
+	expected := []string{"db.internal:5432", "db.internal:6379"}
+	if !reflect.DeepEqual(fp.targets, expected) {
+		t.Errorf("expected %v got %v", expected, fp.targets)
+	}
+}
+
+func TestICMPStatusBothFamilies(t *testing.T) {
+	i := ICMP{Service: Service{URL: "example.com"}, prober: &fakeProber{}, prober6: &fakeProber{err: ErrProbeTimeout}}
+	if err := i.Status(); !IsDegraded(err) {
+		t.Errorf("expected degraded got %v", err)
+	}
+}
+
+func TestTCPProber(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+
+	p := &tcpProber{network: "tcp4", timeout: time.Second}
+	if err := p.Probe(addr); err != nil {
+		t.Errorf("expected nil got %v", err)
+	}
+
+	l.Close()
+	if err := p.Probe(addr); err == nil {
+		t.Error("expected error for closed port")
+	}
+}
+
+func TestICMPProberLoopback(t *testing.T) {
+	p := &icmpProber{network: "ip4", timeout: time.Second}
+	err := p.Probe("127.0.0.1")
+	if err != nil && strings.Contains(err.Error(), "permitted") {
+		t.Skip("raw sockets not permitted")
+	}
+	if err != nil {
+		t.Errorf("expected nil got %v", err)
+	}
+}
+
+func TestNextICMPSeq(t *testing.T) {
+	const probes = 100
+	seqs := make(chan int, probes)
+	var wg sync.WaitGroup
+	for i := 0; i < probes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seqs <- nextICMPSeq()
+		}()
+	}
+	wg.Wait()
+	close(seqs)
+
+	seen := make(map[int]bool)
+	for seq := range seqs {
+		if seen[seq] {
+			t.Errorf("expected concurrent probes to get their own sequence got %d twice", seq)
+		}
+		seen[seq] = true
+	}
+}
+
+func TestExecArgs(t *testing.T) {
+	tt := []struct {
+		name     string
+		args     func() (string, []string, error)
+		expected []string
+	}{
+		{
+			name:     "ping linux",
+			args:     func() (string, []string, error) { return pingArgs("linux", "v6", "example.com", 1500*time.Millisecond) },
+			expected: []string{"ping", "-c", "1", "-W", "2", "-6", "example.com"},
+		},
+		{
+			name:     "ping darwin",
+			args:     func() (string, []string, error) { return pingArgs("darwin", "", "example.com", time.Second) },
+			expected: []string{"ping", "-c", "1", "-t", "1", "example.com"},
+		},
+		{
+			name:     "ping windows",
+			args:     func() (string, []string, error) { return pingArgs("windows", "v4", "example.com", time.Second) },
+			expected: []string{"ping", "-n", "1", "-w", "1000", "-4", "example.com"},
+		},
+		{
+			name:     "nc",
+			args:     func() (string, []string, error) { return ncArgs("linux", "", "db.internal:5432", 5*time.Second) },
+			expected: []string{"nc", "-z", "-w", "5", "db.internal", "5432"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			name, args, err := tc.args()
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if actual := append([]string{name}, args...); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, actual)
+			}
+		})
+	}
+
+	if _, _, err := ncArgs("windows", "", "db:1", time.Second); err != ErrExecUnsupported {
+		t.Errorf("expected %v got %v", ErrExecUnsupported, err)
+	}
+}
+
+func TestProbeFactoryCreate(t *testing.T) {
+	tt := []struct {
+		name     string
+		factory  PingerFactory
+		service  Service
+		expected error
+	}{
+		{name: "tcp wrong type", factory: &TCPFactory{}, service: Service{Type: "icmp"}, expected: ErrInvalidCreate},
+		{name: "tcp no port", factory: &TCPFactory{}, service: Service{Type: "tcp", URL: "db"}, expected: ErrMissingPort},
+		{name: "tcp", factory: &TCPFactory{}, service: Service{Type: "tcp", URL: "db", Port: "5432"}, expected: nil},
+		{name: "icmp bad prober", factory: &ICMPFactory{}, service: Service{Type: "icmp", URL: "db", Prober: "curl"}, expected: ErrInvalidProber},
+		{name: "icmp bad family", factory: &ICMPFactory{}, service: Service{Type: "icmp", URL: "db", IPFamily: "v5"}, expected: ErrInvalidIPFamily},
+		{name: "icmp bad timeout", factory: &ICMPFactory{}, service: Service{Type: "icmp", URL: "db", Timeout: "-1s"}, expected: ErrInvalidTimeout},
+		{name: "icmp exec", factory: &ICMPFactory{}, service: Service{Type: "icmp", URL: "db", Prober: "exec"}, expected: nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.factory.Create(tc.service); err != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, err)
+			}
+		})
+	}
+}
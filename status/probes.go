@@ -0,0 +1,80 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// readiness is the answer of ReadinessHandler: "ok", or what is not
+// ready, for each of its checks
+type readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// LivenessHandler is a HandlerFunc which answers 200 as long as the
+// process serves requests (GET /healthz), for orchestrators to
+// restart it otherwise
+func LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("ok\n"))
+	}
+}
+
+// ReadinessHandler is a HandlerFunc which reports whether the service
+// is ready for traffic (GET /readyz): the page was built from the
+// config, the storage answers a read and its last write did not fail,
+// and the services were last checked within maxAge. It answers 200
+// when ready and 503 otherwise, with the outcome of each check.
+func ReadinessHandler(db StorageBackend, h *StorageHealth, page *PageStore, maxAge time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		now := time.Now()
+		res := readiness{Status: "ok", Checks: map[string]string{"config": "ok", "storage": "ok", "sweep": "ok"}}
+		fail := func(check, reason string) {
+			res.Status, res.Checks[check] = "unavailable", reason
+		}
+
+		var updated time.Time
+		if page != nil {
+			updated = page.Page().Updated
+		}
+		switch {
+		case updated.IsZero():
+			fail("config", "no page built yet")
+			fail("sweep", "no sweep yet")
+		case now.Sub(updated) > maxAge:
+			fail("sweep", "last sweep "+now.Sub(updated).Round(time.Second).String()+" ago")
+		}
+
+		ctx, cancel := requestContext(r)
+		defer cancel()
+		if _, err := db.Services(ctx); err != nil {
+			fail("storage", err.Error())
+		} else if h != nil {
+			if err := h.Check(now); errors.Is(err, ErrStorageWrite) {
+				fail("storage", err.Error())
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if res.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(res)
+	}
+}
@@ -0,0 +1,75 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unreadableStorage is a StorageBackend which cannot be read
+type unreadableStorage struct {
+	*Storage
+}
+
+func (db unreadableStorage) Services(context.Context) ([]ServiceInfo, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestLivenessHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	LivenessHandler()(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok\n" {
+		t.Errorf("expected ok got %d %q", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	LivenessHandler()(w, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	s, _ := OpenStorage("")
+	failing := &failingStorage{Storage: s}
+	health := NewStorageHealth()
+	db := WithHealth(failing, health)
+	page := NewPageStore(NewPage("Status", []Result{{Service: &Service{Name: "web"}, State: StateUp}}))
+	ready := func(h http.HandlerFunc, r *http.Request) (int, readiness) {
+		w := httptest.NewRecorder()
+		h(w, r)
+		var res readiness
+		json.NewDecoder(w.Body).Decode(&res)
+		return w.Code, res
+	}
+	get := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+	if code, res := ready(ReadinessHandler(db, health, page, time.Minute), get); code != http.StatusOK || res.Status != "ok" || res.Checks["storage"] != "ok" {
+		t.Errorf("expected ready got %d %+v", code, res)
+	}
+
+	// a sweep overdue
+	stale := NewPage("Status", nil)
+	stale.Updated = time.Now().Add(-5 * time.Minute)
+	code, res := ready(ReadinessHandler(db, health, NewPageStore(stale), time.Minute), get)
+	if code != http.StatusServiceUnavailable || res.Status != "unavailable" || !strings.HasPrefix(res.Checks["sweep"], "last sweep 5m0s ago") || res.Checks["config"] != "ok" {
+		t.Errorf("expected the sweep overdue got %d %+v", code, res)
+	}
+	if _, res := ready(ReadinessHandler(db, health, nil, time.Minute), get); res.Checks["config"] == "ok" {
+		t.Errorf("expected no page built got %+v", res)
+	}
+
+	// the storage cannot be read or written
+	if code, res := ready(ReadinessHandler(unreadableStorage{s}, health, page, time.Minute), get); code != http.StatusServiceUnavailable || res.Checks["storage"] != "connection refused" {
+		t.Errorf("expected an unreadable storage got %d %+v", code, res)
+	}
+	failing.err = errors.New("disk full")
+	db.RecordStatus(context.Background(), StatusRecord{Service: "web", State: StateUp, Time: time.Now()})
+	if code, res := ready(ReadinessHandler(db, health, page, time.Minute), get); code != http.StatusServiceUnavailable || !strings.Contains(res.Checks["storage"], "disk full") {
+		t.Errorf("expected a failing storage got %d %+v", code, res)
+	}
+}
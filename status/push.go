@@ -0,0 +1,338 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned pushing the results of the checks as metrics
+var (
+	ErrMetricsPushConfig   = errors.New("metrics: push needs a url and a type of pushgateway or remote_write")
+	ErrMetricsPushRejected = errors.New("metrics: push rejected")
+)
+
+// metricsPushQueue is how many sweeps wait to be pushed before the
+// oldest are dropped
+const metricsPushQueue = 16
+
+// MetricsPushConfig pushes the results of every sweep to a Prometheus
+// Pushgateway or a remote-write endpoint, so their metrics are kept
+// in an existing time series database
+type MetricsPushConfig struct {
+	// Type is "pushgateway" or "remote_write"
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// Job and Instance label the metrics, Job "service_status" by
+	// default. They group them on a Pushgateway.
+	Job      string `json:"job,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Labels are added to every metric
+	Labels map[string]string `json:"labels,omitempty"`
+	// Username and Password authenticate with basic auth, or
+	// BearerToken with a token. Password and BearerToken may be
+	// "env:NAME".
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	BearerToken string `json:"bearer_token,omitempty"`
+	// Timeout bounds each push, 10s by default
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// metricSample is the value of a metric for a set of labels, sorted
+// by name
+type metricSample struct {
+	name   string
+	labels [][2]string
+	value  float64
+}
+
+// MetricsPusher pushes the results of the sweeps in the background,
+// so a slow endpoint does not hold the checks up
+type MetricsPusher struct {
+	config MetricsPushConfig
+	client *http.Client
+	queue  chan pushBatch
+}
+
+// pushBatch is the results of a sweep
+type pushBatch struct {
+	results []Result
+	at      time.Time
+}
+
+// NewMetricsPusher returns the MetricsPusher of the config
+func NewMetricsPusher(c MetricsPushConfig) (*MetricsPusher, error) {
+	if c.URL == "" || c.Type != "pushgateway" && c.Type != "remote_write" {
+		return nil, ErrMetricsPushConfig
+	}
+	if _, err := url.Parse(c.URL); err != nil {
+		return nil, err
+	}
+	if c.Job == "" {
+		c.Job = "service_status"
+	}
+	timeout := 10 * time.Second
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		timeout = d
+	}
+	return &MetricsPusher{
+		config: c,
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan pushBatch, metricsPushQueue),
+	}, nil
+}
+
+// Record queues the results of a sweep to be pushed, dropping the
+// oldest sweep queued when the endpoint falls behind
+func (p *MetricsPusher) Record(results []Result, now time.Time) {
+	b := pushBatch{results: results, at: now}
+	for {
+		select {
+		case p.queue <- b:
+			return
+		default:
+		}
+		select {
+		case <-p.queue:
+			log.Printf("push metrics: queue full, dropped a sweep")
+		default:
+		}
+	}
+}
+
+// Run pushes the queued sweeps. It does not return.
+func (p *MetricsPusher) Run() {
+	for b := range p.queue {
+		if err := p.Push(context.Background(), b.results, b.at); err != nil {
+			log.Printf("push metrics: %v", err)
+		}
+	}
+}
+
+// Push sends the metrics of the results checked at now
+func (p *MetricsPusher) Push(ctx context.Context, results []Result, now time.Time) error {
+	samples := p.samples(results)
+	var req *http.Request
+	var err error
+	if p.config.Type == "pushgateway" {
+		req, err = p.pushgatewayRequest(ctx, samples)
+	} else {
+		req, err = p.remoteWriteRequest(ctx, samples, now)
+	}
+	if err != nil {
+		return err
+	}
+	if p.config.Username != "" {
+		req.SetBasicAuth(p.config.Username, readSecret(p.config.Password))
+	} else if p.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+readSecret(p.config.BearerToken))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{Err: ErrMetricsPushRejected, Code: resp.StatusCode}
+	}
+	return nil
+}
+
+// samples returns the metrics of the results: whether each service is
+// up, which is not down or affected, its state, one series per state,
+// and the response time of its check
+func (p *MetricsPusher) samples(results []Result) []metricSample {
+	var samples []metricSample
+	for _, r := range results {
+		labels := map[string]string{"service": r.Service.ID()}
+		if r.Service.Group != "" {
+			labels["group"] = r.Service.Group
+		}
+		up := 1.0
+		if r.State == StateDown || r.State == StateAffected {
+			up = 0
+		}
+		samples = append(samples, p.sample("service_status_up", labels, up))
+		for _, s := range []State{StateUp, StateDegraded, StateDown, StateAffected, StateMaintenance} {
+			value := 0.0
+			if r.State == s {
+				value = 1
+			}
+			samples = append(samples, p.sample("service_status_state", labels, value, "state", string(s)))
+		}
+		if r.Latency > 0 {
+			samples = append(samples, p.sample("service_status_response_time_seconds", labels, r.Latency.Seconds()))
+		}
+	}
+	return samples
+}
+
+// sample returns a sample labelled with labels, the extra labels of
+// the config and the pairs of names and values of more
+func (p *MetricsPusher) sample(name string, labels map[string]string, value float64, more ...string) metricSample {
+	all := make(map[string]string, len(labels)+len(p.config.Labels)+len(more)/2)
+	for k, v := range p.config.Labels {
+		all[k] = v
+	}
+	for k, v := range labels {
+		all[k] = v
+	}
+	for i := 0; i+1 < len(more); i += 2 {
+		all[more[i]] = more[i+1]
+	}
+	s := metricSample{name: name, value: value}
+	for k, v := range all {
+		s.labels = append(s.labels, [2]string{k, v})
+	}
+	sort.Slice(s.labels, func(i, j int) bool { return s.labels[i][0] < s.labels[j][0] })
+	return s
+}
+
+// pushgatewayRequest replaces the metrics of the group of the job and
+// instance on a Pushgateway with the samples, in the text format
+func (p *MetricsPusher) pushgatewayRequest(ctx context.Context, samples []metricSample) (*http.Request, error) {
+	path := "/metrics" + pushgatewayLabel("job", p.config.Job)
+	if p.config.Instance != "" {
+		path += pushgatewayLabel("instance", p.config.Instance)
+	}
+	// the samples of a metric are written together, after its type
+	samples = append([]metricSample(nil), samples...)
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].name < samples[j].name })
+	var b strings.Builder
+	for i, s := range samples {
+		if i == 0 || samples[i-1].name != s.name {
+			fmt.Fprintf(&b, "# TYPE %s gauge\n", s.name)
+		}
+		b.WriteString(s.name)
+		for i, l := range s.labels {
+			if i == 0 {
+				b.WriteByte('{')
+			} else {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=\"%s\"", l[0], escapeLabelValue(l[1]))
+		}
+		if len(s.labels) > 0 {
+			b.WriteByte('}')
+		}
+		b.WriteString(" " + strconv.FormatFloat(s.value, 'g', -1, 64) + "\n")
+	}
+	req, err := http.NewRequest(http.MethodPut, strings.TrimSuffix(p.config.URL, "/")+path, strings.NewReader(b.String()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	return req.WithContext(ctx), nil
+}
+
+// pushgatewayLabel returns the path segments of a label of the
+// grouping key, base64 encoded when the value holds a slash
+func pushgatewayLabel(name, value string) string {
+	if strings.Contains(value, "/") {
+		return "/" + name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return "/" + name + "/" + url.PathEscape(value)
+}
+
+// escapeLabelValue escapes a label value of the text format
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// remoteWriteRequest sends the samples at now to a remote-write
+// endpoint, as a snappy compressed WriteRequest protobuf
+func (p *MetricsPusher) remoteWriteRequest(ctx context.Context, samples []metricSample, now time.Time) (*http.Request, error) {
+	ts := now.UnixNano() / int64(time.Millisecond)
+	var body []byte
+	for _, s := range samples {
+		labels := append([][2]string{{"__name__", s.name}, {"job", p.config.Job}}, s.labels...)
+		if p.config.Instance != "" {
+			labels = append(labels, [2]string{"instance", p.config.Instance})
+		}
+		sort.SliceStable(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+		var series []byte
+		for _, l := range labels {
+			var label []byte
+			label = appendProtoBytes(label, 1, []byte(l[0]))
+			label = appendProtoBytes(label, 2, []byte(l[1]))
+			series = appendProtoBytes(series, 1, label)
+		}
+		var sample []byte
+		sample = appendProtoFixed64(sample, 1, math.Float64bits(s.value))
+		sample = appendProtoVarint(sample, 2, uint64(ts))
+		series = appendProtoBytes(series, 2, sample)
+		body = appendProtoBytes(body, 1, series)
+	}
+	req, err := http.NewRequest(http.MethodPost, p.config.URL, bytes.NewReader(snappyEncode(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return req.WithContext(ctx), nil
+}
+
+// appendUvarint appends v as a varint
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// appendProtoBytes, appendProtoVarint and appendProtoFixed64 append a
+// length-delimited, varint or fixed64 field of a protobuf
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendUvarint(b, uint64(field)<<3|2)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	return appendUvarint(appendUvarint(b, uint64(field)<<3), v)
+}
+
+func appendProtoFixed64(b []byte, field int, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(appendUvarint(b, uint64(field)<<3|1), buf[:]...)
+}
+
+// snappyEncode encodes src in the snappy block format, as literals:
+// remote write requires snappy, and the requests are small enough
+// not to need compressing
+func snappyEncode(src []byte) []byte {
+	b := appendUvarint(nil, uint64(len(src)))
+	for len(src) > 0 {
+		n := len(src)
+		if n > 1<<16 {
+			n = 1 << 16
+		}
+		switch {
+		case n <= 60:
+			b = append(b, byte(n-1)<<2)
+		case n <= 1<<8:
+			b = append(b, 60<<2, byte(n-1))
+		default:
+			b = append(b, 61<<2, byte(n-1), byte((n-1)>>8))
+		}
+		b = append(b, src[:n]...)
+		src = src[n:]
+	}
+	return b
+}
@@ -0,0 +1,186 @@
+package status
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeSnappy decodes the snappy block format, literals only
+func decodeSnappy(t *testing.T, b []byte) []byte {
+	n, i := binary.Uvarint(b)
+	b = b[i:]
+	var out []byte
+	for len(b) > 0 {
+		tag := b[0]
+		if tag&3 != 0 {
+			t.Fatalf("unexpected copy tag %x", tag)
+		}
+		length, skip := int(tag>>2)+1, 1
+		switch tag >> 2 {
+		case 60:
+			length, skip = int(b[1])+1, 2
+		case 61:
+			length, skip = int(b[1])|int(b[2])<<8+1, 3
+		}
+		out = append(out, b[skip:skip+length]...)
+		b = b[skip+length:]
+	}
+	if uint64(len(out)) != n {
+		t.Fatalf("expected %d bytes got %d", n, len(out))
+	}
+	return out
+}
+
+// protoFields decodes the fields of a protobuf message by number,
+// fixed64 and varints as uint64 and length-delimited fields as bytes
+func protoFields(t *testing.T, b []byte) map[int][]interface{} {
+	fields := make(map[int][]interface{})
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			fields[int(key>>3)] = append(fields[int(key>>3)], v)
+			b = b[n:]
+		case 1:
+			fields[int(key>>3)] = append(fields[int(key>>3)], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			fields[int(key>>3)] = append(fields[int(key>>3)], b[n:n+int(l)])
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+func pushResults() []Result {
+	return []Result{
+		{Service: &Service{Name: "api", Group: "edge"}, State: StateUp, Latency: 250 * time.Millisecond},
+		{Service: &Service{Name: "db"}, State: StateDown, Err: errors.New("timeout")},
+	}
+}
+
+func TestMetricsPusherPushgateway(t *testing.T) {
+	var method, path, auth, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		method, path, auth, body = r.Method, r.URL.EscapedPath(), r.Header.Get("Authorization"), string(b)
+	}))
+	defer srv.Close()
+
+	p, err := NewMetricsPusher(MetricsPushConfig{Type: "pushgateway", URL: srv.URL + "/", Instance: "eu/1", Labels: map[string]string{"env": "prod"}, BearerToken: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Push(context.Background(), pushResults(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/service_status/instance@base64/ZXUvMQ" || auth != "Bearer s3cret" {
+		t.Errorf("unexpected request %s %s %s", method, path, auth)
+	}
+	for _, line := range []string{
+		"# TYPE service_status_response_time_seconds gauge\nservice_status_response_time_seconds{env=\"prod\",group=\"edge\",service=\"api\"} 0.25\n",
+		"# TYPE service_status_state gauge\n",
+		`service_status_state{env="prod",service="db",state="down"} 1`,
+		`service_status_state{env="prod",service="db",state="up"} 0`,
+		"# TYPE service_status_up gauge\nservice_status_up{env=\"prod\",group=\"edge\",service=\"api\"} 1\nservice_status_up{env=\"prod\",service=\"db\"} 0\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %q in\n%s", line, body)
+		}
+	}
+	if strings.Count(body, "# TYPE") != 3 {
+		t.Errorf("expected the samples of each metric together got\n%s", body)
+	}
+}
+
+func TestMetricsPusherRemoteWrite(t *testing.T) {
+	var headers http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		b, _ := ioutil.ReadAll(r.Body)
+		body = b
+	}))
+	defer srv.Close()
+
+	p, _ := NewMetricsPusher(MetricsPushConfig{Type: "remote_write", URL: srv.URL + "/api/v1/write", Username: "prom", Password: "pw"})
+	now := time.Unix(1700000000, 0)
+	if err := p.Push(context.Background(), pushResults(), now); err != nil {
+		t.Fatal(err)
+	}
+	if headers.Get("Content-Encoding") != "snappy" || headers.Get("Content-Type") != "application/x-protobuf" || headers.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	if user, pass, _ := (&http.Request{Header: headers}).BasicAuth(); user != "prom" || pass != "pw" {
+		t.Errorf("expected basic auth got %q %q", user, pass)
+	}
+
+	series := make(map[string]float64)
+	for _, ts := range protoFields(t, decodeSnappy(t, body))[1] {
+		f := protoFields(t, ts.([]byte))
+		var labels []string
+		var names []string
+		for _, l := range f[1] {
+			lf := protoFields(t, l.([]byte))
+			names = append(names, string(lf[1][0].([]byte)))
+			labels = append(labels, string(lf[1][0].([]byte))+"="+string(lf[2][0].([]byte)))
+		}
+		if !sort.StringsAreSorted(names) {
+			t.Errorf("expected the labels sorted got %v", names)
+		}
+		sample := protoFields(t, f[2][0].([]byte))
+		if ms := sample[2][0].(uint64); ms != 1700000000000 {
+			t.Errorf("expected the time of the sweep got %d", ms)
+		}
+		series[strings.Join(labels, ",")] = math.Float64frombits(sample[1][0].(uint64))
+	}
+	for s, v := range map[string]float64{
+		"__name__=service_status_up,group=edge,job=service_status,service=api":                    1,
+		"__name__=service_status_up,job=service_status,service=db":                                0,
+		"__name__=service_status_state,job=service_status,service=db,state=down":                  1,
+		"__name__=service_status_response_time_seconds,group=edge,job=service_status,service=api": 0.25,
+	} {
+		if got, ok := series[s]; !ok || got != v {
+			t.Errorf("expected %s %g got %g (%v)", s, v, got, ok)
+		}
+	}
+	if len(series) != 13 {
+		t.Errorf("expected 13 series got %d", len(series))
+	}
+}
+
+func TestMetricsPusherRejected(t *testing.T) {
+	if _, err := NewMetricsPusher(MetricsPushConfig{Type: "graphite", URL: "http://localhost"}); err != ErrMetricsPushConfig {
+		t.Errorf("expected ErrMetricsPushConfig got %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	p, _ := NewMetricsPusher(MetricsPushConfig{Type: "pushgateway", URL: srv.URL})
+	if err := p.Push(context.Background(), pushResults(), time.Now()); !errors.Is(err, ErrMetricsPushRejected) {
+		t.Errorf("expected ErrMetricsPushRejected got %v", err)
+	}
+
+	// a sweep is dropped rather than holding the checks up
+	for i := 0; i < metricsPushQueue+2; i++ {
+		p.Record(pushResults(), time.Now())
+	}
+	if len(p.queue) != metricsPushQueue {
+		t.Errorf("expected a full queue got %d", len(p.queue))
+	}
+}
@@ -0,0 +1,139 @@
+package status
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAlertHeld is returned for an alert held back for quiet hours,
+// which is neither delivered nor failed: it is summarized once they end
+var ErrAlertHeld = errors.New("notify: alert held for quiet hours")
+
+// quietPoll is how often a notifier holding alerts checks
+// whether its quiet hours are over
+const quietPoll = time.Minute
+
+// quietNotifier holds back alerts less severe than except while one
+// of its windows is active, and sends a summary of them once the
+// quiet hours are over
+type quietNotifier struct {
+	Notifier
+	windows []MaintenanceWindow
+	except  Severity
+	poll    time.Duration
+
+	mu   sync.Mutex
+	held []Alert
+	// send delivers the summary. A NotificationManager delivering to
+	// the notifier sets it so the summary is retried, audited and kept
+	// in the dead letters like any alert; without it the summary goes
+	// straight to the notifier.
+	send func(Alert)
+}
+
+// quietHours returns the quiet hours n is wrapped around, nil when it
+// has none
+func quietHours(n Notifier) *quietNotifier {
+	for {
+		switch w := n.(type) {
+		case *quietNotifier:
+			return w
+		case *templateNotifier:
+			n = w.Notifier
+		case *cooldownNotifier:
+			n = w.Notifier
+		case *severityNotifier:
+			n = w.Notifier
+		default:
+			return nil
+		}
+	}
+}
+
+// deliverBy sends the summary with send
+func (n *quietNotifier) deliverBy(send func(Alert)) {
+	n.mu.Lock()
+	n.send = send
+	n.mu.Unlock()
+}
+
+// newQuietNotifier wraps n with quiet hours, validating the windows
+func newQuietNotifier(n Notifier, windows []MaintenanceWindow, except Severity) (*quietNotifier, error) {
+	for _, w := range windows {
+		if err := w.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := except.Validate(); err != nil {
+		return nil, err
+	}
+	if except == "" {
+		except = SeverityCritical
+	}
+	return &quietNotifier{Notifier: n, windows: windows, except: except, poll: quietPoll}, nil
+}
+
+// Notify passes the alert on unless it is held for quiet hours, then
+// returning ErrAlertHeld. Tests are never held.
+func (n *quietNotifier) Notify(a Alert) error {
+	if a.Type == AlertTypeTest || a.Severity.AtLeast(n.except) || !n.quiet(time.Now()) {
+		return n.Notifier.Notify(a)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.held = append(n.held, a)
+	if len(n.held) == 1 {
+		go n.summarizeAfterQuiet()
+	}
+	return ErrAlertHeld
+}
+
+// quiet reports whether t is in one of the windows
+func (n *quietNotifier) quiet(t time.Time) bool {
+	for _, w := range n.windows {
+		if w.Active(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeAfterQuiet waits for the quiet hours to end and sends
+// a summary of the alerts held during them, as urgent as the most
+// urgent of them so it gets past the minimum severity of the notifier
+func (n *quietNotifier) summarizeAfterQuiet() {
+	for n.quiet(time.Now()) {
+		time.Sleep(n.poll)
+	}
+
+	n.mu.Lock()
+	held, send := n.held, n.send
+	n.held = nil
+	n.mu.Unlock()
+
+	severity := SeverityInfo
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d alerts held during quiet hours:\n", len(held))
+	for _, a := range held {
+		if worsens(a.Severity, severity) {
+			severity = a.Severity
+		}
+		fmt.Fprintf(&b, "  %s %s", a.Time.Format("2006-01-02 15:04"), a.Title())
+		if a.Message != "" {
+			fmt.Fprintf(&b, ": %s", firstLine(a.Message))
+		}
+		b.WriteString("\n")
+	}
+
+	summary := Alert{Type: AlertTypeDigest, Severity: severity, Message: b.String(), Time: time.Now()}
+	if send != nil {
+		send(summary)
+	} else if err := n.Notifier.Notify(summary); err != nil {
+		log.Printf("send quiet hours summary: %v", err)
+	}
+}
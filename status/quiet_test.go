@@ -0,0 +1,100 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuietNotifier(t *testing.T) {
+	c := make(chanNotifier, 10)
+	window := MaintenanceWindow{Start: time.Now().Add(-time.Minute), End: time.Now().Add(100 * time.Millisecond)}
+	n, err := newQuietNotifier(c, []MaintenanceWindow{window}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.poll = 10 * time.Millisecond
+
+	api := Service{Name: "api"}
+	n.Notify(Alert{Type: AlertTypeDegraded, Severity: SeverityWarning, Service: api, Message: "slow"})
+	n.Notify(Alert{Type: AlertTypeDown, Severity: SeverityCritical, Service: api, Message: "timeout"})
+	n.Notify(Alert{Type: AlertTypeFlapping, Severity: SeverityWarning, Service: api})
+
+	// critical alerts still go out
+	expectAlert(t, c, AlertTypeDown, 0)
+
+	select {
+	case a := <-c:
+		if a.Type != AlertTypeDigest || !strings.Contains(a.Message, "2 alerts held") || !strings.Contains(a.Message, "api is degraded: slow") {
+			t.Errorf("unexpected summary %+v", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a summary when the quiet hours end")
+	}
+
+	if _, err := NewNotifier(NotifierConfig{Type: "log", QuietHours: []MaintenanceWindow{{Cron: "0 22 * * *"}}}); err != ErrInvalidWindow {
+		t.Errorf("expected %v got %v", ErrInvalidWindow, err)
+	}
+}
+
+// flakyNotifier fails its first fails deliveries, then passes the
+// alerts on to the channel
+type flakyNotifier struct {
+	mu    sync.Mutex
+	fails int
+	chanNotifier
+}
+
+func (f *flakyNotifier) Notify(a Alert) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fails > 0 {
+		f.fails--
+		return errors.New("unavailable")
+	}
+	return f.chanNotifier.Notify(a)
+}
+
+func TestQuietNotifierDelivery(t *testing.T) {
+	c := make(chanNotifier, 10)
+	window := MaintenanceWindow{Start: time.Now().Add(-time.Minute), End: time.Now().Add(100 * time.Millisecond)}
+	q, err := newQuietNotifier(&flakyNotifier{fails: 1, chanNotifier: c}, []MaintenanceWindow{window}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.poll = 10 * time.Millisecond
+	nm := NewNotificationManager([]Notifier{&severityNotifier{Notifier: q, min: SeverityWarning}}, 0)
+	nm.RetryBackoff = time.Millisecond
+	nm.Storage, _ = OpenStorage("")
+
+	nm.NotifyState(&Service{Name: "api"}, StateDegraded, "slow")
+
+	// the summary is as urgent as the alerts it holds, and is retried
+	select {
+	case a := <-c:
+		if a.Type != AlertTypeDigest || a.Severity != SeverityWarning {
+			t.Errorf("unexpected summary %+v", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a summary when the quiet hours end")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	var deliveries []AlertDelivery
+	for len(deliveries) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		deliveries, _ = nm.Storage.Deliveries(context.Background())
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("expected the held alert and the summary audited got %+v", deliveries)
+	}
+	if d := deliveries[0]; d.Type != AlertTypeDegraded || d.OK || !d.Held || d.Error != "" {
+		t.Errorf("expected the alert audited as held got %+v", d)
+	}
+	if d := deliveries[1]; d.Type != AlertTypeDigest || !d.OK || d.Attempts != 2 {
+		t.Errorf("expected the summary audited as delivered on the retry got %+v", d)
+	}
+}
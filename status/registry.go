@@ -0,0 +1,76 @@
+package status
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownChecker is returned when no checker is registered
+// for the type of a service
+var ErrUnknownChecker = errors.New("commands: unknown checker type")
+
+var (
+	checkersMu sync.RWMutex
+	checkers   = make(map[string]PingerFactory)
+)
+
+func init() {
+	RegisterChecker("ping", &PingFactory{})
+	RegisterChecker("grep", &GrepFactory{})
+	RegisterChecker("docker", &DockerFactory{})
+	RegisterChecker("heartbeat", &HeartbeatFactory{})
+	RegisterChecker("s3", &S3Factory{})
+	RegisterChecker("graphql", &GraphQLFactory{})
+	RegisterChecker("checksum", &ChecksumFactory{})
+	RegisterChecker("script", &ScriptFactory{})
+	RegisterChecker("tcp", &TCPFactory{})
+	RegisterChecker("icmp", &ICMPFactory{})
+	RegisterChecker("plugin", &PluginFactory{})
+	RegisterChecker("oauth2", &OAuth2Factory{})
+	RegisterChecker("elasticsearch", &ElasticsearchFactory{})
+	RegisterChecker("domain", &DomainFactory{})
+	RegisterChecker("browser", &BrowserFactory{})
+}
+
+// RegisterChecker makes a checker available for services of the
+// given type, so programs embedding the package can add their own.
+// It panics if the type is registered twice or factory is nil.
+func RegisterChecker(typ string, factory PingerFactory) {
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+
+	if factory == nil {
+		panic("status: RegisterChecker factory is nil")
+	}
+	if _, dup := checkers[typ]; dup {
+		panic("status: RegisterChecker called twice for " + typ)
+	}
+	checkers[typ] = factory
+}
+
+// Checkers returns the sorted types of the registered checkers
+func Checkers() []string {
+	checkersMu.RLock()
+	defer checkersMu.RUnlock()
+
+	types := make([]string, 0, len(checkers))
+	for typ := range checkers {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewChecker creates the Pinger for a service with the
+// factory registered for its type
+func NewChecker(s Service) (Pinger, error) {
+	checkersMu.RLock()
+	factory, ok := checkers[s.Type]
+	checkersMu.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownChecker
+	}
+	return factory.Create(s)
+}
@@ -0,0 +1,44 @@
+package status
+
+import "testing"
+
+type staticFactory struct{}
+
+func (f *staticFactory) Create(s Service) (Pinger, error) {
+	return &fakePinger{Service: s}, nil
+}
+
+func TestRegisterChecker(t *testing.T) {
+	RegisterChecker("test-static", &staticFactory{})
+
+	p, err := NewChecker(Service{Type: "test-static", Name: "custom"})
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	if p.GetService().Name != "custom" {
+		t.Errorf("expected %v got %v", "custom", p.GetService().Name)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	RegisterChecker("ping", &PingFactory{})
+}
+
+func TestNewCheckerUnknown(t *testing.T) {
+	if _, err := NewChecker(Service{Type: "gopher"}); err != ErrUnknownChecker {
+		t.Errorf("expected %v got %v", ErrUnknownChecker, err)
+	}
+}
+
+func TestNewCheckerBuiltin(t *testing.T) {
+	p, err := NewChecker(Service{Type: "ping", URL: "http://localhost"})
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	if _, ok := p.(*Ping); !ok {
+		t.Errorf("expected *Ping got %T", p)
+	}
+}
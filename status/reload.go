@@ -0,0 +1,234 @@
+package status
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"time"
+)
+
+// FileWatcher notices when files are written. It polls the
+// modification time and size of the files, which needs no support from
+// the platform and also notices editors which replace a file, and
+// compares their contents so a file touched but not changed is not.
+type FileWatcher struct {
+	paths func() ([]string, error)
+	files map[string]fileStamp
+}
+
+// fileStamp is what a FileWatcher last saw of a file
+type fileStamp struct {
+	mod  time.Time
+	size int64
+	sum  [sha256.Size]byte
+}
+
+// NewFileWatcher returns a FileWatcher of the file at path as it is
+// now
+func NewFileWatcher(path string) (*FileWatcher, error) {
+	return newFileWatcher(func() ([]string, error) {
+		return []string{path}, nil
+	})
+}
+
+// NewConfigWatcher returns a FileWatcher of the files the config at
+// path is read from, as ReadConfig. The files are listed again on
+// every look, so a file added to an included directory is noticed.
+func NewConfigWatcher(path string) (*FileWatcher, error) {
+	return newFileWatcher(func() ([]string, error) {
+		return ConfigFiles(path)
+	})
+}
+
+func newFileWatcher(paths func() ([]string, error)) (*FileWatcher, error) {
+	w := &FileWatcher{paths: paths}
+	if _, err := w.Changed(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Changed reports whether any of the files changed, was added or was
+// removed since they were last looked at
+func (w *FileWatcher) Changed() (bool, error) {
+	paths, err := w.paths()
+	if err != nil {
+		return false, err
+	}
+	files := make(map[string]fileStamp, len(paths))
+	changed := len(paths) != len(w.files)
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		prev, ok := w.files[path]
+		if ok && fi.ModTime().Equal(prev.mod) && fi.Size() == prev.size {
+			files[path] = prev
+			continue
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		stamp := fileStamp{mod: fi.ModTime(), size: fi.Size(), sum: sha256.Sum256(b)}
+		if !ok || stamp.sum != prev.sum {
+			changed = true
+		}
+		files[path] = stamp
+	}
+	w.files = files
+	return changed, nil
+}
+
+// ServiceChanges are the IDs of the services a reload added, changed
+// the settings of and removed
+type ServiceChanges struct {
+	Added   []string
+	Changed []string
+	Removed []string
+}
+
+func (c ServiceChanges) String() string {
+	return fmt.Sprintf("%d added, %d changed, %d removed", len(c.Added), len(c.Changed), len(c.Removed))
+}
+
+// diffServices compares the services before and after a reload by ID
+func diffServices(before, after []Service) ServiceChanges {
+	var c ServiceChanges
+	old := make(map[string]Service, len(before))
+	for _, s := range before {
+		old[s.ID()] = s
+	}
+	kept := make(map[string]bool, len(after))
+	for _, s := range after {
+		id := s.ID()
+		kept[id] = true
+		prev, ok := old[id]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, id)
+		case !reflect.DeepEqual(prev, s):
+			c.Changed = append(c.Changed, id)
+		}
+	}
+	for _, s := range before {
+		if !kept[s.ID()] {
+			c.Removed = append(c.Removed, s.ID())
+		}
+	}
+	return c
+}
+
+// ReloadServices replaces the configured services with services, as
+// when the config is reloaded. Only the services added, or whose
+// settings changed, get a new checker; the others go on being checked
+// as before. Every service kept keeps its state, history and ongoing
+// incident. The services removed are no longer checked and their
+// history is flagged as removed. A managed service overrides the
+// configured one with its name, as at startup. Nothing changes when
+// one of the services is invalid.
+func (a *Admin) ReloadServices(ctx context.Context, services []Service) (ServiceChanges, error) {
+	managed, err := a.storage().ManagedServices(ctx)
+	if err != nil {
+		return ServiceChanges{}, err
+	}
+	overridden := make(map[string]bool, len(managed))
+	for _, ms := range managed {
+		overridden[ms.Service.ID()] = true
+	}
+
+	c := diffServices(a.configured(), services)
+	rebuilt := make(map[string]bool)
+	for _, ids := range [][]string{c.Added, c.Changed} {
+		for _, id := range ids {
+			rebuilt[id] = !overridden[id]
+		}
+	}
+	pingers := make(map[string]Pinger)
+	for _, s := range services {
+		id := s.ID()
+		if !rebuilt[id] {
+			continue
+		}
+		if err := validateService(s); err != nil {
+			return ServiceChanges{}, fmt.Errorf("%s: %v", id, err)
+		}
+		p, err := NewChecker(s)
+		if err != nil {
+			return ServiceChanges{}, fmt.Errorf("%s: %v", id, err)
+		}
+		pingers[id] = p
+	}
+
+	for _, id := range c.Removed {
+		if overridden[id] {
+			continue
+		}
+		a.Monitor.RemovePinger(id)
+		if a.Monitor.Notifications != nil {
+			a.Monitor.Notifications.forget(id)
+		}
+	}
+	for _, s := range services {
+		if p, ok := pingers[s.ID()]; ok {
+			a.Monitor.SetPinger(p)
+		}
+	}
+	a.mu.Lock()
+	a.Configured = services
+	a.mu.Unlock()
+	return c, a.register(ctx)
+}
+
+// Reload takes the notifiers, escalations and alert settings of from,
+// a NotificationManager built from the reloaded config, keeping the
+// state, ongoing incidents and managed notifiers of the services.
+// Alerts still queued go to the notifiers with their identity, and are
+// dropped when it is gone. The escalations of the ongoing incidents
+// are armed again with the tiers of the reloaded config.
+func (nm *NotificationManager) Reload(from *NotificationManager) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.AlertCooldown = from.AlertCooldown
+	nm.FlapThreshold, nm.FlapWindow = from.FlapThreshold, from.FlapWindow
+	nm.ReminderInterval, nm.MaxReminders = from.ReminderInterval, from.MaxReminders
+
+	nm.notifiersMu.Lock()
+	nm.Notifiers, nm.NotifierIDs, nm.Escalations = from.Notifiers, from.NotifierIDs, from.Escalations
+	nm.notifiersMu.Unlock()
+
+	if nm.Storage == nil {
+		return
+	}
+	ctx, cancel := storageContext()
+	defer cancel()
+	for id, st := range nm.states {
+		if len(st.timers) == 0 {
+			continue
+		}
+		st.stopEscalations()
+		inc, ok, err := nm.Storage.Incident(ctx, st.incident)
+		if err != nil {
+			log.Printf("load incident of %s: %v", id, err)
+		}
+		if ok && inc.Ongoing() && !inc.Acked() {
+			nm.armEscalations(st, st.service, inc)
+		}
+	}
+}
+
+// forget drops the state of a service no longer checked, cancelling
+// its pending escalations and reminders
+func (nm *NotificationManager) forget(id string) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	if st, ok := nm.states[id]; ok {
+		st.stopEscalations()
+		delete(nm.states, id)
+	}
+}
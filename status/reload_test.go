@@ -0,0 +1,179 @@
+package status
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileWatcher(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(path, []byte(`{"services": []}`), 0644)
+
+	w, err := NewFileWatcher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := w.Changed(); changed {
+		t.Error("expected the file unchanged")
+	}
+	// touched, not changed
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if changed, _ := w.Changed(); changed {
+		t.Error("expected a touched file unchanged")
+	}
+	// replaced, as editors save
+	ioutil.WriteFile(path+".tmp", []byte(`{"services": [{"name": "web"}]}`), 0644)
+	os.Rename(path+".tmp", path)
+	if changed, err := w.Changed(); !changed || err != nil {
+		t.Errorf("expected the file changed got %v %v", changed, err)
+	}
+	os.Remove(path)
+	if _, err := w.Changed(); err == nil {
+		t.Error("expected an error for a removed file")
+	}
+}
+
+func TestDiffServices(t *testing.T) {
+	before := []Service{{Name: "web", URL: "http://web"}, {Name: "api", URL: "http://api"}, {Name: "db"}}
+	after := []Service{{Name: "web", URL: "http://web"}, {Name: "api", URL: "http://api/v2"}, {Name: "cache"}}
+	c := diffServices(before, after)
+	expected := ServiceChanges{Added: []string{"cache"}, Changed: []string{"api"}, Removed: []string{"db"}}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v got %+v", expected, c)
+	}
+	if c.String() != "1 added, 1 changed, 1 removed" {
+		t.Errorf("unexpected %q", c)
+	}
+}
+
+func TestAdminReloadServices(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAdmin()
+	a.Configured = append(a.Configured, Service{Name: "db", Type: "ping", URL: "http://db.example.com"})
+	db, _ := NewChecker(a.Configured[1])
+	a.Monitor.SetPinger(db)
+	web := a.Monitor.Pingers[0]
+	a.Monitor.last["web"], a.Monitor.last["db"] = StateDown, StateDown
+	nm := a.Monitor.Notifications
+	nm.NotifyState(&a.Configured[1], StateDown, "timeout")
+	if _, err := a.SaveService(ctx, ManagedService{Service: Service{Name: "api", Type: "ping", URL: "http://api.example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// an invalid service changes nothing
+	if _, err := a.ReloadServices(ctx, []Service{{Name: "web", Type: "nope"}}); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+	if len(a.Monitor.Services()) != 3 || len(a.Configured) != 2 {
+		t.Errorf("expected the services unchanged got %+v", a.Monitor.Services())
+	}
+
+	c, err := a.ReloadServices(ctx, []Service{
+		{Name: "web", Type: "ping", URL: "http://web.example.com"},
+		{Name: "cache", Type: "ping", URL: "http://cache.example.com"},
+		{Name: "api", Type: "ping", URL: "http://other.example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Added, []string{"cache", "api"}) || !reflect.DeepEqual(c.Removed, []string{"db"}) {
+		t.Errorf("unexpected changes %+v", c)
+	}
+	byID := make(map[string]Pinger)
+	for _, p := range a.Monitor.Pingers {
+		byID[p.GetService().ID()] = p
+	}
+	if len(byID) != 3 || byID["web"] != web || byID["cache"] == nil || byID["db"] != nil {
+		t.Errorf("expected web kept, cache added and db removed got %+v", byID)
+	}
+	// the managed service overrides the configured one
+	if byID["api"].GetService().URL != "http://api.example.com" {
+		t.Errorf("expected the managed api checked got %+v", byID["api"].GetService())
+	}
+	if a.Monitor.last["web"] != StateDown {
+		t.Error("expected the state of web kept")
+	}
+	if _, ok := a.Monitor.last["db"]; ok {
+		t.Error("expected the state of db forgotten")
+	}
+	nm.mu.Lock()
+	_, ok := nm.states["db"]
+	nm.mu.Unlock()
+	if ok {
+		t.Error("expected the alert state of db forgotten")
+	}
+	infos, _ := a.storage().Services(ctx)
+	removed := make(map[string]bool)
+	for _, info := range infos {
+		removed[info.Name] = info.IsRemoved()
+	}
+	if !removed["db"] || removed["web"] || removed["cache"] {
+		t.Errorf("expected db flagged removed got %+v", infos)
+	}
+}
+
+func TestNotificationManagerReload(t *testing.T) {
+	nm := NewNotificationManager([]Notifier{&recordingNotifier{}}, time.Minute)
+	nm.Escalations = []Escalation{{After: time.Minute, Notifiers: []Notifier{&recordingNotifier{}}}}
+	nm.states["web"] = &alertState{state: StateDown}
+
+	first, second := &recordingNotifier{}, &recordingNotifier{}
+	from := NewNotificationManager([]Notifier{first, second}, time.Hour)
+	from.ReminderInterval = 10 * time.Minute
+	nm.Reload(from)
+	if nm.AlertCooldown != time.Hour || nm.ReminderInterval != 10*time.Minute || len(nm.Escalations) != 0 {
+		t.Errorf("expected the settings of the reloaded config got %+v", nm)
+	}
+	if nm.notifier(1) != second || !reflect.DeepEqual(nm.targets(1), []string{"notifier-0", "notifier-1"}) || nm.tier(0) != nil {
+		t.Errorf("expected the notifiers replaced got %v", nm.targets(1))
+	}
+	if nm.states["web"].state != StateDown {
+		t.Error("expected the state of the services kept")
+	}
+
+	nm.forget("web")
+	if _, ok := nm.states["web"]; ok {
+		t.Error("expected the state forgotten")
+	}
+}
+
+func TestNotificationManagerReloadInFlight(t *testing.T) {
+	// the notifier is stuck on a first alert while a second is queued
+	b := &blockingNotifier{release: make(chan struct{})}
+	nm := NewNotificationManager([]Notifier{b}, 0)
+	nm.NotifierIDs = []string{"webhook-b"}
+	nm.Storage, _ = OpenStorage("")
+	nm.Escalations = []Escalation{{After: time.Hour, Notifiers: []Notifier{make(chanNotifier, 10)}, NotifierIDs: []string{"webhook-slow"}}}
+	nm.Start(1, 4)
+	s := &Service{Name: "api"}
+	nm.NotifyState(s, StateDown, "timeout")
+	nm.NotifyState(s, StateUp, "")
+	nm.NotifyState(s, StateDown, "timeout")
+
+	// a notifier added before it does not take its queued alerts, and
+	// the armed escalation follows the faster tier
+	added := make(chanNotifier, 10)
+	tier := make(chanNotifier, 10)
+	from := NewNotificationManager([]Notifier{added, b}, 0)
+	from.NotifierIDs = []string{"webhook-a", "webhook-b"}
+	from.Escalations = []Escalation{{After: 20 * time.Millisecond, Notifiers: []Notifier{tier}, NotifierIDs: []string{"webhook-fast"}}}
+	nm.Reload(from)
+	close(b.release)
+
+	expectAlert(t, tier, AlertTypeDown, 1)
+	expectNoAlert(t, added, 50*time.Millisecond)
+	nm.Stop()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.alerts) != 3 {
+		t.Errorf("expected the queued alerts delivered to their notifier got %d", len(b.alerts))
+	}
+}
@@ -0,0 +1,75 @@
+package status
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// armReminders starts the reminders of an outage, or cancels them
+// when the service is up again
+func (nm *NotificationManager) armReminders(st *alertState, s Service) {
+	st.remindGen++
+	st.reminders = 0
+	if st.state == StateUp || nm.ReminderInterval <= 0 {
+		return
+	}
+
+	gen := st.remindGen
+	time.AfterFunc(nm.ReminderInterval, func() {
+		nm.remind(s, gen)
+	})
+}
+
+// remind sends a reminder for a service which is still down and
+// waits for the next one, until the outage is over or acknowledged,
+// or the reminders run out
+func (nm *NotificationManager) remind(s Service, gen int) {
+	nm.mu.Lock()
+	st := nm.states[s.ID()]
+	if st == nil || st.remindGen != gen || st.state == StateUp {
+		nm.mu.Unlock()
+		return
+	}
+	if nm.Storage != nil {
+		ctx, cancel := storageContext()
+		inc, ok, err := nm.Storage.Incident(ctx, st.incident)
+		cancel()
+		if err != nil {
+			log.Printf("load incident of %s: %v", s.ID(), err)
+		}
+		if ok && inc.Acked() {
+			nm.mu.Unlock()
+			return
+		}
+	}
+
+	// degraded services are not reminded of but may go down again
+	var a Alert
+	send := st.state == StateDown
+	if send {
+		st.reminders++
+		now := time.Now()
+		a = Alert{
+			Type:     AlertTypeDown,
+			Severity: serviceSeverity(&s),
+			Service:  s,
+			Message:  fmt.Sprintf("still down after %v: %s", now.Sub(st.downSince).Round(time.Second), st.message),
+			Time:     now,
+			Duration: now.Sub(st.downSince),
+			Reminder: st.reminders,
+			Incident: st.incident,
+			AckURL:   nm.ackURL(st.incident),
+		}
+	}
+	if nm.MaxReminders == 0 || st.reminders < nm.MaxReminders {
+		time.AfterFunc(nm.ReminderInterval, func() {
+			nm.remind(s, gen)
+		})
+	}
+	nm.mu.Unlock()
+
+	if send {
+		nm.dispatch(a, nm.targets(0))
+	}
+}
@@ -0,0 +1,39 @@
+package status
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReminders(t *testing.T) {
+	c := make(chanNotifier, 10)
+	nm := NewNotificationManager([]Notifier{c}, 0)
+	nm.ReminderInterval = 20 * time.Millisecond
+	nm.MaxReminders = 2
+	s := &Service{Name: "api"}
+
+	nm.NotifyState(s, StateDown, "timeout")
+	expectAlert(t, c, AlertTypeDown, 0)
+	for i := 1; i <= 2; i++ {
+		select {
+		case a := <-c:
+			if a.Reminder != i || a.Duration <= 0 || !strings.HasPrefix(a.Message, "still down after") || !strings.HasSuffix(a.Message, ": timeout") {
+				t.Errorf("unexpected reminder %+v", a)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected reminder %d", i)
+		}
+	}
+	expectNoAlert(t, c, 60*time.Millisecond)
+
+	// recovery cancels the reminders of the outage
+	nm.NotifyState(s, StateUp, "")
+	expectAlert(t, c, AlertTypeRecovery, 0)
+	nm.MaxReminders = 0
+	nm.NotifyState(s, StateDown, "timeout")
+	expectAlert(t, c, AlertTypeDown, 0)
+	nm.NotifyState(s, StateUp, "")
+	expectAlert(t, c, AlertTypeRecovery, 0)
+	expectNoAlert(t, c, 60*time.Millisecond)
+}
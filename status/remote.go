@@ -0,0 +1,372 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Errors returned fetching the config from a remote store
+var (
+	ErrRemoteConfigType     = errors.New("config: remote config type must be consul, etcd or http")
+	ErrRemoteConfigKey      = errors.New("config: consul and etcd remote config need a key")
+	ErrRemoteConfigRejected = errors.New("config: remote config request rejected")
+	ErrRemoteKeyNotFound    = errors.New("config: remote config key not found")
+	ErrRemoteInclude        = errors.New("config: a remote fragment cannot set include or remote_config")
+	ErrRemoteSecret         = errors.New("config: a remote fragment cannot reference secrets with ${} or a _file setting")
+)
+
+// RemoteConfigSource is a store fragments of the config, such as the
+// services, are fetched from, so many status pages share one source
+// of truth
+type RemoteConfigSource struct {
+	// Type is "consul" for the Consul KV store, "etcd" for etcd v3 or
+	// "http" for a JSON document at URL
+	Type string `json:"type"`
+	// URL is the address of the Consul agent or etcd member, such as
+	// "http://consul:8500", or of the document
+	URL string `json:"url"`
+	// Key is the key holding the fragment. A key ending in "/" is a
+	// prefix, every key under which is a fragment, in key order.
+	Key string `json:"key,omitempty"`
+	// Token is the Consul ACL token, or the bearer token of the
+	// document. Username and Password sign in to etcd, or are the
+	// basic auth of the document. Token and Password may be
+	// "env:NAME".
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Timeout bounds each request, 10s by default
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// RemoteConfig fetches the fragments of a RemoteConfigSource
+type RemoteConfig struct {
+	config RemoteConfigSource
+	client *http.Client
+}
+
+// NewRemoteConfig returns the RemoteConfig of the source
+func NewRemoteConfig(c RemoteConfigSource) (*RemoteConfig, error) {
+	switch c.Type {
+	case "consul", "etcd":
+		if c.Key == "" {
+			return nil, ErrRemoteConfigKey
+		}
+	case "http":
+	default:
+		return nil, ErrRemoteConfigType
+	}
+	if err := validateHTTPURL(c.URL); err != nil {
+		return nil, err
+	}
+	timeout := 10 * time.Second
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		timeout = d
+	}
+	return &RemoteConfig{config: c, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (r *RemoteConfig) String() string {
+	if r.config.Key == "" {
+		return r.config.URL
+	}
+	return r.config.Type + " " + r.config.URL + " " + r.config.Key
+}
+
+// Fetch returns the fragments of the config in the store
+func (r *RemoteConfig) Fetch(ctx context.Context) ([][]byte, error) {
+	switch r.config.Type {
+	case "consul":
+		return r.fetchConsul(ctx)
+	case "etcd":
+		return r.fetchEtcd(ctx)
+	}
+	req, err := http.NewRequest(http.MethodGet, r.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.config.Username != "" {
+		req.SetBasicAuth(r.config.Username, readSecret(r.config.Password))
+	} else if r.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+readSecret(r.config.Token))
+	}
+	b, err := r.do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{b}, nil
+}
+
+// consulKV is a key of the Consul KV store, its value base64 encoded
+type consulKV struct {
+	Key   string
+	Value []byte
+}
+
+// fetchConsul reads the key, or the keys under the prefix, from the
+// Consul KV HTTP API
+func (r *RemoteConfig) fetchConsul(ctx context.Context) ([][]byte, error) {
+	prefix := strings.HasSuffix(r.config.Key, "/")
+	u := strings.TrimSuffix(r.config.URL, "/") + "/v1/kv/" + strings.TrimPrefix(r.config.Key, "/")
+	if prefix {
+		u += "?recurse"
+	} else {
+		u += "?raw"
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.config.Token != "" {
+		req.Header.Set("X-Consul-Token", readSecret(r.config.Token))
+	}
+	b, err := r.do(req.WithContext(ctx))
+	var se *StatusError
+	if errors.As(err, &se) && se.Code == http.StatusNotFound {
+		if prefix {
+			// no keys under the prefix yet
+			return nil, nil
+		}
+		return nil, ErrRemoteKeyNotFound
+	}
+	if err != nil || !prefix {
+		return [][]byte{b}, err
+	}
+
+	var kvs []consulKV
+	if err := json.Unmarshal(b, &kvs); err != nil {
+		return nil, err
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	var fragments [][]byte
+	for _, kv := range kvs {
+		// the folders of the prefix have no value
+		if len(kv.Value) > 0 {
+			fragments = append(fragments, kv.Value)
+		}
+	}
+	return fragments, nil
+}
+
+// etcdRange is a range request of the etcd v3 JSON gateway, and the
+// keys it answers. The keys and values are base64 encoded.
+type etcdRange struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type etcdRangeResponse struct {
+	KVs []struct {
+		Key   []byte `json:"key"`
+		Value []byte `json:"value"`
+	} `json:"kvs"`
+}
+
+// fetchEtcd reads the key, or the keys under the prefix, from the
+// etcd v3 JSON gateway, signing in first when a username is set
+func (r *RemoteConfig) fetchEtcd(ctx context.Context) ([][]byte, error) {
+	token := ""
+	if r.config.Username != "" {
+		var auth struct {
+			Token string `json:"token"`
+		}
+		err := r.postEtcd(ctx, "/v3/auth/authenticate", "", map[string]string{
+			"name":     r.config.Username,
+			"password": readSecret(r.config.Password),
+		}, &auth)
+		if err != nil {
+			return nil, fmt.Errorf("authenticate: %w", err)
+		}
+		token = auth.Token
+	}
+
+	key := []byte(r.config.Key)
+	prefix := strings.HasSuffix(r.config.Key, "/")
+	req := etcdRange{Key: key}
+	if prefix {
+		// the end of the range of a prefix is the prefix with its
+		// last byte incremented, "/" becoming "0"
+		end := append([]byte{}, key...)
+		end[len(end)-1]++
+		req.RangeEnd = end
+	}
+	var resp etcdRangeResponse
+	if err := r.postEtcd(ctx, "/v3/kv/range", token, req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.KVs) == 0 && !prefix {
+		return nil, ErrRemoteKeyNotFound
+	}
+	sort.Slice(resp.KVs, func(i, j int) bool { return bytes.Compare(resp.KVs[i].Key, resp.KVs[j].Key) < 0 })
+	var fragments [][]byte
+	for _, kv := range resp.KVs {
+		fragments = append(fragments, kv.Value)
+	}
+	return fragments, nil
+}
+
+// postEtcd posts the request v to the etcd v3 JSON gateway and decodes
+// its response into out
+func (r *RemoteConfig) postEtcd(ctx context.Context, path, token string, v, out interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(r.config.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	b, err := r.do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// do sends the request and returns the body of its response
+func (r *RemoteConfig) do(req *http.Request) ([]byte, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{Err: ErrRemoteConfigRejected, Code: resp.StatusCode}
+	}
+	return b, nil
+}
+
+// remoteSources returns the remote config sources of the config, with
+// the references to their secrets resolved
+func remoteSources(v interface{}) ([]*RemoteConfig, error) {
+	if v == nil {
+		return nil, nil
+	}
+	resolved, err := resolveValue("remote_config", copyJSON(v), nil)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(resolved)
+	if err != nil {
+		return nil, err
+	}
+	var sources []RemoteConfigSource
+	if err := json.Unmarshal(b, &sources); err != nil {
+		return nil, &FieldError{Field: "remote_config", Err: err}
+	}
+	remotes := make([]*RemoteConfig, 0, len(sources))
+	for i, s := range sources {
+		r, err := NewRemoteConfig(s)
+		if err != nil {
+			return nil, &FieldError{Field: fmt.Sprintf("remote_config[%d]", i), Err: err}
+		}
+		remotes = append(remotes, r)
+	}
+	return remotes, nil
+}
+
+// copyJSON returns a deep copy of a decoded JSON value
+func copyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = copyJSON(e)
+		}
+		return c
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = copyJSON(e)
+		}
+		return c
+	}
+	return v
+}
+
+// parseRemoteFragment decodes a fragment of a remote store: an object
+// like a config file, or a list of services. The secrets of the config
+// are resolved once its fragments are merged, so a fragment may not
+// reference them, or whoever writes to the store could read the
+// environment and files of the status page.
+func parseRemoteFragment(b []byte) (map[string]interface{}, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := remoteSecret("", v); err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		return map[string]interface{}{"services": v}, nil
+	case map[string]interface{}:
+		if _, ok := v["include"]; ok {
+			return nil, ErrRemoteInclude
+		}
+		if _, ok := v["remote_config"]; ok {
+			return nil, ErrRemoteInclude
+		}
+		return v, nil
+	}
+	return nil, ErrNotObject
+}
+
+// remoteSecret returns the error of the first reference to a secret in
+// the setting at path of a remote fragment
+func remoteSecret(path string, v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		if strings.Contains(v, "${") {
+			return &FieldError{Field: path, Err: ErrRemoteSecret}
+		}
+	case []interface{}:
+		for i, e := range v {
+			if err := remoteSecret(fmt.Sprintf("%s[%d]", path, i), e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			field := k
+			if path != "" {
+				field = path + "." + k
+			}
+			if strings.HasSuffix(k, "_file") {
+				return &FieldError{Field: field, Err: ErrRemoteSecret}
+			}
+			if err := remoteSecret(field, v[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRemoteConfigConsul(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path + "?" + r.URL.RawQuery {
+		case "/v1/kv/status/config?raw":
+			w.Write([]byte(`{"services": [{"name": "web"}]}`))
+		case "/v1/kv/status/teams/?recurse":
+			json.NewEncoder(w).Encode([]consulKV{
+				{Key: "status/teams/web", Value: []byte(`[{"name": "web"}]`)},
+				{Key: "status/teams/"},
+				{Key: "status/teams/api", Value: []byte(`[{"name": "api"}]`)},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	os.Setenv("STATUS_TEST_CONSUL_TOKEN", "secret")
+	defer os.Unsetenv("STATUS_TEST_CONSUL_TOKEN")
+
+	for key, expected := range map[string][]string{
+		"status/config":  {`{"services": [{"name": "web"}]}`},
+		"status/teams/":  {`[{"name": "api"}]`, `[{"name": "web"}]`},
+		"status/empty/":  nil,
+		"status/missing": nil,
+	} {
+		r, err := NewRemoteConfig(RemoteConfigSource{Type: "consul", URL: server.URL, Key: key, Token: "env:STATUS_TEST_CONSUL_TOKEN"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fragments, err := r.Fetch(context.Background())
+		if key == "status/missing" {
+			if err != ErrRemoteKeyNotFound {
+				t.Errorf("expected a missing key got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", key, err)
+		}
+		var got []string
+		for _, f := range fragments {
+			got = append(got, string(f))
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %q got %q", key, expected, got)
+		}
+	}
+
+	r, _ := NewRemoteConfig(RemoteConfigSource{Type: "consul", URL: server.URL, Key: "status/config"})
+	var se *StatusError
+	if _, err := r.Fetch(context.Background()); !errors.As(err, &se) || se.Code != http.StatusForbidden {
+		t.Errorf("expected the request rejected got %v", err)
+	}
+}
+
+func TestRemoteConfigEtcd(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/auth/authenticate":
+			var auth map[string]string
+			json.NewDecoder(r.Body).Decode(&auth)
+			if auth["name"] != "status" || auth["password"] != "pw" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"token": "tok"}`))
+		case "/v3/kv/range":
+			if r.Header.Get("Authorization") != "tok" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var req etcdRange
+			json.NewDecoder(r.Body).Decode(&req)
+			if string(req.Key) != "/status/" || string(req.RangeEnd) != "/status0" {
+				w.Write([]byte(`{}`))
+				return
+			}
+			var resp etcdRangeResponse
+			json.Unmarshal([]byte(`{"kvs": [
+				{"key": "L3N0YXR1cy9i", "value": "eyJzZXJ2aWNlcyI6IFtdfQ=="},
+				{"key": "L3N0YXR1cy9h", "value": "W10="}
+			]}`), &resp)
+			json.NewEncoder(w).Encode(resp)
+		}
+	}))
+	defer server.Close()
+
+	r, err := NewRemoteConfig(RemoteConfigSource{Type: "etcd", URL: server.URL, Key: "/status/", Username: "status", Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragments, err := r.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fragments) != 2 || string(fragments[0]) != `[]` || string(fragments[1]) != `{"services": []}` {
+		t.Errorf("expected the keys under the prefix in order got %q", fragments)
+	}
+
+	r, _ = NewRemoteConfig(RemoteConfigSource{Type: "etcd", URL: server.URL, Key: "/other", Username: "status", Password: "pw"})
+	if _, err := r.Fetch(context.Background()); err != ErrRemoteKeyNotFound {
+		t.Errorf("expected a missing key got %v", err)
+	}
+	r, _ = NewRemoteConfig(RemoteConfigSource{Type: "etcd", URL: server.URL, Key: "/status/", Username: "status"})
+	if _, err := r.Fetch(context.Background()); !errors.Is(err, ErrRemoteConfigRejected) {
+		t.Errorf("expected the sign-in rejected got %v", err)
+	}
+}
+
+func TestNewRemoteConfig(t *testing.T) {
+	for c, expected := range map[*RemoteConfigSource]error{
+		{Type: "http", URL: "https://config.example.com/status.json"}: nil,
+		{Type: "consul", URL: "http://consul:8500", Key: "status"}:    nil,
+		{Type: "consul", URL: "http://consul:8500"}:                   ErrRemoteConfigKey,
+		{Type: "etcd", URL: "etcd:2379", Key: "status"}:               ErrInvalidURL,
+		{Type: "zookeeper", URL: "http://zk"}:                         ErrRemoteConfigType,
+	} {
+		if _, err := NewRemoteConfig(*c); err != expected {
+			t.Errorf("%+v: expected %v got %v", c, expected, err)
+		}
+	}
+}
+
+func TestReadConfigRemote(t *testing.T) {
+	var document string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(document))
+	}))
+	defer server.Close()
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0600)
+	path := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(path, []byte(`{
+		"services": [{"name": "local"}],
+		"remote_config": [{"type": "http", "url": "`+server.URL+`", "token_file": "`+filepath.Join(dir, "token")+`"}]
+	}`), 0644)
+
+	document = `[{"name": "web"}, {"name": "api"}]`
+	b, files, err := ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Services []Service `json:"services"`
+	}
+	json.Unmarshal(b, &config)
+	if len(config.Services) != 3 || config.Services[2].Name != "api" || len(files) != 1 {
+		t.Errorf("expected the remote services after the local one got %+v", config.Services)
+	}
+	if files, _ := ConfigFiles(path); len(files) != 1 {
+		t.Errorf("expected the config file got %v", files)
+	}
+
+	document = `{"include": "other.json"}`
+	if _, _, err := ReadConfig(path); !errors.Is(err, ErrRemoteInclude) {
+		t.Errorf("expected a remote include rejected got %v", err)
+	}
+
+	// the secrets of the status page are not the remote store's to read
+	for _, document = range []string{
+		`[{"name": "web", "url": "https://example.com/?key=${HOME}"}]`,
+		`{"notifiers": [{"type": "webhook", "url_file": "/etc/passwd"}]}`,
+	} {
+		if _, _, err := ReadConfig(path); !errors.Is(err, ErrRemoteSecret) {
+			t.Errorf("%s: expected a remote secret rejected got %v", document, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package status
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRetention is returned for a retention which is not a
+// positive duration
+var ErrInvalidRetention = errors.New("storage: retention must be a positive duration such as 720h or 30d")
+
+// defaultStatusRetention keeps the status history for the
+// longest of the UptimeWindows
+const defaultStatusRetention = 90 * 24 * time.Hour
+
+// Retention is how long the storage keeps each kind of record.
+// Zero keeps them forever.
+type Retention struct {
+	// Status is how long the status history is kept
+	Status time.Duration
+	// Alerts is how long alert deliveries are kept
+	Alerts time.Duration
+	// Incidents is how long resolved incidents are kept after
+	// they end. Ongoing incidents are always kept.
+	Incidents time.Duration
+}
+
+// RetentionConfig holds the retention settings of the config, as
+// durations which may be in days, such as "30d"
+type RetentionConfig struct {
+	Status    string `json:"status_retention,omitempty"`
+	Alerts    string `json:"alert_retention,omitempty"`
+	Incidents string `json:"incident_retention,omitempty"`
+}
+
+// NewRetention returns the Retention described by the config. The
+// status history is kept for 90 days by default.
+func NewRetention(c RetentionConfig) (Retention, error) {
+	r := Retention{Status: defaultStatusRetention}
+	for _, f := range []struct {
+		s string
+		d *time.Duration
+	}{{c.Status, &r.Status}, {c.Alerts, &r.Alerts}, {c.Incidents, &r.Incidents}} {
+		if f.s == "" {
+			continue
+		}
+		d, err := parseRetention(f.s)
+		if err != nil {
+			return Retention{}, err
+		}
+		*f.d = d
+	}
+	return r, nil
+}
+
+// parseRetention parses a duration, also accepting a number of
+// days such as "30d"
+func parseRetention(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, ErrInvalidRetention
+	}
+	return d, nil
+}
+
+// RunJanitor rolls up the status history and prunes what db keeps
+// beyond the retention every interval. It does not return.
+func RunJanitor(db StorageBackend, r Retention, interval time.Duration) {
+	for {
+		ctx, cancel := storageContext()
+		if err := Rollup(ctx, db, time.Now()); err != nil {
+			log.Printf("roll up status history: %v", err)
+		}
+		n, err := db.Prune(ctx, r, time.Now())
+		cancel()
+		if err != nil {
+			log.Printf("prune storage: %v", err)
+		} else if n > 0 {
+			log.Printf("pruned %d records from the storage", n)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,65 @@
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewRetention(t *testing.T) {
+	tt := []struct {
+		name     string
+		config   RetentionConfig
+		expected Retention
+		err      error
+	}{
+		{"defaults", RetentionConfig{}, Retention{Status: 90 * 24 * time.Hour}, nil},
+		{"days and durations", RetentionConfig{Status: "30d", Alerts: "72h", Incidents: "365d"}, Retention{Status: 30 * 24 * time.Hour, Alerts: 72 * time.Hour, Incidents: 365 * 24 * time.Hour}, nil},
+		{"invalid", RetentionConfig{Alerts: "a week"}, Retention{}, ErrInvalidRetention},
+		{"negative", RetentionConfig{Incidents: "-1d"}, Retention{}, ErrInvalidRetention},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRetention(tc.config)
+			if err != tc.err {
+				t.Fatalf("expected %v got %v", tc.err, err)
+			}
+			if r != tc.expected {
+				t.Errorf("expected %+v got %+v", tc.expected, r)
+			}
+		})
+	}
+}
+
+func TestStoragePrune(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Now()
+	old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+
+	db.RecordStatus(ctx, StatusRecord{Service: "api", Time: old}, StatusRecord{Service: "api", Time: recent})
+	db.AddDelivery(ctx, AlertDelivery{Service: "api", Time: old})
+	db.AddDelivery(ctx, AlertDelivery{Service: "api", Time: recent})
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "", old)
+	db.ResolveIncident(ctx, "api", old.Add(time.Minute))
+	db.StartIncident(ctx, "db", StateDown, SeverityCritical, "", old)
+
+	n, err := db.Prune(ctx, Retention{Status: 24 * time.Hour, Alerts: 24 * time.Hour, Incidents: 24 * time.Hour}, now)
+	if err != nil || n != 3 {
+		t.Errorf("expected 3 records pruned got %d (%v)", n, err)
+	}
+	if h, _ := db.StatusHistory(ctx, "api", old.Add(-time.Hour), now); len(h) != 1 {
+		t.Errorf("expected the recent status kept got %v", h)
+	}
+	if d, _ := db.Deliveries(ctx); len(d) != 1 {
+		t.Errorf("expected the recent delivery kept got %v", d)
+	}
+	if inc, _ := db.Incidents(ctx); len(inc) != 1 || inc[0].Service != "db" {
+		t.Errorf("expected the ongoing incident kept got %v", inc)
+	}
+
+	if n, _ := db.Prune(ctx, Retention{}, now); n != 0 {
+		t.Errorf("expected nothing pruned without retention got %d", n)
+	}
+}
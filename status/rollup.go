@@ -0,0 +1,141 @@
+package status
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Granularities of the status rollups
+const (
+	RollupHourly = time.Hour
+	RollupDaily  = 24 * time.Hour
+)
+
+// rollupHourlyRange is the longest range StatusSummary answers with
+// hourly rollups, daily ones beyond
+const rollupHourlyRange = 7 * 24 * time.Hour
+
+// StatusRollup summarises the checks of a service over a period
+// starting at Start, so long ranges can be charted without reading
+// every check
+type StatusRollup struct {
+	Service string        `json:"service"`
+	Start   time.Time     `json:"start"`
+	Period  time.Duration `json:"period"`
+	Checks  int           `json:"checks"`
+	// Down counts the checks which found the service down
+	Down int `json:"down"`
+	// AvgLatency and P95Latency are over the checks which
+	// measured a response time
+	AvgLatency time.Duration `json:"avg_latency"`
+	P95Latency time.Duration `json:"p95_latency"`
+}
+
+// Uptime returns the percentage of the checks which did not
+// find the service down
+func (r StatusRollup) Uptime() float64 {
+	if r.Checks == 0 {
+		return 100
+	}
+	return 100 * float64(r.Checks-r.Down) / float64(r.Checks)
+}
+
+// Rollup rolls the status history of every service up into hourly
+// and daily rollups, for each period which ended since the last one
+// rolled up and before now
+func Rollup(ctx context.Context, db StorageBackend, now time.Time) error {
+	for _, period := range []time.Duration{RollupHourly, RollupDaily} {
+		end := now.Truncate(period)
+		start, err := db.RolledUpUntil(ctx, period)
+		if err != nil {
+			return err
+		}
+		if !start.Before(end) {
+			continue
+		}
+		records, err := db.StatusHistory(ctx, "", start, end)
+		if err != nil {
+			return err
+		}
+		if err := db.SaveRollups(ctx, period, end, rollup(records, period)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StatusSummary returns the rollups of a service between start and
+// end, hourly for ranges up to a week and daily beyond. Periods not
+// rolled up yet are summarised from the status history.
+func StatusSummary(ctx context.Context, db StorageBackend, service string, start, end time.Time) ([]StatusRollup, error) {
+	period := RollupHourly
+	if end.Sub(start) > rollupHourlyRange {
+		period = RollupDaily
+	}
+	start = start.Truncate(period)
+
+	rollups, err := db.Rollups(ctx, service, period, start, end)
+	if err != nil {
+		return nil, err
+	}
+	from := start
+	if n := len(rollups); n > 0 {
+		from = rollups[n-1].Start.Add(period)
+	}
+	records, err := db.StatusHistory(ctx, service, from, end)
+	if err != nil {
+		return nil, err
+	}
+	return append(rollups, rollup(records, period)...), nil
+}
+
+// rollup summarises status records by service and period, ordered
+// by start then service
+func rollup(records []StatusRecord, period time.Duration) []StatusRollup {
+	type key struct {
+		service string
+		start   time.Time
+	}
+	latencies := make(map[key][]time.Duration)
+	byKey := make(map[key]*StatusRollup)
+	var keys []key
+	for _, r := range records {
+		k := key{r.Service, r.Time.Truncate(period)}
+		ru, ok := byKey[k]
+		if !ok {
+			ru = &StatusRollup{Service: r.Service, Start: k.start, Period: period}
+			byKey[k] = ru
+			keys = append(keys, k)
+		}
+		ru.Checks++
+		switch r.State {
+		case StateDown:
+			ru.Down++
+		case StateUp, StateDegraded:
+			latencies[k] = append(latencies[k], r.ResponseTime)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].start.Equal(keys[j].start) {
+			return keys[i].start.Before(keys[j].start)
+		}
+		return keys[i].service < keys[j].service
+	})
+	rollups := make([]StatusRollup, len(keys))
+	for i, k := range keys {
+		ru := byKey[k]
+		if l := latencies[k]; len(l) > 0 {
+			var total time.Duration
+			for _, d := range l {
+				total += d
+			}
+			ru.AvgLatency = total / time.Duration(len(l))
+			sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+			ru.P95Latency = l[(len(l)*95+99)/100-1]
+		}
+		rollups[i] = *ru
+	}
+	return rollups
+}
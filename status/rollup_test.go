@@ -0,0 +1,72 @@
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRollup(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// two hours of api checks, one of them down, and one of db
+	var records []StatusRecord
+	for i := 0; i < 4; i++ {
+		records = append(records, StatusRecord{Service: "api", State: StateUp, Time: day.Add(time.Duration(i) * 15 * time.Minute), ResponseTime: time.Duration(i+1) * 10 * time.Millisecond})
+	}
+	records = append(records,
+		StatusRecord{Service: "api", State: StateDown, Time: day.Add(time.Hour), ResponseTime: 10 * time.Second},
+		StatusRecord{Service: "api", State: StateUp, Time: day.Add(90 * time.Minute), ResponseTime: 20 * time.Millisecond},
+		StatusRecord{Service: "db", State: StateUp, Time: day.Add(time.Minute)},
+	)
+	db.RecordStatus(ctx, records...)
+
+	if err := Rollup(ctx, db, day.Add(26*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	hourly, _ := db.Rollups(ctx, "api", RollupHourly, day, day.Add(24*time.Hour))
+	expected := []StatusRollup{
+		{Service: "api", Start: day, Period: RollupHourly, Checks: 4, AvgLatency: 25 * time.Millisecond, P95Latency: 40 * time.Millisecond},
+		{Service: "api", Start: day.Add(time.Hour), Period: RollupHourly, Checks: 2, Down: 1, AvgLatency: 20 * time.Millisecond, P95Latency: 20 * time.Millisecond},
+	}
+	if len(hourly) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, hourly)
+	}
+	for i := range hourly {
+		if hourly[i] != expected[i] {
+			t.Errorf("expected %+v got %+v", expected[i], hourly[i])
+		}
+	}
+	daily, _ := db.Rollups(ctx, "api", RollupDaily, day, day.Add(24*time.Hour))
+	if len(daily) != 1 || daily[0].Checks != 6 || daily[0].Uptime() != 100*5/6.0 {
+		t.Errorf("expected a daily rollup of 6 checks got %+v", daily)
+	}
+
+	// rolling up again adds nothing
+	Rollup(ctx, db, day.Add(26*time.Hour))
+	if hourly, _ = db.Rollups(ctx, "api", RollupHourly, day, day.Add(24*time.Hour)); len(hourly) != 2 {
+		t.Errorf("expected 2 hourly rollups got %d", len(hourly))
+	}
+}
+
+func TestStatusSummary(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Date(2024, 3, 10, 12, 30, 0, 0, time.UTC)
+	db.RecordStatus(ctx,
+		StatusRecord{Service: "api", State: StateUp, Time: now.Add(-3 * time.Hour)},
+		StatusRecord{Service: "api", State: StateDown, Time: now.Add(-10 * time.Minute)},
+	)
+	Rollup(ctx, db, now.Add(-time.Hour))
+
+	// the rolled up hour and the recent one from the history
+	s, _ := StatusSummary(ctx, db, "api", now.Add(-24*time.Hour), now)
+	if len(s) != 2 || s[0].Period != RollupHourly || s[1].Down != 1 {
+		t.Errorf("expected 2 hourly rollups got %+v", s)
+	}
+	if s, _ := StatusSummary(ctx, db, "api", now.Add(-30*24*time.Hour), now); len(s) != 1 || s[0].Period != RollupDaily || s[0].Checks != 2 {
+		t.Errorf("expected a daily rollup got %+v", s)
+	}
+}
@@ -0,0 +1,288 @@
+package status
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Errors returned by the s3 check
+var (
+	ErrS3AccessDenied = errors.New("commands: s3 access denied")
+	ErrS3NotFound     = errors.New("commands: s3 bucket or object not found")
+	ErrS3Credentials  = errors.New("commands: s3 credentials not found")
+)
+
+const (
+	// emptyPayloadHash is the SHA-256 of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// imdsURL is the EC2 instance metadata service used to look
+	// up IAM role credentials
+	imdsURL = "http://169.254.169.254/latest"
+)
+
+// S3 checks a bucket, or an object within it, is reachable
+// with the configured credentials
+type S3 struct {
+	Service
+	timingRecorder
+	client *http.Client
+}
+
+// GetService return the Service pointer
+func (s *S3) GetService() *Service {
+	return &s.Service
+}
+
+// Status sends a signed HEAD request for the bucket or object and
+// distinguishes authorisation failures from availability failures
+func (s *S3) Status() error {
+	creds, err := awsCredentials()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodHead, s.objectURL(), nil)
+	if err != nil {
+		return err
+	}
+	signV4(req, creds, s.region(), "s3", time.Now())
+
+	resp, err := clientOrDefault(s.client).Do(s.trace(req))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrS3AccessDenied
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrS3NotFound
+	case !validStatus(resp.StatusCode):
+		return ErrServiceUnavailable
+	}
+
+	return nil
+}
+
+// region returns the configured region, falling back to
+// the standard AWS environment variables
+func (s *S3) region() string {
+	if s.Region != "" {
+		return s.Region
+	}
+	if r := os.Getenv("AWS_REGION"); r != "" {
+		return r
+	}
+	if r := os.Getenv("AWS_DEFAULT_REGION"); r != "" {
+		return r
+	}
+	return "us-east-1"
+}
+
+// objectURL returns the URL to HEAD. AWS uses virtual-hosted style
+// addressing while custom endpoints (e.g. MinIO) use path style.
+func (s *S3) objectURL() string {
+	key := strings.TrimPrefix(s.Key, "/")
+	if s.URL == "" {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.region(), key)
+	}
+
+	u := strings.TrimSuffix(s.URL, "/") + "/" + s.Bucket
+	if key != "" {
+		u += "/" + key
+	}
+	return u
+}
+
+// S3Factory implements the PingerFactory
+// interface
+type S3Factory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *S3Factory) Create(s Service) (Pinger, error) {
+	if s.Type != "s3" {
+		return nil, ErrInvalidCreate
+	}
+	client, err := newHTTPClient(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3{
+		Service: s,
+		client:  client,
+	}, nil
+}
+
+// awsCreds holds a set of AWS credentials
+type awsCreds struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"Token"`
+}
+
+// awsCredentials reads credentials from the environment, falling back to
+// the IAM role of the instance the service is running on
+func awsCredentials() (awsCreds, error) {
+	creds := awsCreds{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds, nil
+	}
+
+	return instanceCredentials()
+}
+
+// instanceCredentials fetches role credentials from the instance
+// metadata service using IMDSv2
+func instanceCredentials() (awsCreds, error) {
+	var creds awsCreds
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	req, _ := http.NewRequest(http.MethodPut, imdsURL+"/api/token", nil)
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	resp, err := client.Do(req)
+	if err != nil {
+		return creds, ErrS3Credentials
+	}
+	token, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || !validStatus(resp.StatusCode) {
+		return creds, ErrS3Credentials
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, _ := http.NewRequest(http.MethodGet, imdsURL+path, nil)
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if !validStatus(resp.StatusCode) {
+			return nil, ErrS3Credentials
+		}
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	role, err := get("/meta-data/iam/security-credentials/")
+	if err != nil {
+		return creds, ErrS3Credentials
+	}
+	body, err := get("/meta-data/iam/security-credentials/" + strings.TrimSpace(string(role)))
+	if err != nil {
+		return creds, ErrS3Credentials
+	}
+	if err := json.Unmarshal(body, &creds); err != nil || creds.AccessKeyID == "" {
+		return creds, ErrS3Credentials
+	}
+
+	return creds, nil
+}
+
+// signV4 adds AWS Signature Version 4 headers to a request with an
+// empty body. All headers already present on the request are signed.
+func signV4(req *http.Request, creds awsCreds, region, service string, now time.Time) {
+	signV4Payload(req, creds, region, service, emptyPayloadHash, now)
+}
+
+// signV4Payload signs a request whose body has the hex SHA-256
+// payloadHash
+func signV4Payload(req *http.Request, creds awsCreds, region, service, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders string
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by key as
+// required by the canonical request
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := q[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes s using the RFC 3986 rules AWS expects
+func awsEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
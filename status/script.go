@@ -0,0 +1,156 @@
+package status
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// Errors returned by the script check
+var (
+	ErrMissingCommand = errors.New("commands: script command missing")
+	ErrInvalidTimeout = errors.New("commands: invalid script timeout")
+	ErrScriptTimeout  = errors.New("commands: script timed out")
+)
+
+// defaultScriptTimeout is used when a script service does
+// not configure a timeout
+const defaultScriptTimeout = 30 * time.Second
+
+// maxScriptOutput is the number of bytes of output kept
+// from a script run
+const maxScriptOutput = 4096
+
+// ScriptError is returned when a script exits non-zero or
+// times out. Output holds its combined stdout and stderr.
+type ScriptError struct {
+	Err    error
+	Output string
+}
+
+func (e *ScriptError) Error() string {
+	if e.Output == "" {
+		return "commands: script failed: " + e.Err.Error()
+	}
+	return "commands: script failed: " + e.Err.Error() + "\n" + e.Output
+}
+
+// Script runs a command and reports the service down when
+// it exits non-zero
+type Script struct {
+	Service
+	timeout time.Duration
+}
+
+// GetService return the Service pointer
+func (sc *Script) GetService() *Service {
+	return &sc.Service
+}
+
+// Status runs the command and reports its output when it fails
+func (sc *Script) Status() error {
+	cmd := exec.Command(sc.Command, sc.Args...)
+	cmd.Env = append(os.Environ(), envList(sc.Env)...)
+	out := &limitedBuffer{max: maxScriptOutput}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	if err := runWithTimeout(cmd, sc.timeout); err != nil {
+		return &ScriptError{Err: err, Output: out.String()}
+	}
+	return nil
+}
+
+// runWithTimeout runs cmd and returns ErrScriptTimeout when it does
+// not exit in time. The whole process group is then killed so children
+// of the command don't linger.
+func runWithTimeout(cmd *exec.Cmd, timeout time.Duration) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		killProcessGroup(cmd)
+		<-done
+		return ErrScriptTimeout
+	}
+}
+
+// envList converts env to sorted KEY=value pairs
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// limitedBuffer keeps the first max bytes written to it and
+// discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.max - b.buf.Len(); len(p) > room {
+		p = p[:room]
+		b.truncated = true
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+func (b *limitedBuffer) String() string {
+	s := string(bytes.TrimSpace(b.buf.Bytes()))
+	if b.truncated {
+		s += "\n... (truncated)"
+	}
+	return s
+}
+
+// ScriptFactory implements the PingerFactory
+// interface
+type ScriptFactory struct{}
+
+// Create returns a pointer to a Pinger
+func (factory *ScriptFactory) Create(s Service) (Pinger, error) {
+	if s.Type != "script" {
+		return nil, ErrInvalidCreate
+	}
+	if s.Command == "" {
+		return nil, ErrMissingCommand
+	}
+
+	timeout := defaultScriptTimeout
+	if s.Timeout != "" {
+		d, err := time.ParseDuration(s.Timeout)
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidTimeout
+		}
+		timeout = d
+	}
+
+	return &Script{
+		Service: s,
+		timeout: timeout,
+	}, nil
+}
@@ -0,0 +1,103 @@
+package status
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScriptStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	tt := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		success bool
+		output  string
+	}{
+		{name: "success", args: []string{"-c", "exit 0"}, success: true},
+		{name: "failure", args: []string{"-c", "echo replication lag; exit 2"}, output: "replication lag"},
+		{name: "stderr", args: []string{"-c", "echo broken >&2; exit 1"}, output: "broken"},
+		{name: "args", args: []string{"-c", `test "$1" = "a b"`, "sh", "a b"}, success: true},
+		{name: "env", args: []string{"-c", `test "$STATUS_TEST" = ok`}, env: map[string]string{"STATUS_TEST": "ok"}, success: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := Script{Service: Service{Command: "sh", Args: tc.args, Env: tc.env}, timeout: time.Second}
+			err := sc.Status()
+			if tc.success {
+				if err != nil {
+					t.Errorf("expected nil got %v", err)
+				}
+				return
+			}
+			se, ok := err.(*ScriptError)
+			if !ok {
+				t.Fatalf("expected *ScriptError got %v", err)
+			}
+			if se.Output != tc.output {
+				t.Errorf("expected %q got %q", tc.output, se.Output)
+			}
+		})
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	// the background sleep holds the output pipe open, the check
+	// only returns once the whole process group is killed
+	sc := Script{Service: Service{Command: "sh", Args: []string{"-c", "echo started; sleep 10 & sleep 10"}}, timeout: 100 * time.Millisecond}
+
+	start := time.Now()
+	err := sc.Status()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the script to be killed, took %v", elapsed)
+	}
+	se, ok := err.(*ScriptError)
+	if !ok || se.Err != ErrScriptTimeout {
+		t.Fatalf("expected %v got %v", ErrScriptTimeout, err)
+	}
+	if se.Output != "started" {
+		t.Errorf("expected %q got %q", "started", se.Output)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{max: 4}
+	b.Write([]byte("abc"))
+	b.Write([]byte("def"))
+	if actual := b.String(); !strings.HasPrefix(actual, "abcd\n") || !b.truncated {
+		t.Errorf("expected truncated output got %q", actual)
+	}
+}
+
+func TestScriptFactoryCreate(t *testing.T) {
+	f := ScriptFactory{}
+
+	tt := []struct {
+		name     string
+		service  Service
+		expected error
+	}{
+		{name: "wrong type", service: Service{Type: "ping"}, expected: ErrInvalidCreate},
+		{name: "no command", service: Service{Type: "script"}, expected: ErrMissingCommand},
+		{name: "bad timeout", service: Service{Type: "script", Command: "true", Timeout: "soon"}, expected: ErrInvalidTimeout},
+		{name: "valid", service: Service{Type: "script", Command: "true", Timeout: "5s"}, expected: nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := f.Create(tc.service); err != tc.expected {
+				t.Errorf("expected %v got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package status
+
+import "os/exec"
+
+// setProcessGroup is a no-op on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd. Process groups are not supported
+// on Windows so children of the script are left running.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
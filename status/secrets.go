@@ -0,0 +1,156 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Errors returned resolving the secrets of the config
+var (
+	ErrUnsetVariable    = errors.New("config: environment variable not set")
+	ErrUnclosedVariable = errors.New("config: ${ without a closing }")
+	ErrSecretConflict   = errors.New("config: both the setting and its _file are set")
+	ErrSecretFileName   = errors.New("config: _file setting must be the path of a file")
+)
+
+// ResolveSecrets resolves the references to secrets in data, a JSON
+// config, so they need not be written in plaintext in it. "${NAME}" in
+// any string is replaced by the environment variable NAME, which must
+// be set, and "$${" is a literal "${". A setting "X_file" is replaced by
+// the setting "X" holding the contents of the file it names, without
+// the trailing newline, as Docker secrets are mounted. The settings of
+// v, the type the config is decoded into, whose names end in _file,
+// such as "cert_file", are paths of their own and left alone.
+func ResolveSecrets(data []byte, v interface{}) ([]byte, error) {
+	var config interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&config); err != nil {
+		return nil, err
+	}
+	settings := make(map[string]bool)
+	fileSettings(reflect.TypeOf(v), settings, make(map[reflect.Type]bool))
+	resolved, err := resolveValue("", config, settings)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resolved)
+}
+
+// resolveValue resolves the secrets of the setting at path
+func resolveValue(path string, v interface{}, settings map[string]bool) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		s, err := expandEnv(v)
+		if err != nil {
+			return nil, &FieldError{Field: path, Err: err}
+		}
+		return s, nil
+	case []interface{}:
+		for i, e := range v {
+			r, err := resolveValue(fmt.Sprintf("%s[%d]", path, i), e, settings)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = r
+		}
+		return v, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make(map[string]interface{}, len(v))
+		for _, k := range keys {
+			field := k
+			if path != "" {
+				field = path + "." + k
+			}
+			name := strings.TrimSuffix(k, "_file")
+			if name == k || name == "" || settings[k] {
+				r, err := resolveValue(field, v[k], settings)
+				if err != nil {
+					return nil, err
+				}
+				out[k] = r
+				continue
+			}
+			if _, ok := v[name]; ok {
+				return nil, &FieldError{Field: field, Err: ErrSecretConflict}
+			}
+			file, ok := v[k].(string)
+			if !ok || file == "" {
+				return nil, &FieldError{Field: field, Err: ErrSecretFileName}
+			}
+			file, err := expandEnv(file)
+			if err != nil {
+				return nil, &FieldError{Field: field, Err: err}
+			}
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, &FieldError{Field: field, Err: err}
+			}
+			out[name] = strings.TrimRight(string(b), "\r\n")
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// expandEnv replaces the ${NAME} in s by the environment variables
+func expandEnv(s string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i+2:], '}')
+		if end < 0 {
+			return "", ErrUnclosedVariable
+		}
+		name := s[i+2 : i+2+end]
+		value, ok := os.LookupEnv(name)
+		if !ok || name == "" {
+			return "", fmt.Errorf("%w: %s", ErrUnsetVariable, name)
+		}
+		b.WriteString(s[:i] + value)
+		s = s[i+3+end:]
+	}
+}
+
+// fileSettings adds the JSON names ending in _file of the fields of t,
+// and of the types it holds, to names
+func fileSettings(t reflect.Type, names map[string]bool, seen map[reflect.Type]bool) {
+	if t == nil || seen[t] {
+		return
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		fileSettings(t.Elem(), names, seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if strings.HasSuffix(name, "_file") {
+				names[name] = true
+			}
+			fileSettings(f.Type, names, seen)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets")
+	defer os.RemoveAll(dir)
+	secret := filepath.Join(dir, "webhook")
+	ioutil.WriteFile(secret, []byte("https://hooks.example.com/T0/B0/x\n"), 0600)
+	os.Setenv("STATUS_TEST_HOST", "web.example.com")
+	defer os.Unsetenv("STATUS_TEST_HOST")
+
+	var config struct {
+		Services  []Service        `json:"services"`
+		Notifiers []NotifierConfig `json:"notifiers"`
+		TLS       *TLSConfig       `json:"tls"`
+	}
+	b, err := ResolveSecrets([]byte(`{
+		"services": [{"name": "web", "url": "https://${STATUS_TEST_HOST}/$${PATH}", "port": 8080}],
+		"notifiers": [{"type": "webhook", "url_file": "`+secret+`"}],
+		"tls": {"cert_file": "cert.pem"}
+	}`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(b, &got)
+	expected := map[string]interface{}{
+		"services":  []interface{}{map[string]interface{}{"name": "web", "url": "https://web.example.com/${PATH}", "port": 8080.0}},
+		"notifiers": []interface{}{map[string]interface{}{"type": "webhook", "url": "https://hooks.example.com/T0/B0/x"}},
+		"tls":       map[string]interface{}{"cert_file": "cert.pem"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+
+	for data, expected := range map[string]string{
+		`{"services": [{"url": "${STATUS_TEST_UNSET}"}]}`:             "services[0].url: " + ErrUnsetVariable.Error() + ": STATUS_TEST_UNSET",
+		`{"services": [{"url": "${STATUS_TEST_HOST"}]}`:               "services[0].url: " + ErrUnclosedVariable.Error(),
+		`{"notifiers": [{"url": "x", "url_file": "` + secret + `"}]}`: "notifiers[0].url_file: " + ErrSecretConflict.Error(),
+		`{"notifiers": [{"url_file": 1}]}`:                            "notifiers[0].url_file: " + ErrSecretFileName.Error(),
+	} {
+		_, err := ResolveSecrets([]byte(data), &config)
+		if err == nil || err.Error() != expected {
+			t.Errorf("%s: expected %q got %v", data, expected, err)
+		}
+	}
+	_, err = ResolveSecrets([]byte(`{"notifiers": [{"url_file": "`+filepath.Join(dir, "missing")+`"}]}`), &config)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing file reported got %v", err)
+	}
+}
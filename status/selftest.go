@@ -0,0 +1,76 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// NotifierTest is the outcome of sending a test alert to a notifier
+type NotifierTest struct {
+	// Notifier is the index of the notifier in the
+	// NotificationManager, counting on into the notifiers
+	// of its escalations
+	Notifier int    `json:"notifier"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+// TestNotifiers sends a test alert to every notifier, including those
+// of the escalations and the enabled managed notifiers, once and
+// without retries, and reports which delivered it
+func (nm *NotificationManager) TestNotifiers() []NotifierTest {
+	a := Alert{
+		Type:     AlertTypeTest,
+		Severity: SeverityInfo,
+		Service:  Service{Name: "service_status"},
+		Message:  "test notification, alerts will be delivered here",
+		Time:     time.Now(),
+	}
+
+	nm.notifiersMu.RLock()
+	tiers := len(nm.Escalations)
+	nm.notifiersMu.RUnlock()
+	var tests []NotifierTest
+	for _, id := range nm.targets(tiers) {
+		n, i := nm.lookup(id)
+		if n == nil {
+			continue
+		}
+		t := NotifierTest{Notifier: i, OK: true}
+		if err := n.Notify(a); err != nil {
+			t.OK, t.Error = false, err.Error()
+		}
+		tests = append(tests, t)
+	}
+	return tests
+}
+
+// NotifierTestHandler is a HandlerFunc which sends a test alert to
+// every notifier (POST /api/notifiers/test). It responds with the
+// results, with a 502 status when a notifier failed. Requests present
+// token, or an API key with the admin scope, as a bearer token.
+func NotifierTestHandler(nm *NotificationManager, token string) http.HandlerFunc {
+	token = readSecret(token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !granted(r, token, ScopeAdmin) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		tests := nm.TestNotifiers()
+		w.Header().Set("Content-Type", "application/json")
+		for _, t := range tests {
+			if !t.OK {
+				w.WriteHeader(http.StatusBadGateway)
+				break
+			}
+		}
+		json.NewEncoder(w).Encode(tests)
+	}
+}
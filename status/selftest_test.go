@@ -0,0 +1,76 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTestNotifiers(t *testing.T) {
+	rec := &recordingNotifier{}
+	f := &failingNotifier{fails: 1}
+	quiet := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec, f}, 0)
+	nm.Escalations = []Escalation{{After: time.Hour, Notifiers: []Notifier{&severityNotifier{Notifier: quiet, min: SeverityCritical}}}}
+
+	tests := nm.TestNotifiers()
+	expected := []NotifierTest{{Notifier: 0, OK: true}, {Notifier: 1, Error: "unavailable"}, {Notifier: 2, OK: true}}
+	if len(tests) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, tests)
+	}
+	for i, tc := range tests {
+		if tc != expected[i] {
+			t.Errorf("expected %+v got %+v", expected[i], tc)
+		}
+	}
+	if f.attempts != 1 {
+		t.Errorf("expected a single attempt got %d", f.attempts)
+	}
+	if len(quiet.alerts) != 1 || quiet.alerts[0].Type != AlertTypeTest {
+		t.Errorf("expected the test to pass min_severity got %v", quiet.alerts)
+	}
+}
+
+func TestNotifierTestHandler(t *testing.T) {
+	tt := []struct {
+		name      string
+		method    string
+		token     string
+		notifiers []Notifier
+		code      int
+	}{
+		{"ok", http.MethodPost, "secret", []Notifier{&recordingNotifier{}}, http.StatusOK},
+		{"failed", http.MethodPost, "secret", []Notifier{&failingNotifier{fails: 1}}, http.StatusBadGateway},
+		{"get", http.MethodGet, "secret", nil, http.StatusMethodNotAllowed},
+		{"unauthorized", http.MethodPost, "", []Notifier{&recordingNotifier{}}, http.StatusUnauthorized},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			nm := NewNotificationManager(tc.notifiers, 0)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, "/api/notifiers/test", nil)
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			NotifierTestHandler(nm, "secret")(w, r)
+			if w.Code != tc.code {
+				t.Fatalf("expected %d got %d", tc.code, w.Code)
+			}
+			if tc.code == http.StatusUnauthorized {
+				if rec := tc.notifiers[0].(*recordingNotifier); len(rec.alerts) > 0 {
+					t.Errorf("expected no test alert sent got %v", rec.alerts)
+				}
+			}
+			if tc.code != http.StatusOK && tc.code != http.StatusBadGateway {
+				return
+			}
+			var tests []NotifierTest
+			if err := json.NewDecoder(w.Body).Decode(&tests); err != nil || len(tests) != len(tc.notifiers) {
+				t.Errorf("expected %d results got %v (%v)", len(tc.notifiers), tests, err)
+			}
+		})
+	}
+}
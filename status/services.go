@@ -0,0 +1,251 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ServiceInfo is the metadata of a configured service kept in the
+// storage, so the history of a renamed service follows it and a
+// service removed from the config is flagged rather than forgotten
+type ServiceInfo struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	URL         string    `json:"url,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	RenamedFrom []string  `json:"renamed_from,omitempty"`
+	Created     time.Time `json:"created"`
+	// Removed is when the service left the config, zero while it
+	// is configured
+	Removed time.Time `json:"removed"`
+}
+
+// IsRemoved reports whether the service has left the config
+func (s ServiceInfo) IsRemoved() bool {
+	return !s.Removed.IsZero()
+}
+
+// RegisterServices records the configured services in db at now
+func RegisterServices(ctx context.Context, db StorageBackend, services []Service, now time.Time) error {
+	infos := make([]ServiceInfo, len(services))
+	for i, s := range services {
+		infos[i] = ServiceInfo{
+			Name:        s.ID(),
+			Type:        s.Type,
+			URL:         s.URL,
+			Group:       s.Group,
+			Tags:        s.Tags,
+			RenamedFrom: s.RenamedFrom,
+		}
+	}
+	return db.SyncServices(ctx, infos, now)
+}
+
+// recentWindow is how far back the latency and checks of a service
+// are reported, and recentChecks how many of its checks at most
+const (
+	recentWindow = 24 * time.Hour
+	recentChecks = 50
+)
+
+// ServiceStatus is a service in the storage along with its current
+// state on the page. Removed services have no state.
+type ServiceStatus struct {
+	ServiceInfo
+	State   State  `json:"state,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Since is when the service entered its state
+	Since    *time.Time `json:"since,omitempty"`
+	Incident *Incident  `json:"incident,omitempty"`
+	// Checked is when the service was last checked, Latency its
+	// response times and History its checks over the last day,
+	// newest first. They are only set on a single service.
+	Checked *time.Time      `json:"checked,omitempty"`
+	Latency *ServiceLatency `json:"latency,omitempty"`
+	History []StatusRecord  `json:"history,omitempty"`
+}
+
+// ServiceLatency sums up the response times of a service, in
+// milliseconds
+type ServiceLatency struct {
+	Checks int     `json:"checks"`
+	Last   float64 `json:"last_ms"`
+	Min    float64 `json:"min_ms"`
+	Avg    float64 `json:"avg_ms"`
+	P50    float64 `json:"p50_ms"`
+	P95    float64 `json:"p95_ms"`
+	P99    float64 `json:"p99_ms"`
+	Max    float64 `json:"max_ms"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// newServiceLatency sums up the response times of checks, oldest
+// first, nil when none measured one
+func newServiceLatency(checks []StatusRecord) *ServiceLatency {
+	var times []time.Duration
+	var sum time.Duration
+	for _, c := range checks {
+		if c.State == StateUp || c.State == StateDegraded {
+			times = append(times, c.ResponseTime)
+			sum += c.ResponseTime
+		}
+	}
+	if len(times) == 0 {
+		return nil
+	}
+	last := times[len(times)-1]
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	p := nearestRanks(times, 50, 95, 99)
+	return &ServiceLatency{
+		Checks: len(times),
+		Last:   milliseconds(last),
+		Min:    milliseconds(times[0]),
+		Avg:    milliseconds(sum / time.Duration(len(times))),
+		P50:    milliseconds(p[0]),
+		P95:    milliseconds(p[1]),
+		P99:    milliseconds(p[2]),
+		Max:    milliseconds(times[len(times)-1]),
+	}
+}
+
+// parseStates parses a comma separated list of states, such as
+// "down,degraded"
+func parseStates(s string) (map[State]bool, error) {
+	states := make(map[State]bool)
+	for _, name := range strings.Split(s, ",") {
+		state := State(strings.TrimSpace(name))
+		switch state {
+		case StateUp, StateDown, StateDegraded, StateAffected, StateMaintenance:
+			states[state] = true
+		default:
+			return nil, errors.New("invalid status " + string(state))
+		}
+	}
+	return states, nil
+}
+
+// serviceStatuses returns the services in the storage, sorted by
+// name, with their state on p
+func serviceStatuses(ctx context.Context, db StorageBackend, p Page) ([]ServiceStatus, error) {
+	services, err := db.Services(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]Result, len(p.results))
+	for _, res := range p.results {
+		results[res.Service.ID()] = res
+	}
+	list := make([]ServiceStatus, len(services))
+	for i, s := range services {
+		ss := ServiceStatus{ServiceInfo: s}
+		if res, ok := results[s.Name]; ok && !s.IsRemoved() {
+			ss.State, ss.Message, ss.Incident = res.State, res.Message, res.Incident
+			if ss.Message == "" && res.Err != nil {
+				ss.Message = res.Err.Error()
+			}
+			if !res.Since.IsZero() {
+				since := res.Since
+				ss.Since = &since
+			}
+		}
+		list[i] = ss
+	}
+	return list, nil
+}
+
+// addRecent sets when the service was last checked, its latency and
+// its checks over the day before now
+func (ss *ServiceStatus) addRecent(ctx context.Context, db StorageBackend, now time.Time) error {
+	var err error
+	ss.History, _, err = db.StatusHistoryPage(ctx, ss.Name, now.Add(-recentWindow), now, 0, recentChecks)
+	if err != nil {
+		return err
+	}
+	if len(ss.History) > 0 {
+		checked := ss.History[0].Time
+		ss.Checked = &checked
+	}
+	checks, err := db.StatusHistory(ctx, ss.Name, now.Add(-recentWindow), now)
+	if err != nil {
+		return err
+	}
+	ss.Latency = newServiceLatency(checks)
+	return nil
+}
+
+// ServicesHandler is a HandlerFunc which lists the services in the
+// storage with their state on the page (GET /api/services), including
+// those removed from the config, and filters them by state
+// (?status=down,degraded). It shows one along with its latency and
+// checks over the last day (GET /api/services/{name}) or lists its
+// status history like HistoryHandler (GET /api/services/{name}/history).
+func ServicesHandler(db StorageBackend, page *PageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/services"), "/")
+		// names may be URLs, so the history is matched at the end
+		history := strings.HasSuffix(name, "/history")
+		name = strings.TrimSuffix(name, "/history")
+		var states map[State]bool
+		if s := r.URL.Query().Get("status"); s != "" && name == "" {
+			var err error
+			if states, err = parseStates(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := requestContext(r)
+		defer cancel()
+		var p Page
+		if page != nil {
+			p = page.PageFor(r)
+		}
+		services, err := serviceStatuses(ctx, db, p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if name == "" {
+			list := []ServiceStatus{}
+			for _, ss := range services {
+				if states == nil || states[ss.State] {
+					list = append(list, ss)
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+		for _, ss := range services {
+			if ss.Name != name {
+				continue
+			}
+			if history {
+				writeHistory(w, r, db, name)
+				return
+			}
+			if err := ss.addRecent(ctx, db, time.Now()); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(ss)
+			return
+		}
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,114 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRegisterServices(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	start := time.Now().Add(-time.Hour)
+	RegisterServices(ctx, db, []Service{{Name: "api", Type: "ping", URL: "http://api"}, {Name: "db", Type: "tcp"}}, start)
+	db.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: start})
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", start)
+
+	// api is renamed and db removed
+	now := time.Now()
+	services := []Service{{Name: "public-api", Type: "ping", URL: "http://api", Group: "edge", Tags: []string{"prod"}, RenamedFrom: []string{"api"}}}
+	if err := RegisterServices(ctx, db, services, now); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	ServicesHandler(db, nil)(w, httptest.NewRequest(http.MethodGet, "/api/services", nil))
+	var infos []ServiceInfo
+	json.NewDecoder(w.Body).Decode(&infos)
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 services got %+v", infos)
+	}
+	if s := infos[1]; s.Name != "public-api" || s.Group != "edge" || !s.Created.Equal(start) || s.IsRemoved() {
+		t.Errorf("expected the renamed service got %+v", s)
+	}
+	if s := infos[0]; s.Name != "db" || !s.Removed.Equal(now) {
+		t.Errorf("expected db removed got %+v", s)
+	}
+
+	if history, _ := db.StatusHistory(ctx, "public-api", start, now); len(history) != 1 {
+		t.Errorf("expected the history renamed got %+v", history)
+	}
+	if _, ok, _ := db.OngoingIncident(ctx, "public-api"); !ok {
+		t.Error("expected the incident renamed")
+	}
+
+	w = httptest.NewRecorder()
+	ServicesHandler(db, nil)(w, httptest.NewRequest(http.MethodGet, "/api/services/public-api", nil))
+	var info ServiceInfo
+	json.NewDecoder(w.Body).Decode(&info)
+	if info.Name != "public-api" {
+		t.Errorf("expected public-api got %+v", info)
+	}
+	w = httptest.NewRecorder()
+	ServicesHandler(db, nil)(w, httptest.NewRequest(http.MethodGet, "/api/services/public-api/history", nil))
+	var list HistoryList
+	json.NewDecoder(w.Body).Decode(&list)
+	if list.Total != 1 || list.History[0].Service != "public-api" {
+		t.Errorf("expected the history of public-api got %+v", list)
+	}
+	w = httptest.NewRecorder()
+	ServicesHandler(db, nil)(w, httptest.NewRequest(http.MethodGet, "/api/services/web/history", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %d got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestServicesHandlerStatus(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Now()
+	RegisterServices(ctx, db, []Service{{Name: "api"}, {Name: "web"}, {Name: "db"}}, now.Add(-time.Hour))
+	for i, ms := range []time.Duration{30, 10, 20} {
+		db.RecordStatus(ctx, StatusRecord{Service: "web", State: StateUp, Time: now.Add(time.Duration(i-3) * time.Minute), ResponseTime: ms * time.Millisecond})
+	}
+	db.RecordStatus(ctx, StatusRecord{Service: "web", State: StateDown, Time: now.Add(-30 * time.Second)})
+	since := now.Add(-time.Minute)
+	inc := Incident{ID: "1", Service: "api", State: StateDown, Start: since}
+	page := NewPageStore(NewPage("Status", []Result{
+		{Service: &Service{Name: "api"}, State: StateDown, Err: errors.New("timeout"), Since: since, Incident: &inc},
+		{Service: &Service{Name: "web"}, State: StateUp},
+		{Service: &Service{Name: "db"}, State: StateDegraded, Message: "slow"},
+	}))
+	handler := ServicesHandler(db, page)
+	get := func(path string, v interface{}) int {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, path, nil))
+		json.NewDecoder(w.Body).Decode(v)
+		return w.Code
+	}
+
+	var list []ServiceStatus
+	get("/api/services?status=down,degraded", &list)
+	if len(list) != 2 || list[0].Name != "api" || list[1].Name != "db" {
+		t.Fatalf("expected the down and degraded services got %+v", list)
+	}
+	if s := list[0]; s.State != StateDown || s.Message != "timeout" || s.Since == nil || !s.Since.Equal(since) || s.Incident == nil || s.Incident.ID != "1" {
+		t.Errorf("expected the state of api got %+v", s)
+	}
+	if code := get("/api/services?status=sideways", &list); code != http.StatusBadRequest {
+		t.Errorf("expected an invalid status to be rejected got %d", code)
+	}
+
+	var web ServiceStatus
+	get("/api/services/web", &web)
+	if web.State != StateUp || len(web.History) != 4 || web.Checked == nil || !web.Checked.Equal(now.Add(-30*time.Second)) {
+		t.Fatalf("expected the state and checks of web got %+v", web)
+	}
+	if l := web.Latency; l == nil || l.Checks != 3 || l.Last != 20 || l.Min != 10 || l.Avg != 20 || l.P50 != 20 || l.Max != 30 {
+		t.Errorf("expected the latency of web got %+v", l)
+	}
+}
@@ -0,0 +1,82 @@
+package status
+
+import "errors"
+
+// ErrInvalidSeverity is returned for a severity which is not
+// critical, warning or info
+var ErrInvalidSeverity = errors.New("notify: severity must be critical, warning or info")
+
+// Severity is how urgent an alert is
+type Severity string
+
+// Alert severities, most urgent first
+const (
+	SeverityCritical Severity = "critical"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+// Validate checks the severity is a known one or empty
+func (s Severity) Validate() error {
+	switch s {
+	case "", SeverityCritical, SeverityWarning, SeverityInfo:
+		return nil
+	}
+	return ErrInvalidSeverity
+}
+
+// rank orders severities, info lowest
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// AtLeast reports whether s is as urgent as min
+func (s Severity) AtLeast(min Severity) bool {
+	return s.rank() >= min.rank()
+}
+
+// lowerSeverity returns the less urgent of a and b
+func lowerSeverity(a, b Severity) Severity {
+	if a.AtLeast(b) {
+		return b
+	}
+	return a
+}
+
+// serviceSeverity returns the severity of down alerts for s
+func serviceSeverity(s *Service) Severity {
+	if s.Severity == "" {
+		return SeverityCritical
+	}
+	return s.Severity
+}
+
+// stateSeverity returns the severity of alerts for s being in
+// state, at most a warning when degraded
+func stateSeverity(s *Service, state State) Severity {
+	if state == StateDegraded {
+		return lowerSeverity(SeverityWarning, serviceSeverity(s))
+	}
+	return serviceSeverity(s)
+}
+
+// severityNotifier drops alerts less urgent than min
+type severityNotifier struct {
+	Notifier
+	min Severity
+}
+
+// Notify notifies the wrapped Notifier of alerts of at least
+// the minimum severity, and of tests
+func (n *severityNotifier) Notify(a Alert) error {
+	if a.Type != AlertTypeTest && !a.Severity.AtLeast(n.min) {
+		return nil
+	}
+	return n.Notifier.Notify(a)
+}
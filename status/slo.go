@@ -0,0 +1,244 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrInvalidSLO is returned for an SLO whose target is not a
+// percentage below 100 or whose windows cannot be parsed
+var ErrInvalidSLO = errors.New("slo: target must be between 0 and 100, windows durations such as 30d or 1h")
+
+// Defaults of an SLO
+const (
+	defaultSLOWindow  = "30d"
+	defaultBurnWindow = "1h"
+	// defaultBurnRate spends 2% of a 30 day budget in an hour
+	defaultBurnRate = 14.4
+)
+
+// SLO is the service level objective of a service, such as 99.9% of
+// uptime over 30 days. Its error budget is the downtime the target
+// allows over the Window. Notifiers are alerted when the budget is
+// spent BurnRate times faster than the target sustains over the last
+// BurnWindow.
+type SLO struct {
+	Target     float64 `json:"target"`
+	Window     string  `json:"window,omitempty"`
+	BurnRate   float64 `json:"burn_rate,omitempty"`
+	BurnWindow string  `json:"burn_window,omitempty"`
+}
+
+// Validate returns ErrInvalidSLO unless the target and windows are
+// valid and the burn window fits in the window
+func (s SLO) Validate() error {
+	window, burnWindow, err := s.windows()
+	if err != nil || s.Target <= 0 || s.Target >= 100 || s.BurnRate < 0 || burnWindow > window {
+		return ErrInvalidSLO
+	}
+	return nil
+}
+
+// windows returns the window and the burn window of the SLO
+func (s SLO) windows() (time.Duration, time.Duration, error) {
+	w, bw := s.Window, s.BurnWindow
+	if w == "" {
+		w = defaultSLOWindow
+	}
+	if bw == "" {
+		bw = defaultBurnWindow
+	}
+	window, err := parseRetention(w)
+	if err != nil {
+		return 0, 0, ErrInvalidSLO
+	}
+	burnWindow, err := parseRetention(bw)
+	if err != nil {
+		return 0, 0, ErrInvalidSLO
+	}
+	return window, burnWindow, nil
+}
+
+// ErrorBudget is how much of the error budget of a service is left
+type ErrorBudget struct {
+	Service string  `json:"service"`
+	Target  float64 `json:"target"`
+	Window  string  `json:"window"`
+	Uptime  float64 `json:"uptime"`
+	// Allowed is the downtime the target allows over the window
+	// and Consumed the downtime so far
+	Allowed  time.Duration `json:"allowed"`
+	Consumed time.Duration `json:"consumed"`
+	// Remaining is the percentage of the budget left, negative
+	// once it is exhausted
+	Remaining float64 `json:"remaining"`
+	// BurnRate is how many times faster than the target sustains
+	// the budget was spent over the burn window
+	BurnRate float64 `json:"burn_rate"`
+}
+
+// NewErrorBudget returns the error budget of a service over the
+// window of its SLO ending at now, from its incidents
+func NewErrorBudget(ctx context.Context, db StorageBackend, service string, slo SLO, now time.Time) (ErrorBudget, error) {
+	window, burnWindow, err := slo.windows()
+	if err != nil {
+		return ErrorBudget{}, err
+	}
+	incidents, err := db.IncidentsBetween(ctx, now.Add(-window), now)
+	if err != nil {
+		return ErrorBudget{}, err
+	}
+
+	b := ErrorBudget{Service: service, Target: slo.Target, Window: slo.Window}
+	if b.Window == "" {
+		b.Window = defaultSLOWindow
+	}
+	budget := 100 - slo.Target
+	b.Uptime = uptime(incidents, service, now.Add(-window), now)
+	b.Allowed = time.Duration(budget / 100 * float64(window)).Round(time.Second)
+	b.Consumed = time.Duration((100 - b.Uptime) / 100 * float64(window)).Round(time.Second)
+	b.Remaining = 100 * (budget - (100 - b.Uptime)) / budget
+	b.BurnRate = (100 - uptime(incidents, service, now.Add(-burnWindow), now)) / budget
+	return b, nil
+}
+
+// SLOMonitor tracks the error budgets of the services with an SLO
+// and alerts the notifiers of a NotificationManager when one burns
+// too fast
+type SLOMonitor struct {
+	nm *NotificationManager
+
+	mu       sync.Mutex
+	services []Service
+	// burning are the services alerted on, until their burn rate
+	// falls back under the threshold
+	burning map[string]bool
+}
+
+// NewSLOMonitor returns an SLOMonitor of the services with an SLO
+func NewSLOMonitor(services []Service, nm *NotificationManager) *SLOMonitor {
+	m := &SLOMonitor{nm: nm, burning: make(map[string]bool)}
+	m.SetServices(services)
+	return m
+}
+
+// SetServices tracks the error budgets of the services with an SLO
+// in place of the current ones, as when the config is reloaded
+func (m *SLOMonitor) SetServices(services []Service) {
+	var tracked []Service
+	for _, s := range services {
+		if s.SLO != nil {
+			tracked = append(tracked, s)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.services = tracked
+}
+
+// Budgets returns the error budgets of the services at now, in the
+// order of the config
+func (m *SLOMonitor) Budgets(ctx context.Context, now time.Time) ([]ErrorBudget, error) {
+	m.mu.Lock()
+	services := m.services
+	m.mu.Unlock()
+	return m.budgets(ctx, services, now)
+}
+
+// budgets returns the error budgets of services at now
+func (m *SLOMonitor) budgets(ctx context.Context, services []Service, now time.Time) ([]ErrorBudget, error) {
+	budgets := []ErrorBudget{}
+	for _, s := range services {
+		b, err := NewErrorBudget(ctx, m.nm.Storage, s.ID(), *s.SLO, now)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, nil
+}
+
+// Check alerts on the services whose budget started burning faster
+// than their SLO allows at now
+func (m *SLOMonitor) Check(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	services := m.services
+	m.mu.Unlock()
+	budgets, err := m.budgets(ctx, services, now)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, b := range budgets {
+		s := services[i]
+		threshold := s.SLO.BurnRate
+		if threshold == 0 {
+			threshold = defaultBurnRate
+		}
+		if b.BurnRate < threshold {
+			if m.burning[b.Service] {
+				delete(m.burning, b.Service)
+				m.nm.dispatch(Alert{
+					Type:     AlertTypeBurnRateResolved,
+					Severity: SeverityWarning,
+					Service:  s,
+					Message:  fmt.Sprintf("error budget of %g%% over %s burning %.1fx, %.1f%% left", b.Target, b.Window, b.BurnRate, b.Remaining),
+					Time:     now,
+				}, m.nm.targets(0))
+			}
+			continue
+		}
+		if m.burning[b.Service] {
+			continue
+		}
+		m.burning[b.Service] = true
+		m.nm.dispatch(Alert{
+			Type:     AlertTypeBurnRate,
+			Severity: SeverityWarning,
+			Service:  s,
+			Message:  fmt.Sprintf("error budget of %g%% over %s burning %.1fx too fast, %.1f%% left", b.Target, b.Window, b.BurnRate, b.Remaining),
+			Time:     now,
+		}, m.nm.targets(0))
+	}
+	return nil
+}
+
+// Run checks the error budgets every interval. It does not return.
+func (m *SLOMonitor) Run(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		ctx, cancel := storageContext()
+		if err := m.Check(ctx, time.Now()); err != nil {
+			log.Printf("check error budgets: %v", err)
+		}
+		cancel()
+	}
+}
+
+// SLOHandler is a HandlerFunc which lists the error budgets of the
+// services with an SLO (GET /api/slo)
+func SLOHandler(m *SLOMonitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		ctx, cancel := requestContext(r)
+		defer cancel()
+		budgets, err := m.Budgets(ctx, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(budgets)
+	}
+}
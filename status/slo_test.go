@@ -0,0 +1,68 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestErrorBudget(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Now()
+	// 30 minutes down over a 30 day window with a 99.9% target,
+	// whose budget is 43.2 minutes
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "", now.Add(-10*24*time.Hour))
+	db.ResolveIncident(ctx, "api", now.Add(-10*24*time.Hour+30*time.Minute))
+
+	b, err := NewErrorBudget(ctx, db, "api", SLO{Target: 99.9}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Allowed != 43*time.Minute+12*time.Second || b.Consumed != 30*time.Minute {
+		t.Errorf("expected 30m of 43m12s consumed got %v of %v", b.Consumed, b.Allowed)
+	}
+	if math.Abs(b.Remaining-30.56) > 0.01 || b.BurnRate != 0 {
+		t.Errorf("expected 30.56%% left and no burn got %+v", b)
+	}
+
+	for _, slo := range []SLO{{Target: 100}, {Target: 99, Window: "x"}, {Target: 99, Window: "1h", BurnWindow: "2h"}} {
+		if err := slo.Validate(); err != ErrInvalidSLO {
+			t.Errorf("expected %v for %+v got %v", ErrInvalidSLO, slo, err)
+		}
+	}
+}
+
+func TestSLOMonitor(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	rec := &recordingNotifier{}
+	nm := NewNotificationManager([]Notifier{rec}, 0)
+	nm.Storage = db
+	m := NewSLOMonitor([]Service{{Name: "api", SLO: &SLO{Target: 99.9}}, {Name: "web"}}, nm)
+
+	now := time.Now()
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "", now.Add(-5*time.Minute))
+	m.Check(ctx, now)
+	m.Check(ctx, now.Add(time.Minute))
+	if len(rec.alerts) != 1 || rec.alerts[0].Type != AlertTypeBurnRate || rec.alerts[0].Title() != "api is burning its error budget" {
+		t.Fatalf("expected one burn rate alert got %+v", rec.alerts)
+	}
+	db.ResolveIncident(ctx, "api", now.Add(time.Minute))
+	m.Check(ctx, now.Add(2*time.Hour))
+	if len(rec.alerts) != 2 || rec.alerts[1].Type != AlertTypeBurnRateResolved || rec.alerts[1].Title() != "api stopped burning its error budget" {
+		t.Fatalf("expected the burn rate to be resolved got %+v", rec.alerts)
+	}
+
+	w := httptest.NewRecorder()
+	SLOHandler(m)(w, httptest.NewRequest(http.MethodGet, "/api/slo", nil))
+	var budgets []ErrorBudget
+	json.NewDecoder(w.Body).Decode(&budgets)
+	if len(budgets) != 1 || budgets[0].Service != "api" || budgets[0].BurnRate < defaultBurnRate {
+		t.Errorf("expected the budget of api got %+v", budgets)
+	}
+}
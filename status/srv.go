@@ -0,0 +1,72 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// SRVDiscoverer discovers the instances of services from their DNS SRV
+// records, checking each target at its port
+type SRVDiscoverer struct {
+	config    DiscoveryConfig
+	lookupSRV func(ctx context.Context, name string) ([]*net.SRV, error)
+}
+
+func newSRVDiscoverer(c DiscoveryConfig) (*SRVDiscoverer, error) {
+	resolver := net.DefaultResolver
+	if c.Server != "" {
+		if _, _, err := net.SplitHostPort(c.Server); err != nil {
+			return nil, err
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, c.Server)
+			},
+		}
+	}
+	return &SRVDiscoverer{
+		config: c,
+		lookupSRV: func(ctx context.Context, name string) ([]*net.SRV, error) {
+			_, addrs, err := resolver.LookupSRV(ctx, "", "", name)
+			return addrs, err
+		},
+	}, nil
+}
+
+// Discover returns the services of the targets of every record,
+// sorted by name. A record which does not exist has no instances.
+func (d *SRVDiscoverer) Discover(ctx context.Context) ([]Service, error) {
+	var services []Service
+	for _, name := range d.config.Names {
+		addrs, err := d.lookupSRV(ctx, name)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lookup %s: %w", name, err)
+		}
+		pool := srvPool(name)
+		for _, addr := range addrs {
+			services = append(services, instanceService(d.config, pool, strings.TrimSuffix(addr.Target, "."), int(addr.Port)))
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+// srvPool returns the name of the service of a SRV record, the record
+// without its leading _service._proto labels
+func srvPool(name string) string {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for len(labels) > 1 && strings.HasPrefix(labels[0], "_") {
+		labels = labels[1:]
+	}
+	return strings.Join(labels, ".")
+}
@@ -0,0 +1,50 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestSRVDiscoverer(t *testing.T) {
+	c := DiscoveryConfig{
+		Type:    "dns",
+		Names:   []string{"_db._tcp.db.example.com", "_api._tcp.gone.example.com"},
+		Service: Service{Type: "tcp", Group: "Databases"},
+	}
+	d, err := newSRVDiscoverer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.lookupSRV = func(ctx context.Context, name string) ([]*net.SRV, error) {
+		if name != "_db._tcp.db.example.com" {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return []*net.SRV{{Target: "db-2.example.com.", Port: 5432}, {Target: "db-1.example.com.", Port: 5432}}, nil
+	}
+	services, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Service{
+		{Name: "db.example.com@db-1.example.com:5432", Type: "tcp", URL: "db-1.example.com", Port: "5432", Group: "Databases", Pool: "db.example.com"},
+		{Name: "db.example.com@db-2.example.com:5432", Type: "tcp", URL: "db-2.example.com", Port: "5432", Group: "Databases", Pool: "db.example.com"},
+	}
+	if !reflect.DeepEqual(services, expected) {
+		t.Errorf("expected %+v got %+v", expected, services)
+	}
+
+	d.lookupSRV = func(ctx context.Context, name string) ([]*net.SRV, error) {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name}
+	}
+	var dnsErr *net.DNSError
+	if _, err := d.Discover(context.Background()); !errors.As(err, &dnsErr) {
+		t.Errorf("expected the lookup error got %v", err)
+	}
+
+	if _, err := NewDiscoverer(DiscoveryConfig{Type: "dns", Names: []string{"_x._tcp.example.com"}, Server: "10.0.0.2"}); err == nil {
+		t.Error("expected an error for a server without a port")
+	}
+}
@@ -0,0 +1,46 @@
+package status
+
+import (
+	"net/http"
+	"sync"
+)
+
+// PageStore holds the latest Page, replaced after every sweep of the
+// checks, and is safe for concurrent use by the handlers serving it
+type PageStore struct {
+	mu   sync.RWMutex
+	page Page
+	// public is the page shown to viewers who have not signed in
+	public Page
+}
+
+// NewPageStore returns a PageStore holding p
+func NewPageStore(p Page) *PageStore {
+	return &PageStore{page: p, public: p.Public()}
+}
+
+// Page returns the latest Page
+func (s *PageStore) Page() Page {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.page
+}
+
+// PageFor returns the latest Page as shown to the viewer of r, without
+// the private services until they sign in
+func (s *PageStore) PageFor(r *http.Request) Page {
+	if Viewer(r.Context()) != "" {
+		return s.Page()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.public
+}
+
+// Set replaces the Page with p, the Page of the latest sweep
+func (s *PageStore) Set(p Page) {
+	public := p.Public()
+	s.mu.Lock()
+	s.page, s.public = p, public
+	s.mu.Unlock()
+}
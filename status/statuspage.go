@@ -0,0 +1,342 @@
+package status
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// statuspagePageID is the id of the page in the Statuspage API, which
+// serves a single page
+const statuspagePageID = "status"
+
+// statuspageComponentStates map the states of the services to the
+// statuses of Statuspage components
+var statuspageComponentStates = map[State]string{
+	StateUp:          "operational",
+	StateDegraded:    "degraded_performance",
+	StateAffected:    "partial_outage",
+	StateDown:        "major_outage",
+	StateMaintenance: "under_maintenance",
+}
+
+// statuspageIndicators map the statuses of the page to the indicator
+// and description of its Statuspage status
+var statuspageIndicators = map[string][2]string{
+	"success":     {"none", "All Systems Operational"},
+	"warning":     {"minor", "Partially Degraded Service"},
+	"danger":      {"major", "Partial System Outage"},
+	"maintenance": {"maintenance", "Service Under Maintenance"},
+}
+
+// statuspageImpacts map the severities of incidents to their
+// Statuspage impact
+var statuspageImpacts = map[Severity]string{
+	SeverityCritical: "major",
+	SeverityWarning:  "minor",
+	SeverityInfo:     "none",
+}
+
+// StatuspagePage describes the page in the Statuspage API
+type StatuspagePage struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	TimeZone  string    `json:"time_zone"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StatuspageStatus is the overall status in the Statuspage API:
+// indicator none, minor, major or maintenance
+type StatuspageStatus struct {
+	Indicator   string `json:"indicator"`
+	Description string `json:"description"`
+}
+
+// StatuspageComponent is a service in the Statuspage API
+type StatuspageComponent struct {
+	ID                 string    `json:"id"`
+	Name               string    `json:"name"`
+	Status             string    `json:"status"`
+	Description        *string   `json:"description"`
+	Position           int       `json:"position"`
+	PageID             string    `json:"page_id"`
+	GroupID            *string   `json:"group_id"`
+	Group              bool      `json:"group"`
+	Showcase           bool      `json:"showcase"`
+	OnlyShowIfDegraded bool      `json:"only_show_if_degraded"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// StatuspageUpdate is an update of an incident or a scheduled
+// maintenance in the Statuspage API
+type StatuspageUpdate struct {
+	ID         string    `json:"id"`
+	IncidentID string    `json:"incident_id"`
+	Status     string    `json:"status"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	DisplayAt  time.Time `json:"display_at"`
+}
+
+// StatuspageIncident is an incident or a scheduled maintenance in the
+// Statuspage API. Incidents are investigating, identified once
+// acknowledged, resolved and postmortem once it is written;
+// maintenance is scheduled or in_progress.
+type StatuspageIncident struct {
+	ID              string                `json:"id"`
+	Name            string                `json:"name"`
+	Status          string                `json:"status"`
+	Impact          string                `json:"impact"`
+	Shortlink       string                `json:"shortlink"`
+	PageID          string                `json:"page_id"`
+	CreatedAt       time.Time             `json:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+	StartedAt       time.Time             `json:"started_at"`
+	MonitoringAt    *time.Time            `json:"monitoring_at"`
+	ResolvedAt      *time.Time            `json:"resolved_at"`
+	ScheduledFor    *time.Time            `json:"scheduled_for,omitempty"`
+	ScheduledUntil  *time.Time            `json:"scheduled_until,omitempty"`
+	IncidentUpdates []StatuspageUpdate    `json:"incident_updates"`
+	Components      []StatuspageComponent `json:"components"`
+}
+
+// StatuspageSummary is the summary.json of the Statuspage API: the
+// components, the unresolved incidents and the maintenance which has
+// not ended
+type StatuspageSummary struct {
+	Page                  StatuspagePage        `json:"page"`
+	Status                StatuspageStatus      `json:"status"`
+	Components            []StatuspageComponent `json:"components"`
+	Incidents             []StatuspageIncident  `json:"incidents"`
+	ScheduledMaintenances []StatuspageIncident  `json:"scheduled_maintenances"`
+}
+
+// statuspage translates a Page into the Statuspage API
+type statuspage struct {
+	p          Page
+	baseURL    string
+	components map[string]StatuspageComponent
+}
+
+// newStatuspage returns the translation of p, reached at baseURL
+func newStatuspage(p Page, baseURL string) statuspage {
+	sp := statuspage{p: p, baseURL: strings.TrimSuffix(baseURL, "/"), components: make(map[string]StatuspageComponent)}
+	for i, s := range NewAPIResponse(p).Services {
+		sp.components[s.ID] = StatuspageComponent{
+			ID:        s.ID,
+			Name:      s.ID,
+			Status:    statuspageComponentStates[s.State],
+			Position:  i + 1,
+			PageID:    statuspagePageID,
+			CreatedAt: p.Updated.UTC(),
+			UpdatedAt: p.Updated.UTC(),
+		}
+	}
+	return sp
+}
+
+func (sp statuspage) page() StatuspagePage {
+	return StatuspagePage{
+		ID:        statuspagePageID,
+		Name:      sp.p.Title,
+		URL:       sp.baseURL + "/",
+		TimeZone:  "Etc/UTC",
+		UpdatedAt: sp.p.Updated.UTC(),
+	}
+}
+
+func (sp statuspage) status() StatuspageStatus {
+	i, ok := statuspageIndicators[string(sp.p.Status)]
+	if !ok {
+		i = statuspageIndicators["success"]
+	}
+	return StatuspageStatus{Indicator: i[0], Description: i[1]}
+}
+
+// list returns the components of the services with the given ids,
+// every component without ids, ordered by position
+func (sp statuspage) list(ids []string) []StatuspageComponent {
+	list := make([]StatuspageComponent, len(sp.components))
+	for _, c := range sp.components {
+		list[c.Position-1] = c
+	}
+	if len(ids) == 0 {
+		return list
+	}
+	selected := []StatuspageComponent{}
+	for _, c := range list {
+		for _, id := range ids {
+			if c.ID == id {
+				selected = append(selected, c)
+			}
+		}
+	}
+	return selected
+}
+
+// incident translates an incident. As in the mails to the
+// subscribers, the messages of checks are not shown.
+func (sp statuspage) incident(inc Incident) StatuspageIncident {
+	name, body := inc.Service+" is "+string(inc.State), ""
+	services := []string{inc.Service}
+	if inc.Manual {
+		name, body, services = inc.Title, inc.Message, inc.Services
+	}
+	if body == "" {
+		body = name + "."
+	}
+	start := inc.Start.UTC()
+	si := StatuspageIncident{
+		ID:         inc.ID,
+		Name:       name,
+		Status:     "investigating",
+		Impact:     statuspageImpacts[inc.Severity],
+		Shortlink:  sp.baseURL + "/",
+		PageID:     statuspagePageID,
+		CreatedAt:  start,
+		UpdatedAt:  start,
+		StartedAt:  start,
+		Components: sp.list(services),
+	}
+	if si.Impact == "" {
+		si.Impact = "minor"
+	}
+
+	// updates are listed newest first
+	add := func(status, body string, t time.Time) {
+		t = t.UTC()
+		si.IncidentUpdates = append([]StatuspageUpdate{{
+			ID:         fmt.Sprintf("%s-%d", inc.ID, len(si.IncidentUpdates)),
+			IncidentID: inc.ID,
+			Status:     status,
+			Body:       body,
+			CreatedAt:  t,
+			UpdatedAt:  t,
+			DisplayAt:  t,
+		}}, si.IncidentUpdates...)
+		si.Status = status
+		if t.After(si.UpdatedAt) {
+			si.UpdatedAt = t
+		}
+	}
+	add("investigating", body, inc.Start)
+	for _, u := range inc.Updates {
+		status := "investigating"
+		if inc.Acked() && !u.Time.Before(inc.AckedAt) {
+			status = "identified"
+		}
+		add(status, u.Message, u.Time)
+	}
+	if inc.Acked() && si.Status == "investigating" {
+		si.Status = "identified"
+	}
+	if !inc.Ongoing() {
+		end := inc.End.UTC()
+		si.ResolvedAt = &end
+		add("resolved", "This incident has been resolved.", inc.End)
+		if inc.Postmortem != "" {
+			si.Status = "postmortem"
+		}
+	}
+	return si
+}
+
+// maintenance translates scheduled maintenance at now
+func (sp statuspage) maintenance(sm ScheduledMaintenance, now time.Time) StatuspageIncident {
+	start, end := sm.Start.UTC(), sm.End.UTC()
+	si := StatuspageIncident{
+		ID:             sm.ID,
+		Name:           "Scheduled maintenance",
+		Status:         "scheduled",
+		Impact:         "maintenance",
+		Shortlink:      sp.baseURL + "/",
+		PageID:         statuspagePageID,
+		CreatedAt:      start,
+		UpdatedAt:      start,
+		StartedAt:      start,
+		ScheduledFor:   &start,
+		ScheduledUntil: &end,
+		Components:     sp.list(sm.Services),
+	}
+	if !now.Before(sm.Start) {
+		si.Status = "in_progress"
+	}
+	body := sm.Description
+	if body == "" {
+		body = "Maintenance is scheduled."
+	}
+	si.IncidentUpdates = []StatuspageUpdate{{
+		ID:         sm.ID + "-0",
+		IncidentID: sm.ID,
+		Status:     si.Status,
+		Body:       body,
+		CreatedAt:  start,
+		UpdatedAt:  start,
+		DisplayAt:  start,
+	}}
+	return si
+}
+
+// StatuspageHandler is a HandlerFunc which serves the latest Page of
+// a PageStore as the public Atlassian Statuspage API, for the
+// dashboards and integrations written against it:
+// /api/v2/status.json, /api/v2/summary.json and /api/v2/incidents.json.
+// baseURL is where the page is reached.
+func StatuspageHandler(s *PageStore, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		sp := newStatuspage(s.PageFor(r), baseURL)
+		incidents := []StatuspageIncident{}
+		for _, inc := range sp.p.Incidents {
+			incidents = append(incidents, sp.incident(inc))
+		}
+
+		var resp interface{}
+		switch strings.TrimPrefix(r.URL.Path, "/api/v2/") {
+		case "status.json":
+			resp = struct {
+				Page   StatuspagePage   `json:"page"`
+				Status StatuspageStatus `json:"status"`
+			}{sp.page(), sp.status()}
+		case "summary.json":
+			summary := StatuspageSummary{
+				Page:                  sp.page(),
+				Status:                sp.status(),
+				Components:            sp.list(nil),
+				Incidents:             []StatuspageIncident{},
+				ScheduledMaintenances: []StatuspageIncident{},
+			}
+			for i, inc := range sp.p.Incidents {
+				if inc.Ongoing() {
+					summary.Incidents = append(summary.Incidents, incidents[i])
+				}
+			}
+			for _, sm := range sp.p.Upcoming {
+				summary.ScheduledMaintenances = append(summary.ScheduledMaintenances, sp.maintenance(sm, time.Now()))
+			}
+			resp = summary
+		case "incidents.json":
+			resp = struct {
+				Page      StatuspagePage       `json:"page"`
+				Incidents []StatuspageIncident `json:"incidents"`
+			}{sp.page(), incidents}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		// the Statuspage API is read by dashboards in browsers, from
+		// any origin unless CORS is configured
+		if w.Header().Get("Access-Control-Allow-Origin") == "" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		serveCached(w, r, resp, sp.p.Updated)
+	}
+}
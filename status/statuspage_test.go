@@ -0,0 +1,81 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatuspageHandler(t *testing.T) {
+	now := time.Now()
+	p := NewPage("Status", []Result{
+		{Service: &Service{Name: "web"}, State: StateUp},
+		{Service: &Service{Name: "api"}, State: StateDown, Err: errors.New("timeout")},
+	})
+	p.SetIncidents([]Incident{
+		{ID: "2", Service: "api", State: StateDown, Severity: SeverityCritical, Message: "dial tcp: timeout", Start: now.Add(-time.Hour),
+			AckedBy: "ops", AckedAt: now.Add(-30 * time.Minute)},
+		{ID: "1", Manual: true, Title: "Slow logins", Services: []string{"web"}, State: StateDegraded, Severity: SeverityWarning,
+			Start: now.Add(-48 * time.Hour), End: now.Add(-47 * time.Hour), Postmortem: "A cache expired."},
+	})
+	p.Upcoming = []ScheduledMaintenance{{ID: "m1", Start: now.Add(time.Hour), End: now.Add(2 * time.Hour), Services: []string{"api"}}}
+	handler := StatuspageHandler(NewPageStore(p), "https://status.example.com/")
+
+	get := func(path string, v interface{}) int {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code
+	}
+
+	var status struct {
+		Page   StatuspagePage
+		Status StatuspageStatus
+	}
+	get("/api/v2/status.json", &status)
+	if status.Page.URL != "https://status.example.com/" || status.Status.Indicator != "major" {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	var summary StatuspageSummary
+	get("/api/v2/summary.json", &summary)
+	if len(summary.Components) != 2 || summary.Components[0].ID != "api" || summary.Components[0].Status != "major_outage" ||
+		summary.Components[1].Status != "operational" {
+		t.Errorf("unexpected components %+v", summary.Components)
+	}
+	if len(summary.Incidents) != 1 || summary.Incidents[0].Status != "identified" || summary.Incidents[0].Impact != "major" {
+		t.Errorf("expected the ongoing incident got %+v", summary.Incidents)
+	}
+	if len(summary.ScheduledMaintenances) != 1 || summary.ScheduledMaintenances[0].Status != "scheduled" ||
+		len(summary.ScheduledMaintenances[0].Components) != 1 {
+		t.Errorf("unexpected maintenance %+v", summary.ScheduledMaintenances)
+	}
+
+	var incidents struct {
+		Incidents []StatuspageIncident
+	}
+	get("/api/v2/incidents.json", &incidents)
+	if len(incidents.Incidents) != 2 {
+		t.Fatalf("expected 2 incidents got %+v", incidents.Incidents)
+	}
+	ongoing, resolved := incidents.Incidents[0], incidents.Incidents[1]
+	// the error of the check is not published
+	if ongoing.Name != "api is down" || ongoing.IncidentUpdates[0].Body != "api is down." || ongoing.ResolvedAt != nil {
+		t.Errorf("unexpected incident %+v", ongoing)
+	}
+	if resolved.Status != "postmortem" || resolved.ResolvedAt == nil || len(resolved.IncidentUpdates) != 2 ||
+		resolved.IncidentUpdates[0].Status != "resolved" || len(resolved.Components) != 1 || resolved.Components[0].ID != "web" {
+		t.Errorf("unexpected incident %+v", resolved)
+	}
+
+	if code := get("/api/v2/pages.json", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 got %d", code)
+	}
+}
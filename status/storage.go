@@ -0,0 +1,1400 @@
+package status
+
+import (
+	"context"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the storage
+var (
+	ErrIncidentNotFound = errors.New("storage: incident not found")
+	ErrIncidentResolved = errors.New("storage: incident already resolved")
+	ErrUnknownStorage   = errors.New("storage: unknown storage type")
+	ErrStorageTooNew    = errors.New("storage: written by a newer version, refusing to downgrade")
+)
+
+// StorageTimeout bounds how long a query waits on the storage, such
+// as for a lock held by a concurrent write. Set it before monitoring
+// starts.
+var StorageTimeout = 5 * time.Second
+
+// storageContext returns a context which times out after
+// StorageTimeout, for queries made outside a request
+func storageContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), StorageTimeout)
+}
+
+// requestContext returns the context of a request, which also times
+// out after StorageTimeout
+func requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), StorageTimeout)
+}
+
+// StorageBackend keeps the history of the services: their incidents,
+// the alerts sent about them and the state of the notifications.
+// Storage, a JSON file, is the default backend; others are added
+// with RegisterStorage.
+//
+// Every method but Close gives up with the error of its context once
+// it is done, so a stuck backend cannot hang the checks or requests.
+type StorageBackend interface {
+	// StartIncident opens an incident for a service, or updates the
+	// state, severity and message of its ongoing incident
+	StartIncident(ctx context.Context, service string, state State, severity Severity, message string, t time.Time) (Incident, error)
+	// ResolveIncident ends the ongoing incident of a service and
+	// returns it, false when there is none
+	ResolveIncident(ctx context.Context, service string, t time.Time) (Incident, bool, error)
+	OngoingIncident(ctx context.Context, service string) (Incident, bool, error)
+	Incident(ctx context.Context, id string) (Incident, bool, error)
+	// Incidents returns every incident and IncidentsBetween those
+	// ongoing at some point between start and end, oldest first
+	Incidents(ctx context.Context) ([]Incident, error)
+	IncidentsBetween(ctx context.Context, start, end time.Time) ([]Incident, error)
+	// IncidentsPage returns at most limit incidents selected by
+	// the filter, newest first, skipping the first offset, and how
+	// many it selects in all
+	IncidentsPage(ctx context.Context, f IncidentFilter, offset, limit int) ([]Incident, int, error)
+	SetEscalations(ctx context.Context, id string, n int) error
+	Acknowledge(ctx context.Context, id, by string, t time.Time) (Incident, error)
+	// OpenIncident opens an incident declared by an operator and
+	// CloseIncident resolves one by ID
+	OpenIncident(ctx context.Context, inc Incident) (Incident, error)
+	CloseIncident(ctx context.Context, id string, t time.Time) (Incident, error)
+	// AddIncidentUpdate and SetPostmortem annotate an incident
+	// and return it
+	AddIncidentUpdate(ctx context.Context, id string, u IncidentUpdate) (Incident, error)
+	SetPostmortem(ctx context.Context, id, postmortem string) (Incident, error)
+
+	// RecordStatus keeps the outcome of checks, StatusHistory
+	// returns those of a service, or of every service when empty,
+	// between start and end, oldest first
+	RecordStatus(ctx context.Context, records ...StatusRecord) error
+	StatusHistory(ctx context.Context, service string, start, end time.Time) ([]StatusRecord, error)
+	// StatusHistoryPage returns at most limit of those checks,
+	// newest first, skipping the first offset, and how many there
+	// are in all
+	StatusHistoryPage(ctx context.Context, service string, start, end time.Time, offset, limit int) ([]StatusRecord, int, error)
+	// SaveRollups keeps the rollups of a period, which is rolled up
+	// until then, and Rollups returns those of a service starting
+	// between start and end, oldest first
+	SaveRollups(ctx context.Context, period time.Duration, until time.Time, rollups []StatusRollup) error
+	RolledUpUntil(ctx context.Context, period time.Duration) (time.Time, error)
+	Rollups(ctx context.Context, service string, period time.Duration, start, end time.Time) ([]StatusRollup, error)
+
+	// SyncServices records the configured services at now,
+	// moving the history of renamed ones to their new name and
+	// flagging the ones no longer configured as removed. Services
+	// returns them all, sorted by name.
+	SyncServices(ctx context.Context, services []ServiceInfo, now time.Time) error
+	Services(ctx context.Context) ([]ServiceInfo, error)
+
+	// SetLastAlert and LastAlert keep when a service was last
+	// alerted on, AddDelivery and Deliveries the outcome of
+	// delivering the alerts
+	SetLastAlert(ctx context.Context, service string, t time.Time) error
+	LastAlert(ctx context.Context, service string) (time.Time, error)
+	AddDelivery(ctx context.Context, d AlertDelivery) error
+	Deliveries(ctx context.Context) ([]AlertDelivery, error)
+
+	// SaveMaintenance, RemoveMaintenance and Maintenances keep the
+	// scheduled maintenance
+	SaveMaintenance(ctx context.Context, sm ScheduledMaintenance) (ScheduledMaintenance, error)
+	RemoveMaintenance(ctx context.Context, id string) error
+	Maintenances(ctx context.Context) ([]ScheduledMaintenance, error)
+
+	// SaveNotifier, RemoveNotifier and ManagedNotifiers keep the
+	// notifiers managed through the API
+	SaveNotifier(ctx context.Context, mn ManagedNotifier) (ManagedNotifier, error)
+	RemoveNotifier(ctx context.Context, id string) error
+	ManagedNotifiers(ctx context.Context) ([]ManagedNotifier, error)
+
+	// SaveManagedService, RemoveManagedService and ManagedServices
+	// keep the services managed in the admin area
+	SaveManagedService(ctx context.Context, ms ManagedService) (ManagedService, error)
+	RemoveManagedService(ctx context.Context, id string) error
+	ManagedServices(ctx context.Context) ([]ManagedService, error)
+
+	// SaveAPIKey, RemoveAPIKey and APIKeys keep the hashed API keys
+	SaveAPIKey(ctx context.Context, k APIKey) (APIKey, error)
+	RemoveAPIKey(ctx context.Context, id string) error
+	APIKeys(ctx context.Context) ([]APIKey, error)
+
+	// SaveSubscriber, RemoveSubscriber and Subscribers keep the
+	// email subscribers of the status updates
+	SaveSubscriber(ctx context.Context, s Subscriber) (Subscriber, error)
+	RemoveSubscriber(ctx context.Context, id string) error
+	Subscribers(ctx context.Context) ([]Subscriber, error)
+
+	// Prune removes the records older than the retention at now
+	// and returns how many it removed
+	Prune(ctx context.Context, r Retention, now time.Time) (int, error)
+	// Tenant returns the partition of the storage named name, whose
+	// queries are all scoped to it. An empty name is the storage
+	// itself.
+	Tenant(name string) StorageBackend
+	// Backup writes a consistent snapshot of the storage to w, and
+	// Restore replaces everything in the storage with one
+	Backup(ctx context.Context, w io.Writer) error
+	Restore(ctx context.Context, r io.Reader) error
+	Close() error
+}
+
+// StorageOpener opens a storage backend at a location, such as a
+// path or a connection string
+type StorageOpener func(location string) (StorageBackend, error)
+
+var (
+	storagesMu sync.RWMutex
+	storages   = make(map[string]StorageOpener)
+)
+
+func init() {
+	RegisterStorage("file", func(location string) (StorageBackend, error) {
+		db, err := OpenStorage(location)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	})
+}
+
+// RegisterStorage makes a storage backend available under the given
+// type, so programs embedding the package can add their own. It
+// panics if the type is registered twice or open is nil.
+func RegisterStorage(typ string, open StorageOpener) {
+	storagesMu.Lock()
+	defer storagesMu.Unlock()
+
+	if open == nil {
+		panic("status: RegisterStorage open is nil")
+	}
+	if _, dup := storages[typ]; dup {
+		panic("status: RegisterStorage called twice for " + typ)
+	}
+	storages[typ] = open
+}
+
+// OpenStorageBackend opens the storage backend registered for typ,
+// "file" when empty, at location
+func OpenStorageBackend(typ, location string) (StorageBackend, error) {
+	if typ == "" {
+		typ = "file"
+	}
+	storagesMu.RLock()
+	open, ok := storages[typ]
+	storagesMu.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownStorage
+	}
+	return open(location)
+}
+
+// Incident is a period during which a service was not up, or an
+// incident opened manually by an operator
+type Incident struct {
+	ID      string `json:"id"`
+	Service string `json:"service,omitempty"`
+	// Manual incidents are not tied to a check. They have a Title
+	// and the Services they affect, and are resolved manually.
+	Manual   bool      `json:"manual,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Services []string  `json:"services,omitempty"`
+	State    State     `json:"state"`
+	Severity Severity  `json:"severity"`
+	Message  string    `json:"message,omitempty"`
+	Start    time.Time `json:"start"`
+	// End is zero while the incident is ongoing
+	End time.Time `json:"end"`
+	// Escalations is how many escalation tiers have been
+	// notified of the incident
+	Escalations int `json:"escalations,omitempty"`
+	// AckedBy is who acknowledged the incident, at AckedAt
+	AckedBy string    `json:"acked_by,omitempty"`
+	AckedAt time.Time `json:"acked_at"`
+	// Updates note the progress of the incident, oldest first, and
+	// Postmortem explains it once resolved
+	Updates    []IncidentUpdate `json:"updates,omitempty"`
+	Postmortem string           `json:"postmortem,omitempty"`
+}
+
+// Ongoing reports whether the incident has not been resolved
+func (i Incident) Ongoing() bool {
+	return i.End.IsZero()
+}
+
+// Acked reports whether the incident has been acknowledged
+func (i Incident) Acked() bool {
+	return !i.AckedAt.IsZero()
+}
+
+// storageMigrations upgrade the content of a storage file written by
+// an older version, in order. A file at version n has had the first n
+// applied. Append to add one, never change or remove them.
+var storageMigrations = []func(*storageData){
+	// 1: incidents recorded before severities were critical when
+	// down, warnings when degraded
+	func(d *storageData) {
+		for i := range d.Incidents {
+			if d.Incidents[i].Severity != "" {
+				continue
+			}
+			d.Incidents[i].Severity = SeverityCritical
+			if d.Incidents[i].State == StateDegraded {
+				d.Incidents[i].Severity = SeverityWarning
+			}
+		}
+	},
+	// 2: times were written in the zone they were recorded in
+	func(d *storageData) {
+		*d = canonicalTimes(*d)
+	},
+}
+
+// storageData is the content of a storage file
+type storageData struct {
+	// Version is how many storageMigrations were applied
+	Version   int        `json:"version"`
+	Incidents []Incident `json:"incidents"`
+	NextID    int        `json:"next_id"`
+	// LastAlerts maps services to when they were last alerted on
+	LastAlerts map[string]time.Time `json:"last_alerts,omitempty"`
+	// Deliveries are the latest alert deliveries, oldest first
+	Deliveries     []AlertDelivery `json:"deliveries,omitempty"`
+	NextDeliveryID int             `json:"next_delivery_id,omitempty"`
+	// Maintenances is the scheduled maintenance
+	Maintenances      []ScheduledMaintenance `json:"maintenances,omitempty"`
+	NextMaintenanceID int                    `json:"next_maintenance_id,omitempty"`
+	// Services are the services configured now or before
+	Services []ServiceInfo `json:"services,omitempty"`
+	// Notifiers are the notifiers added through the API
+	Notifiers      []ManagedNotifier `json:"notifiers,omitempty"`
+	NextNotifierID int               `json:"next_notifier_id,omitempty"`
+	// ManagedServices are the services added in the admin area
+	ManagedServices      []ManagedService `json:"managed_services,omitempty"`
+	NextManagedServiceID int              `json:"next_managed_service_id,omitempty"`
+	// APIKeys are the hashed API keys
+	APIKeys      []APIKey `json:"api_keys,omitempty"`
+	NextAPIKeyID int      `json:"next_api_key_id,omitempty"`
+	// Subscribers are the email subscribers of the status updates
+	Subscribers      []Subscriber `json:"subscribers,omitempty"`
+	NextSubscriberID int          `json:"next_subscriber_id,omitempty"`
+	// Tenants are the partitions of the storage, each with data of
+	// its own
+	Tenants map[string]*storageData `json:"tenants,omitempty"`
+	// Statuses is the history of the checks, oldest first
+	Statuses []StatusRecord `json:"statuses,omitempty"`
+	// Rollups summarise the statuses, oldest first, and
+	// RolledUpUntil maps their periods to where they end
+	Rollups       []StatusRollup              `json:"rollups,omitempty"`
+	RolledUpUntil map[time.Duration]time.Time `json:"rolled_up_until,omitempty"`
+}
+
+// Storage is a StorageBackend which keeps the history of the services
+// in memory. With a path it is persisted to a JSON file and survives
+// restarts.
+type Storage struct {
+	// sem holds a token while the storage is in use, so waiting
+	// for it can be given up when a context is done
+	sem  chan struct{}
+	path string
+	data *storageData
+	// root is the storage a tenant partition belongs to, nil for
+	// the root itself
+	root *Storage
+	// aead encrypts the messages in the file, nil when they are
+	// kept in plain text
+	aead cipher.AEAD
+}
+
+// OpenStorage returns a storage persisted to path, loading what is
+// already in it. An empty path keeps everything in memory.
+//
+// A file written by an older version is migrated and saved, and one
+// written by a newer version is refused with ErrStorageTooNew.
+func OpenStorage(path string) (*Storage, error) {
+	db := &Storage{sem: make(chan struct{}, 1), path: path, data: &storageData{Version: len(storageMigrations)}, aead: storageAEAD}
+	if path == "" {
+		return db, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := db.unmarshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("storage: read %s: %w", path, err)
+	}
+	db.data = &data
+	if err := db.migrate(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Tenant returns the partition of the storage named name, created
+// on its first write, so several independent status pages can share
+// one storage. Every query of a partition is scoped to it, and the
+// partitions of a partition are those of the root. An empty name
+// returns the root.
+func (db *Storage) Tenant(name string) StorageBackend {
+	root := db
+	if db.root != nil {
+		root = db.root
+	}
+	if name == "" {
+		return root
+	}
+	root.lock(context.Background())
+	defer root.unlock()
+	data, ok := root.data.Tenants[name]
+	if !ok {
+		data = &storageData{Version: root.data.Version}
+		if root.data.Tenants == nil {
+			root.data.Tenants = make(map[string]*storageData)
+		}
+		root.data.Tenants[name] = data
+	}
+	return &Storage{sem: root.sem, path: root.path, data: data, root: root, aead: root.aead}
+}
+
+// marshal returns data as JSON, its messages encrypted
+func (db *Storage) marshal(data storageData) ([]byte, error) {
+	data, err := convertMessages(canonicalTimes(data), func(s string) (string, error) { return encrypt(db.aead, s) })
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(data, "", "  ")
+}
+
+// canonicalTimes returns a copy of data with every time in UTC, so
+// they are all written in the same format, RFC 3339 in UTC
+func canonicalTimes(data storageData) storageData {
+	if data.Incidents != nil {
+		incidents := make([]Incident, len(data.Incidents))
+		for i, inc := range data.Incidents {
+			inc.Start, inc.End, inc.AckedAt = inc.Start.UTC(), inc.End.UTC(), inc.AckedAt.UTC()
+			if inc.Updates != nil {
+				updates := make([]IncidentUpdate, len(inc.Updates))
+				for j, u := range inc.Updates {
+					u.Time = u.Time.UTC()
+					updates[j] = u
+				}
+				inc.Updates = updates
+			}
+			incidents[i] = inc
+		}
+		data.Incidents = incidents
+	}
+	if data.Services != nil {
+		services := make([]ServiceInfo, len(data.Services))
+		for i, s := range data.Services {
+			s.Created, s.Removed = s.Created.UTC(), s.Removed.UTC()
+			services[i] = s
+		}
+		data.Services = services
+	}
+	if data.LastAlerts != nil {
+		lastAlerts := make(map[string]time.Time, len(data.LastAlerts))
+		for s, t := range data.LastAlerts {
+			lastAlerts[s] = t.UTC()
+		}
+		data.LastAlerts = lastAlerts
+	}
+	if data.Deliveries != nil {
+		deliveries := make([]AlertDelivery, len(data.Deliveries))
+		for i, d := range data.Deliveries {
+			d.Time = d.Time.UTC()
+			deliveries[i] = d
+		}
+		data.Deliveries = deliveries
+	}
+	if data.Maintenances != nil {
+		maintenances := make([]ScheduledMaintenance, len(data.Maintenances))
+		for i, m := range data.Maintenances {
+			m.Start, m.End = m.Start.UTC(), m.End.UTC()
+			maintenances[i] = m
+		}
+		data.Maintenances = maintenances
+	}
+	if data.Subscribers != nil {
+		subscribers := make([]Subscriber, len(data.Subscribers))
+		for i, s := range data.Subscribers {
+			s.Created = s.Created.UTC()
+			subscribers[i] = s
+		}
+		data.Subscribers = subscribers
+	}
+	if data.Statuses != nil {
+		statuses := make([]StatusRecord, len(data.Statuses))
+		for i, r := range data.Statuses {
+			r.Time = r.Time.UTC()
+			statuses[i] = r
+		}
+		data.Statuses = statuses
+	}
+	if data.Rollups != nil {
+		rollups := make([]StatusRollup, len(data.Rollups))
+		for i, r := range data.Rollups {
+			r.Start = r.Start.UTC()
+			rollups[i] = r
+		}
+		data.Rollups = rollups
+	}
+	if data.RolledUpUntil != nil {
+		until := make(map[time.Duration]time.Time, len(data.RolledUpUntil))
+		for p, t := range data.RolledUpUntil {
+			until[p] = t.UTC()
+		}
+		data.RolledUpUntil = until
+	}
+	if data.Tenants != nil {
+		tenants := make(map[string]*storageData, len(data.Tenants))
+		for name, t := range data.Tenants {
+			c := canonicalTimes(*t)
+			tenants[name] = &c
+		}
+		data.Tenants = tenants
+	}
+	return data
+}
+
+// unmarshal returns the data in b, its messages decrypted
+func (db *Storage) unmarshal(b []byte) (storageData, error) {
+	var data storageData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return data, err
+	}
+	return convertMessages(data, func(s string) (string, error) { return decrypt(db.aead, s) })
+}
+
+// migrate applies the storage migrations the data is missing
+func (db *Storage) migrate() error {
+	from := db.data.Version
+	if from > len(storageMigrations) {
+		return ErrStorageTooNew
+	}
+	if from == len(storageMigrations) {
+		return nil
+	}
+	for _, m := range storageMigrations[from:] {
+		m(db.data)
+		for _, t := range db.data.Tenants {
+			m(t)
+		}
+	}
+	db.data.Version = len(storageMigrations)
+	for _, t := range db.data.Tenants {
+		t.Version = db.data.Version
+	}
+	log.Printf("storage: migrated %s from version %d to %d", db.path, from, db.data.Version)
+	return db.save()
+}
+
+// StartIncident opens an incident for a service, or updates the state,
+// severity and message of its ongoing incident
+func (db *Storage) StartIncident(ctx context.Context, service string, state State, severity Severity, message string, t time.Time) (Incident, error) {
+	if err := db.lock(ctx); err != nil {
+		return Incident{}, err
+	}
+	defer db.unlock()
+
+	if i := db.ongoing(service); i >= 0 {
+		inc := &db.data.Incidents[i]
+		inc.State = state
+		inc.Message = message
+		if severity.AtLeast(inc.Severity) {
+			inc.Severity = severity
+		}
+		return *inc, db.save()
+	}
+
+	db.data.NextID++
+	inc := Incident{
+		ID:       strconv.Itoa(db.data.NextID),
+		Service:  service,
+		State:    state,
+		Severity: severity,
+		Message:  message,
+		Start:    t,
+	}
+	db.data.Incidents = append(db.data.Incidents, inc)
+	return inc, db.save()
+}
+
+// ResolveIncident ends the ongoing incident of a service and returns
+// it, false when there is none
+func (db *Storage) ResolveIncident(ctx context.Context, service string, t time.Time) (Incident, bool, error) {
+	if err := db.lock(ctx); err != nil {
+		return Incident{}, false, err
+	}
+	defer db.unlock()
+
+	i := db.ongoing(service)
+	if i < 0 {
+		return Incident{}, false, nil
+	}
+	db.data.Incidents[i].End = t
+	return db.data.Incidents[i], true, db.save()
+}
+
+// OngoingIncident returns the ongoing incident of a service
+func (db *Storage) OngoingIncident(ctx context.Context, service string) (Incident, bool, error) {
+	if err := db.lock(ctx); err != nil {
+		return Incident{}, false, err
+	}
+	defer db.unlock()
+	if i := db.ongoing(service); i >= 0 {
+		return db.data.Incidents[i], true, nil
+	}
+	return Incident{}, false, nil
+}
+
+// Incident returns the incident with the given ID
+func (db *Storage) Incident(ctx context.Context, id string) (Incident, bool, error) {
+	if err := db.lock(ctx); err != nil {
+		return Incident{}, false, err
+	}
+	defer db.unlock()
+	for _, inc := range db.data.Incidents {
+		if inc.ID == id {
+			return inc, true, nil
+		}
+	}
+	return Incident{}, false, nil
+}
+
+// Incidents returns every incident, oldest first
+func (db *Storage) Incidents(ctx context.Context) ([]Incident, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	return append([]Incident(nil), db.data.Incidents...), nil
+}
+
+// IncidentsBetween returns the incidents which were ongoing at
+// some point between start and end, oldest first
+func (db *Storage) IncidentsBetween(ctx context.Context, start, end time.Time) ([]Incident, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	var incidents []Incident
+	for _, inc := range db.data.Incidents {
+		if inc.Start.Before(end) && (inc.Ongoing() || inc.End.After(start)) {
+			incidents = append(incidents, inc)
+		}
+	}
+	return incidents, nil
+}
+
+// IncidentsPage returns at most limit incidents selected by the
+// filter, newest first, skipping the first offset, and how many it
+// selects in all
+func (db *Storage) IncidentsPage(ctx context.Context, f IncidentFilter, offset, limit int) ([]Incident, int, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer db.unlock()
+	total := 0
+	incidents := []Incident{}
+	for i := len(db.data.Incidents) - 1; i >= 0; i-- {
+		inc := db.data.Incidents[i]
+		if !f.Matches(inc) {
+			continue
+		}
+		if total >= offset && len(incidents) < limit {
+			incidents = append(incidents, inc)
+		}
+		total++
+	}
+	return incidents, total, nil
+}
+
+// Uptime returns the percentage of the period between start and end
+// a service did not spend in a down incident
+func Uptime(ctx context.Context, db StorageBackend, service string, start, end time.Time) (float64, error) {
+	if !end.After(start) {
+		return 100, nil
+	}
+	incidents, err := db.IncidentsBetween(ctx, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return uptime(incidents, service, start, end), nil
+}
+
+// uptime returns the percentage of the period between start and end
+// a service did not spend in one of the down incidents
+func uptime(incidents []Incident, service string, start, end time.Time) float64 {
+	period := end.Sub(start)
+	if period <= 0 {
+		return 100
+	}
+
+	var down time.Duration
+	for _, inc := range incidents {
+		if inc.Service != service || inc.State != StateDown {
+			continue
+		}
+		from, to := inc.Start, inc.End
+		if from.Before(start) {
+			from = start
+		}
+		if inc.Ongoing() || to.After(end) {
+			to = end
+		}
+		// incidents outside the period count for nothing
+		if to.After(from) {
+			down += to.Sub(from)
+		}
+	}
+	return 100 * float64(period-down) / float64(period)
+}
+
+// SetEscalations records how many escalation tiers have been
+// notified of an incident
+func (db *Storage) SetEscalations(ctx context.Context, id string, n int) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	for i := range db.data.Incidents {
+		if db.data.Incidents[i].ID == id {
+			db.data.Incidents[i].Escalations = n
+			return db.save()
+		}
+	}
+	return nil
+}
+
+// Acknowledge records who acknowledged an ongoing incident
+func (db *Storage) Acknowledge(ctx context.Context, id, by string, t time.Time) (Incident, error) {
+	if err := db.lock(ctx); err != nil {
+		return Incident{}, err
+	}
+	defer db.unlock()
+	for i := range db.data.Incidents {
+		inc := &db.data.Incidents[i]
+		if inc.ID != id {
+			continue
+		}
+		if !inc.Ongoing() {
+			return *inc, ErrIncidentResolved
+		}
+		inc.AckedBy = by
+		inc.AckedAt = t
+		return *inc, db.save()
+	}
+	return Incident{}, ErrIncidentNotFound
+}
+
+// OpenIncident records a manual incident, assigning its ID
+func (db *Storage) OpenIncident(ctx context.Context, inc Incident) (Incident, error) {
+	if err := db.lock(ctx); err != nil {
+		return Incident{}, err
+	}
+	defer db.unlock()
+	db.data.NextID++
+	inc.ID = strconv.Itoa(db.data.NextID)
+	inc.Manual = true
+	db.data.Incidents = append(db.data.Incidents, inc)
+	return inc, db.save()
+}
+
+// CloseIncident resolves the incident with the given ID
+func (db *Storage) CloseIncident(ctx context.Context, id string, t time.Time) (Incident, error) {
+	if err := db.lock(ctx); err != nil {
+		return Incident{}, err
+	}
+	defer db.unlock()
+	for i := range db.data.Incidents {
+		inc := &db.data.Incidents[i]
+		if inc.ID != id {
+			continue
+		}
+		if !inc.Ongoing() {
+			return *inc, ErrIncidentResolved
+		}
+		inc.End = t
+		return *inc, db.save()
+	}
+	return Incident{}, ErrIncidentNotFound
+}
+
+// AddIncidentUpdate adds an update to an incident, ongoing or
+// resolved
+func (db *Storage) AddIncidentUpdate(ctx context.Context, id string, u IncidentUpdate) (Incident, error) {
+	if strings.TrimSpace(u.Message) == "" {
+		return Incident{}, ErrEmptyUpdate
+	}
+	if err := db.lock(ctx); err != nil {
+		return Incident{}, err
+	}
+	defer db.unlock()
+	for i := range db.data.Incidents {
+		inc := &db.data.Incidents[i]
+		if inc.ID == id {
+			inc.Updates = append(inc.Updates, u)
+			return *inc, db.save()
+		}
+	}
+	return Incident{}, ErrIncidentNotFound
+}
+
+// SetPostmortem sets the postmortem of an incident, removing it
+// when empty
+func (db *Storage) SetPostmortem(ctx context.Context, id, postmortem string) (Incident, error) {
+	if err := db.lock(ctx); err != nil {
+		return Incident{}, err
+	}
+	defer db.unlock()
+	for i := range db.data.Incidents {
+		inc := &db.data.Incidents[i]
+		if inc.ID == id {
+			inc.Postmortem = postmortem
+			return *inc, db.save()
+		}
+	}
+	return Incident{}, ErrIncidentNotFound
+}
+
+// RecordStatus keeps the outcome of checks in the history
+func (db *Storage) RecordStatus(ctx context.Context, records ...StatusRecord) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	db.data.Statuses = append(db.data.Statuses, records...)
+	return db.save()
+}
+
+// StatusHistory returns the checks of a service, or of every
+// service when empty, between start and end, oldest first
+func (db *Storage) StatusHistory(ctx context.Context, service string, start, end time.Time) ([]StatusRecord, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	var history []StatusRecord
+	for _, r := range db.data.Statuses {
+		if (service == "" || r.Service == service) && !r.Time.Before(start) && r.Time.Before(end) {
+			history = append(history, r)
+		}
+	}
+	return history, nil
+}
+
+// StatusHistoryPage returns at most limit checks of a service, or of
+// every service when empty, between start and end, newest first,
+// skipping the first offset, and how many there are in all
+func (db *Storage) StatusHistoryPage(ctx context.Context, service string, start, end time.Time, offset, limit int) ([]StatusRecord, int, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer db.unlock()
+	total := 0
+	history := []StatusRecord{}
+	for i := len(db.data.Statuses) - 1; i >= 0; i-- {
+		r := db.data.Statuses[i]
+		if (service != "" && r.Service != service) || r.Time.Before(start) || !r.Time.Before(end) {
+			continue
+		}
+		if total >= offset && len(history) < limit {
+			history = append(history, r)
+		}
+		total++
+	}
+	return history, total, nil
+}
+
+// SaveRollups keeps the rollups of a period and records it is
+// rolled up until then
+func (db *Storage) SaveRollups(ctx context.Context, period time.Duration, until time.Time, rollups []StatusRollup) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	db.data.Rollups = append(db.data.Rollups, rollups...)
+	if db.data.RolledUpUntil == nil {
+		db.data.RolledUpUntil = make(map[time.Duration]time.Time)
+	}
+	db.data.RolledUpUntil[period] = until
+	return db.save()
+}
+
+// RolledUpUntil returns where the rollups of a period end, the
+// zero time when there are none
+func (db *Storage) RolledUpUntil(ctx context.Context, period time.Duration) (time.Time, error) {
+	if err := db.lock(ctx); err != nil {
+		return time.Time{}, err
+	}
+	defer db.unlock()
+	return db.data.RolledUpUntil[period], nil
+}
+
+// Rollups returns the rollups of a service over a period starting
+// between start and end, oldest first
+func (db *Storage) Rollups(ctx context.Context, service string, period time.Duration, start, end time.Time) ([]StatusRollup, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	var rollups []StatusRollup
+	for _, r := range db.data.Rollups {
+		if r.Service == service && r.Period == period && !r.Start.Before(start) && r.Start.Before(end) {
+			rollups = append(rollups, r)
+		}
+	}
+	return rollups, nil
+}
+
+// SyncServices records the configured services at now, moving the
+// history of renamed ones to their new name and flagging the ones no
+// longer configured as removed
+func (db *Storage) SyncServices(ctx context.Context, services []ServiceInfo, now time.Time) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	index := func(name string) int {
+		for i, s := range db.data.Services {
+			if s.Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	configured := make(map[string]bool)
+	for _, s := range services {
+		configured[s.Name] = true
+		i := index(s.Name)
+		for _, old := range s.RenamedFrom {
+			if i >= 0 {
+				break
+			}
+			if i = index(old); i >= 0 {
+				db.rename(old, s.Name)
+			}
+		}
+		if i < 0 {
+			s.Created = now
+			db.data.Services = append(db.data.Services, s)
+			continue
+		}
+		s.Created = db.data.Services[i].Created
+		db.data.Services[i] = s
+	}
+	for i, s := range db.data.Services {
+		if !configured[s.Name] && !s.IsRemoved() {
+			db.data.Services[i].Removed = now
+		}
+	}
+	return db.save()
+}
+
+// rename moves the history of a service to its new name
+func (db *Storage) rename(old, name string) {
+	for i := range db.data.Incidents {
+		inc := &db.data.Incidents[i]
+		if inc.Service == old {
+			inc.Service = name
+		}
+		if len(inc.Services) > 0 {
+			services := make([]string, len(inc.Services))
+			for j, s := range inc.Services {
+				if s == old {
+					s = name
+				}
+				services[j] = s
+			}
+			inc.Services = services
+		}
+	}
+	for i := range db.data.Statuses {
+		if db.data.Statuses[i].Service == old {
+			db.data.Statuses[i].Service = name
+		}
+	}
+	for i := range db.data.Rollups {
+		if db.data.Rollups[i].Service == old {
+			db.data.Rollups[i].Service = name
+		}
+	}
+	for i := range db.data.Deliveries {
+		if db.data.Deliveries[i].Service == old {
+			db.data.Deliveries[i].Service = name
+		}
+	}
+	if t, ok := db.data.LastAlerts[old]; ok {
+		db.data.LastAlerts[name] = t
+		delete(db.data.LastAlerts, old)
+	}
+}
+
+// Services returns the services configured now or before, sorted by
+// name
+func (db *Storage) Services(ctx context.Context) ([]ServiceInfo, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	services := append([]ServiceInfo{}, db.data.Services...)
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+// SetLastAlert records when a service was last alerted on
+func (db *Storage) SetLastAlert(ctx context.Context, service string, t time.Time) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	if db.data.LastAlerts == nil {
+		db.data.LastAlerts = make(map[string]time.Time)
+	}
+	db.data.LastAlerts[service] = t
+	return db.save()
+}
+
+// LastAlert returns when a service was last alerted on, the
+// zero time if never
+func (db *Storage) LastAlert(ctx context.Context, service string) (time.Time, error) {
+	if err := db.lock(ctx); err != nil {
+		return time.Time{}, err
+	}
+	defer db.unlock()
+	return db.data.LastAlerts[service], nil
+}
+
+// AddDelivery records the outcome of delivering an alert, assigning
+// its ID. Only the latest maxDeliveries are kept.
+func (db *Storage) AddDelivery(ctx context.Context, d AlertDelivery) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	db.data.NextDeliveryID++
+	d.ID = strconv.Itoa(db.data.NextDeliveryID)
+	db.data.Deliveries = append(db.data.Deliveries, d)
+	if n := len(db.data.Deliveries); n > maxDeliveries {
+		db.data.Deliveries = append([]AlertDelivery(nil), db.data.Deliveries[n-maxDeliveries:]...)
+	}
+	return db.save()
+}
+
+// Deliveries returns the recorded alert deliveries, oldest first
+func (db *Storage) Deliveries(ctx context.Context) ([]AlertDelivery, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	return append([]AlertDelivery(nil), db.data.Deliveries...), nil
+}
+
+// SaveMaintenance schedules a maintenance, assigning its ID, or
+// replaces the one with the same ID
+func (db *Storage) SaveMaintenance(ctx context.Context, sm ScheduledMaintenance) (ScheduledMaintenance, error) {
+	if err := db.lock(ctx); err != nil {
+		return ScheduledMaintenance{}, err
+	}
+	defer db.unlock()
+	if sm.ID == "" {
+		db.data.NextMaintenanceID++
+		sm.ID = strconv.Itoa(db.data.NextMaintenanceID)
+		db.data.Maintenances = append(db.data.Maintenances, sm)
+		return sm, db.save()
+	}
+	for i := range db.data.Maintenances {
+		if db.data.Maintenances[i].ID == sm.ID {
+			db.data.Maintenances[i] = sm
+			return sm, db.save()
+		}
+	}
+	return ScheduledMaintenance{}, ErrMaintenanceNotFound
+}
+
+// RemoveMaintenance deletes the scheduled maintenance with the
+// given ID
+func (db *Storage) RemoveMaintenance(ctx context.Context, id string) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	for i, sm := range db.data.Maintenances {
+		if sm.ID == id {
+			db.data.Maintenances = append(db.data.Maintenances[:i:i], db.data.Maintenances[i+1:]...)
+			return db.save()
+		}
+	}
+	return ErrMaintenanceNotFound
+}
+
+// Maintenances returns the scheduled maintenance, oldest first
+func (db *Storage) Maintenances(ctx context.Context) ([]ScheduledMaintenance, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	return append([]ScheduledMaintenance(nil), db.data.Maintenances...), nil
+}
+
+// SaveNotifier adds a managed notifier, assigning its ID, or replaces
+// the one with the same ID
+func (db *Storage) SaveNotifier(ctx context.Context, mn ManagedNotifier) (ManagedNotifier, error) {
+	if err := db.lock(ctx); err != nil {
+		return ManagedNotifier{}, err
+	}
+	defer db.unlock()
+	if mn.ID == "" {
+		db.data.NextNotifierID++
+		mn.ID = strconv.Itoa(db.data.NextNotifierID)
+		db.data.Notifiers = append(db.data.Notifiers, mn)
+		return mn, db.save()
+	}
+	for i := range db.data.Notifiers {
+		if db.data.Notifiers[i].ID == mn.ID {
+			db.data.Notifiers[i] = mn
+			return mn, db.save()
+		}
+	}
+	return ManagedNotifier{}, ErrNotifierNotFound
+}
+
+// RemoveNotifier deletes the managed notifier with the given ID
+func (db *Storage) RemoveNotifier(ctx context.Context, id string) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	for i, mn := range db.data.Notifiers {
+		if mn.ID == id {
+			db.data.Notifiers = append(db.data.Notifiers[:i:i], db.data.Notifiers[i+1:]...)
+			return db.save()
+		}
+	}
+	return ErrNotifierNotFound
+}
+
+// ManagedNotifiers returns the managed notifiers, oldest first
+func (db *Storage) ManagedNotifiers(ctx context.Context) ([]ManagedNotifier, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	return append([]ManagedNotifier(nil), db.data.Notifiers...), nil
+}
+
+// SaveManagedService adds a managed service, assigning its ID, or
+// replaces the one with the same ID
+func (db *Storage) SaveManagedService(ctx context.Context, ms ManagedService) (ManagedService, error) {
+	if err := db.lock(ctx); err != nil {
+		return ManagedService{}, err
+	}
+	defer db.unlock()
+	if ms.ID == "" {
+		db.data.NextManagedServiceID++
+		ms.ID = strconv.Itoa(db.data.NextManagedServiceID)
+		db.data.ManagedServices = append(db.data.ManagedServices, ms)
+		return ms, db.save()
+	}
+	for i := range db.data.ManagedServices {
+		if db.data.ManagedServices[i].ID == ms.ID {
+			db.data.ManagedServices[i] = ms
+			return ms, db.save()
+		}
+	}
+	return ManagedService{}, ErrManagedServiceNotFound
+}
+
+// RemoveManagedService deletes the managed service with the given ID
+func (db *Storage) RemoveManagedService(ctx context.Context, id string) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	for i, ms := range db.data.ManagedServices {
+		if ms.ID == id {
+			db.data.ManagedServices = append(db.data.ManagedServices[:i:i], db.data.ManagedServices[i+1:]...)
+			return db.save()
+		}
+	}
+	return ErrManagedServiceNotFound
+}
+
+// ManagedServices returns the managed services, oldest first
+func (db *Storage) ManagedServices(ctx context.Context) ([]ManagedService, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	return append([]ManagedService(nil), db.data.ManagedServices...), nil
+}
+
+// SaveAPIKey adds an API key, assigning its ID, or replaces the one
+// with the same ID
+func (db *Storage) SaveAPIKey(ctx context.Context, k APIKey) (APIKey, error) {
+	if err := db.lock(ctx); err != nil {
+		return APIKey{}, err
+	}
+	defer db.unlock()
+	if k.ID == "" {
+		db.data.NextAPIKeyID++
+		k.ID = strconv.Itoa(db.data.NextAPIKeyID)
+		db.data.APIKeys = append(db.data.APIKeys, k)
+		return k, db.save()
+	}
+	for i := range db.data.APIKeys {
+		if db.data.APIKeys[i].ID == k.ID {
+			db.data.APIKeys[i] = k
+			return k, db.save()
+		}
+	}
+	return APIKey{}, ErrAPIKeyNotFound
+}
+
+// RemoveAPIKey deletes the API key with the given ID
+func (db *Storage) RemoveAPIKey(ctx context.Context, id string) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	for i, k := range db.data.APIKeys {
+		if k.ID == id {
+			db.data.APIKeys = append(db.data.APIKeys[:i:i], db.data.APIKeys[i+1:]...)
+			return db.save()
+		}
+	}
+	return ErrAPIKeyNotFound
+}
+
+// APIKeys returns the API keys, oldest first
+func (db *Storage) APIKeys(ctx context.Context) ([]APIKey, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	return append([]APIKey(nil), db.data.APIKeys...), nil
+}
+
+// SaveSubscriber adds a subscriber, assigning its ID, or replaces the
+// one with the same ID
+func (db *Storage) SaveSubscriber(ctx context.Context, s Subscriber) (Subscriber, error) {
+	if err := db.lock(ctx); err != nil {
+		return Subscriber{}, err
+	}
+	defer db.unlock()
+	if s.ID == "" {
+		db.data.NextSubscriberID++
+		s.ID = strconv.Itoa(db.data.NextSubscriberID)
+		db.data.Subscribers = append(db.data.Subscribers, s)
+		return s, db.save()
+	}
+	for i := range db.data.Subscribers {
+		if db.data.Subscribers[i].ID == s.ID {
+			db.data.Subscribers[i] = s
+			return s, db.save()
+		}
+	}
+	return Subscriber{}, ErrSubscriberNotFound
+}
+
+// RemoveSubscriber deletes the subscriber with the given ID
+func (db *Storage) RemoveSubscriber(ctx context.Context, id string) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	for i, s := range db.data.Subscribers {
+		if s.ID == id {
+			db.data.Subscribers = append(db.data.Subscribers[:i:i], db.data.Subscribers[i+1:]...)
+			return db.save()
+		}
+	}
+	return ErrSubscriberNotFound
+}
+
+// Subscribers returns the subscribers, oldest first
+func (db *Storage) Subscribers(ctx context.Context) ([]Subscriber, error) {
+	if err := db.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer db.unlock()
+	return append([]Subscriber(nil), db.data.Subscribers...), nil
+}
+
+// Prune removes the status history, alert deliveries and resolved
+// incidents older than the retention at now
+func (db *Storage) Prune(ctx context.Context, r Retention, now time.Time) (int, error) {
+	if err := db.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer db.unlock()
+
+	n := 0
+	if r.Status > 0 {
+		before := now.Add(-r.Status)
+		kept := db.data.Statuses[:0]
+		for _, s := range db.data.Statuses {
+			if s.Time.Before(before) {
+				n++
+				continue
+			}
+			kept = append(kept, s)
+		}
+		db.data.Statuses = kept
+	}
+	if r.Alerts > 0 {
+		before := now.Add(-r.Alerts)
+		kept := db.data.Deliveries[:0]
+		for _, d := range db.data.Deliveries {
+			if d.Time.Before(before) {
+				n++
+				continue
+			}
+			kept = append(kept, d)
+		}
+		db.data.Deliveries = kept
+	}
+	if r.Incidents > 0 {
+		before := now.Add(-r.Incidents)
+		kept := db.data.Incidents[:0]
+		for _, inc := range db.data.Incidents {
+			if !inc.Ongoing() && inc.End.Before(before) {
+				n++
+				continue
+			}
+			kept = append(kept, inc)
+		}
+		db.data.Incidents = kept
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, db.save()
+}
+
+// Backup writes the content of the storage to w as JSON, its
+// messages encrypted like in the file
+func (db *Storage) Backup(ctx context.Context, w io.Writer) error {
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	b, err := db.marshal(*db.data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Restore replaces the content of the storage with a backup, which
+// is migrated when written by an older version
+func (db *Storage) Restore(ctx context.Context, r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	data, err := db.unmarshal(b)
+	if err != nil {
+		return err
+	}
+	if data.Version > len(storageMigrations) {
+		return ErrStorageTooNew
+	}
+	if err := db.lock(ctx); err != nil {
+		return err
+	}
+	defer db.unlock()
+	// partitions already handed out keep reading their own data
+	tenants := db.data.Tenants
+	*db.data = data
+	for name, t := range tenants {
+		if restored, ok := data.Tenants[name]; ok {
+			*t = *restored
+		} else {
+			*t = storageData{Version: data.Version}
+		}
+		if db.data.Tenants == nil {
+			db.data.Tenants = make(map[string]*storageData)
+		}
+		db.data.Tenants[name] = t
+	}
+	if err := db.migrate(); err != nil {
+		return err
+	}
+	return db.save()
+}
+
+// Close does nothing, the file is written on every change
+func (db *Storage) Close() error {
+	return nil
+}
+
+// lock waits for the storage to be free, giving up with the error
+// of ctx once it is done
+func (db *Storage) lock(ctx context.Context) error {
+	select {
+	case db.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unlock frees the storage for the next query
+func (db *Storage) unlock() {
+	<-db.sem
+}
+
+// ongoing returns the index of the ongoing incident of a
+// service, -1 when there is none. Manual incidents are not the
+// incident of a service.
+func (db *Storage) ongoing(service string) int {
+	for i := len(db.data.Incidents) - 1; i >= 0; i-- {
+		if inc := db.data.Incidents[i]; inc.Service == service && !inc.Manual && inc.Ongoing() {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the data to a temporary file which replaces the
+// storage file, so a crash never leaves it half written
+func (db *Storage) save() error {
+	if db.root != nil {
+		return db.root.save()
+	}
+	if db.path == "" {
+		return nil
+	}
+	b, err := db.marshal(*db.data)
+	if err != nil {
+		return err
+	}
+	tmp := db.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, db.path)
+}
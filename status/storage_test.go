@@ -0,0 +1,225 @@
+package status
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorageIncidents(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "status.json")
+
+	db, err := OpenStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	first, _ := db.StartIncident(ctx, "api", StateDegraded, SeverityWarning, "slow", start)
+	second, _ := db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", start.Add(time.Minute))
+	if second.ID != first.ID || second.State != StateDown || second.Severity != SeverityCritical || !second.Start.Equal(start) {
+		t.Errorf("expected the ongoing incident updated got %+v", second)
+	}
+
+	db, err = OpenStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.OngoingIncident(ctx, "api"); !ok {
+		t.Fatal("expected the incident to be persisted")
+	}
+	inc, ok, _ := db.ResolveIncident(ctx, "api", start.Add(time.Hour))
+	if !ok || inc.Ongoing() {
+		t.Errorf("expected the incident resolved got %+v", inc)
+	}
+	if _, ok, _ := db.ResolveIncident(ctx, "api", start.Add(time.Hour)); ok {
+		t.Error("expected no ongoing incident")
+	}
+
+	third, _ := db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", start.Add(2*time.Hour))
+	if incidents, _ := db.Incidents(ctx); third.ID == first.ID || len(incidents) != 2 {
+		t.Errorf("expected a new incident got %+v", incidents)
+	}
+}
+
+func TestStorageContext(t *testing.T) {
+	db, _ := OpenStorage("")
+	if err := db.lock(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a query gives up waiting for a held storage
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := db.RecordStatus(ctx, StatusRecord{Service: "api"}); err != context.DeadlineExceeded {
+		t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+	if _, _, err := db.OngoingIncident(ctx, "api"); err != context.DeadlineExceeded {
+		t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+
+	db.unlock()
+	if err := db.RecordStatus(context.Background(), StatusRecord{Service: "api"}); err != nil {
+		t.Errorf("expected nil got %v", err)
+	}
+}
+
+// memoryStorage is a StorageBackend registered by tests, an
+// in-memory Storage which knows where it was opened
+type memoryStorage struct {
+	*Storage
+	location string
+}
+
+func TestRegisterStorage(t *testing.T) {
+	RegisterStorage("test-memory", func(location string) (StorageBackend, error) {
+		db, _ := OpenStorage("")
+		return &memoryStorage{Storage: db, location: location}, nil
+	})
+
+	db, err := OpenStorageBackend("test-memory", "mem://status")
+	if err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+	if m, ok := db.(*memoryStorage); !ok || m.location != "mem://status" {
+		t.Errorf("expected the registered backend at mem://status got %#v", db)
+	}
+	if db, err := OpenStorageBackend("", ""); err != nil || db == nil {
+		t.Errorf("expected the file backend by default got %v", err)
+	}
+	if _, err := OpenStorageBackend("gopher", ""); err != ErrUnknownStorage {
+		t.Errorf("expected %v got %v", ErrUnknownStorage, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	RegisterStorage("file", func(string) (StorageBackend, error) { return nil, nil })
+}
+
+func TestStorageMigrations(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tt := []struct {
+		name     string
+		content  string
+		expected error
+		severity Severity
+	}{
+		{"unversioned", `{"incidents": [{"id": "1", "service": "api", "state": "degraded"}]}`, nil, SeverityWarning},
+		{"current", `{"version": 1, "incidents": [{"id": "1", "service": "api", "state": "down", "severity": "info"}]}`, nil, SeverityInfo},
+		{"newer", `{"version": 99, "incidents": []}`, ErrStorageTooNew, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".json")
+			if err := ioutil.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			db, err := OpenStorage(path)
+			if err != tc.expected {
+				t.Fatalf("expected %v got %v", tc.expected, err)
+			}
+			if err != nil {
+				return
+			}
+			if inc, _, _ := db.Incident(ctx, "1"); inc.Severity != tc.severity {
+				t.Errorf("expected %v got %v", tc.severity, inc.Severity)
+			}
+
+			// the migrated file is saved at the current version
+			if db, err = OpenStorage(path); err != nil {
+				t.Fatal(err)
+			}
+			if db.data.Version != len(storageMigrations) {
+				t.Errorf("expected version %d got %d", len(storageMigrations), db.data.Version)
+			}
+		})
+	}
+}
+
+func TestStorageTimes(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "status.json")
+
+	// times written in their zone by an older version are rewritten
+	// in UTC
+	content := `{"version": 1, "incidents": [{"id": "1", "service": "api", "state": "down", "severity": "critical",
+		"start": "2020-01-01T02:00:00+02:00", "end": "0001-01-01T00:00:00Z", "acked_at": "0001-01-01T00:00:00Z"}]}`
+	ioutil.WriteFile(path, []byte(content), 0600)
+	db, err := OpenStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.RecordStatus(ctx, StatusRecord{Service: "api", Time: time.Date(2020, 1, 1, 3, 0, 0, 0, time.FixedZone("CET", 3600))})
+	b, _ := ioutil.ReadFile(path)
+	if !strings.Contains(string(b), `"start": "2020-01-01T00:00:00Z"`) || !strings.Contains(string(b), `"time": "2020-01-01T02:00:00Z"`) {
+		t.Errorf("expected the times in UTC got %s", b)
+	}
+
+	// a time which cannot be read fails rather than becoming zero
+	ioutil.WriteFile(path, []byte(`{"version": 2, "incidents": [{"id": "1", "start": "01/01/2020"}]}`), 0600)
+	if _, err := OpenStorage(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("expected an error naming the file got %v", err)
+	}
+}
+
+func TestStorageTenants(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "status.json")
+
+	db, _ := OpenStorage(path)
+	payments, search := db.Tenant("payments"), db.Tenant("search")
+	payments.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", time.Now())
+	search.RecordStatus(ctx, StatusRecord{Service: "api", State: StateUp, Time: time.Now()})
+
+	// every query is scoped to its partition
+	if _, ok, _ := search.OngoingIncident(ctx, "api"); ok {
+		t.Error("expected the incident of payments kept apart from search")
+	}
+	if _, ok, _ := db.OngoingIncident(ctx, "api"); ok {
+		t.Error("expected the incident of payments kept apart from the root")
+	}
+	if _, ok, _ := payments.Tenant("search").OngoingIncident(ctx, "api"); ok {
+		t.Error("expected the partitions of a partition to be those of the root")
+	}
+
+	// the partitions are kept in the file
+	db, err = OpenStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.Tenant("payments").OngoingIncident(ctx, "api"); !ok {
+		t.Error("expected the incident of payments kept across restarts")
+	}
+	if history, _ := db.Tenant("search").StatusHistory(ctx, "api", time.Time{}, time.Now()); len(history) != 1 {
+		t.Errorf("expected the history of search kept across restarts got %+v", history)
+	}
+}
@@ -0,0 +1,354 @@
+package status
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Errors returned by the email subscriptions
+var (
+	ErrInvalidMail        = errors.New("subscribe: mail needs the addr of an smtp server and a from address")
+	ErrInvalidEmail       = errors.New("subscribe: invalid email address")
+	ErrSubscriberNotFound = errors.New("subscribe: subscriber not found")
+)
+
+// Subscriber is an email address receiving the status updates once
+// it confirmed its subscription
+type Subscriber struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	// Token confirms the subscription and unsubscribes, in the
+	// links mailed to the subscriber
+	Token     string    `json:"token"`
+	Confirmed bool      `json:"confirmed"`
+	Created   time.Time `json:"created"`
+}
+
+// MailConfig configures the SMTP server mail is sent through
+type MailConfig struct {
+	// Addr is the host:port of the server
+	Addr     string `json:"addr"`
+	Username string `json:"username,omitempty"`
+	// Password may be "env:NAME"
+	Password string `json:"password,omitempty"`
+	From     string `json:"from"`
+}
+
+// Mailer sends a plain text mail
+type Mailer interface {
+	Mail(to, subject, body string) error
+}
+
+// SMTPMailer is a Mailer sending through an SMTP server
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from *mail.Address
+}
+
+// NewSMTPMailer returns a Mailer sending through the server of c
+func NewSMTPMailer(c MailConfig) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return nil, ErrInvalidMail
+	}
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return nil, ErrInvalidMail
+	}
+	m := &SMTPMailer{addr: c.Addr, from: from}
+	if c.Username != "" {
+		m.auth = smtp.PlainAuth("", c.Username, readSecret(c.Password), host)
+	}
+	return m, nil
+}
+
+// Mail sends a mail to an address
+func (m *SMTPMailer) Mail(to, subject, body string) error {
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return smtp.SendMail(m.addr, m.auth, m.from.Address, []string{to}, []byte(b.String()))
+}
+
+// Subscriptions keeps the email subscribers of a status page, with a
+// double opt-in, and mails them when incidents open and resolve and
+// when maintenance is scheduled
+type Subscriptions struct {
+	Storage StorageBackend
+	Mailer  Mailer
+	// BaseURL is where the status page is reached, to link the
+	// confirmation and the unsubscription
+	BaseURL string
+}
+
+// NewSubscriptions returns the Subscriptions kept in db, mailed with
+// mailer
+func NewSubscriptions(db StorageBackend, mailer Mailer, baseURL string) *Subscriptions {
+	return &Subscriptions{Storage: db, Mailer: mailer, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Subscribe adds an unconfirmed subscriber at now and mails it the
+// link confirming the subscription. Subscribing an unconfirmed
+// address again mails the link again, a confirmed one nothing.
+func (s *Subscriptions) Subscribe(ctx context.Context, email string, now time.Time) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return ErrInvalidEmail
+	}
+	subscribers, err := s.Storage.Subscribers(ctx)
+	if err != nil {
+		return err
+	}
+	var sub Subscriber
+	for _, existing := range subscribers {
+		if strings.EqualFold(existing.Email, addr.Address) {
+			sub = existing
+		}
+	}
+	if sub.Confirmed {
+		return nil
+	}
+	if sub.ID == "" {
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+		sub, err = s.Storage.SaveSubscriber(ctx, Subscriber{Email: addr.Address, Token: hex.EncodeToString(token), Created: now})
+		if err != nil {
+			return err
+		}
+	}
+	return s.Mailer.Mail(sub.Email, "Confirm your subscription",
+		"Confirm your subscription to the status updates:\n\n"+s.link("confirm", sub)+"\n\nIgnore this mail if you did not subscribe.\n")
+}
+
+// Confirm confirms the subscription of the subscriber with token
+func (s *Subscriptions) Confirm(ctx context.Context, token string) (Subscriber, error) {
+	sub, err := s.find(ctx, token)
+	if err != nil || sub.Confirmed {
+		return sub, err
+	}
+	sub.Confirmed = true
+	return s.Storage.SaveSubscriber(ctx, sub)
+}
+
+// Unsubscribe removes the subscriber with token
+func (s *Subscriptions) Unsubscribe(ctx context.Context, token string) error {
+	sub, err := s.find(ctx, token)
+	if err != nil {
+		return err
+	}
+	return s.Storage.RemoveSubscriber(ctx, sub.ID)
+}
+
+// find returns the subscriber with token
+func (s *Subscriptions) find(ctx context.Context, token string) (Subscriber, error) {
+	subscribers, err := s.Storage.Subscribers(ctx)
+	if err != nil {
+		return Subscriber{}, err
+	}
+	for _, sub := range subscribers {
+		if token != "" && sub.Token == token {
+			return sub, nil
+		}
+	}
+	return Subscriber{}, ErrSubscriberNotFound
+}
+
+// link returns the URL of an action on the subscription of sub
+func (s *Subscriptions) link(action string, sub Subscriber) string {
+	return s.BaseURL + "/subscribe/" + action + "?token=" + sub.Token
+}
+
+// Notify mails e to the confirmed subscribers when it opens or
+// resolves an incident or schedules maintenance, returning the last
+// error of the mails which failed
+func (s *Subscriptions) Notify(ctx context.Context, e Event) error {
+	subject, body := eventMail(e)
+	if subject == "" {
+		return nil
+	}
+	subscribers, err := s.Storage.Subscribers(ctx)
+	if err != nil {
+		return err
+	}
+	var last error
+	for _, sub := range subscribers {
+		if !sub.Confirmed {
+			continue
+		}
+		text := body + "\n" + s.BaseURL + "/\n\nUnsubscribe: " + s.link("unsubscribe", sub) + "\n"
+		if err := s.Mailer.Mail(sub.Email, subject, text); err != nil {
+			log.Printf("mail subscriber %s: %v", sub.ID, err)
+			last = err
+		}
+	}
+	return last
+}
+
+// eventMail returns the subject and body of the mail of an event,
+// no subject for events which are not mailed
+func eventMail(e Event) (string, string) {
+	const layout = "2006-01-02 15:04 MST"
+	switch {
+	case e.Type == EventIncidentStarted && e.Incident != nil:
+		inc := e.Incident
+		what := inc.Service + " is " + string(inc.State)
+		if inc.Manual {
+			what = inc.Title
+		}
+		body := what + " since " + inc.Start.UTC().Format(layout) + ".\n"
+		if len(inc.Services) > 0 {
+			body += "Affects " + strings.Join(inc.Services, ", ") + ".\n"
+		}
+		// the messages of checks are errors meant for operators
+		if inc.Manual && inc.Message != "" {
+			body += "\n" + inc.Message + "\n"
+		}
+		return what, body
+	case e.Type == EventIncidentResolved && e.Incident != nil:
+		inc := e.Incident
+		what := inc.Service + " recovered"
+		if inc.Manual {
+			what = "Resolved: " + inc.Title
+		}
+		return what, fmt.Sprintf("%s at %s, after %s.\n", what, inc.End.UTC().Format(layout), inc.End.Sub(inc.Start).Round(time.Minute))
+	case e.Type == EventMaintenanceScheduled && e.Maintenance != nil:
+		sm := e.Maintenance
+		body := fmt.Sprintf("Maintenance is scheduled from %s to %s", sm.Start.UTC().Format(layout), sm.End.UTC().Format(layout))
+		if len(sm.Services) > 0 {
+			body += " on " + strings.Join(sm.Services, ", ")
+		}
+		body += ".\n"
+		if sm.Description != "" {
+			body += "\n" + sm.Description + "\n"
+		}
+		return "Scheduled maintenance " + sm.Start.UTC().Format("2006-01-02"), body
+	}
+	return "", ""
+}
+
+// Run mails the subscribers the events published on bus. It does not
+// return.
+func (s *Subscriptions) Run(bus *EventBus) {
+	events, _ := bus.Subscribe(64)
+	for e := range events {
+		ctx, cancel := storageContext()
+		if err := s.Notify(ctx, e); err != nil {
+			log.Printf("mail subscribers: %v", err)
+		}
+		cancel()
+	}
+}
+
+// SubscribeHandler is a HandlerFunc which subscribes the email form
+// field (POST /subscribe), confirms a subscription (GET
+// /subscribe/confirm?token=) and unsubscribes (GET or POST
+// /subscribe/unsubscribe?token=)
+func SubscribeHandler(s *Subscriptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/subscribe"), "/")
+		token := r.URL.Query().Get("token")
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		var message string
+		var err error
+		switch {
+		case action == "" && r.Method == http.MethodPost:
+			err = s.Subscribe(ctx, r.FormValue("email"), time.Now())
+			message = "Check your inbox to confirm the subscription."
+		case action == "confirm" && r.Method == http.MethodGet:
+			_, err = s.Confirm(ctx, token)
+			message = "Subscribed to the status updates."
+		case action == "unsubscribe" && (r.Method == http.MethodGet || r.Method == http.MethodPost):
+			err = s.Unsubscribe(ctx, token)
+			message = "Unsubscribed from the status updates."
+		default:
+			allow := map[string]string{"": "POST", "confirm": "GET", "unsubscribe": "GET, POST"}
+			if _, ok := allow[action]; !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Allow", allow[action])
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch {
+		case err == ErrInvalidEmail:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err == ErrSubscriberNotFound:
+			http.NotFound(w, r)
+		case ctx.Err() != nil:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			fmt.Fprintln(w, message)
+		}
+	}
+}
+
+// SubscriberHandler is a HandlerFunc which lists the subscribers,
+// without their tokens (GET /api/subscribers/), or removes one
+// (DELETE /api/subscribers/{id}). Requests need token, which may be
+// "env:NAME", or an API key of the admin scope as a bearer token;
+// without either the API is disabled.
+func SubscriberHandler(s *Subscriptions, token string) http.HandlerFunc {
+	token = readSecret(token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !granted(r, token, ScopeAdmin) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/subscribers/")
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			subscribers, err := s.Storage.Subscribers(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			list := []Subscriber{}
+			for _, sub := range subscribers {
+				sub.Token = ""
+				list = append(list, sub)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodDelete && id != "":
+			if err := s.Storage.RemoveSubscriber(ctx, id); err == ErrSubscriberNotFound {
+				http.NotFound(w, r)
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sentMail is a mail sent by a recordingMailer
+type sentMail struct {
+	to, subject, body string
+}
+
+// recordingMailer is a Mailer which keeps the mail it sends
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+	err  error
+}
+
+func (m *recordingMailer) Mail(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentMail{to, subject, body})
+	return m.err
+}
+
+// token returns the token of the last link to action mailed
+func (m *recordingMailer) token(t *testing.T, action string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	body := m.sent[len(m.sent)-1].body
+	i := strings.Index(body, "/subscribe/"+action+"?token=")
+	if i < 0 {
+		t.Fatalf("expected a link to %s in %q", action, body)
+	}
+	return strings.Fields(body[i+len("/subscribe/"+action+"?token="):])[0]
+}
+
+func TestSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	mailer := &recordingMailer{}
+	s := NewSubscriptions(db, mailer, "https://status.example.com/")
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := s.Subscribe(ctx, "not an address", now); err != ErrInvalidEmail {
+		t.Fatalf("expected ErrInvalidEmail got %v", err)
+	}
+	if err := s.Subscribe(ctx, "Ops <ops@example.com>", now); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].to != "ops@example.com" {
+		t.Fatalf("expected a confirmation mail got %+v", mailer.sent)
+	}
+	token := mailer.token(t, "confirm")
+
+	// unconfirmed subscribers are not mailed
+	down := Incident{Service: "api", State: StateDown, Message: "dial tcp 10.0.0.1:443: timeout", Start: now}
+	s.Notify(ctx, Event{Type: EventIncidentStarted, Incident: &down})
+	if len(mailer.sent) != 1 {
+		t.Fatalf("expected no mail before confirming got %+v", mailer.sent)
+	}
+
+	if _, err := s.Confirm(ctx, "wrong"); err != ErrSubscriberNotFound {
+		t.Errorf("expected ErrSubscriberNotFound got %v", err)
+	}
+	if sub, err := s.Confirm(ctx, token); err != nil || !sub.Confirmed {
+		t.Fatalf("expected a confirmed subscriber got %+v, %v", sub, err)
+	}
+	// subscribing again once confirmed mails nothing
+	s.Subscribe(ctx, "OPS@example.com", now)
+	if subscribers, _ := db.Subscribers(ctx); len(subscribers) != 1 || len(mailer.sent) != 1 {
+		t.Errorf("expected one subscriber and mail got %+v, %+v", subscribers, mailer.sent)
+	}
+
+	s.Notify(ctx, Event{Type: EventIncidentStarted, Incident: &down})
+	s.Notify(ctx, Event{Type: EventStateChanged, Service: "api"})
+	s.Notify(ctx, Event{Type: EventMaintenanceScheduled, Maintenance: &ScheduledMaintenance{Start: now, End: now.Add(time.Hour), Services: []string{"db"}, Description: "upgrade"}})
+	if len(mailer.sent) != 3 {
+		t.Fatalf("expected 2 more mails got %+v", mailer.sent)
+	}
+	if m := mailer.sent[1]; m.subject != "api is down" || strings.Contains(m.body, "10.0.0.1") {
+		t.Errorf("unexpected incident mail %+v", m)
+	}
+	if m := mailer.sent[2]; !strings.Contains(m.body, "on db") || !strings.Contains(m.body, "upgrade") {
+		t.Errorf("unexpected maintenance mail %+v", m)
+	}
+
+	if err := s.Unsubscribe(ctx, mailer.token(t, "unsubscribe")); err != nil {
+		t.Fatal(err)
+	}
+	if subscribers, _ := db.Subscribers(ctx); len(subscribers) != 0 {
+		t.Errorf("expected no subscribers got %+v", subscribers)
+	}
+}
+
+func TestSubscribeHandler(t *testing.T) {
+	db, _ := OpenStorage("")
+	mailer := &recordingMailer{}
+	s := NewSubscriptions(db, mailer, "")
+	handler := SubscribeHandler(s)
+
+	tests := []struct {
+		method, path, email string
+		code                int
+	}{
+		{http.MethodPost, "/subscribe", "bad", http.StatusBadRequest},
+		{http.MethodPost, "/subscribe", "ops@example.com", http.StatusOK},
+		{http.MethodGet, "/subscribe", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/subscribe/confirm?token=wrong", "", http.StatusNotFound},
+		{http.MethodGet, "/subscribe/other", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(url.Values{"email": {tt.email}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected %d got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/subscribe/confirm?token="+mailer.token(t, "confirm"), nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 got %d", w.Code)
+	}
+
+	// the admin API lists subscribers without their tokens
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/subscribers/", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	SubscriberHandler(s, "secret")(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "ops@example.com") || strings.Contains(w.Body.String(), mailer.token(t, "confirm")) {
+		t.Errorf("unexpected list %d %s", w.Code, w.Body)
+	}
+
+	// a failing mail server answers an error
+	mailer.err = errors.New("connection refused")
+	r = httptest.NewRequest(http.MethodPost, "/subscribe", strings.NewReader("email=dev%40example.com"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 got %d", w.Code)
+	}
+}
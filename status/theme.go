@@ -0,0 +1,90 @@
+package status
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrInvalidTheme is returned for a theme whose colors are not CSS
+// colors
+var ErrInvalidTheme = errors.New("theme: colors must be hex colors such as #337ab7 or color names")
+
+// defaultTitle is the title of a page whose theme sets none
+const defaultTitle = "My Status"
+
+// themeColor matches the colors a theme may set, which are written in
+// the stylesheet of the page
+var themeColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// Theme brands the status page of an organization
+type Theme struct {
+	Title string `json:"title,omitempty"`
+	// LogoURL is shown in the header, FaviconURL as the icon of the
+	// page and HomepageURL is linked from the header
+	LogoURL     string `json:"logo_url,omitempty"`
+	FaviconURL  string `json:"favicon_url,omitempty"`
+	HomepageURL string `json:"homepage_url,omitempty"`
+	Colors      Colors `json:"colors,omitempty"`
+	// CSSURL is a stylesheet loaded after the others
+	CSSURL      string       `json:"css_url,omitempty"`
+	FooterLinks []FooterLink `json:"footer_links,omitempty"`
+	// Templates is a directory of templates replacing those of the
+	// templates dir with the same name, or their defined templates
+	Templates string `json:"templates,omitempty"`
+}
+
+// Colors is the palette of a theme. Empty colors keep the defaults.
+type Colors struct {
+	// Primary colors the header and the buttons
+	Primary string `json:"primary,omitempty"`
+	// Success, Warning, Danger and Maintenance color the states
+	// of the services
+	Success     string `json:"success,omitempty"`
+	Warning     string `json:"warning,omitempty"`
+	Danger      string `json:"danger,omitempty"`
+	Maintenance string `json:"maintenance,omitempty"`
+}
+
+// FooterLink is a link in the footer of the page
+type FooterLink struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Validate returns ErrInvalidTheme unless every color is a CSS color
+func (t Theme) Validate() error {
+	c := t.Colors
+	for _, color := range []string{c.Primary, c.Success, c.Warning, c.Danger, c.Maintenance} {
+		if color != "" && !themeColor.MatchString(color) {
+			return ErrInvalidTheme
+		}
+	}
+	return nil
+}
+
+// PageTitle returns the title of the page, "My Status" by default
+func (t Theme) PageTitle() string {
+	if t.Title == "" {
+		return defaultTitle
+	}
+	return t.Title
+}
+
+// LoadTemplateOverrides parses the templates of dir over those loaded
+// by LoadTemplate, so a theme replaces the templates it needs to
+func LoadTemplateOverrides(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.gohtml"))
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	t, err := tpl.Clone()
+	if err != nil {
+		return err
+	}
+	if t, err = t.ParseFiles(files...); err != nil {
+		return err
+	}
+	tpl = t
+	return nil
+}
@@ -0,0 +1,74 @@
+package status
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestThemeValidate(t *testing.T) {
+	tests := []struct {
+		colors Colors
+		err    error
+	}{
+		{Colors{}, nil},
+		{Colors{Primary: "#337ab7", Danger: "crimson", Success: "#0f0"}, nil},
+		{Colors{Primary: "red; } body { display: none"}, ErrInvalidTheme},
+		{Colors{Warning: "#12345g"}, ErrInvalidTheme},
+	}
+	for _, tt := range tests {
+		if err := (Theme{Colors: tt.colors}).Validate(); err != tt.err {
+			t.Errorf("%+v: expected %v got %v", tt.colors, tt.err, err)
+		}
+	}
+}
+
+func TestIndexTheme(t *testing.T) {
+	tpl = template.Must(template.ParseGlob("../templates/*.gohtml"))
+	dir, err := ioutil.TempDir("", "theme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	override := `{{ define "uptime" }}<em>custom uptime</em>{{ end }}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "uptime.gohtml"), []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadTemplateOverrides(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	theme := Theme{
+		Title:       "Acme Status",
+		LogoURL:     "https://acme.example.com/logo.png",
+		HomepageURL: "https://acme.example.com",
+		Colors:      Colors{Primary: "#ff6600"},
+		FooterLinks: []FooterLink{{Title: "Privacy", URL: "https://acme.example.com/privacy"}},
+	}
+	p := NewPage(theme.PageTitle(), []Result{{Service: &Service{Name: "web"}, State: StateUp, Uptime: []WindowUptime{{"24h", 100}}}})
+	p.Theme = theme
+	w := httptest.NewRecorder()
+	Index(NewPageStore(p))(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, s := range []string{
+		"<title>Acme Status</title>",
+		`src="https://acme.example.com/logo.png"`,
+		`href="https://acme.example.com"`,
+		"--primary: #ff6600;",
+		`<a href="https://acme.example.com/privacy">Privacy</a>`,
+		"<em>custom uptime</em>",
+		// states are told by text as well as color
+		"</span> Operational</span>",
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("expected %q in %s", s, w.Body)
+		}
+	}
+	if strings.Contains(w.Body.String(), "$MY_HOMEPAGE_URL") {
+		t.Errorf("expected the homepage placeholder to be replaced")
+	}
+}
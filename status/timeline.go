@@ -0,0 +1,186 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// timelineMonths is how many months a page of the timeline shows
+const timelineMonths = 3
+
+// TimelineEntry is an incident or a maintenance on the timeline, with
+// how long it lasted
+type TimelineEntry struct {
+	// Incident or Maintenance is set
+	Incident    *Incident
+	Maintenance *ScheduledMaintenance
+	Start, End  time.Time
+	Ongoing     bool
+	Duration    string
+}
+
+// TimelineMonth lists the entries started in a month, newest first
+type TimelineMonth struct {
+	Month   time.Time
+	Entries []TimelineEntry
+}
+
+// timelinePage is the data of the incident timeline
+type timelinePage struct {
+	Title  string
+	Theme  Theme
+	Months []TimelineMonth
+	// Newer and Older are the numbers of the adjacent pages, Older
+	// zero when there is nothing older
+	Page, Newer, Older int
+	Viewer             string
+	SignIn             bool
+}
+
+// Timeline returns the incidents and the scheduled maintenance started
+// in the timelineMonths months of a page, 0 for the months up to now,
+// newest first, and whether any started before them. The incidents
+// and maintenance of only private services are left out.
+func Timeline(ctx context.Context, db StorageBackend, maintenance []ScheduledMaintenance, private map[string]bool, page int, now time.Time) ([]TimelineMonth, bool, error) {
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	end := first.AddDate(0, 1-page*timelineMonths, 0)
+	start := end.AddDate(0, -timelineMonths, 0)
+	if page == 0 {
+		end = now
+	}
+
+	months := make([]TimelineMonth, timelineMonths)
+	for i := range months {
+		months[i].Month = start.AddDate(0, timelineMonths-1-i, 0)
+	}
+	newest := months[0].Month
+	add := func(e TimelineEntry) {
+		if e.Start.Before(start) || !e.Start.Before(end) {
+			return
+		}
+		i := (newest.Year()-e.Start.Year())*12 + int(newest.Month()-e.Start.Month())
+		months[i].Entries = append(months[i].Entries, e)
+	}
+
+	incidents, err := db.IncidentsBetween(ctx, start, end)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, inc := range publicIncidents(incidents, private) {
+		inc := inc
+		e := TimelineEntry{Incident: &inc, Start: inc.Start, End: inc.End, Ongoing: inc.Ongoing()}
+		if e.Ongoing {
+			e.Duration = formatDuration(now.Sub(inc.Start))
+		} else {
+			e.Duration = formatDuration(inc.End.Sub(inc.Start))
+		}
+		add(e)
+	}
+	older := false
+	for _, sm := range maintenance {
+		services, ok := publicServices(sm.Services, private)
+		if !ok || sm.Start.After(now) {
+			continue
+		}
+		sm := sm
+		sm.Services = services
+		older = older || sm.Start.Before(start)
+		e := TimelineEntry{Maintenance: &sm, Start: sm.Start, End: sm.End, Ongoing: sm.End.After(now)}
+		e.Duration = formatDuration(sm.End.Sub(sm.Start))
+		add(e)
+	}
+	for _, m := range months {
+		sort.SliceStable(m.Entries, func(i, j int) bool { return m.Entries[i].Start.After(m.Entries[j].Start) })
+	}
+
+	if !older {
+		// incidents of private services only do not make a page
+		for offset := 0; !older; offset += maxPageSize {
+			before, total, err := db.IncidentsPage(ctx, IncidentFilter{End: start}, offset, maxPageSize)
+			if err != nil {
+				return nil, false, err
+			}
+			older = len(publicIncidents(before, private)) > 0
+			if offset+maxPageSize >= total {
+				break
+			}
+		}
+	}
+	return months, older, nil
+}
+
+// formatDuration formats how long an incident or maintenance lasted to
+// the minute, such as "2h 5m" or "3d 4h"
+func formatDuration(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	days, hours, minutes := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case d < time.Minute:
+		return "less than a minute"
+	case days > 0 && hours > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case days > 0:
+		return fmt.Sprintf("%dd", days)
+	case hours > 0 && minutes > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// TimelineHandler is a HandlerFunc which renders the timeline of past
+// incidents and maintenance, grouped by month, for the viewer
+// (GET /incidents?page=N, the months up to now by default)
+func TimelineHandler(db StorageBackend, page *PageStore, mr *MaintenanceRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		n := 0
+		if s := r.URL.Query().Get("page"); s != "" {
+			var err error
+			if n, err = strconv.Atoi(s); err != nil || n < 0 {
+				http.Error(w, "page must be a number from 0", http.StatusBadRequest)
+				return
+			}
+		}
+
+		p := page.PageFor(r)
+		var private map[string]bool
+		if Viewer(r.Context()) == "" {
+			private = page.Page().privateServices()
+		}
+		var maintenance []ScheduledMaintenance
+		if mr != nil {
+			maintenance = mr.Scheduled()
+		}
+		ctx, cancel := requestContext(r)
+		defer cancel()
+		months, older, err := Timeline(ctx, db, maintenance, private, n, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		tp := timelinePage{
+			Title:  p.Title,
+			Theme:  p.Theme,
+			Months: months,
+			Page:   n,
+			Newer:  n - 1,
+			Viewer: Viewer(r.Context()),
+			SignIn: canLogin(r.Context()),
+		}
+		if older {
+			tp.Older = n + 1
+		}
+		tpl.ExecuteTemplate(w, "incidents.gohtml", tp)
+	}
+}
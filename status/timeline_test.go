@@ -0,0 +1,120 @@
+package status
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		30 * time.Second:                "less than a minute",
+		45 * time.Minute:                "45m",
+		2 * time.Hour:                   "2h",
+		2*time.Hour + 5*time.Minute:     "2h 5m",
+		3*24*time.Hour + 4*time.Hour:    "3d 4h",
+		2*24*time.Hour + 30*time.Minute: "2d",
+	} {
+		if got := formatDuration(d); got != expected {
+			t.Errorf("%v: expected %q got %q", d, expected, got)
+		}
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	now := time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC)
+	at := func(month time.Month, day int) time.Time { return time.Date(2024, month, day, 10, 0, 0, 0, time.UTC) }
+
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", at(time.May, 2))
+	db.ResolveIncident(ctx, "api", at(time.May, 2).Add(90*time.Minute))
+	inc, _ := db.OpenIncident(ctx, Incident{Manual: true, Title: "Slow checkout", Services: []string{"web"}, State: StateDegraded, Start: at(time.May, 10)})
+	db.AddIncidentUpdate(ctx, inc.ID, IncidentUpdate{Time: at(time.May, 10).Add(time.Hour), Message: "Fix deployed"})
+	db.StartIncident(ctx, "billing", StateDown, SeverityCritical, "timeout", at(time.March, 3))
+	db.ResolveIncident(ctx, "billing", at(time.March, 3).Add(time.Hour))
+	db.StartIncident(ctx, "api", StateDown, SeverityCritical, "timeout", at(time.January, 20))
+	db.ResolveIncident(ctx, "api", at(time.January, 20).Add(time.Hour))
+	maintenance := []ScheduledMaintenance{
+		{ID: "m1", Start: at(time.April, 1), End: at(time.April, 1).Add(2 * time.Hour), Description: "Database upgrade"},
+		{ID: "m2", Start: at(time.June, 1), End: at(time.June, 1).Add(time.Hour)},
+	}
+
+	months, older, err := Timeline(ctx, db, maintenance, nil, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 3 || months[0].Month.Month() != time.May || months[2].Month.Month() != time.March || !older {
+		t.Fatalf("expected March to May with older entries got %+v %v", months, older)
+	}
+	may := months[0].Entries
+	if len(may) != 2 || may[0].Incident.Title != "Slow checkout" || !may[0].Ongoing || may[0].Duration != "5d 2h" || len(may[0].Incident.Updates) != 1 {
+		t.Errorf("expected the ongoing incident first got %+v", may)
+	}
+	if may[1].Incident.Service != "api" || may[1].Duration != "1h 30m" {
+		t.Errorf("expected the resolved incident got %+v", may[1])
+	}
+	if april := months[1].Entries; len(april) != 1 || april[0].Maintenance == nil || april[0].Maintenance.ID != "m1" || april[0].Duration != "2h" {
+		t.Errorf("expected the past maintenance and not the upcoming one got %+v", april)
+	}
+	if march := months[2].Entries; len(march) != 1 || march[0].Incident.Service != "billing" {
+		t.Errorf("expected the billing incident got %+v", march)
+	}
+
+	months, older, _ = Timeline(ctx, db, maintenance, nil, 1, now)
+	if months[0].Month.Month() != time.February || months[2].Month.Month() != time.December || older {
+		t.Errorf("expected December to February got %+v %v", months, older)
+	}
+	if jan := months[1].Entries; len(jan) != 1 || jan[0].Incident.Service != "api" {
+		t.Errorf("expected the January incident got %+v", jan)
+	}
+
+	// the incidents of private services are left out
+	months, _, _ = Timeline(ctx, db, maintenance, map[string]bool{"billing": true}, 0, now)
+	if len(months[2].Entries) != 0 {
+		t.Errorf("expected the private incident left out got %+v", months[2].Entries)
+	}
+}
+
+func TestTimelineHandler(t *testing.T) {
+	tpl = template.Must(template.ParseGlob("../templates/*.gohtml"))
+	ctx := context.Background()
+	db, _ := OpenStorage("")
+	start := time.Now().Add(-2 * time.Hour)
+	db.StartIncident(ctx, "billing", StateDown, SeverityCritical, "card processor timeout", start)
+	db.ResolveIncident(ctx, "billing", start.Add(time.Hour))
+	mr := NewMaintenanceRegistry()
+	mr.scheduled = []ScheduledMaintenance{{ID: "m1", Start: start, End: start.Add(30 * time.Minute), Description: "Database upgrade"}}
+	page := NewPageStore(NewPage("Status", []Result{
+		{Service: &Service{Name: "web"}, State: StateUp},
+		{Service: &Service{Name: "billing", Private: true}, State: StateUp},
+	}))
+	handler := TimelineHandler(db, page, mr)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/incidents", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "Database upgrade") || !strings.Contains(body, start.Format("January 2006")) {
+		t.Errorf("expected the timeline got %d %s", w.Code, body)
+	}
+	if strings.Contains(body, "card processor timeout") {
+		t.Error("expected the incident of the private service hidden")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/incidents", nil)
+	w = httptest.NewRecorder()
+	handler(w, r.WithContext(context.WithValue(r.Context(), viewerKey{}, viewer{name: "ops"})))
+	if body := w.Body.String(); !strings.Contains(body, "card processor timeout") || !strings.Contains(body, "lasted 1h") {
+		t.Errorf("expected the private incident shown to the viewer got %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/incidents?page=-1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package status
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings breaks the latency of an HTTP check down into its
+// phases. Phases skipped on a reused connection are zero. Load is how
+// long a browser check took for the page to fire its load event.
+type Timings struct {
+	DNS     time.Duration `json:"dns"`
+	Connect time.Duration `json:"connect"`
+	TLS     time.Duration `json:"tls"`
+	TTFB    time.Duration `json:"ttfb"`
+	Load    time.Duration `json:"load,omitempty"`
+}
+
+func (t Timings) String() string {
+	if t.Load != 0 && t.TTFB == 0 {
+		return fmt.Sprintf("load %v", t.Load)
+	}
+	s := fmt.Sprintf("dns %v, connect %v, tls %v, ttfb %v", t.DNS, t.Connect, t.TLS, t.TTFB)
+	if t.Load != 0 {
+		s += fmt.Sprintf(", load %v", t.Load)
+	}
+	return s
+}
+
+// Timed is implemented by checkers which record the
+// Timings of their last request
+type Timed interface {
+	Timings() Timings
+}
+
+// timingRecorder records the Timings of traced requests. It is
+// embedded by the HTTP and browser checkers to implement Timed.
+type timingRecorder struct {
+	mu   sync.Mutex
+	last Timings
+}
+
+// Timings returns the timings of the last traced request
+func (r *timingRecorder) Timings() Timings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// set replaces the timings of the last check
+func (r *timingRecorder) set(t Timings) {
+	r.mu.Lock()
+	r.last = t
+	r.mu.Unlock()
+}
+
+// trace returns req with a ClientTrace recording its timings. The
+// hooks may run on dialer goroutines, so all state is guarded by mu.
+func (r *timingRecorder) trace(req *http.Request) *http.Request {
+	r.set(Timings{})
+
+	start := time.Now()
+	var dnsStart, connectStart, tlsStart time.Time
+	record := func(f func()) {
+		r.mu.Lock()
+		f()
+		r.mu.Unlock()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			record(func() { dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			record(func() { r.last.DNS = time.Since(dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			record(func() { connectStart = time.Now() })
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				record(func() { r.last.Connect = time.Since(connectStart) })
+			}
+		},
+		TLSHandshakeStart: func() {
+			record(func() { tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func() { r.last.TLS = time.Since(tlsStart) })
+		},
+		GotFirstResponseByte: func() {
+			record(func() { r.last.TTFB = time.Since(start) })
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
@@ -0,0 +1,85 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPingTimings(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	p := &Ping{Service: Service{URL: ts.URL}, client: ts.Client()}
+	if err := p.Status(); err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+
+	timings := p.Timings()
+	if timings.Connect <= 0 || timings.TLS <= 0 || timings.TTFB <= 0 {
+		t.Errorf("expected connect, tls and ttfb timings got %v", timings)
+	}
+	if timings.DNS != 0 {
+		t.Errorf("expected no dns lookup for an IP got %v", timings.DNS)
+	}
+}
+
+func TestMonitorRecordsTimings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	db, _ := OpenStorage("")
+	m := NewMonitor([]Pinger{&Ping{Service: Service{URL: ts.URL}}, &fakePinger{Service: Service{Name: "fake"}}}, nil)
+	m.History = db
+	start := time.Now()
+	results := m.CheckAllServices()
+	if results[0].Timings == nil || results[0].Timings.TTFB <= 0 {
+		t.Errorf("expected timings got %v", results[0].Timings)
+	}
+	if results[1].Timings != nil {
+		t.Errorf("expected nil got %v", results[1].Timings)
+	}
+
+	history, _ := db.StatusHistory(context.Background(), ts.URL, start, time.Now().Add(time.Second))
+	if len(history) != 1 || history[0].Timings == nil || *history[0].Timings != *results[0].Timings {
+		t.Errorf("expected the timings recorded got %+v", history)
+	}
+
+	w := httptest.NewRecorder()
+	HistoryHandler(db)(w, httptest.NewRequest(http.MethodGet, "/api/history", nil))
+	var list struct {
+		History []StatusRecord `json:"history"`
+	}
+	json.NewDecoder(w.Body).Decode(&list)
+	found := 0
+	for _, r := range list.History {
+		if r.Timings != nil {
+			found++
+		}
+	}
+	if len(list.History) != 2 || found != 1 {
+		t.Errorf("expected the timings of the ping returned got %+v", list.History)
+	}
+}
+
+func TestTimingsString(t *testing.T) {
+	tt := []struct {
+		name     string
+		timings  Timings
+		expected string
+	}{
+		{name: "http", timings: Timings{Connect: time.Millisecond, TTFB: 3 * time.Millisecond}, expected: "dns 0s, connect 1ms, tls 0s, ttfb 3ms"},
+		{name: "browser", timings: Timings{Load: 2 * time.Second}, expected: "load 2s"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.timings.String(); actual != tc.expected {
+				t.Errorf("expected %q got %q", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package status
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned serving the page over HTTPS
+var (
+	ErrTLSConfig     = errors.New("tls: set either cert_file and key_file, or acme")
+	ErrNoACMEDomains = errors.New("tls: acme needs at least one domain")
+	ErrNoCertificate = errors.New("tls: no certificate yet")
+)
+
+// letsEncryptURL is the directory of the ACME server of Let's Encrypt
+const letsEncryptURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+// RenewTimeout bounds a renewal of the certificate, so an ACME server
+// which never validates the order fails the renewal to be retried
+// later rather than hold it up for good
+const RenewTimeout = 10 * time.Minute
+
+// TLSConfig serves the page over HTTPS, with the certificate of
+// CertFile and KeyFile or one obtained from Let's Encrypt
+type TLSConfig struct {
+	// Addr is where the page is served over HTTPS, ":443" by default
+	Addr string `json:"addr,omitempty"`
+	// RedirectAddr serves plain HTTP, redirecting to HTTPS and
+	// answering the ACME challenges. It is ":80" by default with ACME
+	// and off otherwise.
+	RedirectAddr string      `json:"redirect_addr,omitempty"`
+	CertFile     string      `json:"cert_file,omitempty"`
+	KeyFile      string      `json:"key_file,omitempty"`
+	ACME         *ACMEConfig `json:"acme,omitempty"`
+}
+
+// ACMEConfig obtains and renews the certificate of the domains from
+// an ACME server, answering its http-01 challenges on RedirectAddr
+type ACMEConfig struct {
+	Domains []string `json:"domains"`
+	// Email is told of problems with the certificates
+	Email string `json:"email,omitempty"`
+	// CacheDir keeps the account key and the certificate across
+	// restarts, "acme" by default
+	CacheDir string `json:"cache_dir,omitempty"`
+	// DirectoryURL is the ACME server, Let's Encrypt by default. Its
+	// staging directory is worth trying first.
+	DirectoryURL string `json:"directory_url,omitempty"`
+	// RenewBefore is how long before it expires the certificate is
+	// renewed, "720h" by default
+	RenewBefore string `json:"renew_before,omitempty"`
+}
+
+// CertManager keeps the certificate the page is served with over
+// HTTPS, reloading its files or renewing it from ACME
+type CertManager struct {
+	// Addr and RedirectAddr are those of the config, or their defaults
+	Addr         string
+	RedirectAddr string
+
+	certFile, keyFile string
+	loaded            time.Time
+
+	acme        *acmeClient
+	domains     []string
+	cacheDir    string
+	renewBefore time.Duration
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewCertManager returns the CertManager of the config, loading the
+// certificate of its files or the one ACME issued before
+func NewCertManager(c TLSConfig) (*CertManager, error) {
+	files := c.CertFile != "" || c.KeyFile != ""
+	if files == (c.ACME != nil) || files && (c.CertFile == "" || c.KeyFile == "") {
+		return nil, ErrTLSConfig
+	}
+	m := &CertManager{Addr: c.Addr, RedirectAddr: c.RedirectAddr, certFile: c.CertFile, keyFile: c.KeyFile}
+	if m.Addr == "" {
+		m.Addr = ":443"
+	}
+	if files {
+		return m, m.Renew(context.Background())
+	}
+
+	if len(c.ACME.Domains) == 0 {
+		return nil, ErrNoACMEDomains
+	}
+	if m.RedirectAddr == "" {
+		m.RedirectAddr = ":80"
+	}
+	m.domains = c.ACME.Domains
+	m.cacheDir = c.ACME.CacheDir
+	if m.cacheDir == "" {
+		m.cacheDir = "acme"
+	}
+	m.renewBefore = 30 * 24 * time.Hour
+	if c.ACME.RenewBefore != "" {
+		d, err := time.ParseDuration(c.ACME.RenewBefore)
+		if err != nil {
+			return nil, err
+		}
+		m.renewBefore = d
+	}
+	if err := os.MkdirAll(m.cacheDir, 0700); err != nil {
+		return nil, err
+	}
+	key, err := loadACMEKey(filepath.Join(m.cacheDir, "acme_account.key"))
+	if err != nil {
+		return nil, err
+	}
+	directory := c.ACME.DirectoryURL
+	if directory == "" {
+		directory = letsEncryptURL
+	}
+	m.acme = newACMEClient(directory, c.ACME.Email, key)
+
+	// a certificate issued before is used until it is renewed
+	if b, err := ioutil.ReadFile(m.certPath()); err == nil {
+		if cert, err := parseCertificate(b, b); err == nil {
+			m.cert = cert
+		}
+	}
+	return m, nil
+}
+
+// certPath is where the certificate issued by ACME is cached, along
+// with its key
+func (m *CertManager) certPath() string {
+	return filepath.Join(m.cacheDir, strings.Replace(m.domains[0], "*", "_", -1)+".pem")
+}
+
+// parseCertificate parses a PEM certificate chain and key, keeping
+// the parsed leaf to tell when it expires
+func parseCertificate(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	return &cert, err
+}
+
+// GetCertificate returns the certificate to the TLS handshakes
+func (m *CertManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cert == nil {
+		return nil, ErrNoCertificate
+	}
+	return m.cert, nil
+}
+
+// TLSConfig returns the config of a server using the certificate
+func (m *CertManager) TLSConfig() *tls.Config {
+	return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: m.GetCertificate}
+}
+
+// Renew reloads the certificate files once they change, or obtains a
+// certificate from ACME when there is none or it is about to expire.
+// It is called on an interval so renewals are picked up.
+func (m *CertManager) Renew(ctx context.Context) error {
+	if m.acme == nil {
+		return m.reload()
+	}
+	m.mu.RLock()
+	cert := m.cert
+	m.mu.RUnlock()
+	if cert != nil && time.Until(cert.Leaf.NotAfter) > m.renewBefore {
+		return nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	chain, err := m.acme.obtain(ctx, m.domains, key)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if cert, err = parseCertificate(chain, keyPEM); err != nil {
+		return err
+	}
+	tmp := m.certPath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(keyPEM, chain...), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, m.certPath()); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.cert = cert
+	m.mu.Unlock()
+	return nil
+}
+
+// reload loads the certificate files when they changed since they
+// were last loaded
+func (m *CertManager) reload() error {
+	modified := time.Time{}
+	for _, name := range []string{m.certFile, m.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if fi.ModTime().After(modified) {
+			modified = fi.ModTime()
+		}
+	}
+	if !modified.After(m.loaded) {
+		return nil
+	}
+	certPEM, err := ioutil.ReadFile(m.certFile)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := ioutil.ReadFile(m.keyFile)
+	if err != nil {
+		return err
+	}
+	cert, err := parseCertificate(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.cert, m.loaded = cert, modified
+	m.mu.Unlock()
+	return nil
+}
+
+// RedirectHandler is a HandlerFunc which answers the ACME challenges
+// and redirects every other request to HTTPS
+func (m *CertManager) RedirectHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, acmeChallengePath) && m.acme != nil {
+			keyAuth, ok := m.acme.challenge(strings.TrimPrefix(r.URL.Path, acmeChallengePath))
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(keyAuth))
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if _, port, err := net.SplitHostPort(m.Addr); err == nil && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	}
+}
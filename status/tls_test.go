@@ -0,0 +1,290 @@
+package status
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCertificate returns the PEM certificate of a template for pub,
+// signed by parent and its key or self-signed when parent is nil
+func testCertificate(t *testing.T, template *x509.Certificate, pub interface{}, parent *x509.Certificate, key *ecdsa.PrivateKey) []byte {
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestNewCertManagerValidates(t *testing.T) {
+	for _, c := range []TLSConfig{
+		{},
+		{CertFile: "cert.pem"},
+		{CertFile: "cert.pem", KeyFile: "key.pem", ACME: &ACMEConfig{Domains: []string{"status.example.com"}}},
+	} {
+		if _, err := NewCertManager(c); err != ErrTLSConfig {
+			t.Errorf("expected ErrTLSConfig for %+v got %v", c, err)
+		}
+	}
+	if _, err := NewCertManager(TLSConfig{ACME: &ACMEConfig{}}); err != ErrNoACMEDomains {
+		t.Errorf("expected ErrNoACMEDomains got %v", err)
+	}
+}
+
+func TestCertManagerFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tls")
+	defer os.RemoveAll(dir)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(key)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "status.example.com"},
+		DNSNames:     []string{"status.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	ioutil.WriteFile(certFile, testCertificate(t, template, &key.PublicKey, nil, key), 0600)
+
+	m, err := NewCertManager(TLSConfig{CertFile: certFile, KeyFile: keyFile, Addr: ":8443"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := m.GetCertificate(nil)
+	if err != nil || cert.Leaf.Subject.CommonName != "status.example.com" {
+		t.Fatalf("expected the certificate of the files got %v", err)
+	}
+
+	// a renewed certificate is picked up
+	template.Subject.CommonName = "renewed"
+	ioutil.WriteFile(certFile, testCertificate(t, template, &key.PublicKey, nil, key), 0600)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if err := m.Renew(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cert, _ := m.GetCertificate(nil); cert.Leaf.Subject.CommonName != "renewed" {
+		t.Errorf("expected the renewed certificate got %s", cert.Leaf.Subject.CommonName)
+	}
+
+	w := httptest.NewRecorder()
+	m.RedirectHandler()(w, httptest.NewRequest(http.MethodGet, "http://status.example.com:8080/api/status?x=1", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://status.example.com:8443/api/status?x=1" {
+		t.Errorf("expected a redirect to HTTPS got %d %v", w.Code, w.Header())
+	}
+}
+
+// fakeACME is an ACME server checking the signatures of the requests
+// and the answers to its challenge
+type fakeACME struct {
+	*httptest.Server
+	t        *testing.T
+	answer   http.HandlerFunc
+	caKey    *ecdsa.PrivateKey
+	ca       *x509.Certificate
+	mu       sync.Mutex
+	account  *ecdsa.PublicKey
+	nonces   int
+	orders   int
+	badNonce bool
+	status   string
+	cert     []byte
+}
+
+func newFakeACME(t *testing.T) *fakeACME {
+	f := &fakeACME{t: t, status: "pending", badNonce: true}
+	f.caKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	f.ca = &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fake CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// verify returns the payload of a request signed by the account
+func (f *fakeACME) verify(r *http.Request) []byte {
+	var jws struct{ Protected, Payload, Signature string }
+	json.NewDecoder(r.Body).Decode(&jws)
+	p, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	var protected struct {
+		Alg, Nonce, URL, Kid string
+		JWK                  struct{ X, Y string }
+	}
+	json.Unmarshal(p, &protected)
+	if protected.URL != f.URL+r.URL.Path || protected.Nonce == "" {
+		f.t.Errorf("unexpected protected header %s", p)
+	}
+	if protected.JWK.X != "" {
+		x, _ := base64.RawURLEncoding.DecodeString(protected.JWK.X)
+		y, _ := base64.RawURLEncoding.DecodeString(protected.JWK.Y)
+		f.account = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	} else if protected.Kid != f.URL+"/account/1" {
+		f.t.Errorf("expected the account of the key got %q", protected.Kid)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+	sum := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	if f.account == nil || len(sig) != 64 || !ecdsa.Verify(f.account, sum[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		f.t.Errorf("expected a request signed by the account to %s", r.URL.Path)
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+	return payload
+}
+
+func (f *fakeACME) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nonces++
+	w.Header().Set("Replay-Nonce", "nonce-"+string(rune('a'+f.nonces%26)))
+	if r.URL.Path == "/directory" {
+		json.NewEncoder(w).Encode(acmeDirectory{NewNonce: f.URL + "/nonce", NewAccount: f.URL + "/account", NewOrder: f.URL + "/order"})
+		return
+	}
+	if r.URL.Path == "/nonce" {
+		return
+	}
+	payload := f.verify(r)
+	order := acmeOrder{Status: "pending", Authorizations: []string{f.URL + "/authz/1"}, Finalize: f.URL + "/finalize/1"}
+	switch r.URL.Path {
+	case "/account":
+		w.Header().Set("Location", f.URL+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status": "valid"}`))
+	case "/order":
+		if f.badNonce {
+			f.badNonce = false
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"type": "urn:ietf:params:acme:error:badNonce"}`))
+			return
+		}
+		f.orders++
+		w.Header().Set("Location", f.URL+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(order)
+	case "/authz/1":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     f.status,
+			"challenges": []map[string]string{{"type": "dns-01", "url": f.URL + "/challenge/2", "token": "dns"}, {"type": "http-01", "url": f.URL + "/challenge/1", "token": "tok"}},
+		})
+	case "/challenge/1":
+		// the server fetches the answer from the page
+		answer := httptest.NewRecorder()
+		f.answer(answer, httptest.NewRequest(http.MethodGet, "http://status.example.com"+acmeChallengePath+"tok", nil))
+		f.status = "invalid"
+		if strings.HasPrefix(answer.Body.String(), "tok.") {
+			f.status = "valid"
+		}
+		w.Write([]byte(`{}`))
+	case "/finalize/1":
+		var req struct{ CSR string }
+		json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil || csr.CheckSignature() != nil {
+			f.t.Errorf("invalid csr %v", err)
+			http.Error(w, "invalid csr", http.StatusBadRequest)
+			return
+		}
+		f.cert = testCertificate(f.t, &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      csr.Subject,
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		}, csr.PublicKey, f.ca, f.caKey)
+		order.Status = "processing"
+		json.NewEncoder(w).Encode(order)
+	case "/order/1":
+		order.Status, order.Certificate = "valid", f.URL+"/cert/1"
+		json.NewEncoder(w).Encode(order)
+	case "/cert/1":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(f.cert)
+		w.Write(testCertificate(f.t, f.ca, &f.caKey.PublicKey, nil, f.caKey))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestCertManagerACME(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "acme")
+	defer os.RemoveAll(dir)
+	f := newFakeACME(t)
+	defer f.Close()
+
+	config := TLSConfig{ACME: &ACMEConfig{
+		Domains:      []string{"status.example.com"},
+		Email:        "ops@example.com",
+		CacheDir:     dir,
+		DirectoryURL: f.URL + "/directory",
+	}}
+	m, err := NewCertManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Addr != ":443" || m.RedirectAddr != ":80" {
+		t.Errorf("expected the default addresses got %q %q", m.Addr, m.RedirectAddr)
+	}
+	m.acme.poll = time.Millisecond
+	f.answer = m.RedirectHandler()
+	if _, err := m.GetCertificate(nil); err != ErrNoCertificate {
+		t.Errorf("expected ErrNoCertificate got %v", err)
+	}
+
+	if err := m.Renew(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := m.GetCertificate(nil)
+	if err != nil || len(cert.Certificate) != 2 || cert.Leaf.DNSNames[0] != "status.example.com" {
+		t.Fatalf("expected the certificate of the domain got %v", err)
+	}
+	if _, ok := m.acme.challenge("tok"); ok {
+		t.Error("expected the answer to the challenge to be dropped")
+	}
+
+	// the certificate is kept across restarts until it is due
+	m, err = NewCertManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Renew(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cert, err := m.GetCertificate(nil); err != nil || f.orders != 1 || cert.Leaf.SerialNumber.Int64() != 2 {
+		t.Errorf("expected the cached certificate got %d orders %v", f.orders, err)
+	}
+
+	m.renewBefore = 100 * 24 * time.Hour
+	m.acme.poll = time.Millisecond
+	f.answer = func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("wrong")) }
+	f.status = "pending"
+	if err := m.Renew(context.Background()); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("expected a failed challenge got %v", err)
+	}
+}
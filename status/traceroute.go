@@ -0,0 +1,184 @@
+package status
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ErrNoIPv4Address is returned when tracing a host without one
+var ErrNoIPv4Address = errors.New("traceroute: no ipv4 address for host")
+
+const (
+	icmpEchoReply        = 0
+	icmpDestUnreachable  = 3
+	icmpEchoRequest      = 8
+	icmpTimeExceeded     = 11
+	tracerouteMaxHops    = 30
+	tracerouteHopTimeout = time.Second
+)
+
+// Hop is a single router on the path to a host. Addr is
+// empty when the hop did not answer.
+type Hop struct {
+	TTL  int
+	Addr string
+	RTT  time.Duration
+}
+
+// Traceroute sends ICMP echo requests with increasing TTLs to host and
+// returns the routers which answered, stopping at the destination. It
+// needs permission to open raw sockets (root or CAP_NET_RAW).
+func Traceroute(host string, maxHops int, timeout time.Duration) ([]Hop, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	var dst net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			dst = ip
+			break
+		}
+	}
+	if dst == nil {
+		return nil, ErrNoIPv4Address
+	}
+
+	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	id := os.Getpid() & 0xffff
+	buf := make([]byte, 1500)
+	var hops []Hop
+
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		if err := setTTL(conn, ttl); err != nil {
+			return hops, err
+		}
+
+		start := time.Now()
+		if _, err := conn.WriteTo(icmpEcho(id, ttl), &net.IPAddr{IP: dst}); err != nil {
+			return hops, err
+		}
+		conn.SetReadDeadline(start.Add(timeout))
+
+		hop := Hop{TTL: ttl}
+		reached := false
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			typ, ok := parseICMPReply(buf[:n], id, ttl)
+			if !ok {
+				continue
+			}
+			hop.Addr = peer.String()
+			hop.RTT = time.Since(start)
+			reached = typ != icmpTimeExceeded
+			break
+		}
+
+		hops = append(hops, hop)
+		if reached {
+			break
+		}
+	}
+
+	return hops, nil
+}
+
+// icmpEcho returns an ICMP echo request message
+func icmpEcho(id, seq int) []byte {
+	return icmpMessage(icmpEchoRequest, id, seq)
+}
+
+// icmpMessage returns an ICMP echo message of the given type
+func icmpMessage(typ, id, seq int) []byte {
+	b := []byte{byte(typ), 0, 0, 0, byte(id >> 8), byte(id), byte(seq >> 8), byte(seq), 's', 't', 'a', 't', 'u', 's'}
+	cs := icmpChecksum(b)
+	b[2], b[3] = byte(cs>>8), byte(cs)
+	return b
+}
+
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// parseICMPReply returns the type of an ICMP message and whether it
+// answers the echo request with the given id and sequence number.
+// Errors quote the IP header and first 8 bytes of the original request.
+func parseICMPReply(b []byte, id, seq int) (int, bool) {
+	if len(b) < 8 {
+		return 0, false
+	}
+
+	echo := b
+	switch b[0] {
+	case icmpEchoReply:
+	case icmpTimeExceeded, icmpDestUnreachable:
+		if len(b) < 8+20 {
+			return 0, false
+		}
+		ihl := int(b[8]&0x0f) * 4
+		echo = b[8+ihl:]
+		if len(echo) < 8 || echo[0] != icmpEchoRequest {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if int(echo[4])<<8|int(echo[5]) != id || int(echo[6])<<8|int(echo[7]) != seq {
+		return 0, false
+	}
+	return int(b[0]), true
+}
+
+// FormatHops returns a traceroute style report of hops
+func FormatHops(host string, hops []Hop) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "traceroute to %s", host)
+	for _, h := range hops {
+		if h.Addr == "" {
+			fmt.Fprintf(&b, "\n%2d  *", h.TTL)
+			continue
+		}
+		fmt.Fprintf(&b, "\n%2d  %s  %.3fms", h.TTL, h.Addr, float64(h.RTT)/float64(time.Millisecond))
+	}
+	return b.String()
+}
+
+// traceService runs a traceroute to the host of a service
+// and returns the hop report
+var traceService = func(s *Service) string {
+	host := s.URL
+	if u, err := url.Parse(s.URL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	hops, err := Traceroute(host, tracerouteMaxHops, tracerouteHopTimeout)
+	report := FormatHops(host, hops)
+	if err != nil {
+		report += fmt.Sprintf("\ntraceroute failed: %v", err)
+	}
+	return report
+}
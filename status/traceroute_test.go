@@ -0,0 +1,69 @@
+package status
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestICMPEchoChecksum(t *testing.T) {
+	if icmpChecksum(icmpEcho(0x1234, 7)) != 0 {
+		t.Error("expected checksum of message with checksum to be zero")
+	}
+}
+
+func TestParseICMPReply(t *testing.T) {
+	echo := icmpEcho(42, 3)
+	reply := append([]byte{icmpEchoReply}, echo[1:]...)
+
+	// time exceeded quotes a 20 byte IP header and the original echo
+	exceeded := append([]byte{icmpTimeExceeded, 0, 0, 0, 0, 0, 0, 0, 0x45}, make([]byte, 19)...)
+	exceeded = append(exceeded, echo[:8]...)
+
+	tt := []struct {
+		name   string
+		msg    []byte
+		seq    int
+		typ    int
+		expect bool
+	}{
+		{name: "echo reply", msg: reply, seq: 3, typ: icmpEchoReply, expect: true},
+		{name: "time exceeded", msg: exceeded, seq: 3, typ: icmpTimeExceeded, expect: true},
+		{name: "other sequence", msg: exceeded, seq: 4, expect: false},
+		{name: "echo request", msg: echo, seq: 3, expect: false},
+		{name: "truncated", msg: exceeded[:20], seq: 3, expect: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			typ, ok := parseICMPReply(tc.msg, 42, tc.seq)
+			if ok != tc.expect || (ok && typ != tc.typ) {
+				t.Errorf("expected %v/%d got %v/%d", tc.expect, tc.typ, ok, typ)
+			}
+		})
+	}
+}
+
+func TestFormatHops(t *testing.T) {
+	hops := []Hop{
+		{TTL: 1, Addr: "10.0.0.1", RTT: 1500 * time.Microsecond},
+		{TTL: 2},
+	}
+	expected := "traceroute to example.com\n 1  10.0.0.1  1.500ms\n 2  *"
+	if actual := FormatHops("example.com", hops); actual != expected {
+		t.Errorf("expected %q got %q", expected, actual)
+	}
+}
+
+func TestTracerouteLoopback(t *testing.T) {
+	hops, err := Traceroute("127.0.0.1", 3, time.Second)
+	if err != nil && strings.Contains(err.Error(), "permitted") {
+		t.Skipf("raw sockets unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("traceroute: %v", err)
+	}
+	if len(hops) != 1 || hops[0].Addr != "127.0.0.1" {
+		t.Errorf("expected a single loopback hop got %v", hops)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package status
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// setTTL sets the IP time to live of packets sent on conn
+func setTTL(conn net.PacketConn, ttl int) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.New("traceroute: connection does not support socket options")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,11 @@
+package status
+
+import (
+	"errors"
+	"net"
+)
+
+// setTTL is not supported on Windows
+func setTTL(conn net.PacketConn, ttl int) error {
+	return errors.New("traceroute: not supported on windows")
+}
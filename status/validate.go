@@ -0,0 +1,220 @@
+package status
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// Errors returned validating the settings of a check
+var (
+	ErrMissingURL       = errors.New("commands: check needs a url")
+	ErrInvalidURL       = errors.New("commands: url must be an absolute http or https url")
+	ErrMissingRegex     = errors.New("commands: grep check needs a regex")
+	ErrMissingSHA256    = errors.New("commands: checksum check needs the sha256 of the content, 64 hex digits")
+	ErrMissingContainer = errors.New("commands: docker check needs a container")
+	ErrMissingToken     = errors.New("commands: heartbeat check needs a token")
+	ErrMissingBucket    = errors.New("commands: s3 check needs a bucket")
+	ErrMissingDomain    = errors.New("commands: domain check needs a domain or a url")
+	ErrMissingClientID  = errors.New("commands: oauth2 check needs a client_id")
+	ErrIPFamilyIgnored  = errors.New("commands: ip_family is not supported by this type of check")
+	ErrIPFamilyBoth     = errors.New("commands: ip_family both is only supported by ping, tcp and icmp checks")
+	ErrDuplicateService = errors.New("commands: another service has this name")
+)
+
+// FieldError is a problem with a setting of the config, named by its
+// path such as "services[2].regex"
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// httpChecks are the types of check which request their url over HTTP
+var httpChecks = map[string]bool{
+	"ping":          true,
+	"grep":          true,
+	"graphql":       true,
+	"checksum":      true,
+	"elasticsearch": true,
+	"browser":       true,
+}
+
+// ipFamilyChecks are the types of check which honour ip_family, true
+// for those which also probe both families
+var ipFamilyChecks = map[string]bool{
+	"ping":          true,
+	"tcp":           true,
+	"icmp":          true,
+	"grep":          false,
+	"graphql":       false,
+	"checksum":      false,
+	"elasticsearch": false,
+	"s3":            false,
+	"oauth2":        false,
+}
+
+// ValidateService returns every problem with the settings of a
+// service: the fields its type needs, its urls and regex, and the
+// settings common to all services. The fields of the errors are
+// relative to the service.
+func ValidateService(s Service) []error {
+	var errs []error
+	field := func(name string, err error) {
+		errs = append(errs, &FieldError{Field: name, Err: err})
+	}
+
+	switch {
+	case s.Type == "":
+		field("type", ErrUnknownChecker)
+	case httpChecks[s.Type]:
+		if err := validateHTTPURL(s.URL); err != nil {
+			field("url", err)
+		}
+	}
+	switch s.Type {
+	case "grep":
+		if s.Regex == "" {
+			field("regex", ErrMissingRegex)
+		} else if _, err := regexp.Compile(s.Regex); err != nil {
+			field("regex", err)
+		}
+	case "checksum":
+		if b, err := hex.DecodeString(s.SHA256); err != nil || len(b) != sha256.Size {
+			field("sha256", ErrMissingSHA256)
+		}
+	case "tcp":
+		if host, port := probeHost(s); host == "" {
+			field("url", ErrMissingURL)
+		} else if port == "" {
+			field("port", ErrMissingPort)
+		}
+	case "icmp":
+		if host, _ := probeHost(s); host == "" {
+			field("url", ErrMissingURL)
+		}
+	case "script", "plugin":
+		if s.Command == "" {
+			field("command", ErrMissingCommand)
+		}
+	case "docker":
+		if s.Container == "" {
+			field("container", ErrMissingContainer)
+		}
+	case "heartbeat":
+		if s.Token == "" {
+			field("token", ErrMissingToken)
+		}
+	case "s3":
+		if s.Bucket == "" {
+			field("bucket", ErrMissingBucket)
+		}
+	case "domain":
+		if s.Domain == "" && s.URL == "" {
+			field("domain", ErrMissingDomain)
+		}
+	case "oauth2":
+		if s.TokenURL == "" {
+			field("token_url", ErrMissingTokenURL)
+		} else if err := validateHTTPURL(s.TokenURL); err != nil {
+			field("token_url", err)
+		}
+		if s.ClientID == "" {
+			field("client_id", ErrMissingClientID)
+		}
+	}
+	if both, ok := ipFamilyChecks[s.Type]; s.IPFamily != "" && s.Type != "" && !ok {
+		field("ip_family", ErrIPFamilyIgnored)
+	} else if s.IPFamily == "both" && ok && !both {
+		field("ip_family", ErrIPFamilyBoth)
+	}
+	if s.ProxyURL != "" {
+		if u, err := url.Parse(s.ProxyURL); err != nil || u.Host == "" {
+			field("proxy_url", ErrInvalidProxy)
+		}
+	}
+
+	for i, w := range s.Maintenance {
+		if err := w.Validate(); err != nil {
+			field(fmt.Sprintf("maintenance[%d]", i), err)
+		}
+	}
+	if err := s.Severity.Validate(); err != nil {
+		field("severity", err)
+	}
+	if s.SLO != nil {
+		if err := s.SLO.Validate(); err != nil {
+			field("slo", err)
+		}
+	}
+	if s.AlertCooldown != "" {
+		if _, err := time.ParseDuration(s.AlertCooldown); err != nil {
+			field("alert_cooldown", err)
+		}
+	}
+
+	// the checker rejects what is left, such as an unknown type or
+	// an unreadable client certificate
+	if len(errs) == 0 {
+		if _, err := NewChecker(s); err != nil {
+			field("type", fmt.Errorf("%s: %v", s.Type, err))
+		}
+	}
+	return errs
+}
+
+// ValidateServices returns every problem with the services of the
+// config, as ValidateService, and services sharing a name
+func ValidateServices(services []Service) []error {
+	var errs []error
+	seen := make(map[string]bool, len(services))
+	for i, s := range services {
+		prefix := fmt.Sprintf("services[%d]", i)
+		for _, err := range ValidateService(s) {
+			fe := err.(*FieldError)
+			errs = append(errs, &FieldError{Field: prefix + "." + fe.Field, Err: fe.Err})
+		}
+		if seen[s.ID()] {
+			errs = append(errs, &FieldError{Field: prefix + ".name", Err: ErrDuplicateService})
+		}
+		seen[s.ID()] = true
+	}
+	return errs
+}
+
+// ValidateNotifier returns the problem with the config of a
+// notifier: what NewNotifier rejects, or an invalid url
+func ValidateNotifier(c NotifierConfig) error {
+	if _, err := NewNotifier(c); err != nil {
+		return &FieldError{Field: "type", Err: fmt.Errorf("%s: %v", c.Type, err)}
+	}
+	if c.URL != "" && c.Type != "mqtt" {
+		if err := validateHTTPURL(c.URL); err != nil {
+			return &FieldError{Field: "url", Err: err}
+		}
+	}
+	return nil
+}
+
+// validateHTTPURL checks u is an absolute http or https url
+func validateHTTPURL(u string) error {
+	if u == "" {
+		return ErrMissingURL
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" || parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ErrInvalidURL
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package status
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateService(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	for _, tc := range []struct {
+		service  Service
+		expected []string
+	}{
+		{Service{Type: "ping", URL: "https://example.com"}, nil},
+		{Service{Type: "ping"}, []string{"url: commands: check needs a url"}},
+		{Service{Type: "ping", URL: "example.com"}, []string{"url: commands: url must be an absolute http or https url"}},
+		{Service{Type: "grep", URL: "http://example.com"}, []string{"regex: commands: grep check needs a regex"}},
+		{Service{Type: "grep", URL: "http://example.com", Regex: "a("}, []string{"regex: error parsing regexp: missing closing ): `a(`"}},
+		{Service{Type: "checksum", URL: "http://example.com", SHA256: "abc"}, []string{"sha256: " + ErrMissingSHA256.Error()}},
+		{Service{Type: "checksum", URL: "http://example.com", SHA256: sum}, nil},
+		{Service{Type: "tcp", URL: "db"}, []string{"port: commands: tcp check needs a port"}},
+		{Service{Type: "tcp", URL: "db", Port: "5432"}, nil},
+		{Service{Type: "script"}, []string{"command: commands: script command missing"}},
+		{Service{Type: "heartbeat", URL: "backup"}, []string{"token: commands: heartbeat check needs a token"}},
+		{Service{Type: "oauth2", TokenURL: "idp"}, []string{"token_url: " + ErrInvalidURL.Error(), "client_id: " + ErrMissingClientID.Error()}},
+		{Service{Type: "nope"}, []string{"type: nope: commands: unknown checker type"}},
+		{Service{Type: "icmp", URL: "db", IPFamily: "v6"}, nil},
+		{Service{Type: "tcp", URL: "db", Port: "5432", IPFamily: "both"}, nil},
+		{Service{Type: "grep", URL: "http://example.com", Regex: "ok", IPFamily: "v4"}, nil},
+		{Service{Type: "grep", URL: "http://example.com", Regex: "ok", IPFamily: "both"}, []string{"ip_family: " + ErrIPFamilyBoth.Error()}},
+		{Service{Type: "docker", Container: "db", IPFamily: "v4"}, []string{"ip_family: " + ErrIPFamilyIgnored.Error()}},
+		{Service{Type: ""}, []string{"type: commands: unknown checker type"}},
+		// every problem is reported, not only the first
+		{Service{Type: "grep", Severity: "huge", AlertCooldown: "soon"}, []string{"url:", "regex:", "severity:", "alert_cooldown:"}},
+	} {
+		errs := ValidateService(tc.service)
+		if len(errs) != len(tc.expected) {
+			t.Errorf("%+v: expected %v got %v", tc.service, tc.expected, errs)
+			continue
+		}
+		for i, err := range errs {
+			if !strings.HasPrefix(err.Error(), tc.expected[i]) {
+				t.Errorf("%+v: expected %q got %q", tc.service, tc.expected[i], err)
+			}
+		}
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	errs := ValidateServices([]Service{
+		{Name: "web", Type: "ping", URL: "http://web"},
+		{Name: "api", Type: "grep", URL: "http://api"},
+		{Name: "web", Type: "ping", URL: "http://other"},
+	})
+	if len(errs) != 2 || errs[0].Error() != "services[1].regex: "+ErrMissingRegex.Error() || errs[1].Error() != "services[2].name: "+ErrDuplicateService.Error() {
+		t.Errorf("unexpected %v", errs)
+	}
+}
+
+func TestValidateNotifier(t *testing.T) {
+	for c, expected := range map[*NotifierConfig]string{
+		{Type: "log"}:                                        "",
+		{Type: "pagerduty"}:                                  "type: pagerduty: " + ErrMissingRoutingKey.Error(),
+		{Type: "ntfy", Topic: "a", URL: "x"}:                 "url: " + ErrInvalidURL.Error(),
+		{Type: "mqtt", Topic: "a", URL: "tcp://broker:1883"}: "",
+	} {
+		got := ""
+		if err := ValidateNotifier(*c); err != nil {
+			got = err.Error()
+		}
+		if got != expected {
+			t.Errorf("%+v: expected %q got %q", c, expected, got)
+		}
+	}
+}
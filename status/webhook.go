@@ -0,0 +1,114 @@
+package status
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// Errors returned by the webhook notifier
+var (
+	ErrInvalidWebhookURL = errors.New("notify: webhook needs an http or https url")
+	ErrWebhookRejected   = errors.New("notify: webhook rejected the alert")
+)
+
+// WebhookNotifier sends alerts as JSON to an HTTP endpoint
+type WebhookNotifier struct {
+	URL string
+	// Method defaults to POST
+	Method  string
+	Headers map[string]string
+	// Username and Password are sent with basic auth and Token
+	// as a bearer token when set
+	Username string
+	Password string
+	Token    string
+	// Secret, when set, signs the body with HMAC-SHA256 in the
+	// X-Signature header so receivers can verify the sender
+	Secret string
+	Client *http.Client
+}
+
+// newWebhookNotifier returns the WebhookNotifier for c, resolving
+// the secrets read from the environment
+func newWebhookNotifier(c NotifierConfig) (*WebhookNotifier, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	headers := make(map[string]string, len(c.Headers))
+	for k, v := range c.Headers {
+		headers[k] = readSecret(v)
+	}
+	return &WebhookNotifier{
+		URL:      c.URL,
+		Method:   c.Method,
+		Headers:  headers,
+		Username: c.Username,
+		Password: readSecret(c.Password),
+		Token:    readSecret(c.Token),
+		Secret:   readSecret(c.Secret),
+	}, nil
+}
+
+// webhookPayload is the body of a webhook request, the alert
+// with the colour of its type for chat integrations
+type webhookPayload struct {
+	Alert
+	Color string `json:"color"`
+}
+
+// Notify sends the alert as the request body
+func (n *WebhookNotifier) Notify(a Alert) error {
+	body, err := json.Marshal(webhookPayload{Alert: a, Color: a.Type.Color()})
+	if err != nil {
+		return err
+	}
+
+	method := n.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Username != "" {
+		req.SetBasicAuth(n.Username, n.Password)
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	for k, v := range n.Headers {
+		req.Header.Set(k, v)
+	}
+	if n.Secret != "" {
+		req.Header.Set("X-Signature", SignPayload(n.Secret, body))
+	}
+
+	resp, err := clientOrDefault(n.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{Err: ErrWebhookRejected, Code: resp.StatusCode}
+	}
+	return nil
+}
+
+// SignPayload returns the X-Signature header value for body, the hex
+// HMAC-SHA256 of the exact bytes sent prefixed with "sha256="
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
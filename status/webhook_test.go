@@ -0,0 +1,127 @@
+package status
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var got *http.Request
+	var alert Alert
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		json.NewDecoder(r.Body).Decode(&alert)
+	}))
+	defer ts.Close()
+
+	os.Setenv("STATUS_TEST_TOKEN", "t0ken")
+	defer os.Unsetenv("STATUS_TEST_TOKEN")
+
+	tt := []struct {
+		name   string
+		config NotifierConfig
+		check  func(r *http.Request) bool
+	}{
+		{
+			name:   "default post",
+			config: NotifierConfig{},
+			check:  func(r *http.Request) bool { return r.Method == http.MethodPost && r.Header.Get("Authorization") == "" },
+		},
+		{
+			name:   "method and headers",
+			config: NotifierConfig{Method: http.MethodPut, Headers: map[string]string{"X-Team": "ops"}},
+			check:  func(r *http.Request) bool { return r.Method == http.MethodPut && r.Header.Get("X-Team") == "ops" },
+		},
+		{
+			name:   "basic auth",
+			config: NotifierConfig{Username: "status", Password: "secret"},
+			check: func(r *http.Request) bool {
+				u, p, ok := r.BasicAuth()
+				return ok && u == "status" && p == "secret"
+			},
+		},
+		{
+			name:   "bearer token from env",
+			config: NotifierConfig{Token: "env:STATUS_TEST_TOKEN"},
+			check:  func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer t0ken" },
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.Type = "webhook"
+			tc.config.URL = ts.URL
+			n, err := NewNotifier(tc.config)
+			if err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if err := n.Notify(Alert{Type: AlertTypeDown, Service: Service{Name: "api"}}); err != nil {
+				t.Fatalf("expected nil got %v", err)
+			}
+			if !tc.check(got) {
+				t.Errorf("unexpected request %s %v", got.Method, got.Header)
+			}
+			if alert.Type != AlertTypeDown || alert.Service.Name != "api" {
+				t.Errorf("unexpected payload %+v", alert)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	n := &WebhookNotifier{URL: ts.URL}
+	err := n.Notify(Alert{Type: AlertTypeDown})
+	if !errors.Is(err, ErrWebhookRejected) {
+		t.Errorf("expected %v got %v", ErrWebhookRejected, err)
+	}
+	var se *StatusError
+	if !errors.As(err, &se) || se.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d got %v", http.StatusUnauthorized, err)
+	}
+	if _, err := NewNotifier(NotifierConfig{Type: "webhook", URL: "ftp://example.com"}); err != ErrInvalidWebhookURL {
+		t.Errorf("expected %v got %v", ErrInvalidWebhookURL, err)
+	}
+}
+
+func TestWebhookNotifierSignature(t *testing.T) {
+	var signature string
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Signature")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	n, _ := NewNotifier(NotifierConfig{Type: "webhook", URL: ts.URL, Secret: "shh"})
+	if err := n.Notify(Alert{Type: AlertTypeDown, Service: Service{Name: "api"}}); err != nil {
+		t.Fatalf("expected nil got %v", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("shh"))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if signature != expected {
+		t.Errorf("expected %v got %v", expected, signature)
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	// HMAC-SHA256 test case 2 from RFC 4231
+	expected := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if actual := SignPayload("Jefe", []byte("what do ya want for nothing?")); actual != expected {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+}